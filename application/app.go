@@ -2,8 +2,21 @@ package application
 
 import (
 	"bless-activity/controller"
+	_ "bless-activity/migrations"
+	"bless-activity/model"
 	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/export"
 	"bless-activity/service/fishpi"
+	"bless-activity/service/fulfillment"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"bless-activity/service/quota"
+	"bless-activity/service/ratelimit"
+	"bless-activity/service/report"
+	"bless-activity/service/settlement"
+	"bless-activity/service/table"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -11,6 +24,7 @@ import (
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"github.com/pocketbase/pocketbase/tools/hook"
 )
 
@@ -35,11 +49,36 @@ import (
 
 */
 
+// skipBindAnnotation 带有该注解的命令不执行迁移与服务初始化
+const skipBindAnnotation = "skip_bind"
+
 type Application struct {
 	app *pocketbase.PocketBase
 
-	fishPiService  *fishpi.Service
-	articleService *service.ArticleService
+	fishPiService       *fishpi.Service
+	userService         *service.UserService
+	sessionService      *service.SessionService
+	configRegistry      *config.Registry
+	articleService      *service.ArticleService
+	auditService        *service.AuditService
+	activityService     *service.ActivityService
+	thankService        *service.ThankService
+	payoutService       *service.PayoutService
+	paidDrawService     *service.PaidDrawService
+	quotaService        *quota.Service
+	inventoryService    *inventory.Service
+	fulfillmentService  *fulfillment.Service
+	userRewardService   *service.UserRewardService
+	awardService        *service.AwardService
+	snapshotService     *service.SnapshotService
+	notificationService *service.NotificationService
+	championService     *service.ChampionService
+	tableService        *table.Service
+	ledgerService       *ledger.Service
+	settlementEngine    *settlement.Engine
+	phaseScheduler      *service.PhaseScheduler
+	exportService       *export.Service
+	reportService       *report.Service
 
 	baseController     *controller.BaseController
 	fishPiController   *controller.FishPiController
@@ -47,11 +86,15 @@ type Application struct {
 	mooncakeController *controller.MooncakeController
 	voteController     *controller.VoteController
 	activityController *controller.ActivityController
+	adminController    *controller.AdminController
+	tableController    *controller.TableController
 }
 
 func NewApp() *Application {
-	app := pocketbase.New()
+	return newApplication(pocketbase.New())
+}
 
+func newApplication(app *pocketbase.PocketBase) *Application {
 	application := &Application{
 		app: app,
 	}
@@ -60,6 +103,24 @@ func NewApp() *Application {
 }
 
 func (application *Application) Start() error {
+	// migrate 命令，新建的迁移文件写入 migrations 目录
+	migratecmd.MustRegister(application.app, application.app.RootCmd, migratecmd.Config{
+		Dir:          "migrations",
+		TemplateLang: migratecmd.TemplateLangGo,
+	})
+
+	application.app.RootCmd.AddCommand(application.simulateCommand(), application.configsCommand())
+
+	// 模拟命令使用独立的临时应用、生成密钥不需要读取配置，这些命令不初始化当前数据目录
+	if command, _, err := application.app.RootCmd.Find(os.Args[1:]); err != nil || command.Annotations[skipBindAnnotation] == "" {
+		application.bind()
+	}
+
+	return application.app.Start()
+}
+
+// bind 注册初始化钩子：执行数据库迁移后初始化服务，prepare 在迁移之后、初始化之前执行
+func (application *Application) bind(prepare ...func(app core.App) error) {
 
 	// 初始化
 	application.app.OnBootstrap().BindFunc(func(event *core.BootstrapEvent) error {
@@ -68,25 +129,102 @@ func (application *Application) Start() error {
 			return err
 		}
 
+		// 服务初始化依赖集合与默认配置，不能等到 serve 时再迁移
+		if err := event.App.RunAllMigrations(); err != nil {
+			event.App.Logger().Error("执行数据库迁移失败", slog.Any("err", err))
+			return err
+		}
+
+		for _, fn := range prepare {
+			if err := fn(event.App); err != nil {
+				return err
+			}
+		}
+
 		return application.init(event)
 	})
-
-	return application.app.Start()
 }
 
 func (application *Application) init(event *core.BootstrapEvent) error {
 	event.App.Logger().Debug("初始化程序")
 
-	var err error
-	if application.fishPiService, err = fishpi.NewService(event.App); err != nil {
+	// 敏感配置的加密密钥
+	keyring, err := config.LoadKeyring()
+	if err != nil {
+		event.App.Logger().Error("读取配置加密密钥失败", slog.Any("err", err))
+		return err
+	}
+	if keyring == nil {
+		event.App.Logger().Warn("未配置配置加密密钥，敏感配置将以明文保存")
+	}
+
+	// 配置注册表，修改配置后订阅的服务即时重新加载
+	application.configRegistry = config.NewRegistry(event.App, keyring)
+	application.configRegistry.Register(
+		config.Definition{Key: model.ConfigKeyFishpi, Default: func() any { return fishpi.DefaultConfig() }},
+		config.Definition{Key: model.ConfigKeyRatelimit, Default: func() any {
+			rules := ratelimit.DefaultRules()
+			return &rules
+		}},
+		config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+			schedule := service.DefaultSchedule()
+			return &schedule
+		}},
+		config.Definition{Key: model.ConfigKeyChampion, Default: func() any { return &service.ChampionConfig{} }},
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+		config.Definition{Key: model.ConfigKeyPaidDraw, Default: func() any { return &service.PaidDrawConfig{} }},
+		config.Definition{Key: model.ConfigKeyReport, Default: func() any { return report.DefaultConfig() }},
+		config.Definition{Key: model.ConfigKeyQuota, Default: func() any { return quota.DefaultConfig() }},
+	)
+	if err := application.configRegistry.Load(); err != nil {
+		event.App.Logger().Error("加载配置失败", slog.Any("err", err))
+		return err
+	}
+
+	if application.fishPiService, err = fishpi.NewService(event.App, application.configRegistry); err != nil {
 		event.App.Logger().Error("创建fishPi Service失败", slog.Any("err", err))
 		return err
 	}
 
+	application.userService = service.NewUserService(event.App)
+	application.sessionService = service.NewSessionService(event.App)
+
+	application.auditService = service.NewAuditService(event.App)
+	application.activityService = service.NewActivityService(event.App, application.configRegistry)
+	application.thankService = service.NewThankService(event.App, application.fishPiService, application.activityService)
+
 	// 文章爬取服务
-	application.articleService = service.NewArticleService(event.App, application.fishPiService)
+	application.articleService = service.NewArticleService(event.App, application.fishPiService, application.userService, application.thankService)
 	//application.articleService.Start()
 	//go application.articleService.FetchArticles()
+	application.ledgerService = ledger.NewService(event.App, application.fishPiService, application.configRegistry)
+	application.ledgerService.Start()
+	application.paidDrawService = service.NewPaidDrawService(event.App, application.ledgerService, application.configRegistry)
+	application.quotaService = quota.NewService(event.App, application.configRegistry, application.thankService, application.paidDrawService)
+	application.snapshotService = service.NewSnapshotService(event.App)
+	application.notificationService = service.NewNotificationService(event.App)
+	application.ledgerService.OnTrip(application.alertGuardTrip)
+	application.inventoryService = inventory.NewService(event.App)
+	application.inventoryService.OnLowStock(application.alertLowStock)
+	application.fulfillmentService = fulfillment.NewService(event.App, application.fishPiService)
+	application.inventoryService.OnReserve(application.fulfillmentService.Allocate)
+	application.inventoryService.OnRelease(application.fulfillmentService.Revoke)
+	application.userRewardService = service.NewUserRewardService(event.App, application.ledgerService, application.notificationService)
+	application.userRewardService.Start()
+	application.awardService = service.NewAwardService(event.App, application.inventoryService)
+	application.payoutService = service.NewPayoutService(event.App, application.ledgerService, application.inventoryService)
+	application.championService = service.NewChampionService(event.App, application.configRegistry, application.notificationService)
+	application.tableService = table.NewService(event.App)
+	application.tableService.Watch()
+	application.settlementEngine = settlement.NewEngine(event.App, application.ledgerService, application.inventoryService, []byte(os.Getenv(settlement.SigningKeyEnv)))
+	application.exportService = export.NewService(event.App)
+	application.exportService.Start()
+	application.reportService = report.NewService(event.App, application.fishPiService, application.configRegistry)
+
+	// 活动阶段调度
+	application.phaseScheduler = service.NewPhaseScheduler(event.App, application.activityService)
+	application.registerPhaseHooks()
+	application.phaseScheduler.Start()
 
 	// 问题修复
 	if err = application.fixBug(event); err != nil {
@@ -100,54 +238,51 @@ func (application *Application) init(event *core.BootstrapEvent) error {
 	return nil
 }
 
+// alertGuardTrip 支出熔断时提醒所有运营，直到重置前发放保持暂停
+func (application *Application) alertGuardTrip(trip *model.GuardTrip) {
+	count, err := application.notificationService.SendRole(nil, model.UserRoleOperator, service.NotificationTypeGuardTripped,
+		"积分发放已熔断", trip.Message()+"，所有发放已暂停，请检查后在后台重置",
+		map[string]any{"trip_id": trip.Id, "reason": trip.Reason(), "points_id": trip.PointsId()})
+	if err != nil {
+		application.app.Logger().Error("发送熔断提醒失败", slog.String("trip_id", trip.Id), slog.Any("err", err))
+		return
+	}
+	if count == 0 {
+		application.app.Logger().Warn("没有运营账号可以接收熔断提醒", slog.String("trip_id", trip.Id))
+	}
+}
+
+// alertLowStock 奖品库存降到提醒阈值或发完时提醒所有运营，可在后台补货或下架
+func (application *Application) alertLowStock(txApp core.App, stock inventory.Stock) {
+	content := fmt.Sprintf("奖品「%s」剩余 %d 份，请及时补货", stock.Name, stock.Remaining)
+	if stock.Remaining == 0 {
+		content = fmt.Sprintf("奖品「%s」已发完，共 %d 份", stock.Name, stock.Amount)
+	}
+	if _, err := application.notificationService.SendRole(txApp, model.UserRoleOperator, service.NotificationTypeLowStock,
+		"奖品库存不足", content, stock); err != nil {
+		// 提醒失败不影响博饼
+		application.app.Logger().Error("发送库存提醒失败", slog.String("reward_id", stock.RewardId), slog.Any("err", err))
+	}
+}
+
 func (application *Application) registerRoutes(event *core.ServeEvent) error {
 
-	event.Router.Bind(
-		&hook.Handler[*core.RequestEvent]{
-			Id:       "cookie_to_header",
-			Priority: apis.DefaultRateLimitMiddlewarePriority - 999,
-			Func: func(event *core.RequestEvent) error {
-				if event.Request.Header.Get("Authorization") != "" {
-					return event.Next()
-				}
-
-				cookie, err := event.Request.Cookie("token")
-				if err != nil {
-					return event.Next()
-				}
-				if err = cookie.Valid(); err != nil {
-					return event.Next()
-				}
-				event.Request.Header.Set("Authorization", cookie.Value)
-
-				return event.Next()
-			},
-		},
-		&hook.Handler[*core.RequestEvent]{
-			Id:       "query_to_header",
-			Priority: apis.DefaultRateLimitMiddlewarePriority - 998,
-			Func: func(event *core.RequestEvent) error {
-				if event.Request.Header.Get("Authorization") != "" {
-					return event.Next()
-				}
-
-				queryAuth := event.Request.URL.Query().Get("authorization")
-				if queryAuth == "" {
-					return event.Next()
-				}
-				event.Request.Header.Set("Authorization", queryAuth)
-
-				return event.Next()
-			},
-		},
-	)
+	application.baseController = controller.NewBaseController(event, application.sessionService, application.activityService, application.configRegistry)
+
+	// 会话 cookie 转为登录用户，需在 PocketBase 加载 Authorization token 之前执行
+	event.Router.Bind(&hook.Handler[*core.RequestEvent]{
+		Id:       "load_session",
+		Priority: apis.DefaultRateLimitMiddlewarePriority - 999,
+		Func:     application.baseController.LoadSession,
+	})
 
-	application.baseController = controller.NewBaseController(event)
-	application.fishPiController = controller.NewFishPiController(event)
-	application.userController = controller.NewUserController(event)
-	application.mooncakeController = controller.NewMooncakeController(event, application.fishPiService, application.baseController)
+	application.fishPiController = controller.NewFishPiController(event, application.userService, application.sessionService)
+	application.userController = controller.NewUserController(event, application.sessionService, application.notificationService, application.fulfillmentService, application.userRewardService, application.quotaService, application.baseController)
+	application.mooncakeController = controller.NewMooncakeController(event, application.fishPiService, application.ledgerService, application.paidDrawService, application.awardService, application.championService, application.quotaService, application.baseController)
+	application.tableController = controller.NewTableController(event, application.tableService, application.baseController)
 	application.voteController = controller.NewVoteController(event, application.baseController)
-	application.activityController = controller.NewActivityController(event)
+	application.activityController = controller.NewActivityController(event, application.activityService, application.snapshotService)
+	application.adminController = controller.NewAdminController(event, application.auditService, application.activityService, application.payoutService, application.ledgerService, application.inventoryService, application.fulfillmentService, application.awardService, application.settlementEngine, application.configRegistry, application.exportService, application.reportService, application.quotaService)
 
 	event.Router.GET("/test", func(e *core.RequestEvent) error {
 		return e.String(http.StatusOK, "test")
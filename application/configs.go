@@ -0,0 +1,47 @@
+package application
+
+import (
+	"bless-activity/service/config"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// configsCommand 配置加密相关的命令
+func (application *Application) configsCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "configs",
+		Short: "配置加密密钥管理",
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:          "keygen",
+		Short:        "生成新的配置加密密钥，放在 " + config.KeysEnv + " 的最前面作为主密钥",
+		SilenceUsage: true,
+		Annotations:  map[string]string{skipBindAnnotation: "true"},
+		RunE: func(command *cobra.Command, args []string) error {
+			key, err := config.GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(command.OutOrStdout(), key)
+			return nil
+		},
+	})
+
+	command.AddCommand(&cobra.Command{
+		Use:          "reencrypt",
+		Short:        "使用当前主密钥重新加密已保存的敏感配置",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			count, err := application.configRegistry.Reencrypt()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(command.OutOrStdout(), "已重新加密 %d 条配置\n", count)
+			return nil
+		},
+	})
+
+	return command
+}
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"log/slog"
+)
+
+// registerPhaseHooks 注册阶段切换任务，同一阶段内按注册顺序执行
+func (application *Application) registerPhaseHooks() {
+	scheduler := application.phaseScheduler
+
+	// 进入结算阶段：冻结投票结果、状元结算、发放文章排名奖励
+	scheduler.Register(model.ActivityPhaseSettlement, "freeze_votes", application.freezeVotes)
+	scheduler.Register(model.ActivityPhaseSettlement, "settle_best", application.settleBest)
+	scheduler.Register(model.ActivityPhaseSettlement, "pay_rank_rewards", application.runPayout(service.PayoutJobArticleReward))
+
+	// 进入归档阶段：生成最终活动结果快照
+	scheduler.Register(model.ActivityPhaseArchive, "final_snapshot", application.finalSnapshot)
+}
+
+// freezeVotes 投票接口在结算阶段已关闭，这里记录冻结时的福签统计
+func (application *Application) freezeVotes() error {
+	counts, err := application.snapshotService.VoteCounts()
+	if err != nil {
+		return err
+	}
+	return application.snapshotService.Save(service.SnapshotVotesFrozen, model.ActivityPhaseSettlement, counts)
+}
+
+func (application *Application) runPayout(job string) service.PhaseHookFunc {
+	return func() error {
+		summary, err := application.payoutService.Run(job)
+		if err != nil {
+			return err
+		}
+		application.app.Logger().Info("阶段发放任务完成",
+			slog.String("job", summary.Job),
+			slog.Int("total", summary.Total),
+			slog.Int("success", summary.Success),
+			slog.Int("failed", summary.Failed))
+		return nil
+	}
+}
+
+// settleBest 状元结算，结算报告保存在 settlements 中
+func (application *Application) settleBest() error {
+	_, err := application.settlementEngine.Run()
+	return err
+}
+
+// finalSnapshot 保存最终活动结果，之后 /activity/result 直接返回快照
+func (application *Application) finalSnapshot() error {
+	result, err := application.activityService.Result()
+	if err != nil {
+		return err
+	}
+	return application.snapshotService.Save(service.SnapshotFinal, model.ActivityPhaseArchive, result)
+}
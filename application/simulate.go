@@ -0,0 +1,130 @@
+package application
+
+import (
+	"bless-activity/service/simulator"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// simulateCommand 在临时数据目录中启动完整应用，预置用户与文章后通过真实路由回放投票与博饼流量
+func (application *Application) simulateCommand() *cobra.Command {
+	options := simulator.Options{}
+	keep := false
+	jsonOutput := false
+
+	command := &cobra.Command{
+		Use:          "simulate",
+		Short:        "在临时数据目录中模拟一次活动并输出压测报告",
+		SilenceUsage: true,
+		Annotations:  map[string]string{skipBindAnnotation: "true"},
+		RunE: func(command *cobra.Command, args []string) error {
+			report, dir, err := simulate(command, options, keep)
+			if dir != "" && keep {
+				fmt.Fprintf(command.ErrOrStderr(), "模拟数据目录: %s\n", dir)
+			}
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(command.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+			report.Write(command.OutOrStdout())
+			return nil
+		},
+	}
+
+	flags := command.Flags()
+	flags.IntVar(&options.Users, "users", 200, "模拟用户数量")
+	flags.IntVar(&options.Concurrency, "concurrency", 50, "同时在线的用户数量")
+	flags.IntVar(&options.VotesPerUser, "votes", 3, "每位用户尝试赠送的福签数量")
+	flags.IntVar(&options.MaxThankCnt, "max-thanks", 20, "文章感谢数的上限")
+	flags.IntVar(&options.ExtraDraws, "extra-draws", 2, "次数用完后继续尝试博饼的次数")
+	flags.BoolVar(&options.RateLimit, "ratelimit", false, "启用默认限流规则")
+	flags.Float64Var(&options.FishpiFailRate, "fishpi-fail-rate", 0, "模拟积分发放失败的比例")
+	flags.Uint64Var(&options.Seed, "seed", 0, "随机种子，默认使用当前时间")
+	flags.BoolVar(&keep, "keep", false, "保留模拟数据目录")
+	flags.BoolVar(&jsonOutput, "json", false, "以 JSON 格式输出报告")
+
+	return command
+}
+
+func simulate(command *cobra.Command, options simulator.Options, keep bool) (*simulator.Report, string, error) {
+	dir, err := os.MkdirTemp("", "bless-activity-simulate-*")
+	if err != nil {
+		return nil, "", err
+	}
+	if !keep {
+		defer os.RemoveAll(dir)
+	}
+
+	fishpi := simulator.NewFakeFishpi(options.FishpiFailRate)
+	defer fishpi.Close()
+
+	application := newApplication(pocketbase.NewWithConfig(pocketbase.Config{
+		DefaultDataDir:  dir,
+		HideStartBanner: true,
+	}))
+
+	// 迁移完成后、应用初始化之前预置数据
+	var harness *simulator.Simulator
+	application.bind(func(app core.App) (err error) {
+		harness, err = simulator.Prepare(app, fishpi.URL(), options)
+		return err
+	})
+
+	if err = application.app.Bootstrap(); err != nil {
+		return nil, dir, fmt.Errorf("初始化模拟应用失败: %w", err)
+	}
+	// 与 PocketBase 命令结束时一致，触发 OnTerminate 停止后台任务后释放资源
+	defer application.app.OnTerminate().Trigger(&core.TerminateEvent{App: application.app}, func(event *core.TerminateEvent) error {
+		return event.App.ResetBootstrapState()
+	})
+
+	handler, err := application.handler()
+	if err != nil {
+		return nil, dir, err
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	report, err := harness.Run(command.Context(), server.URL, fishpi)
+	return report, dir, err
+}
+
+// handler 触发 OnServe 注册路由并返回 HTTP 处理器，不监听端口
+func (application *Application) handler() (http.Handler, error) {
+	router, err := apis.NewRouter(application.app)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler http.Handler
+	event := &core.ServeEvent{
+		App:    application.app,
+		Router: router,
+		Server: &http.Server{},
+	}
+	err = application.app.OnServe().Trigger(event, func(event *core.ServeEvent) error {
+		mux, err := event.Router.BuildMux()
+		if err != nil {
+			return err
+		}
+		handler = mux
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("注册路由失败: %w", err)
+	}
+	return handler, nil
+}
@@ -1,11 +1,13 @@
 package controller
 
 import (
-	"bless-activity/model"
+	"bless-activity/service"
+	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
-	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
@@ -13,18 +15,22 @@ type ActivityController struct {
 	event *core.ServeEvent
 	app   core.App
 
-	logger *slog.Logger
+	logger          *slog.Logger
+	activityService *service.ActivityService
+	snapshotService *service.SnapshotService
 }
 
-func NewActivityController(event *core.ServeEvent) *ActivityController {
+func NewActivityController(event *core.ServeEvent, activityService *service.ActivityService, snapshotService *service.SnapshotService) *ActivityController {
 	logger := event.App.Logger().With(
 		slog.String("controller", "activity"),
 	)
 
 	controller := &ActivityController{
-		event:  event,
-		app:    event.App,
-		logger: logger,
+		event:           event,
+		app:             event.App,
+		logger:          logger,
+		activityService: activityService,
+		snapshotService: snapshotService,
 	}
 
 	controller.registerRoutes()
@@ -35,6 +41,7 @@ func NewActivityController(event *core.ServeEvent) *ActivityController {
 func (controller *ActivityController) registerRoutes() {
 	group := controller.event.Router.Group("/activity")
 	group.GET("/result", controller.GetActivityResult)
+	group.GET("/phase", controller.GetPhase)
 }
 
 func (controller *ActivityController) makeActionLogger(action string) *slog.Logger {
@@ -43,215 +50,82 @@ func (controller *ActivityController) makeActionLogger(action string) *slog.Logg
 	)
 }
 
-// GetActivityResult 获取活动结果数据
-func (controller *ActivityController) GetActivityResult(event *core.RequestEvent) error {
-	logger := controller.makeActionLogger("get_activity_result")
-
-	// 1. 获取博饼信息（按奖励等级分组统计）
-	var gamingResults []struct {
-		RewardId    string `db:"rewardId" json:"reward_id"`
-		RewardName  string `db:"rewardName" json:"reward_name"`
-		RewardLevel int    `db:"rewardLevel" json:"reward_level"`
-		UserId      string `db:"userId" json:"user_id"`
-		Username    string `db:"username" json:"username"`
-		Nickname    string `db:"nickname" json:"nickname"`
-		Avatar      string `db:"avatar" json:"avatar"`
-		Count       int    `db:"count" json:"count"`
-		IsBest      bool   `db:"isBest" json:"is_best"`
-		Details     string `db:"details" json:"details"`
-		Created     string `db:"created" json:"created"`
-		Times       int    `db:"times" json:"times"`
-	}
-
-	err := controller.app.DB().
-		NewQuery(`
-			SELECT h.rewardId, r.name as rewardName, r.level as rewardLevel,
-			       h.userId, u.name as username, u.nickname, u.avatar,
-			       COUNT(*) as count,
-			       MAX(h.isBest) as isBest,
-			       MAX(h.details) as details,
-			       MAX(h.created) as created,
-			       MAX(h.times) as times
-			FROM histories h
-			LEFT JOIN users u ON h.userId = u.id
-			LEFT JOIN rewards r ON h.rewardId = r.id
-			GROUP BY h.rewardId, h.userId
-			ORDER BY r.level DESC, count DESC
-		`).
-		All(&gamingResults)
+// GetPhase 获取当前活动阶段与倒计时
+func (controller *ActivityController) GetPhase(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_phase")
 
+	schedule, err := controller.activityService.Schedule()
 	if err != nil {
-		logger.Error("查询博饼信息失败", slog.Any("err", err))
-		return event.InternalServerError("查询博饼信息失败", err)
+		logger.Error("获取活动日程失败", slog.Any("err", err))
+		return event.InternalServerError("获取活动日程失败", err)
 	}
 
-	// 2. 获取三种福签的获得信息（包含投票者信息）
-	var voteDetails []struct {
-		ToUserId     string `db:"toUserId" json:"to_user_id"`
-		ToUsername   string `db:"toUsername" json:"to_username"`
-		ToNickname   string `db:"toNickname" json:"to_nickname"`
-		ToAvatar     string `db:"toAvatar" json:"to_avatar"`
-		FromUserId   string `db:"fromUserId" json:"from_user_id"`
-		FromUsername string `db:"fromUsername" json:"from_username"`
-		FromNickname string `db:"fromNickname" json:"from_nickname"`
-		FromAvatar   string `db:"fromAvatar" json:"from_avatar"`
-		VoteType     string `db:"voteType" json:"vote_type"`
-		ArticleId    string `db:"articleId" json:"article_id"`
-		Created      string `db:"created" json:"created"`
+	now := time.Now()
+	result := map[string]any{
+		"server_time": now,
+		"phase":       nil,
+		"next_phase":  nil,
+		"phases":      phasesResponse(schedule.Phases),
 	}
 
-	err = controller.app.DB().
-		NewQuery(`
-			SELECT v.toUserId, v.fromUserId, v.voteType, v.articleId, v.created,
-			       toUser.name as toUsername, toUser.nickname as toNickname, toUser.avatar as toAvatar,
-			       fromUser.name as fromUsername, fromUser.nickname as fromNickname, fromUser.avatar as fromAvatar
-			FROM votes v
-			LEFT JOIN users toUser ON v.toUserId = toUser.id
-			LEFT JOIN users fromUser ON v.fromUserId = fromUser.id
-			ORDER BY v.voteType, v.created DESC
-		`).
-		All(&voteDetails)
-
-	if err != nil {
-		logger.Error("查询福签信息失败", slog.Any("err", err))
-		return event.InternalServerError("查询福签信息失败", err)
+	if phase := schedule.PhaseAt(now); phase != nil {
+		current := phaseResponse(*phase)
+		// countdown 为距当前阶段结束的秒数，阶段不结束时为 -1
+		current["countdown"] = -1
+		if !phase.EndAt.IsZero() {
+			current["countdown"] = int64(phase.EndAt.Sub(now).Seconds())
+		}
+		result["phase"] = current
 	}
-
-	// 按福签类型和接收者分组
-	type VoteRecipient struct {
-		UserId   string                   `json:"user_id"`
-		Username string                   `json:"username"`
-		Nickname string                   `json:"nickname"`
-		Avatar   string                   `json:"avatar"`
-		Count    int                      `json:"count"`
-		Voters   []map[string]interface{} `json:"voters"`
+	if next := schedule.NextAfter(now); next != nil {
+		upcoming := phaseResponse(*next)
+		upcoming["countdown"] = int64(next.StartAt.Sub(now).Seconds())
+		result["next_phase"] = upcoming
 	}
 
-	careerVotesMap := make(map[string]*VoteRecipient)
-	romanceVotesMap := make(map[string]*VoteRecipient)
-	wealthVotesMap := make(map[string]*VoteRecipient)
-
-	for _, vote := range voteDetails {
-		voter := map[string]interface{}{
-			"user_id":  vote.FromUserId,
-			"username": vote.FromUsername,
-			"nickname": vote.FromNickname,
-			"avatar":   vote.FromAvatar,
-			"created":  vote.Created,
-		}
-
-		var targetMap map[string]*VoteRecipient
-		switch vote.VoteType {
-		case model.VoteTypeCareer:
-			targetMap = careerVotesMap
-		case model.VoteTypeRomance:
-			targetMap = romanceVotesMap
-		case model.VoteTypeWealth:
-			targetMap = wealthVotesMap
-		default:
-			continue
-		}
-
-		if targetMap[vote.ToUserId] == nil {
-			targetMap[vote.ToUserId] = &VoteRecipient{
-				UserId:   vote.ToUserId,
-				Username: vote.ToUsername,
-				Nickname: vote.ToNickname,
-				Avatar:   vote.ToAvatar,
-				Count:    0,
-				Voters:   []map[string]interface{}{},
-			}
-		}
-
-		targetMap[vote.ToUserId].Count++
-		targetMap[vote.ToUserId].Voters = append(targetMap[vote.ToUserId].Voters, voter)
-	}
+	return event.JSON(http.StatusOK, result)
+}
 
-	// 转换为数组并排序
-	careerVotes := make([]interface{}, 0, len(careerVotesMap))
-	for _, v := range careerVotesMap {
-		careerVotes = append(careerVotes, v)
-	}
+// GetActivityResult 获取活动结果数据，活动归档后返回最终快照
+func (controller *ActivityController) GetActivityResult(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_activity_result")
 
-	romanceVotes := make([]interface{}, 0, len(romanceVotesMap))
-	for _, v := range romanceVotesMap {
-		romanceVotes = append(romanceVotes, v)
+	snapshot, err := controller.snapshotService.Find(service.SnapshotFinal)
+	if err == nil {
+		return event.Blob(http.StatusOK, "application/json", []byte(snapshot.Data()))
 	}
-
-	wealthVotes := make([]interface{}, 0, len(wealthVotesMap))
-	for _, v := range wealthVotesMap {
-		wealthVotes = append(wealthVotes, v)
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Warn("查询最终快照失败", slog.Any("err", err))
 	}
 
-	// 3. 获取文章排名信息
-	articles := []*model.Article{}
-	err = controller.app.RecordQuery(model.DbNameArticles).
-		OrderBy(model.ArticlesFieldScore + " DESC").
-		Limit(100).
-		All(&articles)
-
+	data, err := controller.activityService.Result()
 	if err != nil {
-		logger.Error("查询文章排名失败", slog.Any("err", err))
-		return event.InternalServerError("查询文章排名失败", err)
+		logger.Error("获取活动结果失败", slog.Any("err", err))
+		return event.InternalServerError("获取活动结果失败", err)
 	}
 
-	// 获取文章对应的用户信息
-	articleRankings := []map[string]interface{}{}
-	for rank, article := range articles {
-		user := new(model.User)
-		err := controller.app.RecordQuery(model.DbNameUsers).
-			Where(dbx.HashExp{model.CommonFieldId: article.UserId()}).
-			One(user)
-
-		if err != nil {
-			logger.Warn("查询用户信息失败", slog.String("userId", article.UserId()), slog.Any("err", err))
-			continue
-		}
-
-		// 统计该文章收到的福签数量
-		careerCount, _ := controller.app.CountRecords(model.DbNameVotes, dbx.HashExp{
-			model.VotesFieldArticleId: article.Id,
-			model.VotesFieldVoteType:  model.VoteTypeCareer,
-		})
-		romanceCount, _ := controller.app.CountRecords(model.DbNameVotes, dbx.HashExp{
-			model.VotesFieldArticleId: article.Id,
-			model.VotesFieldVoteType:  model.VoteTypeRomance,
-		})
-		wealthCount, _ := controller.app.CountRecords(model.DbNameVotes, dbx.HashExp{
-			model.VotesFieldArticleId: article.Id,
-			model.VotesFieldVoteType:  model.VoteTypeWealth,
-		})
+	return event.JSON(http.StatusOK, data)
+}
 
-		articleRankings = append(articleRankings, map[string]interface{}{
-			"rank":            rank + 1,
-			"article_id":      article.Id,
-			"article_o_id":    article.OId(),
-			"title":           article.Title(),
-			"preview_content": article.PreviewContent(),
-			"view_count":      article.ViewCount(),
-			"good_cnt":        article.GoodCnt(),
-			"comment_count":   article.CommentCount(),
-			"collect_cnt":     article.CollectCnt(),
-			"thank_cnt":       article.ThankCnt(),
-			"score":           article.Score(),
-			"user_id":         user.Id,
-			"username":        user.Name(),
-			"nickname":        user.Nickname(),
-			"avatar":          user.Avatar(),
-			"career_votes":    careerCount,
-			"romance_votes":   romanceCount,
-			"wealth_votes":    wealthCount,
-		})
+func phaseResponse(phase service.Phase) map[string]any {
+	var startAt, endAt *time.Time
+	if !phase.StartAt.IsZero() {
+		startAt = &phase.StartAt
+	}
+	if !phase.EndAt.IsZero() {
+		endAt = &phase.EndAt
 	}
+	return map[string]any{
+		"name":     phase.Name,
+		"start_at": startAt,
+		"end_at":   endAt,
+	}
+}
 
-	// 返回所有数据
-	return event.JSON(http.StatusOK, map[string]interface{}{
-		"gaming_results": gamingResults,
-		"votes": map[string]interface{}{
-			"career":  careerVotes,
-			"romance": romanceVotes,
-			"wealth":  wealthVotes,
-		},
-		"article_rankings": articleRankings,
-	})
+func phasesResponse(phases []service.Phase) []map[string]any {
+	result := make([]map[string]any, 0, len(phases))
+	for _, phase := range phases {
+		result = append(result, phaseResponse(phase))
+	}
+	return result
 }
@@ -0,0 +1,1828 @@
+package controller
+
+import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/export"
+	"bless-activity/service/fulfillment"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"bless-activity/service/quota"
+	"bless-activity/service/report"
+	"bless-activity/service/settlement"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 200
+)
+
+// 审核结果
+const (
+	reviewResultClear      = "clear"      // 标记无问题，取消标记
+	reviewResultDisqualify = "disqualify" // 文章取消评奖资格
+	reviewResultRestore    = "restore"    // 文章恢复评奖资格
+	reviewResultRemove     = "remove"     // 删除投票
+)
+
+// AdminController 审核员与运营的管理接口，所有修改都会写入审计记录
+type AdminController struct {
+	event *core.ServeEvent
+	app   core.App
+
+	logger             *slog.Logger
+	auditService       *service.AuditService
+	activityService    *service.ActivityService
+	payoutService      *service.PayoutService
+	ledgerService      *ledger.Service
+	inventoryService   *inventory.Service
+	fulfillmentService *fulfillment.Service
+	awardService       *service.AwardService
+	settlement         *settlement.Engine
+	configRegistry     *config.Registry
+	exportService      *export.Service
+	reportService      *report.Service
+	quotaService       *quota.Service
+}
+
+func NewAdminController(event *core.ServeEvent, auditService *service.AuditService, activityService *service.ActivityService, payoutService *service.PayoutService, ledgerService *ledger.Service, inventoryService *inventory.Service, fulfillmentService *fulfillment.Service, awardService *service.AwardService, settlementEngine *settlement.Engine, configRegistry *config.Registry, exportService *export.Service, reportService *report.Service, quotaService *quota.Service) *AdminController {
+	logger := event.App.Logger().With(
+		slog.String("controller", "admin"),
+	)
+
+	controller := &AdminController{
+		event:              event,
+		app:                event.App,
+		logger:             logger,
+		auditService:       auditService,
+		activityService:    activityService,
+		payoutService:      payoutService,
+		ledgerService:      ledgerService,
+		inventoryService:   inventoryService,
+		fulfillmentService: fulfillmentService,
+		awardService:       awardService,
+		settlement:         settlementEngine,
+		configRegistry:     configRegistry,
+		exportService:      exportService,
+		reportService:      reportService,
+		quotaService:       quotaService,
+	}
+
+	controller.registerRoutes()
+
+	return controller
+}
+
+func (controller *AdminController) registerRoutes() {
+	group := controller.event.Router.Group("/admin")
+	group.BindFunc(controller.CheckLogin)
+
+	// 审计记录，审核员与运营均可查看
+	group.GET("/audits", controller.GetAudits).BindFunc(controller.CheckRole(model.UserRoleModerator, model.UserRoleOperator))
+
+	// 审核员：审核被标记的文章与投票
+	moderation := group.Group("/moderation")
+	moderation.BindFunc(controller.CheckRole(model.UserRoleModerator))
+	moderation.GET("/articles", controller.GetArticles)
+	moderation.POST("/articles/{id}/flag", controller.FlagArticle)
+	moderation.POST("/articles/{id}/review", controller.ReviewArticle)
+	moderation.GET("/votes", controller.GetVotes)
+	moderation.POST("/votes/{id}/flag", controller.FlagVote)
+	moderation.POST("/votes/{id}/review", controller.ReviewVote)
+
+	// 运营：发放任务、积分账本、奖品库存、兑奖、活动日程、系统配置、活动报告、博饼次数
+	operation := group.Group("/operation")
+	operation.BindFunc(controller.CheckRole(model.UserRoleOperator))
+	operation.GET("/payouts", controller.GetPayoutStatus)
+	operation.POST("/payouts", controller.StartPayout)
+	operation.GET("/settlements", controller.GetSettlements)
+	operation.POST("/settlements", controller.RunSettlement)
+	operation.GET("/settlements/{id}", controller.GetSettlement)
+	operation.GET("/histories/{id}/replay", controller.ReplayHistory)
+	operation.GET("/ledger/budgets", controller.GetBudgets)
+	operation.PUT("/ledger/budgets/{source}", controller.UpdateBudget)
+	operation.GET("/ledger/reconciliations", controller.GetReconciliations)
+	operation.POST("/ledger/reconciliations", controller.RunReconciliation)
+	operation.GET("/ledger/reconciliations/{id}", controller.GetReconciliation)
+	operation.GET("/ledger/unknown", controller.GetUnknownPoints)
+	operation.POST("/ledger/points/{id}/resolve", controller.ResolvePoints)
+	operation.GET("/ledger/guard", controller.GetGuard)
+	operation.POST("/ledger/guard/halt", controller.HaltGuard)
+	operation.POST("/ledger/guard/reset", controller.ResetGuard)
+	operation.GET("/rewards/stock", controller.GetRewardStocks)
+	operation.PUT("/rewards/{id}/stock", controller.UpdateRewardStock)
+	operation.POST("/rewards/{id}/retire", controller.RetireReward)
+	operation.GET("/rewards/{id}/codes", controller.GetRewardCodes)
+	operation.POST("/rewards/{id}/codes", controller.ImportRewardCodes)
+	operation.GET("/fulfillments", controller.GetFulfillments)
+	operation.POST("/fulfillments/{id}/transition", controller.TransitFulfillment)
+	operation.GET("/activity", controller.GetActivitySchedule)
+	operation.PUT("/activity", controller.UpdateActivitySchedule)
+	operation.GET("/configs", controller.GetConfigs)
+	operation.GET("/configs/{key}", controller.GetConfig)
+	operation.PATCH("/configs/{key}", controller.UpdateConfig)
+	operation.GET("/reports", controller.GetReports)
+	operation.GET("/reports/preview", controller.PreviewReport)
+	operation.POST("/reports/publish", controller.PublishReport)
+	operation.GET("/reports/{id}", controller.GetReport)
+	operation.GET("/users/{id}/quota", controller.GetUserQuota)
+	operation.GET("/draw-grants", controller.GetDrawGrants)
+	operation.POST("/draw-grants", controller.CreateDrawGrant)
+
+	// 数据导出仅限超级管理员，不经过 /admin 的 CheckLogin（超级管理员不是普通用户）
+	exports := controller.event.Router.Group("/admin/operation/exports")
+	exports.Bind(apis.RequireSuperuserAuth())
+	exports.GET("/datasets", controller.GetExportDatasets)
+	exports.POST("/stream", controller.StreamExport)
+	exports.GET("", controller.GetExports)
+	exports.POST("", controller.CreateExport)
+	exports.GET("/{id}", controller.GetExport)
+	exports.GET("/{id}/download", controller.DownloadExport)
+}
+
+func (controller *AdminController) makeActionLogger(action string) *slog.Logger {
+	return controller.logger.With(
+		slog.String("action", action),
+	)
+}
+
+func (controller *AdminController) CheckLogin(event *core.RequestEvent) error {
+	if event.Auth == nil {
+		return event.UnauthorizedError("未登录", nil)
+	}
+	if event.HasSuperuserAuth() {
+		return event.ForbiddenError("请登录普通用户账号", nil)
+	}
+	return event.Next()
+}
+
+// CheckRole 仅允许指定角色访问
+func (controller *AdminController) CheckRole(roles ...model.UserRole) func(event *core.RequestEvent) error {
+	return func(event *core.RequestEvent) error {
+		user := model.NewUser(event.Auth)
+		if !slices.Contains(roles, user.Role()) {
+			return event.ForbiddenError("没有权限", nil)
+		}
+		return event.Next()
+	}
+}
+
+// audit 在事务中写入审计记录
+func (controller *AdminController) audit(txApp core.App, event *core.RequestEvent, entry service.AuditEntry) error {
+	entry.Actor = model.NewUser(event.Auth)
+	entry.Ip = event.RealIP()
+	return controller.auditService.Record(txApp, entry)
+}
+
+// GetAudits 分页查询审计记录
+func (controller *AdminController) GetAudits(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_audits")
+
+	page, perPage := pagination(event)
+	audits, total, err := controller.auditService.List(event.Request.URL.Query().Get("action"), page, perPage)
+	if err != nil {
+		logger.Error("查询审计记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询审计记录失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(audits))
+	for _, audit := range audits {
+		items = append(items, map[string]any{
+			"id":                audit.Id,
+			"actor_id":          audit.ActorId(),
+			"actor_role":        audit.ActorRole(),
+			"superuser_id":      audit.SuperuserId(),
+			"action":            audit.Action(),
+			"target_collection": audit.TargetCollection(),
+			"target_id":         audit.TargetId(),
+			"before":            audit.Get(model.AuditsFieldBefore),
+			"after":             audit.Get(model.AuditsFieldAfter),
+			"ip":                audit.Ip(),
+			"memo":              audit.Memo(),
+			"created":           audit.Created(),
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// GetArticles 文章列表，status=flagged 仅返回被标记的文章，status=disqualified 仅返回被取消资格的文章
+func (controller *AdminController) GetArticles(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_articles")
+
+	where := dbx.HashExp{}
+	switch event.Request.URL.Query().Get("status") {
+	case "flagged":
+		where[model.ArticlesFieldFlagged] = true
+	case "disqualified":
+		where[model.ArticlesFieldDisqualified] = true
+	}
+
+	page, perPage := pagination(event)
+	articles := []*model.Article{}
+	if err := controller.app.RecordQuery(model.DbNameArticles).
+		Where(where).
+		OrderBy(model.ArticlesFieldUpdated + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&articles); err != nil {
+		logger.Error("查询文章失败", slog.Any("err", err))
+		return event.InternalServerError("查询文章失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(articles))
+	for _, article := range articles {
+		items = append(items, map[string]any{
+			"id":           article.Id,
+			"user_id":      article.UserId(),
+			"o_id":         article.OId(),
+			"title":        article.Title(),
+			"score":        article.Score(),
+			"flagged":      article.Flagged(),
+			"flag_reason":  article.FlagReason(),
+			"disqualified": article.Disqualified(),
+			"updated":      article.Updated(),
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"items":    items,
+	})
+}
+
+// FlagArticle 标记文章待审核
+func (controller *AdminController) FlagArticle(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("flag_article")
+
+	data := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if strings.TrimSpace(data.Reason) == "" {
+		return event.BadRequestError("请填写标记原因", nil)
+	}
+
+	articleId := event.Request.PathValue("id")
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		article := new(model.Article)
+		if err := txApp.RecordQuery(model.DbNameArticles).Where(dbx.HashExp{model.CommonFieldId: articleId}).One(article); err != nil {
+			return err
+		}
+
+		before := service.Snapshot(article.Record)
+		article.SetFlagged(true)
+		article.SetFlagReason(data.Reason)
+		if err := txApp.Save(article); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionArticleFlag,
+			TargetCollection: model.DbNameArticles,
+			TargetId:         article.Id,
+			Before:           before,
+			After:            service.Snapshot(article.Record),
+			Memo:             data.Reason,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("文章不存在", err)
+	}
+	if err != nil {
+		logger.Error("标记文章失败", slog.String("article_id", articleId), slog.Any("err", err))
+		return event.InternalServerError("标记文章失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// ReviewArticle 审核文章：clear 取消标记，disqualify 取消评奖资格，restore 恢复评奖资格
+func (controller *AdminController) ReviewArticle(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("review_article")
+
+	data := struct {
+		Result string `json:"result"`
+		Memo   string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Result != reviewResultClear && data.Result != reviewResultDisqualify && data.Result != reviewResultRestore {
+		return event.BadRequestError("无效的审核结果", nil)
+	}
+
+	articleId := event.Request.PathValue("id")
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		article := new(model.Article)
+		if err := txApp.RecordQuery(model.DbNameArticles).Where(dbx.HashExp{model.CommonFieldId: articleId}).One(article); err != nil {
+			return err
+		}
+
+		before := service.Snapshot(article.Record)
+		switch data.Result {
+		case reviewResultClear:
+			article.SetFlagged(false)
+			article.SetFlagReason("")
+		case reviewResultDisqualify:
+			article.SetFlagged(false)
+			article.SetDisqualified(true)
+		case reviewResultRestore:
+			article.SetDisqualified(false)
+		}
+		if err := txApp.Save(article); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionArticleReview,
+			TargetCollection: model.DbNameArticles,
+			TargetId:         article.Id,
+			Before:           before,
+			After:            service.Snapshot(article.Record),
+			Memo:             data.Result + " " + data.Memo,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("文章不存在", err)
+	}
+	if err != nil {
+		logger.Error("审核文章失败", slog.String("article_id", articleId), slog.Any("err", err))
+		return event.InternalServerError("审核文章失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// GetVotes 投票列表，status=flagged 仅返回被标记的投票
+func (controller *AdminController) GetVotes(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_votes")
+
+	where := dbx.HashExp{}
+	if event.Request.URL.Query().Get("status") == "flagged" {
+		where[model.VotesFieldFlagged] = true
+	}
+
+	page, perPage := pagination(event)
+	votes := []*model.Vote{}
+	if err := controller.app.RecordQuery(model.DbNameVotes).
+		Where(where).
+		OrderBy(model.VotesFieldCreated + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&votes); err != nil {
+		logger.Error("查询投票失败", slog.Any("err", err))
+		return event.InternalServerError("查询投票失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(votes))
+	for _, vote := range votes {
+		items = append(items, map[string]any{
+			"id":           vote.Id,
+			"from_user_id": vote.FromUserId(),
+			"to_user_id":   vote.ToUserId(),
+			"article_id":   vote.ArticleId(),
+			"vote_type":    vote.VoteType(),
+			"flagged":      vote.Flagged(),
+			"flag_reason":  vote.FlagReason(),
+			"created":      vote.Created(),
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"items":    items,
+	})
+}
+
+// FlagVote 标记投票待审核
+func (controller *AdminController) FlagVote(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("flag_vote")
+
+	data := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if strings.TrimSpace(data.Reason) == "" {
+		return event.BadRequestError("请填写标记原因", nil)
+	}
+
+	voteId := event.Request.PathValue("id")
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		vote := new(model.Vote)
+		if err := txApp.RecordQuery(model.DbNameVotes).Where(dbx.HashExp{model.CommonFieldId: voteId}).One(vote); err != nil {
+			return err
+		}
+
+		before := service.Snapshot(vote.Record)
+		vote.SetFlagged(true)
+		vote.SetFlagReason(data.Reason)
+		if err := txApp.Save(vote); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionVoteFlag,
+			TargetCollection: model.DbNameVotes,
+			TargetId:         vote.Id,
+			Before:           before,
+			After:            service.Snapshot(vote.Record),
+			Memo:             data.Reason,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("投票不存在", err)
+	}
+	if err != nil {
+		logger.Error("标记投票失败", slog.String("vote_id", voteId), slog.Any("err", err))
+		return event.InternalServerError("标记投票失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// ReviewVote 审核投票：clear 取消标记，remove 删除投票
+func (controller *AdminController) ReviewVote(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("review_vote")
+
+	data := struct {
+		Result string `json:"result"`
+		Memo   string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Result != reviewResultClear && data.Result != reviewResultRemove {
+		return event.BadRequestError("无效的审核结果", nil)
+	}
+
+	voteId := event.Request.PathValue("id")
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		vote := new(model.Vote)
+		if err := txApp.RecordQuery(model.DbNameVotes).Where(dbx.HashExp{model.CommonFieldId: voteId}).One(vote); err != nil {
+			return err
+		}
+
+		before := service.Snapshot(vote.Record)
+		var after map[string]any
+		if data.Result == reviewResultRemove {
+			if err := txApp.Delete(vote); err != nil {
+				return err
+			}
+		} else {
+			vote.SetFlagged(false)
+			vote.SetFlagReason("")
+			if err := txApp.Save(vote); err != nil {
+				return err
+			}
+			after = service.Snapshot(vote.Record)
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionVoteReview,
+			TargetCollection: model.DbNameVotes,
+			TargetId:         voteId,
+			Before:           before,
+			After:            after,
+			Memo:             data.Result + " " + data.Memo,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("投票不存在", err)
+	}
+	if err != nil {
+		logger.Error("审核投票失败", slog.String("vote_id", voteId), slog.Any("err", err))
+		return event.InternalServerError("审核投票失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// GetPayoutStatus 查询正在执行的发放任务
+func (controller *AdminController) GetPayoutStatus(event *core.RequestEvent) error {
+	return event.JSON(http.StatusOK, map[string]any{
+		"running": controller.payoutService.Running(),
+	})
+}
+
+// StartPayout 在后台执行发放任务
+func (controller *AdminController) StartPayout(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("start_payout")
+
+	data := struct {
+		Job string `json:"job"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if !slices.Contains(service.PayoutJobs, data.Job) {
+		return event.BadRequestError("未知的发放任务", nil)
+	}
+
+	// 先写审计记录，任务在后台执行，结果见日志与积分订单
+	if err := controller.audit(controller.app, event, service.AuditEntry{
+		Action: service.AuditActionPayoutRun,
+		After:  map[string]any{"job": data.Job},
+		Memo:   data.Job,
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+		return event.InternalServerError("写入审计记录失败", err)
+	}
+
+	if err := controller.payoutService.Start(data.Job); err != nil {
+		if errors.Is(err, service.ErrPayoutRunning) {
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		}
+		logger.Error("启动发放任务失败", slog.Any("err", err))
+		return event.InternalServerError("启动发放任务失败", err)
+	}
+
+	return event.JSON(http.StatusAccepted, map[string]any{
+		"success": true,
+		"job":     data.Job,
+	})
+}
+
+// GetSettlements 结算记录列表
+func (controller *AdminController) GetSettlements(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_settlements")
+
+	page, perPage := pagination(event)
+	settlements := []*model.Settlement{}
+	if err := controller.app.RecordQuery(model.DbNameSettlements).
+		OrderBy(model.SettlementsFieldRunNo + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&settlements); err != nil {
+		logger.Error("查询结算记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询结算记录失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(settlements))
+	for _, item := range settlements {
+		items = append(items, map[string]any{
+			"id":       item.Id,
+			"run_no":   item.RunNo(),
+			"winners":  item.Winners(),
+			"digest":   item.Digest(),
+			"verified": controller.settlement.Verify(item),
+			"created":  item.Created(),
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"items":    items,
+	})
+}
+
+// GetSettlement 结算报告详情，附带签名校验结果
+func (controller *AdminController) GetSettlement(event *core.RequestEvent) error {
+	item := new(model.Settlement)
+	if err := controller.app.RecordQuery(model.DbNameSettlements).
+		Where(dbx.HashExp{model.CommonFieldId: event.Request.PathValue("id")}).
+		One(item); err != nil {
+		return event.NotFoundError("结算记录不存在", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"id":        item.Id,
+		"run_no":    item.RunNo(),
+		"digest":    item.Digest(),
+		"signature": item.Signature(),
+		"verified":  controller.settlement.Verify(item),
+		"report":    item.Get(model.SettlementsFieldReport),
+		"created":   item.Created(),
+	})
+}
+
+// ReplayHistory 按博饼记录的种子复现骰子与奖项选择，用于核对博饼结果
+func (controller *AdminController) ReplayHistory(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("replay_history")
+
+	history := new(model.Histories)
+	if err := controller.app.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{model.CommonFieldId: event.Request.PathValue("id")}).
+		One(history); err != nil {
+		return event.NotFoundError("博饼记录不存在", err)
+	}
+
+	replay, err := controller.awardService.Replay(history)
+	if errors.Is(err, service.ErrAwardNoSeed) {
+		return event.BadRequestError(err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("复现博饼记录失败", slog.String("history_id", history.Id), slog.Any("err", err))
+		return event.InternalServerError("复现博饼记录失败", err)
+	}
+
+	return event.JSON(http.StatusOK, replay)
+}
+
+// RunSettlement 立即执行一次状元结算
+func (controller *AdminController) RunSettlement(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("run_settlement")
+
+	result, err := controller.settlement.Run()
+	if errors.Is(err, settlement.ErrRunning) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("状元结算失败", slog.Any("err", err))
+		return event.InternalServerError("状元结算失败", err)
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionSettlementRun,
+		TargetCollection: model.DbNameSettlements,
+		TargetId:         result.Id,
+		After:            map[string]any{"runNo": result.RunNo(), "digest": result.Digest()},
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"id":      result.Id,
+		"run_no":  result.RunNo(),
+		"winners": result.Winners(),
+		"digest":  result.Digest(),
+		"report":  result.Get(model.SettlementsFieldReport),
+	})
+}
+
+// GetBudgets 各来源的预算与使用情况
+func (controller *AdminController) GetBudgets(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_budgets")
+
+	budgets, err := controller.ledgerService.Budgets()
+	if err != nil {
+		logger.Error("查询预算失败", slog.Any("err", err))
+		return event.InternalServerError("查询预算失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": budgets,
+	})
+}
+
+// UpdateBudget 设置来源或活动的预算上限
+func (controller *AdminController) UpdateBudget(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("update_budget")
+
+	data := struct {
+		Cap  int    `json:"cap"`
+		Memo string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Cap < 0 {
+		return event.BadRequestError("预算不能小于0", nil)
+	}
+	source := event.Request.PathValue("source")
+	if source != ledger.BudgetActivity && !slices.Contains(ledger.Sources, source) {
+		return event.BadRequestError("未知的积分来源", nil)
+	}
+
+	var after ledger.BudgetUsage
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, usage, err := controller.ledgerService.SetBudget(txApp, source, data.Cap, data.Memo)
+		if err != nil {
+			return err
+		}
+		after = usage
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionBudgetUpdate,
+			TargetCollection: model.DbNameBudgets,
+			TargetId:         source,
+			Before:           before,
+			After:            after,
+			Memo:             data.Memo,
+		})
+	})
+	if err != nil {
+		logger.Error("设置预算失败", slog.String("source", source), slog.Any("err", err))
+		return event.InternalServerError("设置预算失败", err)
+	}
+
+	return event.JSON(http.StatusOK, after)
+}
+
+// GetReconciliations 对账记录列表
+func (controller *AdminController) GetReconciliations(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_reconciliations")
+
+	page, perPage := pagination(event)
+	reconciliations := []*model.Reconciliation{}
+	if err := controller.app.RecordQuery(model.DbNameReconciliations).
+		OrderBy(model.ReconciliationsFieldCreated + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&reconciliations); err != nil {
+		logger.Error("查询对账记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询对账记录失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(reconciliations))
+	for _, item := range reconciliations {
+		items = append(items, map[string]any{
+			"id":      item.Id,
+			"issues":  item.Issues(),
+			"created": item.Created(),
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"items":    items,
+	})
+}
+
+// GetReconciliation 对账报告，format=csv 时下载 CSV，否则返回 JSON
+func (controller *AdminController) GetReconciliation(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_reconciliation")
+
+	reconciliation, report, err := controller.ledgerService.Find(event.Request.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("对账记录不存在", err)
+	}
+	if err != nil {
+		logger.Error("查询对账报告失败", slog.Any("err", err))
+		return event.InternalServerError("查询对账报告失败", err)
+	}
+
+	if event.Request.URL.Query().Get("format") == "csv" {
+		event.Response.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		event.Response.Header().Set("Content-Disposition", `attachment; filename="reconciliation-`+reconciliation.Id+`.csv"`)
+		event.Response.WriteHeader(http.StatusOK)
+		return report.WriteCSV(event.Response)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"id":      reconciliation.Id,
+		"issues":  reconciliation.Issues(),
+		"report":  report,
+		"created": reconciliation.Created(),
+	})
+}
+
+// RunReconciliation 立即执行一次对账
+func (controller *AdminController) RunReconciliation(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("run_reconciliation")
+
+	reconciliation, report, err := controller.ledgerService.Reconcile()
+	if errors.Is(err, ledger.ErrRunning) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("对账失败", slog.Any("err", err))
+		return event.InternalServerError("对账失败", err)
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionReconcile,
+		TargetCollection: model.DbNameReconciliations,
+		TargetId:         reconciliation.Id,
+		After:            map[string]any{"issues": len(report.Issues), "summary": report.Summary},
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"id":     reconciliation.Id,
+		"issues": reconciliation.Issues(),
+		"report": report,
+	})
+}
+
+// GetUnknownPoints 发放结果未知、等待核对的积分订单
+func (controller *AdminController) GetUnknownPoints(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_unknown_points")
+
+	points, err := controller.ledgerService.Unknown()
+	if err != nil {
+		logger.Error("查询待核对订单失败", slog.Any("err", err))
+		return event.InternalServerError("查询待核对订单失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": points,
+	})
+}
+
+// ResolvePoints 核对摸鱼派后确认发放结果未知的订单是否已到账，未到账的订单之后可以重试
+func (controller *AdminController) ResolvePoints(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("resolve_points")
+
+	data := struct {
+		Credited bool   `json:"credited"`
+		Memo     string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	id := event.Request.PathValue("id")
+
+	var after *model.Points
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, points, err := controller.ledgerService.Resolve(txApp, id, data.Credited)
+		if err != nil {
+			return err
+		}
+		after = points
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionPointsResolve,
+			TargetCollection: model.DbNamePoints,
+			TargetId:         id,
+			Before:           before,
+			After:            after,
+			Memo:             data.Memo,
+		})
+	})
+	if errors.Is(err, ledger.ErrNotUnknown) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("核对积分订单失败", slog.String("points_id", id), slog.Any("err", err))
+		return event.InternalServerError("核对积分订单失败", err)
+	}
+
+	return event.JSON(http.StatusOK, after)
+}
+
+// GetGuard 支出熔断状态与最近的熔断记录
+func (controller *AdminController) GetGuard(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_guard")
+
+	status, err := controller.ledgerService.Guard()
+	if err != nil {
+		logger.Error("查询熔断状态失败", slog.Any("err", err))
+		return event.InternalServerError("查询熔断状态失败", err)
+	}
+	trips, err := controller.ledgerService.Trips(20)
+	if err != nil {
+		logger.Error("查询熔断记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询熔断记录失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"status": status,
+		"trips":  trips,
+	})
+}
+
+// HaltGuard 手动熔断，暂停所有积分发放
+func (controller *AdminController) HaltGuard(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("halt_guard")
+
+	data := struct {
+		Memo string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Memo == "" {
+		return event.BadRequestError("请填写熔断原因", nil)
+	}
+
+	trip, err := controller.ledgerService.Halt(data.Memo)
+	if err != nil {
+		logger.Error("熔断失败", slog.Any("err", err))
+		return event.InternalServerError("熔断失败", err)
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionGuardHalt,
+		TargetCollection: model.DbNameGuardTrips,
+		TargetId:         trip.Id,
+		After:            trip,
+		Memo:             data.Memo,
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+	}
+
+	return event.JSON(http.StatusOK, trip)
+}
+
+// ResetGuard 重置熔断恢复发放，resume 为 true 时补发熔断期间保持待发放的订单
+func (controller *AdminController) ResetGuard(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("reset_guard")
+
+	data := struct {
+		Memo   string `json:"memo"`
+		Resume bool   `json:"resume"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	var trip *model.GuardTrip
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if trip, err = controller.ledgerService.Reset(txApp, event.Auth.Id, data.Memo); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionGuardReset,
+			TargetCollection: model.DbNameGuardTrips,
+			TargetId:         trip.Id,
+			After:            trip,
+			Memo:             data.Memo,
+		})
+	})
+	if errors.Is(err, ledger.ErrNotTripped) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("重置熔断失败", slog.Any("err", err))
+		return event.InternalServerError("重置熔断失败", err)
+	}
+
+	resumed, held := 0, 0
+	if data.Resume {
+		if resumed, held, err = controller.ledgerService.Resume(); err != nil {
+			logger.Error("补发待发放订单失败", slog.Any("err", err))
+			return event.InternalServerError("补发待发放订单失败", err)
+		}
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"trip":    trip,
+		"resumed": resumed,
+		"held":    held,
+	})
+}
+
+// GetRewardStocks 查询所有奖品的库存
+func (controller *AdminController) GetRewardStocks(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_reward_stocks")
+
+	stocks, err := controller.inventoryService.Stocks()
+	if err != nil {
+		logger.Error("查询奖品库存失败", slog.Any("err", err))
+		return event.InternalServerError("查询奖品库存失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": stocks,
+	})
+}
+
+// UpdateRewardStock 调整奖品库存（补货）与低库存提醒阈值，库存不能少于已发放的数量
+func (controller *AdminController) UpdateRewardStock(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("update_reward_stock")
+
+	data := struct {
+		Amount   int    `json:"amount"`
+		LowStock *int   `json:"low_stock"`
+		Memo     string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Amount < 0 {
+		return event.BadRequestError("库存不能小于0", nil)
+	}
+	if data.LowStock != nil && *data.LowStock < 0 {
+		return event.BadRequestError("低库存提醒阈值不能小于0", nil)
+	}
+
+	rewardId := event.Request.PathValue("id")
+	var stock inventory.Stock
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, after, err := controller.inventoryService.Restock(txApp, rewardId, data.Amount, data.LowStock)
+		if err != nil {
+			return err
+		}
+		stock = after
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionRewardStock,
+			TargetCollection: model.DbNameRewards,
+			TargetId:         rewardId,
+			Before:           before,
+			After:            after,
+			Memo:             data.Memo,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("奖品不存在", err)
+	}
+	if errors.Is(err, inventory.ErrBelowReserved) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("调整奖品库存失败", slog.String("reward_id", rewardId), slog.Any("err", err))
+		return event.InternalServerError("调整奖品库存失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+		"stock":   stock,
+	})
+}
+
+// RetireReward 活动中途下架或重新上架奖品，下架后博饼不再获得该奖品，已获奖的记录不受影响
+func (controller *AdminController) RetireReward(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("retire_reward")
+
+	data := struct {
+		Retired bool   `json:"retired"`
+		Memo    string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if data.Memo == "" {
+		return event.BadRequestError("请填写下架原因", nil)
+	}
+
+	rewardId := event.Request.PathValue("id")
+	var stock inventory.Stock
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, after, err := controller.inventoryService.Retire(txApp, rewardId, data.Retired)
+		if err != nil {
+			return err
+		}
+		stock = after
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionRewardRetire,
+			TargetCollection: model.DbNameRewards,
+			TargetId:         rewardId,
+			Before:           before,
+			After:            after,
+			Memo:             data.Memo,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("奖品不存在", err)
+	}
+	if err != nil {
+		logger.Error("下架奖品失败", slog.String("reward_id", rewardId), slog.Any("err", err))
+		return event.InternalServerError("下架奖品失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+		"stock":   stock,
+	})
+}
+
+// GetRewardCodes 查询兑换码奖品码池的总数与已分配数量
+func (controller *AdminController) GetRewardCodes(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_reward_codes")
+
+	rewardId := event.Request.PathValue("id")
+	total, used, err := controller.fulfillmentService.CodeStats(rewardId)
+	if err != nil {
+		logger.Error("查询兑换码失败", slog.String("reward_id", rewardId), slog.Any("err", err))
+		return event.InternalServerError("查询兑换码失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"reward_id": rewardId,
+		"total":     total,
+		"used":      used,
+		"remaining": total - used,
+	})
+}
+
+// ImportRewardCodes 向兑换码奖品的码池导入兑换码，重复的兑换码跳过
+func (controller *AdminController) ImportRewardCodes(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("import_reward_codes")
+
+	data := struct {
+		Codes []string `json:"codes"`
+		Memo  string   `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if len(data.Codes) == 0 {
+		return event.BadRequestError("请填写兑换码", nil)
+	}
+
+	rewardId := event.Request.PathValue("id")
+	var imported, skipped int
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if imported, skipped, err = controller.fulfillmentService.ImportCodes(txApp, rewardId, data.Codes); err != nil {
+			return err
+		}
+
+		// 审计记录只保存数量，不保存兑换码
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionRewardCodes,
+			TargetCollection: model.DbNameRewards,
+			TargetId:         rewardId,
+			After:            map[string]any{"imported": imported, "skipped": skipped},
+			Memo:             data.Memo,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("奖品不存在", err)
+	}
+	if errors.Is(err, fulfillment.ErrInvalidTransition) {
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	}
+	if err != nil {
+		logger.Error("导入兑换码失败", slog.String("reward_id", rewardId), slog.Any("err", err))
+		return event.InternalServerError("导入兑换码失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success":  true,
+		"imported": imported,
+		"skipped":  skipped,
+	})
+}
+
+// GetFulfillments 分页查询兑奖记录，可按 status、kind 过滤
+func (controller *AdminController) GetFulfillments(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_fulfillments")
+
+	query := event.Request.URL.Query()
+	page, perPage := pagination(event)
+	fulfillments, total, err := controller.fulfillmentService.Search(query.Get("status"), query.Get("kind"), page, perPage)
+	if err != nil {
+		logger.Error("查询兑奖记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询兑奖记录失败", err)
+	}
+
+	rewards := map[string]*model.Reward{}
+	items := make([]map[string]any, 0, len(fulfillments))
+	for _, item := range fulfillments {
+		reward, ok := rewards[item.RewardId()]
+		if !ok {
+			reward = new(model.Reward)
+			if err := controller.app.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: item.RewardId()}).One(reward); err != nil {
+				logger.Warn("查找reward失败", slog.Any("err", err), slog.String("reward_id", item.RewardId()))
+				continue
+			}
+			rewards[item.RewardId()] = reward
+		}
+		code, err := controller.fulfillmentService.Code(item)
+		if err != nil {
+			logger.Error("查找兑换码失败", slog.String("fulfillment_id", item.Id), slog.Any("err", err))
+			return event.InternalServerError("查找兑换码失败", err)
+		}
+		response := fulfillmentResponse(item, reward, code)
+		response["user_id"] = item.UserId()
+		items = append(items, response)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// TransitFulfillment 推进兑奖记录：发货需填写运单号，标记失败需填写原因，失败的记录可以重新发放
+func (controller *AdminController) TransitFulfillment(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("transit_fulfillment")
+
+	data := struct {
+		Status     model.FulfillmentStatus `json:"status"`
+		TrackingNo string                  `json:"tracking_no"`
+		Memo       string                  `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if !data.Status.IsValid() {
+		return event.BadRequestError("兑奖状态错误", nil)
+	}
+	if data.Status == model.FulfillmentStatusFailed && data.Memo == "" {
+		return event.BadRequestError("请填写失败原因", nil)
+	}
+
+	id := event.Request.PathValue("id")
+	var after *model.Fulfillment
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		var before map[string]any
+		var err error
+		if before, after, err = controller.fulfillmentService.Transition(txApp, id, data.Status, data.TrackingNo, data.Memo); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionFulfillment,
+			TargetCollection: model.DbNameFulfillments,
+			TargetId:         id,
+			Before:           before,
+			After:            after.FieldsData(),
+			Memo:             data.Memo,
+		})
+	})
+	if err != nil {
+		validationErrors := validation.Errors{}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return event.NotFoundError("兑奖记录不存在", err)
+		case errors.As(err, &validationErrors):
+			return event.BadRequestError("参数校验失败", validationErrors)
+		case errors.Is(err, fulfillment.ErrInvalidTransition):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		}
+		logger.Error("修改兑奖记录失败", slog.String("fulfillment_id", id), slog.Any("err", err))
+		return event.InternalServerError("修改兑奖记录失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+		"id":      after.Id,
+		"status":  after.Status(),
+	})
+}
+
+// GetActivitySchedule 查询活动日程
+func (controller *AdminController) GetActivitySchedule(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_activity_schedule")
+
+	schedule, err := controller.activityService.Schedule()
+	if err != nil {
+		logger.Error("获取活动日程失败", slog.Any("err", err))
+		return event.InternalServerError("获取活动日程失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"phases": phasesResponse(schedule.Phases),
+	})
+}
+
+// UpdateActivitySchedule 调整活动日程（如延长投票或博饼阶段）
+func (controller *AdminController) UpdateActivitySchedule(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("update_activity_schedule")
+
+	data := struct {
+		Phases []struct {
+			Name    model.ActivityPhase `json:"name"`
+			StartAt time.Time           `json:"start_at"`
+			EndAt   time.Time           `json:"end_at"`
+		} `json:"phases"`
+		Memo string `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	schedule := service.Schedule{}
+	for _, phase := range data.Phases {
+		schedule.Phases = append(schedule.Phases, service.Phase{Name: phase.Name, StartAt: phase.StartAt, EndAt: phase.EndAt})
+	}
+	if err := schedule.Validate(); err != nil {
+		return event.BadRequestError(err.Error(), nil)
+	}
+
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, err := controller.activityService.SetSchedule(txApp, schedule)
+		if err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionActivitySchedule,
+			TargetCollection: model.DbNameConfigs,
+			TargetId:         model.ConfigKeyActivity.String(),
+			Before:           before,
+			After:            schedule,
+			Memo:             data.Memo,
+		})
+	})
+	if err != nil {
+		logger.Error("调整活动日程失败", slog.Any("err", err))
+		return event.InternalServerError("调整活动日程失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"phases": phasesResponse(schedule.Phases),
+	})
+}
+
+// GetConfigs 查询所有配置项，敏感字段已掩码
+func (controller *AdminController) GetConfigs(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_configs")
+
+	items := []map[string]any{}
+	for _, key := range controller.configRegistry.Keys() {
+		item, err := controller.configResponse(key)
+		if err != nil {
+			logger.Error("获取配置失败", slog.String("key", key.String()), slog.Any("err", err))
+			return event.InternalServerError("获取配置失败", err)
+		}
+		items = append(items, item)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": items,
+	})
+}
+
+// GetConfig 查询单个配置项及其 JSON Schema
+func (controller *AdminController) GetConfig(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_config")
+
+	key := model.ConfigKey(event.Request.PathValue("key"))
+	item, err := controller.configResponse(key)
+	if err != nil {
+		if errors.Is(err, config.ErrUnknownKey) {
+			return event.NotFoundError("配置项不存在", nil)
+		}
+		logger.Error("获取配置失败", slog.String("key", key.String()), slog.Any("err", err))
+		return event.InternalServerError("获取配置失败", err)
+	}
+
+	return event.JSON(http.StatusOK, item)
+}
+
+// UpdateConfig 修改配置项，只需传入要修改的字段，敏感字段传入掩码时保持原值，保存后相关服务即时重新加载
+func (controller *AdminController) UpdateConfig(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("update_config")
+
+	key := model.ConfigKey(event.Request.PathValue("key"))
+	data := struct {
+		Value json.RawMessage `json:"value"`
+		Memo  string          `json:"memo"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if len(data.Value) == 0 {
+		return event.BadRequestError("缺少配置内容", nil)
+	}
+
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		before, after, err := controller.configRegistry.Update(txApp, key, data.Value)
+		if err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionConfigUpdate,
+			TargetCollection: model.DbNameConfigs,
+			TargetId:         key.String(),
+			Before:           before,
+			After:            after,
+			Memo:             data.Memo,
+		})
+	})
+	if err != nil {
+		validationErrors := validation.Errors{}
+		switch {
+		case errors.Is(err, config.ErrUnknownKey):
+			return event.NotFoundError("配置项不存在", nil)
+		case errors.As(err, &validationErrors):
+			return event.BadRequestError("配置校验失败", validationErrors)
+		}
+		logger.Error("修改配置失败", slog.String("key", key.String()), slog.Any("err", err))
+		return event.InternalServerError("修改配置失败", err)
+	}
+
+	item, err := controller.configResponse(key)
+	if err != nil {
+		logger.Error("获取配置失败", slog.String("key", key.String()), slog.Any("err", err))
+		return event.InternalServerError("获取配置失败", err)
+	}
+	return event.JSON(http.StatusOK, item)
+}
+
+func (controller *AdminController) configResponse(key model.ConfigKey) (map[string]any, error) {
+	value, err := controller.configRegistry.Masked(key)
+	if err != nil {
+		return nil, err
+	}
+	defaultValue, err := controller.configRegistry.Default(key)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := controller.configRegistry.Schema(key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"key":     key,
+		"value":   value,
+		"default": defaultValue,
+		"schema":  schema,
+	}, nil
+}
+
+// GetExportDatasets 可导出的数据集及其列与过滤条件
+func (controller *AdminController) GetExportDatasets(event *core.RequestEvent) error {
+	return event.JSON(http.StatusOK, map[string]any{
+		"datasets":     export.Datasets(),
+		"formats":      model.ExportFormatValues(),
+		"stream_limit": export.StreamLimit,
+	})
+}
+
+// StreamExport 直接下载导出数据，超过 export.StreamLimit 行时需要创建后台导出任务
+func (controller *AdminController) StreamExport(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("stream_export")
+
+	request := export.Request{}
+	if err := event.BindBody(&request); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	count, err := controller.exportService.Count(request)
+	if err != nil {
+		validationErrors := validation.Errors{}
+		if errors.As(err, &validationErrors) {
+			return event.BadRequestError("参数校验失败", validationErrors)
+		}
+		logger.Error("统计导出数据失败", slog.Any("err", err))
+		return event.InternalServerError("统计导出数据失败", err)
+	}
+	if count > export.StreamLimit {
+		return event.Error(http.StatusConflict, export.ErrTooLarge.Error(), map[string]any{"rows": count})
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionExportStream,
+		TargetCollection: model.DbNameExports,
+		After:            request,
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+		return event.InternalServerError("写入审计记录失败", err)
+	}
+
+	name := export.FileName(request.Dataset, request.Format, time.Now().Format("20060102150405"))
+	event.Response.Header().Set("Content-Disposition", "attachment; filename="+name)
+	event.Response.Header().Set("Content-Type", export.ContentType(request.Format))
+	event.Response.Header().Set("Cache-Control", "no-store")
+	event.Response.WriteHeader(http.StatusOK)
+	// 已开始写入响应，出错时只能记录日志
+	if _, err = controller.exportService.Write(event.Response, request); err != nil {
+		logger.Error("导出数据失败", slog.Any("err", err))
+	}
+	return nil
+}
+
+// GetExports 分页查询导出任务
+func (controller *AdminController) GetExports(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_exports")
+
+	page, perPage := pagination(event)
+	exports, total, err := controller.exportService.List(page, perPage)
+	if err != nil {
+		logger.Error("查询导出任务失败", slog.Any("err", err))
+		return event.InternalServerError("查询导出任务失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(exports))
+	for _, item := range exports {
+		items = append(items, exportResponse(item))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// CreateExport 创建后台导出任务，完成后通过 DownloadExport 下载
+func (controller *AdminController) CreateExport(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("create_export")
+
+	request := export.Request{}
+	if err := event.BindBody(&request); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	var item *model.Export
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if item, err = controller.exportService.Create(txApp, model.NewUser(event.Auth), request); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionExportCreate,
+			TargetCollection: model.DbNameExports,
+			TargetId:         item.Id,
+			After:            request,
+		})
+	})
+	if err != nil {
+		validationErrors := validation.Errors{}
+		if errors.As(err, &validationErrors) {
+			return event.BadRequestError("参数校验失败", validationErrors)
+		}
+		logger.Error("创建导出任务失败", slog.Any("err", err))
+		return event.InternalServerError("创建导出任务失败", err)
+	}
+
+	// 立即执行，不等待定时任务
+	go controller.exportService.RunPending()
+
+	return event.JSON(http.StatusOK, exportResponse(item))
+}
+
+// GetExport 查询导出任务
+func (controller *AdminController) GetExport(event *core.RequestEvent) error {
+	item, err := controller.exportService.Find(event.Request.PathValue("id"))
+	if err != nil {
+		return event.NotFoundError("导出任务不存在", err)
+	}
+	return event.JSON(http.StatusOK, exportResponse(item))
+}
+
+// DownloadExport 下载已完成的导出任务文件
+func (controller *AdminController) DownloadExport(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("download_export")
+
+	item, err := controller.exportService.Find(event.Request.PathValue("id"))
+	if err != nil {
+		return event.NotFoundError("导出任务不存在", err)
+	}
+	if item.Status() != model.ExportStatusSuccess {
+		return event.Error(http.StatusConflict, "导出任务未完成", nil)
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionExportDownload,
+		TargetCollection: model.DbNameExports,
+		TargetId:         item.Id,
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+		return event.InternalServerError("写入审计记录失败", err)
+	}
+
+	if err = controller.exportService.Serve(event.Response, event.Request, item); err != nil {
+		logger.Error("下载导出文件失败", slog.String("export_id", item.Id), slog.Any("err", err))
+		return event.InternalServerError("下载导出文件失败", err)
+	}
+	return nil
+}
+
+func exportResponse(item *model.Export) map[string]any {
+	return map[string]any{
+		"id":           item.Id,
+		"actor_id":     item.ActorId(),
+		"superuser_id": item.SuperuserId(),
+		"dataset":      item.Dataset(),
+		"format":       item.Format(),
+		"columns":      item.Columns(),
+		"filters":      item.Filters(),
+		"status":       item.Status(),
+		"rows":         item.Rows(),
+		"error":        item.Error(),
+		"finished_at":  item.FinishedAt(),
+		"created":      item.Created(),
+	}
+}
+
+// GetReports 分页查询发布过的活动报告版本
+func (controller *AdminController) GetReports(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_reports")
+
+	page, perPage := pagination(event)
+	reports, total, err := controller.reportService.List(page, perPage)
+	if err != nil {
+		logger.Error("查询活动报告失败", slog.Any("err", err))
+		return event.InternalServerError("查询活动报告失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(reports))
+	for _, item := range reports {
+		response := reportResponse(item)
+		delete(response, "content")
+		items = append(items, response)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// GetReport 查询活动报告版本的内容
+func (controller *AdminController) GetReport(event *core.RequestEvent) error {
+	item, err := controller.reportService.Find(event.Request.PathValue("id"))
+	if err != nil {
+		return event.NotFoundError("活动报告不存在", err)
+	}
+	return event.JSON(http.StatusOK, reportResponse(item))
+}
+
+// PreviewReport 使用当前数据与模板生成活动报告，不发布
+func (controller *AdminController) PreviewReport(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("preview_report")
+
+	draft, err := controller.reportService.Generate()
+	if err != nil {
+		logger.Error("生成活动报告失败", slog.Any("err", err))
+		return event.InternalServerError("生成活动报告失败", err)
+	}
+
+	response := map[string]any{
+		"title":      draft.Title,
+		"content":    draft.Content,
+		"tags":       draft.Tags,
+		"article_id": "",
+	}
+	if latest, err := controller.reportService.Latest(); err == nil {
+		response["article_id"] = latest.ArticleId()
+	}
+	return event.JSON(http.StatusOK, response)
+}
+
+// PublishReport 生成活动报告并发布到摸鱼派，已发布过时更新原帖子
+func (controller *AdminController) PublishReport(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("publish_report")
+
+	item, created, err := controller.reportService.Publish(model.NewUser(event.Auth))
+	if err != nil {
+		switch {
+		case errors.Is(err, report.ErrPublishing):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		case errors.Is(err, report.ErrPublishFailed):
+			logger.Warn("发布活动报告失败", slog.Any("err", err))
+			return event.Error(http.StatusBadGateway, err.Error(), nil)
+		}
+		logger.Error("发布活动报告失败", slog.Any("err", err))
+		return event.InternalServerError("发布活动报告失败", err)
+	}
+
+	if err = controller.audit(controller.app, event, service.AuditEntry{
+		Action:           service.AuditActionReportPublish,
+		TargetCollection: model.DbNameReports,
+		TargetId:         item.Id,
+		After:            map[string]any{"article_id": item.ArticleId(), "title": item.Title(), "new_article": created},
+	}); err != nil {
+		logger.Error("写入审计记录失败", slog.Any("err", err))
+	}
+
+	response := reportResponse(item)
+	response["new_article"] = created
+	return event.JSON(http.StatusOK, response)
+}
+
+// GetUserQuota 查询用户的博饼次数与各来源的明细，用于答复用户的次数疑问
+func (controller *AdminController) GetUserQuota(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_user_quota")
+
+	record, err := controller.app.FindRecordById(model.DbNameUsers, event.Request.PathValue("id"))
+	if err != nil {
+		return event.NotFoundError("用户不存在", err)
+	}
+	quota, err := controller.quotaService.Get(controller.app, model.NewUser(record))
+	if err != nil {
+		logger.Error("查询博饼次数失败", slog.Any("err", err))
+		return event.InternalServerError("查询博饼次数失败", err)
+	}
+	return event.JSON(http.StatusOK, quota)
+}
+
+// GetDrawGrants 分页查询博饼次数的补发记录，可按 user_id 过滤
+func (controller *AdminController) GetDrawGrants(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_draw_grants")
+
+	page, perPage := pagination(event)
+	grants, total, err := controller.quotaService.Grants(event.Request.URL.Query().Get("user_id"), page, perPage)
+	if err != nil {
+		logger.Error("查询补发记录失败", slog.Any("err", err))
+		return event.InternalServerError("查询补发记录失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(grants))
+	for _, grant := range grants {
+		items = append(items, drawGrantResponse(grant))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// CreateDrawGrant 补发博饼次数，times 为负数时扣回，补发的次数不受上限限制
+func (controller *AdminController) CreateDrawGrant(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("create_draw_grant")
+
+	data := struct {
+		UserId string `json:"user_id"`
+		Times  int    `json:"times"`
+		Reason string `json:"reason"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	var grant *model.DrawGrant
+	err := controller.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if grant, err = controller.quotaService.Grant(txApp, model.NewUser(event.Auth), data.UserId, data.Times, data.Reason); err != nil {
+			return err
+		}
+
+		return controller.audit(txApp, event, service.AuditEntry{
+			Action:           service.AuditActionDrawGrant,
+			TargetCollection: model.DbNameUsers,
+			TargetId:         grant.UserId(),
+			After:            drawGrantResponse(grant),
+			Memo:             grant.Reason(),
+		})
+	})
+	if err != nil {
+		validationErrors := validation.Errors{}
+		if errors.As(err, &validationErrors) {
+			return event.BadRequestError("参数校验失败", validationErrors)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return event.NotFoundError("用户不存在", err)
+		}
+		logger.Error("补发博饼次数失败", slog.String("user_id", data.UserId), slog.Any("err", err))
+		return event.InternalServerError("补发博饼次数失败", err)
+	}
+
+	return event.JSON(http.StatusOK, drawGrantResponse(grant))
+}
+
+func drawGrantResponse(grant *model.DrawGrant) map[string]any {
+	return map[string]any{
+		"id":       grant.Id,
+		"user_id":  grant.UserId(),
+		"actor_id": grant.ActorId(),
+		"times":    grant.Times(),
+		"reason":   grant.Reason(),
+		"created":  grant.Created(),
+	}
+}
+
+func reportResponse(item *model.Report) map[string]any {
+	return map[string]any{
+		"id":         item.Id,
+		"actor_id":   item.ActorId(),
+		"title":      item.Title(),
+		"content":    item.Content(),
+		"tags":       item.Tags(),
+		"article_id": item.ArticleId(),
+		"created":    item.Created(),
+	}
+}
+
+// pagination 解析分页参数
+func pagination(event *core.RequestEvent) (int, int) {
+	query := event.Request.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	page = max(page, 1)
+
+	perPage, _ := strconv.Atoi(query.Get("perPage"))
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+
+	return page, perPage
+}
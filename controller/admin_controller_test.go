@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/export"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+func TestAdminController_CheckRole(t *testing.T) {
+	app := testapp.New(t)
+	controller := &AdminController{app: app}
+
+	cases := []struct {
+		name    string
+		role    model.UserRole
+		roles   []model.UserRole
+		allowed bool
+	}{
+		{"普通用户", model.UserRolePlayer, []model.UserRole{model.UserRoleModerator, model.UserRoleOperator}, false},
+		{"未设置角色", "", []model.UserRole{model.UserRoleOperator}, false},
+		{"审核员访问审核", model.UserRoleModerator, []model.UserRole{model.UserRoleModerator}, true},
+		{"审核员访问运营", model.UserRoleModerator, []model.UserRole{model.UserRoleOperator}, false},
+		{"运营访问运营", model.UserRoleOperator, []model.UserRole{model.UserRoleOperator}, true},
+		{"多个角色", model.UserRoleOperator, []model.UserRole{model.UserRoleModerator, model.UserRoleOperator}, true},
+	}
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := testapp.User(t, app, string(rune('a'+i)), "user"+string(rune('a'+i)))
+			user.SetRole(c.role)
+
+			event := &core.RequestEvent{App: app, Auth: user.Record}
+			err := controller.CheckRole(c.roles...)(event)
+			if c.allowed && err != nil {
+				t.Errorf("应允许访问, 得到 %v", err)
+			}
+			apiErr := new(router.ApiError)
+			if !c.allowed && (!errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden) {
+				t.Errorf("应返回 403, 得到 %v", err)
+			}
+		})
+	}
+}
+
+// superuser 创建 PocketBase 超级管理员
+func superuser(t *testing.T, app core.App, email string) *core.Record {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(collection)
+	record.SetEmail(email)
+	record.SetPassword("superuser-password")
+	if err = app.Save(record); err != nil {
+		t.Fatalf("创建超级管理员失败: %v", err)
+	}
+	return record
+}
+
+func TestAdminController_Exports(t *testing.T) {
+	app := testapp.New(t)
+	handler := serve(t, app, func(event *core.ServeEvent) {
+		controller := &AdminController{
+			event:         event,
+			app:           app,
+			logger:        app.Logger(),
+			auditService:  service.NewAuditService(app),
+			exportService: export.NewService(app),
+		}
+		controller.registerRoutes()
+	})
+
+	admin := superuser(t, app, "admin@fishpi.cn")
+	player := testapp.User(t, app, "1001", "alice")
+	operator := testapp.User(t, app, "1002", "bob")
+	operator.SetRole(model.UserRoleOperator)
+	if err := app.Save(operator); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超级管理员可以导出，导出记录在审计中
+	if recorder := call(t, handler, http.MethodGet, "/admin/operation/exports/datasets", admin, nil); recorder.Code != http.StatusOK {
+		t.Errorf("超级管理员查看数据集 = %d %s", recorder.Code, recorder.Body)
+	}
+	request := export.Request{Dataset: model.ExportDatasetVotes, Format: model.ExportFormatJsonl}
+	if recorder := call(t, handler, http.MethodPost, "/admin/operation/exports/stream", admin, request); recorder.Code != http.StatusOK {
+		t.Errorf("超级管理员导出 = %d %s", recorder.Code, recorder.Body)
+	}
+	audit := new(model.Audit)
+	if err := app.RecordQuery(model.DbNameAudits).One(audit); err != nil {
+		t.Fatalf("导出应写入审计记录: %v", err)
+	}
+	if audit.SuperuserId() != admin.Id || audit.ActorId() != "" || audit.ActorRole() != model.UserRoleSuperuser || audit.Action() != service.AuditActionExportStream {
+		t.Errorf("审计记录 superuser = %s, actor = %s, role = %s, action = %s", audit.SuperuserId(), audit.ActorId(), audit.ActorRole(), audit.Action())
+	}
+
+	// 普通用户与运营都不能导出，管理接口仍不允许超级管理员访问
+	for _, user := range []*model.User{player, operator} {
+		if recorder := call(t, handler, http.MethodGet, "/admin/operation/exports", user.Record, nil); recorder.Code != http.StatusForbidden {
+			t.Errorf("%s 查看导出任务 = %d", user.Name(), recorder.Code)
+		}
+		if recorder := call(t, handler, http.MethodPost, "/admin/operation/exports/stream", user.Record, request); recorder.Code != http.StatusForbidden {
+			t.Errorf("%s 导出 = %d", user.Name(), recorder.Code)
+		}
+	}
+	if recorder := call(t, handler, http.MethodGet, "/admin/operation/exports", nil, nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("未登录查看导出任务 = %d", recorder.Code)
+	}
+	if recorder := call(t, handler, http.MethodGet, "/admin/audits", admin, nil); recorder.Code != http.StatusForbidden {
+		t.Errorf("超级管理员访问审计记录 = %d", recorder.Code)
+	}
+}
@@ -1,30 +1,245 @@
 package controller
 
 import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
 
+const (
+	ctxSession = "session"
+)
+
+// queryAuthPaths 允许通过 ?authorization= 传递会话 token 的路由（如 EventSource 无法设置请求头、也不便携带 cookie 的场景）
+var queryAuthPaths = []string{
+	"/api/realtime",
+}
+
 type BaseController struct {
 	event *core.ServeEvent
 	app   core.App
+
+	logger          *slog.Logger
+	sessionService  *service.SessionService
+	activityService *service.ActivityService
+	limiter         *ratelimit.Limiter
+	configRegistry  *config.Registry
 }
 
-func NewBaseController(event *core.ServeEvent) *BaseController {
+func NewBaseController(event *core.ServeEvent, sessionService *service.SessionService, activityService *service.ActivityService, configRegistry *config.Registry) *BaseController {
+	logger := event.App.Logger().With(
+		slog.String("controller", "base"),
+	)
+
 	controller := &BaseController{
-		event: event,
-		app:   event.App,
+		event:           event,
+		app:             event.App,
+		logger:          logger,
+		sessionService:  sessionService,
+		activityService: activityService,
+		limiter:         ratelimit.NewLimiter(),
+		configRegistry:  configRegistry,
 	}
 	return controller
 }
 
-func (controller *BaseController) CheckActivity(event *core.RequestEvent) error {
+// CheckPhase 仅允许在指定的活动阶段内访问
+func (controller *BaseController) CheckPhase(phases ...model.ActivityPhase) func(event *core.RequestEvent) error {
+	return func(event *core.RequestEvent) error {
+		schedule, err := controller.activityService.Schedule()
+		if err != nil {
+			controller.logger.Error("获取活动日程失败", slog.Any("err", err))
+			return event.InternalServerError("获取活动日程失败", err)
+		}
 
-	endTime := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)
-	if time.Now().After(endTime) {
-		return event.ForbiddenError("活动已结束", nil)
+		now := time.Now()
+		phase := schedule.PhaseAt(now)
+		if phase != nil && slices.Contains(phases, phase.Name) {
+			return event.Next()
+		}
+
+		// 尚未进入任何允许的阶段时提示未开始，否则提示已结束
+		for _, started := range schedule.Started(now) {
+			if slices.Contains(phases, started.Name) {
+				return event.ForbiddenError("活动已结束", nil)
+			}
+		}
+		return event.ForbiddenError("活动未开始", nil)
 	}
+}
+
+// RateLimit 按路由规则分别对用户与 IP 限流，需放在 CheckLogin 之后
+func (controller *BaseController) RateLimit(name string) func(event *core.RequestEvent) error {
+	return func(event *core.RequestEvent) error {
+		// 每次请求读取当前规则，修改配置后即时生效
+		rules, err := config.Get[ratelimit.Rules](controller.configRegistry, model.ConfigKeyRatelimit)
+		if err != nil {
+			controller.logger.Error("获取限流规则失败", slog.Any("err", err))
+			return event.Next()
+		}
+		rule, ok := rules[name]
+		if !ok {
+			return event.Next()
+		}
 
+		if event.Auth != nil {
+			if err := controller.takeToken(event, name, model.ThrottleScopeUser, event.Auth.Id, rule.User); err != nil {
+				return err
+			}
+		}
+		if err := controller.takeToken(event, name, model.ThrottleScopeIp, event.RealIP(), rule.Ip); err != nil {
+			return err
+		}
+
+		return event.Next()
+	}
+}
+
+func (controller *BaseController) takeToken(event *core.RequestEvent, name string, scope model.ThrottleScope, id string, limit ratelimit.Limit) error {
+	decision := controller.limiter.Allow(name+":"+scope.String()+":"+id, limit)
+	if decision.Allowed {
+		return nil
+	}
+
+	retryAfter := max(1, int(math.Ceil(decision.RetryAfter.Seconds())))
+	if decision.Report {
+		controller.recordThrottle(event, name, scope, decision.Dropped, retryAfter)
+	}
+
+	event.Response.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+	return event.TooManyRequestsError(fmt.Sprintf("操作太频繁，请 %d 秒后再试", retryAfter), nil)
+}
+
+// recordThrottle 记录被限流的请求，同一个桶的连续拒绝会合并为一条记录
+func (controller *BaseController) recordThrottle(event *core.RequestEvent, name string, scope model.ThrottleScope, dropped int, retryAfter int) {
+	logger := controller.logger.With(
+		slog.String("action", "record_throttle"),
+		slog.String("rule", name),
+		slog.String("scope", scope.String()),
+		slog.String("ip", event.RealIP()),
+	)
+	logger.Warn("请求被限流", slog.Int("dropped", dropped))
+
+	collection, err := controller.app.FindCollectionByNameOrId(model.DbNameThrottles)
+	if err != nil {
+		logger.Error("查找限流记录集合失败", slog.Any("err", err))
+		return
+	}
+
+	throttle := model.NewThrottleFromCollection(collection)
+	throttle.SetRule(name)
+	throttle.SetScope(scope)
+	if event.Auth != nil {
+		throttle.SetUserId(event.Auth.Id)
+	}
+	throttle.SetIp(event.RealIP())
+	throttle.SetMethod(event.Request.Method)
+	throttle.SetPath(event.Request.URL.Path)
+	throttle.SetDropped(dropped)
+	throttle.SetRetryAfter(retryAfter)
+	if err = controller.app.Save(throttle); err != nil {
+		logger.Error("保存限流记录失败", slog.Any("err", err))
+	}
+}
+
+// LoadSession 从会话 cookie（或白名单路由的 query）加载登录用户
+// 携带 Authorization 请求头的请求交给 PocketBase 自身的 token 校验，注销所有设备时会轮换 tokenKey 使其失效
+func (controller *BaseController) LoadSession(event *core.RequestEvent) error {
+	if event.Request.Header.Get("Authorization") != "" {
+		return event.Next()
+	}
+
+	token := ""
+	fromCookie := false
+	if cookie, err := event.Request.Cookie(service.SessionCookieName); err == nil && cookie.Valid() == nil {
+		token = cookie.Value
+		fromCookie = true
+	} else if slices.Contains(queryAuthPaths, event.Request.URL.Path) {
+		token = event.Request.URL.Query().Get("authorization")
+	}
+	if token == "" {
+		return event.Next()
+	}
+
+	session, user, err := controller.sessionService.Authenticate(token, event.RealIP())
+	if err != nil {
+		if fromCookie {
+			clearSessionCookies(event)
+		}
+		return event.Next()
+	}
+
+	// cookie 会被浏览器自动携带，修改类请求需要额外校验 CSRF token
+	if fromCookie && isUnsafeMethod(event.Request.Method) && !slices.Contains(queryAuthPaths, event.Request.URL.Path) {
+		if !service.VerifyCsrf(token, event.Request.Header.Get(service.CsrfHeaderName)) {
+			return event.ForbiddenError("CSRF 校验失败，请刷新页面后重试", nil)
+		}
+	}
+
+	event.Auth = user
+	event.Set(ctxSession, session)
 	return event.Next()
 }
+
+// currentSession 返回当前请求使用的会话，通过 Authorization 请求头登录时为 nil
+func currentSession(event *core.RequestEvent) *model.Session {
+	session, _ := event.Get(ctxSession).(*model.Session)
+	return session
+}
+
+func isUnsafeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// setSessionCookies 写入 HttpOnly 会话 cookie 与前端可读的 CSRF cookie
+func setSessionCookies(event *core.RequestEvent, token string, duration time.Duration) {
+	maxAge := int(duration / time.Second)
+	event.SetCookie(&http.Cookie{
+		Name:     service.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	event.SetCookie(&http.Cookie{
+		Name:     service.CsrfCookieName,
+		Value:    service.CsrfToken(token),
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: false,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookies 清除会话相关 cookie
+func clearSessionCookies(event *core.RequestEvent) {
+	for _, name := range []string{service.SessionCookieName, service.CsrfCookieName} {
+		event.SetCookie(&http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			Expires:  time.Unix(0, 0),
+			Secure:   true,
+			HttpOnly: name == service.SessionCookieName,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
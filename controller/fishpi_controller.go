@@ -2,19 +2,26 @@ package controller
 
 import (
 	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/openid"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/url"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/imroc/req/v3"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+const (
+	fishpiBaseUrl       = "https://fishpi.cn"
+	cookieOpenIdState   = "openid_state"
+	openIdStateDuration = 10 * time.Minute
 )
 
 const (
@@ -28,18 +35,33 @@ type FishPiController struct {
 	event *core.ServeEvent
 	app   core.App
 
-	logger *slog.Logger
+	logger         *slog.Logger
+	relyingParty   *openid.RelyingParty
+	userService    *service.UserService
+	sessionService *service.SessionService
 }
 
-func NewFishPiController(event *core.ServeEvent) *FishPiController {
+func NewFishPiController(event *core.ServeEvent, userService *service.UserService, sessionService *service.SessionService) *FishPiController {
 	logger := event.App.Logger().With(
 		slog.String("controller", "fishpi"),
 	)
 
+	appUrl := strings.TrimSuffix(event.App.Settings().Meta.AppURL, "/")
+
 	controller := &FishPiController{
-		event:  event,
-		app:    event.App,
-		logger: logger,
+		event:          event,
+		app:            event.App,
+		logger:         logger,
+		userService:    userService,
+		sessionService: sessionService,
+		relyingParty: openid.NewRelyingParty(openid.Config{
+			ProviderURL: fishpiBaseUrl,
+			Endpoint:    fishpiBaseUrl + "/openid/login",
+			VerifyURL:   fishpiBaseUrl + "/openid/verify",
+			VerifyJSON:  true,
+			Realm:       appUrl,
+			ReturnTo:    appUrl + "/fishpi/callback",
+		}, service.NewOpenIdNonceStore(event.App, openid.DefaultNonceMaxAge+openid.ClockSkew), nil),
 	}
 
 	controller.registerRoutes()
@@ -62,26 +84,27 @@ func (controller *FishPiController) makeActionLogger(action string) *slog.Logger
 }
 
 func (controller *FishPiController) Login(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("login")
 
-	appUrl := event.App.Settings().Meta.AppURL
-	callbackUrl := fmt.Sprintf("%s/fishpi/callback", appUrl)
-
-	query := url.Values{}
-	query.Set("openid.ns", "http://specs.openid.net/auth/2.0")
-	query.Set("openid.mode", "checkid_setup")
-	query.Set("openid.return_to", callbackUrl)
-	query.Set("openid.realm", appUrl)
-	query.Set("openid.claimed_id", "http://specs.openid.net/auth/2.0/identifier_select")
-	query.Set("openid.identity", "http://specs.openid.net/auth/2.0/identifier_select")
-
-	addr := url.URL{
-		Scheme:   "https",
-		Host:     "fishpi.cn",
-		Path:     "/openid/login",
-		RawQuery: query.Encode(),
+	state := security.RandomString(32)
+	authUrl, err := controller.relyingParty.AuthURL(event.Request.Context(), state)
+	if err != nil {
+		logger.Error("生成认证地址失败", slog.Any("err", err))
+		return event.InternalServerError("生成认证地址失败", err)
 	}
 
-	return event.Redirect(http.StatusFound, addr.String())
+	// state 写入 cookie，回调时与 return_to 中的 state 比对，防止登录 CSRF
+	event.SetCookie(&http.Cookie{
+		Name:     cookieOpenIdState,
+		Value:    state,
+		Path:     "/fishpi",
+		MaxAge:   int(openIdStateDuration / time.Second),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return event.Redirect(http.StatusFound, authUrl)
 }
 
 func (controller *FishPiController) CallbackVerify(event *core.RequestEvent) error {
@@ -89,41 +112,37 @@ func (controller *FishPiController) CallbackVerify(event *core.RequestEvent) err
 		slog.String("path", event.Request.URL.String()),
 	)
 
-	info, err := event.RequestInfo()
-	if err != nil {
-		logger.Error("获取请求信息失败", slog.Any("err", err))
-		return err
+	state := ""
+	if cookie, cookieErr := event.Request.Cookie(cookieOpenIdState); cookieErr == nil {
+		state = cookie.Value
 	}
+	// state 只能使用一次
+	event.SetCookie(&http.Cookie{
+		Name:     cookieOpenIdState,
+		Value:    "",
+		Path:     "/fishpi",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
 
-	query := info.Query
-	query["openid.mode"] = "check_authentication"
-
-	resp := new(req.Response)
-	if resp, err = req.C().R().
-		SetBodyJsonMarshal(query).
-		Post("https://fishpi.cn/openid/verify"); err != nil {
-		logger.Error("发起验证请求失败", slog.Any("err", err))
-		return err
-	}
-	valid := false
-	arr := strings.Split(resp.String(), "\n")
-	for _, line := range arr {
-		if strings.HasPrefix(line, "is_valid:") {
-			valid = strings.TrimPrefix(line, "is_valid:") == "true"
-			break
-		}
+	assertion, err := controller.relyingParty.Verify(event.Request.Context(), event.Request.URL, state)
+	if err != nil {
+		logger.Error("验证失败", slog.Any("err", err))
+		return event.UnauthorizedError("用户信息无效", err)
 	}
-	if !valid {
-		logger.Error("验证失败", slog.String("resp", resp.String()))
-		return errors.New("用户信息无效")
+	openId := assertion.LocalID()
+	if openId == "" {
+		logger.Error("解析openid失败", slog.String("claimed_id", assertion.ClaimedID))
+		return event.UnauthorizedError("用户信息无效", nil)
 	}
-	identity := query["openid.identity"]
-	openid := filepath.Base(identity)
 
+	resp := new(req.Response)
 	result := new(FishpiUserInfoResult)
 	if resp, err = req.C().R().
 		SetSuccessResult(result).
-		Get(fmt.Sprintf("https://fishpi.cn/api/user/getInfoById?userId=%s", openid)); err != nil {
+		Get(fmt.Sprintf("%s/api/user/getInfoById?userId=%s", fishpiBaseUrl, openId)); err != nil {
 		logger.Error("发起获取用户信息请求失败", slog.Any("err", err))
 		return err
 	}
@@ -134,7 +153,7 @@ func (controller *FishPiController) CallbackVerify(event *core.RequestEvent) err
 	}
 
 	user := new(model.User)
-	if err = event.App.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.UsersFieldOId: openid}).One(user); err == nil {
+	if err = event.App.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.UsersFieldOId: openId}).One(user); err == nil {
 		event.Set(ctxFishpiLoginUser, user)
 		event.Set(ctxFishpiUserInfo, result.Data)
 		event.Set(ctxFishpiNext, "login")
@@ -144,7 +163,7 @@ func (controller *FishPiController) CallbackVerify(event *core.RequestEvent) err
 		return err
 	}
 
-	event.Set(ctxFishpiOpenId, openid)
+	event.Set(ctxFishpiOpenId, openId)
 	event.Set(ctxFishpiUserInfo, result.Data)
 	event.Set(ctxFishpiNext, "register")
 
@@ -201,145 +220,58 @@ func (controller *FishPiController) login(event *core.RequestEvent) error {
 		}
 	}
 
-	token, err := user.NewAuthToken()
-	if err != nil {
-		logger.Error("生成token失败", slog.Any("err", err))
-		return err
+	// 爬虫创建的用户补充摸鱼派账号关联，失败不影响登录
+	if err := controller.userService.EnsureFishpiAuth(event.App, user); err != nil {
+		logger.Warn("关联摸鱼派账号失败", slog.Any("err", err))
 	}
 
-	cookie := &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Path:     "/",
-		MaxAge:   int(user.Collection().AuthToken.DurationTime() / time.Second),
-		Secure:   true,
-		HttpOnly: false,
-		SameSite: http.SameSiteNoneMode,
+	if err := controller.startSession(event, user); err != nil {
+		logger.Error("创建会话失败", slog.Any("err", err))
+		return err
 	}
-	event.SetCookie(cookie)
 	return event.Redirect(http.StatusFound, "/?from=login")
 }
 
+// startSession 为用户创建会话并写入会话 cookie
+func (controller *FishPiController) startSession(event *core.RequestEvent, user *model.User) error {
+	token, _, err := controller.sessionService.Create(user, event.Request.UserAgent(), event.RealIP())
+	if err != nil {
+		return err
+	}
+	setSessionCookies(event, token, controller.sessionService.Duration(user))
+	return nil
+}
+
+// register 首次登录且尚未被爬虫创建的用户：按摸鱼派资料注册账号
+// 没有活动文章的用户可以赠送福签，但不能参与博饼
 func (controller *FishPiController) register(event *core.RequestEvent) error {
-	//logger := controller.makeActionLogger("callback register").With(
-	//	slog.String("path", event.Request.URL.String()),
-	//)
-
-	//openid := event.Get(ctxFishpiOpenId).(string)
-	//fishpiUserInfo := event.Get(ctxFishpiUserInfo).(*FishpiUserInfo)
-	//
-	//userCollection, err := event.App.FindCollectionByNameOrId("users")
-	//if err != nil {
-	//	logger.Error("查找用户表失败", slog.Any("err", err))
-	//	return err
-	//}
-	//var file *filesystem.File
-	//if file, err = filesystem.NewFileFromURL(context.Background(), fishpiUserInfo.UserAvatarURL); err != nil {
-	//	logger.Error("头像加载失败", slog.Any("err", err))
-	//	return err
-	//}
-	//
-	//user := core.NewRecord(userCollection)
-	//user.SetEmail(fmt.Sprintf("%s@fishpi.cn", openid))
-	//user.SetEmailVisibility(true)
-	//user.SetVerified(true)
-	//user.Set("name", fishpiUserInfo.Name())
-	//user.Set("avatar", file)
-	//user.Set("avatar_url", fishpiUserInfo.UserAvatarURL)
-	//user.Set("role", "user")
-	//user.SetRandomPassword()
-	//
-	//var authCollection *core.Collection
-	//if authCollection, err = event.App.FindCollectionByNameOrId("externalAuths"); err != nil {
-	//	logger.Error("查找用户表失败", slog.Any("err", err))
-	//	return err
-	//}
-	//externalAuth := core.NewRecord(authCollection)
-	//
-	//externalAuth.Set("provider", "fishpi")
-	//externalAuth.Set("openid", openid)
-	//
-	//var tokenCollection *core.Collection
-	//if tokenCollection, err = event.App.FindCollectionByNameOrId("staticTokens"); err != nil {
-	//	logger.Error("查找token表失败", slog.Any("err", err))
-	//	return err
-	//}
-	//tokenRecord := core.NewRecord(tokenCollection)
-	//
-	//var walletCollection *core.Collection
-	//if walletCollection, err = event.App.FindCollectionByNameOrId("wallets"); err != nil {
-	//	logger.Error("查找钱包表失败", slog.Any("err", err))
-	//	return err
-	//}
-	//wallet := core.NewRecord(walletCollection)
-	//wallet.Set("level", 0)
-	//wallet.Set("balance", 0)
-	//
-	//var token string
-	//if err = event.App.RunInTransaction(func(txApp core.App) error {
-	//	if e := txApp.Save(user); e != nil {
-	//		return e
-	//	}
-	//	externalAuth.Set("user", user.Id)
-	//	if e := txApp.Save(externalAuth); e != nil {
-	//		return e
-	//	}
-	//	wallet.Set("user", user.Id)
-	//	if e := txApp.Save(wallet); e != nil {
-	//		return e
-	//	}
-	//	overdue := time.Hour * 24 * 30
-	//	token, err = user.NewStaticAuthToken(overdue)
-	//	if err != nil {
-	//		logger.Error("创建token失败", slog.Any("err", err))
-	//		return err
-	//	}
-	//	tokenRecord.Set("user", user.Id)
-	//	tokenRecord.Set("token", token)
-	//	tokenRecord.Set("expired", time.Now().Add(overdue))
-	//	if e := txApp.Save(tokenRecord); e != nil {
-	//		return e
-	//	}
-	//
-	//	return nil
-	//}); err != nil {
-	//	bbb, _ := user.MarshalJSON()
-	//	logger.Error("创建用户信息失败", slog.Any("user_record", user), slog.Any("user", string(bbb)), slog.Any("err", err))
-	//	return err
-	//}
-	//
-	//if clientId := event.Request.PathValue("client_id"); clientId != "" {
-	//	if client, clientErr := event.App.SubscriptionsBroker().ClientById(clientId); clientErr == nil {
-	//		// todo 错误处理
-	//		if body, jsonErr := json.Marshal(map[string]any{
-	//			"action": "register",
-	//			"id":     user.Id,
-	//			"token":  token,
-	//			"is_new": true,
-	//		}); jsonErr == nil {
-	//			client.Send(subscriptions.Message{
-	//				Name: "login",
-	//				Data: body,
-	//			})
-	//		}
-	//	}
-	//}
-	//
-	//cookie := &http.Cookie{
-	//	Name:     "token",
-	//	Value:    tokenRecord.GetString("token"),
-	//	Path:     "/",
-	//	MaxAge:   int(tokenRecord.GetDateTime("expired").Sub(types.NowDateTime()).Seconds()),
-	//	Secure:   true,
-	//	HttpOnly: true,
-	//	SameSite: http.SameSiteNoneMode,
-	//}
-	//event.SetCookie(cookie)
-	//return event.JSON(http.StatusOK, map[string]any{
-	//	"action": "register",
-	//	"id":     user.Id,
-	//	"token":  token,
-	//	"is_new": true,
-	//})
+	logger := controller.makeActionLogger("callback register").With(
+		slog.String("path", event.Request.URL.String()),
+	)
+
+	openId := event.Get(ctxFishpiOpenId).(string)
+	fishpiUserInfo := event.Get(ctxFishpiUserInfo).(*FishpiUserInfo)
+
+	logger = logger.With(slog.String("openid", openId), slog.String("name", fishpiUserInfo.UserName))
+
+	var user *model.User
+	if err := event.App.RunInTransaction(func(txApp core.App) error {
+		var saveErr error
+		user, saveErr = controller.userService.SaveFishpiUser(txApp, service.FishpiProfile{
+			OId:      openId,
+			Name:     fishpiUserInfo.UserName,
+			Nickname: fishpiUserInfo.UserNickname,
+			Avatar:   fishpiUserInfo.UserAvatarURL,
+		})
+		return saveErr
+	}); err != nil {
+		logger.Error("创建用户信息失败", slog.Any("fishpi_user_info", fishpiUserInfo), slog.Any("err", err))
+		return err
+	}
+
+	if err := controller.startSession(event, user); err != nil {
+		logger.Error("创建会话失败", slog.Any("err", err))
+		return err
+	}
 	return event.Redirect(http.StatusFound, "/?from=register")
 }
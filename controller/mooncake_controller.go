@@ -2,38 +2,57 @@ package controller
 
 import (
 	"bless-activity/model"
+	"bless-activity/service"
 	"bless-activity/service/fishpi"
+	"bless-activity/service/ledger"
 	"bless-activity/service/mooncakeGambling"
+	"bless-activity/service/quota"
+	"bless-activity/service/ratelimit"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 type MooncakeController struct {
 	event *core.ServeEvent
 	app   core.App
 
-	logger        *slog.Logger
-	game          *mooncakeGambling.MooncakeGame
-	fishpiService *fishpi.Service
-	base          *BaseController
+	logger          *slog.Logger
+	game            *mooncakeGambling.MooncakeGame
+	fishpiService   *fishpi.Service
+	ledgerService   *ledger.Service
+	paidDrawService *service.PaidDrawService
+	awardService    *service.AwardService
+	championService *service.ChampionService
+	quotaService    *quota.Service
+	base            *BaseController
 }
 
-func NewMooncakeController(event *core.ServeEvent, fishpiService *fishpi.Service, base *BaseController) *MooncakeController {
+func NewMooncakeController(event *core.ServeEvent, fishpiService *fishpi.Service, ledgerService *ledger.Service, paidDrawService *service.PaidDrawService, awardService *service.AwardService, championService *service.ChampionService, quotaService *quota.Service, base *BaseController) *MooncakeController {
 	logger := event.App.Logger().With(
 		slog.String("controller", "mooncake"),
 	)
 
 	controller := &MooncakeController{
-		event:         event,
-		app:           event.App,
-		logger:        logger,
-		game:          mooncakeGambling.NewMooncakeGame(),
-		fishpiService: fishpiService,
-		base:          base,
+		event:           event,
+		app:             event.App,
+		logger:          logger,
+		game:            mooncakeGambling.NewMooncakeGame(),
+		fishpiService:   fishpiService,
+		ledgerService:   ledgerService,
+		paidDrawService: paidDrawService,
+		awardService:    awardService,
+		championService: championService,
+		quotaService:    quotaService,
+		base:            base,
 	}
 
 	controller.registerRoutes()
@@ -43,7 +62,7 @@ func NewMooncakeController(event *core.ServeEvent, fishpiService *fishpi.Service
 
 func (controller *MooncakeController) registerRoutes() {
 	group := controller.event.Router.Group("/mooncake")
-	group.POST("/gambling", controller.Gambling).BindFunc(controller.CheckLogin, controller.base.CheckActivity)
+	group.POST("/gambling", controller.Gambling).BindFunc(controller.CheckLogin, controller.base.RateLimit(ratelimit.RuleMooncakeGambling), controller.base.CheckPhase(model.ActivityPhaseVoting, model.ActivityPhaseDrawing))
 	group.GET("/history", controller.GetHistory).BindFunc(controller.CheckLogin)
 }
 
@@ -63,51 +82,75 @@ func (controller *MooncakeController) CheckLogin(event *core.RequestEvent) error
 	return event.Next()
 }
 
-// Gambling 博饼接口
+// Gambling 博饼接口，免费次数用完后 paid 为 true 时花费积分博饼
 func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 	logger := controller.makeActionLogger("gambling")
 
-	user := model.NewUser(event.Auth)
-
-	// 查找用户最新文章
-	article := new(model.Article)
-	if err := controller.app.RecordQuery(model.DbNameArticles).
-		Where(dbx.HashExp{model.ArticlesFieldUserId: user.Id}).
-		OrderBy(model.ArticlesFieldCreatedAt + " desc").
-		One(article); err != nil {
-		logger.Error("查找最新文章失败", slog.Any("err", err))
-		return event.InternalServerError("查找最新文章失败", err)
+	data := struct {
+		Paid bool `json:"paid"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
 	}
 
-	// 查询用户已抽奖次数
-	drawTimes, err := controller.app.CountRecords(model.DbNameHistories, dbx.HashExp{
-		model.HistoriesFieldUserId: user.Id,
-	})
+	user := model.NewUser(event.Auth)
+
+	// 博饼次数，没有活动文章时不能博饼
+	drawQuota, err := controller.quotaService.Get(controller.app, user)
 	if err != nil {
-		logger.Error("查找抽奖次数失败", slog.Any("err", err))
-		return event.InternalServerError("查找抽奖次数失败", err)
+		logger.Error("查询博饼次数失败", slog.Any("err", err))
+		return event.InternalServerError("查询博饼次数失败", err)
 	}
-
-	// 计算剩余次数
-	totalTimes := model.DefaultMooncakeGamblingTimes + article.ThankCnt()
-	// 限制最大次数为20次
-	if totalTimes > model.MaxMooncakeGamblingTimes {
-		totalTimes = model.MaxMooncakeGamblingTimes
+	if !drawQuota.HasArticle {
+		return event.ForbiddenError("发布活动文章后才能参与博饼", nil)
 	}
-	restTimes := totalTimes - int(drawTimes)
-
+	restTimes := drawQuota.Rest
+
+	// 免费次数用完后付费博饼，博饼前扣费，之后保存记录失败时退款
+	var fee *model.Points
+	paidRestTimes := 0
+	saved := false
+	defer func() {
+		if fee != nil && fee.Status() == model.PointStatusSuccess && !saved {
+			controller.paidDrawService.Refund(user, fee, "博饼记录保存失败")
+		}
+	}()
 	if restTimes <= 0 {
-		return event.BadRequestError("博饼次数已用完", nil)
+		if !data.Paid {
+			if drawQuota.Paid.Rest > 0 {
+				return event.BadRequestError(fmt.Sprintf("免费博饼次数已用完，可花费%d积分继续博饼", drawQuota.Paid.Cost), nil)
+			}
+			return event.BadRequestError("博饼次数已用完", nil)
+		}
+
+		fee, err = controller.paidDrawService.Charge(user, drawQuota.Paid)
+		switch {
+		case errors.Is(err, service.ErrPaidDrawDisabled) || errors.Is(err, service.ErrPaidDrawExhausted):
+			return event.BadRequestError(err.Error(), nil)
+		case errors.Is(err, service.ErrPaidDrawCharging):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		case errors.Is(err, ledger.ErrUnknownResult):
+			logger.Error("付费博饼扣费结果未知", slog.Any("err", err))
+			return event.Error(http.StatusConflict, "扣费结果未确认，请稍后再试", nil)
+		case errors.Is(err, service.ErrPaidDrawCharge):
+			logger.Warn("付费博饼扣费失败", slog.Any("err", err))
+			return event.BadRequestError("扣除积分失败，请确认积分是否足够", nil)
+		case err != nil:
+			logger.Error("付费博饼扣费失败", slog.Any("err", err))
+			return event.InternalServerError("付费博饼扣费失败", err)
+		}
+		paidRestTimes = drawQuota.Paid.Rest - 1
+		restTimes = 1
 	}
 
-	// 进行博饼
-	result := controller.game.Play()
+	// 进行博饼，骰子与奖项选择使用同一个种子，可按种子复现
+	seed := rand.Uint64()
+	random := mooncakeGambling.NewSeededRand(seed)
+	result := controller.game.PlayWith(random)
 
-	// 根据 PrizeLevel 查找对应的 awards（可能有多个同等级的奖项）
-	selectedAward := new(model.Awards)
-	if err = controller.app.RecordQuery(model.DbNameAwards).
-		Where(dbx.HashExp{model.AwardsFieldLevel: int(result.PrizeLevel)}).
-		One(selectedAward); err != nil {
+	// 同一等级可能有多个奖项，按权重在用户满足领取条件的奖项中选择
+	selectedAward, eligible, err := controller.awardService.Pick(random, int(result.PrizeLevel), user.Id, types.DateTime{})
+	if err != nil {
 		logger.Error("查找奖项失败", slog.Any("err", err))
 		return event.InternalServerError("查找奖项失败", err)
 	}
@@ -130,7 +173,6 @@ func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 
 	history := model.NewHistoriesFromCollection(historiesCollection)
 	history.SetUserId(user.Id)
-	history.SetTimes(int(drawTimes) + 1)
 	if reward != nil {
 		history.SetRewardId(reward.Id)
 	}
@@ -139,91 +181,81 @@ func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 	}
 	history.SetIsTop(result.PrizeLevel.IsTop())
 	history.SetDetails(result.Dices)
-
-	// 如果是 top 等级，处理 isBest 比较逻辑：
-	// - 查找用户最新的一条 isBest 记录（按时间倒序）
-	// - 若不存在，则将当前记录标记为 isBest
-	// - 若存在，则使用 details() 数据构造 GameResult 并通过 CompareGameResult 比较，较大的为 isBest
-	if result.PrizeLevel.IsTop() {
-		prevBest := new(model.Histories)
-		if err := controller.app.RecordQuery(model.DbNameHistories).
-			Where(dbx.HashExp{
-				model.HistoriesFieldUserId: user.Id,
-				model.HistoriesFieldIsBest: true,
-			}).
-			OrderBy(model.HistoriesFieldCreated + " desc").
-			Limit(1).
-			One(prevBest); err != nil {
-			// 没有找到之前的 isBest（或查询失败）——把当前标记为 isBest
-			history.SetIsBest(true)
-		} else {
-			// 找到之前的最佳记录，按规则比较两次结果
-			prevResult := controller.game.PlayWithDices(prevBest.Details())
-			compare := mooncakeGambling.CompareGameResult(result, prevResult)
-			if compare > 0 {
-				// 当前更好：取消之前的 isBest，并将当前设为 isBest
-				prevBest.SetIsBest(false)
-				if err := controller.app.Save(prevBest); err != nil {
-					logger.Error("取消之前 isBest 记录失败", slog.Any("err", err))
-					// 不中断流程
-				}
-				history.SetIsBest(true)
-			} else {
-				// 仍不如已有的最佳，当前不是 isBest
-				history.SetIsBest(false)
-			}
-		}
-	} else {
-		history.SetIsBest(false)
+	history.SetSeed(strconv.FormatUint(seed, 10))
+	history.SetPickedAwardId(selectedAward.Id)
+	if fee != nil {
+		history.SetFeeId(fee.Id)
 	}
 
-	// 决定是否实际获得奖励（gotReward）
-	got := false
-	if reward != nil {
-		// 查询已经发放的数量（gotReward == true）
-		issuedCount, cntErr := controller.app.CountRecords(model.DbNameHistories, dbx.HashExp{
-			model.HistoriesFieldRewardId:  reward.Id,
-			model.HistoriesFieldGotReward: true,
-		})
-		if cntErr != nil {
-			// 如果查询失败，不中断流程；记录警告，默认不发放
-			logger.Warn("查询已发放奖励数量失败，暂不发放奖励", slog.Any("err", cntErr))
-			issuedCount = 0
+	// 决定是否实际获得奖励（gotReward）：
+	// 状元四点红（PrizeLevelZSiDianHong）及以上仅当 isBest 为 true 时可获得，其他奖励先到先得
+	// 在保存记录的事务中预留库存，非状元奖品发完或下架时降级到备选奖项，事务失败时预留随之回滚
+	if err := controller.app.RunInTransaction(func(txApp core.App) error {
+		// 在事务中重新统计次数，并发博饼时不会超出免费次数，博饼次序也不会重复
+		current, err := controller.quotaService.Get(txApp, user)
+		if err != nil {
+			return err
 		}
-
-		// 特殊规则：level >= 状元四点红（PrizeLevelZSiDianHong）仅当 isBest 为 true 时可获得
-		if result.PrizeLevel >= mooncakeGambling.PrizeLevelZSiDianHong {
-			if history.IsBest() && int(issuedCount) < reward.Amount() {
-				got = true
-			} else {
-				got = false
-			}
-		} else {
-			// 其他奖励：先到先得（只要未超出 reward.Amount 就发放）
-			if int(issuedCount) < reward.Amount() {
-				got = true
-			} else {
-				got = false
+		if fee == nil {
+			if current.Rest <= 0 {
+				return quota.ErrExhausted
 			}
+			restTimes = current.Rest
+			history.SetQuotaSource(current.Next().String())
 		}
-	}
+		history.SetTimes(current.Draws + 1)
 
-	history.SetGotReward(got)
-
-	if err := controller.app.Save(history); err != nil {
+		if err := controller.markBest(txApp, history, result); err != nil {
+			return err
+		}
+		eligible := eligible && (result.PrizeLevel < mooncakeGambling.PrizeLevelZSiDianHong || history.IsBest())
+		history.SetGotReward(false)
+		if err := txApp.Save(history); err != nil {
+			return err
+		}
+		if !eligible {
+			return nil
+		}
+		award, awardReward, err := controller.awardService.Reserve(txApp, history, selectedAward, !result.PrizeLevel.IsTop())
+		if err != nil || award == nil {
+			return err
+		}
+		history.SetAwardId(award.Id)
+		history.SetRewardId(awardReward.Id)
+		history.SetGotReward(true)
+		if err := txApp.Save(history); err != nil {
+			return err
+		}
+		selectedAward, reward = award, awardReward
+		return nil
+	}); errors.Is(err, quota.ErrExhausted) {
+		return event.BadRequestError(err.Error(), nil)
+	} else if err != nil {
 		logger.Error("保存历史记录失败", slog.Any("err", err))
 		return event.InternalServerError("保存历史记录失败", err)
 	}
+	saved = true
+	got := history.GotReward()
+
+	// 全服状元模式：挑战当前状元，失败不影响本次博饼
+	champion, err := controller.championService.Challenge(history, result)
+	if err != nil {
+		logger.Error("挑战全服状元失败", slog.Any("err", err))
+	}
 
 	// 当抽到四进及以上的奖励时，发送消息到聊天室进行活动推广
 	if result.PrizeLevel >= mooncakeGambling.PrizeLevelSiJin || (user.Name() == "8888" && result.PrizeLevel > mooncakeGambling.PrizeLevelNone) {
 		go func() {
 			// 构建聊天室消息
 			var message string
-			if got {
+			if got && reward.Kind() == model.RewardKindPoints {
 				// 获得了实际奖励
 				message = fmt.Sprintf("🎉 恭喜 @%s 在活动《[双节同庆·福签传情](https://fishpi.cn/article/1759997269582)》博中了 **%s**（%s），获得奖励：%d积分！",
 					user.Name(), selectedAward.Name(), reward.Name(), reward.Point())
+			} else if got {
+				// 获得了积分之外的奖品，需要领取
+				message = fmt.Sprintf("🎉 恭喜 @%s 在活动《[双节同庆·福签传情](https://fishpi.cn/article/1759997269582)》博中了 **%s**，获得奖品：%s！",
+					user.Name(), selectedAward.Name(), reward.Name())
 			} else {
 				// 未获得实际奖励（已发完或不符合条件）
 				message = fmt.Sprintf("🎲 @%s 在活动《[双节同庆·福签传情](https://fishpi.cn/article/1759997269582)》博中了 **%s**（%s）！",
@@ -248,48 +280,18 @@ func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 		}()
 	}
 
-	// 发放积分奖励（仅当获得奖励且不是状元级别）
-	if got && reward.Point() > 0 && !result.PrizeLevel.IsTop() {
-		// 创建积分订单记录，状态为待发放
-		pointsCollection, err := controller.app.FindCollectionByNameOrId(model.DbNamePoints)
-		if err != nil {
-			logger.Error("查找points集合失败", slog.Any("err", err))
-			// 不中断流程，只记录错误
-		} else {
-			pointsRecord := model.NewPointsFromCollection(pointsCollection)
-			pointsRecord.SetUserId(user.Id)
-			pointsRecord.SetHistoryId(history.Id)
-			pointsRecord.SetPoint(reward.Point())
-			pointsRecord.SetStatus(model.PointStatusPending)
-			pointsRecord.SetMemo(fmt.Sprintf("活动《双节同庆·福签传情》第%d次博饼：%s(%s)", history.Times(), selectedAward.Name(), reward.Name()))
-
-			// 保存订单记录
-			if err := controller.app.Save(pointsRecord); err != nil {
-				logger.Error("保存积分订单失败", slog.Any("err", err))
-			} else {
-				// 进行积分发放
-				memo := fmt.Sprintf("%s 交易单号：%s", pointsRecord.Memo(), pointsRecord.Id)
-				var distributeErr error
-				if !controller.app.IsDev() {
-					distributeErr = controller.fishpiService.Distribute(user.Name(), reward.Point(), memo)
-				}
-
-				if distributeErr != nil {
-					// 发放失败，更新订单状态
-					logger.Error("发放积分失败", slog.Any("err", distributeErr))
-					pointsRecord.SetStatus(model.PointStatusFailed)
-					pointsRecord.SetError(distributeErr.Error())
-					if err := controller.app.Save(pointsRecord); err != nil {
-						logger.Error("更新积分订单状态失败", slog.Any("err", err))
-					}
-				} else {
-					// 发放成功，更新订单状态
-					pointsRecord.SetStatus(model.PointStatusSuccess)
-					if err := controller.app.Save(pointsRecord); err != nil {
-						logger.Error("更新积分订单状态失败", slog.Any("err", err))
-					}
-				}
-			}
+	// 发放积分奖励（仅当获得积分奖品且不是状元级别），订单以博饼记录为幂等键，其他奖品由用户领取
+	if got && reward.Kind() == model.RewardKindPoints && reward.Point() > 0 && !result.PrizeLevel.IsTop() {
+		if _, err := controller.ledgerService.Pay(ledger.Order{
+			Key:       ledger.HistoryKey(history.Id),
+			Source:    ledger.SourceGambling,
+			UserId:    user.Id,
+			HistoryId: history.Id,
+			Point:     reward.Point(),
+			Memo:      fmt.Sprintf("活动《双节同庆·福签传情》第%d次博饼：%s(%s)", history.Times(), selectedAward.Name(), reward.Name()),
+		}, user.Name()); err != nil {
+			// 不中断流程，失败的订单可重试
+			logger.Error("发放积分失败", slog.Any("err", err))
 		}
 	}
 
@@ -311,6 +313,12 @@ func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 			}
 			return ""
 		}(),
+		"reward_kind": func() string {
+			if reward != nil {
+				return reward.Kind().String()
+			}
+			return ""
+		}(),
 		"award_id": func() string {
 			if selectedAward != nil {
 				return selectedAward.Id
@@ -323,10 +331,50 @@ func (controller *MooncakeController) Gambling(event *core.RequestEvent) error {
 			}
 			return ""
 		}(),
-		"got_reward": history.GotReward(),
+		"got_reward":      history.GotReward(),
+		"champion":        champion != nil,
+		"paid":            fee != nil,
+		"paid_rest_times": paidRestTimes,
 	})
 }
 
+// markBest 状元等级与用户之前最好的一次比较，较大的标记为 isBest，在保存记录的事务中执行
+func (controller *MooncakeController) markBest(txApp core.App, history *model.Histories, result mooncakeGambling.GameResult) error {
+	history.SetIsBest(false)
+	if !result.PrizeLevel.IsTop() {
+		return nil
+	}
+
+	prevBest := new(model.Histories)
+	err := txApp.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{
+			model.HistoriesFieldUserId: history.UserId(),
+			model.HistoriesFieldIsBest: true,
+		}).
+		OrderBy(model.HistoriesFieldCreated + " desc").
+		Limit(1).
+		One(prevBest)
+	if errors.Is(err, sql.ErrNoRows) {
+		history.SetIsBest(true)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 当前更好时取消之前的 isBest
+	prevResult := controller.game.PlayWithDices(prevBest.Details())
+	if mooncakeGambling.CompareGameResult(result, prevResult) <= 0 {
+		return nil
+	}
+	prevBest.SetIsBest(false)
+	if err = txApp.Save(prevBest); err != nil {
+		return err
+	}
+	history.SetIsBest(true)
+	return nil
+}
+
 // GetHistory 获取博饼历史记录
 func (controller *MooncakeController) GetHistory(event *core.RequestEvent) error {
 	logger := controller.makeActionLogger("get_history")
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"bless-activity/service/mooncakeGambling"
+	"bless-activity/service/quota"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// newTestMooncake 使用模拟摸鱼派接口的博饼接口，configs 为需要修改的配置
+func newTestMooncake(t *testing.T, app core.App, configs map[model.ConfigKey]any) (*MooncakeController, *testapp.Fishpi) {
+	t.Helper()
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app,
+		testapp.FishpiDefinition(),
+		config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+			schedule := service.DefaultSchedule()
+			return &schedule
+		}},
+		config.Definition{Key: model.ConfigKeyChampion, Default: func() any { return &service.ChampionConfig{} }},
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+		config.Definition{Key: model.ConfigKeyPaidDraw, Default: func() any { return &service.PaidDrawConfig{} }},
+		config.Definition{Key: model.ConfigKeyQuota, Default: func() any { return quota.DefaultConfig() }},
+	)
+	for key, value := range configs {
+		patch, _ := json.Marshal(value)
+		if _, _, err := registry.Update(app, key, patch); err != nil {
+			t.Fatalf("修改配置 %s 失败: %v", key, err)
+		}
+	}
+
+	fishpiService := fake.Service(t, app, registry)
+	ledgerService := ledger.NewService(app, fishpiService, registry)
+	paidDrawService := service.NewPaidDrawService(app, ledgerService, registry)
+	activityService := service.NewActivityService(app, registry)
+	thankService := service.NewThankService(app, fishpiService, activityService)
+	return &MooncakeController{
+		app:             app,
+		logger:          app.Logger(),
+		game:            mooncakeGambling.NewMooncakeGame(),
+		fishpiService:   fishpiService,
+		ledgerService:   ledgerService,
+		paidDrawService: paidDrawService,
+		awardService:    service.NewAwardService(app, inventory.NewService(app)),
+		championService: service.NewChampionService(app, registry, service.NewNotificationService(app)),
+		quotaService:    quota.NewService(app, registry, thankService, paidDrawService),
+	}, fake
+}
+
+// gamble 以用户身份调用博饼接口
+func gamble(controller *MooncakeController, user *model.User, paid bool) (*httptest.ResponseRecorder, error) {
+	body, _ := json.Marshal(map[string]any{"paid": paid})
+	request := httptest.NewRequest(http.MethodPost, "/mooncake/gambling", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	event := &core.RequestEvent{App: controller.app, Auth: user.Record}
+	event.Request = request
+	event.Response = recorder
+	return recorder, controller.Gambling(event)
+}
+
+func TestMooncakeController_GamblingRefund(t *testing.T) {
+	app := testapp.New(t)
+	controller, fake := newTestMooncake(t, app, map[model.ConfigKey]any{
+		model.ConfigKeyQuota:    quota.Config{Base: 0, Cap: model.MaxMooncakeGamblingTimes},
+		model.ConfigKeyPaidDraw: service.PaidDrawConfig{Enabled: true, Cost: 10},
+	})
+	user := testapp.User(t, app, "1001", "alice")
+	testapp.Article(t, app, user, "2001")
+
+	// 免费次数用完后未选择付费
+	_, err := gamble(controller, user, false)
+	apiErr := new(router.ApiError)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("免费次数用完时应提示付费, 得到 %v", err)
+	}
+
+	// 扣费后保存博饼记录失败，退还扣费
+	errSave := errors.New("模拟保存失败")
+	app.OnRecordCreateExecute(model.DbNameHistories).BindFunc(func(event *core.RecordEvent) error {
+		return errSave
+	})
+	if _, err = gamble(controller, user, true); !errors.As(err, &apiErr) || apiErr.Status != http.StatusInternalServerError {
+		t.Fatalf("保存失败时应返回 500, 得到 %v", err)
+	}
+
+	orders := []*model.Points{}
+	if err = app.RecordQuery(model.DbNamePoints).OrderBy(model.PointsFieldCreated + " asc").All(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("订单数量 = %d, 期望扣费与退款各一条", len(orders))
+	}
+	fee, refund := orders[0], orders[1]
+	if fee.Source() != ledger.SourceDrawFee || fee.Status() != model.PointStatusSuccess {
+		t.Errorf("扣费订单 = %s %s", fee.Source(), fee.Status())
+	}
+	if refund.Source() != ledger.SourceDrawRefund || refund.Status() != model.PointStatusSuccess || refund.Key() != ledger.DrawRefundKey(fee.Key()) {
+		t.Errorf("退款订单 = %s %s %s", refund.Source(), refund.Status(), refund.Key())
+	}
+	if fake.Credited() != 0 {
+		t.Errorf("扣费并退款后积分变化 %d, 期望 0", fake.Credited())
+	}
+	if count, _ := app.CountRecords(model.DbNameHistories, dbx.HashExp{model.HistoriesFieldUserId: user.Id}); count != 0 {
+		t.Errorf("保存失败时不应留下博饼记录, 得到 %d 条", count)
+	}
+}
+
+func TestMooncakeController_GamblingConcurrent(t *testing.T) {
+	app := testapp.New(t)
+	controller, _ := newTestMooncake(t, app, map[model.ConfigKey]any{
+		model.ConfigKeyQuota: quota.Config{Base: 3, Cap: model.MaxMooncakeGamblingTimes},
+	})
+	user := testapp.User(t, app, "1001", "alice")
+	testapp.Article(t, app, user, "2001")
+
+	// 第一次保存博饼记录时在事务中等待，其余请求在此期间完成事务外的次数检查
+	var once sync.Once
+	app.OnRecordCreateExecute(model.DbNameHistories).BindFunc(func(event *core.RecordEvent) error {
+		once.Do(func() { time.Sleep(300 * time.Millisecond) })
+		return event.Next()
+	})
+
+	// 并发博饼时在事务中重新统计次数，不会超出免费次数
+	var wg sync.WaitGroup
+	statuses := make([]int, 10)
+	for i := range statuses {
+		wg.Go(func() {
+			_, err := gamble(controller, user, false)
+			apiErr := new(router.ApiError)
+			switch {
+			case err == nil:
+				statuses[i] = http.StatusOK
+			case errors.As(err, &apiErr):
+				statuses[i] = apiErr.Status
+			default:
+				statuses[i] = http.StatusInternalServerError
+			}
+		})
+	}
+	wg.Wait()
+
+	if ok := len(slices.DeleteFunc(slices.Clone(statuses), func(status int) bool { return status != http.StatusOK })); ok != 3 {
+		t.Errorf("成功 %d 次, 期望 3 次: %v", ok, statuses)
+	}
+	histories := []*model.Histories{}
+	if err := app.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{model.HistoriesFieldUserId: user.Id}).
+		OrderBy(model.HistoriesFieldTimes + " asc").
+		All(&histories); err != nil {
+		t.Fatal(err)
+	}
+	times := []int{}
+	for _, history := range histories {
+		times = append(times, history.Times())
+	}
+	if !slices.Equal(times, []int{1, 2, 3}) {
+		t.Errorf("博饼次序 = %v, 期望 [1 2 3]", times)
+	}
+}
@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/ratelimit"
+	"bless-activity/service/table"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// 返回给用户的牌桌错误
+var tableErrors = []error{
+	table.ErrTableFull,
+	table.ErrAlreadySeated,
+	table.ErrNotSeated,
+	table.ErrNotWaiting,
+	table.ErrNotPlaying,
+	table.ErrNotYourTurn,
+	table.ErrNotEnoughPlayers,
+	table.ErrNotOwner,
+	table.ErrSeatedElsewhere,
+}
+
+// TableController 多人牌桌博饼，牌桌与回合变化通过 realtime 订阅 tables、table_turns 获取
+type TableController struct {
+	event *core.ServeEvent
+	app   core.App
+
+	logger       *slog.Logger
+	tableService *table.Service
+	base         *BaseController
+}
+
+func NewTableController(event *core.ServeEvent, tableService *table.Service, base *BaseController) *TableController {
+	logger := event.App.Logger().With(
+		slog.String("controller", "table"),
+	)
+
+	controller := &TableController{
+		event:        event,
+		app:          event.App,
+		logger:       logger,
+		tableService: tableService,
+		base:         base,
+	}
+
+	controller.registerRoutes()
+
+	return controller
+}
+
+func (controller *TableController) registerRoutes() {
+	group := controller.event.Router.Group("/mooncake/tables")
+	group.BindFunc(controller.CheckLogin)
+	group.GET("", controller.GetTables)
+	group.GET("/{id}", controller.GetTable)
+	group.POST("", controller.CreateTable).BindFunc(controller.base.CheckPhase(model.ActivityPhaseVoting, model.ActivityPhaseDrawing))
+	group.POST("/{id}/join", controller.JoinTable).BindFunc(controller.base.CheckPhase(model.ActivityPhaseVoting, model.ActivityPhaseDrawing))
+	group.POST("/{id}/leave", controller.LeaveTable)
+	group.POST("/{id}/start", controller.StartTable).BindFunc(controller.base.CheckPhase(model.ActivityPhaseVoting, model.ActivityPhaseDrawing))
+	group.POST("/{id}/roll", controller.Roll).BindFunc(controller.base.RateLimit(ratelimit.RuleTableRoll))
+}
+
+func (controller *TableController) makeActionLogger(action string) *slog.Logger {
+	return controller.logger.With(
+		slog.String("action", action),
+	)
+}
+
+func (controller *TableController) CheckLogin(event *core.RequestEvent) error {
+	if event.Auth == nil {
+		return event.UnauthorizedError("未登录", nil)
+	}
+	if event.HasSuperuserAuth() {
+		return event.ForbiddenError("请登录普通用户账号", nil)
+	}
+	return event.Next()
+}
+
+// GetTables 等待中的牌桌与自己所在的牌桌
+func (controller *TableController) GetTables(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_tables")
+
+	tables, err := controller.tableService.List(event.Auth.Id)
+	if err != nil {
+		logger.Error("查找牌桌失败", slog.Any("err", err))
+		return event.InternalServerError("查找牌桌失败", err)
+	}
+
+	result := make([]map[string]any, 0, len(tables))
+	for _, t := range tables {
+		result = append(result, tableResponse(t))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": result,
+		"total": len(result),
+	})
+}
+
+// GetTable 牌桌详情与回合记录，开始后只有入座的玩家可以查看
+func (controller *TableController) GetTable(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_table")
+
+	t, err := controller.tableService.Find(event.Request.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return event.NotFoundError("牌桌不存在", err)
+		}
+		logger.Error("查找牌桌失败", slog.Any("err", err))
+		return event.InternalServerError("查找牌桌失败", err)
+	}
+	if t.Status() != model.TableStatusWaiting && !slices.Contains(t.Players(), event.Auth.Id) {
+		return event.NotFoundError("牌桌不存在", nil)
+	}
+
+	turns, err := controller.tableService.Turns(t.Id)
+	if err != nil {
+		logger.Error("查找回合记录失败", slog.Any("err", err))
+		return event.InternalServerError("查找回合记录失败", err)
+	}
+	standings, err := table.Standings(t)
+	if err != nil {
+		logger.Error("解析牌桌结算失败", slog.Any("err", err))
+		return event.InternalServerError("解析牌桌结算失败", err)
+	}
+
+	items := make([]map[string]any, 0, len(turns))
+	for _, turn := range turns {
+		items = append(items, turnResponse(turn))
+	}
+
+	response := tableResponse(t)
+	response["turns"] = items
+	response["standings"] = standings
+	return event.JSON(http.StatusOK, response)
+}
+
+// CreateTable 创建牌桌
+func (controller *TableController) CreateTable(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("create_table")
+
+	options := table.CreateOptions{}
+	if err := event.BindBody(&options); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+	if err := options.Normalize(); err != nil {
+		return event.BadRequestError(err.Error(), err)
+	}
+
+	t, err := controller.tableService.Create(event.Auth.Id, options)
+	if err != nil {
+		return controller.tableError(event, logger, "创建牌桌失败", err)
+	}
+
+	return event.JSON(http.StatusOK, tableResponse(t))
+}
+
+// JoinTable 入座
+func (controller *TableController) JoinTable(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("join_table")
+
+	t, err := controller.tableService.Join(event.Request.PathValue("id"), event.Auth.Id)
+	if err != nil {
+		return controller.tableError(event, logger, "入座失败", err)
+	}
+
+	return event.JSON(http.StatusOK, tableResponse(t))
+}
+
+// LeaveTable 开始前离座
+func (controller *TableController) LeaveTable(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("leave_table")
+
+	t, err := controller.tableService.Leave(event.Request.PathValue("id"), event.Auth.Id)
+	if err != nil {
+		return controller.tableError(event, logger, "离座失败", err)
+	}
+
+	return event.JSON(http.StatusOK, tableResponse(t))
+}
+
+// StartTable 桌主开始博饼
+func (controller *TableController) StartTable(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("start_table")
+
+	t, err := controller.tableService.Start(event.Request.PathValue("id"), event.Auth.Id)
+	if err != nil {
+		return controller.tableError(event, logger, "开始失败", err)
+	}
+
+	return event.JSON(http.StatusOK, tableResponse(t))
+}
+
+// Roll 轮到自己时博饼
+func (controller *TableController) Roll(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("roll")
+
+	t, turn, err := controller.tableService.Roll(event.Request.PathValue("id"), event.Auth.Id)
+	if err != nil {
+		return controller.tableError(event, logger, "博饼失败", err)
+	}
+
+	response := turnResponse(turn)
+	response["table"] = tableResponse(t)
+	return event.JSON(http.StatusOK, response)
+}
+
+// tableError 牌桌规则错误返回 400，其余错误记录日志并返回 500
+func (controller *TableController) tableError(event *core.RequestEvent, logger *slog.Logger, message string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return event.NotFoundError("牌桌不存在", err)
+	}
+	for _, target := range tableErrors {
+		if errors.Is(err, target) {
+			return event.BadRequestError(err.Error(), err)
+		}
+	}
+
+	logger.Error(message, slog.Any("err", err))
+	return event.InternalServerError(message, err)
+}
+
+func tableResponse(t *model.Table) map[string]any {
+	current := ""
+	if t.Status() == model.TableStatusPlaying && t.Seat() < len(t.Players()) {
+		current = t.Players()[t.Seat()]
+	}
+
+	return map[string]any{
+		"id":             t.Id,
+		"name":           t.Name(),
+		"owner_id":       t.OwnerId(),
+		"status":         t.Status(),
+		"capacity":       t.Capacity(),
+		"max_rounds":     t.MaxRounds(),
+		"turn_timeout":   t.TurnTimeout(),
+		"players":        t.Players(),
+		"seat":           t.Seat(),
+		"round":          t.Round(),
+		"current_player": current,
+		"turn_deadline":  t.TurnDeadline(),
+		"pool":           t.Get(model.TablesFieldPool),
+		"remaining":      t.Get(model.TablesFieldRemaining),
+		"created":        t.Created(),
+	}
+}
+
+func turnResponse(turn *model.TableTurn) map[string]any {
+	return map[string]any{
+		"id":          turn.Id,
+		"user_id":     turn.UserId(),
+		"seat":        turn.Seat(),
+		"round":       turn.Round(),
+		"dices":       turn.Dices(),
+		"prize_level": turn.PrizeLevel(),
+		"prize_name":  turn.PrizeName(),
+		"prize":       turn.Prize(),
+		"auto":        turn.Auto(),
+		"created":     turn.Created(),
+	}
+}
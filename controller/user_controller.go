@@ -2,10 +2,18 @@ package controller
 
 import (
 	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/fulfillment"
+	"bless-activity/service/quota"
+	"bless-activity/service/ratelimit"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
-	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -14,18 +22,30 @@ type UserController struct {
 	event *core.ServeEvent
 	app   core.App
 
-	logger *slog.Logger
+	logger              *slog.Logger
+	sessionService      *service.SessionService
+	notificationService *service.NotificationService
+	fulfillmentService  *fulfillment.Service
+	userRewardService   *service.UserRewardService
+	quotaService        *quota.Service
+	base                *BaseController
 }
 
-func NewUserController(event *core.ServeEvent) *UserController {
+func NewUserController(event *core.ServeEvent, sessionService *service.SessionService, notificationService *service.NotificationService, fulfillmentService *fulfillment.Service, userRewardService *service.UserRewardService, quotaService *quota.Service, base *BaseController) *UserController {
 	logger := event.App.Logger().With(
 		slog.String("controller", "user"),
 	)
 
 	controller := &UserController{
-		event:  event,
-		app:    event.App,
-		logger: logger,
+		event:               event,
+		app:                 event.App,
+		logger:              logger,
+		sessionService:      sessionService,
+		notificationService: notificationService,
+		fulfillmentService:  fulfillmentService,
+		userRewardService:   userRewardService,
+		quotaService:        quotaService,
+		base:                base,
 	}
 
 	controller.registerRoutes()
@@ -38,8 +58,29 @@ func (controller *UserController) registerRoutes() {
 	group.GET("/me", controller.GetMe).BindFunc(
 		controller.CheckLogin,
 	)
-	// 后端登出，清除 token cookie 并重定向到首页
-	group.GET("/logout", controller.Logout)
+	// 后端登出，注销当前会话并清除 cookie；修改会话状态，与其它修改类请求一样需要 CSRF 校验
+	group.POST("/logout", controller.Logout)
+	// 每日签到，活动期间开放
+	group.POST("/checkin", controller.Checkin).BindFunc(controller.CheckLogin, controller.base.CheckPhase(model.ActivityPhaseVoting, model.ActivityPhaseDrawing))
+
+	// 会话管理：登录设备列表、刷新、注销单个设备、注销所有设备
+	group.GET("/sessions", controller.GetSessions).BindFunc(controller.CheckLogin)
+	group.POST("/sessions/refresh", controller.RefreshSession).BindFunc(controller.CheckLogin)
+	group.POST("/sessions/revoke-all", controller.RevokeAllSessions).BindFunc(controller.CheckLogin)
+	group.DELETE("/sessions/{id}", controller.RevokeSession).BindFunc(controller.CheckLogin)
+
+	// 站内通知
+	group.GET("/notifications", controller.GetNotifications).BindFunc(controller.CheckLogin)
+	group.POST("/notifications/{id}/read", controller.ReadNotification).BindFunc(controller.CheckLogin)
+
+	// 我的奖励：获奖记录与到账情况，积分发放失败时可申请重新发放
+	group.GET("/rewards", controller.GetRewards).BindFunc(controller.CheckLogin)
+	group.POST("/rewards/{historyId}/retry", controller.RetryReward).BindFunc(controller.CheckLogin, controller.base.RateLimit(ratelimit.RuleRewardRetry))
+
+	// 兑奖：积分之外的奖品需要领取
+	group.GET("/fulfillments", controller.GetFulfillments).BindFunc(controller.CheckLogin)
+	group.POST("/fulfillments/{id}/claim", controller.ClaimFulfillment).BindFunc(controller.CheckLogin)
+	group.POST("/fulfillments/{id}/confirm", controller.ConfirmFulfillment).BindFunc(controller.CheckLogin)
 }
 
 func (controller *UserController) makeActionLogger(action string) *slog.Logger {
@@ -63,60 +104,411 @@ func (controller *UserController) GetMe(event *core.RequestEvent) error {
 
 	user := model.NewUser(event.Auth)
 
-	article := new(model.Article)
-	if err := controller.app.RecordQuery(model.DbNameArticles).Where(dbx.HashExp{model.ArticlesFieldUserId: user.Id}).OrderBy(model.ArticlesFieldCreatedAt + " desc").One(article); err != nil {
-		logger.Error("查找最新文章失败", slog.Any("err", err))
-		return event.InternalServerError("查找最新文章失败", err)
+	// 博饼次数与各来源的明细，自行注册的用户可能没有活动文章，此时只能赠送福签，不能博饼
+	quota, err := controller.quotaService.Get(controller.app, user)
+	if err != nil {
+		logger.Error("查询博饼次数失败", slog.Any("err", err))
+		return event.InternalServerError("查询博饼次数失败", err)
 	}
 
-	drawTimes, drawTimesErr := controller.app.CountRecords(model.DbNameHistories, dbx.HashExp{model.HistoriesFieldUserId: user.Id})
-	if drawTimesErr != nil {
-		logger.Error("查找抽奖次数失败", slog.Any("err", drawTimesErr))
-		return event.InternalServerError("查找抽奖次数失败", drawTimesErr)
+	thankCnt := 0
+	for _, item := range quota.Items {
+		if item.Source == model.QuotaSourceThanks {
+			thankCnt = item.Times
+		}
 	}
 
-	totalTimes := model.DefaultMooncakeGamblingTimes + article.ThankCnt()
-	// 限制最大次数为20次
-	if totalTimes > model.MaxMooncakeGamblingTimes {
-		totalTimes = model.MaxMooncakeGamblingTimes
-	}
-	restTimes := totalTimes - int(drawTimes)
-
-	return event.JSON(http.StatusOK, map[string]any{
+	response := map[string]any{
 		"id":                              user.Id,
 		"o_id":                            user.OId(),
 		"name":                            user.Name(),
 		"nickname":                        user.Nickname(),
 		"avatar":                          user.Avatar(),
-		"article_id":                      article.Id,
-		"article_o_id":                    article.OId(),
-		"article_title":                   article.Title(),
-		"article_thank_cnt":               article.ThankCnt(),
-		"default_mooncake_gambling_times": model.DefaultMooncakeGamblingTimes,
-		"max_mooncake_gambling_times":     model.MaxMooncakeGamblingTimes,
-		"draw_times":                      drawTimes,
-		"rest_times":                      restTimes,
+		"has_article":                     quota.HasArticle,
+		"article_id":                      "",
+		"article_o_id":                    "",
+		"article_title":                   "",
+		"article_thank_cnt":               0,
+		"valid_thank_cnt":                 thankCnt,
+		"default_mooncake_gambling_times": quota.Base,
+		"max_mooncake_gambling_times":     quota.Cap,
+		"draw_times":                      quota.Draws,
+		"rest_times":                      quota.Rest,
+		"paid_draw":                       quota.Paid,
+		"quota":                           quota,
+	}
+	if article := quota.Article(); article != nil {
+		response["article_id"] = article.Id
+		response["article_o_id"] = article.OId()
+		response["article_title"] = article.Title()
+		response["article_thank_cnt"] = article.ThankCnt()
+	}
+	return event.JSON(http.StatusOK, response)
+}
+
+// Checkin 每日签到，获得的博饼次数当天有效
+func (controller *UserController) Checkin(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("checkin")
+
+	checkin, err := controller.quotaService.Checkin(model.NewUser(event.Auth))
+	switch {
+	case errors.Is(err, quota.ErrCheckinClosed):
+		return event.ForbiddenError(err.Error(), nil)
+	case errors.Is(err, quota.ErrCheckedIn):
+		return event.Error(http.StatusConflict, err.Error(), nil)
+	case err != nil:
+		logger.Error("签到失败", slog.Any("err", err))
+		return event.InternalServerError("签到失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"day":   checkin.Day(),
+		"times": checkin.Times(),
 	})
 }
 
-// Logout 清除 token cookie 并重定向到首页
+// Logout 注销当前会话并清除 cookie
 func (controller *UserController) Logout(event *core.RequestEvent) error {
-	// 设置过期 cookie 清除客户端 token（兼容HttpOnly和非HttpOnly情形）
-	cookie := &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		Expires:  (func() time.Time { t := time.Unix(0, 0); return t })(),
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
-	}
-	event.SetCookie(cookie)
-
-	// 也尝试清除带点域名的 cookie（部分场景需要）
-	// 注意: Go 的 http.SetCookie 无法直接带 domain 设置为 .example.com 进行删除
-	// 如果需要，请在前端或部署环境中统一处理 domain
-
-	return event.Redirect(http.StatusFound, "/")
+	logger := controller.makeActionLogger("logout")
+
+	if session := currentSession(event); session != nil {
+		if err := controller.sessionService.Revoke(session); err != nil {
+			logger.Error("注销会话失败", slog.Any("err", err))
+		}
+	}
+	clearSessionCookies(event)
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// GetSessions 获取当前用户的登录设备列表
+func (controller *UserController) GetSessions(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_sessions")
+
+	user := model.NewUser(event.Auth)
+	sessions, err := controller.sessionService.List(user.Id)
+	if err != nil {
+		logger.Error("查找会话失败", slog.Any("err", err))
+		return event.InternalServerError("查找会话失败", err)
+	}
+
+	currentId := ""
+	if session := currentSession(event); session != nil {
+		currentId = session.Id
+	}
+
+	result := make([]map[string]any, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, map[string]any{
+			"id":             session.Id,
+			"device":         session.Device(),
+			"ip":             session.Ip(),
+			"last_active_at": session.LastActiveAt(),
+			"expires_at":     session.ExpiresAt(),
+			"created":        session.Created(),
+			"current":        session.Id == currentId,
+		})
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": result,
+		"total": len(result),
+	})
+}
+
+// RefreshSession 轮换当前会话 token 并顺延有效期
+func (controller *UserController) RefreshSession(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("refresh_session")
+
+	session := currentSession(event)
+	if session == nil {
+		return event.BadRequestError("当前登录方式不支持刷新会话", nil)
+	}
+
+	user := model.NewUser(event.Auth)
+	token, err := controller.sessionService.Refresh(session, user)
+	if err != nil {
+		logger.Error("刷新会话失败", slog.Any("err", err))
+		return event.InternalServerError("刷新会话失败", err)
+	}
+	setSessionCookies(event, token, controller.sessionService.Duration(user))
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success":    true,
+		"expires_at": session.ExpiresAt(),
+	})
+}
+
+// RevokeSession 注销指定设备的会话
+func (controller *UserController) RevokeSession(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("revoke_session")
+
+	user := model.NewUser(event.Auth)
+	sessionId := event.Request.PathValue("id")
+
+	session := new(model.Session)
+	if err := controller.app.RecordQuery(model.DbNameSessions).
+		Where(dbx.HashExp{
+			model.CommonFieldId:       sessionId,
+			model.SessionsFieldUserId: user.Id,
+		}).
+		One(session); err != nil {
+		return event.NotFoundError("会话不存在", err)
+	}
+
+	if err := controller.sessionService.Revoke(session); err != nil {
+		logger.Error("注销会话失败", slog.Any("err", err))
+		return event.InternalServerError("注销会话失败", err)
+	}
+	if current := currentSession(event); current != nil && current.Id == session.Id {
+		clearSessionCookies(event)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// RevokeAllSessions 注销当前用户在所有设备上的会话
+func (controller *UserController) RevokeAllSessions(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("revoke_all_sessions")
+
+	user := model.NewUser(event.Auth)
+	count, err := controller.sessionService.RevokeAll(user.Id)
+	if err != nil {
+		logger.Error("注销所有会话失败", slog.Any("err", err))
+		return event.InternalServerError("注销所有会话失败", err)
+	}
+	clearSessionCookies(event)
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success": true,
+		"count":   count,
+	})
+}
+
+// GetNotifications 获取当前用户的站内通知，unread=1 时只返回未读通知
+func (controller *UserController) GetNotifications(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_notifications")
+
+	user := model.NewUser(event.Auth)
+	notifications, err := controller.notificationService.List(user.Id, event.Request.URL.Query().Get("unread") == "1", 50)
+	if err != nil {
+		logger.Error("查找通知失败", slog.Any("err", err))
+		return event.InternalServerError("查找通知失败", err)
+	}
+	unread, err := controller.notificationService.Unread(user.Id)
+	if err != nil {
+		logger.Error("统计未读通知失败", slog.Any("err", err))
+		return event.InternalServerError("统计未读通知失败", err)
+	}
+
+	result := make([]map[string]any, 0, len(notifications))
+	for _, notification := range notifications {
+		result = append(result, notificationResponse(notification))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items":  result,
+		"total":  len(result),
+		"unread": unread,
+	})
+}
+
+// ReadNotification 将通知标记为已读
+func (controller *UserController) ReadNotification(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("read_notification")
+
+	user := model.NewUser(event.Auth)
+	notification, err := controller.notificationService.MarkRead(user.Id, event.Request.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return event.NotFoundError("通知不存在", err)
+		}
+		logger.Error("标记通知已读失败", slog.Any("err", err))
+		return event.InternalServerError("标记通知已读失败", err)
+	}
+
+	return event.JSON(http.StatusOK, notificationResponse(notification))
+}
+
+func notificationResponse(notification *model.Notification) map[string]any {
+	return map[string]any{
+		"id":      notification.Id,
+		"type":    notification.Type(),
+		"title":   notification.Title(),
+		"content": notification.Content(),
+		"data":    notification.Get(model.NotificationsFieldData),
+		"read":    notification.Read(),
+		"created": notification.Created(),
+	}
+}
+
+// GetRewards 获取当前用户的获奖记录，包括积分是否到账、失败原因与重新发放的预计时间
+func (controller *UserController) GetRewards(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_rewards")
+
+	user := model.NewUser(event.Auth)
+	rewards, err := controller.userRewardService.List(user.Id)
+	if err != nil {
+		logger.Error("查找获奖记录失败", slog.Any("err", err))
+		return event.InternalServerError("查找获奖记录失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": rewards,
+		"total": len(rewards),
+	})
+}
+
+// RetryReward 申请重新发放博饼记录失败的积分，排队后每分钟分批处理
+func (controller *UserController) RetryReward(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("retry_reward")
+
+	user := model.NewUser(event.Auth)
+	points, err := controller.userRewardService.RequestRetry(user.Id, event.Request.PathValue("historyId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return event.NotFoundError("积分订单不存在", err)
+		case errors.Is(err, service.ErrRetryQueued):
+			return event.Error(http.StatusConflict, err.Error(), map[string]any{"retry_at": points.RetryAt()})
+		case errors.Is(err, service.ErrRetryCooldown):
+			retryAfter := max(1, int(math.Ceil(controller.userRewardService.RetryCooldown(points).Seconds())))
+			event.Response.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+			return event.TooManyRequestsError(fmt.Sprintf("%s，请 %d 秒后再试", err, retryAfter), nil)
+		case errors.Is(err, service.ErrRetryNotAllowed), errors.Is(err, service.ErrRetryExhausted):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		}
+		logger.Error("申请重新发放失败", slog.Any("err", err))
+		return event.InternalServerError("申请重新发放失败", err)
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"success":  true,
+		"retry_at": points.RetryAt(),
+		"retries":  points.Retries(),
+	})
+}
+
+// GetFulfillments 获取当前用户待领取与已领取的奖品
+func (controller *UserController) GetFulfillments(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("get_fulfillments")
+
+	user := model.NewUser(event.Auth)
+	fulfillments, err := controller.fulfillmentService.List(user.Id)
+	if err != nil {
+		logger.Error("查找兑奖记录失败", slog.Any("err", err))
+		return event.InternalServerError("查找兑奖记录失败", err)
+	}
+
+	rewards := map[string]*model.Reward{}
+	result := make([]map[string]any, 0, len(fulfillments))
+	for _, item := range fulfillments {
+		reward, ok := rewards[item.RewardId()]
+		if !ok {
+			reward = new(model.Reward)
+			if err := controller.app.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: item.RewardId()}).One(reward); err != nil {
+				logger.Warn("查找reward失败", slog.Any("err", err), slog.String("reward_id", item.RewardId()))
+				continue
+			}
+			rewards[item.RewardId()] = reward
+		}
+		code, err := controller.fulfillmentService.Code(item)
+		if err != nil {
+			logger.Error("查找兑换码失败", slog.String("fulfillment_id", item.Id), slog.Any("err", err))
+			return event.InternalServerError("查找兑换码失败", err)
+		}
+		result = append(result, fulfillmentResponse(item, reward, code))
+	}
+
+	return event.JSON(http.StatusOK, map[string]any{
+		"items": result,
+		"total": len(result),
+	})
+}
+
+// ClaimFulfillment 领取奖品，实物奖品需要填写收货信息，发货前可重复提交修改
+func (controller *UserController) ClaimFulfillment(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("claim_fulfillment")
+
+	data := struct {
+		Address *model.ShippingAddress `json:"address"`
+	}{}
+	if err := event.BindBody(&data); err != nil {
+		return event.BadRequestError("请求参数错误", err)
+	}
+
+	user := model.NewUser(event.Auth)
+	item, err := controller.fulfillmentService.Claim(user, event.Request.PathValue("id"), data.Address)
+	if err != nil {
+		validationErrors := validation.Errors{}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return event.NotFoundError("兑奖记录不存在", err)
+		case errors.As(err, &validationErrors):
+			return event.BadRequestError("收货信息校验失败", validationErrors)
+		case errors.Is(err, fulfillment.ErrInvalidTransition), errors.Is(err, fulfillment.ErrCodeExhausted):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		case errors.Is(err, fulfillment.ErrMedalFailed):
+			return event.Error(http.StatusBadGateway, err.Error(), nil)
+		}
+		logger.Error("领取奖品失败", slog.Any("err", err))
+		return event.InternalServerError("领取奖品失败", err)
+	}
+
+	return controller.fulfillmentJSON(event, item)
+}
+
+// ConfirmFulfillment 确认实物奖品已收货
+func (controller *UserController) ConfirmFulfillment(event *core.RequestEvent) error {
+	logger := controller.makeActionLogger("confirm_fulfillment")
+
+	user := model.NewUser(event.Auth)
+	item, err := controller.fulfillmentService.Confirm(user, event.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return event.NotFoundError("兑奖记录不存在", err)
+		case errors.Is(err, fulfillment.ErrInvalidTransition):
+			return event.Error(http.StatusConflict, err.Error(), nil)
+		}
+		logger.Error("确认收货失败", slog.Any("err", err))
+		return event.InternalServerError("确认收货失败", err)
+	}
+
+	return controller.fulfillmentJSON(event, item)
+}
+
+func (controller *UserController) fulfillmentJSON(event *core.RequestEvent, item *model.Fulfillment) error {
+	reward := new(model.Reward)
+	if err := controller.app.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: item.RewardId()}).One(reward); err != nil {
+		return event.InternalServerError("查找奖品失败", err)
+	}
+	code, err := controller.fulfillmentService.Code(item)
+	if err != nil {
+		return event.InternalServerError("查找兑换码失败", err)
+	}
+	return event.JSON(http.StatusOK, fulfillmentResponse(item, reward, code))
+}
+
+func fulfillmentResponse(item *model.Fulfillment, reward *model.Reward, code string) map[string]any {
+	return map[string]any{
+		"id":           item.Id,
+		"history_id":   item.HistoryId(),
+		"reward_id":    item.RewardId(),
+		"reward_name":  reward.Name(),
+		"kind":         item.Kind(),
+		"status":       item.Status(),
+		"code":         code,
+		"address":      item.Address(),
+		"tracking_no":  item.TrackingNo(),
+		"message":      item.Message(),
+		"claimed_at":   item.ClaimedAt(),
+		"shipped_at":   item.ShippedAt(),
+		"delivered_at": item.DeliveredAt(),
+		"created":      item.Created(),
+	}
 }
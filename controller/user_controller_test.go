@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/ledger"
+	"bless-activity/service/ratelimit"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// serve 触发 register 注册路由，返回不监听端口的 HTTP 处理器
+func serve(t *testing.T, app core.App, register func(event *core.ServeEvent)) http.Handler {
+	t.Helper()
+	router, err := apis.NewRouter(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register(&core.ServeEvent{App: app, Router: router})
+	mux, err := router.BuildMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux
+}
+
+// call 以 auth 的身份请求接口，auth 为 nil 时不登录，body 非 nil 时以 JSON 发送
+func call(t *testing.T, handler http.Handler, method string, path string, auth *core.Record, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	request := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if auth != nil {
+		token, err := auth.NewAuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Authorization", token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// failedHistory 创建积分发放失败的获奖记录
+func failedHistory(t *testing.T, app core.App, ledgerService *ledger.Service, fake *testapp.Fishpi, user *model.User) *model.Histories {
+	t.Helper()
+	reward := testapp.Reward(t, app, "积分奖励"+user.Name(), 10)
+	reward.SetKind(model.RewardKindPoints)
+	reward.SetLevel(1)
+	reward.SetPoint(8)
+	if err := app.Save(reward); err != nil {
+		t.Fatal(err)
+	}
+	history := testapp.History(t, app, user, reward, 1)
+	history.SetGotReward(true)
+	if err := app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.SetMode(testapp.FishpiReject)
+	defer fake.SetMode(testapp.FishpiOK)
+	if points, _ := ledgerService.Pay(ledger.Order{
+		Key:       ledger.HistoryKey(history.Id),
+		Source:    ledger.SourceGambling,
+		UserId:    user.Id,
+		HistoryId: history.Id,
+		Point:     reward.Point(),
+	}, user.Name()); points == nil || points.Status() != model.PointStatusFailed {
+		t.Fatalf("积分订单应发放失败, 得到 %v", points)
+	}
+	return history
+}
+
+func TestUserController_Rewards(t *testing.T) {
+	app := testapp.New(t)
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app,
+		testapp.FishpiDefinition(),
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+		config.Definition{Key: model.ConfigKeyRatelimit, Default: func() any {
+			rules := ratelimit.DefaultRules()
+			return &rules
+		}},
+	)
+	ledgerService := ledger.NewService(app, fake.Service(t, app, registry), registry)
+	userRewardService := service.NewUserRewardService(app, ledgerService, service.NewNotificationService(app))
+	handler := serve(t, app, func(event *core.ServeEvent) {
+		controller := &UserController{
+			event:             event,
+			app:               app,
+			logger:            app.Logger(),
+			userRewardService: userRewardService,
+			base:              NewBaseController(event, service.NewSessionService(app), nil, registry),
+		}
+		controller.registerRoutes()
+	})
+
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+	history := failedHistory(t, app, ledgerService, fake, alice)
+	bobHistory := failedHistory(t, app, ledgerService, fake, bob)
+
+	if recorder := call(t, handler, http.MethodGet, "/user/rewards", nil, nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("未登录 = %d", recorder.Code)
+	}
+
+	// 只列出自己的奖励，失败的积分可以申请重新发放
+	recorder := call(t, handler, http.MethodGet, "/user/rewards", alice.Record, nil)
+	body := struct {
+		Items []service.UserReward `json:"items"`
+		Total int                  `json:"total"`
+	}{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("获取奖励 = %d %s", recorder.Code, recorder.Body)
+	}
+	if body.Total != 1 || body.Items[0].HistoryId != history.Id || body.Items[0].Status != service.UserRewardStatusFailed || !body.Items[0].CanRetry {
+		t.Errorf("alice 的奖励 = %+v", body)
+	}
+
+	// 排队后重复申请提示等待，同一用户超出次数后限流
+	retry := "/user/rewards/" + history.Id + "/retry"
+	if recorder = call(t, handler, http.MethodPost, retry, alice.Record, nil); recorder.Code != http.StatusOK {
+		t.Errorf("申请重新发放 = %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder = call(t, handler, http.MethodPost, retry, alice.Record, nil); recorder.Code != http.StatusConflict {
+		t.Errorf("重复申请 = %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder = call(t, handler, http.MethodPost, retry, alice.Record, nil); recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("超出次数 = %d %s", recorder.Code, recorder.Body)
+	}
+
+	// 限流按用户计数，不影响其他用户
+	if recorder = call(t, handler, http.MethodPost, "/user/rewards/"+bobHistory.Id+"/retry", bob.Record, nil); recorder.Code != http.StatusOK {
+		t.Errorf("bob 申请重新发放 = %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder = call(t, handler, http.MethodPost, retry, bob.Record, nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("申请他人的订单 = %d %s", recorder.Code, recorder.Body)
+	}
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bless-activity/model"
+	"bless-activity/service/ratelimit"
 	"log/slog"
 	"net/http"
 
@@ -36,8 +37,8 @@ func NewVoteController(event *core.ServeEvent, base *BaseController) *VoteContro
 
 func (controller *VoteController) registerRoutes() {
 	group := controller.event.Router.Group("/vote")
-	group.POST("", controller.CreateVote).BindFunc(controller.CheckLogin, controller.base.CheckActivity)
-	group.DELETE("/{id}", controller.DeleteVote).BindFunc(controller.CheckLogin, controller.base.CheckActivity)
+	group.POST("", controller.CreateVote).BindFunc(controller.CheckLogin, controller.base.RateLimit(ratelimit.RuleVoteCreate), controller.base.CheckPhase(model.ActivityPhaseVoting))
+	group.DELETE("/{id}", controller.DeleteVote).BindFunc(controller.CheckLogin, controller.base.RateLimit(ratelimit.RuleVoteDelete), controller.base.CheckPhase(model.ActivityPhaseVoting))
 	group.GET("/my", controller.GetMyVotes).BindFunc(controller.CheckLogin)
 	group.GET("/rank", controller.GetVoteRank)
 	group.GET("/statistics", controller.GetStatistics)
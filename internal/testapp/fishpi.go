@@ -0,0 +1,164 @@
+package testapp
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/fishpi"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FishpiMode 模拟积分接口的响应方式
+type FishpiMode int
+
+const (
+	// FishpiOK 发放成功
+	FishpiOK FishpiMode = iota
+	// FishpiReject 接口返回失败，积分未到账
+	FishpiReject
+	// FishpiDrop 积分已到账但连接在响应前断开，调用方无法确认结果
+	FishpiDrop
+)
+
+// FishpiCall 一次积分发放请求
+type FishpiCall struct {
+	UserName string
+	Point    int
+	Memo     string
+	Credited bool
+}
+
+// Fishpi 模拟摸鱼派积分接口与文章感谢明细接口，记录每次发放请求
+type Fishpi struct {
+	server *httptest.Server
+
+	mutex  sync.Mutex
+	mode   FishpiMode
+	calls  []FishpiCall
+	thanks map[string][]*fishpi.GetApiArticleThanksResponseThank
+}
+
+func NewFishpi(t testing.TB) *Fishpi {
+	fake := &Fishpi{thanks: map[string][]*fishpi.GetApiArticleThanksResponseThank{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /user/edit/points", fake.editPoints)
+	mux.HandleFunc("GET /api/article/{id}/thanks", fake.articleThanks)
+	fake.server = httptest.NewServer(mux)
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+// SetMode 设置之后请求的响应方式
+func (fake *Fishpi) SetMode(mode FishpiMode) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.mode = mode
+}
+
+// Calls 收到的发放请求
+func (fake *Fishpi) Calls() []FishpiCall {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]FishpiCall(nil), fake.calls...)
+}
+
+// Credited 实际到账的积分合计
+func (fake *Fishpi) Credited() int {
+	total := 0
+	for _, call := range fake.Calls() {
+		if call.Credited {
+			total += call.Point
+		}
+	}
+	return total
+}
+
+// Bind 将摸鱼派配置指向模拟接口，registry 需已注册摸鱼派配置
+func (fake *Fishpi) Bind(t testing.TB, app core.App, registry *config.Registry) {
+	t.Helper()
+	patch, _ := json.Marshal(map[string]any{"base_url": fake.server.URL})
+	if _, _, err := registry.Update(app, model.ConfigKeyFishpi, patch); err != nil {
+		t.Fatalf("更新摸鱼派配置失败: %v", err)
+	}
+}
+
+func (fake *Fishpi) editPoints(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		UserName string `json:"userName"`
+		Point    int    `json:"point"`
+		Memo     string `json:"memo"`
+	}{}
+	_ = json.NewDecoder(r.Body).Decode(&data)
+
+	fake.mutex.Lock()
+	mode := fake.mode
+	fake.calls = append(fake.calls, FishpiCall{UserName: data.UserName, Point: data.Point, Memo: data.Memo, Credited: mode != FishpiReject})
+	fake.mutex.Unlock()
+
+	switch mode {
+	case FishpiReject:
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": -1, "msg": "模拟发放失败"})
+	case FishpiDrop:
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0})
+	}
+}
+
+// SetThanks 设置文章的感谢与打赏明细，按先后排列
+func (fake *Fishpi) SetThanks(articleOId string, thanks ...*fishpi.GetApiArticleThanksResponseThank) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.thanks[articleOId] = thanks
+}
+
+func (fake *Fishpi) articleThanks(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	page, size = max(page, 1), max(size, 1)
+
+	fake.mutex.Lock()
+	thanks := fake.thanks[r.PathValue("id")]
+	fake.mutex.Unlock()
+
+	start, end := min((page-1)*size, len(thanks)), min(page*size, len(thanks))
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "data": map[string]any{
+		"thanks":     thanks[start:end],
+		"pagination": map[string]any{"paginationPageCount": (len(thanks) + size - 1) / size},
+	}})
+}
+
+// Registry 注册并加载配置项，没有记录时使用默认值
+func Registry(t testing.TB, app core.App, definitions ...config.Definition) *config.Registry {
+	t.Helper()
+	registry := config.NewRegistry(app, nil)
+	registry.Register(definitions...)
+	if err := registry.Load(); err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return registry
+}
+
+// FishpiDefinition 摸鱼派配置项
+func FishpiDefinition() config.Definition {
+	return config.Definition{Key: model.ConfigKeyFishpi, Default: func() any { return fishpi.DefaultConfig() }}
+}
+
+// FishpiService 使用模拟接口的摸鱼派服务
+func (fake *Fishpi) Service(t testing.TB, app core.App, registry *config.Registry) *fishpi.Service {
+	t.Helper()
+	fake.Bind(t, app, registry)
+	service, err := fishpi.NewService(app, registry)
+	if err != nil {
+		t.Fatalf("创建摸鱼派服务失败: %v", err)
+	}
+	return service
+}
@@ -0,0 +1,129 @@
+// Package testapp 为需要数据库的测试创建执行过全部迁移的临时应用
+package testapp
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "bless-activity/migrations"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// New 在临时目录中创建应用并执行全部迁移，测试结束后自动清理
+func New(t testing.TB) core.App {
+	t.Helper()
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatalf("初始化应用失败: %v", err)
+	}
+	if err := app.RunAllMigrations(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = app.ResetBootstrapState()
+	})
+	return app
+}
+
+// User 创建摸鱼派用户
+func User(t testing.TB, app core.App, oId string, name string) *model.User {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(model.DbNameUsers)
+	if err != nil {
+		t.Fatalf("查询用户集合失败: %v", err)
+	}
+	user := model.NewUserFromCollection(collection)
+	user.SetOId(oId)
+	user.SetName(name)
+	user.SetNickname(name)
+	user.SetEmail(name + "@fishpi.cn")
+	user.SetPassword(name + "-password")
+	if err = app.Save(user); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// Article 创建用户的活动文章
+func Article(t testing.TB, app core.App, user *model.User, oId string) *model.Article {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(model.DbNameArticles)
+	if err != nil {
+		t.Fatalf("查询文章集合失败: %v", err)
+	}
+	article := model.NewArticleFromCollection(collection)
+	article.SetUserId(user.Id)
+	article.SetOId(oId)
+	article.SetTitle("文章" + oId)
+	if err = app.Save(article); err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+	return article
+}
+
+// Reward 创建指定数量的实物奖品
+func Reward(t testing.TB, app core.App, name string, amount int) *model.Reward {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(model.DbNameRewards)
+	if err != nil {
+		t.Fatalf("查询奖励集合失败: %v", err)
+	}
+	reward := model.NewRewardFromCollection(collection)
+	reward.SetName(name)
+	reward.SetKind(model.RewardKindPhysical)
+	reward.SetAmount(amount)
+	if err = app.Save(reward); err != nil {
+		t.Fatalf("创建奖励失败: %v", err)
+	}
+	return reward
+}
+
+// Award 奖励对应的奖项，没有时创建
+func Award(t testing.TB, app core.App, reward *model.Reward) *model.Awards {
+	t.Helper()
+	award := new(model.Awards)
+	err := app.RecordQuery(model.DbNameAwards).Where(dbx.HashExp{model.AwardsFieldRewardId: reward.Id}).One(award)
+	if err == nil {
+		return award
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("查询奖项失败: %v", err)
+	}
+	collection, err := app.FindCollectionByNameOrId(model.DbNameAwards)
+	if err != nil {
+		t.Fatalf("查询奖项集合失败: %v", err)
+	}
+	award = model.NewAwardsFromCollection(collection)
+	award.SetName(reward.Name())
+	award.SetLevel(reward.Level())
+	award.SetRewardId(reward.Id)
+	award.SetWeight(1)
+	award.SetCondition(model.AwardConditionNone)
+	if err = app.Save(award); err != nil {
+		t.Fatalf("创建奖项失败: %v", err)
+	}
+	return award
+}
+
+// History 创建用户的博饼记录
+func History(t testing.TB, app core.App, user *model.User, reward *model.Reward, times int) *model.Histories {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(model.DbNameHistories)
+	if err != nil {
+		t.Fatalf("查询博饼记录集合失败: %v", err)
+	}
+	history := model.NewHistoriesFromCollection(collection)
+	history.SetUserId(user.Id)
+	history.SetTimes(times)
+	history.SetAwardId(Award(t, app, reward).Id)
+	history.SetRewardId(reward.Id)
+	history.SetDetails([6]int{1, 2, 3, 4, 5, 6})
+	if err = app.Save(history); err != nil {
+		t.Fatalf("创建博饼记录失败: %v", err)
+	}
+	return history
+}
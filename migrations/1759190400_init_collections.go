@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 活动的基础集合：用户扩展字段、配置、文章、奖励、奖项、博饼记录、福签、积分订单
+func init() {
+	m.Register(func(app core.App) error {
+		if err := extendUsers(app); err != nil {
+			return err
+		}
+
+		configs := core.NewBaseCollection("configs", "pbc_3543795673")
+		configs.Fields.Add(
+			&core.TextField{Id: "text2324736937", Name: "key"},
+			&core.JSONField{Id: "json494360628", Name: "value"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		configs.AddIndex("idx_YKdpxU3F6m", true, "`key`", "")
+
+		articles := core.NewBaseCollection("articles", "pbc_4287850865")
+		articles.ListRule = types.Pointer("")
+		articles.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.TextField{Id: "text3618505730", Name: "oId", Required: true},
+			&core.TextField{Id: "text724990059", Name: "title"},
+			&core.TextField{Id: "text1990010230", Name: "previewContent"},
+			&core.NumberField{Id: "number3884439329", Name: "viewCount"},
+			&core.NumberField{Id: "number1530089671", Name: "goodCnt"},
+			&core.NumberField{Id: "number1057733009", Name: "commentCount"},
+			&core.NumberField{Id: "number770600511", Name: "collectCnt"},
+			&core.NumberField{Id: "number572975045", Name: "thankCnt"},
+			&core.NumberField{Id: "number848901969", Name: "score"},
+			&core.BoolField{Id: "bool391258049", Name: "flagged"},
+			&core.TextField{Id: "text1520603174", Name: "flagReason", Hidden: true},
+			&core.BoolField{Id: "bool2831528131", Name: "disqualified"},
+			&core.DateField{Id: "date2261412156", Name: "createdAt"},
+			&core.DateField{Id: "date3175243278", Name: "updatedAt"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		articles.AddIndex("idx_4wJXYespWZ", true, "`oId`", "")
+
+		rewards := core.NewBaseCollection("rewards", "pbc_2020696541")
+		rewards.ListRule = types.Pointer("")
+		rewards.ViewRule = types.Pointer("")
+		rewards.Fields.Add(
+			&core.NumberField{Id: "number2599078931", Name: "level"},
+			&core.TextField{Id: "text1579384326", Name: "name"},
+			&core.NumberField{Id: "number3081106212", Name: "point"},
+			&core.NumberField{Id: "number2392944706", Name: "amount"},
+			&core.TextField{Id: "text2337469052", Name: "more"},
+		)
+		rewards.AddIndex("idx_H4yexeZJwR", true, "`name`", "")
+
+		awards := core.NewBaseCollection("awards", "pbc_318348976")
+		awards.ListRule = types.Pointer("")
+		awards.ViewRule = types.Pointer("")
+		awards.Fields.Add(
+			&core.NumberField{Id: "number2599078931", Name: "level"},
+			&core.TextField{Id: "text1579384326", Name: "name"},
+			&core.RelationField{Id: "relation85988260", Name: "rewardId", CollectionId: "pbc_2020696541", MaxSelect: 1},
+			&core.TextField{Id: "text1843675174", Name: "description"},
+		)
+		awards.AddIndex("idx_JWQQvXjYNI", true, "`level`", "")
+
+		histories := core.NewBaseCollection("histories", "pbc_2883201083")
+		histories.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.NumberField{Id: "number500690572", Name: "times", Required: true},
+			&core.RelationField{Id: "relation3881083213", Name: "awardId", Required: true, CollectionId: "pbc_318348976", MaxSelect: 1},
+			&core.RelationField{Id: "relation85988260", Name: "rewardId", Required: true, CollectionId: "pbc_2020696541", MaxSelect: 1},
+			&core.BoolField{Id: "bool4066340203", Name: "isTop"},
+			&core.BoolField{Id: "bool204497731", Name: "isBest"},
+			&core.BoolField{Id: "bool928540714", Name: "gotReward"},
+			&core.JSONField{Id: "json1915095946", Name: "details", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		votes := core.NewBaseCollection("votes", "pbc_2597176356")
+		votes.Fields.Add(
+			&core.RelationField{Id: "relation3495199097", Name: "fromUserId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.RelationField{Id: "relation3793655126", Name: "toUserId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.RelationField{Id: "relation4272070894", Name: "articleId", CollectionId: "pbc_4287850865", MaxSelect: 1},
+			&core.SelectField{Id: "select3190483859", Name: "voteType", Values: []string{"career", "romance", "wealth"}, MaxSelect: 1},
+			&core.BoolField{Id: "bool391258049", Name: "flagged"},
+			&core.TextField{Id: "text1520603174", Name: "flagReason", Hidden: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		votes.AddIndex("idx_PCotpNB086", true, "`fromUserId`, `voteType`", "")
+		votes.AddIndex("idx_4JuizFDvXp", true, "`fromUserId`, `toUserId`", "")
+
+		points := core.NewBaseCollection("points", "pbc_279573351")
+		points.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.RelationField{Id: "relation1765114810", Name: "historyId", CollectionId: "pbc_2883201083", MaxSelect: 1},
+			&core.NumberField{Id: "number3081106212", Name: "point"},
+			&core.SelectField{Id: "select2063623452", Name: "status", Values: []string{"pending", "success", "failed"}, MaxSelect: 1},
+			&core.TextField{Id: "text2873790506", Name: "memo"},
+			&core.TextField{Id: "text1574812785", Name: "error"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		awardCount := core.NewViewCollection("award_count", "pbc_1938344286")
+		awardCount.ViewQuery = "select awardId as id, awardId, count(id) as `count` from histories group by awardId"
+
+		return createCollections(app, configs, articles, rewards, awards, histories, votes, points, awardCount)
+	}, func(app core.App) error {
+		if err := deleteCollections(app, "configs", "articles", "rewards", "awards", "histories", "votes", "points", "award_count"); err != nil {
+			return err
+		}
+
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"nickname", "oId", "role"} {
+			users.Fields.RemoveByName(name)
+		}
+		users.RemoveIndex("idx_mLY2AGmQwo")
+		return app.Save(users)
+	})
+}
+
+// extendUsers 为默认的 users 集合添加摸鱼派用户字段，已扩展过时跳过
+func extendUsers(app core.App) error {
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return err
+	}
+	if users.Fields.GetByName("oId") != nil {
+		return nil
+	}
+
+	// 默认的 avatar 是文件字段，头像使用摸鱼派的链接，先移除再添加同名链接字段
+	if avatar := users.Fields.GetByName("avatar"); avatar != nil && avatar.Type() != core.FieldTypeURL {
+		users.Fields.RemoveByName("avatar")
+		if err = app.Save(users); err != nil {
+			return fmt.Errorf("移除头像文件字段失败: %w", err)
+		}
+	}
+
+	users.ListRule = types.Pointer("id = @request.auth.id")
+	users.ViewRule = types.Pointer("")
+	users.CreateRule = types.Pointer("@request.body.role:isset = false")
+	users.UpdateRule = types.Pointer("id = @request.auth.id && @request.body.role:isset = false")
+	users.DeleteRule = types.Pointer("id = @request.auth.id")
+	users.Fields.Add(
+		&core.TextField{Id: "text2710109796", Name: "nickname"},
+		&core.URLField{Id: "url376926767", Name: "avatar"},
+		&core.TextField{Id: "text3618505730", Name: "oId", Required: true},
+		&core.SelectField{Id: "select1466534506", Name: "role", Values: []string{"player", "moderator", "operator"}, MaxSelect: 1},
+	)
+	users.AddIndex("idx_mLY2AGmQwo", true, "`oId`", "")
+	if err = app.Save(users); err != nil {
+		return fmt.Errorf("扩展用户集合失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 服务端会话
+func init() {
+	m.Register(func(app core.App) error {
+		sessions := core.NewBaseCollection("sessions", "pbc_2590022931")
+		sessions.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.TextField{Id: "text3855182112", Name: "tokenHash", Required: true, Hidden: true},
+			&core.TextField{Id: "text154121870", Name: "device"},
+			&core.TextField{Id: "text2783163181", Name: "ip"},
+			&core.DateField{Id: "date2455564630", Name: "lastActiveAt"},
+			&core.DateField{Id: "date730627375", Name: "expiresAt", Required: true},
+			&core.BoolField{Id: "bool3181538509", Name: "revoked"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		sessions.AddIndex("idx_sessions_tokenHash", true, "`tokenHash`", "")
+		sessions.AddIndex("idx_sessions_userId", false, "`userId`", "")
+
+		return createCollections(app, sessions)
+	}, func(app core.App) error {
+		return deleteCollections(app, "sessions")
+	})
+}
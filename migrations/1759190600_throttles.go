@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 限流记录
+func init() {
+	m.Register(func(app core.App) error {
+		throttles := core.NewBaseCollection("throttles", "pbc_2992225552")
+		throttles.Fields.Add(
+			&core.TextField{Id: "text1188605132", Name: "rule", Required: true},
+			&core.SelectField{Id: "select11490771", Name: "scope", Required: true, Values: []string{"user", "ip"}, MaxSelect: 1},
+			&core.RelationField{Id: "relation1689669068", Name: "userId", CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.TextField{Id: "text2783163181", Name: "ip"},
+			&core.TextField{Id: "text1582905952", Name: "method"},
+			&core.TextField{Id: "text190089999", Name: "path"},
+			&core.NumberField{Id: "number3531999665", Name: "dropped", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number4138036481", Name: "retryAfter", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		throttles.AddIndex("idx_throttles_rule_created", false, "`rule`, `created`", "")
+		throttles.AddIndex("idx_throttles_userId", false, "`userId`", "")
+		throttles.AddIndex("idx_throttles_ip", false, "`ip`", "")
+
+		return createCollections(app, throttles)
+	}, func(app core.App) error {
+		return deleteCollections(app, "throttles")
+	})
+}
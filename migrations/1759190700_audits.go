@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 管理操作审计
+func init() {
+	m.Register(func(app core.App) error {
+		audits := core.NewBaseCollection("audits", "pbc_843390572")
+		audits.Fields.Add(
+			&core.RelationField{Id: "relation1842063794", Name: "actorId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.SelectField{Id: "select1207772737", Name: "actorRole", Required: true, Values: []string{"player", "moderator", "operator"}, MaxSelect: 1},
+			&core.TextField{Id: "text1204587666", Name: "action", Required: true},
+			&core.TextField{Id: "text3392714195", Name: "targetCollection"},
+			&core.TextField{Id: "text962958965", Name: "targetId"},
+			&core.JSONField{Id: "json3627769262", Name: "before"},
+			&core.JSONField{Id: "json2302955073", Name: "after"},
+			&core.TextField{Id: "text2783163181", Name: "ip"},
+			&core.TextField{Id: "text2873790506", Name: "memo"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		audits.AddIndex("idx_audits_actorId", false, "`actorId`", "")
+		audits.AddIndex("idx_audits_targetCollection_targetId", false, "`targetCollection`, `targetId`", "")
+		audits.AddIndex("idx_audits_action", false, "`action`", "")
+
+		return createCollections(app, audits)
+	}, func(app core.App) error {
+		return deleteCollections(app, "audits")
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 活动阶段钩子执行记录与阶段快照
+func init() {
+	m.Register(func(app core.App) error {
+		phaseHooks := core.NewBaseCollection("phase_hooks", "pbc_3700275265")
+		phaseHooks.Fields.Add(
+			&core.SelectField{Id: "select2982008523", Name: "phase", Required: true, Values: []string{"preheat", "voting", "drawing", "settlement", "archive"}, MaxSelect: 1},
+			&core.TextField{Id: "text2757247829", Name: "hook", Required: true},
+			&core.SelectField{Id: "select2063623452", Name: "status", Required: true, Values: []string{"success", "failed"}, MaxSelect: 1},
+			&core.TextField{Id: "text1574812785", Name: "error"},
+			&core.DateField{Id: "date3851565399", Name: "runAt"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		phaseHooks.AddIndex("idx_phase_hooks_phase_hook", true, "`phase`, `hook`", "")
+
+		snapshots := core.NewBaseCollection("snapshots", "pbc_1301366333")
+		snapshots.Fields.Add(
+			&core.TextField{Id: "text1579384326", Name: "name", Required: true},
+			&core.SelectField{Id: "select2982008523", Name: "phase", Values: []string{"preheat", "voting", "drawing", "settlement", "archive"}, MaxSelect: 1},
+			&core.JSONField{Id: "json2918445923", Name: "data"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		snapshots.AddIndex("idx_snapshots_name", true, "`name`", "")
+
+		return createCollections(app, phaseHooks, snapshots)
+	}, func(app core.App) error {
+		return deleteCollections(app, "phase_hooks", "snapshots")
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 状元结算报告
+func init() {
+	m.Register(func(app core.App) error {
+		settlements := core.NewBaseCollection("settlements", "pbc_129970546")
+		settlements.Fields.Add(
+			&core.NumberField{Id: "number3899649140", Name: "runNo", Required: true, Min: types.Pointer(1.0), OnlyInt: true},
+			&core.NumberField{Id: "number4240053356", Name: "winners", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text219324594", Name: "digest", Required: true},
+			&core.TextField{Id: "text2928148801", Name: "signature", Required: true},
+			&core.JSONField{Id: "json3291445124", Name: "report", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		settlements.AddIndex("idx_settlements_runNo", true, "`runNo`", "")
+
+		return createCollections(app, settlements)
+	}, func(app core.App) error {
+		return deleteCollections(app, "settlements")
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 全服状元记录与站内通知
+func init() {
+	m.Register(func(app core.App) error {
+		championEvents := core.NewBaseCollection("champion_events", "pbc_4232002909")
+		championEvents.ListRule = types.Pointer("")
+		championEvents.ViewRule = types.Pointer("")
+		championEvents.Fields.Add(
+			&core.RelationField{Id: "relation1765114810", Name: "historyId", Required: true, CollectionId: "pbc_2883201083", CascadeDelete: true, MaxSelect: 1},
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.RelationField{Id: "relation3673119869", Name: "previousHistoryId", CollectionId: "pbc_2883201083", MaxSelect: 1},
+			&core.RelationField{Id: "relation47865137", Name: "previousUserId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.NumberField{Id: "number2300229803", Name: "prizeLevel", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text3268382750", Name: "prizeName"},
+			&core.JSONField{Id: "json2007585796", Name: "dices"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		championEvents.AddIndex("idx_champion_events_created", false, "`created`", "")
+		championEvents.AddIndex("idx_champion_events_userId", false, "`userId`", "")
+
+		notifications := core.NewBaseCollection("notifications", "pbc_1610658003")
+		notifications.ListRule = types.Pointer("userId = @request.auth.id")
+		notifications.ViewRule = types.Pointer("userId = @request.auth.id")
+		notifications.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.TextField{Id: "text2363381545", Name: "type", Required: true},
+			&core.TextField{Id: "text724990059", Name: "title", Required: true},
+			&core.TextField{Id: "text4274335913", Name: "content"},
+			&core.JSONField{Id: "json2918445923", Name: "data"},
+			&core.BoolField{Id: "bool2555855207", Name: "read"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		notifications.AddIndex("idx_notifications_userId_read", false, "`userId`, `read`", "")
+
+		return createCollections(app, championEvents, notifications)
+	}, func(app core.App) error {
+		return deleteCollections(app, "champion_events", "notifications")
+	})
+}
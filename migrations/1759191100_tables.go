@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 多人博饼桌与每轮记录
+func init() {
+	m.Register(func(app core.App) error {
+		tables := core.NewBaseCollection("tables", "pbc_2219246113")
+		tables.ListRule = types.Pointer("@request.auth.id != '' && (status = 'waiting' || players.id ?= @request.auth.id)")
+		tables.ViewRule = types.Pointer("@request.auth.id != '' && (status = 'waiting' || players.id ?= @request.auth.id)")
+		tables.Fields.Add(
+			&core.TextField{Id: "text1579384326", Name: "name", Required: true, Max: 50},
+			&core.RelationField{Id: "relation3764321573", Name: "ownerId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.SelectField{Id: "select2063623452", Name: "status", Required: true, Values: []string{"waiting", "playing", "finished", "cancelled"}, MaxSelect: 1},
+			&core.NumberField{Id: "number3051925876", Name: "capacity", Required: true, Min: types.Pointer(2.0), Max: types.Pointer(10.0), OnlyInt: true},
+			&core.NumberField{Id: "number82984437", Name: "maxRounds", Required: true, Min: types.Pointer(1.0), Max: types.Pointer(50.0), OnlyInt: true},
+			&core.NumberField{Id: "number4256699627", Name: "turnTimeout", Required: true, Min: types.Pointer(10.0), Max: types.Pointer(600.0), OnlyInt: true},
+			&core.RelationField{Id: "relation642663334", Name: "players", CollectionId: "_pb_users_auth_", MaxSelect: 10},
+			&core.NumberField{Id: "number1029453414", Name: "seat", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number3320769076", Name: "round", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.JSONField{Id: "json2945558918", Name: "pool"},
+			&core.JSONField{Id: "json2850781065", Name: "remaining"},
+			&core.DateField{Id: "date2665628683", Name: "turnDeadline"},
+			&core.JSONField{Id: "json325763347", Name: "result"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		tables.AddIndex("idx_tables_status_turnDeadline", false, "`status`, `turnDeadline`", "")
+		tables.AddIndex("idx_tables_ownerId", false, "`ownerId`", "")
+
+		tableTurns := core.NewBaseCollection("table_turns", "pbc_4285199957")
+		tableTurns.ListRule = types.Pointer("tableId.players.id ?= @request.auth.id")
+		tableTurns.ViewRule = types.Pointer("tableId.players.id ?= @request.auth.id")
+		tableTurns.Fields.Add(
+			&core.RelationField{Id: "relation4053386217", Name: "tableId", Required: true, CollectionId: "pbc_2219246113", CascadeDelete: true, MaxSelect: 1},
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.NumberField{Id: "number1029453414", Name: "seat", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number3320769076", Name: "round", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.JSONField{Id: "json2007585796", Name: "dices"},
+			&core.NumberField{Id: "number2300229803", Name: "prizeLevel", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text3268382750", Name: "prizeName"},
+			&core.TextField{Id: "text1372097473", Name: "prize"},
+			&core.BoolField{Id: "bool1723475450", Name: "auto"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		tableTurns.AddIndex("idx_table_turns_tableId_round_seat", true, "`tableId`, `round`, `seat`", "")
+
+		return createCollections(app, tables, tableTurns)
+	}, func(app core.App) error {
+		return deleteCollections(app, "tables", "table_turns")
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 每条博饼记录最多一条积分订单，避免重复发放
+//
+// 已有重复订单时无法判断哪一条已经到账，不自动删除，提示人工核对后再启动
+func init() {
+	m.Register(func(app core.App) error {
+		duplicates := []struct {
+			HistoryId string `db:"historyId"`
+			Count     int    `db:"count"`
+		}{}
+		err := app.DB().Select("historyId", "count(*) as count").
+			From("points").
+			Where(dbx.NewExp("`historyId` != ''")).
+			GroupBy("historyId").
+			Having(dbx.NewExp("count(*) > 1")).
+			OrderBy("historyId").
+			All(&duplicates)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			samples := []string{}
+			for _, duplicate := range duplicates[:min(len(duplicates), 5)] {
+				samples = append(samples, fmt.Sprintf("%s(%d 条)", duplicate.HistoryId, duplicate.Count))
+			}
+			return fmt.Errorf("points 中有 %d 条博饼记录对应多条积分订单，例如 %s，请人工核对并删除重复订单后再启动", len(duplicates), strings.Join(samples, ", "))
+		}
+
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.AddIndex("idx_points_historyId", true, "`historyId`", "`historyId` != ''")
+		return app.Save(points)
+	}, func(app core.App) error {
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.RemoveIndex("idx_points_historyId")
+		return app.Save(points)
+	})
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// defaultReward 默认奖励及其对应的奖项，状元级别共用一个奖励
+type defaultReward struct {
+	name   string
+	point  int
+	amount int
+	awards []defaultAward
+}
+
+type defaultAward struct {
+	level int
+	name  string
+}
+
+var defaultRewards = []defaultReward{
+	{"谢谢参与", 0, 0, []defaultAward{{0, "无奖"}}},
+	{"一秀奖励", 8, 300, []defaultAward{{1, "一秀"}}},
+	{"二举奖励", 16, 120, []defaultAward{{2, "二举"}}},
+	{"四进奖励", 32, 40, []defaultAward{{3, "四进"}}},
+	{"三红奖励", 64, 20, []defaultAward{{4, "三红"}}},
+	{"对堂奖励", 128, 10, []defaultAward{{5, "对堂"}}},
+	{"状元奖励", 888, 3, []defaultAward{
+		{6, "状元四点红"},
+		{7, "状元五子登科"},
+		{8, "状元五红"},
+		{9, "状元黑六勃"},
+		{10, "状元遍地锦"},
+		{11, "状元插金花"},
+		{12, "状元六勃红"},
+	}},
+}
+
+// 默认奖励与奖项，已有奖励配置时跳过
+func init() {
+	m.Register(func(app core.App) error {
+		count, err := app.CountRecords("rewards")
+		if err != nil || count > 0 {
+			return err
+		}
+
+		rewards, err := app.FindCollectionByNameOrId("rewards")
+		if err != nil {
+			return err
+		}
+		awards, err := app.FindCollectionByNameOrId("awards")
+		if err != nil {
+			return err
+		}
+
+		for _, seed := range defaultRewards {
+			reward := core.NewRecord(rewards)
+			reward.Set("level", seed.awards[0].level)
+			reward.Set("name", seed.name)
+			reward.Set("point", seed.point)
+			reward.Set("amount", seed.amount)
+			if err = app.Save(reward); err != nil {
+				return err
+			}
+
+			for _, item := range seed.awards {
+				award := core.NewRecord(awards)
+				award.Set("level", item.level)
+				award.Set("name", item.name)
+				award.Set("rewardId", reward.Id)
+				if err = app.Save(award); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		names := make([]any, 0, len(defaultRewards))
+		for _, seed := range defaultRewards {
+			names = append(names, seed.name)
+		}
+		rewards := []*core.Record{}
+		if err := app.RecordQuery("rewards").Where(dbx.In("name", names...)).All(&rewards); err != nil {
+			return err
+		}
+		for _, reward := range rewards {
+			awards, err := app.FindAllRecords("awards", dbx.HashExp{"rewardId": reward.Id})
+			if err != nil {
+				return err
+			}
+			for _, award := range awards {
+				if err = app.Delete(award); err != nil {
+					return err
+				}
+			}
+			if err = app.Delete(reward); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
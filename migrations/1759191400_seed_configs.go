@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 默认的摸鱼派配置，密钥需要在后台填写，已有配置时跳过
+func init() {
+	m.Register(func(app core.App) error {
+		count, err := app.CountRecords("configs", dbx.HashExp{"key": "fishpi"})
+		if err != nil || count > 0 {
+			return err
+		}
+
+		configs, err := app.FindCollectionByNameOrId("configs")
+		if err != nil {
+			return err
+		}
+		config := core.NewRecord(configs)
+		config.Set("key", "fishpi")
+		config.Set("value", map[string]any{
+			"base_url":         "https://fishpi.cn",
+			"api_key":          "",
+			"gold_finger_key":  "",
+			"metal_finger_key": "",
+		})
+		return app.Save(config)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 积分账本：积分订单的幂等键与来源、复式记账分录、来源预算与对账报告
+func init() {
+	m.Register(func(app core.App) error {
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.Fields.Add(
+			&core.TextField{Id: "text2324736937", Name: "key"},
+			&core.TextField{Id: "text1602912115", Name: "source"},
+		)
+		if err = app.Save(points); err != nil {
+			return err
+		}
+
+		ledgerEntries := core.NewBaseCollection("ledger_entries", "pbc_3510286744")
+		ledgerEntries.Fields.Add(
+			&core.TextField{Id: "text3616895705", Name: "transaction", Required: true},
+			&core.RelationField{Id: "relation2391532211", Name: "pointsId", CollectionId: "pbc_279573351", MaxSelect: 1},
+			&core.TextField{Id: "text1297495474", Name: "account", Required: true},
+			&core.NumberField{Id: "number2392944706", Name: "amount", OnlyInt: true},
+			&core.TextField{Id: "text2873790506", Name: "memo"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		ledgerEntries.AddIndex("idx_ledger_entries_transaction_account", true, "`transaction`, `account`", "")
+		ledgerEntries.AddIndex("idx_ledger_entries_account", false, "`account`", "")
+
+		budgets := core.NewBaseCollection("budgets", "pbc_3383022248")
+		budgets.Fields.Add(
+			&core.TextField{Id: "text1602912115", Name: "source", Required: true},
+			&core.NumberField{Id: "number1146066909", Name: "cap", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text2873790506", Name: "memo"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		budgets.AddIndex("idx_budgets_source", true, "`source`", "")
+
+		reconciliations := core.NewBaseCollection("reconciliations", "pbc_2716411237")
+		reconciliations.Fields.Add(
+			&core.NumberField{Id: "number1806407402", Name: "issues", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.JSONField{Id: "json3291445124", Name: "report", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		if err = createCollections(app, ledgerEntries, budgets, reconciliations); err != nil {
+			return err
+		}
+
+		// 已有订单的交易单号即订单 id，补写幂等键、来源与已发放订单的分录
+		records := []*core.Record{}
+		if err = app.RecordQuery("points").Where(dbx.HashExp{"key": ""}).All(&records); err != nil {
+			return err
+		}
+		for _, record := range records {
+			source := legacyPointsSource(record.GetString("memo"))
+			record.Set("key", record.Id)
+			record.Set("source", source)
+			if err = app.Save(record); err != nil {
+				return err
+			}
+			if record.GetString("status") != "success" {
+				continue
+			}
+			for account, amount := range map[string]int{
+				"budget:" + source:                   -record.GetInt("point"),
+				"user:" + record.GetString("userId"): record.GetInt("point"),
+			} {
+				entry := core.NewRecord(ledgerEntries)
+				entry.Set("transaction", record.Id)
+				entry.Set("pointsId", record.Id)
+				entry.Set("account", account)
+				entry.Set("amount", amount)
+				entry.Set("memo", record.GetString("memo"))
+				if err = app.Save(entry); err != nil {
+					return err
+				}
+			}
+		}
+
+		points.AddIndex("idx_points_key", true, "`key`", "`key` != ''")
+		points.AddIndex("idx_points_source_status", false, "`source`, `status`", "")
+		return app.Save(points)
+	}, func(app core.App) error {
+		if err := deleteCollections(app, "ledger_entries", "budgets", "reconciliations"); err != nil {
+			return err
+		}
+
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.RemoveIndex("idx_points_key")
+		points.RemoveIndex("idx_points_source_status")
+		points.Fields.RemoveByName("key")
+		points.Fields.RemoveByName("source")
+		return app.Save(points)
+	})
+}
+
+// legacyPointsSource 根据备注判断旧订单的来源
+func legacyPointsSource(memo string) string {
+	switch {
+	case strings.HasPrefix(memo, "【补发】"):
+		return "reissue"
+	case strings.Contains(memo, "文章评分奖励"):
+		return "article_reward"
+	case strings.Contains(memo, "状元结算"):
+		return "settlement"
+	default:
+		return "gambling"
+	}
+}
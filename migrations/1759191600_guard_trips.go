@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 支出熔断记录，未重置的记录表示熔断中
+func init() {
+	m.Register(func(app core.App) error {
+		guardTrips := core.NewBaseCollection("guard_trips", "pbc_1226123922")
+		guardTrips.Fields.Add(
+			&core.TextField{Id: "text1001949196", Name: "reason", Required: true},
+			&core.RelationField{Id: "relation1694193028", Name: "pointsId", CollectionId: "pbc_279573351", MaxSelect: 1},
+			&core.RelationField{Id: "relation1689669068", Name: "userId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.NumberField{Id: "number3081106212", Name: "point", OnlyInt: true},
+			&core.NumberField{Id: "number2140596320", Name: "limit", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number578656804", Name: "actual", OnlyInt: true},
+			&core.TextField{Id: "text3065852031", Name: "message"},
+			&core.RelationField{Id: "relation3024501032", Name: "resetBy", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.DateField{Id: "date3789166166", Name: "resetAt"},
+			&core.TextField{Id: "text58762106", Name: "resetMemo"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		guardTrips.AddIndex("idx_guard_trips_resetAt", false, "`resetAt`", "")
+
+		return createCollections(app, guardTrips)
+	}, func(app core.App) error {
+		return deleteCollections(app, "guard_trips")
+	})
+}
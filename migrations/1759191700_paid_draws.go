@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 付费博饼：博饼记录关联扣费订单，为空表示免费次数
+func init() {
+	m.Register(func(app core.App) error {
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.Fields.Add(&core.RelationField{Id: "relation4191169031", Name: "feeId", CollectionId: "pbc_279573351", MaxSelect: 1})
+		histories.AddIndex("idx_histories_feeId", true, "`feeId`", "`feeId` != ''")
+		return app.Save(histories)
+	}, func(app core.App) error {
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.RemoveIndex("idx_histories_feeId")
+		histories.Fields.RemoveByName("feeId")
+		return app.Save(histories)
+	})
+}
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 奖品库存：奖品的预留计数、下架与低库存提醒阈值，每条获奖记录对应一条预留
+func init() {
+	m.Register(func(app core.App) error {
+		rewards, err := app.FindCollectionByNameOrId("rewards")
+		if err != nil {
+			return err
+		}
+		rewards.Fields.Add(
+			&core.NumberField{Id: "number1302522818", Name: "reserved", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.BoolField{Id: "bool4060018830", Name: "retired"},
+			&core.NumberField{Id: "number3312117137", Name: "lowStock", Min: types.Pointer(0.0), OnlyInt: true},
+		)
+		if err = app.Save(rewards); err != nil {
+			return err
+		}
+
+		reservations := core.NewBaseCollection("reservations", "pbc_5087801")
+		reservations.Fields.Add(
+			&core.RelationField{Id: "relation85988260", Name: "rewardId", Required: true, CollectionId: "pbc_2020696541", MaxSelect: 1},
+			&core.RelationField{Id: "relation1765114810", Name: "historyId", Required: true, CollectionId: "pbc_2883201083", CascadeDelete: true, MaxSelect: 1},
+			&core.SelectField{Id: "select2063623452", Name: "status", Required: true, MaxSelect: 1, Values: []string{"held", "released"}},
+			&core.TextField{Id: "text1001949196", Name: "reason"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		reservations.AddIndex("idx_reservations_historyId_held", true, "`historyId`", "`status` = 'held'")
+		reservations.AddIndex("idx_reservations_rewardId_status", false, "`rewardId`, `status`", "")
+		if err = createCollections(app, reservations); err != nil {
+			return err
+		}
+
+		// 已获奖的记录补写预留，预留计数与获奖记录数一致
+		histories := []*core.Record{}
+		if err = app.RecordQuery("histories").
+			Where(dbx.HashExp{"gotReward": true}).
+			AndWhere(dbx.Not(dbx.HashExp{"rewardId": ""})).
+			All(&histories); err != nil {
+			return err
+		}
+		reserved := map[string]int{}
+		for _, history := range histories {
+			reservation := core.NewRecord(reservations)
+			reservation.Set("rewardId", history.GetString("rewardId"))
+			reservation.Set("historyId", history.Id)
+			reservation.Set("status", "held")
+			if err = app.Save(reservation); err != nil {
+				return err
+			}
+			reserved[history.GetString("rewardId")]++
+		}
+		for rewardId, count := range reserved {
+			reward, err := app.FindRecordById("rewards", rewardId)
+			if err != nil {
+				return err
+			}
+			reward.Set("reserved", count)
+			if err = app.Save(reward); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		if err := deleteCollections(app, "reservations"); err != nil {
+			return err
+		}
+
+		rewards, err := app.FindCollectionByNameOrId("rewards")
+		if err != nil {
+			return err
+		}
+		rewards.Fields.RemoveByName("reserved")
+		rewards.Fields.RemoveByName("retired")
+		rewards.Fields.RemoveByName("lowStock")
+		return app.Save(rewards)
+	})
+}
@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 同一等级多个奖项：按权重随机选择，奖品发完时降级到备选奖项，奖项可限制领取条件
+// 博饼记录保存种子与按权重选中的奖项，用于复现选择过程
+func init() {
+	m.Register(func(app core.App) error {
+		awards, err := app.FindCollectionByNameOrId("awards")
+		if err != nil {
+			return err
+		}
+		awards.RemoveIndex("idx_JWQQvXjYNI")
+		awards.AddIndex("idx_awards_level", false, "`level`", "")
+		awards.Fields.Add(
+			&core.NumberField{Id: "number130897217", Name: "weight", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.RelationField{Id: "relation1478674254", Name: "fallbackId", CollectionId: "pbc_318348976", MaxSelect: 1},
+			&core.SelectField{Id: "select3184953411", Name: "condition", Required: true, MaxSelect: 1, Values: []string{"none", "first_win"}},
+		)
+		if err = app.Save(awards); err != nil {
+			return err
+		}
+		if _, err = app.DB().Update("awards", dbx.Params{"weight": 1, "condition": "none"}, nil).Execute(); err != nil {
+			return err
+		}
+
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.Fields.Add(
+			&core.TextField{Id: "text1149756166", Name: "seed"},
+			&core.RelationField{Id: "relation395988516", Name: "pickedAwardId", CollectionId: "pbc_318348976", MaxSelect: 1},
+		)
+		return app.Save(histories)
+	}, func(app core.App) error {
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.Fields.RemoveByName("seed")
+		histories.Fields.RemoveByName("pickedAwardId")
+		if err = app.Save(histories); err != nil {
+			return err
+		}
+
+		awards, err := app.FindCollectionByNameOrId("awards")
+		if err != nil {
+			return err
+		}
+		awards.RemoveIndex("idx_awards_level")
+		awards.AddIndex("idx_JWQQvXjYNI", true, "`level`", "")
+		awards.Fields.RemoveByName("weight")
+		awards.Fields.RemoveByName("fallbackId")
+		awards.Fields.RemoveByName("condition")
+		return app.Save(awards)
+	})
+}
@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 奖品类型与兑奖：积分之外的兑换码、实物、勋章奖品，每条获奖记录对应一条兑奖记录，兑换码从导入的码池分配
+func init() {
+	m.Register(func(app core.App) error {
+		rewards, err := app.FindCollectionByNameOrId("rewards")
+		if err != nil {
+			return err
+		}
+		rewards.Fields.Add(
+			&core.SelectField{Id: "select1002749145", Name: "kind", Required: true, MaxSelect: 1, Values: []string{"points", "code", "physical", "medal"}},
+			&core.JSONField{Id: "json3695466425", Name: "medal"},
+		)
+		if err = app.Save(rewards); err != nil {
+			return err
+		}
+		if _, err = app.DB().Update("rewards", dbx.Params{"kind": "points"}, nil).Execute(); err != nil {
+			return err
+		}
+
+		rewardCodes := core.NewBaseCollection("reward_codes", "pbc_2188483089")
+		rewardCodes.Fields.Add(
+			&core.RelationField{Id: "relation85988260", Name: "rewardId", Required: true, CollectionId: "pbc_2020696541", CascadeDelete: true, MaxSelect: 1},
+			&core.TextField{Id: "text1997877400", Name: "code", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		rewardCodes.AddIndex("idx_reward_codes_rewardId_code", true, "`rewardId`, `code`", "")
+
+		fulfillments := core.NewBaseCollection("fulfillments", "pbc_2258989397")
+		fulfillments.Fields.Add(
+			&core.RelationField{Id: "relation1765114810", Name: "historyId", Required: true, CollectionId: "pbc_2883201083", CascadeDelete: true, MaxSelect: 1},
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.RelationField{Id: "relation85988260", Name: "rewardId", Required: true, CollectionId: "pbc_2020696541", MaxSelect: 1},
+			&core.SelectField{Id: "select1002749145", Name: "kind", Required: true, MaxSelect: 1, Values: []string{"code", "physical", "medal"}},
+			&core.SelectField{Id: "select2063623452", Name: "status", Required: true, MaxSelect: 1, Values: []string{"allocated", "claimed", "shipped", "delivered", "failed"}},
+			&core.RelationField{Id: "relation3053192281", Name: "codeId", CollectionId: "pbc_2188483089", MaxSelect: 1},
+			&core.JSONField{Id: "json223244161", Name: "address"},
+			&core.TextField{Id: "text3085302248", Name: "trackingNo"},
+			&core.TextField{Id: "text3065852031", Name: "message"},
+			&core.DateField{Id: "date2585205160", Name: "claimedAt"},
+			&core.DateField{Id: "date1037769508", Name: "shippedAt"},
+			&core.DateField{Id: "date1611150777", Name: "deliveredAt"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		fulfillments.AddIndex("idx_fulfillments_historyId", true, "`historyId`", "")
+		fulfillments.AddIndex("idx_fulfillments_userId", false, "`userId`", "")
+		fulfillments.AddIndex("idx_fulfillments_status", false, "`status`", "")
+		if err = createCollections(app, rewardCodes, fulfillments); err != nil {
+			return err
+		}
+
+		// 兑换码与兑奖记录互相关联，兑奖记录集合创建后再添加
+		if rewardCodes, err = app.FindCollectionByNameOrId("reward_codes"); err != nil {
+			return err
+		}
+		rewardCodes.Fields.Add(&core.RelationField{Id: "relation2800981183", Name: "fulfillmentId", CollectionId: "pbc_2258989397", MaxSelect: 1})
+		rewardCodes.AddIndex("idx_reward_codes_fulfillmentId", false, "`rewardId`, `fulfillmentId`", "")
+		return app.Save(rewardCodes)
+	}, func(app core.App) error {
+		if rewardCodes, err := app.FindCollectionByNameOrId("reward_codes"); err == nil {
+			rewardCodes.RemoveIndex("idx_reward_codes_fulfillmentId")
+			rewardCodes.Fields.RemoveByName("fulfillmentId")
+			if err = app.Save(rewardCodes); err != nil {
+				return err
+			}
+		}
+		if err := deleteCollections(app, "reward_codes", "fulfillments"); err != nil {
+			return err
+		}
+
+		rewards, err := app.FindCollectionByNameOrId("rewards")
+		if err != nil {
+			return err
+		}
+		rewards.Fields.RemoveByName("kind")
+		rewards.Fields.RemoveByName("medal")
+		return app.Save(rewards)
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 用户申请重新发放失败的积分订单：retryAt 为排队后预计重新发放的时间，retries 为用户申请的次数
+func init() {
+	m.Register(func(app core.App) error {
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.Fields.Add(
+			&core.DateField{Id: "date2532062771", Name: "retryAt"},
+			&core.NumberField{Id: "number3846627654", Name: "retries", Min: types.Pointer(0.0), OnlyInt: true},
+		)
+		points.AddIndex("idx_points_status_retryAt", false, "`status`, `retryAt`", "")
+		return app.Save(points)
+	}, func(app core.App) error {
+		points, err := app.FindCollectionByNameOrId("points")
+		if err != nil {
+			return err
+		}
+		points.RemoveIndex("idx_points_status_retryAt")
+		points.Fields.RemoveByName("retryAt")
+		points.Fields.RemoveByName("retries")
+		return app.Save(points)
+	})
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 数据导出任务：导出的文件保存在 PocketBase 存储中，只能通过后台接口下载
+func init() {
+	m.Register(func(app core.App) error {
+		exports := core.NewBaseCollection("exports", "pbc_3962442816")
+		exports.Fields.Add(
+			&core.RelationField{Id: "relation1842063794", Name: "actorId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.SelectField{Id: "select3080733136", Name: "dataset", Required: true, MaxSelect: 1, Values: []string{"histories", "votes", "points", "articles", "settlement"}},
+			&core.SelectField{Id: "select3736761055", Name: "format", Required: true, MaxSelect: 1, Values: []string{"csv", "xlsx", "jsonl"}},
+			&core.JSONField{Id: "json2021091213", Name: "filters"},
+			&core.JSONField{Id: "json2899230903", Name: "columns"},
+			&core.SelectField{Id: "select2063623452", Name: "status", Required: true, MaxSelect: 1, Values: []string{"pending", "running", "success", "failed"}},
+			&core.NumberField{Id: "number176944289", Name: "rows", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.FileField{Id: "file2359244304", Name: "file", MaxSelect: 1, MaxSize: 1 << 30, Protected: true},
+			&core.TextField{Id: "text1574812785", Name: "error"},
+			&core.DateField{Id: "date3441720398", Name: "finishedAt"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		exports.AddIndex("idx_exports_created", false, "`created`", "")
+
+		return createCollections(app, exports)
+	}, func(app core.App) error {
+		return deleteCollections(app, "exports")
+	})
+}
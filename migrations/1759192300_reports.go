@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 活动报告：每次发布到摸鱼派保存一个版本，再次发布时更新同一篇帖子
+func init() {
+	m.Register(func(app core.App) error {
+		reports := core.NewBaseCollection("reports", "pbc_4045383493")
+		reports.Fields.Add(
+			&core.RelationField{Id: "relation1842063794", Name: "actorId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.TextField{Id: "text724990059", Name: "title", Required: true, Max: 255},
+			&core.TextField{Id: "text4274335913", Name: "content", Required: true, Max: 1 << 20},
+			&core.TextField{Id: "text1874629670", Name: "tags"},
+			&core.TextField{Id: "text4272070894", Name: "articleId", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		reports.AddIndex("idx_reports_created", false, "`created`", "")
+
+		return createCollections(app, reports)
+	}, func(app core.App) error {
+		return deleteCollections(app, "reports")
+	})
+}
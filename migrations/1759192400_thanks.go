@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 感谢明细：逐条保存文章收到的感谢与打赏，博饼次数只统计活动期间非作者本人的不同用户的感谢
+func init() {
+	m.Register(func(app core.App) error {
+		thanks := core.NewBaseCollection("thanks", "pbc_115684958")
+		thanks.Fields.Add(
+			&core.RelationField{Id: "relation4272070894", Name: "articleId", Required: true, CollectionId: "pbc_4287850865", MaxSelect: 1, CascadeDelete: true},
+			&core.TextField{Id: "text3618505730", Name: "oId", Required: true},
+			&core.SelectField{Id: "select1002749145", Name: "kind", Required: true, MaxSelect: 1, Values: []string{"thank", "reward"}},
+			&core.TextField{Id: "text4050411225", Name: "fromOId", Required: true},
+			&core.TextField{Id: "text1325709056", Name: "fromName"},
+			&core.RelationField{Id: "relation3495199097", Name: "fromUserId", CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.NumberField{Id: "number3081106212", Name: "point", OnlyInt: true},
+			&core.DateField{Id: "date1153039858", Name: "thankedAt", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		thanks.AddIndex("idx_thanks_oId", true, "`oId`", "")
+		thanks.AddIndex("idx_thanks_article", false, "`articleId`, `kind`, `thankedAt`", "")
+
+		return createCollections(app, thanks)
+	}, func(app core.App) error {
+		return deleteCollections(app, "thanks")
+	})
+}
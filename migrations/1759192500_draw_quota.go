@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 博饼次数来源：每日签到、管理员补发，博饼记录标记消耗的每日重置的次数来源
+func init() {
+	m.Register(func(app core.App) error {
+		checkins := core.NewBaseCollection("checkins", "pbc_2632388549")
+		checkins.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.TextField{Id: "text3852478864", Name: "day", Required: true, Pattern: `^\d{4}-\d{2}-\d{2}$`},
+			&core.NumberField{Id: "number500690572", Name: "times", OnlyInt: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		checkins.AddIndex("idx_checkins_user_day", true, "`userId`, `day`", "")
+
+		grants := core.NewBaseCollection("draw_grants", "pbc_2487874486")
+		grants.Fields.Add(
+			&core.RelationField{Id: "relation1689669068", Name: "userId", Required: true, CollectionId: "_pb_users_auth_", CascadeDelete: true, MaxSelect: 1},
+			&core.RelationField{Id: "relation1842063794", Name: "actorId", Required: true, CollectionId: "_pb_users_auth_", MaxSelect: 1},
+			&core.NumberField{Id: "number500690572", Name: "times", Required: true, OnlyInt: true},
+			&core.TextField{Id: "text1001949196", Name: "reason", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		grants.AddIndex("idx_draw_grants_userId", false, "`userId`", "")
+
+		if err := createCollections(app, checkins, grants); err != nil {
+			return err
+		}
+
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.Fields.Add(&core.TextField{Id: "text732839851", Name: "quotaSource"})
+		histories.AddIndex("idx_histories_quotaSource", false, "`userId`, `quotaSource`, `created`", "`quotaSource` != ''")
+		return app.Save(histories)
+	}, func(app core.App) error {
+		histories, err := app.FindCollectionByNameOrId("histories")
+		if err != nil {
+			return err
+		}
+		histories.RemoveIndex("idx_histories_quotaSource")
+		histories.Fields.RemoveByName("quotaSource")
+		if err = app.Save(histories); err != nil {
+			return err
+		}
+		return deleteCollections(app, "checkins", "draw_grants")
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// OpenID 已使用的 response_nonce，重启后仍能拒绝重放的断言
+func init() {
+	m.Register(func(app core.App) error {
+		nonces := core.NewBaseCollection("openid_nonces", "pbc_4103996127")
+		nonces.Fields.Add(
+			&core.TextField{Id: "text3292663675", Name: "endpoint", Required: true},
+			&core.TextField{Id: "text2988741373", Name: "nonce", Required: true},
+			&core.DateField{Id: "date917377331", Name: "issuedAt", Required: true},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		nonces.AddIndex("idx_openid_nonces_nonce", true, "`endpoint`, `nonce`", "")
+		nonces.AddIndex("idx_openid_nonces_issuedAt", false, "`issuedAt`", "")
+
+		return createCollections(app, nonces)
+	}, func(app core.App) error {
+		return deleteCollections(app, "openid_nonces")
+	})
+}
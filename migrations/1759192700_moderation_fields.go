@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 补齐角色与审核字段：这些字段已并入初始化迁移，但导入过旧版结构的部署中集合已存在，初始化迁移不会再添加
+func init() {
+	m.Register(func(app core.App) error {
+		err := addMissingFields(app, "users",
+			&core.SelectField{Id: "select1466534506", Name: "role", Values: []string{"player", "moderator", "operator"}, MaxSelect: 1},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = addMissingFields(app, "articles",
+			&core.BoolField{Id: "bool391258049", Name: "flagged"},
+			&core.TextField{Id: "text1520603174", Name: "flagReason", Hidden: true},
+			&core.BoolField{Id: "bool2831528131", Name: "disqualified"},
+		)
+		if err != nil {
+			return err
+		}
+
+		return addMissingFields(app, "votes",
+			&core.BoolField{Id: "bool391258049", Name: "flagged"},
+			&core.TextField{Id: "text1520603174", Name: "flagReason", Hidden: true},
+		)
+	}, func(app core.App) error {
+		// 字段属于初始化迁移的结构，回滚时保留
+		return nil
+	})
+}
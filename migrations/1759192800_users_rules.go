@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 用户只能通过摸鱼派登录创建；name 是积分发放的目标用户名，oId 与 role 由服务端维护，均不允许用户自行修改
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		users.CreateRule = nil
+		users.UpdateRule = types.Pointer("id = @request.auth.id && @request.body.name:isset = false && @request.body.oId:isset = false && @request.body.role:isset = false")
+		return app.Save(users)
+	}, func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		users.CreateRule = types.Pointer("@request.body.role:isset = false")
+		users.UpdateRule = types.Pointer("id = @request.auth.id && @request.body.role:isset = false")
+		return app.Save(users)
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 阶段任务按阶段开始时间区分，重新设置日程再次进入同一阶段时任务会重新执行
+//
+// 已有的执行记录按当前日程补全开始时间，升级后不会重复执行
+func init() {
+	m.Register(func(app core.App) error {
+		phaseHooks, err := app.FindCollectionByNameOrId("phase_hooks")
+		if err != nil {
+			return err
+		}
+		if phaseHooks.Fields.GetByName("startAt") == nil {
+			phaseHooks.Fields.Add(&core.DateField{Id: "date1489594136", Name: "startAt"})
+		}
+		phaseHooks.RemoveIndex("idx_phase_hooks_phase_hook")
+		phaseHooks.AddIndex("idx_phase_hooks_phase_hook_startAt", true, "`phase`, `hook`, `startAt`", "")
+		if err = app.Save(phaseHooks); err != nil {
+			return err
+		}
+
+		activity := new(core.Record)
+		err = app.RecordQuery("configs").Where(dbx.HashExp{"key": "activity"}).One(activity)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		schedule := struct {
+			Phases []struct {
+				Name    string    `json:"name"`
+				StartAt time.Time `json:"startAt"`
+			} `json:"phases"`
+		}{}
+		if err = json.Unmarshal([]byte(activity.GetString("value")), &schedule); err != nil {
+			return err
+		}
+		for _, phase := range schedule.Phases {
+			if phase.StartAt.IsZero() {
+				continue
+			}
+			startAt, err := types.ParseDateTime(phase.StartAt)
+			if err != nil {
+				return err
+			}
+			if _, err = app.DB().Update("phase_hooks", dbx.Params{"startAt": startAt.String()},
+				dbx.HashExp{"phase": phase.Name, "startAt": ""}).Execute(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		phaseHooks, err := app.FindCollectionByNameOrId("phase_hooks")
+		if err != nil {
+			return err
+		}
+		phaseHooks.RemoveIndex("idx_phase_hooks_phase_hook_startAt")
+		phaseHooks.Fields.RemoveByName("startAt")
+		phaseHooks.AddIndex("idx_phase_hooks_phase_hook", true, "`phase`, `hook`", "")
+		return app.Save(phaseHooks)
+	})
+}
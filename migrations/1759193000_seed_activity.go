@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 没有活动日程时写入改为阶段日程之前写死的活动时间：投票与博饼开放到 10 月 20 日
+//
+// 只包含投票阶段，没有结算与归档阶段，升级后不会补跑阶段任务，已有日程时跳过
+func init() {
+	m.Register(func(app core.App) error {
+		count, err := app.CountRecords("configs", dbx.HashExp{"key": "activity"})
+		if err != nil || count > 0 {
+			return err
+		}
+
+		configs, err := app.FindCollectionByNameOrId("configs")
+		if err != nil {
+			return err
+		}
+		config := core.NewRecord(configs)
+		config.Set("key", "activity")
+		config.Set("value", map[string]any{
+			"phases": []map[string]any{
+				{"name": "voting", "startAt": time.Time{}, "endAt": time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)},
+			},
+		})
+		return app.Save(config)
+	}, func(app core.App) error {
+		return nil
+	})
+}
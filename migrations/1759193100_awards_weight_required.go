@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 权重为 0 的奖项不参与选择，管理后台新建奖项时权重默认为 0，导致奖项永远无法博中
+// 已有权重为 0 的奖项改为 1，之后保存时权重至少为 1
+func init() {
+	m.Register(func(app core.App) error {
+		if _, err := app.DB().Update("awards", dbx.Params{"weight": 1}, dbx.NewExp("[[weight]] <= 0")).Execute(); err != nil {
+			return err
+		}
+
+		awards, err := app.FindCollectionByNameOrId("awards")
+		if err != nil {
+			return err
+		}
+		weight, ok := awards.Fields.GetByName("weight").(*core.NumberField)
+		if !ok {
+			return nil
+		}
+		weight.Required = true
+		weight.Min = types.Pointer(1.0)
+		return app.Save(awards)
+	}, func(app core.App) error {
+		awards, err := app.FindCollectionByNameOrId("awards")
+		if err != nil {
+			return err
+		}
+		weight, ok := awards.Fields.GetByName("weight").(*core.NumberField)
+		if !ok {
+			return nil
+		}
+		weight.Required = false
+		weight.Min = types.Pointer(0.0)
+		return app.Save(awards)
+	})
+}
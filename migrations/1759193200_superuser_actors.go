@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// 数据导出只允许超级管理员操作，超级管理员不属于 users 集合
+// 审计记录与导出任务增加 superuserId，操作者为超级管理员时 actorId 为空
+func init() {
+	m.Register(func(app core.App) error {
+		superusers, err := app.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"audits", "exports"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			if actor, ok := collection.Fields.GetByName("actorId").(*core.RelationField); ok {
+				actor.Required = false
+			}
+			if role, ok := collection.Fields.GetByName("actorRole").(*core.SelectField); ok {
+				role.Values = []string{"player", "moderator", "operator", "superuser"}
+			}
+			if collection.Fields.GetByName("superuserId") == nil {
+				collection.Fields.Add(&core.RelationField{Id: "relation3658255269", Name: "superuserId", CollectionId: superusers.Id, MaxSelect: 1})
+			}
+			if err = app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		for _, name := range []string{"audits", "exports"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			if actor, ok := collection.Fields.GetByName("actorId").(*core.RelationField); ok {
+				actor.Required = true
+			}
+			if role, ok := collection.Fields.GetByName("actorRole").(*core.SelectField); ok {
+				role.Values = []string{"player", "moderator", "operator"}
+			}
+			collection.Fields.RemoveByName("superuserId")
+			if err = app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
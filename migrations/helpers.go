@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// createCollections 按顺序创建集合，已手动导入过结构的部署中同 id 或同名的集合会被跳过
+func createCollections(app core.App, collections ...*core.Collection) error {
+	for _, collection := range collections {
+		if _, err := app.FindCollectionByNameOrId(collection.Id); err == nil {
+			continue
+		}
+		if _, err := app.FindCollectionByNameOrId(collection.Name); err == nil {
+			continue
+		}
+		if err := app.Save(collection); err != nil {
+			return fmt.Errorf("创建集合 %s 失败: %w", collection.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteCollections 按相反顺序删除集合，不存在的集合跳过
+func deleteCollections(app core.App, names ...string) error {
+	for i := len(names) - 1; i >= 0; i-- {
+		collection, err := app.FindCollectionByNameOrId(names[i])
+		if err != nil {
+			continue
+		}
+		if err = app.Delete(collection); err != nil {
+			return fmt.Errorf("删除集合 %s 失败: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// addMissingFields 为已存在的集合逐个补充缺少的字段，已有同名字段时跳过
+//
+// 早期手动导入 pb_schema.json 的部署中集合已存在，createCollections 会整体跳过，需要按字段补齐
+func addMissingFields(app core.App, name string, fields ...core.Field) error {
+	collection, err := app.FindCollectionByNameOrId(name)
+	if err != nil {
+		return err
+	}
+
+	added := false
+	for _, field := range fields {
+		if collection.Fields.GetByName(field.GetName()) != nil {
+			continue
+		}
+		collection.Fields.Add(field)
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	if err = app.Save(collection); err != nil {
+		return fmt.Errorf("补充集合 %s 的字段失败: %w", name, err)
+	}
+	return nil
+}
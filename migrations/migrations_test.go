@@ -0,0 +1,227 @@
+package migrations_test
+
+import (
+	"bless-activity/internal/testapp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// runMigration 重新执行指定文件的迁移
+func runMigration(t *testing.T, app core.App, file string) error {
+	t.Helper()
+	for _, migration := range core.AppMigrations.Items() {
+		if migration.File == file {
+			return migration.Up(app)
+		}
+	}
+	t.Fatalf("未找到迁移 %s", file)
+	return nil
+}
+
+// removeFields 模拟导入旧版结构的部署，集合存在但缺少字段
+func removeFields(t *testing.T, app core.App, name string, fields ...string) {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range fields {
+		collection.Fields.RemoveByName(field)
+	}
+	if err = app.Save(collection); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModerationFields(t *testing.T) {
+	app := testapp.New(t)
+	removeFields(t, app, "users", "role")
+	removeFields(t, app, "articles", "flagged", "disqualified")
+	removeFields(t, app, "votes", "flagReason")
+
+	// 执行两次，已有字段时跳过
+	for range 2 {
+		if err := runMigration(t, app, "1759192700_moderation_fields.go"); err != nil {
+			t.Fatalf("补齐字段失败: %v", err)
+		}
+	}
+
+	expected := map[string][]string{
+		"users":    {"role"},
+		"articles": {"flagged", "flagReason", "disqualified"},
+		"votes":    {"flagged", "flagReason"},
+	}
+	for name, fields := range expected {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range fields {
+			if collection.Fields.GetByName(field) == nil {
+				t.Errorf("%s 缺少字段 %s", name, field)
+			}
+		}
+	}
+	// 补齐的字段可以直接查询
+	for _, query := range []string{
+		"SELECT count(*) FROM users WHERE role = 'operator'",
+		"SELECT count(*) FROM articles WHERE flagged = true AND disqualified = false",
+		"SELECT count(*) FROM votes WHERE flagReason != ''",
+	} {
+		count := 0
+		if err := app.DB().NewQuery(query).Row(&count); err != nil {
+			t.Errorf("%s 失败: %v", query, err)
+		}
+	}
+}
+
+func TestUsersRules(t *testing.T) {
+	app := testapp.New(t)
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users.CreateRule != nil {
+		t.Errorf("不应允许通过接口创建用户, createRule = %q", *users.CreateRule)
+	}
+	if users.UpdateRule == nil {
+		t.Fatal("updateRule 不应为空")
+	}
+	for _, field := range []string{"name", "oId", "role"} {
+		if !strings.Contains(*users.UpdateRule, "@request.body."+field+":isset = false") {
+			t.Errorf("updateRule 未锁定 %s: %s", field, *users.UpdateRule)
+		}
+	}
+}
+
+func TestPointsHistoryUnique(t *testing.T) {
+	app := testapp.New(t)
+	points, err := app.FindCollectionByNameOrId("points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	points.RemoveIndex("idx_points_historyId")
+	if err = app.Save(points); err != nil {
+		t.Fatal(err)
+	}
+
+	// 无关联博饼记录的订单不参与唯一检查
+	for _, historyId := range []string{"h1", "h1", "", ""} {
+		_, err = app.DB().Insert("points", map[string]any{"id": core.GenerateDefaultRandomId(), "historyId": historyId}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = runMigration(t, app, "1759191200_points_history_unique.go")
+	if err == nil || !strings.Contains(err.Error(), "h1(2 条)") {
+		t.Fatalf("存在重复订单时应提示人工核对, 得到 %v", err)
+	}
+
+	if _, err = app.DB().Delete("points", nil).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err = runMigration(t, app, "1759191200_points_history_unique.go"); err != nil {
+		t.Fatalf("无重复订单时创建索引失败: %v", err)
+	}
+}
+
+func TestPhaseHooksStartAt(t *testing.T) {
+	app := testapp.New(t)
+
+	// 升级前的执行记录没有阶段开始时间
+	configs, _ := app.FindCollectionByNameOrId("configs")
+	activity, err := app.FindFirstRecordByData(configs, "key", "activity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity.Set("value", map[string]any{"phases": []map[string]any{
+		{"name": "voting", "startAt": time.Time{}, "endAt": time.Date(2025, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{"name": "settlement", "startAt": time.Date(2025, 10, 12, 0, 0, 0, 0, time.UTC)},
+	}})
+	if err = app.Save(activity); err != nil {
+		t.Fatal(err)
+	}
+	phaseHooks, _ := app.FindCollectionByNameOrId("phase_hooks")
+	for _, phase := range []string{"voting", "settlement"} {
+		record := core.NewRecord(phaseHooks)
+		record.Set("phase", phase)
+		record.Set("hook", "settle_best")
+		record.Set("status", "success")
+		if err = app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = runMigration(t, app, "1759192900_phase_hooks_start.go"); err != nil {
+		t.Fatalf("补全开始时间失败: %v", err)
+	}
+	expected := map[string]string{"voting": "", "settlement": "2025-10-12 00:00:00.000Z"}
+	for phase, startAt := range expected {
+		record, err := app.FindFirstRecordByData(phaseHooks, "phase", phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := record.GetDateTime("startAt").String(); got != startAt {
+			t.Errorf("%s 的开始时间 = %q, 期望 %q", phase, got, startAt)
+		}
+	}
+}
+
+func TestSeedActivity(t *testing.T) {
+	app := testapp.New(t)
+	configs, _ := app.FindCollectionByNameOrId("configs")
+	activity, err := app.FindFirstRecordByData(configs, "key", "activity")
+	if err != nil {
+		t.Fatalf("迁移应写入活动日程: %v", err)
+	}
+	if value := activity.GetString("value"); !strings.Contains(value, `"voting"`) || strings.Contains(value, `"settlement"`) {
+		t.Errorf("写入的日程 = %s, 期望只有投票阶段", value)
+	}
+
+	// 已有日程时跳过
+	activity.Set("value", map[string]any{"phases": []map[string]any{{"name": "archive"}}})
+	if err = app.Save(activity); err != nil {
+		t.Fatal(err)
+	}
+	if err = runMigration(t, app, "1759193000_seed_activity.go"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := app.CountRecords("configs", dbx.HashExp{"key": "activity"}); count != 1 {
+		t.Errorf("活动日程 %d 条, 期望 1 条", count)
+	}
+	activity, _ = app.FindRecordById(configs, activity.Id)
+	if !strings.Contains(activity.GetString("value"), `"archive"`) {
+		t.Errorf("已有日程被覆盖: %s", activity.GetString("value"))
+	}
+}
+
+func TestAwardsWeightRequired(t *testing.T) {
+	app := testapp.New(t)
+	awards, _ := app.FindCollectionByNameOrId("awards")
+	award, err := app.FindFirstRecordByData(awards, "name", "一秀")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 升级前通过管理后台创建的奖项权重为 0
+	if _, err = app.DB().Update("awards", dbx.Params{"weight": 0}, dbx.HashExp{"id": award.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err = runMigration(t, app, "1759193100_awards_weight_required.go"); err != nil {
+		t.Fatal(err)
+	}
+	award, _ = app.FindRecordById(awards, award.Id)
+	if award.GetInt("weight") != 1 {
+		t.Errorf("权重为 0 的奖项应改为 1, 得到 %d", award.GetInt("weight"))
+	}
+
+	// 之后保存时权重不能为 0
+	award.Set("weight", 0)
+	if err = app.Save(award); err == nil {
+		t.Error("权重为 0 时应保存失败")
+	}
+}
@@ -20,6 +20,29 @@ var (
 	_ core.RecordProxy = (*Histories)(nil)
 	_ core.RecordProxy = (*Vote)(nil)
 	_ core.RecordProxy = (*Points)(nil)
+	_ core.RecordProxy = (*Session)(nil)
+	_ core.RecordProxy = (*Throttle)(nil)
+	_ core.RecordProxy = (*Audit)(nil)
+	_ core.RecordProxy = (*PhaseHook)(nil)
+	_ core.RecordProxy = (*Snapshot)(nil)
+	_ core.RecordProxy = (*Settlement)(nil)
+	_ core.RecordProxy = (*ChampionEvent)(nil)
+	_ core.RecordProxy = (*Notification)(nil)
+	_ core.RecordProxy = (*Table)(nil)
+	_ core.RecordProxy = (*TableTurn)(nil)
+	_ core.RecordProxy = (*LedgerEntry)(nil)
+	_ core.RecordProxy = (*Budget)(nil)
+	_ core.RecordProxy = (*Reconciliation)(nil)
+	_ core.RecordProxy = (*GuardTrip)(nil)
+	_ core.RecordProxy = (*Reservation)(nil)
+	_ core.RecordProxy = (*RewardCode)(nil)
+	_ core.RecordProxy = (*Fulfillment)(nil)
+	_ core.RecordProxy = (*Export)(nil)
+	_ core.RecordProxy = (*Report)(nil)
+	_ core.RecordProxy = (*Thank)(nil)
+	_ core.RecordProxy = (*Checkin)(nil)
+	_ core.RecordProxy = (*DrawGrant)(nil)
+	_ core.RecordProxy = (*OpenidNonce)(nil)
 )
 
 const (
@@ -31,6 +54,7 @@ const (
 	UsersFieldNickname        = "nickname"
 	UsersFieldAvatar          = "avatar"
 	UsersFieldOId             = "oId"
+	UsersFieldRole            = "role"
 	UsersFieldCreated         = "created"
 	UsersFieldUpdated         = "updated"
 )
@@ -82,6 +106,22 @@ func (user *User) SetOId(value string) {
 	user.Set(UsersFieldOId, value)
 }
 
+// Role 未设置角色的用户视为玩家，超级管理员的记录不属于 users 集合，视为 superuser
+func (user *User) Role() UserRole {
+	if user.IsSuperuser() {
+		return UserRoleSuperuser
+	}
+	role, err := ParseUserRole(user.GetString(UsersFieldRole))
+	if err != nil {
+		return UserRolePlayer
+	}
+	return role
+}
+
+func (user *User) SetRole(value UserRole) {
+	user.Set(UsersFieldRole, value)
+}
+
 func (user *User) Created() types.DateTime {
 	return user.GetDateTime(UsersFieldCreated)
 }
@@ -139,6 +179,9 @@ const (
 	ArticlesFieldCollectCnt     = "collectCnt"
 	ArticlesFieldThankCnt       = "thankCnt"
 	ArticlesFieldScore          = "score"
+	ArticlesFieldFlagged        = "flagged"
+	ArticlesFieldFlagReason     = "flagReason"
+	ArticlesFieldDisqualified   = "disqualified"
 	ArticlesFieldCreatedAt      = "createdAt"
 	ArticlesFieldUpdatedAt      = "updatedAt"
 	ArticlesFieldCreated        = "created"
@@ -240,6 +283,30 @@ func (article *Article) SetScore(value float64) {
 	article.Set(ArticlesFieldScore, value)
 }
 
+func (article *Article) Flagged() bool {
+	return article.GetBool(ArticlesFieldFlagged)
+}
+
+func (article *Article) SetFlagged(value bool) {
+	article.Set(ArticlesFieldFlagged, value)
+}
+
+func (article *Article) FlagReason() string {
+	return article.GetString(ArticlesFieldFlagReason)
+}
+
+func (article *Article) SetFlagReason(value string) {
+	article.Set(ArticlesFieldFlagReason, value)
+}
+
+func (article *Article) Disqualified() bool {
+	return article.GetBool(ArticlesFieldDisqualified)
+}
+
+func (article *Article) SetDisqualified(value bool) {
+	article.Set(ArticlesFieldDisqualified, value)
+}
+
 func (article *Article) CreatedAt() types.DateTime {
 	return article.GetDateTime(ArticlesFieldCreatedAt)
 }
@@ -313,12 +380,17 @@ func (article *Article) CalculateScore() float64 {
 }
 
 const (
-	DbNameRewards      = "rewards"
-	RewardsFieldLevel  = "level"
-	RewardsFieldName   = "name"
-	RewardsFieldPoint  = "point"
-	RewardsFieldAmount = "amount"
-	RewardsFiledMore   = "more"
+	DbNameRewards        = "rewards"
+	RewardsFieldLevel    = "level"
+	RewardsFieldName     = "name"
+	RewardsFieldPoint    = "point"
+	RewardsFieldAmount   = "amount"
+	RewardsFiledMore     = "more"
+	RewardsFieldReserved = "reserved"
+	RewardsFieldRetired  = "retired"
+	RewardsFieldLowStock = "lowStock"
+	RewardsFieldKind     = "kind"
+	RewardsFieldMedal    = "medal"
 )
 
 type Reward struct {
@@ -376,12 +448,57 @@ func (reward *Reward) SetMore(value string) {
 	reward.Set(RewardsFiledMore, value)
 }
 
+func (reward *Reward) Reserved() int {
+	return reward.GetInt(RewardsFieldReserved)
+}
+
+func (reward *Reward) SetReserved(value int) {
+	reward.Set(RewardsFieldReserved, value)
+}
+
+func (reward *Reward) Retired() bool {
+	return reward.GetBool(RewardsFieldRetired)
+}
+
+func (reward *Reward) SetRetired(value bool) {
+	reward.Set(RewardsFieldRetired, value)
+}
+
+func (reward *Reward) LowStock() int {
+	return reward.GetInt(RewardsFieldLowStock)
+}
+
+func (reward *Reward) SetLowStock(value int) {
+	reward.Set(RewardsFieldLowStock, value)
+}
+
+func (reward *Reward) Kind() RewardKind {
+	return MustParseRewardKind(reward.GetString(RewardsFieldKind))
+}
+
+func (reward *Reward) SetKind(value RewardKind) {
+	reward.Set(RewardsFieldKind, value)
+}
+
+func (reward *Reward) Medal() Medal {
+	medal := Medal{}
+	_ = reward.UnmarshalJSONField(RewardsFieldMedal, &medal)
+	return medal
+}
+
+func (reward *Reward) SetMedal(value Medal) {
+	reward.Set(RewardsFieldMedal, value)
+}
+
 const (
 	DbNameAwards           = "awards"
 	AwardsFieldLevel       = "level"
 	AwardsFieldName        = "name"
 	AwardsFieldRewardId    = "rewardId"
 	AwardsFieldDescription = "description"
+	AwardsFieldWeight      = "weight"
+	AwardsFieldFallbackId  = "fallbackId"
+	AwardsFieldCondition   = "condition"
 )
 
 type Awards struct {
@@ -431,18 +548,46 @@ func (award *Awards) SetDescription(value string) {
 	award.Set(AwardsFieldDescription, value)
 }
 
+func (award *Awards) Weight() int {
+	return award.GetInt(AwardsFieldWeight)
+}
+
+func (award *Awards) SetWeight(value int) {
+	award.Set(AwardsFieldWeight, value)
+}
+
+func (award *Awards) FallbackId() string {
+	return award.GetString(AwardsFieldFallbackId)
+}
+
+func (award *Awards) SetFallbackId(value string) {
+	award.Set(AwardsFieldFallbackId, value)
+}
+
+func (award *Awards) Condition() AwardCondition {
+	return MustParseAwardCondition(award.GetString(AwardsFieldCondition))
+}
+
+func (award *Awards) SetCondition(value AwardCondition) {
+	award.Set(AwardsFieldCondition, value)
+}
+
 const (
-	DbNameHistories         = "histories"
-	HistoriesFieldUserId    = "userId"
-	HistoriesFieldTimes     = "times"
-	HistoriesFieldAwardId   = "awardId"
-	HistoriesFieldRewardId  = "rewardId"
-	HistoriesFieldIsTop     = "isTop"
-	HistoriesFieldIsBest    = "isBest"
-	HistoriesFieldGotReward = "gotReward"
-	HistoriesFieldDetails   = "details"
-	HistoriesFieldCreated   = "created"
-	HistoriesFieldUpdated   = "updated"
+	DbNameHistories             = "histories"
+	HistoriesFieldUserId        = "userId"
+	HistoriesFieldTimes         = "times"
+	HistoriesFieldAwardId       = "awardId"
+	HistoriesFieldRewardId      = "rewardId"
+	HistoriesFieldIsTop         = "isTop"
+	HistoriesFieldIsBest        = "isBest"
+	HistoriesFieldGotReward     = "gotReward"
+	HistoriesFieldDetails       = "details"
+	HistoriesFieldFeeId         = "feeId"
+	HistoriesFieldSeed          = "seed"
+	HistoriesFieldPickedAwardId = "pickedAwardId"
+	HistoriesFieldQuotaSource   = "quotaSource"
+	HistoriesFieldCreated       = "created"
+	HistoriesFieldUpdated       = "updated"
 )
 
 type Histories struct {
@@ -531,6 +676,39 @@ func (history *Histories) SetDetails(value [6]int) {
 	history.Set(HistoriesFieldDetails, value)
 }
 
+func (history *Histories) FeeId() string {
+	return history.GetString(HistoriesFieldFeeId)
+}
+
+func (history *Histories) SetFeeId(value string) {
+	history.Set(HistoriesFieldFeeId, value)
+}
+
+func (history *Histories) Seed() string {
+	return history.GetString(HistoriesFieldSeed)
+}
+
+func (history *Histories) SetSeed(value string) {
+	history.Set(HistoriesFieldSeed, value)
+}
+
+func (history *Histories) PickedAwardId() string {
+	return history.GetString(HistoriesFieldPickedAwardId)
+}
+
+func (history *Histories) SetPickedAwardId(value string) {
+	history.Set(HistoriesFieldPickedAwardId, value)
+}
+
+// QuotaSource 消耗的每日重置的次数来源，为空表示消耗长期次数或付费博饼
+func (history *Histories) QuotaSource() string {
+	return history.GetString(HistoriesFieldQuotaSource)
+}
+
+func (history *Histories) SetQuotaSource(value string) {
+	history.Set(HistoriesFieldQuotaSource, value)
+}
+
 func (history *Histories) Created() types.DateTime {
 	return history.GetDateTime(HistoriesFieldCreated)
 }
@@ -545,6 +723,8 @@ const (
 	VotesFieldToUserId   = "toUserId"
 	VotesFieldArticleId  = "articleId"
 	VotesFieldVoteType   = "voteType"
+	VotesFieldFlagged    = "flagged"
+	VotesFieldFlagReason = "flagReason"
 	VotesFieldCreated    = "created"
 	VotesFieldUpdated    = "updated"
 )
@@ -596,6 +776,22 @@ func (vote *Vote) SetVoteType(value string) {
 	vote.Set(VotesFieldVoteType, value)
 }
 
+func (vote *Vote) Flagged() bool {
+	return vote.GetBool(VotesFieldFlagged)
+}
+
+func (vote *Vote) SetFlagged(value bool) {
+	vote.Set(VotesFieldFlagged, value)
+}
+
+func (vote *Vote) FlagReason() string {
+	return vote.GetString(VotesFieldFlagReason)
+}
+
+func (vote *Vote) SetFlagReason(value string) {
+	vote.Set(VotesFieldFlagReason, value)
+}
+
 func (vote *Vote) Created() types.DateTime {
 	return vote.GetDateTime(VotesFieldCreated)
 }
@@ -612,6 +808,10 @@ const (
 	PointsFieldStatus    = "status"
 	PointsFieldMemo      = "memo"
 	PointsFieldError     = "error"
+	PointsFieldKey       = "key"
+	PointsFieldSource    = "source"
+	PointsFieldRetryAt   = "retryAt"
+	PointsFieldRetries   = "retries"
 	PointsFieldCreated   = "created"
 	PointsFieldUpdated   = "updated"
 )
@@ -679,6 +879,38 @@ func (points *Points) SetError(value string) {
 	points.Set(PointsFieldError, value)
 }
 
+func (points *Points) Key() string {
+	return points.GetString(PointsFieldKey)
+}
+
+func (points *Points) SetKey(value string) {
+	points.Set(PointsFieldKey, value)
+}
+
+func (points *Points) Source() string {
+	return points.GetString(PointsFieldSource)
+}
+
+func (points *Points) SetSource(value string) {
+	points.Set(PointsFieldSource, value)
+}
+
+func (points *Points) RetryAt() types.DateTime {
+	return points.GetDateTime(PointsFieldRetryAt)
+}
+
+func (points *Points) SetRetryAt(value types.DateTime) {
+	points.Set(PointsFieldRetryAt, value)
+}
+
+func (points *Points) Retries() int {
+	return points.GetInt(PointsFieldRetries)
+}
+
+func (points *Points) SetRetries(value int) {
+	points.Set(PointsFieldRetries, value)
+}
+
 func (points *Points) Created() types.DateTime {
 	return points.GetDateTime(PointsFieldCreated)
 }
@@ -686,3 +918,1993 @@ func (points *Points) Created() types.DateTime {
 func (points *Points) Updated() types.DateTime {
 	return points.GetDateTime(PointsFieldUpdated)
 }
+
+const (
+	DbNameSessions            = "sessions"
+	SessionsFieldUserId       = "userId"
+	SessionsFieldTokenHash    = "tokenHash"
+	SessionsFieldDevice       = "device"
+	SessionsFieldIp           = "ip"
+	SessionsFieldLastActiveAt = "lastActiveAt"
+	SessionsFieldExpiresAt    = "expiresAt"
+	SessionsFieldRevoked      = "revoked"
+	SessionsFieldCreated      = "created"
+	SessionsFieldUpdated      = "updated"
+)
+
+type Session struct {
+	core.BaseRecordProxy
+}
+
+func NewSession(record *core.Record) *Session {
+	session := new(Session)
+	session.SetProxyRecord(record)
+	return session
+}
+
+func NewSessionFromCollection(collection *core.Collection) *Session {
+	record := core.NewRecord(collection)
+	return NewSession(record)
+}
+
+func (session *Session) UserId() string {
+	return session.GetString(SessionsFieldUserId)
+}
+
+func (session *Session) SetUserId(value string) {
+	session.Set(SessionsFieldUserId, value)
+}
+
+func (session *Session) TokenHash() string {
+	return session.GetString(SessionsFieldTokenHash)
+}
+
+func (session *Session) SetTokenHash(value string) {
+	session.Set(SessionsFieldTokenHash, value)
+}
+
+func (session *Session) Device() string {
+	return session.GetString(SessionsFieldDevice)
+}
+
+func (session *Session) SetDevice(value string) {
+	session.Set(SessionsFieldDevice, value)
+}
+
+func (session *Session) Ip() string {
+	return session.GetString(SessionsFieldIp)
+}
+
+func (session *Session) SetIp(value string) {
+	session.Set(SessionsFieldIp, value)
+}
+
+func (session *Session) LastActiveAt() types.DateTime {
+	return session.GetDateTime(SessionsFieldLastActiveAt)
+}
+
+func (session *Session) SetLastActiveAt(value types.DateTime) {
+	session.Set(SessionsFieldLastActiveAt, value)
+}
+
+func (session *Session) ExpiresAt() types.DateTime {
+	return session.GetDateTime(SessionsFieldExpiresAt)
+}
+
+func (session *Session) SetExpiresAt(value types.DateTime) {
+	session.Set(SessionsFieldExpiresAt, value)
+}
+
+func (session *Session) Revoked() bool {
+	return session.GetBool(SessionsFieldRevoked)
+}
+
+func (session *Session) SetRevoked(value bool) {
+	session.Set(SessionsFieldRevoked, value)
+}
+
+func (session *Session) Created() types.DateTime {
+	return session.GetDateTime(SessionsFieldCreated)
+}
+
+func (session *Session) Updated() types.DateTime {
+	return session.GetDateTime(SessionsFieldUpdated)
+}
+
+const (
+	DbNameThrottles          = "throttles"
+	ThrottlesFieldRule       = "rule"
+	ThrottlesFieldScope      = "scope"
+	ThrottlesFieldUserId     = "userId"
+	ThrottlesFieldIp         = "ip"
+	ThrottlesFieldMethod     = "method"
+	ThrottlesFieldPath       = "path"
+	ThrottlesFieldDropped    = "dropped"
+	ThrottlesFieldRetryAfter = "retryAfter"
+	ThrottlesFieldCreated    = "created"
+	ThrottlesFieldUpdated    = "updated"
+)
+
+type Throttle struct {
+	core.BaseRecordProxy
+}
+
+func NewThrottle(record *core.Record) *Throttle {
+	throttle := new(Throttle)
+	throttle.SetProxyRecord(record)
+	return throttle
+}
+
+func NewThrottleFromCollection(collection *core.Collection) *Throttle {
+	record := core.NewRecord(collection)
+	return NewThrottle(record)
+}
+
+func (throttle *Throttle) Rule() string {
+	return throttle.GetString(ThrottlesFieldRule)
+}
+
+func (throttle *Throttle) SetRule(value string) {
+	throttle.Set(ThrottlesFieldRule, value)
+}
+
+func (throttle *Throttle) Scope() ThrottleScope {
+	return MustParseThrottleScope(throttle.GetString(ThrottlesFieldScope))
+}
+
+func (throttle *Throttle) SetScope(value ThrottleScope) {
+	throttle.Set(ThrottlesFieldScope, value)
+}
+
+func (throttle *Throttle) UserId() string {
+	return throttle.GetString(ThrottlesFieldUserId)
+}
+
+func (throttle *Throttle) SetUserId(value string) {
+	throttle.Set(ThrottlesFieldUserId, value)
+}
+
+func (throttle *Throttle) Ip() string {
+	return throttle.GetString(ThrottlesFieldIp)
+}
+
+func (throttle *Throttle) SetIp(value string) {
+	throttle.Set(ThrottlesFieldIp, value)
+}
+
+func (throttle *Throttle) Method() string {
+	return throttle.GetString(ThrottlesFieldMethod)
+}
+
+func (throttle *Throttle) SetMethod(value string) {
+	throttle.Set(ThrottlesFieldMethod, value)
+}
+
+func (throttle *Throttle) Path() string {
+	return throttle.GetString(ThrottlesFieldPath)
+}
+
+func (throttle *Throttle) SetPath(value string) {
+	throttle.Set(ThrottlesFieldPath, value)
+}
+
+func (throttle *Throttle) Dropped() int {
+	return throttle.GetInt(ThrottlesFieldDropped)
+}
+
+func (throttle *Throttle) SetDropped(value int) {
+	throttle.Set(ThrottlesFieldDropped, value)
+}
+
+func (throttle *Throttle) RetryAfter() int {
+	return throttle.GetInt(ThrottlesFieldRetryAfter)
+}
+
+func (throttle *Throttle) SetRetryAfter(value int) {
+	throttle.Set(ThrottlesFieldRetryAfter, value)
+}
+
+func (throttle *Throttle) Created() types.DateTime {
+	return throttle.GetDateTime(ThrottlesFieldCreated)
+}
+
+func (throttle *Throttle) Updated() types.DateTime {
+	return throttle.GetDateTime(ThrottlesFieldUpdated)
+}
+
+const (
+	DbNameAudits                = "audits"
+	AuditsFieldActorId          = "actorId"
+	AuditsFieldActorRole        = "actorRole"
+	AuditsFieldSuperuserId      = "superuserId"
+	AuditsFieldAction           = "action"
+	AuditsFieldTargetCollection = "targetCollection"
+	AuditsFieldTargetId         = "targetId"
+	AuditsFieldBefore           = "before"
+	AuditsFieldAfter            = "after"
+	AuditsFieldIp               = "ip"
+	AuditsFieldMemo             = "memo"
+	AuditsFieldCreated          = "created"
+	AuditsFieldUpdated          = "updated"
+)
+
+type Audit struct {
+	core.BaseRecordProxy
+}
+
+func NewAudit(record *core.Record) *Audit {
+	audit := new(Audit)
+	audit.SetProxyRecord(record)
+	return audit
+}
+
+func NewAuditFromCollection(collection *core.Collection) *Audit {
+	record := core.NewRecord(collection)
+	return NewAudit(record)
+}
+
+func (audit *Audit) ActorId() string {
+	return audit.GetString(AuditsFieldActorId)
+}
+
+func (audit *Audit) SetActorId(value string) {
+	audit.Set(AuditsFieldActorId, value)
+}
+
+func (audit *Audit) ActorRole() UserRole {
+	return MustParseUserRole(audit.GetString(AuditsFieldActorRole))
+}
+
+func (audit *Audit) SetActorRole(value UserRole) {
+	audit.Set(AuditsFieldActorRole, value)
+}
+
+func (audit *Audit) SuperuserId() string {
+	return audit.GetString(AuditsFieldSuperuserId)
+}
+
+func (audit *Audit) SetSuperuserId(value string) {
+	audit.Set(AuditsFieldSuperuserId, value)
+}
+
+func (audit *Audit) Action() string {
+	return audit.GetString(AuditsFieldAction)
+}
+
+func (audit *Audit) SetAction(value string) {
+	audit.Set(AuditsFieldAction, value)
+}
+
+func (audit *Audit) TargetCollection() string {
+	return audit.GetString(AuditsFieldTargetCollection)
+}
+
+func (audit *Audit) SetTargetCollection(value string) {
+	audit.Set(AuditsFieldTargetCollection, value)
+}
+
+func (audit *Audit) TargetId() string {
+	return audit.GetString(AuditsFieldTargetId)
+}
+
+func (audit *Audit) SetTargetId(value string) {
+	audit.Set(AuditsFieldTargetId, value)
+}
+
+func (audit *Audit) Before() string {
+	return audit.GetString(AuditsFieldBefore)
+}
+
+func (audit *Audit) SetBefore(value any) {
+	audit.Set(AuditsFieldBefore, value)
+}
+
+func (audit *Audit) After() string {
+	return audit.GetString(AuditsFieldAfter)
+}
+
+func (audit *Audit) SetAfter(value any) {
+	audit.Set(AuditsFieldAfter, value)
+}
+
+func (audit *Audit) Ip() string {
+	return audit.GetString(AuditsFieldIp)
+}
+
+func (audit *Audit) SetIp(value string) {
+	audit.Set(AuditsFieldIp, value)
+}
+
+func (audit *Audit) Memo() string {
+	return audit.GetString(AuditsFieldMemo)
+}
+
+func (audit *Audit) SetMemo(value string) {
+	audit.Set(AuditsFieldMemo, value)
+}
+
+func (audit *Audit) Created() types.DateTime {
+	return audit.GetDateTime(AuditsFieldCreated)
+}
+
+func (audit *Audit) Updated() types.DateTime {
+	return audit.GetDateTime(AuditsFieldUpdated)
+}
+
+const (
+	DbNamePhaseHooks       = "phase_hooks"
+	PhaseHooksFieldPhase   = "phase"
+	PhaseHooksFieldHook    = "hook"
+	PhaseHooksFieldStatus  = "status"
+	PhaseHooksFieldError   = "error"
+	PhaseHooksFieldRunAt   = "runAt"
+	PhaseHooksFieldStartAt = "startAt"
+	PhaseHooksFieldCreated = "created"
+	PhaseHooksFieldUpdated = "updated"
+)
+
+type PhaseHook struct {
+	core.BaseRecordProxy
+}
+
+func NewPhaseHook(record *core.Record) *PhaseHook {
+	phaseHook := new(PhaseHook)
+	phaseHook.SetProxyRecord(record)
+	return phaseHook
+}
+
+func NewPhaseHookFromCollection(collection *core.Collection) *PhaseHook {
+	record := core.NewRecord(collection)
+	return NewPhaseHook(record)
+}
+
+func (phaseHook *PhaseHook) Phase() ActivityPhase {
+	return MustParseActivityPhase(phaseHook.GetString(PhaseHooksFieldPhase))
+}
+
+func (phaseHook *PhaseHook) SetPhase(value ActivityPhase) {
+	phaseHook.Set(PhaseHooksFieldPhase, value)
+}
+
+func (phaseHook *PhaseHook) Hook() string {
+	return phaseHook.GetString(PhaseHooksFieldHook)
+}
+
+func (phaseHook *PhaseHook) SetHook(value string) {
+	phaseHook.Set(PhaseHooksFieldHook, value)
+}
+
+func (phaseHook *PhaseHook) Status() PhaseHookStatus {
+	return MustParsePhaseHookStatus(phaseHook.GetString(PhaseHooksFieldStatus))
+}
+
+func (phaseHook *PhaseHook) SetStatus(value PhaseHookStatus) {
+	phaseHook.Set(PhaseHooksFieldStatus, value)
+}
+
+func (phaseHook *PhaseHook) Error() string {
+	return phaseHook.GetString(PhaseHooksFieldError)
+}
+
+func (phaseHook *PhaseHook) SetError(value string) {
+	phaseHook.Set(PhaseHooksFieldError, value)
+}
+
+func (phaseHook *PhaseHook) RunAt() types.DateTime {
+	return phaseHook.GetDateTime(PhaseHooksFieldRunAt)
+}
+
+func (phaseHook *PhaseHook) SetRunAt(value types.DateTime) {
+	phaseHook.Set(PhaseHooksFieldRunAt, value)
+}
+
+func (phaseHook *PhaseHook) StartAt() types.DateTime {
+	return phaseHook.GetDateTime(PhaseHooksFieldStartAt)
+}
+
+func (phaseHook *PhaseHook) SetStartAt(value types.DateTime) {
+	phaseHook.Set(PhaseHooksFieldStartAt, value)
+}
+
+func (phaseHook *PhaseHook) Created() types.DateTime {
+	return phaseHook.GetDateTime(PhaseHooksFieldCreated)
+}
+
+func (phaseHook *PhaseHook) Updated() types.DateTime {
+	return phaseHook.GetDateTime(PhaseHooksFieldUpdated)
+}
+
+const (
+	DbNameSnapshots       = "snapshots"
+	SnapshotsFieldName    = "name"
+	SnapshotsFieldPhase   = "phase"
+	SnapshotsFieldData    = "data"
+	SnapshotsFieldCreated = "created"
+	SnapshotsFieldUpdated = "updated"
+)
+
+type Snapshot struct {
+	core.BaseRecordProxy
+}
+
+func NewSnapshot(record *core.Record) *Snapshot {
+	snapshot := new(Snapshot)
+	snapshot.SetProxyRecord(record)
+	return snapshot
+}
+
+func NewSnapshotFromCollection(collection *core.Collection) *Snapshot {
+	record := core.NewRecord(collection)
+	return NewSnapshot(record)
+}
+
+func (snapshot *Snapshot) Name() string {
+	return snapshot.GetString(SnapshotsFieldName)
+}
+
+func (snapshot *Snapshot) SetName(value string) {
+	snapshot.Set(SnapshotsFieldName, value)
+}
+
+func (snapshot *Snapshot) Phase() ActivityPhase {
+	return MustParseActivityPhase(snapshot.GetString(SnapshotsFieldPhase))
+}
+
+func (snapshot *Snapshot) SetPhase(value ActivityPhase) {
+	snapshot.Set(SnapshotsFieldPhase, value)
+}
+
+func (snapshot *Snapshot) Data() string {
+	return snapshot.GetString(SnapshotsFieldData)
+}
+
+func (snapshot *Snapshot) SetData(value any) {
+	snapshot.Set(SnapshotsFieldData, value)
+}
+
+func (snapshot *Snapshot) Created() types.DateTime {
+	return snapshot.GetDateTime(SnapshotsFieldCreated)
+}
+
+func (snapshot *Snapshot) Updated() types.DateTime {
+	return snapshot.GetDateTime(SnapshotsFieldUpdated)
+}
+
+const (
+	DbNameSettlements         = "settlements"
+	SettlementsFieldRunNo     = "runNo"
+	SettlementsFieldWinners   = "winners"
+	SettlementsFieldDigest    = "digest"
+	SettlementsFieldSignature = "signature"
+	SettlementsFieldReport    = "report"
+	SettlementsFieldCreated   = "created"
+	SettlementsFieldUpdated   = "updated"
+)
+
+type Settlement struct {
+	core.BaseRecordProxy
+}
+
+func NewSettlement(record *core.Record) *Settlement {
+	settlement := new(Settlement)
+	settlement.SetProxyRecord(record)
+	return settlement
+}
+
+func NewSettlementFromCollection(collection *core.Collection) *Settlement {
+	record := core.NewRecord(collection)
+	return NewSettlement(record)
+}
+
+func (settlement *Settlement) RunNo() int {
+	return settlement.GetInt(SettlementsFieldRunNo)
+}
+
+func (settlement *Settlement) SetRunNo(value int) {
+	settlement.Set(SettlementsFieldRunNo, value)
+}
+
+func (settlement *Settlement) Winners() int {
+	return settlement.GetInt(SettlementsFieldWinners)
+}
+
+func (settlement *Settlement) SetWinners(value int) {
+	settlement.Set(SettlementsFieldWinners, value)
+}
+
+func (settlement *Settlement) Digest() string {
+	return settlement.GetString(SettlementsFieldDigest)
+}
+
+func (settlement *Settlement) SetDigest(value string) {
+	settlement.Set(SettlementsFieldDigest, value)
+}
+
+func (settlement *Settlement) Signature() string {
+	return settlement.GetString(SettlementsFieldSignature)
+}
+
+func (settlement *Settlement) SetSignature(value string) {
+	settlement.Set(SettlementsFieldSignature, value)
+}
+
+func (settlement *Settlement) Report() string {
+	return settlement.GetString(SettlementsFieldReport)
+}
+
+func (settlement *Settlement) SetReport(value any) {
+	settlement.Set(SettlementsFieldReport, value)
+}
+
+func (settlement *Settlement) Created() types.DateTime {
+	return settlement.GetDateTime(SettlementsFieldCreated)
+}
+
+func (settlement *Settlement) Updated() types.DateTime {
+	return settlement.GetDateTime(SettlementsFieldUpdated)
+}
+
+const (
+	DbNameChampionEvents                 = "champion_events"
+	ChampionEventsFieldHistoryId         = "historyId"
+	ChampionEventsFieldUserId            = "userId"
+	ChampionEventsFieldPreviousHistoryId = "previousHistoryId"
+	ChampionEventsFieldPreviousUserId    = "previousUserId"
+	ChampionEventsFieldPrizeLevel        = "prizeLevel"
+	ChampionEventsFieldPrizeName         = "prizeName"
+	ChampionEventsFieldDices             = "dices"
+	ChampionEventsFieldCreated           = "created"
+	ChampionEventsFieldUpdated           = "updated"
+)
+
+type ChampionEvent struct {
+	core.BaseRecordProxy
+}
+
+func NewChampionEvent(record *core.Record) *ChampionEvent {
+	championEvent := new(ChampionEvent)
+	championEvent.SetProxyRecord(record)
+	return championEvent
+}
+
+func NewChampionEventFromCollection(collection *core.Collection) *ChampionEvent {
+	record := core.NewRecord(collection)
+	return NewChampionEvent(record)
+}
+
+func (championEvent *ChampionEvent) HistoryId() string {
+	return championEvent.GetString(ChampionEventsFieldHistoryId)
+}
+
+func (championEvent *ChampionEvent) SetHistoryId(value string) {
+	championEvent.Set(ChampionEventsFieldHistoryId, value)
+}
+
+func (championEvent *ChampionEvent) UserId() string {
+	return championEvent.GetString(ChampionEventsFieldUserId)
+}
+
+func (championEvent *ChampionEvent) SetUserId(value string) {
+	championEvent.Set(ChampionEventsFieldUserId, value)
+}
+
+func (championEvent *ChampionEvent) PreviousHistoryId() string {
+	return championEvent.GetString(ChampionEventsFieldPreviousHistoryId)
+}
+
+func (championEvent *ChampionEvent) SetPreviousHistoryId(value string) {
+	championEvent.Set(ChampionEventsFieldPreviousHistoryId, value)
+}
+
+func (championEvent *ChampionEvent) PreviousUserId() string {
+	return championEvent.GetString(ChampionEventsFieldPreviousUserId)
+}
+
+func (championEvent *ChampionEvent) SetPreviousUserId(value string) {
+	championEvent.Set(ChampionEventsFieldPreviousUserId, value)
+}
+
+func (championEvent *ChampionEvent) PrizeLevel() int {
+	return championEvent.GetInt(ChampionEventsFieldPrizeLevel)
+}
+
+func (championEvent *ChampionEvent) SetPrizeLevel(value int) {
+	championEvent.Set(ChampionEventsFieldPrizeLevel, value)
+}
+
+func (championEvent *ChampionEvent) PrizeName() string {
+	return championEvent.GetString(ChampionEventsFieldPrizeName)
+}
+
+func (championEvent *ChampionEvent) SetPrizeName(value string) {
+	championEvent.Set(ChampionEventsFieldPrizeName, value)
+}
+
+func (championEvent *ChampionEvent) Dices() [6]int {
+	var dices [6]int
+	_ = championEvent.UnmarshalJSONField(ChampionEventsFieldDices, &dices)
+	return dices
+}
+
+func (championEvent *ChampionEvent) SetDices(value [6]int) {
+	championEvent.Set(ChampionEventsFieldDices, value)
+}
+
+func (championEvent *ChampionEvent) Created() types.DateTime {
+	return championEvent.GetDateTime(ChampionEventsFieldCreated)
+}
+
+func (championEvent *ChampionEvent) Updated() types.DateTime {
+	return championEvent.GetDateTime(ChampionEventsFieldUpdated)
+}
+
+const (
+	DbNameNotifications       = "notifications"
+	NotificationsFieldUserId  = "userId"
+	NotificationsFieldType    = "type"
+	NotificationsFieldTitle   = "title"
+	NotificationsFieldContent = "content"
+	NotificationsFieldData    = "data"
+	NotificationsFieldRead    = "read"
+	NotificationsFieldCreated = "created"
+	NotificationsFieldUpdated = "updated"
+)
+
+type Notification struct {
+	core.BaseRecordProxy
+}
+
+func NewNotification(record *core.Record) *Notification {
+	notification := new(Notification)
+	notification.SetProxyRecord(record)
+	return notification
+}
+
+func NewNotificationFromCollection(collection *core.Collection) *Notification {
+	record := core.NewRecord(collection)
+	return NewNotification(record)
+}
+
+func (notification *Notification) UserId() string {
+	return notification.GetString(NotificationsFieldUserId)
+}
+
+func (notification *Notification) SetUserId(value string) {
+	notification.Set(NotificationsFieldUserId, value)
+}
+
+func (notification *Notification) Type() string {
+	return notification.GetString(NotificationsFieldType)
+}
+
+func (notification *Notification) SetType(value string) {
+	notification.Set(NotificationsFieldType, value)
+}
+
+func (notification *Notification) Title() string {
+	return notification.GetString(NotificationsFieldTitle)
+}
+
+func (notification *Notification) SetTitle(value string) {
+	notification.Set(NotificationsFieldTitle, value)
+}
+
+func (notification *Notification) Content() string {
+	return notification.GetString(NotificationsFieldContent)
+}
+
+func (notification *Notification) SetContent(value string) {
+	notification.Set(NotificationsFieldContent, value)
+}
+
+func (notification *Notification) SetData(value any) {
+	notification.Set(NotificationsFieldData, value)
+}
+
+func (notification *Notification) Read() bool {
+	return notification.GetBool(NotificationsFieldRead)
+}
+
+func (notification *Notification) SetRead(value bool) {
+	notification.Set(NotificationsFieldRead, value)
+}
+
+func (notification *Notification) Created() types.DateTime {
+	return notification.GetDateTime(NotificationsFieldCreated)
+}
+
+func (notification *Notification) Updated() types.DateTime {
+	return notification.GetDateTime(NotificationsFieldUpdated)
+}
+
+const (
+	DbNameTables            = "tables"
+	TablesFieldName         = "name"
+	TablesFieldOwnerId      = "ownerId"
+	TablesFieldStatus       = "status"
+	TablesFieldCapacity     = "capacity"
+	TablesFieldMaxRounds    = "maxRounds"
+	TablesFieldTurnTimeout  = "turnTimeout"
+	TablesFieldPlayers      = "players"
+	TablesFieldSeat         = "seat"
+	TablesFieldRound        = "round"
+	TablesFieldPool         = "pool"
+	TablesFieldRemaining    = "remaining"
+	TablesFieldTurnDeadline = "turnDeadline"
+	TablesFieldResult       = "result"
+	TablesFieldCreated      = "created"
+	TablesFieldUpdated      = "updated"
+)
+
+type Table struct {
+	core.BaseRecordProxy
+}
+
+func NewTable(record *core.Record) *Table {
+	table := new(Table)
+	table.SetProxyRecord(record)
+	return table
+}
+
+func NewTableFromCollection(collection *core.Collection) *Table {
+	record := core.NewRecord(collection)
+	return NewTable(record)
+}
+
+func (table *Table) Name() string {
+	return table.GetString(TablesFieldName)
+}
+
+func (table *Table) SetName(value string) {
+	table.Set(TablesFieldName, value)
+}
+
+func (table *Table) OwnerId() string {
+	return table.GetString(TablesFieldOwnerId)
+}
+
+func (table *Table) SetOwnerId(value string) {
+	table.Set(TablesFieldOwnerId, value)
+}
+
+func (table *Table) Status() TableStatus {
+	return MustParseTableStatus(table.GetString(TablesFieldStatus))
+}
+
+func (table *Table) SetStatus(value TableStatus) {
+	table.Set(TablesFieldStatus, value)
+}
+
+func (table *Table) Capacity() int {
+	return table.GetInt(TablesFieldCapacity)
+}
+
+func (table *Table) SetCapacity(value int) {
+	table.Set(TablesFieldCapacity, value)
+}
+
+func (table *Table) MaxRounds() int {
+	return table.GetInt(TablesFieldMaxRounds)
+}
+
+func (table *Table) SetMaxRounds(value int) {
+	table.Set(TablesFieldMaxRounds, value)
+}
+
+func (table *Table) TurnTimeout() int {
+	return table.GetInt(TablesFieldTurnTimeout)
+}
+
+func (table *Table) SetTurnTimeout(value int) {
+	table.Set(TablesFieldTurnTimeout, value)
+}
+
+func (table *Table) Players() []string {
+	return table.GetStringSlice(TablesFieldPlayers)
+}
+
+func (table *Table) SetPlayers(value []string) {
+	table.Set(TablesFieldPlayers, value)
+}
+
+func (table *Table) Seat() int {
+	return table.GetInt(TablesFieldSeat)
+}
+
+func (table *Table) SetSeat(value int) {
+	table.Set(TablesFieldSeat, value)
+}
+
+func (table *Table) Round() int {
+	return table.GetInt(TablesFieldRound)
+}
+
+func (table *Table) SetRound(value int) {
+	table.Set(TablesFieldRound, value)
+}
+
+func (table *Table) Pool() string {
+	return table.GetString(TablesFieldPool)
+}
+
+func (table *Table) SetPool(value any) {
+	table.Set(TablesFieldPool, value)
+}
+
+func (table *Table) Remaining() string {
+	return table.GetString(TablesFieldRemaining)
+}
+
+func (table *Table) SetRemaining(value any) {
+	table.Set(TablesFieldRemaining, value)
+}
+
+func (table *Table) TurnDeadline() types.DateTime {
+	return table.GetDateTime(TablesFieldTurnDeadline)
+}
+
+func (table *Table) SetTurnDeadline(value types.DateTime) {
+	table.Set(TablesFieldTurnDeadline, value)
+}
+
+func (table *Table) Result() string {
+	return table.GetString(TablesFieldResult)
+}
+
+func (table *Table) SetResult(value any) {
+	table.Set(TablesFieldResult, value)
+}
+
+func (table *Table) Created() types.DateTime {
+	return table.GetDateTime(TablesFieldCreated)
+}
+
+func (table *Table) Updated() types.DateTime {
+	return table.GetDateTime(TablesFieldUpdated)
+}
+
+const (
+	DbNameTableTurns          = "table_turns"
+	TableTurnsFieldTableId    = "tableId"
+	TableTurnsFieldUserId     = "userId"
+	TableTurnsFieldSeat       = "seat"
+	TableTurnsFieldRound      = "round"
+	TableTurnsFieldDices      = "dices"
+	TableTurnsFieldPrizeLevel = "prizeLevel"
+	TableTurnsFieldPrizeName  = "prizeName"
+	TableTurnsFieldPrize      = "prize"
+	TableTurnsFieldAuto       = "auto"
+	TableTurnsFieldCreated    = "created"
+	TableTurnsFieldUpdated    = "updated"
+)
+
+type TableTurn struct {
+	core.BaseRecordProxy
+}
+
+func NewTableTurn(record *core.Record) *TableTurn {
+	tableTurn := new(TableTurn)
+	tableTurn.SetProxyRecord(record)
+	return tableTurn
+}
+
+func NewTableTurnFromCollection(collection *core.Collection) *TableTurn {
+	record := core.NewRecord(collection)
+	return NewTableTurn(record)
+}
+
+func (tableTurn *TableTurn) TableId() string {
+	return tableTurn.GetString(TableTurnsFieldTableId)
+}
+
+func (tableTurn *TableTurn) SetTableId(value string) {
+	tableTurn.Set(TableTurnsFieldTableId, value)
+}
+
+func (tableTurn *TableTurn) UserId() string {
+	return tableTurn.GetString(TableTurnsFieldUserId)
+}
+
+func (tableTurn *TableTurn) SetUserId(value string) {
+	tableTurn.Set(TableTurnsFieldUserId, value)
+}
+
+func (tableTurn *TableTurn) Seat() int {
+	return tableTurn.GetInt(TableTurnsFieldSeat)
+}
+
+func (tableTurn *TableTurn) SetSeat(value int) {
+	tableTurn.Set(TableTurnsFieldSeat, value)
+}
+
+func (tableTurn *TableTurn) Round() int {
+	return tableTurn.GetInt(TableTurnsFieldRound)
+}
+
+func (tableTurn *TableTurn) SetRound(value int) {
+	tableTurn.Set(TableTurnsFieldRound, value)
+}
+
+func (tableTurn *TableTurn) Dices() [6]int {
+	var dices [6]int
+	_ = tableTurn.UnmarshalJSONField(TableTurnsFieldDices, &dices)
+	return dices
+}
+
+func (tableTurn *TableTurn) SetDices(value [6]int) {
+	tableTurn.Set(TableTurnsFieldDices, value)
+}
+
+func (tableTurn *TableTurn) PrizeLevel() int {
+	return tableTurn.GetInt(TableTurnsFieldPrizeLevel)
+}
+
+func (tableTurn *TableTurn) SetPrizeLevel(value int) {
+	tableTurn.Set(TableTurnsFieldPrizeLevel, value)
+}
+
+func (tableTurn *TableTurn) PrizeName() string {
+	return tableTurn.GetString(TableTurnsFieldPrizeName)
+}
+
+func (tableTurn *TableTurn) SetPrizeName(value string) {
+	tableTurn.Set(TableTurnsFieldPrizeName, value)
+}
+
+func (tableTurn *TableTurn) Prize() string {
+	return tableTurn.GetString(TableTurnsFieldPrize)
+}
+
+func (tableTurn *TableTurn) SetPrize(value string) {
+	tableTurn.Set(TableTurnsFieldPrize, value)
+}
+
+func (tableTurn *TableTurn) Auto() bool {
+	return tableTurn.GetBool(TableTurnsFieldAuto)
+}
+
+func (tableTurn *TableTurn) SetAuto(value bool) {
+	tableTurn.Set(TableTurnsFieldAuto, value)
+}
+
+func (tableTurn *TableTurn) Created() types.DateTime {
+	return tableTurn.GetDateTime(TableTurnsFieldCreated)
+}
+
+func (tableTurn *TableTurn) Updated() types.DateTime {
+	return tableTurn.GetDateTime(TableTurnsFieldUpdated)
+}
+
+const (
+	DbNameLedgerEntries           = "ledger_entries"
+	LedgerEntriesFieldTransaction = "transaction"
+	LedgerEntriesFieldPointsId    = "pointsId"
+	LedgerEntriesFieldAccount     = "account"
+	LedgerEntriesFieldAmount      = "amount"
+	LedgerEntriesFieldMemo        = "memo"
+	LedgerEntriesFieldCreated     = "created"
+	LedgerEntriesFieldUpdated     = "updated"
+)
+
+type LedgerEntry struct {
+	core.BaseRecordProxy
+}
+
+func NewLedgerEntry(record *core.Record) *LedgerEntry {
+	ledgerEntry := new(LedgerEntry)
+	ledgerEntry.SetProxyRecord(record)
+	return ledgerEntry
+}
+
+func NewLedgerEntryFromCollection(collection *core.Collection) *LedgerEntry {
+	record := core.NewRecord(collection)
+	return NewLedgerEntry(record)
+}
+
+func (ledgerEntry *LedgerEntry) Transaction() string {
+	return ledgerEntry.GetString(LedgerEntriesFieldTransaction)
+}
+
+func (ledgerEntry *LedgerEntry) SetTransaction(value string) {
+	ledgerEntry.Set(LedgerEntriesFieldTransaction, value)
+}
+
+func (ledgerEntry *LedgerEntry) PointsId() string {
+	return ledgerEntry.GetString(LedgerEntriesFieldPointsId)
+}
+
+func (ledgerEntry *LedgerEntry) SetPointsId(value string) {
+	ledgerEntry.Set(LedgerEntriesFieldPointsId, value)
+}
+
+func (ledgerEntry *LedgerEntry) Account() string {
+	return ledgerEntry.GetString(LedgerEntriesFieldAccount)
+}
+
+func (ledgerEntry *LedgerEntry) SetAccount(value string) {
+	ledgerEntry.Set(LedgerEntriesFieldAccount, value)
+}
+
+func (ledgerEntry *LedgerEntry) Amount() int {
+	return ledgerEntry.GetInt(LedgerEntriesFieldAmount)
+}
+
+func (ledgerEntry *LedgerEntry) SetAmount(value int) {
+	ledgerEntry.Set(LedgerEntriesFieldAmount, value)
+}
+
+func (ledgerEntry *LedgerEntry) Memo() string {
+	return ledgerEntry.GetString(LedgerEntriesFieldMemo)
+}
+
+func (ledgerEntry *LedgerEntry) SetMemo(value string) {
+	ledgerEntry.Set(LedgerEntriesFieldMemo, value)
+}
+
+func (ledgerEntry *LedgerEntry) Created() types.DateTime {
+	return ledgerEntry.GetDateTime(LedgerEntriesFieldCreated)
+}
+
+func (ledgerEntry *LedgerEntry) Updated() types.DateTime {
+	return ledgerEntry.GetDateTime(LedgerEntriesFieldUpdated)
+}
+
+const (
+	DbNameBudgets       = "budgets"
+	BudgetsFieldSource  = "source"
+	BudgetsFieldCap     = "cap"
+	BudgetsFieldMemo    = "memo"
+	BudgetsFieldCreated = "created"
+	BudgetsFieldUpdated = "updated"
+)
+
+type Budget struct {
+	core.BaseRecordProxy
+}
+
+func NewBudget(record *core.Record) *Budget {
+	budget := new(Budget)
+	budget.SetProxyRecord(record)
+	return budget
+}
+
+func NewBudgetFromCollection(collection *core.Collection) *Budget {
+	record := core.NewRecord(collection)
+	return NewBudget(record)
+}
+
+func (budget *Budget) Source() string {
+	return budget.GetString(BudgetsFieldSource)
+}
+
+func (budget *Budget) SetSource(value string) {
+	budget.Set(BudgetsFieldSource, value)
+}
+
+func (budget *Budget) Cap() int {
+	return budget.GetInt(BudgetsFieldCap)
+}
+
+func (budget *Budget) SetCap(value int) {
+	budget.Set(BudgetsFieldCap, value)
+}
+
+func (budget *Budget) Memo() string {
+	return budget.GetString(BudgetsFieldMemo)
+}
+
+func (budget *Budget) SetMemo(value string) {
+	budget.Set(BudgetsFieldMemo, value)
+}
+
+func (budget *Budget) Created() types.DateTime {
+	return budget.GetDateTime(BudgetsFieldCreated)
+}
+
+func (budget *Budget) Updated() types.DateTime {
+	return budget.GetDateTime(BudgetsFieldUpdated)
+}
+
+const (
+	DbNameReconciliations       = "reconciliations"
+	ReconciliationsFieldIssues  = "issues"
+	ReconciliationsFieldReport  = "report"
+	ReconciliationsFieldCreated = "created"
+	ReconciliationsFieldUpdated = "updated"
+)
+
+type Reconciliation struct {
+	core.BaseRecordProxy
+}
+
+func NewReconciliation(record *core.Record) *Reconciliation {
+	reconciliation := new(Reconciliation)
+	reconciliation.SetProxyRecord(record)
+	return reconciliation
+}
+
+func NewReconciliationFromCollection(collection *core.Collection) *Reconciliation {
+	record := core.NewRecord(collection)
+	return NewReconciliation(record)
+}
+
+func (reconciliation *Reconciliation) Issues() int {
+	return reconciliation.GetInt(ReconciliationsFieldIssues)
+}
+
+func (reconciliation *Reconciliation) SetIssues(value int) {
+	reconciliation.Set(ReconciliationsFieldIssues, value)
+}
+
+func (reconciliation *Reconciliation) SetReport(value any) {
+	reconciliation.Set(ReconciliationsFieldReport, value)
+}
+
+func (reconciliation *Reconciliation) Created() types.DateTime {
+	return reconciliation.GetDateTime(ReconciliationsFieldCreated)
+}
+
+func (reconciliation *Reconciliation) Updated() types.DateTime {
+	return reconciliation.GetDateTime(ReconciliationsFieldUpdated)
+}
+
+const (
+	DbNameGuardTrips         = "guard_trips"
+	GuardTripsFieldReason    = "reason"
+	GuardTripsFieldPointsId  = "pointsId"
+	GuardTripsFieldUserId    = "userId"
+	GuardTripsFieldPoint     = "point"
+	GuardTripsFieldLimit     = "limit"
+	GuardTripsFieldActual    = "actual"
+	GuardTripsFieldMessage   = "message"
+	GuardTripsFieldResetBy   = "resetBy"
+	GuardTripsFieldResetAt   = "resetAt"
+	GuardTripsFieldResetMemo = "resetMemo"
+	GuardTripsFieldCreated   = "created"
+	GuardTripsFieldUpdated   = "updated"
+)
+
+type GuardTrip struct {
+	core.BaseRecordProxy
+}
+
+func NewGuardTrip(record *core.Record) *GuardTrip {
+	guardTrip := new(GuardTrip)
+	guardTrip.SetProxyRecord(record)
+	return guardTrip
+}
+
+func NewGuardTripFromCollection(collection *core.Collection) *GuardTrip {
+	record := core.NewRecord(collection)
+	return NewGuardTrip(record)
+}
+
+func (guardTrip *GuardTrip) Reason() string {
+	return guardTrip.GetString(GuardTripsFieldReason)
+}
+
+func (guardTrip *GuardTrip) SetReason(value string) {
+	guardTrip.Set(GuardTripsFieldReason, value)
+}
+
+func (guardTrip *GuardTrip) PointsId() string {
+	return guardTrip.GetString(GuardTripsFieldPointsId)
+}
+
+func (guardTrip *GuardTrip) SetPointsId(value string) {
+	guardTrip.Set(GuardTripsFieldPointsId, value)
+}
+
+func (guardTrip *GuardTrip) UserId() string {
+	return guardTrip.GetString(GuardTripsFieldUserId)
+}
+
+func (guardTrip *GuardTrip) SetUserId(value string) {
+	guardTrip.Set(GuardTripsFieldUserId, value)
+}
+
+func (guardTrip *GuardTrip) Point() int {
+	return guardTrip.GetInt(GuardTripsFieldPoint)
+}
+
+func (guardTrip *GuardTrip) SetPoint(value int) {
+	guardTrip.Set(GuardTripsFieldPoint, value)
+}
+
+func (guardTrip *GuardTrip) Limit() int {
+	return guardTrip.GetInt(GuardTripsFieldLimit)
+}
+
+func (guardTrip *GuardTrip) SetLimit(value int) {
+	guardTrip.Set(GuardTripsFieldLimit, value)
+}
+
+func (guardTrip *GuardTrip) Actual() int {
+	return guardTrip.GetInt(GuardTripsFieldActual)
+}
+
+func (guardTrip *GuardTrip) SetActual(value int) {
+	guardTrip.Set(GuardTripsFieldActual, value)
+}
+
+func (guardTrip *GuardTrip) Message() string {
+	return guardTrip.GetString(GuardTripsFieldMessage)
+}
+
+func (guardTrip *GuardTrip) SetMessage(value string) {
+	guardTrip.Set(GuardTripsFieldMessage, value)
+}
+
+func (guardTrip *GuardTrip) ResetBy() string {
+	return guardTrip.GetString(GuardTripsFieldResetBy)
+}
+
+func (guardTrip *GuardTrip) SetResetBy(value string) {
+	guardTrip.Set(GuardTripsFieldResetBy, value)
+}
+
+func (guardTrip *GuardTrip) ResetAt() types.DateTime {
+	return guardTrip.GetDateTime(GuardTripsFieldResetAt)
+}
+
+func (guardTrip *GuardTrip) SetResetAt(value types.DateTime) {
+	guardTrip.Set(GuardTripsFieldResetAt, value)
+}
+
+func (guardTrip *GuardTrip) ResetMemo() string {
+	return guardTrip.GetString(GuardTripsFieldResetMemo)
+}
+
+func (guardTrip *GuardTrip) SetResetMemo(value string) {
+	guardTrip.Set(GuardTripsFieldResetMemo, value)
+}
+
+func (guardTrip *GuardTrip) Created() types.DateTime {
+	return guardTrip.GetDateTime(GuardTripsFieldCreated)
+}
+
+func (guardTrip *GuardTrip) Updated() types.DateTime {
+	return guardTrip.GetDateTime(GuardTripsFieldUpdated)
+}
+
+const (
+	DbNameReservations         = "reservations"
+	ReservationsFieldRewardId  = "rewardId"
+	ReservationsFieldHistoryId = "historyId"
+	ReservationsFieldStatus    = "status"
+	ReservationsFieldReason    = "reason"
+	ReservationsFieldCreated   = "created"
+	ReservationsFieldUpdated   = "updated"
+)
+
+type Reservation struct {
+	core.BaseRecordProxy
+}
+
+func NewReservation(record *core.Record) *Reservation {
+	reservation := new(Reservation)
+	reservation.SetProxyRecord(record)
+	return reservation
+}
+
+func NewReservationFromCollection(collection *core.Collection) *Reservation {
+	record := core.NewRecord(collection)
+	return NewReservation(record)
+}
+
+func (reservation *Reservation) RewardId() string {
+	return reservation.GetString(ReservationsFieldRewardId)
+}
+
+func (reservation *Reservation) SetRewardId(value string) {
+	reservation.Set(ReservationsFieldRewardId, value)
+}
+
+func (reservation *Reservation) HistoryId() string {
+	return reservation.GetString(ReservationsFieldHistoryId)
+}
+
+func (reservation *Reservation) SetHistoryId(value string) {
+	reservation.Set(ReservationsFieldHistoryId, value)
+}
+
+func (reservation *Reservation) Status() ReservationStatus {
+	return MustParseReservationStatus(reservation.GetString(ReservationsFieldStatus))
+}
+
+func (reservation *Reservation) SetStatus(value ReservationStatus) {
+	reservation.Set(ReservationsFieldStatus, value)
+}
+
+func (reservation *Reservation) Reason() string {
+	return reservation.GetString(ReservationsFieldReason)
+}
+
+func (reservation *Reservation) SetReason(value string) {
+	reservation.Set(ReservationsFieldReason, value)
+}
+
+func (reservation *Reservation) Created() types.DateTime {
+	return reservation.GetDateTime(ReservationsFieldCreated)
+}
+
+func (reservation *Reservation) Updated() types.DateTime {
+	return reservation.GetDateTime(ReservationsFieldUpdated)
+}
+
+const (
+	DbNameRewardCodes             = "reward_codes"
+	RewardCodesFieldRewardId      = "rewardId"
+	RewardCodesFieldCode          = "code"
+	RewardCodesFieldFulfillmentId = "fulfillmentId"
+	RewardCodesFieldCreated       = "created"
+	RewardCodesFieldUpdated       = "updated"
+)
+
+type RewardCode struct {
+	core.BaseRecordProxy
+}
+
+func NewRewardCode(record *core.Record) *RewardCode {
+	rewardCode := new(RewardCode)
+	rewardCode.SetProxyRecord(record)
+	return rewardCode
+}
+
+func NewRewardCodeFromCollection(collection *core.Collection) *RewardCode {
+	record := core.NewRecord(collection)
+	return NewRewardCode(record)
+}
+
+func (rewardCode *RewardCode) RewardId() string {
+	return rewardCode.GetString(RewardCodesFieldRewardId)
+}
+
+func (rewardCode *RewardCode) SetRewardId(value string) {
+	rewardCode.Set(RewardCodesFieldRewardId, value)
+}
+
+func (rewardCode *RewardCode) Code() string {
+	return rewardCode.GetString(RewardCodesFieldCode)
+}
+
+func (rewardCode *RewardCode) SetCode(value string) {
+	rewardCode.Set(RewardCodesFieldCode, value)
+}
+
+func (rewardCode *RewardCode) FulfillmentId() string {
+	return rewardCode.GetString(RewardCodesFieldFulfillmentId)
+}
+
+func (rewardCode *RewardCode) SetFulfillmentId(value string) {
+	rewardCode.Set(RewardCodesFieldFulfillmentId, value)
+}
+
+func (rewardCode *RewardCode) Created() types.DateTime {
+	return rewardCode.GetDateTime(RewardCodesFieldCreated)
+}
+
+func (rewardCode *RewardCode) Updated() types.DateTime {
+	return rewardCode.GetDateTime(RewardCodesFieldUpdated)
+}
+
+const (
+	DbNameFulfillments           = "fulfillments"
+	FulfillmentsFieldHistoryId   = "historyId"
+	FulfillmentsFieldUserId      = "userId"
+	FulfillmentsFieldRewardId    = "rewardId"
+	FulfillmentsFieldKind        = "kind"
+	FulfillmentsFieldStatus      = "status"
+	FulfillmentsFieldCodeId      = "codeId"
+	FulfillmentsFieldAddress     = "address"
+	FulfillmentsFieldTrackingNo  = "trackingNo"
+	FulfillmentsFieldMessage     = "message"
+	FulfillmentsFieldClaimedAt   = "claimedAt"
+	FulfillmentsFieldShippedAt   = "shippedAt"
+	FulfillmentsFieldDeliveredAt = "deliveredAt"
+	FulfillmentsFieldCreated     = "created"
+	FulfillmentsFieldUpdated     = "updated"
+)
+
+type Fulfillment struct {
+	core.BaseRecordProxy
+}
+
+func NewFulfillment(record *core.Record) *Fulfillment {
+	fulfillment := new(Fulfillment)
+	fulfillment.SetProxyRecord(record)
+	return fulfillment
+}
+
+func NewFulfillmentFromCollection(collection *core.Collection) *Fulfillment {
+	record := core.NewRecord(collection)
+	return NewFulfillment(record)
+}
+
+func (fulfillment *Fulfillment) HistoryId() string {
+	return fulfillment.GetString(FulfillmentsFieldHistoryId)
+}
+
+func (fulfillment *Fulfillment) SetHistoryId(value string) {
+	fulfillment.Set(FulfillmentsFieldHistoryId, value)
+}
+
+func (fulfillment *Fulfillment) UserId() string {
+	return fulfillment.GetString(FulfillmentsFieldUserId)
+}
+
+func (fulfillment *Fulfillment) SetUserId(value string) {
+	fulfillment.Set(FulfillmentsFieldUserId, value)
+}
+
+func (fulfillment *Fulfillment) RewardId() string {
+	return fulfillment.GetString(FulfillmentsFieldRewardId)
+}
+
+func (fulfillment *Fulfillment) SetRewardId(value string) {
+	fulfillment.Set(FulfillmentsFieldRewardId, value)
+}
+
+func (fulfillment *Fulfillment) Kind() RewardKind {
+	return MustParseRewardKind(fulfillment.GetString(FulfillmentsFieldKind))
+}
+
+func (fulfillment *Fulfillment) SetKind(value RewardKind) {
+	fulfillment.Set(FulfillmentsFieldKind, value)
+}
+
+func (fulfillment *Fulfillment) Status() FulfillmentStatus {
+	return MustParseFulfillmentStatus(fulfillment.GetString(FulfillmentsFieldStatus))
+}
+
+func (fulfillment *Fulfillment) SetStatus(value FulfillmentStatus) {
+	fulfillment.Set(FulfillmentsFieldStatus, value)
+}
+
+func (fulfillment *Fulfillment) CodeId() string {
+	return fulfillment.GetString(FulfillmentsFieldCodeId)
+}
+
+func (fulfillment *Fulfillment) SetCodeId(value string) {
+	fulfillment.Set(FulfillmentsFieldCodeId, value)
+}
+
+func (fulfillment *Fulfillment) Address() ShippingAddress {
+	address := ShippingAddress{}
+	_ = fulfillment.UnmarshalJSONField(FulfillmentsFieldAddress, &address)
+	return address
+}
+
+func (fulfillment *Fulfillment) SetAddress(value ShippingAddress) {
+	fulfillment.Set(FulfillmentsFieldAddress, value)
+}
+
+func (fulfillment *Fulfillment) TrackingNo() string {
+	return fulfillment.GetString(FulfillmentsFieldTrackingNo)
+}
+
+func (fulfillment *Fulfillment) SetTrackingNo(value string) {
+	fulfillment.Set(FulfillmentsFieldTrackingNo, value)
+}
+
+func (fulfillment *Fulfillment) Message() string {
+	return fulfillment.GetString(FulfillmentsFieldMessage)
+}
+
+func (fulfillment *Fulfillment) SetMessage(value string) {
+	fulfillment.Set(FulfillmentsFieldMessage, value)
+}
+
+func (fulfillment *Fulfillment) ClaimedAt() types.DateTime {
+	return fulfillment.GetDateTime(FulfillmentsFieldClaimedAt)
+}
+
+func (fulfillment *Fulfillment) SetClaimedAt(value types.DateTime) {
+	fulfillment.Set(FulfillmentsFieldClaimedAt, value)
+}
+
+func (fulfillment *Fulfillment) ShippedAt() types.DateTime {
+	return fulfillment.GetDateTime(FulfillmentsFieldShippedAt)
+}
+
+func (fulfillment *Fulfillment) SetShippedAt(value types.DateTime) {
+	fulfillment.Set(FulfillmentsFieldShippedAt, value)
+}
+
+func (fulfillment *Fulfillment) DeliveredAt() types.DateTime {
+	return fulfillment.GetDateTime(FulfillmentsFieldDeliveredAt)
+}
+
+func (fulfillment *Fulfillment) SetDeliveredAt(value types.DateTime) {
+	fulfillment.Set(FulfillmentsFieldDeliveredAt, value)
+}
+
+func (fulfillment *Fulfillment) Created() types.DateTime {
+	return fulfillment.GetDateTime(FulfillmentsFieldCreated)
+}
+
+func (fulfillment *Fulfillment) Updated() types.DateTime {
+	return fulfillment.GetDateTime(FulfillmentsFieldUpdated)
+}
+
+const (
+	DbNameExports           = "exports"
+	ExportsFieldActorId     = "actorId"
+	ExportsFieldSuperuserId = "superuserId"
+	ExportsFieldDataset     = "dataset"
+	ExportsFieldFormat      = "format"
+	ExportsFieldFilters     = "filters"
+	ExportsFieldColumns     = "columns"
+	ExportsFieldStatus      = "status"
+	ExportsFieldRows        = "rows"
+	ExportsFieldFile        = "file"
+	ExportsFieldError       = "error"
+	ExportsFieldFinishedAt  = "finishedAt"
+	ExportsFieldCreated     = "created"
+	ExportsFieldUpdated     = "updated"
+)
+
+type Export struct {
+	core.BaseRecordProxy
+}
+
+func NewExport(record *core.Record) *Export {
+	export := new(Export)
+	export.SetProxyRecord(record)
+	return export
+}
+
+func NewExportFromCollection(collection *core.Collection) *Export {
+	record := core.NewRecord(collection)
+	return NewExport(record)
+}
+
+func (export *Export) ActorId() string {
+	return export.GetString(ExportsFieldActorId)
+}
+
+func (export *Export) SetActorId(value string) {
+	export.Set(ExportsFieldActorId, value)
+}
+
+func (export *Export) SuperuserId() string {
+	return export.GetString(ExportsFieldSuperuserId)
+}
+
+func (export *Export) SetSuperuserId(value string) {
+	export.Set(ExportsFieldSuperuserId, value)
+}
+
+func (export *Export) Dataset() ExportDataset {
+	return MustParseExportDataset(export.GetString(ExportsFieldDataset))
+}
+
+func (export *Export) SetDataset(value ExportDataset) {
+	export.Set(ExportsFieldDataset, value.String())
+}
+
+func (export *Export) Format() ExportFormat {
+	return MustParseExportFormat(export.GetString(ExportsFieldFormat))
+}
+
+func (export *Export) SetFormat(value ExportFormat) {
+	export.Set(ExportsFieldFormat, value.String())
+}
+
+func (export *Export) Filters() map[string]string {
+	filters := map[string]string{}
+	_ = export.UnmarshalJSONField(ExportsFieldFilters, &filters)
+	return filters
+}
+
+func (export *Export) SetFilters(value map[string]string) {
+	export.Set(ExportsFieldFilters, value)
+}
+
+func (export *Export) Columns() []string {
+	columns := []string{}
+	_ = export.UnmarshalJSONField(ExportsFieldColumns, &columns)
+	return columns
+}
+
+func (export *Export) SetColumns(value []string) {
+	export.Set(ExportsFieldColumns, value)
+}
+
+func (export *Export) Status() ExportStatus {
+	return MustParseExportStatus(export.GetString(ExportsFieldStatus))
+}
+
+func (export *Export) SetStatus(value ExportStatus) {
+	export.Set(ExportsFieldStatus, value.String())
+}
+
+func (export *Export) Rows() int {
+	return export.GetInt(ExportsFieldRows)
+}
+
+func (export *Export) SetRows(value int) {
+	export.Set(ExportsFieldRows, value)
+}
+
+func (export *Export) File() string {
+	return export.GetString(ExportsFieldFile)
+}
+
+func (export *Export) Error() string {
+	return export.GetString(ExportsFieldError)
+}
+
+func (export *Export) SetError(value string) {
+	export.Set(ExportsFieldError, value)
+}
+
+func (export *Export) FinishedAt() types.DateTime {
+	return export.GetDateTime(ExportsFieldFinishedAt)
+}
+
+func (export *Export) SetFinishedAt(value types.DateTime) {
+	export.Set(ExportsFieldFinishedAt, value)
+}
+
+func (export *Export) Created() types.DateTime {
+	return export.GetDateTime(ExportsFieldCreated)
+}
+
+func (export *Export) Updated() types.DateTime {
+	return export.GetDateTime(ExportsFieldUpdated)
+}
+
+const (
+	DbNameReports         = "reports"
+	ReportsFieldActorId   = "actorId"
+	ReportsFieldTitle     = "title"
+	ReportsFieldContent   = "content"
+	ReportsFieldTags      = "tags"
+	ReportsFieldArticleId = "articleId"
+	ReportsFieldCreated   = "created"
+	ReportsFieldUpdated   = "updated"
+)
+
+type Report struct {
+	core.BaseRecordProxy
+}
+
+func NewReport(record *core.Record) *Report {
+	report := new(Report)
+	report.SetProxyRecord(record)
+	return report
+}
+
+func NewReportFromCollection(collection *core.Collection) *Report {
+	record := core.NewRecord(collection)
+	return NewReport(record)
+}
+
+func (report *Report) ActorId() string {
+	return report.GetString(ReportsFieldActorId)
+}
+
+func (report *Report) SetActorId(value string) {
+	report.Set(ReportsFieldActorId, value)
+}
+
+func (report *Report) Title() string {
+	return report.GetString(ReportsFieldTitle)
+}
+
+func (report *Report) SetTitle(value string) {
+	report.Set(ReportsFieldTitle, value)
+}
+
+func (report *Report) Content() string {
+	return report.GetString(ReportsFieldContent)
+}
+
+func (report *Report) SetContent(value string) {
+	report.Set(ReportsFieldContent, value)
+}
+
+func (report *Report) Tags() string {
+	return report.GetString(ReportsFieldTags)
+}
+
+func (report *Report) SetTags(value string) {
+	report.Set(ReportsFieldTags, value)
+}
+
+func (report *Report) ArticleId() string {
+	return report.GetString(ReportsFieldArticleId)
+}
+
+func (report *Report) SetArticleId(value string) {
+	report.Set(ReportsFieldArticleId, value)
+}
+
+func (report *Report) Created() types.DateTime {
+	return report.GetDateTime(ReportsFieldCreated)
+}
+
+func (report *Report) Updated() types.DateTime {
+	return report.GetDateTime(ReportsFieldUpdated)
+}
+
+const (
+	DbNameThanks          = "thanks"
+	ThanksFieldArticleId  = "articleId"
+	ThanksFieldOId        = "oId"
+	ThanksFieldKind       = "kind"
+	ThanksFieldFromOId    = "fromOId"
+	ThanksFieldFromName   = "fromName"
+	ThanksFieldFromUserId = "fromUserId"
+	ThanksFieldPoint      = "point"
+	ThanksFieldThankedAt  = "thankedAt"
+	ThanksFieldCreated    = "created"
+	ThanksFieldUpdated    = "updated"
+)
+
+type Thank struct {
+	core.BaseRecordProxy
+}
+
+func NewThank(record *core.Record) *Thank {
+	thank := new(Thank)
+	thank.SetProxyRecord(record)
+	return thank
+}
+
+func NewThankFromCollection(collection *core.Collection) *Thank {
+	record := core.NewRecord(collection)
+	return NewThank(record)
+}
+
+func (thank *Thank) ArticleId() string {
+	return thank.GetString(ThanksFieldArticleId)
+}
+
+func (thank *Thank) SetArticleId(value string) {
+	thank.Set(ThanksFieldArticleId, value)
+}
+
+func (thank *Thank) OId() string {
+	return thank.GetString(ThanksFieldOId)
+}
+
+func (thank *Thank) SetOId(value string) {
+	thank.Set(ThanksFieldOId, value)
+}
+
+func (thank *Thank) Kind() ThankKind {
+	return MustParseThankKind(thank.GetString(ThanksFieldKind))
+}
+
+func (thank *Thank) SetKind(value ThankKind) {
+	thank.Set(ThanksFieldKind, value.String())
+}
+
+func (thank *Thank) FromOId() string {
+	return thank.GetString(ThanksFieldFromOId)
+}
+
+func (thank *Thank) SetFromOId(value string) {
+	thank.Set(ThanksFieldFromOId, value)
+}
+
+func (thank *Thank) FromName() string {
+	return thank.GetString(ThanksFieldFromName)
+}
+
+func (thank *Thank) SetFromName(value string) {
+	thank.Set(ThanksFieldFromName, value)
+}
+
+func (thank *Thank) FromUserId() string {
+	return thank.GetString(ThanksFieldFromUserId)
+}
+
+func (thank *Thank) SetFromUserId(value string) {
+	thank.Set(ThanksFieldFromUserId, value)
+}
+
+func (thank *Thank) Point() int {
+	return thank.GetInt(ThanksFieldPoint)
+}
+
+func (thank *Thank) SetPoint(value int) {
+	thank.Set(ThanksFieldPoint, value)
+}
+
+func (thank *Thank) ThankedAt() types.DateTime {
+	return thank.GetDateTime(ThanksFieldThankedAt)
+}
+
+func (thank *Thank) SetThankedAt(value types.DateTime) {
+	thank.Set(ThanksFieldThankedAt, value)
+}
+
+func (thank *Thank) Created() types.DateTime {
+	return thank.GetDateTime(ThanksFieldCreated)
+}
+
+func (thank *Thank) Updated() types.DateTime {
+	return thank.GetDateTime(ThanksFieldUpdated)
+}
+
+const (
+	DbNameCheckins       = "checkins"
+	CheckinsFieldUserId  = "userId"
+	CheckinsFieldDay     = "day"
+	CheckinsFieldTimes   = "times"
+	CheckinsFieldCreated = "created"
+	CheckinsFieldUpdated = "updated"
+)
+
+type Checkin struct {
+	core.BaseRecordProxy
+}
+
+func NewCheckin(record *core.Record) *Checkin {
+	checkin := new(Checkin)
+	checkin.SetProxyRecord(record)
+	return checkin
+}
+
+func NewCheckinFromCollection(collection *core.Collection) *Checkin {
+	record := core.NewRecord(collection)
+	return NewCheckin(record)
+}
+
+func (checkin *Checkin) UserId() string {
+	return checkin.GetString(CheckinsFieldUserId)
+}
+
+func (checkin *Checkin) SetUserId(value string) {
+	checkin.Set(CheckinsFieldUserId, value)
+}
+
+// Day 签到日期，格式为 2006-01-02
+func (checkin *Checkin) Day() string {
+	return checkin.GetString(CheckinsFieldDay)
+}
+
+func (checkin *Checkin) SetDay(value string) {
+	checkin.Set(CheckinsFieldDay, value)
+}
+
+func (checkin *Checkin) Times() int {
+	return checkin.GetInt(CheckinsFieldTimes)
+}
+
+func (checkin *Checkin) SetTimes(value int) {
+	checkin.Set(CheckinsFieldTimes, value)
+}
+
+func (checkin *Checkin) Created() types.DateTime {
+	return checkin.GetDateTime(CheckinsFieldCreated)
+}
+
+func (checkin *Checkin) Updated() types.DateTime {
+	return checkin.GetDateTime(CheckinsFieldUpdated)
+}
+
+const (
+	DbNameDrawGrants       = "draw_grants"
+	DrawGrantsFieldUserId  = "userId"
+	DrawGrantsFieldActorId = "actorId"
+	DrawGrantsFieldTimes   = "times"
+	DrawGrantsFieldReason  = "reason"
+	DrawGrantsFieldCreated = "created"
+	DrawGrantsFieldUpdated = "updated"
+)
+
+type DrawGrant struct {
+	core.BaseRecordProxy
+}
+
+func NewDrawGrant(record *core.Record) *DrawGrant {
+	grant := new(DrawGrant)
+	grant.SetProxyRecord(record)
+	return grant
+}
+
+func NewDrawGrantFromCollection(collection *core.Collection) *DrawGrant {
+	record := core.NewRecord(collection)
+	return NewDrawGrant(record)
+}
+
+func (grant *DrawGrant) UserId() string {
+	return grant.GetString(DrawGrantsFieldUserId)
+}
+
+func (grant *DrawGrant) SetUserId(value string) {
+	grant.Set(DrawGrantsFieldUserId, value)
+}
+
+func (grant *DrawGrant) ActorId() string {
+	return grant.GetString(DrawGrantsFieldActorId)
+}
+
+func (grant *DrawGrant) SetActorId(value string) {
+	grant.Set(DrawGrantsFieldActorId, value)
+}
+
+// Times 补发的次数，为负数时表示扣回
+func (grant *DrawGrant) Times() int {
+	return grant.GetInt(DrawGrantsFieldTimes)
+}
+
+func (grant *DrawGrant) SetTimes(value int) {
+	grant.Set(DrawGrantsFieldTimes, value)
+}
+
+func (grant *DrawGrant) Reason() string {
+	return grant.GetString(DrawGrantsFieldReason)
+}
+
+func (grant *DrawGrant) SetReason(value string) {
+	grant.Set(DrawGrantsFieldReason, value)
+}
+
+func (grant *DrawGrant) Created() types.DateTime {
+	return grant.GetDateTime(DrawGrantsFieldCreated)
+}
+
+func (grant *DrawGrant) Updated() types.DateTime {
+	return grant.GetDateTime(DrawGrantsFieldUpdated)
+}
+
+const (
+	DbNameOpenidNonces        = "openid_nonces"
+	OpenidNoncesFieldEndpoint = "endpoint"
+	OpenidNoncesFieldNonce    = "nonce"
+	OpenidNoncesFieldIssuedAt = "issuedAt"
+	OpenidNoncesFieldCreated  = "created"
+	OpenidNoncesFieldUpdated  = "updated"
+)
+
+type OpenidNonce struct {
+	core.BaseRecordProxy
+}
+
+func NewOpenidNonce(record *core.Record) *OpenidNonce {
+	nonce := new(OpenidNonce)
+	nonce.SetProxyRecord(record)
+	return nonce
+}
+
+func NewOpenidNonceFromCollection(collection *core.Collection) *OpenidNonce {
+	record := core.NewRecord(collection)
+	return NewOpenidNonce(record)
+}
+
+func (nonce *OpenidNonce) Endpoint() string {
+	return nonce.GetString(OpenidNoncesFieldEndpoint)
+}
+
+func (nonce *OpenidNonce) SetEndpoint(value string) {
+	nonce.Set(OpenidNoncesFieldEndpoint, value)
+}
+
+func (nonce *OpenidNonce) Nonce() string {
+	return nonce.GetString(OpenidNoncesFieldNonce)
+}
+
+func (nonce *OpenidNonce) SetNonce(value string) {
+	nonce.Set(OpenidNoncesFieldNonce, value)
+}
+
+func (nonce *OpenidNonce) IssuedAt() types.DateTime {
+	return nonce.GetDateTime(OpenidNoncesFieldIssuedAt)
+}
+
+func (nonce *OpenidNonce) SetIssuedAt(value types.DateTime) {
+	nonce.Set(OpenidNoncesFieldIssuedAt, value)
+}
+
+func (nonce *OpenidNonce) Created() types.DateTime {
+	return nonce.GetDateTime(OpenidNoncesFieldCreated)
+}
+
+func (nonce *OpenidNonce) Updated() types.DateTime {
+	return nonce.GetDateTime(OpenidNoncesFieldUpdated)
+}
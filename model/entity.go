@@ -10,3 +10,18 @@ const (
 	VoteTypeRomance = "romance" // 姻缘符
 	VoteTypeWealth  = "wealth"  // 招财符
 )
+
+// ShippingAddress 实物奖励的收货信息
+type ShippingAddress struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+}
+
+// Medal 摸鱼派勋章或头衔，对应金手指发放勋章接口的参数
+type Medal struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Attr        string `json:"attr"`
+	Data        string `json:"data"`
+}
@@ -4,7 +4,14 @@ package model
 // ConfigKey
 /*
 ENUM(
-fishpi // 摸鱼派
+fishpi    // 摸鱼派
+ratelimit // 限流规则
+activity  // 活动时间
+champion  // 状元模式
+guard     // 支出熔断
+paid_draw // 付费博饼
+report    // 活动报告
+quota     // 博饼次数
 )
 */
 type ConfigKey string
@@ -18,3 +25,150 @@ failed  // 发放失败
 )
 */
 type PointStatus string
+
+// ThrottleScope
+/*
+ENUM(
+user // 按用户限流
+ip   // 按 IP 限流
+)
+*/
+type ThrottleScope string
+
+// UserRole
+/*
+ENUM(
+player    // 玩家
+moderator // 审核员，可审核被标记的文章与投票
+operator  // 运营，可执行发放任务、调整奖品库存与活动时间
+superuser // PocketBase 超级管理员，可导出活动数据，不属于 users 集合
+)
+*/
+type UserRole string
+
+// ActivityPhase
+/*
+ENUM(
+preheat    // 预热
+voting     // 投票
+drawing    // 博饼
+settlement // 结算
+archive    // 归档
+)
+*/
+type ActivityPhase string
+
+// PhaseHookStatus
+/*
+ENUM(
+success // 执行成功
+failed  // 执行失败
+)
+*/
+type PhaseHookStatus string
+
+// TableStatus
+/*
+ENUM(
+waiting   // 等待玩家加入
+playing   // 进行中
+finished  // 已结算
+cancelled // 已解散
+)
+*/
+type TableStatus string
+
+// ReservationStatus
+/*
+ENUM(
+held     // 已预留
+released // 已释放
+)
+*/
+type ReservationStatus string
+
+// AwardCondition
+/*
+ENUM(
+none      // 不限制
+first_win // 仅限首次获奖的用户
+)
+*/
+type AwardCondition string
+
+// RewardKind
+/*
+ENUM(
+points   // 积分
+code     // 兑换码
+physical // 实物
+medal    // 摸鱼派勋章或头衔
+)
+*/
+type RewardKind string
+
+// FulfillmentStatus
+/*
+ENUM(
+allocated // 待领取
+claimed   // 已领取
+shipped   // 已发货
+delivered // 已送达
+failed    // 发放失败
+)
+*/
+type FulfillmentStatus string
+
+// ExportDataset
+/*
+ENUM(
+histories  // 博饼记录
+votes      // 福签记录
+points     // 积分订单
+articles   // 文章排名
+settlement // 状元结算获奖名单
+)
+*/
+type ExportDataset string
+
+// ExportFormat
+/*
+ENUM(
+csv   // CSV
+xlsx  // Excel
+jsonl // JSON Lines
+)
+*/
+type ExportFormat string
+
+// ExportStatus
+/*
+ENUM(
+pending // 排队中
+running // 导出中
+success // 已完成
+failed  // 失败
+)
+*/
+type ExportStatus string
+
+// ThankKind
+/*
+ENUM(
+thank  // 感谢
+reward // 打赏
+)
+*/
+type ThankKind string
+
+// QuotaSource
+/*
+ENUM(
+base    // 基础次数
+thanks  // 文章感谢
+votes   // 收到福签
+checkin // 每日签到
+grant   // 管理员补发
+)
+*/
+type QuotaSource string
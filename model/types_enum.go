@@ -11,16 +11,246 @@ import (
 	"strings"
 )
 
+const (
+	// ActivityPhasePreheat is a ActivityPhase of type preheat.
+	// 预热
+	ActivityPhasePreheat ActivityPhase = "preheat"
+	// ActivityPhaseVoting is a ActivityPhase of type voting.
+	// 投票
+	ActivityPhaseVoting ActivityPhase = "voting"
+	// ActivityPhaseDrawing is a ActivityPhase of type drawing.
+	// 博饼
+	ActivityPhaseDrawing ActivityPhase = "drawing"
+	// ActivityPhaseSettlement is a ActivityPhase of type settlement.
+	// 结算
+	ActivityPhaseSettlement ActivityPhase = "settlement"
+	// ActivityPhaseArchive is a ActivityPhase of type archive.
+	// 归档
+	ActivityPhaseArchive ActivityPhase = "archive"
+)
+
+var ErrInvalidActivityPhase = fmt.Errorf("not a valid ActivityPhase, try [%s]", strings.Join(_ActivityPhaseNames, ", "))
+
+var _ActivityPhaseNames = []string{
+	string(ActivityPhasePreheat),
+	string(ActivityPhaseVoting),
+	string(ActivityPhaseDrawing),
+	string(ActivityPhaseSettlement),
+	string(ActivityPhaseArchive),
+}
+
+// ActivityPhaseNames returns a list of possible string values of ActivityPhase.
+func ActivityPhaseNames() []string {
+	tmp := make([]string, len(_ActivityPhaseNames))
+	copy(tmp, _ActivityPhaseNames)
+	return tmp
+}
+
+// ActivityPhaseValues returns a list of the values for ActivityPhase
+func ActivityPhaseValues() []ActivityPhase {
+	return []ActivityPhase{
+		ActivityPhasePreheat,
+		ActivityPhaseVoting,
+		ActivityPhaseDrawing,
+		ActivityPhaseSettlement,
+		ActivityPhaseArchive,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ActivityPhase) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ActivityPhase) IsValid() bool {
+	_, err := ParseActivityPhase(string(x))
+	return err == nil
+}
+
+var _ActivityPhaseValue = map[string]ActivityPhase{
+	"preheat":    ActivityPhasePreheat,
+	"voting":     ActivityPhaseVoting,
+	"drawing":    ActivityPhaseDrawing,
+	"settlement": ActivityPhaseSettlement,
+	"archive":    ActivityPhaseArchive,
+}
+
+// ParseActivityPhase attempts to convert a string to a ActivityPhase.
+func ParseActivityPhase(name string) (ActivityPhase, error) {
+	if x, ok := _ActivityPhaseValue[name]; ok {
+		return x, nil
+	}
+	return ActivityPhase(""), fmt.Errorf("%s is %w", name, ErrInvalidActivityPhase)
+}
+
+// MustParseActivityPhase converts a string to a ActivityPhase, and panics if is not valid.
+func MustParseActivityPhase(name string) ActivityPhase {
+	val, err := ParseActivityPhase(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ActivityPhase) Ptr() *ActivityPhase {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ActivityPhase) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ActivityPhase) UnmarshalText(text []byte) error {
+	tmp, err := ParseActivityPhase(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ActivityPhase) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// AwardConditionNone is a AwardCondition of type none.
+	// 不限制
+	AwardConditionNone AwardCondition = "none"
+	// AwardConditionFirstWin is a AwardCondition of type first_win.
+	// 仅限首次获奖的用户
+	AwardConditionFirstWin AwardCondition = "first_win"
+)
+
+var ErrInvalidAwardCondition = fmt.Errorf("not a valid AwardCondition, try [%s]", strings.Join(_AwardConditionNames, ", "))
+
+var _AwardConditionNames = []string{
+	string(AwardConditionNone),
+	string(AwardConditionFirstWin),
+}
+
+// AwardConditionNames returns a list of possible string values of AwardCondition.
+func AwardConditionNames() []string {
+	tmp := make([]string, len(_AwardConditionNames))
+	copy(tmp, _AwardConditionNames)
+	return tmp
+}
+
+// AwardConditionValues returns a list of the values for AwardCondition
+func AwardConditionValues() []AwardCondition {
+	return []AwardCondition{
+		AwardConditionNone,
+		AwardConditionFirstWin,
+	}
+}
+
+// String implements the Stringer interface.
+func (x AwardCondition) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x AwardCondition) IsValid() bool {
+	_, err := ParseAwardCondition(string(x))
+	return err == nil
+}
+
+var _AwardConditionValue = map[string]AwardCondition{
+	"none":      AwardConditionNone,
+	"first_win": AwardConditionFirstWin,
+}
+
+// ParseAwardCondition attempts to convert a string to a AwardCondition.
+func ParseAwardCondition(name string) (AwardCondition, error) {
+	if x, ok := _AwardConditionValue[name]; ok {
+		return x, nil
+	}
+	return AwardCondition(""), fmt.Errorf("%s is %w", name, ErrInvalidAwardCondition)
+}
+
+// MustParseAwardCondition converts a string to a AwardCondition, and panics if is not valid.
+func MustParseAwardCondition(name string) AwardCondition {
+	val, err := ParseAwardCondition(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x AwardCondition) Ptr() *AwardCondition {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x AwardCondition) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *AwardCondition) UnmarshalText(text []byte) error {
+	tmp, err := ParseAwardCondition(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *AwardCondition) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
 const (
 	// ConfigKeyFishpi is a ConfigKey of type fishpi.
 	// 摸鱼派
 	ConfigKeyFishpi ConfigKey = "fishpi"
+	// ConfigKeyRatelimit is a ConfigKey of type ratelimit.
+	// 限流规则
+	ConfigKeyRatelimit ConfigKey = "ratelimit"
+	// ConfigKeyActivity is a ConfigKey of type activity.
+	// 活动时间
+	ConfigKeyActivity ConfigKey = "activity"
+	// ConfigKeyChampion is a ConfigKey of type champion.
+	// 状元模式
+	ConfigKeyChampion ConfigKey = "champion"
+	// ConfigKeyGuard is a ConfigKey of type guard.
+	// 支出熔断
+	ConfigKeyGuard ConfigKey = "guard"
+	// ConfigKeyPaidDraw is a ConfigKey of type paid_draw.
+	// 付费博饼
+	ConfigKeyPaidDraw ConfigKey = "paid_draw"
+	// ConfigKeyReport is a ConfigKey of type report.
+	// 活动报告
+	ConfigKeyReport ConfigKey = "report"
+	// ConfigKeyQuota is a ConfigKey of type quota.
+	// 博饼次数
+	ConfigKeyQuota ConfigKey = "quota"
 )
 
 var ErrInvalidConfigKey = fmt.Errorf("not a valid ConfigKey, try [%s]", strings.Join(_ConfigKeyNames, ", "))
 
 var _ConfigKeyNames = []string{
 	string(ConfigKeyFishpi),
+	string(ConfigKeyRatelimit),
+	string(ConfigKeyActivity),
+	string(ConfigKeyChampion),
+	string(ConfigKeyGuard),
+	string(ConfigKeyPaidDraw),
+	string(ConfigKeyReport),
+	string(ConfigKeyQuota),
 }
 
 // ConfigKeyNames returns a list of possible string values of ConfigKey.
@@ -34,6 +264,13 @@ func ConfigKeyNames() []string {
 func ConfigKeyValues() []ConfigKey {
 	return []ConfigKey{
 		ConfigKeyFishpi,
+		ConfigKeyRatelimit,
+		ConfigKeyActivity,
+		ConfigKeyChampion,
+		ConfigKeyGuard,
+		ConfigKeyPaidDraw,
+		ConfigKeyReport,
+		ConfigKeyQuota,
 	}
 }
 
@@ -50,7 +287,14 @@ func (x ConfigKey) IsValid() bool {
 }
 
 var _ConfigKeyValue = map[string]ConfigKey{
-	"fishpi": ConfigKeyFishpi,
+	"fishpi":    ConfigKeyFishpi,
+	"ratelimit": ConfigKeyRatelimit,
+	"activity":  ConfigKeyActivity,
+	"champion":  ConfigKeyChampion,
+	"guard":     ConfigKeyGuard,
+	"paid_draw": ConfigKeyPaidDraw,
+	"report":    ConfigKeyReport,
+	"quota":     ConfigKeyQuota,
 }
 
 // ParseConfigKey attempts to convert a string to a ConfigKey.
@@ -89,92 +333,1320 @@ func (x *ConfigKey) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ConfigKey) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
 const (
-	// PointStatusPending is a PointStatus of type pending.
-	// 待发放
-	PointStatusPending PointStatus = "pending"
-	// PointStatusSuccess is a PointStatus of type success.
-	// 发放成功
-	PointStatusSuccess PointStatus = "success"
-	// PointStatusFailed is a PointStatus of type failed.
-	// 发放失败
-	PointStatusFailed PointStatus = "failed"
+	// ExportDatasetHistories is a ExportDataset of type histories.
+	// 博饼记录
+	ExportDatasetHistories ExportDataset = "histories"
+	// ExportDatasetVotes is a ExportDataset of type votes.
+	// 福签记录
+	ExportDatasetVotes ExportDataset = "votes"
+	// ExportDatasetPoints is a ExportDataset of type points.
+	// 积分订单
+	ExportDatasetPoints ExportDataset = "points"
+	// ExportDatasetArticles is a ExportDataset of type articles.
+	// 文章排名
+	ExportDatasetArticles ExportDataset = "articles"
+	// ExportDatasetSettlement is a ExportDataset of type settlement.
+	// 状元结算获奖名单
+	ExportDatasetSettlement ExportDataset = "settlement"
 )
 
-var ErrInvalidPointStatus = fmt.Errorf("not a valid PointStatus, try [%s]", strings.Join(_PointStatusNames, ", "))
+var ErrInvalidExportDataset = fmt.Errorf("not a valid ExportDataset, try [%s]", strings.Join(_ExportDatasetNames, ", "))
 
-var _PointStatusNames = []string{
-	string(PointStatusPending),
-	string(PointStatusSuccess),
-	string(PointStatusFailed),
+var _ExportDatasetNames = []string{
+	string(ExportDatasetHistories),
+	string(ExportDatasetVotes),
+	string(ExportDatasetPoints),
+	string(ExportDatasetArticles),
+	string(ExportDatasetSettlement),
 }
 
-// PointStatusNames returns a list of possible string values of PointStatus.
-func PointStatusNames() []string {
-	tmp := make([]string, len(_PointStatusNames))
-	copy(tmp, _PointStatusNames)
+// ExportDatasetNames returns a list of possible string values of ExportDataset.
+func ExportDatasetNames() []string {
+	tmp := make([]string, len(_ExportDatasetNames))
+	copy(tmp, _ExportDatasetNames)
 	return tmp
 }
 
-// PointStatusValues returns a list of the values for PointStatus
-func PointStatusValues() []PointStatus {
-	return []PointStatus{
-		PointStatusPending,
-		PointStatusSuccess,
-		PointStatusFailed,
+// ExportDatasetValues returns a list of the values for ExportDataset
+func ExportDatasetValues() []ExportDataset {
+	return []ExportDataset{
+		ExportDatasetHistories,
+		ExportDatasetVotes,
+		ExportDatasetPoints,
+		ExportDatasetArticles,
+		ExportDatasetSettlement,
 	}
 }
 
 // String implements the Stringer interface.
-func (x PointStatus) String() string {
+func (x ExportDataset) String() string {
 	return string(x)
 }
 
 // IsValid provides a quick way to determine if the typed value is
 // part of the allowed enumerated values
-func (x PointStatus) IsValid() bool {
-	_, err := ParsePointStatus(string(x))
+func (x ExportDataset) IsValid() bool {
+	_, err := ParseExportDataset(string(x))
 	return err == nil
 }
 
-var _PointStatusValue = map[string]PointStatus{
-	"pending": PointStatusPending,
-	"success": PointStatusSuccess,
-	"failed":  PointStatusFailed,
+var _ExportDatasetValue = map[string]ExportDataset{
+	"histories":  ExportDatasetHistories,
+	"votes":      ExportDatasetVotes,
+	"points":     ExportDatasetPoints,
+	"articles":   ExportDatasetArticles,
+	"settlement": ExportDatasetSettlement,
 }
 
-// ParsePointStatus attempts to convert a string to a PointStatus.
-func ParsePointStatus(name string) (PointStatus, error) {
-	if x, ok := _PointStatusValue[name]; ok {
+// ParseExportDataset attempts to convert a string to a ExportDataset.
+func ParseExportDataset(name string) (ExportDataset, error) {
+	if x, ok := _ExportDatasetValue[name]; ok {
 		return x, nil
 	}
-	return PointStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidPointStatus)
+	return ExportDataset(""), fmt.Errorf("%s is %w", name, ErrInvalidExportDataset)
 }
 
-// MustParsePointStatus converts a string to a PointStatus, and panics if is not valid.
-func MustParsePointStatus(name string) PointStatus {
-	val, err := ParsePointStatus(name)
+// MustParseExportDataset converts a string to a ExportDataset, and panics if is not valid.
+func MustParseExportDataset(name string) ExportDataset {
+	val, err := ParseExportDataset(name)
 	if err != nil {
 		panic(err)
 	}
 	return val
 }
 
-func (x PointStatus) Ptr() *PointStatus {
+func (x ExportDataset) Ptr() *ExportDataset {
 	return &x
 }
 
 // MarshalText implements the text marshaller method.
-func (x PointStatus) MarshalText() ([]byte, error) {
+func (x ExportDataset) MarshalText() ([]byte, error) {
 	return []byte(string(x)), nil
 }
 
 // UnmarshalText implements the text unmarshaller method.
-func (x *PointStatus) UnmarshalText(text []byte) error {
-	tmp, err := ParsePointStatus(string(text))
+func (x *ExportDataset) UnmarshalText(text []byte) error {
+	tmp, err := ParseExportDataset(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ExportDataset) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ExportFormatCsv is a ExportFormat of type csv.
+	// CSV
+	ExportFormatCsv ExportFormat = "csv"
+	// ExportFormatXlsx is a ExportFormat of type xlsx.
+	// Excel
+	ExportFormatXlsx ExportFormat = "xlsx"
+	// ExportFormatJsonl is a ExportFormat of type jsonl.
+	// JSON Lines
+	ExportFormatJsonl ExportFormat = "jsonl"
+)
+
+var ErrInvalidExportFormat = fmt.Errorf("not a valid ExportFormat, try [%s]", strings.Join(_ExportFormatNames, ", "))
+
+var _ExportFormatNames = []string{
+	string(ExportFormatCsv),
+	string(ExportFormatXlsx),
+	string(ExportFormatJsonl),
+}
+
+// ExportFormatNames returns a list of possible string values of ExportFormat.
+func ExportFormatNames() []string {
+	tmp := make([]string, len(_ExportFormatNames))
+	copy(tmp, _ExportFormatNames)
+	return tmp
+}
+
+// ExportFormatValues returns a list of the values for ExportFormat
+func ExportFormatValues() []ExportFormat {
+	return []ExportFormat{
+		ExportFormatCsv,
+		ExportFormatXlsx,
+		ExportFormatJsonl,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ExportFormat) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ExportFormat) IsValid() bool {
+	_, err := ParseExportFormat(string(x))
+	return err == nil
+}
+
+var _ExportFormatValue = map[string]ExportFormat{
+	"csv":   ExportFormatCsv,
+	"xlsx":  ExportFormatXlsx,
+	"jsonl": ExportFormatJsonl,
+}
+
+// ParseExportFormat attempts to convert a string to a ExportFormat.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	if x, ok := _ExportFormatValue[name]; ok {
+		return x, nil
+	}
+	return ExportFormat(""), fmt.Errorf("%s is %w", name, ErrInvalidExportFormat)
+}
+
+// MustParseExportFormat converts a string to a ExportFormat, and panics if is not valid.
+func MustParseExportFormat(name string) ExportFormat {
+	val, err := ParseExportFormat(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ExportFormat) Ptr() *ExportFormat {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ExportFormat) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ExportFormat) UnmarshalText(text []byte) error {
+	tmp, err := ParseExportFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ExportFormat) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ExportStatusPending is a ExportStatus of type pending.
+	// 排队中
+	ExportStatusPending ExportStatus = "pending"
+	// ExportStatusRunning is a ExportStatus of type running.
+	// 导出中
+	ExportStatusRunning ExportStatus = "running"
+	// ExportStatusSuccess is a ExportStatus of type success.
+	// 已完成
+	ExportStatusSuccess ExportStatus = "success"
+	// ExportStatusFailed is a ExportStatus of type failed.
+	// 失败
+	ExportStatusFailed ExportStatus = "failed"
+)
+
+var ErrInvalidExportStatus = fmt.Errorf("not a valid ExportStatus, try [%s]", strings.Join(_ExportStatusNames, ", "))
+
+var _ExportStatusNames = []string{
+	string(ExportStatusPending),
+	string(ExportStatusRunning),
+	string(ExportStatusSuccess),
+	string(ExportStatusFailed),
+}
+
+// ExportStatusNames returns a list of possible string values of ExportStatus.
+func ExportStatusNames() []string {
+	tmp := make([]string, len(_ExportStatusNames))
+	copy(tmp, _ExportStatusNames)
+	return tmp
+}
+
+// ExportStatusValues returns a list of the values for ExportStatus
+func ExportStatusValues() []ExportStatus {
+	return []ExportStatus{
+		ExportStatusPending,
+		ExportStatusRunning,
+		ExportStatusSuccess,
+		ExportStatusFailed,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ExportStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ExportStatus) IsValid() bool {
+	_, err := ParseExportStatus(string(x))
+	return err == nil
+}
+
+var _ExportStatusValue = map[string]ExportStatus{
+	"pending": ExportStatusPending,
+	"running": ExportStatusRunning,
+	"success": ExportStatusSuccess,
+	"failed":  ExportStatusFailed,
+}
+
+// ParseExportStatus attempts to convert a string to a ExportStatus.
+func ParseExportStatus(name string) (ExportStatus, error) {
+	if x, ok := _ExportStatusValue[name]; ok {
+		return x, nil
+	}
+	return ExportStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidExportStatus)
+}
+
+// MustParseExportStatus converts a string to a ExportStatus, and panics if is not valid.
+func MustParseExportStatus(name string) ExportStatus {
+	val, err := ParseExportStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ExportStatus) Ptr() *ExportStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ExportStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ExportStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParseExportStatus(string(text))
 	if err != nil {
 		return err
 	}
 	*x = tmp
 	return nil
 }
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ExportStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// FulfillmentStatusAllocated is a FulfillmentStatus of type allocated.
+	// 待领取
+	FulfillmentStatusAllocated FulfillmentStatus = "allocated"
+	// FulfillmentStatusClaimed is a FulfillmentStatus of type claimed.
+	// 已领取
+	FulfillmentStatusClaimed FulfillmentStatus = "claimed"
+	// FulfillmentStatusShipped is a FulfillmentStatus of type shipped.
+	// 已发货
+	FulfillmentStatusShipped FulfillmentStatus = "shipped"
+	// FulfillmentStatusDelivered is a FulfillmentStatus of type delivered.
+	// 已送达
+	FulfillmentStatusDelivered FulfillmentStatus = "delivered"
+	// FulfillmentStatusFailed is a FulfillmentStatus of type failed.
+	// 发放失败
+	FulfillmentStatusFailed FulfillmentStatus = "failed"
+)
+
+var ErrInvalidFulfillmentStatus = fmt.Errorf("not a valid FulfillmentStatus, try [%s]", strings.Join(_FulfillmentStatusNames, ", "))
+
+var _FulfillmentStatusNames = []string{
+	string(FulfillmentStatusAllocated),
+	string(FulfillmentStatusClaimed),
+	string(FulfillmentStatusShipped),
+	string(FulfillmentStatusDelivered),
+	string(FulfillmentStatusFailed),
+}
+
+// FulfillmentStatusNames returns a list of possible string values of FulfillmentStatus.
+func FulfillmentStatusNames() []string {
+	tmp := make([]string, len(_FulfillmentStatusNames))
+	copy(tmp, _FulfillmentStatusNames)
+	return tmp
+}
+
+// FulfillmentStatusValues returns a list of the values for FulfillmentStatus
+func FulfillmentStatusValues() []FulfillmentStatus {
+	return []FulfillmentStatus{
+		FulfillmentStatusAllocated,
+		FulfillmentStatusClaimed,
+		FulfillmentStatusShipped,
+		FulfillmentStatusDelivered,
+		FulfillmentStatusFailed,
+	}
+}
+
+// String implements the Stringer interface.
+func (x FulfillmentStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x FulfillmentStatus) IsValid() bool {
+	_, err := ParseFulfillmentStatus(string(x))
+	return err == nil
+}
+
+var _FulfillmentStatusValue = map[string]FulfillmentStatus{
+	"allocated": FulfillmentStatusAllocated,
+	"claimed":   FulfillmentStatusClaimed,
+	"shipped":   FulfillmentStatusShipped,
+	"delivered": FulfillmentStatusDelivered,
+	"failed":    FulfillmentStatusFailed,
+}
+
+// ParseFulfillmentStatus attempts to convert a string to a FulfillmentStatus.
+func ParseFulfillmentStatus(name string) (FulfillmentStatus, error) {
+	if x, ok := _FulfillmentStatusValue[name]; ok {
+		return x, nil
+	}
+	return FulfillmentStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidFulfillmentStatus)
+}
+
+// MustParseFulfillmentStatus converts a string to a FulfillmentStatus, and panics if is not valid.
+func MustParseFulfillmentStatus(name string) FulfillmentStatus {
+	val, err := ParseFulfillmentStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x FulfillmentStatus) Ptr() *FulfillmentStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x FulfillmentStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *FulfillmentStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParseFulfillmentStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *FulfillmentStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// PhaseHookStatusSuccess is a PhaseHookStatus of type success.
+	// 执行成功
+	PhaseHookStatusSuccess PhaseHookStatus = "success"
+	// PhaseHookStatusFailed is a PhaseHookStatus of type failed.
+	// 执行失败
+	PhaseHookStatusFailed PhaseHookStatus = "failed"
+)
+
+var ErrInvalidPhaseHookStatus = fmt.Errorf("not a valid PhaseHookStatus, try [%s]", strings.Join(_PhaseHookStatusNames, ", "))
+
+var _PhaseHookStatusNames = []string{
+	string(PhaseHookStatusSuccess),
+	string(PhaseHookStatusFailed),
+}
+
+// PhaseHookStatusNames returns a list of possible string values of PhaseHookStatus.
+func PhaseHookStatusNames() []string {
+	tmp := make([]string, len(_PhaseHookStatusNames))
+	copy(tmp, _PhaseHookStatusNames)
+	return tmp
+}
+
+// PhaseHookStatusValues returns a list of the values for PhaseHookStatus
+func PhaseHookStatusValues() []PhaseHookStatus {
+	return []PhaseHookStatus{
+		PhaseHookStatusSuccess,
+		PhaseHookStatusFailed,
+	}
+}
+
+// String implements the Stringer interface.
+func (x PhaseHookStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x PhaseHookStatus) IsValid() bool {
+	_, err := ParsePhaseHookStatus(string(x))
+	return err == nil
+}
+
+var _PhaseHookStatusValue = map[string]PhaseHookStatus{
+	"success": PhaseHookStatusSuccess,
+	"failed":  PhaseHookStatusFailed,
+}
+
+// ParsePhaseHookStatus attempts to convert a string to a PhaseHookStatus.
+func ParsePhaseHookStatus(name string) (PhaseHookStatus, error) {
+	if x, ok := _PhaseHookStatusValue[name]; ok {
+		return x, nil
+	}
+	return PhaseHookStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidPhaseHookStatus)
+}
+
+// MustParsePhaseHookStatus converts a string to a PhaseHookStatus, and panics if is not valid.
+func MustParsePhaseHookStatus(name string) PhaseHookStatus {
+	val, err := ParsePhaseHookStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x PhaseHookStatus) Ptr() *PhaseHookStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x PhaseHookStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *PhaseHookStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParsePhaseHookStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *PhaseHookStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// PointStatusPending is a PointStatus of type pending.
+	// 待发放
+	PointStatusPending PointStatus = "pending"
+	// PointStatusSuccess is a PointStatus of type success.
+	// 发放成功
+	PointStatusSuccess PointStatus = "success"
+	// PointStatusFailed is a PointStatus of type failed.
+	// 发放失败
+	PointStatusFailed PointStatus = "failed"
+)
+
+var ErrInvalidPointStatus = fmt.Errorf("not a valid PointStatus, try [%s]", strings.Join(_PointStatusNames, ", "))
+
+var _PointStatusNames = []string{
+	string(PointStatusPending),
+	string(PointStatusSuccess),
+	string(PointStatusFailed),
+}
+
+// PointStatusNames returns a list of possible string values of PointStatus.
+func PointStatusNames() []string {
+	tmp := make([]string, len(_PointStatusNames))
+	copy(tmp, _PointStatusNames)
+	return tmp
+}
+
+// PointStatusValues returns a list of the values for PointStatus
+func PointStatusValues() []PointStatus {
+	return []PointStatus{
+		PointStatusPending,
+		PointStatusSuccess,
+		PointStatusFailed,
+	}
+}
+
+// String implements the Stringer interface.
+func (x PointStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x PointStatus) IsValid() bool {
+	_, err := ParsePointStatus(string(x))
+	return err == nil
+}
+
+var _PointStatusValue = map[string]PointStatus{
+	"pending": PointStatusPending,
+	"success": PointStatusSuccess,
+	"failed":  PointStatusFailed,
+}
+
+// ParsePointStatus attempts to convert a string to a PointStatus.
+func ParsePointStatus(name string) (PointStatus, error) {
+	if x, ok := _PointStatusValue[name]; ok {
+		return x, nil
+	}
+	return PointStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidPointStatus)
+}
+
+// MustParsePointStatus converts a string to a PointStatus, and panics if is not valid.
+func MustParsePointStatus(name string) PointStatus {
+	val, err := ParsePointStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x PointStatus) Ptr() *PointStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x PointStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *PointStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParsePointStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *PointStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ReservationStatusHeld is a ReservationStatus of type held.
+	// 已预留
+	ReservationStatusHeld ReservationStatus = "held"
+	// ReservationStatusReleased is a ReservationStatus of type released.
+	// 已释放
+	ReservationStatusReleased ReservationStatus = "released"
+)
+
+var ErrInvalidReservationStatus = fmt.Errorf("not a valid ReservationStatus, try [%s]", strings.Join(_ReservationStatusNames, ", "))
+
+var _ReservationStatusNames = []string{
+	string(ReservationStatusHeld),
+	string(ReservationStatusReleased),
+}
+
+// ReservationStatusNames returns a list of possible string values of ReservationStatus.
+func ReservationStatusNames() []string {
+	tmp := make([]string, len(_ReservationStatusNames))
+	copy(tmp, _ReservationStatusNames)
+	return tmp
+}
+
+// ReservationStatusValues returns a list of the values for ReservationStatus
+func ReservationStatusValues() []ReservationStatus {
+	return []ReservationStatus{
+		ReservationStatusHeld,
+		ReservationStatusReleased,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ReservationStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ReservationStatus) IsValid() bool {
+	_, err := ParseReservationStatus(string(x))
+	return err == nil
+}
+
+var _ReservationStatusValue = map[string]ReservationStatus{
+	"held":     ReservationStatusHeld,
+	"released": ReservationStatusReleased,
+}
+
+// ParseReservationStatus attempts to convert a string to a ReservationStatus.
+func ParseReservationStatus(name string) (ReservationStatus, error) {
+	if x, ok := _ReservationStatusValue[name]; ok {
+		return x, nil
+	}
+	return ReservationStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidReservationStatus)
+}
+
+// MustParseReservationStatus converts a string to a ReservationStatus, and panics if is not valid.
+func MustParseReservationStatus(name string) ReservationStatus {
+	val, err := ParseReservationStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ReservationStatus) Ptr() *ReservationStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ReservationStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ReservationStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParseReservationStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ReservationStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// RewardKindPoints is a RewardKind of type points.
+	// 积分
+	RewardKindPoints RewardKind = "points"
+	// RewardKindCode is a RewardKind of type code.
+	// 兑换码
+	RewardKindCode RewardKind = "code"
+	// RewardKindPhysical is a RewardKind of type physical.
+	// 实物
+	RewardKindPhysical RewardKind = "physical"
+	// RewardKindMedal is a RewardKind of type medal.
+	// 摸鱼派勋章或头衔
+	RewardKindMedal RewardKind = "medal"
+)
+
+var ErrInvalidRewardKind = fmt.Errorf("not a valid RewardKind, try [%s]", strings.Join(_RewardKindNames, ", "))
+
+var _RewardKindNames = []string{
+	string(RewardKindPoints),
+	string(RewardKindCode),
+	string(RewardKindPhysical),
+	string(RewardKindMedal),
+}
+
+// RewardKindNames returns a list of possible string values of RewardKind.
+func RewardKindNames() []string {
+	tmp := make([]string, len(_RewardKindNames))
+	copy(tmp, _RewardKindNames)
+	return tmp
+}
+
+// RewardKindValues returns a list of the values for RewardKind
+func RewardKindValues() []RewardKind {
+	return []RewardKind{
+		RewardKindPoints,
+		RewardKindCode,
+		RewardKindPhysical,
+		RewardKindMedal,
+	}
+}
+
+// String implements the Stringer interface.
+func (x RewardKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x RewardKind) IsValid() bool {
+	_, err := ParseRewardKind(string(x))
+	return err == nil
+}
+
+var _RewardKindValue = map[string]RewardKind{
+	"points":   RewardKindPoints,
+	"code":     RewardKindCode,
+	"physical": RewardKindPhysical,
+	"medal":    RewardKindMedal,
+}
+
+// ParseRewardKind attempts to convert a string to a RewardKind.
+func ParseRewardKind(name string) (RewardKind, error) {
+	if x, ok := _RewardKindValue[name]; ok {
+		return x, nil
+	}
+	return RewardKind(""), fmt.Errorf("%s is %w", name, ErrInvalidRewardKind)
+}
+
+// MustParseRewardKind converts a string to a RewardKind, and panics if is not valid.
+func MustParseRewardKind(name string) RewardKind {
+	val, err := ParseRewardKind(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x RewardKind) Ptr() *RewardKind {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x RewardKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *RewardKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseRewardKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *RewardKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// TableStatusWaiting is a TableStatus of type waiting.
+	// 等待玩家加入
+	TableStatusWaiting TableStatus = "waiting"
+	// TableStatusPlaying is a TableStatus of type playing.
+	// 进行中
+	TableStatusPlaying TableStatus = "playing"
+	// TableStatusFinished is a TableStatus of type finished.
+	// 已结算
+	TableStatusFinished TableStatus = "finished"
+	// TableStatusCancelled is a TableStatus of type cancelled.
+	// 已解散
+	TableStatusCancelled TableStatus = "cancelled"
+)
+
+var ErrInvalidTableStatus = fmt.Errorf("not a valid TableStatus, try [%s]", strings.Join(_TableStatusNames, ", "))
+
+var _TableStatusNames = []string{
+	string(TableStatusWaiting),
+	string(TableStatusPlaying),
+	string(TableStatusFinished),
+	string(TableStatusCancelled),
+}
+
+// TableStatusNames returns a list of possible string values of TableStatus.
+func TableStatusNames() []string {
+	tmp := make([]string, len(_TableStatusNames))
+	copy(tmp, _TableStatusNames)
+	return tmp
+}
+
+// TableStatusValues returns a list of the values for TableStatus
+func TableStatusValues() []TableStatus {
+	return []TableStatus{
+		TableStatusWaiting,
+		TableStatusPlaying,
+		TableStatusFinished,
+		TableStatusCancelled,
+	}
+}
+
+// String implements the Stringer interface.
+func (x TableStatus) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x TableStatus) IsValid() bool {
+	_, err := ParseTableStatus(string(x))
+	return err == nil
+}
+
+var _TableStatusValue = map[string]TableStatus{
+	"waiting":   TableStatusWaiting,
+	"playing":   TableStatusPlaying,
+	"finished":  TableStatusFinished,
+	"cancelled": TableStatusCancelled,
+}
+
+// ParseTableStatus attempts to convert a string to a TableStatus.
+func ParseTableStatus(name string) (TableStatus, error) {
+	if x, ok := _TableStatusValue[name]; ok {
+		return x, nil
+	}
+	return TableStatus(""), fmt.Errorf("%s is %w", name, ErrInvalidTableStatus)
+}
+
+// MustParseTableStatus converts a string to a TableStatus, and panics if is not valid.
+func MustParseTableStatus(name string) TableStatus {
+	val, err := ParseTableStatus(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x TableStatus) Ptr() *TableStatus {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x TableStatus) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *TableStatus) UnmarshalText(text []byte) error {
+	tmp, err := ParseTableStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *TableStatus) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ThrottleScopeUser is a ThrottleScope of type user.
+	// 按用户限流
+	ThrottleScopeUser ThrottleScope = "user"
+	// ThrottleScopeIp is a ThrottleScope of type ip.
+	// 按 IP 限流
+	ThrottleScopeIp ThrottleScope = "ip"
+)
+
+var ErrInvalidThrottleScope = fmt.Errorf("not a valid ThrottleScope, try [%s]", strings.Join(_ThrottleScopeNames, ", "))
+
+var _ThrottleScopeNames = []string{
+	string(ThrottleScopeUser),
+	string(ThrottleScopeIp),
+}
+
+// ThrottleScopeNames returns a list of possible string values of ThrottleScope.
+func ThrottleScopeNames() []string {
+	tmp := make([]string, len(_ThrottleScopeNames))
+	copy(tmp, _ThrottleScopeNames)
+	return tmp
+}
+
+// ThrottleScopeValues returns a list of the values for ThrottleScope
+func ThrottleScopeValues() []ThrottleScope {
+	return []ThrottleScope{
+		ThrottleScopeUser,
+		ThrottleScopeIp,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ThrottleScope) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ThrottleScope) IsValid() bool {
+	_, err := ParseThrottleScope(string(x))
+	return err == nil
+}
+
+var _ThrottleScopeValue = map[string]ThrottleScope{
+	"user": ThrottleScopeUser,
+	"ip":   ThrottleScopeIp,
+}
+
+// ParseThrottleScope attempts to convert a string to a ThrottleScope.
+func ParseThrottleScope(name string) (ThrottleScope, error) {
+	if x, ok := _ThrottleScopeValue[name]; ok {
+		return x, nil
+	}
+	return ThrottleScope(""), fmt.Errorf("%s is %w", name, ErrInvalidThrottleScope)
+}
+
+// MustParseThrottleScope converts a string to a ThrottleScope, and panics if is not valid.
+func MustParseThrottleScope(name string) ThrottleScope {
+	val, err := ParseThrottleScope(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ThrottleScope) Ptr() *ThrottleScope {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ThrottleScope) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ThrottleScope) UnmarshalText(text []byte) error {
+	tmp, err := ParseThrottleScope(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ThrottleScope) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// UserRolePlayer is a UserRole of type player.
+	// 玩家
+	UserRolePlayer UserRole = "player"
+	// UserRoleModerator is a UserRole of type moderator.
+	// 审核员，可审核被标记的文章与投票
+	UserRoleModerator UserRole = "moderator"
+	// UserRoleOperator is a UserRole of type operator.
+	// 运营，可执行发放任务、调整奖品库存与活动时间
+	UserRoleOperator UserRole = "operator"
+	// UserRoleSuperuser is a UserRole of type superuser.
+	// PocketBase 超级管理员，可导出活动数据，不属于 users 集合
+	UserRoleSuperuser UserRole = "superuser"
+)
+
+var ErrInvalidUserRole = fmt.Errorf("not a valid UserRole, try [%s]", strings.Join(_UserRoleNames, ", "))
+
+var _UserRoleNames = []string{
+	string(UserRolePlayer),
+	string(UserRoleModerator),
+	string(UserRoleOperator),
+	string(UserRoleSuperuser),
+}
+
+// UserRoleNames returns a list of possible string values of UserRole.
+func UserRoleNames() []string {
+	tmp := make([]string, len(_UserRoleNames))
+	copy(tmp, _UserRoleNames)
+	return tmp
+}
+
+// UserRoleValues returns a list of the values for UserRole
+func UserRoleValues() []UserRole {
+	return []UserRole{
+		UserRolePlayer,
+		UserRoleModerator,
+		UserRoleOperator,
+		UserRoleSuperuser,
+	}
+}
+
+// String implements the Stringer interface.
+func (x UserRole) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x UserRole) IsValid() bool {
+	_, err := ParseUserRole(string(x))
+	return err == nil
+}
+
+var _UserRoleValue = map[string]UserRole{
+	"player":    UserRolePlayer,
+	"moderator": UserRoleModerator,
+	"operator":  UserRoleOperator,
+	"superuser": UserRoleSuperuser,
+}
+
+// ParseUserRole attempts to convert a string to a UserRole.
+func ParseUserRole(name string) (UserRole, error) {
+	if x, ok := _UserRoleValue[name]; ok {
+		return x, nil
+	}
+	return UserRole(""), fmt.Errorf("%s is %w", name, ErrInvalidUserRole)
+}
+
+// MustParseUserRole converts a string to a UserRole, and panics if is not valid.
+func MustParseUserRole(name string) UserRole {
+	val, err := ParseUserRole(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x UserRole) Ptr() *UserRole {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x UserRole) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *UserRole) UnmarshalText(text []byte) error {
+	tmp, err := ParseUserRole(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *UserRole) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// ThankKindThank is a ThankKind of type thank.
+	// 感谢
+	ThankKindThank ThankKind = "thank"
+	// ThankKindReward is a ThankKind of type reward.
+	// 打赏
+	ThankKindReward ThankKind = "reward"
+)
+
+var ErrInvalidThankKind = fmt.Errorf("not a valid ThankKind, try [%s]", strings.Join(_ThankKindNames, ", "))
+
+var _ThankKindNames = []string{
+	string(ThankKindThank),
+	string(ThankKindReward),
+}
+
+// ThankKindNames returns a list of possible string values of ThankKind.
+func ThankKindNames() []string {
+	tmp := make([]string, len(_ThankKindNames))
+	copy(tmp, _ThankKindNames)
+	return tmp
+}
+
+// ThankKindValues returns a list of the values for ThankKind
+func ThankKindValues() []ThankKind {
+	return []ThankKind{
+		ThankKindThank,
+		ThankKindReward,
+	}
+}
+
+// String implements the Stringer interface.
+func (x ThankKind) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ThankKind) IsValid() bool {
+	_, err := ParseThankKind(string(x))
+	return err == nil
+}
+
+var _ThankKindValue = map[string]ThankKind{
+	"thank":  ThankKindThank,
+	"reward": ThankKindReward,
+}
+
+// ParseThankKind attempts to convert a string to a ThankKind.
+func ParseThankKind(name string) (ThankKind, error) {
+	if x, ok := _ThankKindValue[name]; ok {
+		return x, nil
+	}
+	return ThankKind(""), fmt.Errorf("%s is %w", name, ErrInvalidThankKind)
+}
+
+// MustParseThankKind converts a string to a ThankKind, and panics if is not valid.
+func MustParseThankKind(name string) ThankKind {
+	val, err := ParseThankKind(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x ThankKind) Ptr() *ThankKind {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x ThankKind) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *ThankKind) UnmarshalText(text []byte) error {
+	tmp, err := ParseThankKind(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *ThankKind) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
+
+const (
+	// QuotaSourceBase is a QuotaSource of type base.
+	// 基础次数
+	QuotaSourceBase QuotaSource = "base"
+	// QuotaSourceThanks is a QuotaSource of type thanks.
+	// 文章感谢
+	QuotaSourceThanks QuotaSource = "thanks"
+	// QuotaSourceVotes is a QuotaSource of type votes.
+	// 收到福签
+	QuotaSourceVotes QuotaSource = "votes"
+	// QuotaSourceCheckin is a QuotaSource of type checkin.
+	// 每日签到
+	QuotaSourceCheckin QuotaSource = "checkin"
+	// QuotaSourceGrant is a QuotaSource of type grant.
+	// 管理员补发
+	QuotaSourceGrant QuotaSource = "grant"
+)
+
+var ErrInvalidQuotaSource = fmt.Errorf("not a valid QuotaSource, try [%s]", strings.Join(_QuotaSourceNames, ", "))
+
+var _QuotaSourceNames = []string{
+	string(QuotaSourceBase),
+	string(QuotaSourceThanks),
+	string(QuotaSourceVotes),
+	string(QuotaSourceCheckin),
+	string(QuotaSourceGrant),
+}
+
+// QuotaSourceNames returns a list of possible string values of QuotaSource.
+func QuotaSourceNames() []string {
+	tmp := make([]string, len(_QuotaSourceNames))
+	copy(tmp, _QuotaSourceNames)
+	return tmp
+}
+
+// QuotaSourceValues returns a list of the values for QuotaSource
+func QuotaSourceValues() []QuotaSource {
+	return []QuotaSource{
+		QuotaSourceBase,
+		QuotaSourceThanks,
+		QuotaSourceVotes,
+		QuotaSourceCheckin,
+		QuotaSourceGrant,
+	}
+}
+
+// String implements the Stringer interface.
+func (x QuotaSource) String() string {
+	return string(x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x QuotaSource) IsValid() bool {
+	_, err := ParseQuotaSource(string(x))
+	return err == nil
+}
+
+var _QuotaSourceValue = map[string]QuotaSource{
+	"base":    QuotaSourceBase,
+	"thanks":  QuotaSourceThanks,
+	"votes":   QuotaSourceVotes,
+	"checkin": QuotaSourceCheckin,
+	"grant":   QuotaSourceGrant,
+}
+
+// ParseQuotaSource attempts to convert a string to a QuotaSource.
+func ParseQuotaSource(name string) (QuotaSource, error) {
+	if x, ok := _QuotaSourceValue[name]; ok {
+		return x, nil
+	}
+	return QuotaSource(""), fmt.Errorf("%s is %w", name, ErrInvalidQuotaSource)
+}
+
+// MustParseQuotaSource converts a string to a QuotaSource, and panics if is not valid.
+func MustParseQuotaSource(name string) QuotaSource {
+	val, err := ParseQuotaSource(name)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+func (x QuotaSource) Ptr() *QuotaSource {
+	return &x
+}
+
+// MarshalText implements the text marshaller method.
+func (x QuotaSource) MarshalText() ([]byte, error) {
+	return []byte(string(x)), nil
+}
+
+// UnmarshalText implements the text unmarshaller method.
+func (x *QuotaSource) UnmarshalText(text []byte) error {
+	tmp, err := ParseQuotaSource(string(text))
+	if err != nil {
+		return err
+	}
+	*x = tmp
+	return nil
+}
+
+// AppendText appends the textual representation of itself to the end of b
+// (allocating a larger slice if necessary) and returns the updated slice.
+//
+// Implementations must not retain b, nor mutate any bytes within b[:len(b)].
+func (x *QuotaSource) AppendText(b []byte) ([]byte, error) {
+	return append(b, x.String()...), nil
+}
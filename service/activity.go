@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultSchedule 没有日程记录时的默认日程，不包含任何阶段，需要运营明确设置后活动才开始
+//
+// 默认日程不能带具体日期：过去的日期会让阶段调度器在首次启动时补跑所有结算与发奖任务
+// 升级前写死的活动时间由迁移写入日程记录，只包含投票阶段
+func DefaultSchedule() Schedule {
+	return Schedule{Phases: []Phase{}}
+}
+
+// Phase 活动阶段，区间为 [StartAt, EndAt)
+// 第一个阶段的 StartAt 可为零值表示不限制开始时间，最后一个阶段的 EndAt 可为零值表示不结束
+type Phase struct {
+	Name    model.ActivityPhase `json:"name"`
+	StartAt time.Time           `json:"startAt"`
+	EndAt   time.Time           `json:"endAt"`
+}
+
+// Contains 判断时间是否在阶段内
+func (phase Phase) Contains(t time.Time) bool {
+	if !phase.StartAt.IsZero() && t.Before(phase.StartAt) {
+		return false
+	}
+	return phase.EndAt.IsZero() || t.Before(phase.EndAt)
+}
+
+// Schedule 活动日程，阶段按时间先后排列且互不重叠
+type Schedule struct {
+	Phases []Phase `json:"phases"`
+}
+
+// Validate 校验阶段名称、顺序与时间区间
+func (schedule Schedule) Validate() error {
+	if len(schedule.Phases) == 0 {
+		return errors.New("至少需要一个阶段")
+	}
+
+	seen := map[model.ActivityPhase]bool{}
+	for i, phase := range schedule.Phases {
+		if !phase.Name.IsValid() {
+			return fmt.Errorf("无效的阶段: %s", phase.Name)
+		}
+		if seen[phase.Name] {
+			return fmt.Errorf("阶段重复: %s", phase.Name)
+		}
+		seen[phase.Name] = true
+
+		if phase.StartAt.IsZero() && i > 0 {
+			return fmt.Errorf("阶段 %s 缺少开始时间", phase.Name)
+		}
+		if phase.EndAt.IsZero() && i < len(schedule.Phases)-1 {
+			return fmt.Errorf("阶段 %s 缺少结束时间", phase.Name)
+		}
+		if !phase.StartAt.IsZero() && !phase.EndAt.IsZero() && !phase.EndAt.After(phase.StartAt) {
+			return fmt.Errorf("阶段 %s 的结束时间需晚于开始时间", phase.Name)
+		}
+		if i > 0 && phase.StartAt.Before(schedule.Phases[i-1].EndAt) {
+			return fmt.Errorf("阶段 %s 与上一阶段重叠", phase.Name)
+		}
+	}
+
+	return nil
+}
+
+// PhaseAt 返回时间所在的阶段，不在任何阶段内时返回 nil
+func (schedule Schedule) PhaseAt(t time.Time) *Phase {
+	for i := range schedule.Phases {
+		if schedule.Phases[i].Contains(t) {
+			return &schedule.Phases[i]
+		}
+	}
+	return nil
+}
+
+// NextAfter 返回时间之后最近开始的阶段，没有时返回 nil
+func (schedule Schedule) NextAfter(t time.Time) *Phase {
+	for i := range schedule.Phases {
+		if schedule.Phases[i].StartAt.After(t) {
+			return &schedule.Phases[i]
+		}
+	}
+	return nil
+}
+
+// Started 返回截至该时间已经开始的阶段（按时间先后）
+func (schedule Schedule) Started(t time.Time) []Phase {
+	phases := []Phase{}
+	for _, phase := range schedule.Phases {
+		if phase.StartAt.After(t) {
+			break
+		}
+		phases = append(phases, phase)
+	}
+	return phases
+}
+
+// ActiveWindow 活动进行期间（投票与博饼阶段）的起止时间，区间为 [start, end)，零值表示不限制
+func (schedule Schedule) ActiveWindow() (start time.Time, end time.Time) {
+	found := false
+	for _, phase := range schedule.Phases {
+		if phase.Name != model.ActivityPhaseVoting && phase.Name != model.ActivityPhaseDrawing {
+			continue
+		}
+		if !found {
+			start = phase.StartAt
+			found = true
+		}
+		end = phase.EndAt
+	}
+	return start, end
+}
+
+type ActivityService struct {
+	app      core.App
+	registry *config.Registry
+}
+
+func NewActivityService(app core.App, registry *config.Registry) *ActivityService {
+	return &ActivityService{
+		app:      app,
+		registry: registry,
+	}
+}
+
+// Schedule 当前的活动日程，没有配置时为不包含任何阶段的默认日程
+func (service *ActivityService) Schedule() (Schedule, error) {
+	return config.Get[Schedule](service.registry, model.ConfigKeyActivity)
+}
+
+// CurrentPhase 返回当前所处阶段，不在任何阶段内时返回 nil
+func (service *ActivityService) CurrentPhase() (*Phase, error) {
+	schedule, err := service.Schedule()
+	if err != nil {
+		return nil, err
+	}
+	return schedule.PhaseAt(time.Now()), nil
+}
+
+// SetSchedule 通过配置注册表校验并保存活动日程，返回保存前的日程
+//
+// 与 /operation/configs/activity 共用同一条写入路径，保存后注册表重新加载并通知订阅者
+func (service *ActivityService) SetSchedule(txApp core.App, schedule Schedule) (Schedule, error) {
+	before, err := service.Schedule()
+	if err != nil {
+		return before, err
+	}
+
+	value, err := json.Marshal(schedule)
+	if err != nil {
+		return before, err
+	}
+	_, _, err = service.registry.Update(txApp, model.ConfigKeyActivity, value)
+	return before, err
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/inventory"
+	"fmt"
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+)
+
+// Result 汇总活动结果：博饼获奖、福签与文章排名
+func (service *ActivityService) Result() (map[string]any, error) {
+	// 1. 获取博饼信息（按奖励等级分组统计）
+	var gamingResults []struct {
+		RewardId    string `db:"rewardId" json:"reward_id"`
+		RewardName  string `db:"rewardName" json:"reward_name"`
+		RewardLevel int    `db:"rewardLevel" json:"reward_level"`
+		UserId      string `db:"userId" json:"user_id"`
+		Username    string `db:"username" json:"username"`
+		Nickname    string `db:"nickname" json:"nickname"`
+		Avatar      string `db:"avatar" json:"avatar"`
+		Count       int    `db:"count" json:"count"`
+		IsBest      bool   `db:"isBest" json:"is_best"`
+		Details     string `db:"details" json:"details"`
+		Created     string `db:"created" json:"created"`
+		Times       int    `db:"times" json:"times"`
+	}
+
+	err := service.app.DB().
+		NewQuery(`
+			SELECT h.rewardId, r.name as rewardName, r.level as rewardLevel,
+			       h.userId, u.name as username, u.nickname, u.avatar,
+			       COUNT(*) as count,
+			       MAX(h.isBest) as isBest,
+			       MAX(h.details) as details,
+			       MAX(h.created) as created,
+			       MAX(h.times) as times
+			FROM histories h
+			LEFT JOIN users u ON h.userId = u.id
+			LEFT JOIN rewards r ON h.rewardId = r.id
+			GROUP BY h.rewardId, h.userId
+			ORDER BY r.level DESC, count DESC
+		`).
+		All(&gamingResults)
+
+	if err != nil {
+		return nil, fmt.Errorf("查询博饼信息失败: %w", err)
+	}
+
+	// 2. 获取三种福签的获得信息（包含投票者信息）
+	var voteDetails []struct {
+		ToUserId     string `db:"toUserId" json:"to_user_id"`
+		ToUsername   string `db:"toUsername" json:"to_username"`
+		ToNickname   string `db:"toNickname" json:"to_nickname"`
+		ToAvatar     string `db:"toAvatar" json:"to_avatar"`
+		FromUserId   string `db:"fromUserId" json:"from_user_id"`
+		FromUsername string `db:"fromUsername" json:"from_username"`
+		FromNickname string `db:"fromNickname" json:"from_nickname"`
+		FromAvatar   string `db:"fromAvatar" json:"from_avatar"`
+		VoteType     string `db:"voteType" json:"vote_type"`
+		ArticleId    string `db:"articleId" json:"article_id"`
+		Created      string `db:"created" json:"created"`
+	}
+
+	err = service.app.DB().
+		NewQuery(`
+			SELECT v.toUserId, v.fromUserId, v.voteType, v.articleId, v.created,
+			       toUser.name as toUsername, toUser.nickname as toNickname, toUser.avatar as toAvatar,
+			       fromUser.name as fromUsername, fromUser.nickname as fromNickname, fromUser.avatar as fromAvatar
+			FROM votes v
+			LEFT JOIN users toUser ON v.toUserId = toUser.id
+			LEFT JOIN users fromUser ON v.fromUserId = fromUser.id
+			ORDER BY v.voteType, v.created DESC
+		`).
+		All(&voteDetails)
+
+	if err != nil {
+		return nil, fmt.Errorf("查询福签信息失败: %w", err)
+	}
+
+	// 按福签类型和接收者分组
+	type VoteRecipient struct {
+		UserId   string                   `json:"user_id"`
+		Username string                   `json:"username"`
+		Nickname string                   `json:"nickname"`
+		Avatar   string                   `json:"avatar"`
+		Count    int                      `json:"count"`
+		Voters   []map[string]interface{} `json:"voters"`
+	}
+
+	careerVotesMap := make(map[string]*VoteRecipient)
+	romanceVotesMap := make(map[string]*VoteRecipient)
+	wealthVotesMap := make(map[string]*VoteRecipient)
+
+	for _, vote := range voteDetails {
+		voter := map[string]interface{}{
+			"user_id":  vote.FromUserId,
+			"username": vote.FromUsername,
+			"nickname": vote.FromNickname,
+			"avatar":   vote.FromAvatar,
+			"created":  vote.Created,
+		}
+
+		var targetMap map[string]*VoteRecipient
+		switch vote.VoteType {
+		case model.VoteTypeCareer:
+			targetMap = careerVotesMap
+		case model.VoteTypeRomance:
+			targetMap = romanceVotesMap
+		case model.VoteTypeWealth:
+			targetMap = wealthVotesMap
+		default:
+			continue
+		}
+
+		if targetMap[vote.ToUserId] == nil {
+			targetMap[vote.ToUserId] = &VoteRecipient{
+				UserId:   vote.ToUserId,
+				Username: vote.ToUsername,
+				Nickname: vote.ToNickname,
+				Avatar:   vote.ToAvatar,
+				Count:    0,
+				Voters:   []map[string]interface{}{},
+			}
+		}
+
+		targetMap[vote.ToUserId].Count++
+		targetMap[vote.ToUserId].Voters = append(targetMap[vote.ToUserId].Voters, voter)
+	}
+
+	// 转换为数组并排序
+	careerVotes := make([]interface{}, 0, len(careerVotesMap))
+	for _, v := range careerVotesMap {
+		careerVotes = append(careerVotes, v)
+	}
+
+	romanceVotes := make([]interface{}, 0, len(romanceVotesMap))
+	for _, v := range romanceVotesMap {
+		romanceVotes = append(romanceVotes, v)
+	}
+
+	wealthVotes := make([]interface{}, 0, len(wealthVotesMap))
+	for _, v := range wealthVotesMap {
+		wealthVotes = append(wealthVotes, v)
+	}
+
+	// 3. 获取文章排名信息
+	articles := []*model.Article{}
+	err = service.app.RecordQuery(model.DbNameArticles).
+		OrderBy(model.ArticlesFieldScore + " DESC").
+		Limit(100).
+		All(&articles)
+
+	if err != nil {
+		return nil, fmt.Errorf("查询文章排名失败: %w", err)
+	}
+
+	// 获取文章对应的用户信息
+	articleRankings := []map[string]interface{}{}
+	for rank, article := range articles {
+		user := new(model.User)
+		err := service.app.RecordQuery(model.DbNameUsers).
+			Where(dbx.HashExp{model.CommonFieldId: article.UserId()}).
+			One(user)
+
+		if err != nil {
+			service.app.Logger().Warn("查询用户信息失败", slog.String("userId", article.UserId()), slog.Any("err", err))
+			continue
+		}
+
+		// 统计该文章收到的福签数量
+		careerCount, _ := service.app.CountRecords(model.DbNameVotes, dbx.HashExp{
+			model.VotesFieldArticleId: article.Id,
+			model.VotesFieldVoteType:  model.VoteTypeCareer,
+		})
+		romanceCount, _ := service.app.CountRecords(model.DbNameVotes, dbx.HashExp{
+			model.VotesFieldArticleId: article.Id,
+			model.VotesFieldVoteType:  model.VoteTypeRomance,
+		})
+		wealthCount, _ := service.app.CountRecords(model.DbNameVotes, dbx.HashExp{
+			model.VotesFieldArticleId: article.Id,
+			model.VotesFieldVoteType:  model.VoteTypeWealth,
+		})
+
+		articleRankings = append(articleRankings, map[string]interface{}{
+			"rank":            rank + 1,
+			"article_id":      article.Id,
+			"article_o_id":    article.OId(),
+			"title":           article.Title(),
+			"preview_content": article.PreviewContent(),
+			"view_count":      article.ViewCount(),
+			"good_cnt":        article.GoodCnt(),
+			"comment_count":   article.CommentCount(),
+			"collect_cnt":     article.CollectCnt(),
+			"thank_cnt":       article.ThankCnt(),
+			"score":           article.Score(),
+			"user_id":         user.Id,
+			"username":        user.Name(),
+			"nickname":        user.Nickname(),
+			"avatar":          user.Avatar(),
+			"career_votes":    careerCount,
+			"romance_votes":   romanceCount,
+			"wealth_votes":    wealthCount,
+		})
+	}
+
+	// 4. 获取全服状元及变更历史
+	championConfig, err := config.Get[ChampionConfig](service.registry, model.ConfigKeyChampion)
+	if err != nil {
+		return nil, fmt.Errorf("读取状元模式配置失败: %w", err)
+	}
+	championHistory, err := queryChampionRecords(service.app, 100)
+	if err != nil {
+		return nil, fmt.Errorf("查询状元变更历史失败: %w", err)
+	}
+	var championCurrent any
+	if len(championHistory) > 0 {
+		championCurrent = championHistory[0]
+	}
+
+	// 5. 各奖项的剩余库存
+	awardStocks, err := service.awardStocks()
+	if err != nil {
+		return nil, fmt.Errorf("查询奖品库存失败: %w", err)
+	}
+
+	// 返回所有数据
+	return map[string]any{
+		"gaming_results": gamingResults,
+		"votes": map[string]interface{}{
+			"career":  careerVotes,
+			"romance": romanceVotes,
+			"wealth":  wealthVotes,
+		},
+		"article_rankings": articleRankings,
+		"champion": map[string]any{
+			"global":  championConfig.Global,
+			"current": championCurrent,
+			"history": championHistory,
+		},
+		"award_stocks": awardStocks,
+	}, nil
+}
+
+// awardStocks 每个奖项对应奖品的剩余库存，同一奖品的多个奖项共享库存
+func (service *ActivityService) awardStocks() ([]map[string]any, error) {
+	rewards := []*model.Reward{}
+	if err := service.app.RecordQuery(model.DbNameRewards).All(&rewards); err != nil {
+		return nil, err
+	}
+	stocks := make(map[string]inventory.Stock, len(rewards))
+	for _, reward := range rewards {
+		stocks[reward.Id] = inventory.NewStock(reward)
+	}
+
+	awards := []*model.Awards{}
+	if err := service.app.RecordQuery(model.DbNameAwards).
+		OrderBy(model.AwardsFieldLevel + " DESC").
+		All(&awards); err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, 0, len(awards))
+	for _, award := range awards {
+		stock, ok := stocks[award.RewardId()]
+		if !ok {
+			continue
+		}
+		result = append(result, map[string]any{
+			"award_id":    award.Id,
+			"award_name":  award.Name(),
+			"award_level": award.Level(),
+			"reward_id":   stock.RewardId,
+			"reward_name": stock.Name,
+			"amount":      stock.Amount,
+			"reserved":    stock.Reserved,
+			"remaining":   stock.Remaining,
+			"retired":     stock.Retired,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func testSchedule() Schedule {
+	day := func(d int) time.Time { return time.Date(2025, 10, d, 0, 0, 0, 0, time.UTC) }
+	return Schedule{
+		Phases: []Phase{
+			{Name: model.ActivityPhasePreheat, EndAt: day(2)},
+			{Name: model.ActivityPhaseVoting, StartAt: day(2), EndAt: day(10)},
+			{Name: model.ActivityPhaseSettlement, StartAt: day(12), EndAt: day(13)},
+			{Name: model.ActivityPhaseArchive, StartAt: day(13)},
+		},
+	}
+}
+
+func TestSchedule_PhaseAt(t *testing.T) {
+	schedule := testSchedule()
+
+	tests := []struct {
+		name   string
+		time   time.Time
+		expect model.ActivityPhase
+	}{
+		{"预热不限开始时间", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), model.ActivityPhasePreheat},
+		{"开始时间包含在内", time.Date(2025, 10, 2, 0, 0, 0, 0, time.UTC), model.ActivityPhaseVoting},
+		{"结束时间不包含在内", time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC), model.ActivityPhaseArchive},
+		{"归档不结束", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), model.ActivityPhaseArchive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phase := schedule.PhaseAt(tt.time)
+			if phase == nil || phase.Name != tt.expect {
+				t.Errorf("PhaseAt() = %v, 期望 %s", phase, tt.expect)
+			}
+		})
+	}
+
+	// 阶段之间的空档不属于任何阶段
+	gap := time.Date(2025, 10, 11, 0, 0, 0, 0, time.UTC)
+	if phase := schedule.PhaseAt(gap); phase != nil {
+		t.Errorf("空档期 PhaseAt() = %s, 期望 nil", phase.Name)
+	}
+	if next := schedule.NextAfter(gap); next == nil || next.Name != model.ActivityPhaseSettlement {
+		t.Errorf("NextAfter() = %v, 期望 settlement", next)
+	}
+	if started := schedule.Started(gap); len(started) != 2 {
+		t.Errorf("Started() 数量 = %d, 期望 2", len(started))
+	}
+}
+
+func TestSchedule_ActiveWindow(t *testing.T) {
+	start, end := testSchedule().ActiveWindow()
+	if !start.Equal(time.Date(2025, 10, 2, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 10, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ActiveWindow() = [%s, %s)", start, end)
+	}
+
+	// 默认日程没有阶段，不限制活动时间
+	start, end = DefaultSchedule().ActiveWindow()
+	if !start.IsZero() || !end.IsZero() {
+		t.Errorf("默认日程 ActiveWindow() = [%s, %s)", start, end)
+	}
+
+	// 博饼阶段在投票之后时取到博饼结束
+	day := func(d int) time.Time { return time.Date(2025, 10, d, 0, 0, 0, 0, time.UTC) }
+	start, end = Schedule{Phases: []Phase{
+		{Name: model.ActivityPhasePreheat, EndAt: day(1)},
+		{Name: model.ActivityPhaseVoting, StartAt: day(1), EndAt: day(5)},
+		{Name: model.ActivityPhaseDrawing, StartAt: day(5), EndAt: day(8)},
+		{Name: model.ActivityPhaseArchive, StartAt: day(8)},
+	}}.ActiveWindow()
+	if !start.Equal(day(1)) || !end.Equal(day(8)) {
+		t.Errorf("ActiveWindow() = [%s, %s)", start, end)
+	}
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	if err := testSchedule().Validate(); err != nil {
+		t.Fatalf("合法日程校验失败: %v", err)
+	}
+	// 默认日程为空，不能作为运营设置的日程保存
+	if err := DefaultSchedule().Validate(); err == nil {
+		t.Fatal("默认日程不应通过校验")
+	}
+
+	invalid := map[string]func(s *Schedule){
+		"空日程":     func(s *Schedule) { s.Phases = nil },
+		"无效阶段":    func(s *Schedule) { s.Phases[1].Name = "unknown" },
+		"阶段重复":    func(s *Schedule) { s.Phases[2].Name = model.ActivityPhaseVoting },
+		"中间阶段无开始": func(s *Schedule) { s.Phases[1].StartAt = time.Time{} },
+		"中间阶段无结束": func(s *Schedule) { s.Phases[1].EndAt = time.Time{} },
+		"结束早于开始":  func(s *Schedule) { s.Phases[1].EndAt = s.Phases[1].StartAt.Add(-time.Hour) },
+		"阶段重叠":    func(s *Schedule) { s.Phases[2].StartAt = s.Phases[1].EndAt.Add(-time.Hour) },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			schedule := testSchedule()
+			mutate(&schedule)
+			if err := schedule.Validate(); err == nil {
+				t.Error("期望校验失败")
+			}
+		})
+	}
+}
+
+func TestActivityService_Schedule(t *testing.T) {
+	app := testapp.New(t)
+	registry := testapp.Registry(t, app, config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+		schedule := DefaultSchedule()
+		return &schedule
+	}})
+	service := NewActivityService(app, registry)
+
+	// 迁移写入改为阶段日程之前的活动时间，只有投票阶段，调度器不会补跑结算与归档任务
+	schedule, err := service.Schedule()
+	if err != nil {
+		t.Fatalf("读取日程失败: %v", err)
+	}
+	endAt := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)
+	if len(schedule.Phases) != 1 || schedule.Phases[0].Name != model.ActivityPhaseVoting || !schedule.Phases[0].StartAt.IsZero() || !schedule.Phases[0].EndAt.Equal(endAt) {
+		t.Fatalf("迁移写入的日程 = %+v", schedule)
+	}
+	if err = schedule.Validate(); err != nil {
+		t.Errorf("迁移写入的日程校验失败: %v", err)
+	}
+
+	before, err := service.SetSchedule(app, testSchedule())
+	if err != nil {
+		t.Fatalf("保存日程失败: %v", err)
+	}
+	if len(before.Phases) != 1 {
+		t.Errorf("保存前的日程应为迁移写入的日程, 得到 %+v", before)
+	}
+	// 保存后注册表重新加载
+	if schedule, err = service.Schedule(); err != nil || len(schedule.Phases) != len(testSchedule().Phases) {
+		t.Fatalf("保存后的日程 = %+v, %v", schedule, err)
+	}
+
+	// 与配置接口共用注册表的校验，非法日程不会保存
+	invalid := testSchedule()
+	invalid.Phases[1].Name = "unknown"
+	err = app.RunInTransaction(func(txApp core.App) error {
+		_, err := service.SetSchedule(txApp, invalid)
+		return err
+	})
+	if errs := (validation.Errors{}); !errors.As(err, &errs) {
+		t.Errorf("非法日程应返回 validation.Errors, 得到 %v", err)
+	}
+	if schedule, _ = service.Schedule(); schedule.Phases[1].Name != model.ActivityPhaseVoting {
+		t.Errorf("非法日程不应生效, 得到 %+v", schedule)
+	}
+
+	// 通过配置接口修改时同样生效
+	extended := testSchedule()
+	extended.Phases[3].StartAt = extended.Phases[3].StartAt.Add(24 * time.Hour)
+	extended.Phases[2].EndAt = extended.Phases[3].StartAt
+	data, _ := json.Marshal(extended)
+	if _, _, err = registry.Update(app, model.ConfigKeyActivity, data); err != nil {
+		t.Fatalf("通过注册表修改日程失败: %v", err)
+	}
+	if schedule, _ = service.Schedule(); !schedule.Phases[3].StartAt.Equal(extended.Phases[3].StartAt) {
+		t.Errorf("注册表修改后日程未更新, 得到 %+v", schedule)
+	}
+}
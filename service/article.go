@@ -3,7 +3,6 @@ package service
 import (
 	"bless-activity/model"
 	"bless-activity/service/fishpi"
-	"fmt"
 	"log/slog"
 	"slices"
 	"time"
@@ -19,15 +18,19 @@ type ArticleService struct {
 
 	app           core.App
 	fishpiService *fishpi.Service
+	userService   *UserService
+	thankService  *ThankService
 }
 
-func NewArticleService(app core.App, fishpiService *fishpi.Service) *ArticleService {
+func NewArticleService(app core.App, fishpiService *fishpi.Service, userService *UserService, thankService *ThankService) *ArticleService {
 
 	service := ArticleService{
 		userMap:       maputil.NewConcurrentMap[string, *model.User](100),
 		articleMap:    maputil.NewConcurrentMap[string, *model.Article](100),
 		app:           app,
 		fishpiService: fishpiService,
+		userService:   userService,
+		thankService:  thankService,
 	}
 	return &service
 }
@@ -115,6 +118,7 @@ func (service *ArticleService) HandleArticle(responseArticle *fishpi.GetApiArtic
 		if err := service.app.Save(article); err != nil {
 			return
 		}
+		service.syncThanks(article)
 		return
 	}
 
@@ -148,6 +152,19 @@ func (service *ArticleService) HandleArticle(responseArticle *fishpi.GetApiArtic
 		return
 	}
 	service.articleMap.Set(article.OId(), article)
+	service.syncThanks(article)
+}
+
+// syncThanks 保存文章的感谢明细，失败时等下次爬取重试
+func (service *ArticleService) syncThanks(article *model.Article) {
+	created, err := service.thankService.Sync(article)
+	if err != nil {
+		service.app.Logger().Error("爬取感谢明细失败", slog.String("article", article.OId()), slog.Any("err", err))
+		return
+	}
+	if created > 0 {
+		service.app.Logger().Debug("保存感谢明细", slog.String("article", article.OId()), slog.Int("created", created))
+	}
 }
 
 func (service *ArticleService) HandleAuthor(author *fishpi.GetApiArticlesTagResponseArticleAuthor) error {
@@ -162,21 +179,17 @@ func (service *ArticleService) HandleAuthor(author *fishpi.GetApiArticlesTagResp
 		}
 		return nil
 	}
-	// 创建用户
-	userCollection, err := service.app.FindCollectionByNameOrId(model.DbNameUsers)
-	if err != nil {
-		return err
-	}
-	user = model.NewUserFromCollection(userCollection)
-	user.SetEmail(fmt.Sprintf("%s@fishpi.cn", author.OId))
-	user.SetEmailVisibility(true)
-	user.SetVerified(true)
-	user.SetOId(author.OId)
-	user.SetName(author.UserName)
-	user.SetNickname(author.UserNickname)
-	user.SetAvatar(author.UserAvatarURL)
-	user.SetRandomPassword()
-	if err = service.app.Save(user); err != nil {
+	// 创建用户（用户可能已通过登录自行注册，由 SaveFishpiUser 处理并发创建）
+	if err := service.app.RunInTransaction(func(txApp core.App) error {
+		var saveErr error
+		user, saveErr = service.userService.SaveFishpiUser(txApp, FishpiProfile{
+			OId:      author.OId,
+			Name:     author.UserName,
+			Nickname: author.UserNickname,
+			Avatar:   author.UserAvatarURL,
+		})
+		return saveErr
+	}); err != nil {
 		return err
 	}
 	service.userMap.Set(user.OId(), user)
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"bless-activity/model"
+	"maps"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// 管理操作类型
+const (
+	AuditActionArticleFlag      = "article.flag"
+	AuditActionArticleReview    = "article.review"
+	AuditActionVoteFlag         = "vote.flag"
+	AuditActionVoteReview       = "vote.review"
+	AuditActionSettlementRun    = "settlement.run"
+	AuditActionPayoutRun        = "payout.run"
+	AuditActionRewardStock      = "reward.stock"
+	AuditActionRewardRetire     = "reward.retire"
+	AuditActionRewardCodes      = "reward.codes"
+	AuditActionFulfillment      = "fulfillment.transition"
+	AuditActionActivitySchedule = "activity.schedule"
+	AuditActionConfigUpdate     = "config.update"
+	AuditActionBudgetUpdate     = "budget.update"
+	AuditActionReconcile        = "ledger.reconcile"
+	AuditActionPointsResolve    = "points.resolve"
+	AuditActionGuardHalt        = "guard.halt"
+	AuditActionGuardReset       = "guard.reset"
+	AuditActionExportStream     = "export.stream"
+	AuditActionExportCreate     = "export.create"
+	AuditActionExportDownload   = "export.download"
+	AuditActionReportPublish    = "report.publish"
+	AuditActionDrawGrant        = "draw.grant"
+)
+
+// AuditEntry 一条管理操作记录
+type AuditEntry struct {
+	Actor  *model.User
+	Action string
+	// Target 为被操作的记录，Before/After 为操作前后的字段快照
+	TargetCollection string
+	TargetId         string
+	Before           any
+	After            any
+	Ip               string
+	Memo             string
+}
+
+type AuditService struct {
+	app core.App
+}
+
+func NewAuditService(app core.App) *AuditService {
+	return &AuditService{
+		app: app,
+	}
+}
+
+// Record 写入审计记录，应与被审计的修改在同一事务中调用
+func (service *AuditService) Record(txApp core.App, entry AuditEntry) error {
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameAudits)
+	if err != nil {
+		return err
+	}
+
+	audit := model.NewAuditFromCollection(collection)
+	// 超级管理员不属于 users 集合，记录在 superuserId 中
+	if entry.Actor.IsSuperuser() {
+		audit.SetSuperuserId(entry.Actor.Id)
+	} else {
+		audit.SetActorId(entry.Actor.Id)
+	}
+	audit.SetActorRole(entry.Actor.Role())
+	audit.SetAction(entry.Action)
+	audit.SetTargetCollection(entry.TargetCollection)
+	audit.SetTargetId(entry.TargetId)
+	audit.SetBefore(entry.Before)
+	audit.SetAfter(entry.After)
+	audit.SetIp(entry.Ip)
+	audit.SetMemo(entry.Memo)
+	return txApp.Save(audit)
+}
+
+// List 按时间倒序分页查询审计记录，action 为空时不过滤
+func (service *AuditService) List(action string, page int, perPage int) ([]*model.Audit, int64, error) {
+	// 空的 HashExp 在 CountRecords 中会生成 WHERE ()，没有过滤条件时不传
+	filters := []dbx.Expression{}
+	if action != "" {
+		filters = append(filters, dbx.HashExp{model.AuditsFieldAction: action})
+	}
+
+	total, err := service.app.CountRecords(model.DbNameAudits, filters...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	audits := []*model.Audit{}
+	err = service.app.RecordQuery(model.DbNameAudits).
+		Where(dbx.And(filters...)).
+		OrderBy(model.AuditsFieldCreated + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&audits)
+	return audits, total, err
+}
+
+// Snapshot 复制记录的全部字段（含隐藏字段），用于审计的 before/after
+func Snapshot(record *core.Record) map[string]any {
+	if record == nil {
+		return nil
+	}
+	return maps.Clone(record.FieldsData())
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var errTestRollback = errors.New("回滚")
+
+func TestAuditService_RecordAndList(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAuditService(app)
+	operator := testapp.User(t, app, "1001", "operator")
+	operator.SetRole(model.UserRoleOperator)
+	article := testapp.Article(t, app, operator, "1700000000000")
+
+	// 审计记录与被审计的修改在同一事务中，事务失败时一并回滚
+	before := Snapshot(article.Record)
+	_ = app.RunInTransaction(func(txApp core.App) error {
+		article.SetFlagged(true)
+		if err := txApp.Save(article); err != nil {
+			return err
+		}
+		if err := service.Record(txApp, AuditEntry{Actor: operator, Action: AuditActionArticleFlag, TargetCollection: model.DbNameArticles, TargetId: article.Id}); err != nil {
+			return err
+		}
+		return errTestRollback
+	})
+	if _, total, err := service.List("", 1, 10); err != nil || total != 0 {
+		t.Fatalf("事务回滚后不应有审计记录, 得到 %d 条, %v", total, err)
+	}
+
+	for _, action := range []string{AuditActionArticleFlag, AuditActionPayoutRun, AuditActionArticleFlag} {
+		if err := app.RunInTransaction(func(txApp core.App) error {
+			return service.Record(txApp, AuditEntry{
+				Actor:            operator,
+				Action:           action,
+				TargetCollection: model.DbNameArticles,
+				TargetId:         article.Id,
+				Before:           before,
+				After:            Snapshot(article.Record),
+				Ip:               "1.1.1.1",
+			})
+		}); err != nil {
+			t.Fatalf("写入审计记录失败: %v", err)
+		}
+	}
+
+	audits, total, err := service.List(AuditActionArticleFlag, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(audits) != 1 {
+		t.Fatalf("按操作过滤期望共 2 条、本页 1 条, 得到 %d, %d", total, len(audits))
+	}
+	audit := audits[0]
+	if audit.ActorId() != operator.Id || audit.ActorRole() != model.UserRoleOperator || audit.TargetId() != article.Id {
+		t.Errorf("审计记录字段不一致: %s %s %s", audit.ActorId(), audit.ActorRole(), audit.TargetId())
+	}
+	if _, total, err = service.List("", 1, 10); err != nil || total != 3 {
+		t.Errorf("期望共 3 条审计记录, 得到 %d, %v", total, err)
+	}
+}
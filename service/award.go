@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/inventory"
+	"bless-activity/service/mooncakeGambling"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// maxAwardFallbacks 备选奖项最多降级的次数，避免配置成环
+const maxAwardFallbacks = 5
+
+var (
+	ErrAwardNotFound = errors.New("该等级没有配置奖项")
+	ErrAwardNoSeed   = errors.New("该博饼记录没有种子，无法复现")
+)
+
+// AwardReplay 按博饼记录的种子复现的骰子与奖项选择
+// 复现使用当前的奖项配置，博饼之后调整过权重或条件时选择结果可能不同
+type AwardReplay struct {
+	HistoryId     string `json:"history_id"`
+	Seed          string `json:"seed"`
+	Dices         [6]int `json:"dices"`
+	PrizeLevel    int    `json:"prize_level"`
+	DicesMatch    bool   `json:"dices_match"`
+	PickedAwardId string `json:"picked_award_id"`
+	PickedMatch   bool   `json:"picked_match"`
+	AwardId       string `json:"award_id"`
+	Fallback      bool   `json:"fallback"` // 最终的奖项是否为降级后的备选奖项
+}
+
+// AwardService 博饼奖项：同一等级可以配置多个奖项，按博饼种子的随机数加权选择，奖品发完或下架时沿备选奖项降级
+type AwardService struct {
+	app              core.App
+	inventoryService *inventory.Service
+	game             *mooncakeGambling.MooncakeGame
+	logger           *slog.Logger
+}
+
+func NewAwardService(app core.App, inventoryService *inventory.Service) *AwardService {
+	return &AwardService{
+		app:              app,
+		inventoryService: inventoryService,
+		game:             mooncakeGambling.NewMooncakeGame(),
+		logger:           app.Logger().WithGroup("award"),
+	}
+}
+
+// pickWeighted 按权重随机选择下标，权重不大于 0 的不参与选择，全部不参与时返回 -1 且不消耗随机数
+func pickWeighted(r *rand.Rand, weights []int) int {
+	total := 0
+	for _, weight := range weights {
+		total += max(0, weight)
+	}
+	if total == 0 {
+		return -1
+	}
+	n := r.IntN(total)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if n < weight {
+			return i
+		}
+		n -= weight
+	}
+	return -1
+}
+
+// Pick 按权重在等级的奖项中选择一个，只在用户满足领取条件的奖项中选择，都不满足时返回该等级的第一个奖项且 eligible 为 false
+// 选择在事务之外进行，预留奖品时由 Reserve 在事务中重新判断领取条件
+// before 非零时按该时间之前的获奖记录判断领取条件，用于复现
+func (service *AwardService) Pick(r *rand.Rand, level int, userId string, before types.DateTime) (award *model.Awards, eligible bool, err error) {
+	awards := []*model.Awards{}
+	if err = service.app.RecordQuery(model.DbNameAwards).
+		Where(dbx.HashExp{model.AwardsFieldLevel: level}).
+		OrderBy(model.CommonFieldId + " asc").
+		All(&awards); err != nil {
+		return nil, false, err
+	}
+	if len(awards) == 0 {
+		return nil, false, fmt.Errorf("%w: %d", ErrAwardNotFound, level)
+	}
+
+	weights := make([]int, len(awards))
+	for i, award := range awards {
+		ok, err := service.eligible(service.app, award, userId, before)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			weights[i] = award.Weight()
+		}
+	}
+	if i := pickWeighted(r, weights); i >= 0 {
+		return awards[i], true, nil
+	}
+	return awards[0], false, nil
+}
+
+// Reserve 在事务中重新判断领取条件并为博饼记录预留奖项的奖品，fallback 为 true 时奖品已发完、已下架或不满足条件沿备选奖项降级
+// 返回最终获得的奖项与奖品，都无法预留时返回 nil
+func (service *AwardService) Reserve(txApp core.App, history *model.Histories, award *model.Awards, fallback bool) (*model.Awards, *model.Reward, error) {
+	visited := map[string]bool{}
+	for i := 0; i <= maxAwardFallbacks && !visited[award.Id]; i++ {
+		visited[award.Id] = true
+
+		// 选择奖项时在事务之外判断过领取条件，并发博饼时可能已获奖，在事务中重新判断
+		ok, err := service.eligible(txApp, award, history.UserId(), types.DateTime{})
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			_, err = service.inventoryService.Reserve(txApp, award.RewardId(), history.Id)
+			if err == nil {
+				reward := new(model.Reward)
+				if err = txApp.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: award.RewardId()}).One(reward); err != nil {
+					return nil, nil, err
+				}
+				return award, reward, nil
+			}
+			if !errors.Is(err, inventory.ErrOutOfStock) && !errors.Is(err, inventory.ErrRetired) {
+				return nil, nil, err
+			}
+		}
+
+		if !fallback || award.FallbackId() == "" {
+			break
+		}
+		next := new(model.Awards)
+		if err := txApp.RecordQuery(model.DbNameAwards).Where(dbx.HashExp{model.CommonFieldId: award.FallbackId()}).One(next); err != nil {
+			return nil, nil, fmt.Errorf("查找备选奖项失败 %s: %w", award.FallbackId(), err)
+		}
+		service.logger.Debug("奖品无法发放，降级到备选奖项",
+			slog.String("history_id", history.Id),
+			slog.String("award_id", award.Id),
+			slog.String("fallback_id", next.Id))
+		award = next
+	}
+	return nil, nil, nil
+}
+
+// Replay 按博饼记录的种子重新掷骰子并选择奖项，用于核对博饼结果
+func (service *AwardService) Replay(history *model.Histories) (*AwardReplay, error) {
+	seed, err := strconv.ParseUint(history.Seed(), 10, 64)
+	if err != nil {
+		return nil, ErrAwardNoSeed
+	}
+
+	r := mooncakeGambling.NewSeededRand(seed)
+	result := service.game.PlayWith(r)
+	award, _, err := service.Pick(r, int(result.PrizeLevel), history.UserId(), history.Created())
+	if err != nil {
+		return nil, err
+	}
+	return &AwardReplay{
+		HistoryId:     history.Id,
+		Seed:          history.Seed(),
+		Dices:         result.Dices,
+		PrizeLevel:    int(result.PrizeLevel),
+		DicesMatch:    result.Dices == history.Details(),
+		PickedAwardId: award.Id,
+		PickedMatch:   award.Id == history.PickedAwardId(),
+		AwardId:       history.AwardId(),
+		Fallback:      history.PickedAwardId() != "" && history.AwardId() != history.PickedAwardId(),
+	}, nil
+}
+
+// eligible 用户是否满足奖项的领取条件
+func (service *AwardService) eligible(txApp core.App, award *model.Awards, userId string, before types.DateTime) (bool, error) {
+	switch award.Condition() {
+	case model.AwardConditionFirstWin:
+		where := dbx.And(dbx.HashExp{
+			model.HistoriesFieldUserId:    userId,
+			model.HistoriesFieldGotReward: true,
+		})
+		if !before.IsZero() {
+			where = dbx.And(where, dbx.NewExp("[["+model.HistoriesFieldCreated+"]] < {:before}", dbx.Params{"before": before.String()}))
+		}
+		won, err := txApp.CountRecords(model.DbNameHistories, where)
+		return won == 0, err
+	default:
+		return true, nil
+	}
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/inventory"
+	"bless-activity/service/mooncakeGambling"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestPickWeighted(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	if i := pickWeighted(r, []int{0, 0, -1}); i != -1 {
+		t.Errorf("没有可选的奖项: got %d", i)
+	}
+	for n := 0; n < 100; n++ {
+		if i := pickWeighted(r, []int{0, 3, 0}); i != 1 {
+			t.Fatalf("只有一个可选的奖项: got %d", i)
+		}
+	}
+
+	// 按权重分布
+	counts := make([]int, 3)
+	for n := 0; n < 10000; n++ {
+		counts[pickWeighted(r, []int{1, 0, 3})]++
+	}
+	if counts[1] != 0 {
+		t.Errorf("权重为 0 的奖项被选中 %d 次", counts[1])
+	}
+	if counts[2] < counts[0]*2 || counts[2] > counts[0]*4 {
+		t.Errorf("权重 1:3 的分布不符: %v", counts)
+	}
+
+	// 同一种子的选择相同
+	a, b := rand.New(rand.NewPCG(7, 7)), rand.New(rand.NewPCG(7, 7))
+	for n := 0; n < 100; n++ {
+		if x, y := pickWeighted(a, []int{2, 5, 1}), pickWeighted(b, []int{2, 5, 1}); x != y {
+			t.Fatalf("第%d次: %d != %d", n+1, x, y)
+		}
+	}
+}
+
+// newAward 在指定等级创建奖项
+func newAward(t *testing.T, app core.App, level int, reward *model.Reward, weight int, condition model.AwardCondition) *model.Awards {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(model.DbNameAwards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	award := model.NewAwardsFromCollection(collection)
+	award.SetLevel(level)
+	award.SetName(reward.Name())
+	award.SetRewardId(reward.Id)
+	award.SetWeight(weight)
+	award.SetCondition(condition)
+	if err = app.Save(award); err != nil {
+		t.Fatalf("创建奖项失败: %v", err)
+	}
+	return award
+}
+
+// chainAwards 依次将奖项的备选奖项指向下一个
+func chainAwards(t *testing.T, app core.App, awards ...*model.Awards) {
+	t.Helper()
+	for i := 0; i < len(awards)-1; i++ {
+		awards[i].SetFallbackId(awards[i+1].Id)
+		if err := app.Save(awards[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// win 为用户创建一条已获奖的博饼记录
+func win(t *testing.T, app core.App, user *model.User, reward *model.Reward) *model.Histories {
+	t.Helper()
+	history := testapp.History(t, app, user, reward, 1)
+	history.SetGotReward(true)
+	if err := app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+	return history
+}
+
+func TestAwardService_PickIneligible(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 10)
+	const level = 99
+
+	if _, _, err := service.Pick(rand.New(rand.NewPCG(1, 1)), level, user.Id, types.DateTime{}); !errors.Is(err, ErrAwardNotFound) {
+		t.Errorf("没有奖项时应返回 ErrAwardNotFound, 得到 %v", err)
+	}
+
+	first := newAward(t, app, level, reward, 1, model.AwardConditionFirstWin)
+	second := newAward(t, app, level, reward, 1, model.AwardConditionFirstWin)
+	r := rand.New(rand.NewPCG(1, 1))
+
+	// 没有获奖过时满足首次获奖条件
+	award, eligible, err := service.Pick(r, level, user.Id, types.DateTime{})
+	if err != nil || !eligible || (award.Id != first.Id && award.Id != second.Id) {
+		t.Fatalf("Pick() = %v, %v, %v", award, eligible, err)
+	}
+
+	// 获奖后都不满足条件，返回第一个奖项且不可领取
+	history := win(t, app, user, reward)
+	award, eligible, err = service.Pick(r, level, user.Id, types.DateTime{})
+	if err != nil || eligible {
+		t.Fatalf("都不满足条件时 eligible 应为 false, 得到 %v %v", eligible, err)
+	}
+	if want := min(first.Id, second.Id); award.Id != want {
+		t.Errorf("都不满足条件时应返回第一个奖项 %s, 得到 %s", want, award.Id)
+	}
+
+	// 复现时只统计博饼之前的获奖记录
+	if _, eligible, _ = service.Pick(r, level, user.Id, history.Created()); !eligible {
+		t.Error("获奖之前的博饼应满足首次获奖条件")
+	}
+
+	// 只在满足条件的奖项中选择
+	always := newAward(t, app, level, reward, 1, model.AwardConditionNone)
+	for range 20 {
+		if award, eligible, _ = service.Pick(r, level, user.Id, types.DateTime{}); !eligible || award.Id != always.Id {
+			t.Fatalf("应选择无条件的奖项, 得到 %s %v", award.Id, eligible)
+		}
+	}
+}
+
+func TestAwardService_ReserveFallback(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	user := testapp.User(t, app, "1001", "alice")
+	const level = 99
+
+	empty := testapp.Reward(t, app, "已发完", 0)
+	winner := testapp.Reward(t, app, "茶叶", 1)
+	a := newAward(t, app, level, empty, 1, model.AwardConditionNone)
+	b := newAward(t, app, level, empty, 1, model.AwardConditionNone)
+	c := newAward(t, app, level, winner, 1, model.AwardConditionNone)
+	chainAwards(t, app, a, b, c)
+
+	// 不降级时直接返回 nil
+	history := testapp.History(t, app, user, empty, 1)
+	award, reward, err := service.Reserve(app, history, a, false)
+	if err != nil || award != nil || reward != nil {
+		t.Errorf("不降级时应返回 nil, 得到 %v %v %v", award, reward, err)
+	}
+
+	// 沿备选奖项降级到有库存的奖项
+	award, reward, err = service.Reserve(app, history, a, true)
+	if err != nil || award == nil || award.Id != c.Id || reward.Id != winner.Id {
+		t.Fatalf("降级结果 = %v %v %v", award, reward, err)
+	}
+
+	// 备选奖项都发完时返回 nil
+	other := testapp.History(t, app, user, empty, 2)
+	if award, _, err = service.Reserve(app, other, a, true); err != nil || award != nil {
+		t.Errorf("都发完时应返回 nil, 得到 %v %v", award, err)
+	}
+}
+
+func TestAwardService_ReserveFallbackIneligible(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	user := testapp.User(t, app, "1001", "alice")
+	const level = 99
+
+	empty := testapp.Reward(t, app, "已发完", 0)
+	firstWin := testapp.Reward(t, app, "首次获奖", 5)
+	fallback := testapp.Reward(t, app, "茶叶", 5)
+	a := newAward(t, app, level, empty, 1, model.AwardConditionNone)
+	b := newAward(t, app, level, firstWin, 1, model.AwardConditionFirstWin)
+	c := newAward(t, app, level, fallback, 1, model.AwardConditionNone)
+	chainAwards(t, app, a, b, c)
+
+	// 备选奖项重新判断领取条件，已获奖的用户跳过首次获奖的奖项
+	win(t, app, user, fallback)
+	history := testapp.History(t, app, user, empty, 2)
+	award, _, err := service.Reserve(app, history, a, true)
+	if err != nil || award == nil || award.Id != c.Id {
+		t.Errorf("应跳过不满足条件的备选奖项, 得到 %v %v", award, err)
+	}
+}
+
+func TestAwardService_ReserveRecheck(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	user := testapp.User(t, app, "1001", "alice")
+	const level = 99
+
+	firstWin := testapp.Reward(t, app, "首次获奖", 5)
+	fallback := testapp.Reward(t, app, "茶叶", 5)
+	a := newAward(t, app, level, firstWin, 1, model.AwardConditionFirstWin)
+	picked, eligible, err := service.Pick(rand.New(rand.NewPCG(1, 1)), level, user.Id, types.DateTime{})
+	if err != nil || !eligible || picked.Id != a.Id {
+		t.Fatalf("Pick() = %v, %v, %v", picked, eligible, err)
+	}
+
+	// 选择之后同一用户的另一次博饼先获奖，预留时不再满足首次获奖条件
+	win(t, app, user, fallback)
+	history := testapp.History(t, app, user, firstWin, 2)
+	if award, _, err := service.Reserve(app, history, picked, false); err != nil || award != nil {
+		t.Errorf("不满足条件时不应预留, 得到 %v %v", award, err)
+	}
+	if reward, _ := app.FindRecordById(model.DbNameRewards, firstWin.Id); reward.GetInt(model.RewardsFieldReserved) != 0 {
+		t.Errorf("不满足条件时预留了 %d 份", reward.GetInt(model.RewardsFieldReserved))
+	}
+
+	// 允许降级时改为备选奖项
+	c := newAward(t, app, level, fallback, 1, model.AwardConditionNone)
+	chainAwards(t, app, a, c)
+	picked.SetFallbackId(c.Id)
+	if award, _, err := service.Reserve(app, history, picked, true); err != nil || award == nil || award.Id != c.Id {
+		t.Errorf("不满足条件时应降级到备选奖项, 得到 %v %v", award, err)
+	}
+}
+
+func TestAwardService_ReserveFallbackLimit(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	user := testapp.User(t, app, "1001", "alice")
+	const level = 99
+
+	empty := testapp.Reward(t, app, "已发完", 0)
+	winner := testapp.Reward(t, app, "茶叶", 5)
+
+	// 配置成环时不会死循环
+	a := newAward(t, app, level, empty, 1, model.AwardConditionNone)
+	b := newAward(t, app, level, empty, 1, model.AwardConditionNone)
+	chainAwards(t, app, a, b, a)
+	history := testapp.History(t, app, user, empty, 1)
+	if award, _, err := service.Reserve(app, history, a, true); err != nil || award != nil {
+		t.Errorf("成环时应返回 nil, 得到 %v %v", award, err)
+	}
+
+	// 最多降级 maxAwardFallbacks 次
+	for _, fallbacks := range []int{maxAwardFallbacks, maxAwardFallbacks + 1} {
+		chain := []*model.Awards{}
+		for range fallbacks {
+			chain = append(chain, newAward(t, app, level, empty, 1, model.AwardConditionNone))
+		}
+		last := newAward(t, app, level, winner, 1, model.AwardConditionNone)
+		chainAwards(t, app, append(chain, last)...)
+
+		history := testapp.History(t, app, user, empty, fallbacks+1)
+		award, _, err := service.Reserve(app, history, chain[0], true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reached := award != nil && award.Id == last.Id; reached != (fallbacks <= maxAwardFallbacks) {
+			t.Errorf("降级 %d 次: 得到 %v", fallbacks, award)
+		}
+	}
+}
+
+func TestAwardService_Replay(t *testing.T) {
+	app := testapp.New(t)
+	service := NewAwardService(app, inventory.NewService(app))
+	game := mooncakeGambling.NewMooncakeGame()
+	user := testapp.User(t, app, "1001", "alice")
+
+	// 按博饼接口的流程完成若干次博饼，复现结果与记录一致
+	for seed := range uint64(20) {
+		r := mooncakeGambling.NewSeededRand(seed)
+		result := game.PlayWith(r)
+		picked, eligible, err := service.Pick(r, int(result.PrizeLevel), user.Id, types.DateTime{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		collection, _ := app.FindCollectionByNameOrId(model.DbNameHistories)
+		history := model.NewHistoriesFromCollection(collection)
+		history.SetUserId(user.Id)
+		history.SetTimes(int(seed) + 1)
+		history.SetDetails(result.Dices)
+		history.SetSeed(strconv.FormatUint(seed, 10))
+		history.SetPickedAwardId(picked.Id)
+		history.SetAwardId(picked.Id)
+		history.SetRewardId(picked.RewardId())
+		err = app.RunInTransaction(func(txApp core.App) error {
+			if err := txApp.Save(history); err != nil {
+				return err
+			}
+			if !eligible {
+				return nil
+			}
+			award, reward, err := service.Reserve(txApp, history, picked, !result.PrizeLevel.IsTop())
+			if err != nil || award == nil {
+				return err
+			}
+			history.SetAwardId(award.Id)
+			history.SetRewardId(reward.Id)
+			history.SetGotReward(true)
+			return txApp.Save(history)
+		})
+		if err != nil {
+			t.Fatalf("保存博饼记录失败: %v", err)
+		}
+
+		replay, err := service.Replay(history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !replay.DicesMatch || !replay.PickedMatch || replay.PrizeLevel != int(result.PrizeLevel) {
+			t.Errorf("种子 %d 复现不一致: %+v", seed, replay)
+		}
+		if replay.Fallback != (history.AwardId() != picked.Id) {
+			t.Errorf("种子 %d 降级标记不一致: %+v", seed, replay)
+		}
+	}
+
+	history := testapp.History(t, app, user, testapp.Reward(t, app, "月饼", 1), 99)
+	if _, err := service.Replay(history); !errors.Is(err, ErrAwardNoSeed) {
+		t.Errorf("没有种子时应返回 ErrAwardNoSeed, 得到 %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/mooncakeGambling"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ChampionConfig 状元模式配置
+type ChampionConfig struct {
+	// Global 全服争夺状元：新的状元博出更好的结果时夺走当前状元
+	Global bool `json:"global"`
+}
+
+// Validate 只有开关，没有需要校验的取值，保存时仍经过严格解码，拒绝未知字段
+func (config *ChampionConfig) Validate() error {
+	return validation.ValidateStruct(config)
+}
+
+// ChampionRecord 状元变更记录
+type ChampionRecord struct {
+	Id                string `db:"id" json:"id"`
+	HistoryId         string `db:"historyId" json:"history_id"`
+	UserId            string `db:"userId" json:"user_id"`
+	Username          string `db:"username" json:"username"`
+	Nickname          string `db:"nickname" json:"nickname"`
+	Avatar            string `db:"avatar" json:"avatar"`
+	PreviousHistoryId string `db:"previousHistoryId" json:"previous_history_id"`
+	PreviousUserId    string `db:"previousUserId" json:"previous_user_id"`
+	PrizeLevel        int    `db:"prizeLevel" json:"prize_level"`
+	PrizeName         string `db:"prizeName" json:"prize_name"`
+	Dices             string `db:"dices" json:"dices"`
+	Created           string `db:"created" json:"created"`
+}
+
+// ChampionService 全服状元争夺（传统博状元规则）
+// 所有玩家的状元记录按 CompareGameResult 比较，更好的结果夺走状元，结果相同时先博出者保持
+type ChampionService struct {
+	app                 core.App
+	registry            *config.Registry
+	logger              *slog.Logger
+	game                *mooncakeGambling.MooncakeGame
+	notificationService *NotificationService
+
+	mutex sync.Mutex
+}
+
+func NewChampionService(app core.App, registry *config.Registry, notificationService *NotificationService) *ChampionService {
+	return &ChampionService{
+		app:                 app,
+		registry:            registry,
+		logger:              app.Logger().With(slog.String("service", "champion")),
+		game:                mooncakeGambling.NewMooncakeGame(),
+		notificationService: notificationService,
+	}
+}
+
+// Config 读取状元模式配置
+func (service *ChampionService) Config() (ChampionConfig, error) {
+	return config.Get[ChampionConfig](service.registry, model.ConfigKeyChampion)
+}
+
+// Enabled 是否开启全服状元模式
+func (service *ChampionService) Enabled() bool {
+	value, err := service.Config()
+	if err != nil {
+		service.logger.Error("读取状元模式配置失败", slog.Any("err", err))
+		return false
+	}
+	return value.Global
+}
+
+// Challenge 用新的博饼记录挑战当前状元，夺得状元时返回变更记录，否则返回 nil
+func (service *ChampionService) Challenge(history *model.Histories, result mooncakeGambling.GameResult) (*model.ChampionEvent, error) {
+	if !result.PrizeLevel.IsTop() || !service.Enabled() {
+		return nil, nil
+	}
+
+	// 进程内串行化挑战，事务保证变更记录与通知一起写入
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	var event *model.ChampionEvent
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		current, err := service.current(txApp)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("查找当前状元失败: %w", err)
+		}
+		if current != nil {
+			if current.HistoryId() == history.Id {
+				return nil
+			}
+			if mooncakeGambling.CompareGameResult(result, service.game.PlayWithDices(current.Dices())) <= 0 {
+				return nil
+			}
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNameChampionEvents)
+		if err != nil {
+			return err
+		}
+		event = model.NewChampionEventFromCollection(collection)
+		event.SetHistoryId(history.Id)
+		event.SetUserId(history.UserId())
+		event.SetPrizeLevel(int(result.PrizeLevel))
+		event.SetPrizeName(result.PrizeName)
+		event.SetDices(result.Dices)
+		if current != nil {
+			event.SetPreviousHistoryId(current.HistoryId())
+			event.SetPreviousUserId(current.UserId())
+		}
+		if err = txApp.Save(event); err != nil {
+			return fmt.Errorf("保存状元变更记录失败: %w", err)
+		}
+
+		// 被夺走状元的用户收到通知，自己刷新自己的状元不通知
+		if current == nil || current.UserId() == history.UserId() {
+			return nil
+		}
+		user := new(model.User)
+		if err = txApp.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.CommonFieldId: history.UserId()}).One(user); err != nil {
+			return fmt.Errorf("查找用户失败: %w", err)
+		}
+		_, err = service.notificationService.Send(txApp, current.UserId(), NotificationTypeChampionDethroned,
+			"你的状元被夺走了",
+			fmt.Sprintf("%s 博出了 %s，夺走了你的 %s", user.Nickname(), result.PrizeName, current.PrizeName()),
+			map[string]any{
+				"event_id":            event.Id,
+				"history_id":          history.Id,
+				"user_id":             history.UserId(),
+				"previous_history_id": current.HistoryId(),
+			})
+		if err != nil {
+			return fmt.Errorf("发送状元通知失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Current 当前状元，没有时返回 sql.ErrNoRows
+func (service *ChampionService) Current() (*model.ChampionEvent, error) {
+	return service.current(service.app)
+}
+
+// History 状元变更历史，按时间倒序
+func (service *ChampionService) History(limit int) ([]ChampionRecord, error) {
+	return queryChampionRecords(service.app, limit)
+}
+
+func (service *ChampionService) current(app core.App) (*model.ChampionEvent, error) {
+	event := new(model.ChampionEvent)
+	if err := app.RecordQuery(model.DbNameChampionEvents).
+		OrderBy(model.ChampionEventsFieldCreated+" desc", model.CommonFieldId+" desc").
+		Limit(1).
+		One(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func queryChampionRecords(app core.App, limit int) ([]ChampionRecord, error) {
+	records := []ChampionRecord{}
+	if err := app.DB().
+		NewQuery(`
+			SELECT c.id, c.historyId, c.userId, c.previousHistoryId, c.previousUserId,
+			       c.prizeLevel, c.prizeName, c.dices, c.created,
+			       u.name as username, u.nickname, u.avatar
+			FROM champion_events c
+			LEFT JOIN users u ON c.userId = u.id
+			ORDER BY c.created DESC, c.id DESC
+			LIMIT {:limit}
+		`).
+		Bind(dbx.Params{"limit": limit}).
+		All(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/mooncakeGambling"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestChampion 开启全服状元模式
+func newTestChampion(t *testing.T, app core.App) *ChampionService {
+	t.Helper()
+	registry := testapp.Registry(t, app, config.Definition{Key: model.ConfigKeyChampion, Default: func() any { return &ChampionConfig{} }})
+	patch, _ := json.Marshal(ChampionConfig{Global: true})
+	if _, _, err := registry.Update(app, model.ConfigKeyChampion, patch); err != nil {
+		t.Fatalf("开启全服状元失败: %v", err)
+	}
+	return NewChampionService(app, registry, NewNotificationService(app))
+}
+
+// topHistory 创建一条指定骰子的博饼记录
+func topHistory(t *testing.T, app core.App, user *model.User, times int, dices [6]int) (*model.Histories, mooncakeGambling.GameResult) {
+	t.Helper()
+	history := newHistory(t, app, user, findReward(t, app, "一秀奖励"), times)
+	history.SetDetails(dices)
+	if err := app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+	return history, mooncakeGambling.NewMooncakeGame().PlayWithDices(dices)
+}
+
+func countChampionEvents(t *testing.T, app core.App) int64 {
+	t.Helper()
+	count, err := app.CountRecords(model.DbNameChampionEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestChampionService_Challenge(t *testing.T) {
+	app := testapp.New(t)
+	service := newTestChampion(t, app)
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+
+	// 非状元不参与争夺
+	history, result := topHistory(t, app, alice, 1, [6]int{4, 4, 4, 1, 2, 3})
+	if event, err := service.Challenge(history, result); err != nil || event != nil {
+		t.Fatalf("非状元不应夺得状元, 得到 %v %v", event, err)
+	}
+
+	// 没有状元时直接夺得
+	history, result = topHistory(t, app, alice, 2, [6]int{4, 4, 4, 4, 2, 3})
+	event, err := service.Challenge(history, result)
+	if err != nil || event == nil || event.UserId() != alice.Id || event.PreviousUserId() != "" {
+		t.Fatalf("第一个状元 = %v, %v", event, err)
+	}
+
+	// 结果相同时先博出者保持
+	tie, tieResult := topHistory(t, app, bob, 1, [6]int{4, 4, 4, 4, 2, 3})
+	if event, err = service.Challenge(tie, tieResult); err != nil || event != nil {
+		t.Fatalf("结果相同时不应夺走状元, 得到 %v %v", event, err)
+	}
+
+	// 更好的结果夺走状元，被夺走的用户收到通知
+	better, betterResult := topHistory(t, app, bob, 2, [6]int{4, 4, 4, 4, 4, 3})
+	event, err = service.Challenge(better, betterResult)
+	if err != nil || event == nil || event.UserId() != bob.Id || event.PreviousUserId() != alice.Id || event.PreviousHistoryId() != history.Id {
+		t.Fatalf("夺得状元 = %v, %v", event, err)
+	}
+	current, err := service.Current()
+	if err != nil || current.Id != event.Id {
+		t.Errorf("当前状元 = %v, %v", current, err)
+	}
+
+	notifications := []*model.Notification{}
+	if err = app.RecordQuery(model.DbNameNotifications).All(&notifications); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].UserId() != alice.Id || notifications[0].Type() != NotificationTypeChampionDethroned {
+		t.Errorf("通知 = %d 条, 期望通知被夺走状元的 alice", len(notifications))
+	}
+
+	// 刷新自己的状元不通知
+	best, bestResult := topHistory(t, app, bob, 3, [6]int{4, 4, 4, 4, 4, 4})
+	if event, err = service.Challenge(best, bestResult); err != nil || event == nil {
+		t.Fatalf("刷新状元失败: %v", err)
+	}
+	if count, _ := app.CountRecords(model.DbNameNotifications); count != 1 {
+		t.Errorf("刷新自己的状元不应通知, 通知 %d 条", count)
+	}
+	if got := countChampionEvents(t, app); got != 3 {
+		t.Errorf("状元变更记录 %d 条, 期望 3 条", got)
+	}
+}
+
+func TestChampionService_ChallengeDisabled(t *testing.T) {
+	app := testapp.New(t)
+	registry := testapp.Registry(t, app, config.Definition{Key: model.ConfigKeyChampion, Default: func() any { return &ChampionConfig{} }})
+	service := NewChampionService(app, registry, NewNotificationService(app))
+	user := testapp.User(t, app, "1001", "alice")
+
+	history, result := topHistory(t, app, user, 1, [6]int{4, 4, 4, 4, 4, 4})
+	if event, err := service.Challenge(history, result); err != nil || event != nil {
+		t.Errorf("未开启全服状元时不应争夺, 得到 %v %v", event, err)
+	}
+}
+
+func TestChampionService_ChallengeConcurrent(t *testing.T) {
+	app := testapp.New(t)
+	service := newTestChampion(t, app)
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+
+	// 两个相同的状元同时挑战，只有一个夺得状元
+	first, result := topHistory(t, app, alice, 1, [6]int{4, 4, 4, 4, 2, 3})
+	second, _ := topHistory(t, app, bob, 1, [6]int{4, 4, 4, 4, 2, 3})
+	var wg sync.WaitGroup
+	for _, history := range []*model.Histories{first, second} {
+		wg.Go(func() {
+			if _, err := service.Challenge(history, result); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got := countChampionEvents(t, app); got != 1 {
+		t.Errorf("状元变更记录 %d 条, 期望 1 条", got)
+	}
+	if count, _ := app.CountRecords(model.DbNameNotifications, dbx.HashExp{model.NotificationsFieldType: NotificationTypeChampionDethroned}); count != 0 {
+		t.Errorf("结果相同时不应通知, 通知 %d 条", count)
+	}
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 主密钥的环境变量，格式为 id:base64，多个密钥用逗号或换行分隔，第一个用于加密，其余仅用于解密（轮换）
+const (
+	KeysEnv    = "CONFIG_ENCRYPTION_KEYS"
+	KeyFileEnv = "CONFIG_ENCRYPTION_KEY_FILE"
+)
+
+// encryptedPrefix 加密值的前缀，完整格式为 enc:v1:<密钥 id>:<加密后的数据密钥>:<加密后的内容>
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrKeyringMissing = errors.New("配置中有加密字段，但未配置主密钥 " + KeysEnv + " 或 " + KeyFileEnv)
+	ErrKeyNotFound    = errors.New("找不到加密使用的主密钥")
+)
+
+// Keyring 信封加密的主密钥：每个值使用随机的数据密钥 AES-256-GCM 加密，数据密钥再用主密钥加密后一起保存
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// LoadKeyring 从环境变量或密钥文件读取主密钥，都未配置时返回 nil
+func LoadKeyring() (*Keyring, error) {
+	text := os.Getenv(KeysEnv)
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return ParseKeyring(text)
+}
+
+// ParseKeyring 解析 id:base64 格式的密钥列表，密钥长度必须为 32 字节
+func ParseKeyring(text string) (*Keyring, error) {
+	keyring := &Keyring{keys: map[string][]byte{}}
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("密钥格式应为 id:base64")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不是有效的 base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("密钥 %s 的长度应为 32 字节", id)
+		}
+		if _, exists := keyring.keys[id]; exists {
+			return nil, fmt.Errorf("密钥 %s 重复", id)
+		}
+		if keyring.primary == "" {
+			keyring.primary = id
+		}
+		keyring.keys[id] = key
+	}
+	if keyring.primary == "" {
+		return nil, errors.New("未找到有效的密钥")
+	}
+	return keyring, nil
+}
+
+// GenerateKey 生成 id:base64 格式的新密钥
+func GenerateKey() (string, error) {
+	id := make([]byte, 4)
+	key := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id) + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Primary 用于加密的主密钥 id
+func (keyring *Keyring) Primary() string {
+	return keyring.primary
+}
+
+// Encrypt 使用主密钥加密，aad 用于绑定配置项与字段，防止密文被挪用到其他字段
+func (keyring *Keyring) Encrypt(plaintext string, aad string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(keyring.keys[keyring.primary], dataKey, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	content, err := seal(dataKey, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + keyring.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(content), nil
+}
+
+// Decrypt 解密 Encrypt 的结果，未加密的值原样返回
+func (keyring *Keyring) Decrypt(value string, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if keyring == nil {
+		return "", ErrKeyringMissing
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("密文格式错误")
+	}
+	key, ok := keyring.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, parts[0])
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("数据密钥格式错误: %w", err)
+	}
+	content, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %w", err)
+	}
+
+	dataKey, err := open(key, wrapped, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+	plaintext, err := open(dataKey, content, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted 是否为加密后的值
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// KeyId 加密值使用的主密钥 id，未加密时返回空字符串
+func KeyId(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	return id
+}
+
+// seal AES-256-GCM 加密，返回 nonce 与密文拼接的结果
+func seal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key []byte, data []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("密文长度错误")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
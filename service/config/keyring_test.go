@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	keyring, err := ParseKeyring(strings.Join(keys, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := testKeyring(t, key)
+
+	encrypted, err := keyring.Encrypt("api-key", "fishpi.api_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || KeyId(encrypted) != keyring.Primary() || strings.Contains(encrypted, "api-key") {
+		t.Fatalf("加密结果错误 %s", encrypted)
+	}
+	if plaintext, err := keyring.Decrypt(encrypted, "fishpi.api_key"); err != nil || plaintext != "api-key" {
+		t.Errorf("解密结果 %q %v", plaintext, err)
+	}
+	if _, err = keyring.Decrypt(encrypted, "fishpi.password"); err == nil {
+		t.Error("aad 不一致时应解密失败")
+	}
+	if plaintext, err := keyring.Decrypt("plain", "fishpi.api_key"); err != nil || plaintext != "plain" {
+		t.Errorf("明文应原样返回 %q %v", plaintext, err)
+	}
+
+	var missing *Keyring
+	if _, err = missing.Decrypt(encrypted, "fishpi.api_key"); !errors.Is(err, ErrKeyringMissing) {
+		t.Errorf("未配置密钥时应返回 ErrKeyringMissing %v", err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+
+	encrypted, err := testKeyring(t, oldKey).Encrypt("password", "fishpi.password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新密钥在前，旧密钥仍可解密
+	rotated := testKeyring(t, newKey, oldKey)
+	if plaintext, err := rotated.Decrypt(encrypted, "fishpi.password"); err != nil || plaintext != "password" {
+		t.Errorf("轮换后应能解密旧密文 %q %v", plaintext, err)
+	}
+	if reencrypted, _ := rotated.Encrypt("password", "fishpi.password"); KeyId(reencrypted) != rotated.Primary() {
+		t.Error("应使用新的主密钥加密")
+	}
+
+	if _, err = testKeyring(t, newKey).Decrypt(encrypted, "fishpi.password"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("移除旧密钥后应返回 ErrKeyNotFound %v", err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	key, _ := GenerateKey()
+	id, _, _ := strings.Cut(key, ":")
+
+	keyring, err := ParseKeyring("# 注释\n" + key + "\n")
+	if err != nil || keyring.Primary() != id {
+		t.Errorf("解析结果 %v %v", keyring, err)
+	}
+
+	for _, text := range []string{"", "no-colon", "a:not-base64!", "a:c2hvcnQ=", key + "," + key} {
+		if _, err = ParseKeyring(text); err == nil {
+			t.Errorf("%q 应解析失败", text)
+		}
+	}
+}
+
+func TestRegistrySecrets(t *testing.T) {
+	key, _ := GenerateKey()
+	registry := &Registry{keyring: testKeyring(t, key)}
+
+	data, err := registry.encrypt("test", testDefinition, []byte(`{"base_url":"https://example.com","key":"secret","retries":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]any{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(fields["key"].(string)) || fields["base_url"] != "https://example.com" || fields["retries"] != float64(3) {
+		t.Fatalf("只应加密敏感字段 %s", data)
+	}
+
+	// 已加密的字段不会重复加密
+	if again, _ := registry.encrypt("test", testDefinition, data); string(again) != string(data) {
+		t.Error("已加密的字段不应重复加密")
+	}
+
+	data, err = registry.decrypt("test", testDefinition, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := decode(testDefinition, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.(*testConfig).Key != "secret" {
+		t.Errorf("解密结果错误 %s", data)
+	}
+
+	if _, err = (&Registry{}).decrypt("other", testDefinition, []byte(`{"key":"`+fields["key"].(string)+`"}`)); err == nil {
+		t.Error("未配置密钥时解密应失败")
+	}
+}
@@ -0,0 +1,463 @@
+package config
+
+import (
+	"bless-activity/model"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SecretMask 敏感字段读取时的掩码，更新时传入掩码表示保持原值
+const SecretMask = "******"
+
+var ErrUnknownKey = errors.New("未知的配置项")
+
+// Definition 配置项定义，Default 返回带默认值的配置指针，同时决定配置的 Go 类型
+//
+// 配置类型实现 Validate() error 时会在保存前校验，字段带 secret:"true" 标签时读取会被掩码
+type Definition struct {
+	Key     model.ConfigKey
+	Default func() any
+}
+
+// Registry 类型化的配置注册表，缓存解析后的配置，configs 记录变更后重新加载并通知订阅者
+//
+// 配置了主密钥时，敏感字段在保存前加密，读取时透明解密
+type Registry struct {
+	app     core.App
+	logger  *slog.Logger
+	keyring *Keyring
+
+	mutex       sync.RWMutex
+	definitions map[model.ConfigKey]Definition
+	values      map[model.ConfigKey]any
+	subscribers map[model.ConfigKey][]func(value any)
+}
+
+func NewRegistry(app core.App, keyring *Keyring) *Registry {
+	registry := &Registry{
+		app:         app,
+		logger:      app.Logger().WithGroup("config"),
+		keyring:     keyring,
+		definitions: map[model.ConfigKey]Definition{},
+		values:      map[model.ConfigKey]any{},
+		subscribers: map[model.ConfigKey][]func(value any){},
+	}
+
+	// 后台直接编辑 configs 记录时同样加密、校验并热加载
+	app.OnRecordCreate(model.DbNameConfigs).BindFunc(registry.onSave)
+	app.OnRecordUpdate(model.DbNameConfigs).BindFunc(registry.onSave)
+	app.OnRecordValidate(model.DbNameConfigs).BindFunc(registry.onValidate)
+	app.OnRecordAfterCreateSuccess(model.DbNameConfigs).BindFunc(registry.onChange)
+	app.OnRecordAfterUpdateSuccess(model.DbNameConfigs).BindFunc(registry.onChange)
+	app.OnRecordAfterDeleteSuccess(model.DbNameConfigs).BindFunc(registry.onChange)
+
+	return registry
+}
+
+// Register 注册配置项，需要在 Load 之前调用
+func (registry *Registry) Register(definitions ...Definition) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, definition := range definitions {
+		registry.definitions[definition.Key] = definition
+	}
+}
+
+// Load 读取所有已注册的配置，没有记录时使用默认值
+func (registry *Registry) Load() error {
+	for _, key := range registry.Keys() {
+		if err := registry.reload(key); err != nil {
+			return fmt.Errorf("加载配置 %s 失败: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Keys 已注册的配置项
+func (registry *Registry) Keys() []model.ConfigKey {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	keys := make([]model.ConfigKey, 0, len(registry.definitions))
+	for key := range registry.definitions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Value 当前配置，返回值为配置指针，调用方不应修改
+func (registry *Registry) Value(key model.ConfigKey) (any, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	value, ok := registry.values[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	return value, nil
+}
+
+// Get 当前配置的副本
+func Get[T any](registry *Registry, key model.ConfigKey) (T, error) {
+	var zero T
+	value, err := registry.Value(key)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := value.(*T)
+	if !ok {
+		return zero, fmt.Errorf("配置 %s 的类型为 %T", key, value)
+	}
+	return *typed, nil
+}
+
+// Subscribe 订阅配置变更，回调参数为新的配置副本
+func Subscribe[T any](registry *Registry, key model.ConfigKey, fn func(value T)) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.subscribers[key] = append(registry.subscribers[key], func(value any) {
+		if typed, ok := value.(*T); ok {
+			fn(*typed)
+		}
+	})
+}
+
+// Masked 当前配置，敏感字段已掩码
+func (registry *Registry) Masked(key model.ConfigKey) (any, error) {
+	value, err := registry.Value(key)
+	if err != nil {
+		return nil, err
+	}
+	return mask(value), nil
+}
+
+// Default 配置默认值，敏感字段已掩码
+func (registry *Registry) Default(key model.ConfigKey) (any, error) {
+	definition, err := registry.definition(key)
+	if err != nil {
+		return nil, err
+	}
+	return mask(definition.Default()), nil
+}
+
+// Schema 配置的 JSON Schema
+func (registry *Registry) Schema(key model.ConfigKey) (map[string]any, error) {
+	definition, err := registry.definition(key)
+	if err != nil {
+		return nil, err
+	}
+	return schemaOf(reflect.TypeOf(definition.Default()).Elem()), nil
+}
+
+// Update 将 patch 中的字段合并到当前配置，校验后保存，返回掩码后的修改前后配置
+//
+// patch 中的敏感字段为掩码时保持原值，校验失败时返回 validation.Errors
+func (registry *Registry) Update(txApp core.App, key model.ConfigKey, patch json.RawMessage) (before any, after any, err error) {
+	definition, err := registry.definition(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := registry.Value(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := merge(current, patch)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := decode(definition, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := new(model.Config)
+	if err = txApp.RecordQuery(model.DbNameConfigs).Where(dbx.HashExp{model.ConfigsFieldKey: key}).One(config); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNameConfigs)
+		if err != nil {
+			return nil, nil, err
+		}
+		config = model.NewConfigFromCollection(collection)
+		config.SetKey(key)
+	}
+	config.SetValue(string(data))
+	if err = txApp.Save(config); err != nil {
+		return nil, nil, err
+	}
+
+	return mask(current), mask(value), nil
+}
+
+func (registry *Registry) definition(key model.ConfigKey) (Definition, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	definition, ok := registry.definitions[key]
+	if !ok {
+		return Definition{}, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	return definition, nil
+}
+
+// reload 从数据库重新读取配置并通知订阅者
+func (registry *Registry) reload(key model.ConfigKey) error {
+	definition, err := registry.definition(key)
+	if err != nil {
+		return err
+	}
+
+	value := definition.Default()
+	config := new(model.Config)
+	if err = registry.app.RecordQuery(model.DbNameConfigs).Where(dbx.HashExp{model.ConfigsFieldKey: key}).One(config); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	} else {
+		data, err := registry.decrypt(key, definition, []byte(config.Value()))
+		if err != nil {
+			return err
+		}
+		if value, err = decode(definition, data); err != nil {
+			return err
+		}
+	}
+
+	registry.mutex.Lock()
+	registry.values[key] = value
+	subscribers := slices.Clone(registry.subscribers[key])
+	registry.mutex.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(value)
+	}
+	return nil
+}
+
+// onSave 保存前加密明文的敏感字段
+func (registry *Registry) onSave(event *core.RecordEvent) error {
+	config := model.NewConfig(event.Record)
+	key := model.ConfigKey(config.GetString(model.ConfigsFieldKey))
+	definition, err := registry.definition(key)
+	if err != nil {
+		return event.Next()
+	}
+
+	data, err := registry.encrypt(key, definition, []byte(config.Value()))
+	if err != nil {
+		return err
+	}
+	config.SetValue(string(data))
+	return event.Next()
+}
+
+func (registry *Registry) onValidate(event *core.RecordEvent) error {
+	config := model.NewConfig(event.Record)
+	key := model.ConfigKey(config.GetString(model.ConfigsFieldKey))
+	definition, err := registry.definition(key)
+	if err != nil {
+		return event.Next()
+	}
+
+	data, err := registry.decrypt(key, definition, []byte(config.Value()))
+	if err != nil {
+		return err
+	}
+	if _, err = decode(definition, data); err != nil {
+		return err
+	}
+	return event.Next()
+}
+
+// Reencrypt 使用当前主密钥重新加密所有配置的敏感字段，用于轮换主密钥或加密已有的明文配置，返回更新的记录数
+func (registry *Registry) Reencrypt() (int, error) {
+	if registry.keyring == nil {
+		return 0, ErrKeyringMissing
+	}
+
+	count := 0
+	err := registry.app.RunInTransaction(func(txApp core.App) error {
+		configs := []*model.Config{}
+		if err := txApp.RecordQuery(model.DbNameConfigs).All(&configs); err != nil {
+			return err
+		}
+		for _, config := range configs {
+			key := model.ConfigKey(config.GetString(model.ConfigsFieldKey))
+			definition, err := registry.definition(key)
+			if err != nil || len(secretFields(reflect.TypeOf(definition.Default()).Elem())) == 0 {
+				continue
+			}
+
+			// 写回明文，由保存钩子使用主密钥加密
+			data, err := registry.decrypt(key, definition, []byte(config.Value()))
+			if err != nil {
+				return fmt.Errorf("解密配置 %s 失败: %w", key, err)
+			}
+			config.SetValue(string(data))
+			if err = txApp.Save(config); err != nil {
+				return fmt.Errorf("保存配置 %s 失败: %w", key, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// decrypt 解密敏感字段，未加密的字段原样保留
+func (registry *Registry) decrypt(key model.ConfigKey, definition Definition, data []byte) ([]byte, error) {
+	return transformSecrets(definition, data, func(name string, value string) (string, error) {
+		return registry.keyring.Decrypt(value, key.String()+"."+name)
+	})
+}
+
+// encrypt 使用主密钥加密明文的敏感字段，已加密的字段保持不变，未配置主密钥时不加密
+func (registry *Registry) encrypt(key model.ConfigKey, definition Definition, data []byte) ([]byte, error) {
+	if registry.keyring == nil {
+		return data, nil
+	}
+	return transformSecrets(definition, data, func(name string, value string) (string, error) {
+		if value == "" || IsEncrypted(value) {
+			return value, nil
+		}
+		return registry.keyring.Encrypt(value, key.String()+"."+name)
+	})
+}
+
+// transformSecrets 对 JSON 对象中字符串类型的敏感字段逐个转换
+func transformSecrets(definition Definition, data []byte, fn func(name string, value string) (string, error)) ([]byte, error) {
+	secrets := secretFields(reflect.TypeOf(definition.Default()).Elem())
+	if len(secrets) == 0 {
+		return data, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// 格式错误交给 decode 返回校验错误
+		return data, nil
+	}
+	changed := false
+	for name := range secrets {
+		value := ""
+		if raw, ok := fields[name]; !ok || json.Unmarshal(raw, &value) != nil {
+			continue
+		}
+		result, err := fn(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if result == value {
+			continue
+		}
+		if fields[name], err = json.Marshal(result); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(fields)
+}
+
+func (registry *Registry) onChange(event *core.RecordEvent) error {
+	key := model.ConfigKey(event.Record.GetString(model.ConfigsFieldKey))
+	if _, err := registry.definition(key); err == nil {
+		if err = registry.reload(key); err != nil {
+			registry.logger.Error("重新加载配置失败", slog.String("key", key.String()), slog.Any("err", err))
+		} else {
+			registry.logger.Info("配置已重新加载", slog.String("key", key.String()))
+		}
+	}
+	return event.Next()
+}
+
+// decode 按定义的类型严格解析配置并校验，失败时返回 validation.Errors
+func decode(definition Definition, data []byte) (any, error) {
+	value := definition.Default()
+	t := reflect.TypeOf(value).Elem()
+
+	// 结构体类型先检查未知字段，便于按字段返回错误
+	if t.Kind() == reflect.Struct {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, validation.Errors{"value": validation.NewError("validation_invalid_json", "配置应为 JSON 对象")}
+		}
+		known := jsonFields(t)
+		errs := validation.Errors{}
+		for name := range fields {
+			if _, ok := known[name]; !ok {
+				errs[name] = validation.NewError("validation_unknown_field", "未知字段")
+			}
+		}
+		if len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		typeErr := new(json.UnmarshalTypeError)
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, validation.Errors{typeErr.Field: validation.NewError("validation_invalid_type", fmt.Sprintf("类型错误，应为 %s", jsonType(typeErr.Type)))}
+		}
+		return nil, validation.Errors{"value": validation.NewError("validation_invalid_json", err.Error())}
+	}
+
+	if validatable, ok := value.(validation.Validatable); ok {
+		if err := validatable.Validate(); err != nil {
+			errs := validation.Errors{}
+			if errors.As(err, &errs) {
+				return nil, errs
+			}
+			return nil, validation.Errors{"value": validation.NewError("validation_invalid_value", err.Error())}
+		}
+	}
+	return value, nil
+}
+
+// merge 将 patch 的顶层字段覆盖到当前配置上，掩码的敏感字段保持原值
+func merge(current any, patch json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(current).Elem().Kind() != reflect.Struct && reflect.TypeOf(current).Elem().Kind() != reflect.Map {
+		return patch, nil
+	}
+
+	base := map[string]json.RawMessage{}
+	if err = json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(patch, &fields); err != nil {
+		return nil, validation.Errors{"value": validation.NewError("validation_invalid_json", "配置应为 JSON 对象")}
+	}
+
+	secrets := secretFields(reflect.TypeOf(current).Elem())
+	for name, raw := range fields {
+		if secrets[name] && string(raw) == `"`+SecretMask+`"` {
+			continue
+		}
+		base[name] = raw
+	}
+	return json.Marshal(base)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type testConfig struct {
+	BaseUrl string `json:"base_url"`
+	Key     string `json:"key" secret:"true"`
+	Retries int    `json:"retries"`
+}
+
+func (config *testConfig) Validate() error {
+	if config.Retries < 0 {
+		return validation.Errors{"retries": validation.NewError("validation_min", "不能为负数")}
+	}
+	return nil
+}
+
+var testDefinition = Definition{Default: func() any { return &testConfig{BaseUrl: "https://example.com", Retries: 3} }}
+
+func TestDecode(t *testing.T) {
+	value, err := decode(testDefinition, []byte(`{"key":"secret"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config := value.(*testConfig); config.BaseUrl != "https://example.com" || config.Key != "secret" || config.Retries != 3 {
+		t.Errorf("缺省字段应使用默认值 %+v", config)
+	}
+
+	cases := map[string]string{
+		`{"unknown":1}`:     "unknown",
+		`{"retries":"3"}`:   "retries",
+		`{"retries":-1}`:    "retries",
+		`["not", "object"]`: "value",
+		`{"base_url":"a"`:   "value",
+	}
+	for data, field := range cases {
+		_, err := decode(testDefinition, []byte(data))
+		errs := validation.Errors{}
+		if !errors.As(err, &errs) || errs[field] == nil {
+			t.Errorf("%s err = %v, 应包含字段 %s", data, err, field)
+		}
+	}
+}
+
+func TestMergeAndMask(t *testing.T) {
+	current := &testConfig{BaseUrl: "https://example.com", Key: "secret", Retries: 3}
+
+	data, err := merge(current, json.RawMessage(`{"base_url":"https://fishpi.cn","key":"`+SecretMask+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := decode(testDefinition, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config := value.(*testConfig); config.BaseUrl != "https://fishpi.cn" || config.Key != "secret" || config.Retries != 3 {
+		t.Errorf("掩码应保持原值 %+v", config)
+	}
+
+	masked := mask(current).(map[string]any)
+	if masked["key"] != SecretMask || masked["base_url"] != "https://example.com" {
+		t.Errorf("mask = %v", masked)
+	}
+	if masked := mask(&testConfig{}).(map[string]any); masked["key"] != "" {
+		t.Errorf("空的敏感字段不需要掩码 %v", masked)
+	}
+
+	schema := schemaOf(reflect.TypeOf(current).Elem())
+	properties := schema["properties"].(map[string]any)
+	if properties["key"].(map[string]any)["writeOnly"] != true || properties["retries"].(map[string]any)["type"] != "integer" {
+		t.Errorf("schema = %v", schema)
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonFields 结构体按 JSON 名称索引的字段
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// secretFields 带 secret:"true" 标签的字段
+func secretFields(t reflect.Type) map[string]bool {
+	secrets := map[string]bool{}
+	if t.Kind() != reflect.Struct {
+		return secrets
+	}
+	for name, field := range jsonFields(t) {
+		if field.Tag.Get("secret") == "true" {
+			secrets[name] = true
+		}
+	}
+	return secrets
+}
+
+// mask 将配置转为 JSON 对象并替换非空的敏感字段
+func mask(value any) any {
+	secrets := secretFields(reflect.TypeOf(value).Elem())
+	if len(secrets) == 0 {
+		return value
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	fields := map[string]any{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	for name := range secrets {
+		if text, ok := fields[name].(string); ok && text != "" {
+			fields[name] = SecretMask
+		}
+	}
+	return fields
+}
+
+// schemaOf 根据 Go 类型生成 JSON Schema，敏感字段标记为 writeOnly
+func schemaOf(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		properties := map[string]any{}
+		for name, field := range jsonFields(t) {
+			property := schemaOf(field.Type)
+			if field.Tag.Get("secret") == "true" {
+				property["writeOnly"] = true
+			}
+			properties[name] = property
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	default:
+		return map[string]any{"type": jsonType(t)}
+	}
+}
+
+// jsonType Go 类型对应的 JSON 类型名称
+func jsonType(t reflect.Type) string {
+	if t == nil {
+		return "null"
+	}
+	if t == timeType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,399 @@
+package export
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type kind int
+
+const (
+	kindText kind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindJSON
+	kindTime // 仅用于过滤条件
+)
+
+type column struct {
+	name string
+	expr string
+	kind kind
+}
+
+type filter struct {
+	expr string
+	op   string
+	kind kind
+}
+
+// dataset 可导出的数据集，base 为默认条件，指定 override 过滤条件时不使用
+type dataset struct {
+	from     string
+	columns  []column
+	filters  map[string]filter
+	orderBy  string
+	base     string
+	override string
+}
+
+// createdFilters 按创建时间过滤
+func createdFilters(expr string) map[string]filter {
+	return map[string]filter{
+		"from": {expr: expr, op: ">=", kind: kindTime},
+		"to":   {expr: expr, op: "<=", kind: kindTime},
+	}
+}
+
+func withFilters(filters map[string]filter, more map[string]filter) map[string]filter {
+	for name, f := range more {
+		filters[name] = f
+	}
+	return filters
+}
+
+func voteCount(voteType string) string {
+	return "(SELECT COUNT(*) FROM votes v WHERE v.articleId = ar.id AND v.voteType = '" + voteType + "')"
+}
+
+func winner(path string) string {
+	return "json_extract(w.value, '$." + path + "')"
+}
+
+var datasets = map[model.ExportDataset]dataset{
+	model.ExportDatasetHistories: {
+		from: "histories h LEFT JOIN users u ON u.id = h.userId LEFT JOIN rewards r ON r.id = h.rewardId LEFT JOIN awards a ON a.id = h.awardId",
+		columns: []column{
+			{"id", "h.id", kindText},
+			{"user_id", "h.userId", kindText},
+			{"username", "u.name", kindText},
+			{"times", "h.times", kindInt},
+			{"dices", "h.details", kindJSON},
+			{"prize_level", "r.level", kindInt},
+			{"award_name", "a.name", kindText},
+			{"reward_name", "r.name", kindText},
+			{"got_reward", "h.gotReward", kindBool},
+			{"is_top", "h.isTop", kindBool},
+			{"is_best", "h.isBest", kindBool},
+			{"fee_id", "h.feeId", kindText},
+			{"quota_source", "h.quotaSource", kindText},
+			{"seed", "h.seed", kindText},
+			{"created", "h.created", kindText},
+		},
+		filters: withFilters(createdFilters("h.created"), map[string]filter{
+			"user_id":     {expr: "h.userId", op: "=", kind: kindText},
+			"username":    {expr: "u.name", op: "=", kind: kindText},
+			"prize_level": {expr: "r.level", op: "=", kind: kindInt},
+			"got_reward":  {expr: "h.gotReward", op: "=", kind: kindBool},
+			"is_top":      {expr: "h.isTop", op: "=", kind: kindBool},
+		}),
+		orderBy: "h.created, h.id",
+	},
+	model.ExportDatasetVotes: {
+		from: "votes v LEFT JOIN users f ON f.id = v.fromUserId LEFT JOIN users t ON t.id = v.toUserId LEFT JOIN articles ar ON ar.id = v.articleId",
+		columns: []column{
+			{"id", "v.id", kindText},
+			{"from_user_id", "v.fromUserId", kindText},
+			{"from_username", "f.name", kindText},
+			{"to_user_id", "v.toUserId", kindText},
+			{"to_username", "t.name", kindText},
+			{"article_id", "v.articleId", kindText},
+			{"article_title", "ar.title", kindText},
+			{"vote_type", "v.voteType", kindText},
+			{"flagged", "v.flagged", kindBool},
+			{"flag_reason", "v.flagReason", kindText},
+			{"created", "v.created", kindText},
+		},
+		filters: withFilters(createdFilters("v.created"), map[string]filter{
+			"vote_type":    {expr: "v.voteType", op: "=", kind: kindText},
+			"from_user_id": {expr: "v.fromUserId", op: "=", kind: kindText},
+			"to_user_id":   {expr: "v.toUserId", op: "=", kind: kindText},
+			"article_id":   {expr: "v.articleId", op: "=", kind: kindText},
+			"flagged":      {expr: "v.flagged", op: "=", kind: kindBool},
+		}),
+		orderBy: "v.created, v.id",
+	},
+	model.ExportDatasetPoints: {
+		from: "points p LEFT JOIN users u ON u.id = p.userId",
+		columns: []column{
+			{"id", "p.id", kindText},
+			{"user_id", "p.userId", kindText},
+			{"username", "u.name", kindText},
+			{"history_id", "p.historyId", kindText},
+			{"point", "p.point", kindInt},
+			{"status", "p.status", kindText},
+			{"source", "p.source", kindText},
+			{"key", "p.key", kindText},
+			{"memo", "p.memo", kindText},
+			{"error", "p.error", kindText},
+			{"retries", "p.retries", kindInt},
+			{"created", "p.created", kindText},
+			{"updated", "p.updated", kindText},
+		},
+		filters: withFilters(createdFilters("p.created"), map[string]filter{
+			"user_id":  {expr: "p.userId", op: "=", kind: kindText},
+			"username": {expr: "u.name", op: "=", kind: kindText},
+			"status":   {expr: "p.status", op: "=", kind: kindText},
+			"source":   {expr: "p.source", op: "=", kind: kindText},
+		}),
+		orderBy: "p.created, p.id",
+	},
+	// 排名为过滤后按评分的排名
+	model.ExportDatasetArticles: {
+		from: "articles ar LEFT JOIN users u ON u.id = ar.userId",
+		columns: []column{
+			{"rank", "RANK() OVER (ORDER BY ar.score DESC)", kindInt},
+			{"article_id", "ar.id", kindText},
+			{"o_id", "ar.oId", kindText},
+			{"title", "ar.title", kindText},
+			{"user_id", "ar.userId", kindText},
+			{"username", "u.name", kindText},
+			{"view_count", "ar.viewCount", kindInt},
+			{"good_cnt", "ar.goodCnt", kindInt},
+			{"comment_count", "ar.commentCount", kindInt},
+			{"collect_cnt", "ar.collectCnt", kindInt},
+			{"thank_cnt", "ar.thankCnt", kindInt},
+			{"score", "ar.score", kindFloat},
+			{"career_votes", voteCount(model.VoteTypeCareer), kindInt},
+			{"romance_votes", voteCount(model.VoteTypeRomance), kindInt},
+			{"wealth_votes", voteCount(model.VoteTypeWealth), kindInt},
+			{"flagged", "ar.flagged", kindBool},
+			{"disqualified", "ar.disqualified", kindBool},
+			{"created_at", "ar.createdAt", kindText},
+		},
+		filters: map[string]filter{
+			"user_id":      {expr: "ar.userId", op: "=", kind: kindText},
+			"flagged":      {expr: "ar.flagged", op: "=", kind: kindBool},
+			"disqualified": {expr: "ar.disqualified", op: "=", kind: kindBool},
+		},
+		orderBy: "ar.score DESC, ar.id",
+	},
+	// 默认导出最近一次结算的获奖名单
+	model.ExportDatasetSettlement: {
+		from: "settlements s, json_each(s.report, '$.winners') w LEFT JOIN users u ON u.id = " + winner("userId") +
+			" LEFT JOIN rewards r ON r.id = " + winner("rewardId"),
+		columns: []column{
+			{"run_no", "s.runNo", kindInt},
+			{"rank", winner("rank"), kindInt},
+			{"history_id", winner("historyId"), kindText},
+			{"user_id", winner("userId"), kindText},
+			{"username", "u.name", kindText},
+			{"reward_id", winner("rewardId"), kindText},
+			{"reward_name", "r.name", kindText},
+			{"reward_point", "r.point", kindInt},
+			{"times", winner("times"), kindInt},
+			{"dices", winner("dices"), kindJSON},
+			{"prize_level", winner("prizeLevel"), kindInt},
+			{"prize_name", winner("prizeName"), kindText},
+			{"settled_at", "s.created", kindText},
+		},
+		filters: map[string]filter{
+			"run_no":  {expr: "s.runNo", op: "=", kind: kindInt},
+			"user_id": {expr: winner("userId"), op: "=", kind: kindText},
+		},
+		orderBy:  "s.runNo, " + winner("rank"),
+		base:     "s.runNo = (SELECT MAX(runNo) FROM settlements)",
+		override: "run_no",
+	},
+}
+
+// Request 导出请求，Columns 为空时导出全部列，Filters 的值均为字符串，按列类型解析
+type Request struct {
+	Dataset model.ExportDataset `json:"dataset"`
+	Format  model.ExportFormat  `json:"format"`
+	Columns []string            `json:"columns"`
+	Filters map[string]string   `json:"filters"`
+}
+
+// DatasetInfo 数据集可选的列与过滤条件
+type DatasetInfo struct {
+	Name    model.ExportDataset `json:"name"`
+	Columns []string            `json:"columns"`
+	Filters []string            `json:"filters"`
+}
+
+// Datasets 所有可导出的数据集
+func Datasets() []DatasetInfo {
+	infos := make([]DatasetInfo, 0, len(datasets))
+	for _, name := range model.ExportDatasetValues() {
+		ds := datasets[name]
+		info := DatasetInfo{Name: name, Columns: make([]string, 0, len(ds.columns))}
+		for _, c := range ds.columns {
+			info.Columns = append(info.Columns, c.name)
+		}
+		for f := range ds.filters {
+			info.Filters = append(info.Filters, f)
+		}
+		slices.Sort(info.Filters)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// query 校验后的导出请求
+type query struct {
+	dataset dataset
+	columns []column
+	where   string
+	params  dbx.Params
+}
+
+// build 校验请求并生成查询，错误为 validation.Errors
+func build(request Request) (*query, error) {
+	errs := validation.Errors{}
+	if !request.Format.IsValid() {
+		errs["format"] = validation.NewError("validation_invalid_format", "导出格式错误")
+	}
+	ds, ok := datasets[request.Dataset]
+	if !ok {
+		errs["dataset"] = validation.NewError("validation_invalid_dataset", "数据集不存在")
+		return nil, errs
+	}
+
+	q := &query{dataset: ds, params: dbx.Params{}}
+	if len(request.Columns) == 0 {
+		q.columns = ds.columns
+	}
+	for _, name := range request.Columns {
+		i := slices.IndexFunc(ds.columns, func(c column) bool { return c.name == name })
+		if i < 0 {
+			errs["columns"] = validation.NewError("validation_unknown_column", "未知的列: "+name)
+			break
+		}
+		if slices.ContainsFunc(q.columns, func(c column) bool { return c.name == name }) {
+			errs["columns"] = validation.NewError("validation_duplicate_column", "重复的列: "+name)
+			break
+		}
+		q.columns = append(q.columns, ds.columns[i])
+	}
+
+	names := make([]string, 0, len(request.Filters))
+	for name := range request.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	conditions := []string{}
+	if ds.base != "" && request.Filters[ds.override] == "" {
+		conditions = append(conditions, ds.base)
+	}
+	for i, name := range names {
+		value := request.Filters[name]
+		f, ok := ds.filters[name]
+		if !ok {
+			errs["filters."+name] = validation.NewError("validation_unknown_filter", "未知的过滤条件")
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		parsed, err := parseFilter(f.kind, value)
+		if err != nil {
+			errs["filters."+name] = err
+			continue
+		}
+		param := "f" + strconv.Itoa(i)
+		conditions = append(conditions, fmt.Sprintf("%s %s {:%s}", f.expr, f.op, param))
+		q.params[param] = parsed
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(conditions) > 0 {
+		q.where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return q, nil
+}
+
+func parseFilter(k kind, value string) (any, error) {
+	switch k {
+	case kindInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, validation.NewError("validation_invalid_int", "应为整数")
+		}
+		return n, nil
+	case kindBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, validation.NewError("validation_invalid_bool", "应为 true 或 false")
+		}
+		return b, nil
+	case kindTime:
+		t, err := types.ParseDateTime(value)
+		if err != nil || t.IsZero() {
+			return nil, validation.NewError("validation_invalid_time", "时间格式错误")
+		}
+		return t.String(), nil
+	default:
+		return value, nil
+	}
+}
+
+func (q *query) selectSQL() string {
+	exprs := make([]string, len(q.columns))
+	for i, c := range q.columns {
+		exprs[i] = c.expr + " AS " + c.name
+	}
+	return "SELECT " + strings.Join(exprs, ", ") + " FROM " + q.dataset.from + q.where + " ORDER BY " + q.dataset.orderBy
+}
+
+func (q *query) countSQL() string {
+	return "SELECT COUNT(*) FROM " + q.dataset.from + q.where
+}
+
+func (q *query) names() []string {
+	names := make([]string, len(q.columns))
+	for i, c := range q.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// values 按列类型转换一行查询结果
+func (q *query) values(row dbx.NullStringMap, values []any) []any {
+	for i, c := range q.columns {
+		values[i] = convert(c.kind, row[c.name])
+	}
+	return values
+}
+
+func convert(k kind, value sql.NullString) any {
+	if !value.Valid {
+		return nil
+	}
+	switch k {
+	case kindInt:
+		if n, err := strconv.Atoi(value.String); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(value.String, 64); err == nil {
+			return int(f)
+		}
+		return nil
+	case kindFloat:
+		f, err := strconv.ParseFloat(value.String, 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	case kindBool:
+		return value.String == "1" || value.String == "true"
+	case kindJSON:
+		if !json.Valid([]byte(value.String)) {
+			return value.String
+		}
+		return json.RawMessage(value.String)
+	default:
+		return value.String
+	}
+}
@@ -0,0 +1,246 @@
+package export
+
+import (
+	"bless-activity/model"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// StreamLimit 直接下载的最大行数，超过时需要创建后台导出任务
+	StreamLimit = 5000
+	// exportJobId 执行后台导出任务的定时任务
+	exportJobId = "exports"
+)
+
+var ErrTooLarge = fmt.Errorf("数据超过 %d 行，请创建后台导出任务", StreamLimit)
+
+// Service 数据导出：少量数据直接流式下载，大量数据由后台任务导出到 PocketBase 存储后下载
+type Service struct {
+	app     core.App
+	logger  *slog.Logger
+	running sync.Mutex
+}
+
+func NewService(app core.App) *Service {
+	return &Service{
+		app:    app,
+		logger: app.Logger().WithGroup("export"),
+	}
+}
+
+// Start 每分钟执行待处理的导出任务，启动时把中断的任务重新放回队列
+func (service *Service) Start() {
+	if _, err := service.app.DB().Update(model.DbNameExports,
+		dbx.Params{model.ExportsFieldStatus: model.ExportStatusPending.String()},
+		dbx.HashExp{model.ExportsFieldStatus: model.ExportStatusRunning.String()},
+	).Execute(); err != nil {
+		service.logger.Error("恢复中断的导出任务失败", slog.Any("err", err))
+	}
+
+	service.app.Cron().MustAdd(exportJobId, "* * * * *", service.RunPending)
+}
+
+// RunPending 依次执行待处理的导出任务，已有任务在执行时跳过
+func (service *Service) RunPending() {
+	if !service.running.TryLock() {
+		return
+	}
+	defer service.running.Unlock()
+
+	for {
+		export := new(model.Export)
+		if err := service.app.RecordQuery(model.DbNameExports).
+			Where(dbx.HashExp{model.ExportsFieldStatus: model.ExportStatusPending.String()}).
+			OrderBy(model.ExportsFieldCreated+" asc", model.CommonFieldId+" asc").
+			Limit(1).
+			One(export); err != nil {
+			return
+		}
+		// 保存失败时停止，避免反复取到同一个任务
+		if err := service.run(export); err != nil {
+			service.logger.Error("更新导出任务失败", slog.String("export_id", export.Id), slog.Any("err", err))
+			return
+		}
+	}
+}
+
+// Count 导出请求对应的行数
+func (service *Service) Count(request Request) (int, error) {
+	q, err := build(request)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err = service.app.DB().NewQuery(q.countSQL()).Bind(q.params).Row(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Write 按请求的格式把数据写入 w，返回写入的行数
+func (service *Service) Write(w io.Writer, request Request) (int, error) {
+	q, err := build(request)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := service.app.DB().NewQuery(q.selectSQL()).Bind(q.params).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	writer, err := NewWriter(request.Format, w, q.names())
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	values := make([]any, len(q.columns))
+	for rows.Next() {
+		row := dbx.NullStringMap{}
+		if err = rows.ScanMap(row); err != nil {
+			return count, err
+		}
+		if err = writer.Write(q.values(row, values)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// Create 校验请求并创建待处理的导出任务
+func (service *Service) Create(txApp core.App, actor *model.User, request Request) (*model.Export, error) {
+	if _, err := build(request); err != nil {
+		return nil, err
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameExports)
+	if err != nil {
+		return nil, err
+	}
+	export := model.NewExportFromCollection(collection)
+	if actor.IsSuperuser() {
+		export.SetSuperuserId(actor.Id)
+	} else {
+		export.SetActorId(actor.Id)
+	}
+	export.SetDataset(request.Dataset)
+	export.SetFormat(request.Format)
+	export.SetFilters(request.Filters)
+	export.SetColumns(request.Columns)
+	export.SetStatus(model.ExportStatusPending)
+	if err = txApp.Save(export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// List 分页查询导出任务，按创建时间倒序
+func (service *Service) List(page int, perPage int) ([]*model.Export, int, error) {
+	total, err := service.app.CountRecords(model.DbNameExports)
+	if err != nil {
+		return nil, 0, err
+	}
+	exports := []*model.Export{}
+	if err = service.app.RecordQuery(model.DbNameExports).
+		OrderBy(model.ExportsFieldCreated+" desc", model.CommonFieldId+" desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&exports); err != nil {
+		return nil, 0, err
+	}
+	return exports, int(total), nil
+}
+
+// Find 查找导出任务，不存在时返回 sql.ErrNoRows
+func (service *Service) Find(id string) (*model.Export, error) {
+	export := new(model.Export)
+	if err := service.app.RecordQuery(model.DbNameExports).
+		Where(dbx.HashExp{model.CommonFieldId: id}).
+		One(export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// Serve 下载导出任务的文件
+func (service *Service) Serve(res http.ResponseWriter, req *http.Request, export *model.Export) error {
+	fsys, err := service.app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+
+	res.Header().Set("Content-Disposition", "attachment; filename="+export.File())
+	res.Header().Set("Content-Type", ContentType(export.Format()))
+	res.Header().Set("Cache-Control", "no-store")
+	return fsys.Serve(res, req, export.BaseFilesPath()+"/"+export.File(), export.File())
+}
+
+// run 执行导出任务：先写入临时文件，完成后保存到导出记录的文件字段
+func (service *Service) run(export *model.Export) error {
+	logger := service.logger.With(slog.String("export_id", export.Id))
+
+	export.SetStatus(model.ExportStatusRunning)
+	if err := service.app.Save(export); err != nil {
+		return err
+	}
+
+	rows := 0
+	temp, err := os.CreateTemp("", "export-*")
+	if err == nil {
+		defer os.Remove(temp.Name())
+		rows, err = service.export(export, temp)
+	}
+	export.SetFinishedAt(types.NowDateTime())
+	if err != nil {
+		logger.Error("导出失败", slog.Any("err", err))
+		export.SetStatus(model.ExportStatusFailed)
+		export.SetError(err.Error())
+	} else {
+		logger.Info("导出完成", slog.Int("rows", rows))
+		export.SetStatus(model.ExportStatusSuccess)
+		export.SetRows(rows)
+	}
+	return service.app.Save(export)
+}
+
+// export 把导出任务的数据写入临时文件并设置到文件字段，保存记录时上传
+func (service *Service) export(export *model.Export, temp *os.File) (int, error) {
+	request := Request{
+		Dataset: export.Dataset(),
+		Format:  export.Format(),
+		Columns: export.Columns(),
+		Filters: export.Filters(),
+	}
+
+	rows, err := service.Write(temp, request)
+	if err = errors.Join(err, temp.Close()); err != nil {
+		return 0, err
+	}
+
+	file, err := filesystem.NewFileFromPath(temp.Name())
+	if err != nil {
+		return 0, err
+	}
+	file.OriginalName = FileName(request.Dataset, request.Format, time.Now().Format("20060102150405"))
+	file.Name = file.OriginalName
+	export.Set(model.ExportsFieldFile, file)
+	return rows, nil
+}
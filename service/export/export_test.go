@@ -0,0 +1,222 @@
+package export
+
+import (
+	"archive/zip"
+	"bless-activity/model"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range cases {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, 期望 %s", i, got, want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer, err := NewWriter(model.ExportFormatCsv, buf, []string{"name", "times", "dices"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]any{
+		{"张三, 李四", 3, json.RawMessage(`[1,2,3,4,5,6]`)},
+		{nil, 1.5, true},
+	} {
+		if err = writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\ufeffname,times,dices\n\"张三, 李四\",3,\"[1,2,3,4,5,6]\"\n,1.5,true\n"
+	if got := buf.String(); got != want {
+		t.Errorf("csv = %q, 期望 %q", got, want)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer, err := NewWriter(model.ExportFormatJsonl, buf, []string{"z", "a", "dices"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Write([]any{"<名>", nil, json.RawMessage(`[4,4,4,4,1,2]`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 字段按列的顺序输出
+	want := `{"z":"<名>","a":null,"dices":[4,4,4,4,1,2]}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("jsonl = %q, 期望 %q", got, want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer, err := NewWriter(model.ExportFormatXlsx, buf, []string{"name", "times", "top"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Write([]any{"a<b", 6, true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, file := range reader.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(data)
+	}
+	if len(reader.File) != 6 {
+		t.Errorf("xlsx 包含 %d 个文件, 期望 6", len(reader.File))
+	}
+	for _, want := range []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">a&lt;b</t></is></c>`,
+		`<c r="B2"><v>6</v></c>`,
+		`<c r="C2" t="b"><v>1</v></c>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("工作表缺少 %s", want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	q, err := build(Request{
+		Dataset: model.ExportDatasetPoints,
+		Format:  model.ExportFormatCsv,
+		Columns: []string{"user_id", "point"},
+		Filters: map[string]string{"status": "failed", "from": "2025-10-01 00:00:00"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(q.names(), ","), "user_id,point"; got != want {
+		t.Errorf("columns = %s, 期望 %s", got, want)
+	}
+	if want := " WHERE p.created >= {:f0} AND p.status = {:f1}"; q.where != want {
+		t.Errorf("where = %q, 期望 %q", q.where, want)
+	}
+	if q.params["f0"] != "2025-10-01 00:00:00.000Z" || q.params["f1"] != "failed" {
+		t.Errorf("params = %v", q.params)
+	}
+
+	// 未指定列时导出全部列
+	q, err = build(Request{Dataset: model.ExportDatasetVotes, Format: model.ExportFormatJsonl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.columns) != len(datasets[model.ExportDatasetVotes].columns) || q.where != "" {
+		t.Errorf("columns = %v, where = %q", q.names(), q.where)
+	}
+}
+
+func TestBuildSettlement(t *testing.T) {
+	// 默认导出最近一次结算，指定 run_no 时导出该次结算
+	q, err := build(Request{Dataset: model.ExportDatasetSettlement, Format: model.ExportFormatCsv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(q.where, "MAX(runNo)") {
+		t.Errorf("where = %q", q.where)
+	}
+
+	q, err = build(Request{Dataset: model.ExportDatasetSettlement, Format: model.ExportFormatCsv, Filters: map[string]string{"run_no": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(q.where, "MAX(runNo)") || q.params["f0"] != 2 {
+		t.Errorf("where = %q, params = %v", q.where, q.params)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		request Request
+		fields  []string
+	}{
+		{"数据集不存在", Request{Dataset: "users", Format: model.ExportFormatCsv}, []string{"dataset"}},
+		{"格式错误", Request{Dataset: model.ExportDatasetHistories, Format: "pdf"}, []string{"format"}},
+		{"未知的列", Request{Dataset: model.ExportDatasetHistories, Format: model.ExportFormatCsv, Columns: []string{"id", "password"}}, []string{"columns"}},
+		{"重复的列", Request{Dataset: model.ExportDatasetHistories, Format: model.ExportFormatCsv, Columns: []string{"id", "id"}}, []string{"columns"}},
+		{"过滤条件错误", Request{Dataset: model.ExportDatasetHistories, Format: model.ExportFormatCsv, Filters: map[string]string{
+			"prize_level": "一等奖",
+			"is_top":      "maybe",
+			"to":          "昨天",
+			"password":    "1",
+		}}, []string{"filters.prize_level", "filters.is_top", "filters.to", "filters.password"}},
+	}
+	for _, c := range cases {
+		_, err := build(c.request)
+		errs := validation.Errors{}
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: err = %v, 期望 validation.Errors", c.name, err)
+			continue
+		}
+		if len(errs) != len(c.fields) {
+			t.Errorf("%s: errs = %v", c.name, errs)
+		}
+		for _, field := range c.fields {
+			if errs[field] == nil {
+				t.Errorf("%s: 缺少 %s 的错误", c.name, field)
+			}
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		kind  kind
+		value sql.NullString
+		want  any
+	}{
+		{kindText, sql.NullString{String: "abc", Valid: true}, "abc"},
+		{kindText, sql.NullString{}, nil},
+		{kindInt, sql.NullString{String: "42", Valid: true}, 42},
+		{kindInt, sql.NullString{String: "3.0", Valid: true}, 3},
+		{kindFloat, sql.NullString{String: "12.5", Valid: true}, 12.5},
+		{kindBool, sql.NullString{String: "1", Valid: true}, true},
+		{kindBool, sql.NullString{String: "0", Valid: true}, false},
+		{kindJSON, sql.NullString{String: "[1,2]", Valid: true}, json.RawMessage("[1,2]")},
+		{kindJSON, sql.NullString{String: "[1,", Valid: true}, "[1,"},
+	}
+	for _, c := range cases {
+		if got := convert(c.kind, c.value); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("convert(%d, %q) = %#v, 期望 %#v", c.kind, c.value.String, got, c.want)
+		}
+	}
+}
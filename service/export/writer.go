@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bless-activity/model"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Writer 按格式逐行写入导出数据，创建时写入表头
+type Writer interface {
+	Write(values []any) error
+	Close() error
+}
+
+// NewWriter 创建对应格式的写入器
+func NewWriter(format model.ExportFormat, w io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case model.ExportFormatCsv:
+		return newCSVWriter(w, columns)
+	case model.ExportFormatXlsx:
+		return newXLSXWriter(w, columns)
+	case model.ExportFormatJsonl:
+		return &jsonlWriter{w: w, columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// ContentType 导出文件的 MIME 类型
+func ContentType(format model.ExportFormat) string {
+	switch format {
+	case model.ExportFormatCsv:
+		return "text/csv; charset=utf-8"
+	case model.ExportFormatXlsx:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/x-ndjson; charset=utf-8"
+	}
+}
+
+// FileName 导出文件名
+func FileName(dataset model.ExportDataset, format model.ExportFormat, suffix string) string {
+	return dataset.String() + "-" + suffix + "." + format.String()
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	// 写入 BOM，Excel 打开时才能正确识别中文
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	writer := &csvWriter{writer: csv.NewWriter(w), record: make([]string, len(columns))}
+	if err := writer.writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *csvWriter) Write(values []any) error {
+	for i, value := range values {
+		writer.record[i] = text(value)
+	}
+	return writer.writer.Write(writer.record)
+}
+
+func (writer *csvWriter) Close() error {
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+// jsonlWriter 每行一个 JSON 对象，字段按列的顺序输出，不转义 HTML 字符
+type jsonlWriter struct {
+	w       io.Writer
+	columns []string
+	buf     bytes.Buffer
+}
+
+func (writer *jsonlWriter) Write(values []any) error {
+	writer.buf.Reset()
+	encoder := json.NewEncoder(&writer.buf)
+	encoder.SetEscapeHTML(false)
+	writer.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			writer.buf.WriteByte(',')
+		}
+		// Encode 会在末尾追加换行，写入下一个值前去掉
+		if err := encoder.Encode(writer.columns[i]); err != nil {
+			return err
+		}
+		writer.buf.Truncate(writer.buf.Len() - 1)
+		writer.buf.WriteByte(':')
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		writer.buf.Truncate(writer.buf.Len() - 1)
+	}
+	writer.buf.WriteString("}\n")
+	_, err := writer.w.Write(writer.buf.Bytes())
+	return err
+}
+
+func (writer *jsonlWriter) Close() error {
+	return nil
+}
+
+// text 单元格的文本形式
+func text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
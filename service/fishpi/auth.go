@@ -0,0 +1,119 @@
+package fishpi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// ErrUnauthorized api key 无效或过期
+var ErrUnauthorized = errors.New("摸鱼派 api key 无效")
+
+// authorized 使用当前 api key 调用接口，鉴权失败时重新登录并重试一次
+func authorized[T any](service *Service, call func(apiKey string) (T, error)) (T, error) {
+	current := service.current.Load()
+	if current.apiKey == "" && current.config.canLogin() {
+		if _, err := service.renewApiKey(current); err != nil {
+			var zero T
+			return zero, err
+		}
+		current = service.current.Load()
+	}
+
+	result, err := call(current.apiKey)
+	if !errors.Is(err, ErrUnauthorized) || !current.config.canLogin() {
+		return result, err
+	}
+
+	service.logger.Warn("摸鱼派 api key 失效，重新登录", slog.Any("err", err))
+	apiKey, err := service.renewApiKey(current)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return call(apiKey)
+}
+
+// renewApiKey 登录获取新的 api key，stale 为调用失败时使用的快照，已被其他请求更新时直接使用新的 api key
+func (service *Service) renewApiKey(stale *snapshot) (string, error) {
+	service.loginMutex.Lock()
+	defer service.loginMutex.Unlock()
+
+	if current := service.current.Load(); current != stale {
+		if current.config == stale.config && current.apiKey != stale.apiKey {
+			return current.apiKey, nil
+		}
+		stale = current
+	}
+
+	apiKey, err := service.login(stale.client, stale.config)
+	if err != nil {
+		return "", err
+	}
+	// 登录期间配置被修改时不覆盖新的快照
+	service.current.CompareAndSwap(stale, &snapshot{config: stale.config, client: stale.client, apiKey: apiKey})
+	service.logger.Info("摸鱼派登录成功，api key 已更新", slog.String("username", stale.config.Username))
+	return apiKey, nil
+}
+
+// login 使用账号密码登录，配置了两步验证密钥时自动生成验证码
+func (service *Service) login(client *req.Client, config Config) (string, error) {
+	mfaCode := config.MfaCode
+	if config.Totp != "" {
+		code, err := GenerateTotp(config.Totp, time.Now())
+		if err != nil {
+			return "", err
+		}
+		mfaCode = code
+	}
+
+	password := md5.Sum([]byte(config.Password))
+	response := new(PostApiGetKeyResponse)
+	res, err := client.NewRequest().
+		SetBodyJsonMarshal(&PostApiGetKeyRequest{
+			NameOrEmail:  config.Username,
+			UserPassword: hex.EncodeToString(password[:]),
+			MfaCode:      mfaCode,
+		}).
+		SetSuccessResult(response).
+		Post("/api/getKey")
+	if err != nil {
+		return "", err
+	}
+	if res.IsErrorState() {
+		return "", fmt.Errorf("摸鱼派登录失败 status:%d", res.GetStatusCode())
+	}
+	if response.Code != 0 || response.Key == "" {
+		return "", fmt.Errorf("摸鱼派登录失败 code:%d,message:%s", response.Code, response.Msg)
+	}
+	return response.Key, nil
+}
+
+// responseError 接口返回的错误，鉴权失败时包装为 ErrUnauthorized
+func responseError(res *req.Response, code int, msg string) error {
+	if res.GetStatusCode() == http.StatusUnauthorized || res.GetStatusCode() == http.StatusForbidden ||
+		(code != 0 && isAuthMessage(msg)) {
+		return fmt.Errorf("%w: %s", ErrUnauthorized, msg)
+	}
+	if res.IsErrorState() {
+		return fmt.Errorf("status:%d", res.GetStatusCode())
+	}
+	if code != 0 {
+		return fmt.Errorf("code:%d,message:%s", code, msg)
+	}
+	return nil
+}
+
+// isAuthMessage 摸鱼派 api key 失效时 msg 为 401 或登录提示
+func isAuthMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return msg == "401" || strings.Contains(msg, "unauthorized") || strings.Contains(msg, "apikey") ||
+		strings.Contains(msg, "未登录") || strings.Contains(msg, "请先登录")
+}
@@ -0,0 +1,99 @@
+package fishpi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGenerateTotp(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		if code, err := GenerateTotp(secret, time.Unix(unix, 0)); err != nil || code != want {
+			t.Errorf("%d: %s %v, want %s", unix, code, err, want)
+		}
+	}
+	if code, err := GenerateTotp("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); err != nil || code != "287082" {
+		t.Errorf("应忽略大小写与空格 %s %v", code, err)
+	}
+	if _, err := GenerateTotp("not base32!", time.Now()); err == nil {
+		t.Error("无效密钥应返回错误")
+	}
+}
+
+func TestAuthorizedRenewsApiKey(t *testing.T) {
+	var logins, sends atomic.Int32
+	var accepted atomic.Value
+	accepted.Store("fresh-key")
+	password := md5.Sum([]byte("password"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/getKey", func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		body := PostApiGetKeyRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.NameOrEmail != "admin" || body.UserPassword != hex.EncodeToString(password[:]) || len(body.MfaCode) != 6 {
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": -1, "msg": "账号或密码错误"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "Key": "fresh-key"})
+	})
+	mux.HandleFunc("POST /chat-room/send", func(w http.ResponseWriter, r *http.Request) {
+		sends.Add(1)
+		body := PostChatroomSendRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.ApiKey != accepted.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": -1, "msg": "401"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := &Service{logger: slog.New(slog.DiscardHandler)}
+	service.reload(Config{BaseUrl: server.URL, ApiKey: "expired", Username: "admin", Password: "password", Totp: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+
+	// 失效后重新登录并重试一次
+	if _, err := service.PostChatroomSend(&PostChatroomSendRequest{Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if logins.Load() != 1 || sends.Load() != 2 {
+		t.Errorf("登录 %d 次，发送 %d 次", logins.Load(), sends.Load())
+	}
+
+	// 之后使用缓存的 api key
+	if _, err := service.PostChatroomSend(&PostChatroomSendRequest{Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if logins.Load() != 1 || sends.Load() != 3 {
+		t.Errorf("应使用缓存的 api key，登录 %d 次，发送 %d 次", logins.Load(), sends.Load())
+	}
+
+	// 重试后仍失败时不再重试
+	accepted.Store("revoked")
+	_, err := service.PostChatroomSend(&PostChatroomSendRequest{Content: "hi"})
+	if err == nil || logins.Load() != 2 || sends.Load() != 5 {
+		t.Errorf("只应重试一次 %v，登录 %d 次，发送 %d 次", err, logins.Load(), sends.Load())
+	}
+
+	// 未配置账号密码时直接返回鉴权错误
+	service.reload(Config{BaseUrl: server.URL, ApiKey: "expired"})
+	if _, err = service.PostChatroomSend(&PostChatroomSendRequest{Content: "hi"}); err == nil || logins.Load() != 2 {
+		t.Errorf("未配置账号时不应登录 %v", err)
+	}
+}
@@ -1,10 +1,15 @@
 package fishpi
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 )
 
+// ErrRejected 摸鱼派明确拒绝了积分变更，积分没有到账；其他错误（如超时、连接断开）无法确定是否已到账
+var ErrRejected = errors.New("摸鱼派拒绝")
+
 func (service *Service) GetInfo(openid string) (*UserInfo, error) {
 	logger := service.logger.With(
 		slog.String("service_action", "获取用户信息"),
@@ -12,7 +17,7 @@ func (service *Service) GetInfo(openid string) (*UserInfo, error) {
 	)
 
 	result := new(UserInfoResult)
-	resp, err := service.client.NewRequest().
+	resp, err := service.client().NewRequest().
 		SetSuccessResult(result).
 		SetQueryParam("userId", openid).
 		Get("/api/user/getInfoById")
@@ -38,9 +43,9 @@ func (service *Service) EditPoint(req *EditPointReq) (*EditPointReply, error) {
 	)
 
 	result := new(EditPointReply)
-	resp, err := service.client.NewRequest().
+	resp, err := service.client().NewRequest().
 		SetBodyJsonMarshal(map[string]any{
-			"goldFingerKey": service.config.GoldFingerKey,
+			"goldFingerKey": service.config().GoldFingerKey,
 			"userName":      req.UserName,
 			"point":         req.Point,
 			"memo":          req.Memo,
@@ -53,11 +58,15 @@ func (service *Service) EditPoint(req *EditPointReq) (*EditPointReply, error) {
 	}
 	if resp.IsErrorState() {
 		logger.Error("请求状态码异常", slog.String("resp", resp.String()))
+		// 4xx 说明请求没有被处理，5xx 时可能已经到账
+		if resp.GetStatusCode() < http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: status:%d", ErrRejected, resp.GetStatusCode())
+		}
 		return nil, fmt.Errorf("status:%d", resp.GetStatusCode())
 	}
 	if result.Code != 0 {
 		logger.Error("状态码异常", slog.String("resp", resp.String()))
-		return nil, fmt.Errorf("code:%d,message:%s", result.Code, result.Msg)
+		return nil, fmt.Errorf("%w: code:%d,message:%s", ErrRejected, result.Code, result.Msg)
 	}
 	return result, nil
 }
@@ -68,7 +77,7 @@ func (service *Service) GetUser(username string) (*GetUserReply, error) {
 		slog.String("username", username),
 	)
 	result := new(GetUserReply)
-	resp, err := service.client.NewRequest().
+	resp, err := service.client().NewRequest().
 		SetPathParam("username", username).
 		SetSuccessResult(result).
 		Get("/user/{username}")
@@ -84,7 +93,7 @@ func (service *Service) GetUser(username string) (*GetUserReply, error) {
 	return result, nil
 }
 
-// Distribute 发放积分的便捷方法
+// Distribute 发放积分的便捷方法，明确失败时返回 ErrRejected
 func (service *Service) Distribute(username string, point int, memo string) error {
 	req := &EditPointReq{
 		UserName: username,
@@ -94,3 +103,37 @@ func (service *Service) Distribute(username string, point int, memo string) erro
 	_, err := service.EditPoint(req)
 	return err
 }
+
+// GiveMetal 通过金手指给用户发放勋章
+func (service *Service) GiveMetal(req *GiveMetalReq) error {
+	logger := service.logger.With(
+		slog.String("service_action", "发放勋章"),
+		slog.Any("req", req),
+	)
+
+	result := new(EditPointReply)
+	resp, err := service.client().NewRequest().
+		SetBodyJsonMarshal(map[string]any{
+			"goldFingerKey": service.config().GoldFingerKey,
+			"userName":      req.UserName,
+			"name":          req.Name,
+			"description":   req.Description,
+			"attr":          req.Attr,
+			"data":          req.Data,
+		}).
+		SetSuccessResult(result).
+		Post(`/user/edit/give-metal`)
+	if err != nil {
+		logger.Error("请求失败", slog.Any("err", err))
+		return err
+	}
+	if resp.IsErrorState() {
+		logger.Error("请求状态码异常", slog.String("resp", resp.String()))
+		return fmt.Errorf("status:%d", resp.GetStatusCode())
+	}
+	if result.Code != 0 {
+		logger.Error("状态码异常", slog.String("resp", resp.String()))
+		return fmt.Errorf("code:%d,message:%s", result.Code, result.Msg)
+	}
+	return nil
+}
@@ -1,16 +1,65 @@
 package fishpi
 
-import "fmt"
+import (
+	"fmt"
+	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Config 摸鱼派配置，带 secret 标签的字段读取时会被掩码
+//
+// 填写账号密码后 api key 失效时自动登录获取，Totp 为两步验证密钥，MfaCode 为未配置 Totp 时使用的固定验证码
 type Config struct {
 	BaseUrl        string `json:"base_url"`
-	ApiKey         string `json:"api_key"`
+	ApiKey         string `json:"api_key" secret:"true"`
 	Username       string `json:"username"`
-	Password       string `json:"password"`
-	MfaCode        string `json:"mfa_code"`
-	Totp           string `json:"totp"`
-	GoldFingerKey  string `json:"gold_finger_key"`
-	MetalFingerKey string `json:"metal_finger_key"`
+	Password       string `json:"password" secret:"true"`
+	MfaCode        string `json:"mfa_code" secret:"true"`
+	Totp           string `json:"totp" secret:"true"`
+	GoldFingerKey  string `json:"gold_finger_key" secret:"true"`
+	MetalFingerKey string `json:"metal_finger_key" secret:"true"`
+}
+
+// DefaultConfig 默认配置，密钥需要在后台填写
+func DefaultConfig() *Config {
+	return &Config{
+		BaseUrl: "https://fishpi.cn",
+	}
+}
+
+// canLogin 是否配置了自动登录所需的账号密码
+func (config Config) canLogin() bool {
+	return config.Username != "" && config.Password != ""
+}
+
+func (config *Config) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.BaseUrl, validation.Required.Error("不能为空"), is.URL.Error("应为有效的链接")),
+		validation.Field(&config.Password, validation.When(config.Username != "", validation.Required.Error("填写账号后不能为空"))),
+		validation.Field(&config.Totp, validation.By(func(any) error {
+			if config.Totp == "" {
+				return nil
+			}
+			if _, err := GenerateTotp(config.Totp, time.Now()); err != nil {
+				return validation.NewError("validation_totp", "应为 base32 编码的两步验证密钥")
+			}
+			return nil
+		})),
+	)
+}
+
+type PostApiGetKeyRequest struct {
+	NameOrEmail  string `json:"nameOrEmail"`
+	UserPassword string `json:"userPassword"` // 密码的 md5
+	MfaCode      string `json:"mfaCode"`
+}
+
+type PostApiGetKeyResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Key  string `json:"Key"`
 }
 
 type UserInfoResult struct {
@@ -35,6 +84,14 @@ type EditPointReq struct {
 	Memo     string // 请求来源 游戏《我要当学霸》 原因 (8888)开摆购买道具 交易内容 学时x1
 }
 
+type GiveMetalReq struct {
+	UserName    string // 用户名
+	Name        string // 勋章名称
+	Description string // 勋章描述
+	Attr        string // 勋章样式，如 url=...&backcolor=...&fontcolor=...
+	Data        string // 勋章附加数据
+}
+
 type EditPointReply struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
@@ -92,6 +149,49 @@ type PostChatroomSendResponse struct {
 	Msg  string `json:"msg"`
 }
 
+// PostArticleRequest 发布或更新帖子，ArticleTags 为逗号分隔的标签
+type PostArticleRequest struct {
+	ApiKey            string `json:"apiKey"`
+	ArticleTitle      string `json:"articleTitle"`
+	ArticleContent    string `json:"articleContent"` // Markdown
+	ArticleTags       string `json:"articleTags"`
+	Commentable       bool   `json:"commentable"`
+	NotifyFollowers   bool   `json:"notifyFollowers"`
+	ArticleType       int    `json:"articleType"` // 0 普通帖子
+	ArticleShowInList int    `json:"articleShowInList"`
+}
+
+type PostArticleResponse struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	ArticleId string `json:"articleId"`
+}
+
+type GetApiArticleThanksResponse struct {
+	Msg  string                          `json:"msg"`
+	Code int                             `json:"code"`
+	Data GetApiArticleThanksResponseData `json:"data"`
+}
+
+type GetApiArticleThanksResponseData struct {
+	Thanks     []*GetApiArticleThanksResponseThank `json:"thanks"`
+	Pagination struct {
+		PaginationPageCount int `json:"paginationPageCount"`
+	} `json:"pagination"`
+}
+
+// GetApiArticleThanksResponseThank 一次感谢或打赏
+type GetApiArticleThanksResponseThank struct {
+	OId           string `json:"oId"`
+	Type          string `json:"type"` // thank 感谢，reward 打赏
+	Point         int    `json:"point"`
+	Time          int64  `json:"time"` // 毫秒时间戳
+	UserOId       string `json:"userOId"`
+	UserName      string `json:"userName"`
+	UserNickname  string `json:"userNickname"`
+	UserAvatarURL string `json:"userAvatarURL"`
+}
+
 type GetApiArticlesTagResponse struct {
 	Msg  string                        `json:"msg"`
 	Code int                           `json:"code"`
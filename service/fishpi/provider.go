@@ -0,0 +1,34 @@
+package fishpi
+
+import (
+	"errors"
+
+	"github.com/pocketbase/pocketbase/tools/auth"
+	"golang.org/x/oauth2"
+)
+
+// AuthProviderName 摸鱼派账号在 _externalAuths 中的 provider 名称
+const AuthProviderName = "fishpi"
+
+func init() {
+	auth.Providers[AuthProviderName] = func() auth.Provider {
+		return NewAuthProvider()
+	}
+}
+
+var _ auth.Provider = (*AuthProvider)(nil)
+
+// AuthProvider 仅用于在 _externalAuths 中登记摸鱼派账号，实际登录走 OpenID 回调
+type AuthProvider struct {
+	auth.BaseProvider
+}
+
+func NewAuthProvider() *AuthProvider {
+	provider := &AuthProvider{}
+	provider.SetDisplayName("摸鱼派")
+	return provider
+}
+
+func (provider *AuthProvider) FetchAuthUser(*oauth2.Token) (*auth.AuthUser, error) {
+	return nil, errors.New("摸鱼派账号请通过 /fishpi/login 登录")
+}
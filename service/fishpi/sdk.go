@@ -2,76 +2,144 @@ package fishpi
 
 import (
 	"fmt"
-
-	"github.com/duke-git/lancet/v2/xerror"
 )
 
 // GetChatroomNodeGet 获取节点列表
 func (service *Service) GetChatroomNodeGet() (*GetChatroomNodeGetResponse, error) {
-	res, err := service.client.NewRequest().
-		SetQueryParam("apiKey", service.config.ApiKey).
-		Get("/chat-room/node/get")
-	if err != nil {
-		return nil, err
-	}
-	response := new(GetChatroomNodeGetResponse)
-	if err = res.Unmarshal(&response); err != nil {
-		return nil, err
-	}
-	if response.Code != 0 {
-		return nil, xerror.New("%s", response.Msg)
-	}
-	for _, node := range response.Avaliable {
-		node.Node += fmt.Sprintf("?apiKey=%s", service.config.ApiKey)
-	}
-	return response, nil
+	return authorized(service, func(apiKey string) (*GetChatroomNodeGetResponse, error) {
+		response := new(GetChatroomNodeGetResponse)
+		res, err := service.client().NewRequest().
+			SetQueryParam("apiKey", apiKey).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Get("/chat-room/node/get")
+		if err != nil {
+			return nil, err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return nil, err
+		}
+		for _, node := range response.Avaliable {
+			node.Node += fmt.Sprintf("?apiKey=%s", apiKey)
+		}
+		return response, nil
+	})
 }
 
 // PostChatroomSend 发送聊天室消息
 func (service *Service) PostChatroomSend(req *PostChatroomSendRequest) (*PostChatroomSendResponse, error) {
-	req.ApiKey = service.config.ApiKey
-	req.Client = "Golang/v0.0.3"
+	return authorized(service, func(apiKey string) (*PostChatroomSendResponse, error) {
+		req.ApiKey = apiKey
+		req.Client = "Golang/v0.0.3"
 
-	res, err := service.client.NewRequest().
-		SetBodyJsonMarshal(req).
-		Post("/chat-room/send")
-	if err != nil {
-		return nil, err
-	}
-	response := new(PostChatroomSendResponse)
-	if err = res.Unmarshal(&response); err != nil {
-		return nil, err
-	}
-	if response.Code != 0 {
-		return nil, xerror.New("%s", response.Msg)
-	}
-	return response, nil
+		response := new(PostChatroomSendResponse)
+		res, err := service.client().NewRequest().
+			SetBodyJsonMarshal(req).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Post("/chat-room/send")
+		if err != nil {
+			return nil, err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
 }
 
 // GetApiArticlesTag 获取帖子列表根据标签
 func (service *Service) GetApiArticlesTag(tagName string, page int, size int) (*GetApiArticlesTagResponse, error) {
 	page = max(page, 1)
 	size = max(size, 1)
-	res, err := service.client.NewRequest().
-		SetQueryParam("apiKey", service.config.ApiKey).
-		SetQueryParamsAnyType(map[string]any{
-			"p":    page,
-			"size": size,
-		}).
-		SetPathParam("tag", tagName).
-		Get("/api/articles/tag/{tag}")
-	if err != nil {
-		return nil, err
-	}
+	return authorized(service, func(apiKey string) (*GetApiArticlesTagResponse, error) {
+		response := new(GetApiArticlesTagResponse)
+		res, err := service.client().NewRequest().
+			SetQueryParam("apiKey", apiKey).
+			SetQueryParamsAnyType(map[string]any{
+				"p":    page,
+				"size": size,
+			}).
+			SetPathParam("tag", tagName).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Get("/api/articles/tag/{tag}")
+		if err != nil {
+			return nil, err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
+}
+
+// PostArticle 发布帖子，返回帖子 id
+func (service *Service) PostArticle(req *PostArticleRequest) (string, error) {
+	return authorized(service, func(apiKey string) (string, error) {
+		req.ApiKey = apiKey
+
+		response := new(PostArticleResponse)
+		res, err := service.client().NewRequest().
+			SetBodyJsonMarshal(req).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Post("/article")
+		if err != nil {
+			return "", err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return "", err
+		}
+		return response.ArticleId, nil
+	})
+}
 
-	response := new(GetApiArticlesTagResponse)
-	if err = res.Unmarshal(&response); err != nil {
-		return nil, err
-	}
+// PutArticle 更新帖子
+func (service *Service) PutArticle(articleId string, req *PostArticleRequest) error {
+	_, err := authorized(service, func(apiKey string) (string, error) {
+		req.ApiKey = apiKey
 
-	if response.Code != 0 {
-		return nil, xerror.New("%s", response.Msg)
-	}
+		response := new(PostArticleResponse)
+		res, err := service.client().NewRequest().
+			SetPathParam("id", articleId).
+			SetBodyJsonMarshal(req).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Put("/article/{id}")
+		if err != nil {
+			return "", err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return "", err
+		}
+		return articleId, nil
+	})
+	return err
+}
 
-	return response, nil
+// GetApiArticleThanks 分页获取帖子的感谢与打赏明细，按时间先后排列
+func (service *Service) GetApiArticleThanks(articleId string, page int, size int) (*GetApiArticleThanksResponse, error) {
+	page = max(page, 1)
+	size = max(size, 1)
+	return authorized(service, func(apiKey string) (*GetApiArticleThanksResponse, error) {
+		response := new(GetApiArticleThanksResponse)
+		res, err := service.client().NewRequest().
+			SetQueryParam("apiKey", apiKey).
+			SetQueryParamsAnyType(map[string]any{
+				"p":    page,
+				"size": size,
+			}).
+			SetPathParam("id", articleId).
+			SetSuccessResult(response).
+			SetErrorResult(response).
+			Get("/api/article/{id}/thanks")
+		if err != nil {
+			return nil, err
+		}
+		if err = responseError(res, response.Code, response.Msg); err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
 }
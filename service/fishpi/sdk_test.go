@@ -0,0 +1,93 @@
+package fishpi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostAndPutArticle(t *testing.T) {
+	var posted, put PostArticleRequest
+	var putId string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /article", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&posted)
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "articleId": "1700000000000"})
+	})
+	mux.HandleFunc("PUT /article/{id}", func(w http.ResponseWriter, r *http.Request) {
+		putId = r.PathValue("id")
+		_ = json.NewDecoder(r.Body).Decode(&put)
+		if putId == "missing" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": -1, "msg": "帖子不存在"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "articleId": putId})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := &Service{logger: slog.New(slog.DiscardHandler)}
+	service.reload(Config{BaseUrl: server.URL, ApiKey: "key"})
+
+	articleId, err := service.PostArticle(&PostArticleRequest{ArticleTitle: "总结", ArticleContent: "## 内容", ArticleTags: "活动总结"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if articleId != "1700000000000" || posted.ApiKey != "key" || posted.ArticleTitle != "总结" || posted.ArticleContent != "## 内容" {
+		t.Errorf("articleId = %s, 请求 %+v", articleId, posted)
+	}
+
+	if err = service.PutArticle(articleId, &PostArticleRequest{ArticleTitle: "总结v2"}); err != nil {
+		t.Fatal(err)
+	}
+	if putId != articleId || put.ApiKey != "key" || put.ArticleTitle != "总结v2" {
+		t.Errorf("更新 %s, 请求 %+v", putId, put)
+	}
+
+	if err = service.PutArticle("missing", &PostArticleRequest{}); err == nil {
+		t.Error("帖子不存在时应返回错误")
+	}
+}
+
+func TestGetApiArticleThanks(t *testing.T) {
+	var query string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/article/{id}/thanks", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "1700000000000" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": -1, "msg": "帖子不存在"})
+			return
+		}
+		query = r.URL.RawQuery
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "data": map[string]any{
+			"thanks": []map[string]any{
+				{"oId": "1", "type": "thank", "time": 1759300000000, "userOId": "u1", "userName": "alice"},
+				{"oId": "2", "type": "reward", "point": 32, "time": 1759300001000, "userOId": "u2", "userName": "bob"},
+			},
+			"pagination": map[string]any{"paginationPageCount": 1},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := &Service{logger: slog.New(slog.DiscardHandler)}
+	service.reload(Config{BaseUrl: server.URL, ApiKey: "key"})
+
+	response, err := service.GetApiArticleThanks("1700000000000", 0, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "apiKey=key&p=1&size=50" {
+		t.Errorf("query = %s", query)
+	}
+	thanks := response.Data.Thanks
+	if len(thanks) != 2 || thanks[0].UserOId != "u1" || thanks[1].Type != "reward" || thanks[1].Point != 32 || thanks[1].Time != 1759300001000 {
+		t.Errorf("thanks = %+v", thanks)
+	}
+
+	if _, err = service.GetApiArticleThanks("missing", 1, 50); err == nil {
+		t.Error("帖子不存在时应返回错误")
+	}
+}
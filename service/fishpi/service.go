@@ -2,52 +2,69 @@ package fishpi
 
 import (
 	"bless-activity/model"
-	"encoding/json"
+	"bless-activity/service/config"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 
 	"github.com/imroc/req/v3"
 	"github.com/lxzan/gws"
-	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
+// snapshot 当前配置、对应的 HTTP 客户端与 api key，配置变更或重新登录时整体替换
+type snapshot struct {
+	config Config
+	client *req.Client
+	apiKey string
+}
+
 type Service struct {
-	config *Config
+	current    atomic.Pointer[snapshot]
+	loginMutex sync.Mutex
 
 	conn *gws.Conn
 
 	app    core.App
-	client *req.Client
 	logger *slog.Logger
 }
 
-func NewService(app core.App) (*Service, error) {
+func NewService(app core.App, registry *config.Registry) (*Service, error) {
 	s := &Service{
 		app:    app,
 		logger: app.Logger().WithGroup("fishpi"),
 	}
 
-	if err := s.init(); err != nil {
+	value, err := config.Get[Config](registry, model.ConfigKeyFishpi)
+	if err != nil {
 		return nil, err
 	}
+	s.reload(value)
+
+	// 修改地址或密钥后重建客户端，无需重启
+	config.Subscribe(registry, model.ConfigKeyFishpi, func(value Config) {
+		s.reload(value)
+		s.logger.Info("摸鱼派配置已更新", slog.String("base_url", value.BaseUrl))
+	})
 
 	return s, nil
 }
 
-func (service *Service) init() error {
-	config := new(model.Config)
-	if err := service.app.RecordQuery(model.DbNameConfigs).Where(dbx.HashExp{model.ConfigsFieldKey: model.ConfigKeyFishpi}).One(config); err != nil {
-		return err
-	}
-	if err := json.Unmarshal([]byte(config.Value()), &service.config); err != nil {
-		return err
-	}
-
-	service.client = req.NewClient().
-		SetBaseURL(service.config.BaseUrl).
+func (service *Service) reload(value Config) {
+	client := req.NewClient().
+		SetBaseURL(value.BaseUrl).
 		SetUserAgent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko)")
 
+	// 配置了账号密码时，api key 为空或失效后自动登录获取
+	service.current.Store(&snapshot{config: value, client: client, apiKey: value.ApiKey})
+
 	//go service.start()
+}
+
+func (service *Service) client() *req.Client {
+	return service.current.Load().client
+}
 
-	return nil
+func (service *Service) config() Config {
+	return service.current.Load().config
 }
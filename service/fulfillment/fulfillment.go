@@ -0,0 +1,433 @@
+package fulfillment
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/fishpi"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var (
+	ErrInvalidTransition = errors.New("当前状态不能执行该操作")
+	ErrCodeExhausted     = errors.New("兑换码已发完，请联系运营")
+	ErrMedalFailed       = errors.New("发放勋章失败，请稍后重试")
+)
+
+// transitions 各类奖品的兑奖流程：
+// 兑换码领取时从码池分配后直接送达；实物领取时填写收货地址，发货前可修改，运营发货后由用户或运营确认送达；
+// 勋章领取时通过摸鱼派金手指发放，成功即送达。未送达的记录可以标记失败，失败后可重新发放
+var transitions = map[model.RewardKind]map[model.FulfillmentStatus][]model.FulfillmentStatus{
+	model.RewardKindCode: {
+		model.FulfillmentStatusAllocated: {model.FulfillmentStatusDelivered, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusFailed:    {model.FulfillmentStatusAllocated},
+	},
+	model.RewardKindPhysical: {
+		model.FulfillmentStatusAllocated: {model.FulfillmentStatusClaimed, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusClaimed:   {model.FulfillmentStatusClaimed, model.FulfillmentStatusShipped, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusShipped:   {model.FulfillmentStatusDelivered, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusFailed:    {model.FulfillmentStatusAllocated},
+	},
+	model.RewardKindMedal: {
+		model.FulfillmentStatusAllocated: {model.FulfillmentStatusClaimed, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusClaimed:   {model.FulfillmentStatusDelivered, model.FulfillmentStatusFailed},
+		model.FulfillmentStatusFailed:    {model.FulfillmentStatusAllocated},
+	},
+}
+
+// CanTransition 该类奖品的兑奖记录能否从 from 变为 to
+func CanTransition(kind model.RewardKind, from model.FulfillmentStatus, to model.FulfillmentStatus) bool {
+	return slices.Contains(transitions[kind][from], to)
+}
+
+// ValidateAddress 校验收货信息
+func ValidateAddress(address *model.ShippingAddress) error {
+	return validation.ValidateStruct(address,
+		validation.Field(&address.Name, validation.Required.Error("请填写收货人"), validation.RuneLength(1, 32).Error("收货人过长")),
+		validation.Field(&address.Phone, validation.Required.Error("请填写联系电话"), validation.RuneLength(5, 20).Error("联系电话格式错误")),
+		validation.Field(&address.Address, validation.Required.Error("请填写收货地址"), validation.RuneLength(5, 200).Error("收货地址长度应为5-200个字符")),
+	)
+}
+
+// Service 兑奖：积分之外的奖品在获奖时创建待领取的兑奖记录，用户领取后按奖品类型的流程发放
+// 积分奖品由积分账本发放，不创建兑奖记录
+type Service struct {
+	app           core.App
+	fishpiService *fishpi.Service
+	logger        *slog.Logger
+}
+
+func NewService(app core.App, fishpiService *fishpi.Service) *Service {
+	return &Service{
+		app:           app,
+		fishpiService: fishpiService,
+		logger:        app.Logger().WithGroup("fulfillment"),
+	}
+}
+
+// Allocate 在预留奖品的事务中为获奖记录创建待领取的兑奖记录，已有记录时重新变为待领取
+func (service *Service) Allocate(txApp core.App, reward *model.Reward, historyId string) error {
+	if reward.Kind() == model.RewardKindPoints {
+		return nil
+	}
+
+	history := new(model.Histories)
+	if err := txApp.RecordQuery(model.DbNameHistories).Where(dbx.HashExp{model.CommonFieldId: historyId}).One(history); err != nil {
+		return err
+	}
+
+	fulfillment, err := find(txApp, dbx.HashExp{model.FulfillmentsFieldHistoryId: historyId})
+	if errors.Is(err, sql.ErrNoRows) {
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNameFulfillments)
+		if err != nil {
+			return err
+		}
+		fulfillment = model.NewFulfillmentFromCollection(collection)
+		fulfillment.SetHistoryId(historyId)
+	} else if err != nil {
+		return err
+	} else if fulfillment.Status() != model.FulfillmentStatusFailed {
+		return nil
+	}
+	fulfillment.SetUserId(history.UserId())
+	fulfillment.SetRewardId(reward.Id)
+	fulfillment.SetKind(reward.Kind())
+	fulfillment.SetStatus(model.FulfillmentStatusAllocated)
+	fulfillment.SetMessage("")
+	return txApp.Save(fulfillment)
+}
+
+// Revoke 获奖被撤销时将未送达的兑奖记录标记为失败，已送达的只记录日志，需人工处理
+func (service *Service) Revoke(txApp core.App, historyId string, reason string) error {
+	fulfillment, err := find(txApp, dbx.HashExp{model.FulfillmentsFieldHistoryId: historyId})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fulfillment.Status() == model.FulfillmentStatusFailed {
+		return nil
+	}
+	if !CanTransition(fulfillment.Kind(), fulfillment.Status(), model.FulfillmentStatusFailed) {
+		service.logger.Warn("获奖已撤销，兑奖记录无法撤回，需人工处理",
+			slog.String("fulfillment_id", fulfillment.Id),
+			slog.String("status", fulfillment.Status().String()))
+		return nil
+	}
+	fulfillment.SetStatus(model.FulfillmentStatusFailed)
+	fulfillment.SetMessage("获奖已撤销：" + reason)
+	return txApp.Save(fulfillment)
+}
+
+// Claim 用户领取奖品：兑换码从码池分配，实物保存收货信息，勋章通过摸鱼派发放
+func (service *Service) Claim(user *model.User, id string, address *model.ShippingAddress) (*model.Fulfillment, error) {
+	var fulfillment *model.Fulfillment
+	var reward *model.Reward
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if fulfillment, err = find(txApp, dbx.HashExp{
+			model.CommonFieldId:           id,
+			model.FulfillmentsFieldUserId: user.Id,
+		}); err != nil {
+			return err
+		}
+		if reward, err = findReward(txApp, fulfillment.RewardId()); err != nil {
+			return err
+		}
+
+		switch fulfillment.Kind() {
+		case model.RewardKindCode:
+			if fulfillment.Status() != model.FulfillmentStatusAllocated {
+				return ErrInvalidTransition
+			}
+			return service.assignCode(txApp, fulfillment)
+		case model.RewardKindPhysical:
+			if address == nil {
+				return validation.Errors{"address": validation.NewError("address_required", "请填写收货信息")}
+			}
+			if err = ValidateAddress(address); err != nil {
+				return err
+			}
+			if err = transit(fulfillment, model.FulfillmentStatusClaimed); err != nil {
+				return err
+			}
+			fulfillment.SetAddress(*address)
+			return txApp.Save(fulfillment)
+		case model.RewardKindMedal:
+			if err = transit(fulfillment, model.FulfillmentStatusClaimed); err != nil {
+				return err
+			}
+			return txApp.Save(fulfillment)
+		default:
+			return ErrInvalidTransition
+		}
+	})
+	if err != nil || fulfillment.Kind() != model.RewardKindMedal {
+		return fulfillment, err
+	}
+
+	// 勋章在事务外调用摸鱼派，失败时标记为失败，由运营重新发放
+	medal := reward.Medal()
+	to, message := model.FulfillmentStatusDelivered, ""
+	if err = service.fishpiService.GiveMetal(&fishpi.GiveMetalReq{
+		UserName:    user.Name(),
+		Name:        medal.Name,
+		Description: medal.Description,
+		Attr:        medal.Attr,
+		Data:        medal.Data,
+	}); err != nil {
+		service.logger.Error("发放勋章失败", slog.String("fulfillment_id", fulfillment.Id), slog.Any("err", err))
+		to, message = model.FulfillmentStatusFailed, err.Error()
+	}
+	if err := transit(fulfillment, to); err != nil {
+		return fulfillment, err
+	}
+	fulfillment.SetMessage(message)
+	if err := service.app.Save(fulfillment); err != nil {
+		return fulfillment, err
+	}
+	if to == model.FulfillmentStatusFailed {
+		return fulfillment, ErrMedalFailed
+	}
+	return fulfillment, nil
+}
+
+// Confirm 用户确认实物已收货
+func (service *Service) Confirm(user *model.User, id string) (*model.Fulfillment, error) {
+	fulfillment, err := find(service.app, dbx.HashExp{
+		model.CommonFieldId:           id,
+		model.FulfillmentsFieldUserId: user.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fulfillment.Status() != model.FulfillmentStatusShipped {
+		return fulfillment, ErrInvalidTransition
+	}
+	if err = transit(fulfillment, model.FulfillmentStatusDelivered); err != nil {
+		return fulfillment, err
+	}
+	return fulfillment, service.app.Save(fulfillment)
+}
+
+// Transition 运营推进兑奖记录：发货（填写运单号）、确认送达、标记失败或重新发放，返回修改前后的记录
+func (service *Service) Transition(txApp core.App, id string, to model.FulfillmentStatus, trackingNo string, message string) (before map[string]any, after *model.Fulfillment, err error) {
+	fulfillment, err := find(txApp, dbx.HashExp{model.CommonFieldId: id})
+	if err != nil {
+		return nil, nil, err
+	}
+	before = fulfillment.FieldsData()
+
+	// 运营不代替用户领取，领取需要用户操作
+	if to == model.FulfillmentStatusClaimed {
+		return before, nil, ErrInvalidTransition
+	}
+	if to == model.FulfillmentStatusShipped && strings.TrimSpace(trackingNo) == "" {
+		return before, nil, validation.Errors{"tracking_no": validation.NewError("tracking_no_required", "请填写运单号")}
+	}
+	if err = transit(fulfillment, to); err != nil {
+		return before, nil, err
+	}
+	if trackingNo != "" {
+		fulfillment.SetTrackingNo(strings.TrimSpace(trackingNo))
+	}
+	fulfillment.SetMessage(message)
+
+	// 重新发放的兑换码释放后重新分配
+	if to == model.FulfillmentStatusAllocated && fulfillment.CodeId() != "" {
+		if _, err = txApp.DB().Update(model.DbNameRewardCodes, dbx.Params{model.RewardCodesFieldFulfillmentId: ""},
+			dbx.HashExp{model.CommonFieldId: fulfillment.CodeId()}).Execute(); err != nil {
+			return before, nil, err
+		}
+		fulfillment.SetCodeId("")
+	}
+	if err = txApp.Save(fulfillment); err != nil {
+		return before, nil, err
+	}
+	return before, fulfillment, nil
+}
+
+// ImportCodes 向奖品的码池导入兑换码，空白与重复的兑换码跳过
+func (service *Service) ImportCodes(txApp core.App, rewardId string, codes []string) (imported int, skipped int, err error) {
+	reward, err := findReward(txApp, rewardId)
+	if err != nil {
+		return 0, 0, err
+	}
+	if reward.Kind() != model.RewardKindCode {
+		return 0, 0, fmt.Errorf("%w: 奖品「%s」不是兑换码类型", ErrInvalidTransition, reward.Name())
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameRewardCodes)
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" || seen[code] {
+			skipped++
+			continue
+		}
+		seen[code] = true
+
+		exists, err := txApp.CountRecords(model.DbNameRewardCodes, dbx.HashExp{
+			model.RewardCodesFieldRewardId: rewardId,
+			model.RewardCodesFieldCode:     code,
+		})
+		if err != nil {
+			return imported, skipped, err
+		}
+		if exists > 0 {
+			skipped++
+			continue
+		}
+
+		rewardCode := model.NewRewardCodeFromCollection(collection)
+		rewardCode.SetRewardId(rewardId)
+		rewardCode.SetCode(code)
+		if err = txApp.Save(rewardCode); err != nil {
+			return imported, skipped, err
+		}
+		imported++
+	}
+	return imported, skipped, nil
+}
+
+// CodeStats 奖品码池的兑换码总数与已分配数量
+func (service *Service) CodeStats(rewardId string) (total int, used int, err error) {
+	count, err := service.app.CountRecords(model.DbNameRewardCodes, dbx.HashExp{model.RewardCodesFieldRewardId: rewardId})
+	if err != nil {
+		return 0, 0, err
+	}
+	unused, err := service.app.CountRecords(model.DbNameRewardCodes, dbx.HashExp{
+		model.RewardCodesFieldRewardId:      rewardId,
+		model.RewardCodesFieldFulfillmentId: "",
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(count), int(count - unused), nil
+}
+
+// Code 兑奖记录分配到的兑换码
+func (service *Service) Code(fulfillment *model.Fulfillment) (string, error) {
+	if fulfillment.CodeId() == "" {
+		return "", nil
+	}
+	rewardCode := new(model.RewardCode)
+	if err := service.app.RecordQuery(model.DbNameRewardCodes).
+		Where(dbx.HashExp{model.CommonFieldId: fulfillment.CodeId()}).
+		One(rewardCode); err != nil {
+		return "", err
+	}
+	return rewardCode.Code(), nil
+}
+
+// List 用户的兑奖记录，按创建时间倒序
+func (service *Service) List(userId string) ([]*model.Fulfillment, error) {
+	fulfillments := []*model.Fulfillment{}
+	err := service.app.RecordQuery(model.DbNameFulfillments).
+		Where(dbx.HashExp{model.FulfillmentsFieldUserId: userId}).
+		OrderBy(model.FulfillmentsFieldCreated + " desc").
+		All(&fulfillments)
+	return fulfillments, err
+}
+
+// Search 分页查询兑奖记录，status、kind 为空时不过滤
+func (service *Service) Search(status string, kind string, page int, perPage int) ([]*model.Fulfillment, int, error) {
+	// 空的 HashExp 在 CountRecords 中会生成 WHERE ()，没有过滤条件时不传
+	filters := []dbx.Expression{}
+	if status != "" {
+		filters = append(filters, dbx.HashExp{model.FulfillmentsFieldStatus: status})
+	}
+	if kind != "" {
+		filters = append(filters, dbx.HashExp{model.FulfillmentsFieldKind: kind})
+	}
+
+	total, err := service.app.CountRecords(model.DbNameFulfillments, filters...)
+	if err != nil {
+		return nil, 0, err
+	}
+	fulfillments := []*model.Fulfillment{}
+	if err = service.app.RecordQuery(model.DbNameFulfillments).
+		Where(dbx.And(filters...)).
+		OrderBy(model.FulfillmentsFieldCreated + " desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&fulfillments); err != nil {
+		return nil, 0, err
+	}
+	return fulfillments, int(total), nil
+}
+
+// assignCode 从码池中取一个未分配的兑换码，码池为空时返回 ErrCodeExhausted
+func (service *Service) assignCode(txApp core.App, fulfillment *model.Fulfillment) error {
+	rewardCode := new(model.RewardCode)
+	if err := txApp.RecordQuery(model.DbNameRewardCodes).
+		Where(dbx.HashExp{
+			model.RewardCodesFieldRewardId:      fulfillment.RewardId(),
+			model.RewardCodesFieldFulfillmentId: "",
+		}).
+		OrderBy(model.RewardCodesFieldCreated+" asc", model.CommonFieldId+" asc").
+		Limit(1).
+		One(rewardCode); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCodeExhausted
+		}
+		return err
+	}
+
+	rewardCode.SetFulfillmentId(fulfillment.Id)
+	if err := txApp.Save(rewardCode); err != nil {
+		return err
+	}
+	fulfillment.SetCodeId(rewardCode.Id)
+	if err := transit(fulfillment, model.FulfillmentStatusDelivered); err != nil {
+		return err
+	}
+	fulfillment.SetClaimedAt(fulfillment.DeliveredAt())
+	return txApp.Save(fulfillment)
+}
+
+// transit 按兑奖流程修改状态并记录时间
+func transit(fulfillment *model.Fulfillment, to model.FulfillmentStatus) error {
+	if !CanTransition(fulfillment.Kind(), fulfillment.Status(), to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, fulfillment.Status(), to)
+	}
+	now := types.NowDateTime()
+	switch to {
+	case model.FulfillmentStatusClaimed:
+		fulfillment.SetClaimedAt(now)
+	case model.FulfillmentStatusShipped:
+		fulfillment.SetShippedAt(now)
+	case model.FulfillmentStatusDelivered:
+		fulfillment.SetDeliveredAt(now)
+	}
+	fulfillment.SetStatus(to)
+	return nil
+}
+
+func find(txApp core.App, where dbx.Expression) (*model.Fulfillment, error) {
+	fulfillment := new(model.Fulfillment)
+	if err := txApp.RecordQuery(model.DbNameFulfillments).Where(where).One(fulfillment); err != nil {
+		return nil, err
+	}
+	return fulfillment, nil
+}
+
+func findReward(txApp core.App, rewardId string) (*model.Reward, error) {
+	reward := new(model.Reward)
+	if err := txApp.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: rewardId}).One(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
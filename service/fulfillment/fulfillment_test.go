@@ -0,0 +1,277 @@
+package fulfillment
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/inventory"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		name string
+		kind model.RewardKind
+		from model.FulfillmentStatus
+		to   model.FulfillmentStatus
+		want bool
+	}{
+		{"兑换码领取即送达", model.RewardKindCode, model.FulfillmentStatusAllocated, model.FulfillmentStatusDelivered, true},
+		{"兑换码不需要发货", model.RewardKindCode, model.FulfillmentStatusAllocated, model.FulfillmentStatusShipped, false},
+		{"兑换码送达后不能撤回", model.RewardKindCode, model.FulfillmentStatusDelivered, model.FulfillmentStatusFailed, false},
+		{"实物填写地址", model.RewardKindPhysical, model.FulfillmentStatusAllocated, model.FulfillmentStatusClaimed, true},
+		{"实物发货前修改地址", model.RewardKindPhysical, model.FulfillmentStatusClaimed, model.FulfillmentStatusClaimed, true},
+		{"实物未填地址不能发货", model.RewardKindPhysical, model.FulfillmentStatusAllocated, model.FulfillmentStatusShipped, false},
+		{"实物发货", model.RewardKindPhysical, model.FulfillmentStatusClaimed, model.FulfillmentStatusShipped, true},
+		{"实物发货后不能改地址", model.RewardKindPhysical, model.FulfillmentStatusShipped, model.FulfillmentStatusClaimed, false},
+		{"实物送达", model.RewardKindPhysical, model.FulfillmentStatusShipped, model.FulfillmentStatusDelivered, true},
+		{"实物丢件", model.RewardKindPhysical, model.FulfillmentStatusShipped, model.FulfillmentStatusFailed, true},
+		{"勋章领取", model.RewardKindMedal, model.FulfillmentStatusAllocated, model.FulfillmentStatusClaimed, true},
+		{"勋章发放成功", model.RewardKindMedal, model.FulfillmentStatusClaimed, model.FulfillmentStatusDelivered, true},
+		{"勋章不能跳过领取", model.RewardKindMedal, model.FulfillmentStatusAllocated, model.FulfillmentStatusDelivered, false},
+		{"失败后重新发放", model.RewardKindMedal, model.FulfillmentStatusFailed, model.FulfillmentStatusAllocated, true},
+		{"积分没有兑奖流程", model.RewardKindPoints, model.FulfillmentStatusAllocated, model.FulfillmentStatusDelivered, false},
+	}
+	for _, c := range cases {
+		if got := CanTransition(c.kind, c.from, c.to); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// newTestService 按应用的方式将兑奖接到库存预留与释放的事务中
+func newTestService(t *testing.T, app core.App) (*Service, *inventory.Service) {
+	t.Helper()
+	service := NewService(app, nil)
+	inventoryService := inventory.NewService(app)
+	inventoryService.OnReserve(service.Allocate)
+	inventoryService.OnRelease(service.Revoke)
+	return service, inventoryService
+}
+
+// codeReward 创建兑换码奖品并导入码池
+func codeReward(t *testing.T, app core.App, service *Service, amount int, codes ...string) *model.Reward {
+	t.Helper()
+	reward := testapp.Reward(t, app, "激活码", amount)
+	reward.SetKind(model.RewardKindCode)
+	if err := app.Save(reward); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.ImportCodes(app, reward.Id, codes); err != nil {
+		t.Fatal(err)
+	}
+	return reward
+}
+
+// reserve 在事务中为获奖记录预留奖品，返回创建的兑奖记录
+func reserve(t *testing.T, app core.App, inventoryService *inventory.Service, reward *model.Reward, historyId string) *model.Fulfillment {
+	t.Helper()
+	if err := app.RunInTransaction(func(txApp core.App) error {
+		_, err := inventoryService.Reserve(txApp, reward.Id, historyId)
+		return err
+	}); err != nil {
+		t.Fatalf("预留失败: %v", err)
+	}
+	fulfillment, err := find(app, dbx.HashExp{model.FulfillmentsFieldHistoryId: historyId})
+	if err != nil {
+		t.Fatalf("预留后应创建兑奖记录: %v", err)
+	}
+	return fulfillment
+}
+
+// release 在事务中撤销获奖记录的预留，返回撤销后的兑奖记录
+func release(t *testing.T, app core.App, inventoryService *inventory.Service, historyId string) *model.Fulfillment {
+	t.Helper()
+	if err := app.RunInTransaction(func(txApp core.App) error {
+		_, err := inventoryService.Release(txApp, historyId, "违规")
+		return err
+	}); err != nil {
+		t.Fatalf("释放失败: %v", err)
+	}
+	fulfillment, err := find(app, dbx.HashExp{model.FulfillmentsFieldHistoryId: historyId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fulfillment
+}
+
+func TestService_ImportCodes(t *testing.T) {
+	app := testapp.New(t)
+	service, _ := newTestService(t, app)
+	reward := codeReward(t, app, service, 3, "A")
+
+	imported, skipped, err := service.ImportCodes(app, reward.Id, []string{" B ", "A", "", "B", "C"})
+	if err != nil || imported != 2 || skipped != 3 {
+		t.Errorf("ImportCodes() = %d, %d, %v, 期望导入 2 跳过 3", imported, skipped, err)
+	}
+	if total, used, _ := service.CodeStats(reward.Id); total != 3 || used != 0 {
+		t.Errorf("CodeStats() = %d, %d", total, used)
+	}
+
+	physical := testapp.Reward(t, app, "月饼", 1)
+	if _, _, err = service.ImportCodes(app, physical.Id, []string{"D"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("非兑换码奖品应拒绝导入, 得到 %v", err)
+	}
+}
+
+func TestService_AllocateCode(t *testing.T) {
+	app := testapp.New(t)
+	service, inventoryService := newTestService(t, app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := codeReward(t, app, service, 3, "A", "B")
+
+	// 预留时创建待领取的兑奖记录，领取时从码池分配，同一批导入的兑换码不保证顺序
+	assigned := map[string]bool{}
+	for i := range 2 {
+		history := testapp.History(t, app, user, reward, i+1)
+		fulfillment := reserve(t, app, inventoryService, reward, history.Id)
+		if fulfillment.Status() != model.FulfillmentStatusAllocated || fulfillment.UserId() != user.Id || fulfillment.Kind() != model.RewardKindCode {
+			t.Fatalf("兑奖记录 = %v", fulfillment.FieldsData())
+		}
+		claimed, err := service.Claim(user, fulfillment.Id, nil)
+		if err != nil || claimed.Status() != model.FulfillmentStatusDelivered {
+			t.Fatalf("领取失败: %v", err)
+		}
+		code, _ := service.Code(claimed)
+		assigned[code] = true
+	}
+	if !assigned["A"] || !assigned["B"] {
+		t.Errorf("两次领取应分别得到 A 与 B, 得到 %v", assigned)
+	}
+	if total, used, _ := service.CodeStats(reward.Id); total != 2 || used != 2 {
+		t.Errorf("CodeStats() = %d, %d", total, used)
+	}
+
+	// 码池发完时领取失败，兑奖记录保持待领取
+	history := testapp.History(t, app, user, reward, 3)
+	fulfillment := reserve(t, app, inventoryService, reward, history.Id)
+	if _, err := service.Claim(user, fulfillment.Id, nil); !errors.Is(err, ErrCodeExhausted) {
+		t.Fatalf("码池发完应返回 ErrCodeExhausted, 得到 %v", err)
+	}
+	fulfillment, _ = find(app, dbx.HashExp{model.CommonFieldId: fulfillment.Id})
+	if fulfillment.Status() != model.FulfillmentStatusAllocated || fulfillment.CodeId() != "" {
+		t.Errorf("领取失败后应保持待领取, 得到 %s %s", fulfillment.Status(), fulfillment.CodeId())
+	}
+
+	// 补充兑换码后可以继续领取
+	if _, _, err := service.ImportCodes(app, reward.Id, []string{"C"}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := service.Claim(user, fulfillment.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := service.Code(claimed); code != "C" {
+		t.Errorf("补充后领取得到 %s", code)
+	}
+}
+
+func TestService_AllocatePoints(t *testing.T) {
+	app := testapp.New(t)
+	_, inventoryService := newTestService(t, app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "积分", 1)
+	reward.SetKind(model.RewardKindPoints)
+	if err := app.Save(reward); err != nil {
+		t.Fatal(err)
+	}
+
+	// 积分奖品由积分账本发放，不创建兑奖记录
+	history := testapp.History(t, app, user, reward, 1)
+	if err := app.RunInTransaction(func(txApp core.App) error {
+		_, err := inventoryService.Reserve(txApp, reward.Id, history.Id)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := app.CountRecords(model.DbNameFulfillments); count != 0 {
+		t.Errorf("积分奖品不应创建兑奖记录, 得到 %d 条", count)
+	}
+}
+
+func TestService_RevokeCode(t *testing.T) {
+	app := testapp.New(t)
+	service, inventoryService := newTestService(t, app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := codeReward(t, app, service, 2, "A", "B")
+
+	// 未领取的兑奖记录随预留释放标记为失败
+	pending := testapp.History(t, app, user, reward, 1)
+	reserve(t, app, inventoryService, reward, pending.Id)
+	fulfillment := release(t, app, inventoryService, pending.Id)
+	if fulfillment.Status() != model.FulfillmentStatusFailed || !strings.Contains(fulfillment.Message(), "违规") {
+		t.Errorf("撤销后 = %s %q", fulfillment.Status(), fulfillment.Message())
+	}
+
+	// 重新预留时兑奖记录变回待领取
+	fulfillment = reserve(t, app, inventoryService, reward, pending.Id)
+	if fulfillment.Status() != model.FulfillmentStatusAllocated || fulfillment.Message() != "" {
+		t.Errorf("重新预留后 = %s %q", fulfillment.Status(), fulfillment.Message())
+	}
+
+	// 已送达的兑换码不能撤回，只记录日志
+	delivered := testapp.History(t, app, user, reward, 2)
+	fulfillment = reserve(t, app, inventoryService, reward, delivered.Id)
+	if _, err := service.Claim(user, fulfillment.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	fulfillment = release(t, app, inventoryService, delivered.Id)
+	if fulfillment.Status() != model.FulfillmentStatusDelivered || fulfillment.CodeId() == "" {
+		t.Errorf("已送达的兑奖记录不应撤回, 得到 %s", fulfillment.Status())
+	}
+}
+
+func TestService_AllocateRollback(t *testing.T) {
+	app := testapp.New(t)
+	service, inventoryService := newTestService(t, app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := codeReward(t, app, service, 1, "A")
+	history := testapp.History(t, app, user, reward, 1)
+
+	// 预留的事务回滚时兑奖记录一并回滚
+	failed := errors.New("保存博饼记录失败")
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if _, err := inventoryService.Reserve(txApp, reward.Id, history.Id); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatal(err)
+	}
+	if count, _ := app.CountRecords(model.DbNameFulfillments); count != 0 {
+		t.Errorf("回滚后不应有兑奖记录, 得到 %d 条", count)
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	app := testapp.New(t)
+	service, inventoryService := newTestService(t, app)
+	user := testapp.User(t, app, "1001", "alice")
+	code := codeReward(t, app, service, 2, "A")
+	physical := testapp.Reward(t, app, "月饼", 2)
+
+	reserve(t, app, inventoryService, code, testapp.History(t, app, user, code, 1).Id)
+	reserve(t, app, inventoryService, physical, testapp.History(t, app, user, physical, 2).Id)
+
+	cases := []struct {
+		status string
+		kind   string
+		want   int
+	}{
+		{"", "", 2},
+		{"", model.RewardKindCode.String(), 1},
+		{model.FulfillmentStatusAllocated.String(), "", 2},
+		{model.FulfillmentStatusDelivered.String(), model.RewardKindCode.String(), 0},
+	}
+	for _, c := range cases {
+		fulfillments, total, err := service.Search(c.status, c.kind, 1, 10)
+		if err != nil || total != c.want || len(fulfillments) != c.want {
+			t.Errorf("Search(%q, %q) = %d 条, total %d, %v, 期望 %d", c.status, c.kind, len(fulfillments), total, err, c.want)
+		}
+	}
+}
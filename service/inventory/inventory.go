@@ -0,0 +1,273 @@
+package inventory
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var (
+	ErrOutOfStock    = errors.New("奖品已发完")
+	ErrRetired       = errors.New("奖品已下架")
+	ErrBelowReserved = errors.New("库存不能少于已发放的数量")
+)
+
+// Stock 奖品库存，Remaining 为还可以发放的数量
+type Stock struct {
+	RewardId  string `json:"reward_id"`
+	Name      string `json:"name"`
+	Level     int    `json:"level"`
+	Point     int    `json:"point"`
+	Amount    int    `json:"amount"`
+	Reserved  int    `json:"reserved"`
+	Remaining int    `json:"remaining"`
+	LowStock  int    `json:"low_stock"`
+	Retired   bool   `json:"retired"`
+}
+
+func NewStock(reward *model.Reward) Stock {
+	stock := Stock{
+		RewardId:  reward.Id,
+		Name:      reward.Name(),
+		Level:     reward.Level(),
+		Point:     reward.Point(),
+		Amount:    reward.Amount(),
+		Reserved:  reward.Reserved(),
+		Remaining: max(0, reward.Amount()-reward.Reserved()),
+		LowStock:  reward.LowStock(),
+		Retired:   reward.Retired(),
+	}
+	if stock.Retired {
+		stock.Remaining = 0
+	}
+	return stock
+}
+
+// lowStockReached 预留后剩余数量刚好降到提醒阈值或发完，每次降到阈值只提醒一次
+func lowStockReached(stock Stock) bool {
+	return stock.Remaining == 0 || (stock.LowStock > 0 && stock.Remaining == stock.LowStock)
+}
+
+// Service 奖品库存：每个奖品一个预留计数，博饼事务中原子地预留，事务失败时随之回滚，撤销获奖时释放
+type Service struct {
+	app    core.App
+	logger *slog.Logger
+
+	onLowStock []func(txApp core.App, stock Stock)
+	onReserve  []func(txApp core.App, reward *model.Reward, historyId string) error
+	onRelease  []func(txApp core.App, historyId string, reason string) error
+}
+
+func NewService(app core.App) *Service {
+	return &Service{
+		app:    app,
+		logger: app.Logger().WithGroup("inventory"),
+	}
+}
+
+// OnLowStock 库存降到提醒阈值或发完时的回调，在预留的事务中执行
+func (service *Service) OnLowStock(fn func(txApp core.App, stock Stock)) {
+	service.onLowStock = append(service.onLowStock, fn)
+}
+
+// OnReserve 预留成功后的回调，在预留的事务中执行，返回错误时预留失败
+func (service *Service) OnReserve(fn func(txApp core.App, reward *model.Reward, historyId string) error) {
+	service.onReserve = append(service.onReserve, fn)
+}
+
+// OnRelease 释放预留后的回调，在释放的事务中执行，返回错误时释放失败
+func (service *Service) OnRelease(fn func(txApp core.App, historyId string, reason string) error) {
+	service.onRelease = append(service.onRelease, fn)
+}
+
+// Reserve 在事务中为获奖记录预留一份奖品，库存不足返回 ErrOutOfStock，已下架返回 ErrRetired，同一记录重复预留时直接返回
+func (service *Service) Reserve(txApp core.App, rewardId string, historyId string) (Stock, error) {
+	return service.reserve(txApp, rewardId, historyId, true)
+}
+
+// ReserveSettled 状元结算调整获奖记录时预留，下架只停止新的获奖，不影响结算
+func (service *Service) ReserveSettled(txApp core.App, rewardId string, historyId string) (Stock, error) {
+	return service.reserve(txApp, rewardId, historyId, false)
+}
+
+func (service *Service) reserve(txApp core.App, rewardId string, historyId string, checkRetired bool) (Stock, error) {
+	held, err := findHeld(txApp, historyId)
+	if err != nil {
+		return Stock{}, err
+	}
+	if held != nil {
+		return service.stock(txApp, held.RewardId())
+	}
+
+	where := dbx.And(
+		dbx.HashExp{model.CommonFieldId: rewardId},
+		dbx.NewExp("[["+model.RewardsFieldReserved+"]] < [["+model.RewardsFieldAmount+"]]"),
+	)
+	if checkRetired {
+		where = dbx.And(where, dbx.HashExp{model.RewardsFieldRetired: false})
+	}
+	result, err := txApp.DB().Update(model.DbNameRewards, dbx.Params{
+		model.RewardsFieldReserved: dbx.NewExp("[[" + model.RewardsFieldReserved + "]] + 1"),
+	}, where).Execute()
+	if err != nil {
+		return Stock{}, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return Stock{}, err
+	} else if affected == 0 {
+		stock, err := service.stock(txApp, rewardId)
+		if err != nil {
+			return stock, err
+		}
+		if checkRetired && stock.Retired {
+			return stock, ErrRetired
+		}
+		return stock, ErrOutOfStock
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameReservations)
+	if err != nil {
+		return Stock{}, err
+	}
+	reservation := model.NewReservationFromCollection(collection)
+	reservation.SetRewardId(rewardId)
+	reservation.SetHistoryId(historyId)
+	reservation.SetStatus(model.ReservationStatusHeld)
+	if err = txApp.Save(reservation); err != nil {
+		return Stock{}, fmt.Errorf("保存奖品预留失败: %w", err)
+	}
+
+	reward, err := findReward(txApp, rewardId)
+	if err != nil {
+		return Stock{}, err
+	}
+	for _, fn := range service.onReserve {
+		if err = fn(txApp, reward, historyId); err != nil {
+			return Stock{}, err
+		}
+	}
+	stock := NewStock(reward)
+	if lowStockReached(stock) {
+		service.logger.Warn("奖品库存不足", slog.String("reward_id", rewardId), slog.Int("remaining", stock.Remaining))
+		for _, fn := range service.onLowStock {
+			fn(txApp, stock)
+		}
+	}
+	return stock, nil
+}
+
+// Release 在事务中释放获奖记录的预留，奖品回到库存，没有预留时返回 false
+func (service *Service) Release(txApp core.App, historyId string, reason string) (bool, error) {
+	held, err := findHeld(txApp, historyId)
+	if err != nil || held == nil {
+		return false, err
+	}
+
+	held.SetStatus(model.ReservationStatusReleased)
+	held.SetReason(reason)
+	if err = txApp.Save(held); err != nil {
+		return false, err
+	}
+	if _, err = txApp.DB().Update(model.DbNameRewards, dbx.Params{
+		model.RewardsFieldReserved: dbx.NewExp("[[" + model.RewardsFieldReserved + "]] - 1"),
+	}, dbx.And(
+		dbx.HashExp{model.CommonFieldId: held.RewardId()},
+		dbx.NewExp("[["+model.RewardsFieldReserved+"]] > 0"),
+	)).Execute(); err != nil {
+		return false, err
+	}
+	for _, fn := range service.onRelease {
+		if err = fn(txApp, historyId, reason); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Restock 调整奖品总量与低库存提醒阈值，总量不能少于已预留的数量，返回修改前后的库存
+func (service *Service) Restock(txApp core.App, rewardId string, amount int, lowStock *int) (before Stock, after Stock, err error) {
+	reward, err := findReward(txApp, rewardId)
+	if err != nil {
+		return before, after, err
+	}
+	before = NewStock(reward)
+	if amount < reward.Reserved() {
+		return before, after, fmt.Errorf("%w: 已发放 %d 份", ErrBelowReserved, reward.Reserved())
+	}
+
+	reward.SetAmount(amount)
+	if lowStock != nil {
+		reward.SetLowStock(*lowStock)
+	}
+	if err = txApp.Save(reward); err != nil {
+		return before, after, err
+	}
+	return before, NewStock(reward), nil
+}
+
+// Retire 下架或重新上架奖品，下架后博饼不再获得该奖品，已获奖的记录不受影响
+func (service *Service) Retire(txApp core.App, rewardId string, retired bool) (before Stock, after Stock, err error) {
+	reward, err := findReward(txApp, rewardId)
+	if err != nil {
+		return before, after, err
+	}
+	before = NewStock(reward)
+	reward.SetRetired(retired)
+	if err = txApp.Save(reward); err != nil {
+		return before, after, err
+	}
+	return before, NewStock(reward), nil
+}
+
+// Stocks 所有奖品的库存，按等级倒序
+func (service *Service) Stocks() ([]Stock, error) {
+	rewards := []*model.Reward{}
+	if err := service.app.RecordQuery(model.DbNameRewards).
+		OrderBy(model.RewardsFieldLevel + " desc").
+		All(&rewards); err != nil {
+		return nil, err
+	}
+	stocks := make([]Stock, 0, len(rewards))
+	for _, reward := range rewards {
+		stocks = append(stocks, NewStock(reward))
+	}
+	return stocks, nil
+}
+
+func (service *Service) stock(txApp core.App, rewardId string) (Stock, error) {
+	reward, err := findReward(txApp, rewardId)
+	if err != nil {
+		return Stock{}, err
+	}
+	return NewStock(reward), nil
+}
+
+func findReward(txApp core.App, rewardId string) (*model.Reward, error) {
+	reward := new(model.Reward)
+	if err := txApp.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: rewardId}).One(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+// findHeld 获奖记录当前的预留，没有时返回 nil
+func findHeld(txApp core.App, historyId string) (*model.Reservation, error) {
+	reservation := new(model.Reservation)
+	if err := txApp.RecordQuery(model.DbNameReservations).
+		Where(dbx.HashExp{
+			model.ReservationsFieldHistoryId: historyId,
+			model.ReservationsFieldStatus:    model.ReservationStatusHeld.String(),
+		}).
+		One(reservation); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
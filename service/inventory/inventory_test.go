@@ -0,0 +1,245 @@
+package inventory
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestLowStockReached(t *testing.T) {
+	cases := []struct {
+		name  string
+		stock Stock
+		want  bool
+	}{
+		{"充足", Stock{Remaining: 5, LowStock: 2}, false},
+		{"降到阈值", Stock{Remaining: 2, LowStock: 2}, true},
+		{"低于阈值不重复提醒", Stock{Remaining: 1, LowStock: 2}, false},
+		{"发完", Stock{Remaining: 0, LowStock: 2}, true},
+		{"未设置阈值", Stock{Remaining: 1}, false},
+		{"未设置阈值发完", Stock{Remaining: 0}, true},
+	}
+	for _, c := range cases {
+		if got := lowStockReached(c.stock); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// reserved 重新读取奖品的预留数量
+func reserved(t *testing.T, app core.App, reward *model.Reward) int {
+	t.Helper()
+	fresh, err := findReward(app, reward.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fresh.Reserved()
+}
+
+func TestService_ReserveOutOfStock(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 2)
+
+	lowStock := []int{}
+	service.OnLowStock(func(txApp core.App, stock Stock) { lowStock = append(lowStock, stock.Remaining) })
+
+	for i, want := range []int{1, 0} {
+		history := testapp.History(t, app, user, reward, i+1)
+		stock, err := service.Reserve(app, reward.Id, history.Id)
+		if err != nil || stock.Remaining != want {
+			t.Fatalf("第 %d 次预留: remaining = %d, err = %v", i+1, stock.Remaining, err)
+		}
+	}
+	history := testapp.History(t, app, user, reward, 3)
+	if _, err := service.Reserve(app, reward.Id, history.Id); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("发完后应返回 ErrOutOfStock, 得到 %v", err)
+	}
+	if got := reserved(t, app, reward); got != 2 {
+		t.Errorf("预留数量 = %d, 期望 2", got)
+	}
+	if len(lowStock) != 1 || lowStock[0] != 0 {
+		t.Errorf("库存提醒 = %v, 期望发完时提醒一次", lowStock)
+	}
+}
+
+func TestService_ReserveConcurrent(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 3)
+
+	histories := make([]string, 10)
+	for i := range histories {
+		histories[i] = testapp.History(t, app, user, reward, i+1).Id
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(histories))
+	for i, historyId := range histories {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = app.RunInTransaction(func(txApp core.App) error {
+				_, err := service.Reserve(txApp, reward.Id, historyId)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrOutOfStock):
+			t.Errorf("并发预留失败: %v", err)
+		}
+	}
+	if succeeded != 3 || reserved(t, app, reward) != 3 {
+		t.Errorf("成功 %d 次、预留 %d, 期望都为 3", succeeded, reserved(t, app, reward))
+	}
+	if count, _ := app.CountRecords(model.DbNameReservations, dbx.HashExp{model.ReservationsFieldRewardId: reward.Id}); count != 3 {
+		t.Errorf("预留记录 %d 条, 期望 3", count)
+	}
+}
+
+func TestService_ReserveRetired(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 5)
+
+	before, after, err := service.Retire(app, reward.Id, true)
+	if err != nil || before.Retired || !after.Retired || after.Remaining != 0 {
+		t.Fatalf("下架失败: %+v %+v %v", before, after, err)
+	}
+
+	history := testapp.History(t, app, user, reward, 1)
+	if _, err = service.Reserve(app, reward.Id, history.Id); !errors.Is(err, ErrRetired) {
+		t.Errorf("下架后应返回 ErrRetired, 得到 %v", err)
+	}
+	// 状元结算不受下架影响
+	if _, err = service.ReserveSettled(app, reward.Id, history.Id); err != nil {
+		t.Errorf("下架后结算预留失败: %v", err)
+	}
+	if got := reserved(t, app, reward); got != 1 {
+		t.Errorf("预留数量 = %d, 期望 1", got)
+	}
+}
+
+func TestService_ReserveIdempotent(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 5)
+	other := testapp.Reward(t, app, "茶叶", 5)
+	history := testapp.History(t, app, user, reward, 1)
+
+	for range 2 {
+		if _, err := service.Reserve(app, reward.Id, history.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已有预留时返回原奖品的库存，不会再预留其他奖品
+	stock, err := service.Reserve(app, other.Id, history.Id)
+	if err != nil || stock.RewardId != reward.Id {
+		t.Errorf("重复预留应返回已预留的奖品, 得到 %s %v", stock.RewardId, err)
+	}
+	if reserved(t, app, reward) != 1 || reserved(t, app, other) != 0 {
+		t.Errorf("预留数量 = %d、%d, 期望 1、0", reserved(t, app, reward), reserved(t, app, other))
+	}
+	if count, _ := app.CountRecords(model.DbNameReservations, dbx.HashExp{model.ReservationsFieldHistoryId: history.Id}); count != 1 {
+		t.Errorf("预留记录 %d 条, 期望 1", count)
+	}
+}
+
+func TestService_Release(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 1)
+	history := testapp.History(t, app, user, reward, 1)
+
+	releases := []string{}
+	service.OnRelease(func(txApp core.App, historyId string, reason string) error {
+		releases = append(releases, reason)
+		return nil
+	})
+
+	if _, err := service.Reserve(app, reward.Id, history.Id); err != nil {
+		t.Fatal(err)
+	}
+	released, err := service.Release(app, history.Id, "撤销获奖")
+	if err != nil || !released {
+		t.Fatalf("释放失败: %v %v", released, err)
+	}
+	if got := reserved(t, app, reward); got != 0 {
+		t.Errorf("释放后预留数量 = %d, 期望 0", got)
+	}
+	if released, err = service.Release(app, history.Id, "重复释放"); err != nil || released {
+		t.Errorf("没有预留时应返回 false, 得到 %v %v", released, err)
+	}
+	if len(releases) != 1 || releases[0] != "撤销获奖" {
+		t.Errorf("释放回调 = %v", releases)
+	}
+
+	// 释放后的奖品可以再次发放
+	other := testapp.History(t, app, user, reward, 2)
+	if _, err = service.Reserve(app, reward.Id, other.Id); err != nil {
+		t.Errorf("释放后再次预留失败: %v", err)
+	}
+}
+
+func TestService_ReserveRollback(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 1)
+	history := testapp.History(t, app, user, reward, 1)
+
+	errRollback := errors.New("回滚")
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if _, err := service.Reserve(txApp, reward.Id, history.Id); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if got := reserved(t, app, reward); got != 0 {
+		t.Errorf("事务回滚后预留数量 = %d, 期望 0", got)
+	}
+}
+
+func TestService_Restock(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	reward := testapp.Reward(t, app, "月饼", 3)
+	for i := range 2 {
+		if _, err := service.Reserve(app, reward.Id, testapp.History(t, app, user, reward, i+1).Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := service.Restock(app, reward.Id, 1, nil); !errors.Is(err, ErrBelowReserved) {
+		t.Errorf("少于已预留数量时应返回 ErrBelowReserved, 得到 %v", err)
+	}
+	lowStock := 1
+	before, after, err := service.Restock(app, reward.Id, 2, &lowStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Remaining != 1 || after.Remaining != 0 || after.Amount != 2 || after.LowStock != 1 {
+		t.Errorf("调整库存 %+v -> %+v", before, after)
+	}
+}
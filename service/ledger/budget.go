@@ -0,0 +1,104 @@
+package ledger
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// BudgetUsage 来源或活动预算的使用情况，Cap 为空表示不限制
+type BudgetUsage struct {
+	Source    string `json:"source"`
+	Cap       *int   `json:"cap"`
+	Committed int    `json:"committed"` // 待发放与已发放订单占用的额度
+	Spent     int    `json:"spent"`     // 已记账的支出
+	Memo      string `json:"memo"`
+}
+
+// Budgets 活动与所有来源的预算使用情况
+func (service *Service) Budgets() ([]BudgetUsage, error) {
+	budgets := []*model.Budget{}
+	if err := service.app.RecordQuery(model.DbNameBudgets).All(&budgets); err != nil {
+		return nil, err
+	}
+	bySource := map[string]*model.Budget{}
+	sources := append([]string{BudgetActivity}, Sources...)
+	for _, budget := range budgets {
+		bySource[budget.Source()] = budget
+		if !slices.Contains(sources, budget.Source()) {
+			sources = append(sources, budget.Source())
+		}
+	}
+
+	usages := make([]BudgetUsage, 0, len(sources))
+	for _, source := range sources {
+		usage, err := service.usage(service.app, source, bySource[source])
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// SetBudget 设置来源或活动的预算上限，返回修改前后的使用情况
+func (service *Service) SetBudget(txApp core.App, source string, limit int, memo string) (before BudgetUsage, after BudgetUsage, err error) {
+	budget := new(model.Budget)
+	err = txApp.RecordQuery(model.DbNameBudgets).Where(dbx.HashExp{model.BudgetsFieldSource: source}).One(budget)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return before, after, err
+		}
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNameBudgets)
+		if err != nil {
+			return before, after, err
+		}
+		budget = model.NewBudgetFromCollection(collection)
+		budget.SetSource(source)
+		if before, err = service.usage(txApp, source, nil); err != nil {
+			return before, after, err
+		}
+	} else if before, err = service.usage(txApp, source, budget); err != nil {
+		return before, after, err
+	}
+
+	budget.SetCap(limit)
+	budget.SetMemo(memo)
+	if err = txApp.Save(budget); err != nil {
+		return before, after, err
+	}
+	after, err = service.usage(txApp, source, budget)
+	return before, after, err
+}
+
+func (service *Service) usage(txApp core.App, source string, budget *model.Budget) (BudgetUsage, error) {
+	usage := BudgetUsage{Source: source}
+	if budget != nil {
+		limit := budget.Cap()
+		usage.Cap = &limit
+		usage.Memo = budget.Memo()
+	}
+
+	var err error
+	if usage.Committed, err = committedPoints(txApp, source, ""); err != nil {
+		return usage, err
+	}
+	accounts := []any{}
+	for _, item := range budgetSources(source) {
+		accounts = append(accounts, BudgetAccount(item))
+	}
+	spent := 0
+	if err = txApp.DB().
+		Select("COALESCE(-SUM([[" + model.LedgerEntriesFieldAmount + "]]), 0)").
+		From(model.DbNameLedgerEntries).
+		Where(dbx.In(model.LedgerEntriesFieldAccount, accounts...)).
+		Row(&spent); err != nil {
+		return usage, err
+	}
+	usage.Spent = spent
+	return usage, nil
+}
@@ -0,0 +1,366 @@
+package ledger
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 熔断原因
+const (
+	TripPaymentMax  = "payment_max"  // 单笔积分超出上限
+	TripUserCap     = "user_cap"     // 用户累计收入超出上限
+	TripRate        = "rate"         // 每分钟支出超出上限
+	TripTotalBudget = "total_budget" // 活动总支出超出预算
+	TripManual      = "manual"       // 运营手动熔断
+)
+
+var (
+	ErrCircuitOpen = errors.New("支出熔断中，暂停发放")
+	ErrNotTripped  = errors.New("支出未熔断")
+)
+
+// GuardConfig 支出护栏，为 0 时不限制，任一阈值被触发时熔断，暂停所有发放直到运营重置
+type GuardConfig struct {
+	TotalBudget   int `json:"total_budget"`    // 活动总支出上限
+	UserCap       int `json:"user_cap"`        // 单个用户累计收入上限
+	RatePerMinute int `json:"rate_per_minute"` // 每分钟支出上限
+	PaymentMax    int `json:"payment_max"`     // 单笔积分上限
+}
+
+func (config *GuardConfig) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.TotalBudget, validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.UserCap, validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.RatePerMinute, validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.PaymentMax, validation.Min(0).Error("不能为负数")),
+	)
+}
+
+// GuardStatus 熔断状态与当前支出
+type GuardStatus struct {
+	Config   GuardConfig      `json:"config"`
+	Total    int              `json:"total"`    // 已记账的总支出
+	Rate     int              `json:"rate"`     // 最近一分钟的支出
+	Inflight int              `json:"inflight"` // 正在发放的积分
+	Held     int64            `json:"held"`     // 因熔断保持待发放的订单
+	Trip     *model.GuardTrip `json:"trip"`     // 未重置的熔断，为空表示正常
+	Tripped  bool             `json:"tripped"`
+}
+
+// guardUsage 已占用的支出
+type guardUsage struct {
+	Total int
+	User  int
+	Rate  int
+}
+
+// violation 触发的阈值
+type violation struct {
+	Reason  string
+	Limit   int
+	Actual  int
+	Message string
+}
+
+// inflight 正在向摸鱼派发放的订单，记账前也计入支出
+type inflight struct {
+	UserId string
+	Point  int
+}
+
+// checkGuard 检查本次发放后是否超出阈值，按单笔、用户、速率、总预算的顺序返回第一个超出的
+func checkGuard(config GuardConfig, usage guardUsage, point int) *violation {
+	if config.PaymentMax > 0 && point > config.PaymentMax {
+		return &violation{Reason: TripPaymentMax, Limit: config.PaymentMax, Actual: point,
+			Message: fmt.Sprintf("单笔 %d 积分超出上限 %d", point, config.PaymentMax)}
+	}
+	if config.UserCap > 0 && usage.User+point > config.UserCap {
+		return &violation{Reason: TripUserCap, Limit: config.UserCap, Actual: usage.User + point,
+			Message: fmt.Sprintf("用户累计收入 %d 积分，本次 %d，超出上限 %d", usage.User, point, config.UserCap)}
+	}
+	if config.RatePerMinute > 0 && usage.Rate+point > config.RatePerMinute {
+		return &violation{Reason: TripRate, Limit: config.RatePerMinute, Actual: usage.Rate + point,
+			Message: fmt.Sprintf("最近一分钟支出 %d 积分，本次 %d，超出上限 %d", usage.Rate, point, config.RatePerMinute)}
+	}
+	if config.TotalBudget > 0 && usage.Total+point > config.TotalBudget {
+		return &violation{Reason: TripTotalBudget, Limit: config.TotalBudget, Actual: usage.Total + point,
+			Message: fmt.Sprintf("活动总支出 %d 积分，本次 %d，超出预算 %d", usage.Total, point, config.TotalBudget)}
+	}
+	return nil
+}
+
+// OnTrip 熔断时的回调，用于提醒运营
+func (service *Service) OnTrip(fn func(trip *model.GuardTrip)) {
+	service.guardMutex.Lock()
+	defer service.guardMutex.Unlock()
+	service.onTrip = append(service.onTrip, fn)
+}
+
+// acquire 发放前检查熔断状态与阈值，通过后计入正在发放的支出，超出阈值时熔断
+//
+// 扣费订单不是支出，不经过护栏
+func (service *Service) acquire(points *model.Points) error {
+	if points.Point() < 0 {
+		return nil
+	}
+
+	service.guardMutex.Lock()
+
+	trip, err := service.openTrip()
+	if err != nil {
+		service.guardMutex.Unlock()
+		return err
+	}
+	if trip != nil {
+		service.guardMutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrCircuitOpen, trip.Message())
+	}
+
+	config, err := service.guardConfig()
+	if err != nil {
+		service.guardMutex.Unlock()
+		return err
+	}
+	usage, err := service.guardUsage(points.UserId())
+	if err != nil {
+		service.guardMutex.Unlock()
+		return err
+	}
+	for _, item := range service.inflight {
+		usage.Total += item.Point
+		usage.Rate += item.Point
+		if item.UserId == points.UserId() {
+			usage.User += item.Point
+		}
+	}
+	found := checkGuard(config, usage, points.Point())
+	if found == nil {
+		service.inflight[points.Id] = inflight{UserId: points.UserId(), Point: points.Point()}
+		service.guardMutex.Unlock()
+		return nil
+	}
+
+	trip, err = service.trip(found.Reason, points, found.Limit, found.Actual, found.Message)
+	callbacks := service.onTrip
+	service.guardMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, fn := range callbacks {
+		fn(trip)
+	}
+	return fmt.Errorf("%w: %s", ErrCircuitOpen, found.Message)
+}
+
+// release 发放结束，移出正在发放的支出
+func (service *Service) release(pointsId string) {
+	service.guardMutex.Lock()
+	defer service.guardMutex.Unlock()
+	delete(service.inflight, pointsId)
+}
+
+// Halt 运营手动熔断，已熔断时返回当前的熔断记录
+func (service *Service) Halt(memo string) (*model.GuardTrip, error) {
+	service.guardMutex.Lock()
+	trip, err := service.openTrip()
+	if err != nil || trip != nil {
+		service.guardMutex.Unlock()
+		return trip, err
+	}
+	trip, err = service.trip(TripManual, nil, 0, 0, memo)
+	callbacks := service.onTrip
+	service.guardMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range callbacks {
+		fn(trip)
+	}
+	return trip, nil
+}
+
+// Reset 运营重置熔断，恢复发放，保持待发放的订单需通过 Resume 补发
+func (service *Service) Reset(txApp core.App, actorId string, memo string) (*model.GuardTrip, error) {
+	service.guardMutex.Lock()
+	defer service.guardMutex.Unlock()
+
+	trip := new(model.GuardTrip)
+	if err := txApp.RecordQuery(model.DbNameGuardTrips).
+		Where(dbx.HashExp{model.GuardTripsFieldResetAt: ""}).
+		OrderBy(model.GuardTripsFieldCreated + " desc").
+		Limit(1).
+		One(trip); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotTripped
+		}
+		return nil, err
+	}
+	trip.SetResetBy(actorId)
+	trip.SetResetAt(types.NowDateTime())
+	trip.SetResetMemo(memo)
+	if err := txApp.Save(trip); err != nil {
+		return nil, err
+	}
+	service.logger.Info("支出熔断已重置", slog.String("trip_id", trip.Id), slog.String("actor_id", actorId))
+	return trip, nil
+}
+
+// Resume 补发因熔断保持待发放的订单，再次熔断时停止
+func (service *Service) Resume() (resumed int, held int, err error) {
+	orders := []*model.Points{}
+	if err = service.app.RecordQuery(model.DbNamePoints).
+		Where(heldExp()).
+		OrderBy(model.PointsFieldCreated + " asc").
+		All(&orders); err != nil {
+		return 0, 0, err
+	}
+
+	for i, points := range orders {
+		user := new(model.User)
+		if err = service.app.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.CommonFieldId: points.UserId()}).One(user); err != nil {
+			service.logger.Warn("用户不存在，跳过", slog.String("points_id", points.Id), slog.Any("err", err))
+			continue
+		}
+		if err = service.Distribute(points, user.Name()); err != nil {
+			if errors.Is(err, ErrCircuitOpen) {
+				return resumed, len(orders) - i, nil
+			}
+			service.logger.Error("补发积分失败", slog.String("points_id", points.Id), slog.Any("err", err))
+			continue
+		}
+		resumed++
+	}
+	return resumed, 0, nil
+}
+
+// Guard 熔断状态与当前支出
+func (service *Service) Guard() (*GuardStatus, error) {
+	service.guardMutex.Lock()
+	defer service.guardMutex.Unlock()
+
+	config, err := service.guardConfig()
+	if err != nil {
+		return nil, err
+	}
+	usage, err := service.guardUsage("")
+	if err != nil {
+		return nil, err
+	}
+	status := &GuardStatus{Config: config, Total: usage.Total, Rate: usage.Rate}
+	for _, item := range service.inflight {
+		status.Inflight += item.Point
+	}
+	if status.Trip, err = service.openTrip(); err != nil {
+		return nil, err
+	}
+	status.Tripped = status.Trip != nil
+	if status.Held, err = service.app.CountRecords(model.DbNamePoints, heldExp()); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Trips 熔断记录，按时间倒序
+func (service *Service) Trips(limit int) ([]*model.GuardTrip, error) {
+	trips := []*model.GuardTrip{}
+	err := service.app.RecordQuery(model.DbNameGuardTrips).
+		OrderBy(model.GuardTripsFieldCreated + " desc").
+		Limit(int64(limit)).
+		All(&trips)
+	return trips, err
+}
+
+// trip 保存熔断记录，需持有 guardMutex
+func (service *Service) trip(reason string, points *model.Points, limit int, actual int, message string) (*model.GuardTrip, error) {
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameGuardTrips)
+	if err != nil {
+		return nil, err
+	}
+	trip := model.NewGuardTripFromCollection(collection)
+	trip.SetReason(reason)
+	trip.SetLimit(limit)
+	trip.SetActual(actual)
+	trip.SetMessage(message)
+	if points != nil {
+		trip.SetPointsId(points.Id)
+		trip.SetUserId(points.UserId())
+		trip.SetPoint(points.Point())
+	}
+	if err = service.app.Save(trip); err != nil {
+		return nil, fmt.Errorf("保存熔断记录失败: %w", err)
+	}
+	service.logger.Error("支出熔断，暂停所有发放",
+		slog.String("reason", reason),
+		slog.String("trip_id", trip.Id),
+		slog.String("message", message))
+	return trip, nil
+}
+
+// openTrip 未重置的熔断记录，没有时返回 nil
+func (service *Service) openTrip() (*model.GuardTrip, error) {
+	trip := new(model.GuardTrip)
+	if err := service.app.RecordQuery(model.DbNameGuardTrips).
+		Where(dbx.HashExp{model.GuardTripsFieldResetAt: ""}).
+		OrderBy(model.GuardTripsFieldCreated + " desc").
+		Limit(1).
+		One(trip); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return trip, nil
+}
+
+func (service *Service) guardConfig() (GuardConfig, error) {
+	if service.registry == nil {
+		return GuardConfig{}, nil
+	}
+	return config.Get[GuardConfig](service.registry, model.ConfigKeyGuard)
+}
+
+// guardUsage 用户账户的记账收入，不扣除付费博饼的扣费，userId 为空时不统计用户
+func (service *Service) guardUsage(userId string) (guardUsage, error) {
+	usage := guardUsage{}
+	income := dbx.NewExp("[[" + model.LedgerEntriesFieldAmount + "]] > 0")
+	userAccounts := dbx.And(dbx.Like(model.LedgerEntriesFieldAccount, userAccountPrefix).Match(false, true), income)
+	if err := service.sumEntries(userAccounts, &usage.Total); err != nil {
+		return usage, err
+	}
+	since := types.NowDateTime().Add(-time.Minute).String()
+	if err := service.sumEntries(dbx.And(userAccounts, dbx.NewExp("[["+model.LedgerEntriesFieldCreated+"]] >= {:since}", dbx.Params{"since": since})), &usage.Rate); err != nil {
+		return usage, err
+	}
+	if userId != "" {
+		if err := service.sumEntries(dbx.And(dbx.HashExp{model.LedgerEntriesFieldAccount: UserAccount(userId)}, income), &usage.User); err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
+
+func (service *Service) sumEntries(where dbx.Expression, sum *int) error {
+	return service.app.DB().
+		Select("COALESCE(SUM([[" + model.LedgerEntriesFieldAmount + "]]), 0)").
+		From(model.DbNameLedgerEntries).
+		Where(where).
+		Row(sum)
+}
+
+// heldExp 因熔断保持待发放的订单
+func heldExp() dbx.Expression {
+	return dbx.And(
+		dbx.HashExp{model.PointsFieldStatus: model.PointStatusPending.String()},
+		dbx.Like(model.PointsFieldError, ErrCircuitOpen.Error()).Match(false, true),
+	)
+}
@@ -0,0 +1,148 @@
+package ledger
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCheckGuard(t *testing.T) {
+	config := GuardConfig{TotalBudget: 1000, UserCap: 100, RatePerMinute: 200, PaymentMax: 64}
+	usage := guardUsage{Total: 900, User: 80, Rate: 150}
+
+	cases := []struct {
+		name   string
+		config GuardConfig
+		usage  guardUsage
+		point  int
+		reason string
+		actual int
+	}{
+		{"未超出", config, usage, 20, "", 0},
+		{"单笔超出", config, usage, 65, TripPaymentMax, 65},
+		{"用户超出", config, usage, 21, TripUserCap, 101},
+		{"速率超出", config, guardUsage{Total: 900, Rate: 190}, 20, TripRate, 210},
+		{"总预算超出", config, guardUsage{Total: 990}, 20, TripTotalBudget, 1010},
+		{"单笔优先", config, guardUsage{Total: 990, User: 100, Rate: 200}, 100, TripPaymentMax, 100},
+		{"不限制", GuardConfig{}, guardUsage{Total: 1 << 20, User: 1 << 20, Rate: 1 << 20}, 1 << 10, "", 0},
+	}
+	for _, c := range cases {
+		found := checkGuard(c.config, c.usage, c.point)
+		if c.reason == "" {
+			if found != nil {
+				t.Errorf("%s: 不应熔断 %+v", c.name, found)
+			}
+			continue
+		}
+		if found == nil || found.Reason != c.reason || found.Actual != c.actual {
+			t.Errorf("%s: got %+v, want %s %d", c.name, found, c.reason, c.actual)
+		}
+	}
+}
+
+func TestGuardConfigValidate(t *testing.T) {
+	if err := (&GuardConfig{TotalBudget: 1000}).Validate(); err != nil {
+		t.Errorf("合法配置校验失败 %v", err)
+	}
+	errs := validation.Errors{}
+	if err := (&GuardConfig{UserCap: -1}).Validate(); !errors.As(err, &errs) || errs["user_cap"] == nil {
+		t.Errorf("负数应校验失败 %v", err)
+	}
+}
+
+// setGuard 修改支出护栏配置
+func setGuard(t *testing.T, service *Service, patch string) {
+	t.Helper()
+	if _, _, err := service.registry.Update(service.app, model.ConfigKeyGuard, json.RawMessage(patch)); err != nil {
+		t.Fatalf("修改护栏配置失败: %v", err)
+	}
+}
+
+func TestService_GuardTripHoldResume(t *testing.T) {
+	app, service, fake := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+	setGuard(t, service, `{"payment_max": 50}`)
+
+	tripped := 0
+	service.OnTrip(func(trip *model.GuardTrip) { tripped++ })
+
+	// 超出单笔上限时熔断，订单保持待发放
+	big, err := service.Pay(Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 60}, user.Name())
+	if !errors.Is(err, ErrCircuitOpen) || big.Status() != model.PointStatusPending {
+		t.Fatalf("超出单笔上限应熔断并保持待发放, 得到 %s %v", big.Status(), err)
+	}
+	// 熔断期间其他订单同样保持待发放
+	small, err := service.Pay(Order{Key: "test:2", Source: SourceGambling, UserId: user.Id, Point: 10}, user.Name())
+	if !errors.Is(err, ErrCircuitOpen) || small.Status() != model.PointStatusPending {
+		t.Fatalf("熔断期间应暂停发放, 得到 %s %v", small.Status(), err)
+	}
+	if tripped != 1 || len(fake.Calls()) != 0 {
+		t.Errorf("熔断回调 %d 次、摸鱼派请求 %d 次, 期望 1 次、0 次", tripped, len(fake.Calls()))
+	}
+
+	status, err := service.Guard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Tripped || status.Held != 2 || status.Trip.Reason() != TripPaymentMax {
+		t.Errorf("熔断状态 = %+v", status)
+	}
+
+	// 未重置前补发仍然保持待发放
+	if resumed, held, err := service.Resume(); err != nil || resumed != 0 || held != 2 {
+		t.Errorf("未重置时 Resume() = %d, %d, %v", resumed, held, err)
+	}
+
+	// 提高上限并重置后补发
+	setGuard(t, service, `{"payment_max": 100}`)
+	if _, err = service.Reset(app, user.Id, "已确认"); err != nil {
+		t.Fatalf("重置熔断失败: %v", err)
+	}
+	if _, err = service.Reset(app, user.Id, "重复重置"); !errors.Is(err, ErrNotTripped) {
+		t.Errorf("未熔断时重置应返回 ErrNotTripped, 得到 %v", err)
+	}
+	resumed, held, err := service.Resume()
+	if err != nil || resumed != 2 || held != 0 {
+		t.Fatalf("Resume() = %d, %d, %v", resumed, held, err)
+	}
+	if fake.Credited() != 70 {
+		t.Errorf("补发后到账 %d, 期望 70", fake.Credited())
+	}
+	if status, _ = service.Guard(); status.Tripped || status.Held != 0 || status.Total != 70 {
+		t.Errorf("补发后熔断状态 = %+v", status)
+	}
+}
+
+func TestService_GuardError(t *testing.T) {
+	app, service, fake := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+	setGuard(t, service, `{"payment_max": 50}`)
+
+	// 熔断记录保存失败时订单不能停留在待发放，否则 Resume 与后台都找不到
+	errSave := errors.New("模拟保存失败")
+	app.OnRecordCreateExecute(model.DbNameGuardTrips).BindFunc(func(event *core.RecordEvent) error {
+		return errSave
+	})
+
+	points, err := service.Pay(Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 60}, user.Name())
+	if !errors.Is(err, errSave) || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("护栏出错时应返回原始错误, 得到 %v", err)
+	}
+	if points.Status() != model.PointStatusFailed {
+		t.Errorf("护栏出错的订单状态 = %s, 期望 failed", points.Status())
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("护栏出错时不应请求摸鱼派")
+	}
+
+	// 失败的订单重试时重新经过护栏
+	setGuard(t, service, `{"payment_max": 100}`)
+	if err = service.Retry(points, user.Name()); err != nil || points.Status() != model.PointStatusSuccess {
+		t.Errorf("重试失败: %s %v", points.Status(), err)
+	}
+}
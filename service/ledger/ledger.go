@@ -0,0 +1,395 @@
+package ledger
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/fishpi"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// 积分来源，每个来源对应一个预算账户
+const (
+	SourceGambling      = "gambling"
+	SourceSettlement    = "settlement"
+	SourceReissue       = "reissue"
+	SourceArticleReward = "article_reward"
+)
+
+var Sources = []string{SourceGambling, SourceSettlement, SourceReissue, SourceArticleReward}
+
+// BudgetActivity 活动预算，限制所有积分来源的合计支出
+//
+// 每个部署只运行一个活动，活动预算与各来源的预算同时生效，任一超出时订单失败
+const BudgetActivity = "activity"
+
+// 付费博饼的扣费与退款，扣费订单的积分为负数，记账时用户账户支出、扣费账户收入
+const (
+	SourceDrawFee    = "draw_fee"
+	SourceDrawRefund = "draw_refund"
+)
+
+// 账户前缀
+const (
+	budgetAccountPrefix = "budget:"
+	userAccountPrefix   = "user:"
+)
+
+var (
+	ErrBudgetExceeded = errors.New("超出预算")
+	ErrNotFailed      = errors.New("只能重试发放失败的订单")
+	ErrChargeRetry    = errors.New("扣费订单不能重试")
+	ErrRunning        = errors.New("对账正在执行")
+	ErrUnknownResult  = errors.New("发放结果未知，需核对摸鱼派是否已到账")
+	ErrNotUnknown     = errors.New("只能核对发放结果未知的订单")
+)
+
+// Order 待创建的积分订单
+type Order struct {
+	Key       string // 幂等键，同一个键只会创建一条订单
+	Source    string
+	UserId    string
+	HistoryId string
+	Point     int
+	Memo      string
+}
+
+// HistoryKey 博饼记录奖励的幂等键，首次发放、补发与状元结算共用，保证每条记录只发放一次
+func HistoryKey(historyId string) string {
+	return "history:" + historyId
+}
+
+// ArticleRewardKey 文章评分奖励的幂等键
+func ArticleRewardKey(userId string) string {
+	return "article:" + userId
+}
+
+// DrawFeeKey 付费博饼扣费的幂等键，n 为用户的第几笔扣费
+func DrawFeeKey(userId string, n int) string {
+	return fmt.Sprintf("draw_fee:%s:%d", userId, n)
+}
+
+// DrawRefundKey 扣费订单的退款幂等键，每笔扣费最多退款一次
+func DrawRefundKey(feeKey string) string {
+	return "draw_refund:" + feeKey
+}
+
+// BudgetAccount 来源的预算账户，发放成功后记为支出
+func BudgetAccount(source string) string {
+	return budgetAccountPrefix + source
+}
+
+// UserAccount 用户的积分账户，发放成功后记为收入
+func UserAccount(userId string) string {
+	return userAccountPrefix + userId
+}
+
+// Memo 发放到摸鱼派的备注，附带交易单号用于对账
+func Memo(points *model.Points) string {
+	return fmt.Sprintf("%s 交易单号：%s", points.Memo(), points.Key())
+}
+
+// Service 积分账本：幂等地创建积分订单、检查来源预算、发放前经过支出护栏、发放成功后复式记账，并与摸鱼派余额对账
+type Service struct {
+	app           core.App
+	fishpiService *fishpi.Service
+	registry      *config.Registry
+	logger        *slog.Logger
+
+	reconciling sync.Mutex
+
+	guardMutex sync.Mutex
+	inflight   map[string]inflight
+	onTrip     []func(trip *model.GuardTrip)
+}
+
+func NewService(app core.App, fishpiService *fishpi.Service, registry *config.Registry) *Service {
+	return &Service{
+		app:           app,
+		fishpiService: fishpiService,
+		registry:      registry,
+		logger:        app.Logger().WithGroup("ledger"),
+		inflight:      map[string]inflight{},
+	}
+}
+
+// Pay 创建订单并向摸鱼派发放，订单已存在时直接返回不会重复发放
+//
+// 超出来源预算时订单标记为失败并返回 ErrBudgetExceeded，提高预算后可通过失败重试补发
+func (service *Service) Pay(order Order, username string) (*model.Points, error) {
+	points, created, err := service.Create(order)
+	if err != nil || !created {
+		return points, err
+	}
+	if points.Status() == model.PointStatusFailed {
+		return points, fmt.Errorf("%w: 订单 %s", ErrBudgetExceeded, points.Key())
+	}
+	return points, service.Distribute(points, username)
+}
+
+// Create 创建待发放的积分订单，相同幂等键或博饼记录的订单已存在时返回已有订单
+func (service *Service) Create(order Order) (points *model.Points, created bool, err error) {
+	err = service.app.RunInTransaction(func(txApp core.App) error {
+		var exists dbx.Expression = dbx.HashExp{model.PointsFieldKey: order.Key}
+		if order.HistoryId != "" {
+			exists = dbx.Or(exists, dbx.HashExp{model.PointsFieldHistoryId: order.HistoryId})
+		}
+		points = new(model.Points)
+		err := txApp.RecordQuery(model.DbNamePoints).Where(exists).One(points)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNamePoints)
+		if err != nil {
+			return err
+		}
+		points = model.NewPointsFromCollection(collection)
+		points.SetKey(order.Key)
+		points.SetSource(order.Source)
+		points.SetUserId(order.UserId)
+		points.SetHistoryId(order.HistoryId)
+		points.SetPoint(order.Point)
+		points.SetMemo(order.Memo)
+		points.SetStatus(model.PointStatusPending)
+		if err = checkBudget(txApp, order.Source, order.Point, ""); err != nil {
+			if !errors.Is(err, ErrBudgetExceeded) {
+				return err
+			}
+			service.logger.Error("积分订单超出预算", slog.String("key", order.Key), slog.Any("err", err))
+			points.SetStatus(model.PointStatusFailed)
+			points.SetError(err.Error())
+		}
+		created = true
+		return txApp.Save(points)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("保存积分订单失败: %w", err)
+	}
+	return points, created, nil
+}
+
+// Distribute 向摸鱼派发放待发放的订单，成功后在同一事务中更新状态并记账
+//
+// 支出熔断时订单保持待发放并返回 ErrCircuitOpen，运营重置后通过 Resume 补发，护栏检查出错时订单失败；
+// 摸鱼派明确拒绝时订单失败，可以重试；超时等无法确定是否到账时订单保持待发放并返回 ErrUnknownResult，
+// 不会被重试或补发，需要运营核对后通过 Resolve 确认
+func (service *Service) Distribute(points *model.Points, username string) error {
+	if points.Status() != model.PointStatusPending {
+		return nil
+	}
+
+	if err := service.acquire(points); err != nil {
+		// 熔断时保持待发放等待 Resume；护栏检查本身出错时还没有请求摸鱼派，标记为失败以便重试
+		if !errors.Is(err, ErrCircuitOpen) {
+			err = fmt.Errorf("支出护栏检查失败: %w", err)
+			points.SetStatus(model.PointStatusFailed)
+		}
+		points.SetError(err.Error())
+		if saveErr := service.app.Save(points); saveErr != nil {
+			service.logger.Error("更新积分订单状态失败", slog.String("points_id", points.Id), slog.Any("err", saveErr))
+		}
+		return err
+	}
+	defer service.release(points.Id)
+
+	var distributeErr error
+	if !service.app.IsDev() {
+		distributeErr = service.fishpiService.Distribute(username, points.Point(), Memo(points))
+	}
+	if distributeErr != nil {
+		if !errors.Is(distributeErr, fishpi.ErrRejected) {
+			distributeErr = fmt.Errorf("%w: %w", ErrUnknownResult, distributeErr)
+			service.logger.Error("积分发放结果未知", slog.String("points_id", points.Id), slog.Any("err", distributeErr))
+		} else {
+			points.SetStatus(model.PointStatusFailed)
+		}
+		points.SetError(distributeErr.Error())
+		if err := service.app.Save(points); err != nil {
+			service.logger.Error("更新积分订单状态失败", slog.String("points_id", points.Id), slog.Any("err", err))
+		}
+		return distributeErr
+	}
+
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		points.SetStatus(model.PointStatusSuccess)
+		points.SetError("")
+		if err := txApp.Save(points); err != nil {
+			return err
+		}
+		return post(txApp, points)
+	})
+	if err != nil {
+		// 积分已到账，只是本地状态未更新，对账时会发现
+		service.logger.Error("积分已发放但记账失败", slog.String("points_id", points.Id), slog.Any("err", err))
+	}
+	return err
+}
+
+// Retry 重新发放失败的订单，重新检查来源预算
+func (service *Service) Retry(points *model.Points, username string) error {
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		if err := txApp.RecordQuery(model.DbNamePoints).Where(dbx.HashExp{model.CommonFieldId: points.Id}).One(points); err != nil {
+			return err
+		}
+		if points.Status() != model.PointStatusFailed {
+			return ErrNotFailed
+		}
+		// 扣费失败时用户没有博饼，重试会在事后扣除用户的积分
+		if points.Point() < 0 {
+			return ErrChargeRetry
+		}
+		if err := checkBudget(txApp, points.Source(), points.Point(), points.Id); err != nil {
+			return err
+		}
+		points.SetStatus(model.PointStatusPending)
+		points.SetError("")
+		return txApp.Save(points)
+	})
+	if err != nil {
+		return err
+	}
+	return service.Distribute(points, username)
+}
+
+// Resolve 核对发放结果未知的订单：credited 为 true 时确认已到账并记账，否则标记为失败，之后可以重试
+func (service *Service) Resolve(txApp core.App, id string, credited bool) (before *model.Points, after *model.Points, err error) {
+	after = new(model.Points)
+	if err = txApp.RecordQuery(model.DbNamePoints).Where(dbx.And(dbx.HashExp{model.CommonFieldId: id}, unknownExp())).One(after); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrNotUnknown
+		}
+		return nil, nil, err
+	}
+	before = model.NewPoints(after.Fresh())
+
+	if credited {
+		after.SetStatus(model.PointStatusSuccess)
+		after.SetError("")
+		if err = txApp.Save(after); err != nil {
+			return before, nil, err
+		}
+		return before, after, post(txApp, after)
+	}
+
+	after.SetStatus(model.PointStatusFailed)
+	after.SetError("核对后确认未到账")
+	return before, after, txApp.Save(after)
+}
+
+// Unknown 发放结果未知、等待核对的订单
+func (service *Service) Unknown() ([]*model.Points, error) {
+	points := []*model.Points{}
+	err := service.app.RecordQuery(model.DbNamePoints).
+		Where(unknownExp()).
+		OrderBy(model.PointsFieldCreated + " asc").
+		All(&points)
+	return points, err
+}
+
+// unknownExp 发放结果未知的订单
+func unknownExp() dbx.Expression {
+	return dbx.And(
+		dbx.HashExp{model.PointsFieldStatus: model.PointStatusPending.String()},
+		dbx.Like(model.PointsFieldError, ErrUnknownResult.Error()).Match(false, true),
+	)
+}
+
+// post 为发放成功的订单写入借贷分录：来源预算账户支出，用户账户收入，已记账时跳过
+func post(txApp core.App, points *model.Points) error {
+	posted, err := txApp.CountRecords(model.DbNameLedgerEntries, dbx.HashExp{model.LedgerEntriesFieldTransaction: points.Key()})
+	if err != nil {
+		return err
+	}
+	if posted > 0 {
+		return nil
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameLedgerEntries)
+	if err != nil {
+		return err
+	}
+	for _, line := range []struct {
+		account string
+		amount  int
+	}{
+		{BudgetAccount(points.Source()), -points.Point()},
+		{UserAccount(points.UserId()), points.Point()},
+	} {
+		entry := model.NewLedgerEntryFromCollection(collection)
+		entry.SetTransaction(points.Key())
+		entry.SetPointsId(points.Id)
+		entry.SetAccount(line.account)
+		entry.SetAmount(line.amount)
+		entry.SetMemo(points.Memo())
+		if err = txApp.Save(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBudget 检查来源预算与活动预算，已占用的额度为待发放与已发放的订单，没有设置预算时不限制
+func checkBudget(txApp core.App, source string, point int, excludeId string) error {
+	if err := checkCap(txApp, source, point, excludeId); err != nil {
+		return err
+	}
+	if !slices.Contains(Sources, source) {
+		return nil
+	}
+	return checkCap(txApp, BudgetActivity, point, excludeId)
+}
+
+func checkCap(txApp core.App, source string, point int, excludeId string) error {
+	budget := new(model.Budget)
+	if err := txApp.RecordQuery(model.DbNameBudgets).Where(dbx.HashExp{model.BudgetsFieldSource: source}).One(budget); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	committed, err := committedPoints(txApp, source, excludeId)
+	if err != nil {
+		return err
+	}
+	if committed+point > budget.Cap() {
+		return fmt.Errorf("%w: %s 已占用 %d，本次 %d，上限 %d", ErrBudgetExceeded, source, committed, point, budget.Cap())
+	}
+	return nil
+}
+
+// budgetSources 预算包含的积分来源，活动预算包含所有来源
+func budgetSources(source string) []string {
+	if source == BudgetActivity {
+		return Sources
+	}
+	return []string{source}
+}
+
+// committedPoints 预算已占用的积分
+func committedPoints(txApp core.App, source string, excludeId string) (int, error) {
+	sources := []any{}
+	for _, item := range budgetSources(source) {
+		sources = append(sources, item)
+	}
+	committed := 0
+	err := txApp.DB().
+		Select("COALESCE(SUM([[" + model.PointsFieldPoint + "]]), 0)").
+		From(model.DbNamePoints).
+		Where(dbx.In(model.PointsFieldSource, sources...)).
+		AndWhere(dbx.In(model.PointsFieldStatus, model.PointStatusPending.String(), model.PointStatusSuccess.String())).
+		AndWhere(dbx.Not(dbx.HashExp{model.CommonFieldId: excludeId})).
+		Row(&committed)
+	return committed, err
+}
@@ -0,0 +1,175 @@
+package ledger
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestService 使用模拟摸鱼派接口的积分账本
+func newTestService(t *testing.T) (core.App, *Service, *testapp.Fishpi) {
+	t.Helper()
+	app := testapp.New(t)
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app,
+		testapp.FishpiDefinition(),
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &GuardConfig{} }},
+	)
+	return app, NewService(app, fake.Service(t, app, registry), registry), fake
+}
+
+// countEntries 订单的分录数量
+func countEntries(t *testing.T, app core.App, points *model.Points) int64 {
+	t.Helper()
+	count, err := app.CountRecords(model.DbNameLedgerEntries, dbx.HashExp{model.LedgerEntriesFieldTransaction: points.Key()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestService_PayIdempotent(t *testing.T) {
+	app, service, fake := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+	order := Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 32, Memo: "测试发放"}
+
+	first, err := service.Pay(order, user.Name())
+	if err != nil {
+		t.Fatalf("发放失败: %v", err)
+	}
+	second, err := service.Pay(order, user.Name())
+	if err != nil {
+		t.Fatalf("重复发放失败: %v", err)
+	}
+
+	if first.Id != second.Id {
+		t.Errorf("相同幂等键应返回同一条订单: %s %s", first.Id, second.Id)
+	}
+	if count, _ := app.CountRecords(model.DbNamePoints, dbx.HashExp{model.PointsFieldKey: order.Key}); count != 1 {
+		t.Errorf("订单数量 = %d, 期望 1", count)
+	}
+	if count := countEntries(t, app, first); count != 2 {
+		t.Errorf("分录数量 = %d, 期望一对借贷分录", count)
+	}
+	if calls := len(fake.Calls()); calls != 1 || fake.Credited() != 32 {
+		t.Errorf("摸鱼派请求 %d 次、到账 %d, 期望 1 次、32", calls, fake.Credited())
+	}
+}
+
+func TestService_DistributeRejected(t *testing.T) {
+	app, service, fake := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+	fake.SetMode(testapp.FishpiReject)
+
+	points, err := service.Pay(Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 32}, user.Name())
+	if err == nil || errors.Is(err, ErrUnknownResult) {
+		t.Fatalf("明确拒绝时应返回发放失败, 得到 %v", err)
+	}
+	if points.Status() != model.PointStatusFailed {
+		t.Errorf("明确拒绝的订单状态 = %s, 期望 failed", points.Status())
+	}
+
+	// 拒绝的订单可以直接重试
+	fake.SetMode(testapp.FishpiOK)
+	if err = service.Retry(points, user.Name()); err != nil {
+		t.Fatalf("重试失败: %v", err)
+	}
+	if points.Status() != model.PointStatusSuccess || fake.Credited() != 32 {
+		t.Errorf("重试后状态 %s、到账 %d", points.Status(), fake.Credited())
+	}
+}
+
+func TestService_DistributeUnknown(t *testing.T) {
+	app, service, fake := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+
+	// 连接在响应前断开，积分可能已经到账
+	fake.SetMode(testapp.FishpiDrop)
+	points, err := service.Pay(Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 32}, user.Name())
+	if !errors.Is(err, ErrUnknownResult) {
+		t.Fatalf("结果未知时应返回 ErrUnknownResult, 得到 %v", err)
+	}
+	if points.Status() != model.PointStatusPending {
+		t.Errorf("结果未知的订单状态 = %s, 期望 pending", points.Status())
+	}
+	fake.SetMode(testapp.FishpiOK)
+
+	// 核对前不能重试，熔断恢复也不会补发
+	if err = service.Retry(points, user.Name()); !errors.Is(err, ErrNotFailed) {
+		t.Errorf("结果未知的订单不应重试, 得到 %v", err)
+	}
+	if resumed, _, err := service.Resume(); err != nil || resumed != 0 {
+		t.Errorf("结果未知的订单不应被补发, resumed = %d, err = %v", resumed, err)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Fatalf("摸鱼派请求 %d 次, 期望 1 次", len(fake.Calls()))
+	}
+
+	unknown, err := service.Unknown()
+	if err != nil || len(unknown) != 1 || unknown[0].Id != points.Id {
+		t.Fatalf("待核对订单 = %v, %v", unknown, err)
+	}
+
+	// 核对已到账后记账，不再请求摸鱼派
+	_, after, err := service.Resolve(app, points.Id, true)
+	if err != nil {
+		t.Fatalf("核对订单失败: %v", err)
+	}
+	if after.Status() != model.PointStatusSuccess || countEntries(t, app, after) != 2 {
+		t.Errorf("核对到账后状态 %s、分录 %d", after.Status(), countEntries(t, app, after))
+	}
+	if _, _, err = service.Resolve(app, points.Id, true); !errors.Is(err, ErrNotUnknown) {
+		t.Errorf("重复核对应返回 ErrNotUnknown, 得到 %v", err)
+	}
+	if len(fake.Calls()) != 1 || fake.Credited() != 32 {
+		t.Errorf("摸鱼派请求 %d 次、到账 %d", len(fake.Calls()), fake.Credited())
+	}
+
+	// 核对未到账后订单失败，可以重试
+	fake.SetMode(testapp.FishpiDrop)
+	points, _ = service.Pay(Order{Key: "test:2", Source: SourceGambling, UserId: user.Id, Point: 16}, user.Name())
+	fake.SetMode(testapp.FishpiOK)
+	if _, after, err = service.Resolve(app, points.Id, false); err != nil || after.Status() != model.PointStatusFailed {
+		t.Fatalf("核对未到账失败: %v", err)
+	}
+	if err = service.Retry(after, user.Name()); err != nil || after.Status() != model.PointStatusSuccess {
+		t.Errorf("核对未到账后重试失败: %v", err)
+	}
+}
+
+func TestService_ActivityBudget(t *testing.T) {
+	app, service, _ := newTestService(t)
+	user := testapp.User(t, app, "1001", "alice")
+
+	if _, _, err := service.SetBudget(app, BudgetActivity, 100, "活动总预算"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Pay(Order{Key: "test:1", Source: SourceGambling, UserId: user.Id, Point: 60}, user.Name()); err != nil {
+		t.Fatalf("预算内发放失败: %v", err)
+	}
+	// 来源没有单独的预算，合计超出活动预算
+	points, err := service.Pay(Order{Key: "test:2", Source: SourceSettlement, UserId: user.Id, Point: 60}, user.Name())
+	if !errors.Is(err, ErrBudgetExceeded) || points.Status() != model.PointStatusFailed {
+		t.Fatalf("超出活动预算应失败, 得到 %v", err)
+	}
+
+	budgets, err := service.Budgets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, budget := range budgets {
+		if budget.Source != BudgetActivity {
+			continue
+		}
+		if budget.Cap == nil || *budget.Cap != 100 || budget.Committed != 60 || budget.Spent != 60 {
+			t.Errorf("活动预算使用情况 = %+v", budget)
+		}
+		return
+	}
+	t.Error("预算列表缺少活动预算")
+}
@@ -0,0 +1,175 @@
+package ledger
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+// reconcileJobId 定时对账任务
+const reconcileJobId = "ledger-reconcile"
+
+// Start 每天凌晨自动对账，报告可在后台下载
+func (service *Service) Start() {
+	service.app.Cron().MustAdd(reconcileJobId, "0 4 * * *", func() {
+		if _, _, err := service.Reconcile(); err != nil && !errors.Is(err, ErrRunning) {
+			service.logger.Error("定时对账失败", slog.Any("err", err))
+		}
+	})
+}
+
+// Reconcile 对账：检查订单与分录、来源预算，并与上一次对账比较摸鱼派余额，保存并返回报告
+func (service *Service) Reconcile() (*model.Reconciliation, *Report, error) {
+	if !service.reconciling.TryLock() {
+		return nil, nil, ErrRunning
+	}
+	defer service.reconciling.Unlock()
+
+	report := &Report{GeneratedAt: time.Now().UTC(), Balances: []Balance{}, Issues: []Issue{}}
+
+	// 1. 订单与分录
+	points := []*model.Points{}
+	if err := service.app.RecordQuery(model.DbNamePoints).OrderBy(model.PointsFieldCreated + " asc").All(&points); err != nil {
+		return nil, nil, fmt.Errorf("查询积分订单失败: %w", err)
+	}
+	orders := make([]order, 0, len(points))
+	for _, item := range points {
+		orders = append(orders, order{
+			Id:      item.Id,
+			Key:     item.Key(),
+			Source:  item.Source(),
+			UserId:  item.UserId(),
+			Point:   item.Point(),
+			Status:  item.Status().String(),
+			Updated: item.Updated().Time(),
+		})
+	}
+
+	ledgerEntries := []*model.LedgerEntry{}
+	if err := service.app.RecordQuery(model.DbNameLedgerEntries).All(&ledgerEntries); err != nil {
+		return nil, nil, fmt.Errorf("查询分录失败: %w", err)
+	}
+	entries := make([]entry, 0, len(ledgerEntries))
+	credited := map[string]int{}
+	for _, item := range ledgerEntries {
+		entries = append(entries, entry{Transaction: item.Transaction(), Account: item.Account(), Amount: item.Amount()})
+		if userId, ok := strings.CutPrefix(item.Account(), userAccountPrefix); ok {
+			credited[userId] += item.Amount()
+		}
+	}
+
+	var issues []Issue
+	report.Summary, issues = checkOrders(orders, entries, time.Now())
+	report.Issues = append(report.Issues, issues...)
+
+	// 2. 来源预算
+	var err error
+	if report.Budgets, err = service.Budgets(); err != nil {
+		return nil, nil, fmt.Errorf("查询预算失败: %w", err)
+	}
+	report.Issues = append(report.Issues, checkBudgets(report.Budgets)...)
+
+	// 3. 摸鱼派余额，与上一次对账的余额比较，开发模式下不会实际发放，跳过
+	users := []*model.User{}
+	if err = service.app.RecordQuery(model.DbNameUsers).All(&users); err != nil {
+		return nil, nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	usernames := map[string]string{}
+	for _, user := range users {
+		usernames[user.Id] = user.Name()
+	}
+	if !service.app.IsDev() {
+		if err = service.checkBalances(report, credited, usernames); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for i := range report.Issues {
+		report.Issues[i].Username = usernames[report.Issues[i].UserId]
+	}
+
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameReconciliations)
+	if err != nil {
+		return nil, nil, err
+	}
+	reconciliation := model.NewReconciliationFromCollection(collection)
+	reconciliation.SetIssues(len(report.Issues))
+	reconciliation.SetReport(report)
+	if err = service.app.Save(reconciliation); err != nil {
+		return nil, nil, fmt.Errorf("保存对账报告失败: %w", err)
+	}
+
+	service.logger.Info("对账完成",
+		slog.Int("orders", report.Summary.Orders),
+		slog.Int("balances", len(report.Balances)),
+		slog.Int("issues", len(report.Issues)))
+	return reconciliation, report, nil
+}
+
+// checkBalances 查询记账用户的摸鱼派余额，与上一次对账的余额比较
+func (service *Service) checkBalances(report *Report, credited map[string]int, usernames map[string]string) error {
+	userIds := make([]string, 0, len(credited))
+	for userId := range credited {
+		userIds = append(userIds, userId)
+	}
+	slices.Sort(userIds)
+	for _, userId := range userIds {
+		balance := Balance{UserId: userId, Username: usernames[userId], Credited: credited[userId]}
+		if reply, err := service.fishpiService.GetUser(balance.Username); err != nil {
+			service.logger.Warn("查询摸鱼派余额失败", slog.String("username", balance.Username), slog.Any("err", err))
+		} else {
+			balance.Balance = &reply.UserPoint
+		}
+		report.Balances = append(report.Balances, balance)
+	}
+
+	previousBalances := []Balance{}
+	previous, err := service.Latest()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("查找上一次对账失败: %w", err)
+	}
+	if previous != nil {
+		previousReport := new(Report)
+		if err = previous.UnmarshalJSONField(model.ReconciliationsFieldReport, previousReport); err != nil {
+			return fmt.Errorf("解析上一次对账报告失败: %w", err)
+		}
+		report.PreviousId = previous.Id
+		previousBalances = previousReport.Balances
+	}
+	report.Issues = append(report.Issues, checkBalances(report.Balances, previousBalances)...)
+	return nil
+}
+
+// Latest 最近一次对账
+func (service *Service) Latest() (*model.Reconciliation, error) {
+	reconciliation := new(model.Reconciliation)
+	if err := service.app.RecordQuery(model.DbNameReconciliations).
+		OrderBy(model.ReconciliationsFieldCreated + " desc").
+		Limit(1).
+		One(reconciliation); err != nil {
+		return nil, err
+	}
+	return reconciliation, nil
+}
+
+// Find 查找对账报告
+func (service *Service) Find(id string) (*model.Reconciliation, *Report, error) {
+	reconciliation := new(model.Reconciliation)
+	if err := service.app.RecordQuery(model.DbNameReconciliations).
+		Where(dbx.HashExp{model.CommonFieldId: id}).
+		One(reconciliation); err != nil {
+		return nil, nil, err
+	}
+	report := new(Report)
+	if err := reconciliation.UnmarshalJSONField(model.ReconciliationsFieldReport, report); err != nil {
+		return nil, nil, fmt.Errorf("解析对账报告失败: %w", err)
+	}
+	return reconciliation, report, nil
+}
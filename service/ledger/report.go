@@ -0,0 +1,236 @@
+package ledger
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 对账问题类型
+const (
+	IssueMissingEntries     = "missing_entries"     // 已发放的订单没有记账
+	IssueUnbalanced         = "unbalanced"          // 交易的借贷不平衡
+	IssueAmountMismatch     = "amount_mismatch"     // 记账金额与订单积分不一致
+	IssueOrphanEntries      = "orphan_entries"      // 分录没有对应的已发放订单
+	IssueStalePending       = "stale_pending"       // 订单长时间处于待发放
+	IssueBudgetExceeded     = "budget_exceeded"     // 来源支出超出预算
+	IssueBalanceMismatch    = "balance_mismatch"    // 摸鱼派余额变化与记账不一致
+	IssueBalanceUnavailable = "balance_unavailable" // 无法查询摸鱼派余额
+)
+
+// stalePendingAfter 待发放超过该时长的订单视为卡住
+const stalePendingAfter = 10 * time.Minute
+
+// Report 对账报告
+type Report struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	PreviousId  string        `json:"previousId"` // 余额基准的上一次对账
+	Summary     Summary       `json:"summary"`
+	Budgets     []BudgetUsage `json:"budgets"`
+	Balances    []Balance     `json:"balances"`
+	Issues      []Issue       `json:"issues"`
+}
+
+// Summary 订单与记账汇总
+type Summary struct {
+	Orders   int `json:"orders"`
+	Pending  int `json:"pending"`
+	Success  int `json:"success"`
+	Failed   int `json:"failed"`
+	Paid     int `json:"paid"`     // 已发放订单的积分
+	Credited int `json:"credited"` // 用户账户的记账收入
+}
+
+// Balance 用户的摸鱼派余额与记账收入
+//
+// 摸鱼派只提供当前余额，Expected 为上一次对账的余额加上两次对账之间的记账收入，用户在摸鱼派的其他积分变动也会造成差异
+type Balance struct {
+	UserId   string `json:"userId"`
+	Username string `json:"username"`
+	Credited int    `json:"credited"`
+	Balance  *int   `json:"balance"`
+	Expected *int   `json:"expected"`
+	Diff     int    `json:"diff"`
+}
+
+// Issue 对账发现的问题
+type Issue struct {
+	Type     string `json:"type"`
+	PointsId string `json:"pointsId,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Source   string `json:"source,omitempty"`
+	UserId   string `json:"userId,omitempty"`
+	Username string `json:"username,omitempty"`
+	Expected int    `json:"expected"`
+	Actual   int    `json:"actual"`
+	Message  string `json:"message"`
+}
+
+// order 对账使用的订单
+type order struct {
+	Id      string
+	Key     string
+	Source  string
+	UserId  string
+	Point   int
+	Status  string
+	Updated time.Time
+}
+
+// entry 对账使用的分录
+type entry struct {
+	Transaction string
+	Account     string
+	Amount      int
+}
+
+// checkOrders 检查订单与分录：已发放订单都已记账、交易借贷平衡、金额一致、没有多余的分录、没有卡住的订单
+func checkOrders(orders []order, entries []entry, now time.Time) (Summary, []Issue) {
+	summary := Summary{}
+	issues := []Issue{}
+
+	transactions := map[string][]entry{}
+	for _, item := range entries {
+		transactions[item.Transaction] = append(transactions[item.Transaction], item)
+		if strings.HasPrefix(item.Account, userAccountPrefix) {
+			summary.Credited += item.Amount
+		}
+	}
+
+	for _, item := range orders {
+		summary.Orders++
+		switch item.Status {
+		case "pending":
+			summary.Pending++
+			if now.Sub(item.Updated) > stalePendingAfter {
+				issues = append(issues, Issue{Type: IssueStalePending, PointsId: item.Id, Key: item.Key, Source: item.Source, UserId: item.UserId,
+					Expected: item.Point, Message: "订单长时间处于待发放，需确认摸鱼派是否已到账"})
+			}
+		case "failed":
+			summary.Failed++
+		}
+
+		lines, posted := transactions[item.Key]
+		delete(transactions, item.Key)
+		if item.Status != "success" {
+			if posted {
+				issues = append(issues, Issue{Type: IssueOrphanEntries, PointsId: item.Id, Key: item.Key, Source: item.Source, UserId: item.UserId,
+					Message: "订单未发放成功但已记账"})
+			}
+			continue
+		}
+
+		summary.Success++
+		summary.Paid += item.Point
+		if !posted {
+			issues = append(issues, Issue{Type: IssueMissingEntries, PointsId: item.Id, Key: item.Key, Source: item.Source, UserId: item.UserId,
+				Expected: item.Point, Message: "订单已发放但没有记账"})
+			continue
+		}
+
+		total, credited := 0, 0
+		for _, line := range lines {
+			total += line.Amount
+			if line.Account == UserAccount(item.UserId) {
+				credited += line.Amount
+			}
+		}
+		if total != 0 {
+			issues = append(issues, Issue{Type: IssueUnbalanced, PointsId: item.Id, Key: item.Key, Source: item.Source, UserId: item.UserId,
+				Actual: total, Message: "交易借贷不平衡"})
+		}
+		if credited != item.Point {
+			issues = append(issues, Issue{Type: IssueAmountMismatch, PointsId: item.Id, Key: item.Key, Source: item.Source, UserId: item.UserId,
+				Expected: item.Point, Actual: credited, Message: "记账金额与订单积分不一致"})
+		}
+	}
+
+	keys := make([]string, 0, len(transactions))
+	for key := range transactions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		total := 0
+		for _, line := range transactions[key] {
+			total += line.Amount
+		}
+		issues = append(issues, Issue{Type: IssueOrphanEntries, Key: key, Actual: total, Message: "分录没有对应的积分订单"})
+	}
+
+	return summary, issues
+}
+
+// checkBudgets 检查来源支出是否超出预算
+func checkBudgets(budgets []BudgetUsage) []Issue {
+	issues := []Issue{}
+	for _, budget := range budgets {
+		if budget.Cap != nil && budget.Spent > *budget.Cap {
+			issues = append(issues, Issue{Type: IssueBudgetExceeded, Source: budget.Source, Expected: *budget.Cap, Actual: budget.Spent, Message: "来源支出超出预算"})
+		}
+	}
+	return issues
+}
+
+// checkBalances 与上一次对账的余额比较，计算期望余额与差异
+func checkBalances(balances []Balance, previous []Balance) []Issue {
+	previousByUser := map[string]Balance{}
+	for _, item := range previous {
+		previousByUser[item.UserId] = item
+	}
+
+	issues := []Issue{}
+	for i := range balances {
+		balance := &balances[i]
+		if balance.Balance == nil {
+			issues = append(issues, Issue{Type: IssueBalanceUnavailable, UserId: balance.UserId, Expected: balance.Credited, Message: "无法查询摸鱼派余额"})
+			continue
+		}
+		last, ok := previousByUser[balance.UserId]
+		if !ok || last.Balance == nil {
+			continue
+		}
+
+		expected := *last.Balance + balance.Credited - last.Credited
+		balance.Expected = &expected
+		balance.Diff = *balance.Balance - expected
+		if balance.Diff != 0 {
+			issues = append(issues, Issue{Type: IssueBalanceMismatch, UserId: balance.UserId, Expected: expected, Actual: *balance.Balance,
+				Message: "摸鱼派余额变化与记账收入不一致，可能存在未到账或其他积分变动"})
+		}
+	}
+	return issues
+}
+
+// WriteCSV 写入 CSV 格式的对账报告：先是每位用户的余额，然后是发现的问题
+func (report *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	rows := [][]string{{"type", "user_id", "username", "points_id", "key", "source", "expected", "actual", "diff", "message"}}
+
+	optional := func(value *int) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.Itoa(*value)
+	}
+	for _, balance := range report.Balances {
+		diff := ""
+		if balance.Expected != nil {
+			diff = strconv.Itoa(balance.Diff)
+		}
+		rows = append(rows, []string{"balance", balance.UserId, balance.Username, "", "", "",
+			optional(balance.Expected), optional(balance.Balance), diff, "记账收入 " + strconv.Itoa(balance.Credited)})
+	}
+	for _, issue := range report.Issues {
+		rows = append(rows, []string{issue.Type, issue.UserId, issue.Username, issue.PointsId, issue.Key, issue.Source,
+			strconv.Itoa(issue.Expected), strconv.Itoa(issue.Actual), strconv.Itoa(issue.Actual - issue.Expected), issue.Message})
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
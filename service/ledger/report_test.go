@@ -0,0 +1,120 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestCheckOrders(t *testing.T) {
+	now := time.Now()
+	orders := []order{
+		{Id: "p1", Key: "history:h1", Source: SourceGambling, UserId: "u1", Point: 8, Status: "success", Updated: now},
+		{Id: "p2", Key: "history:h2", Source: SourceGambling, UserId: "u1", Point: 16, Status: "success", Updated: now},
+		{Id: "p3", Key: "history:h3", Source: SourceGambling, UserId: "u2", Point: 32, Status: "success", Updated: now},
+		{Id: "p4", Key: "history:h4", Source: SourceReissue, UserId: "u2", Point: 64, Status: "pending", Updated: now.Add(-time.Hour)},
+		{Id: "p5", Key: "history:h5", Source: SourceReissue, UserId: "u2", Point: 64, Status: "failed", Updated: now},
+	}
+	entries := []entry{
+		{Transaction: "history:h1", Account: BudgetAccount(SourceGambling), Amount: -8},
+		{Transaction: "history:h1", Account: UserAccount("u1"), Amount: 8},
+		// 金额与订单不一致且不平衡
+		{Transaction: "history:h3", Account: BudgetAccount(SourceGambling), Amount: -32},
+		{Transaction: "history:h3", Account: UserAccount("u2"), Amount: 30},
+		// 没有订单的分录
+		{Transaction: "history:h9", Account: UserAccount("u3"), Amount: 5},
+		{Transaction: "history:h9", Account: BudgetAccount(SourceGambling), Amount: -5},
+	}
+
+	summary, issues := checkOrders(orders, entries, now)
+	if summary.Orders != 5 || summary.Success != 3 || summary.Pending != 1 || summary.Failed != 1 || summary.Paid != 56 || summary.Credited != 43 {
+		t.Errorf("汇总错误 %+v", summary)
+	}
+
+	types := map[string]string{}
+	for _, issue := range issues {
+		types[issue.Type+":"+issue.Key] = issue.PointsId
+	}
+	want := []string{
+		IssueMissingEntries + ":history:h2",
+		IssueUnbalanced + ":history:h3",
+		IssueAmountMismatch + ":history:h3",
+		IssueStalePending + ":history:h4",
+		IssueOrphanEntries + ":history:h9",
+	}
+	if len(issues) != len(want) {
+		t.Errorf("问题数量 %d，应为 %d: %+v", len(issues), len(want), issues)
+	}
+	for _, key := range want {
+		if _, ok := types[key]; !ok {
+			t.Errorf("缺少问题 %s", key)
+		}
+	}
+}
+
+func TestCheckBalances(t *testing.T) {
+	balance := func(value int) *int { return &value }
+	previous := []Balance{
+		{UserId: "u1", Credited: 10, Balance: balance(100)},
+		{UserId: "u2", Credited: 0, Balance: balance(50)},
+	}
+	current := []Balance{
+		{UserId: "u1", Credited: 30, Balance: balance(120)},
+		{UserId: "u2", Credited: 8, Balance: balance(50)},
+		{UserId: "u3", Credited: 8, Balance: balance(8)},
+		{UserId: "u4", Credited: 8},
+	}
+
+	issues := checkBalances(current, previous)
+	if *current[0].Expected != 120 || current[0].Diff != 0 {
+		t.Errorf("u1 期望余额 %d 差异 %d", *current[0].Expected, current[0].Diff)
+	}
+	if *current[1].Expected != 58 || current[1].Diff != -8 {
+		t.Errorf("u2 期望余额 %d 差异 %d", *current[1].Expected, current[1].Diff)
+	}
+	if current[2].Expected != nil {
+		t.Error("首次对账的用户没有期望余额")
+	}
+	if len(issues) != 2 || issues[0].Type != IssueBalanceMismatch || issues[0].UserId != "u2" || issues[1].Type != IssueBalanceUnavailable {
+		t.Errorf("对账问题错误 %+v", issues)
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	limit := 100
+	issues := checkBudgets([]BudgetUsage{
+		{Source: SourceGambling, Cap: &limit, Spent: 100},
+		{Source: SourceReissue, Cap: &limit, Spent: 101},
+		{Source: SourceSettlement, Spent: 1000},
+	})
+	if len(issues) != 1 || issues[0].Source != SourceReissue || issues[0].Actual-issues[0].Expected != 1 {
+		t.Errorf("预算问题错误 %+v", issues)
+	}
+}
+
+func TestReportWriteCSV(t *testing.T) {
+	value, expected := 120, 118
+	report := &Report{
+		Balances: []Balance{{UserId: "u1", Username: "alice", Credited: 30, Balance: &value, Expected: &expected, Diff: 2}},
+		Issues:   []Issue{{Type: IssueMissingEntries, PointsId: "p2", Key: "history:h2", Source: SourceGambling, UserId: "u1", Username: "alice", Expected: 16, Message: "订单已发放但没有记账"}},
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := report.WriteCSV(buffer); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || len(rows[0]) != 10 {
+		t.Fatalf("CSV 行数 %d", len(rows))
+	}
+	if rows[1][0] != "balance" || rows[1][2] != "alice" || rows[1][6] != "118" || rows[1][7] != "120" || rows[1][8] != "2" {
+		t.Errorf("余额行错误 %v", rows[1])
+	}
+	if rows[2][0] != IssueMissingEntries || rows[2][4] != "history:h2" || rows[2][8] != "-16" {
+		t.Errorf("问题行错误 %v", rows[2])
+	}
+}
@@ -61,25 +61,63 @@ func NewMooncakeGame() *MooncakeGame {
 	return &MooncakeGame{}
 }
 
+// NewSeededRand 以博饼种子创建随机数，同一种子掷出的骰子与之后的奖项选择都可以复现
+func NewSeededRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed>>32))
+}
+
 // RollDices 掷骰子
 func (g *MooncakeGame) RollDices() [6]int {
+	return g.rollDices(rand.IntN)
+}
+
+// RollDicesWith 使用给定的随机数掷骰子
+func (g *MooncakeGame) RollDicesWith(r *rand.Rand) [6]int {
+	return g.rollDices(r.IntN)
+}
+
+func (g *MooncakeGame) rollDices(intN func(n int) int) [6]int {
 	var dices [6]int
 	for i := 0; i < 6; i++ {
-		dices[i] = rand.IntN(6) + 1 // 1-6点
+		dices[i] = intN(6) + 1 // 1-6点
 	}
 	return dices
 }
 
+// Odds 各奖励等级的理论概率，枚举全部 6^6 种骰子组合计算
+func (g *MooncakeGame) Odds() map[PrizeLevel]float64 {
+	counts := make(map[PrizeLevel]int)
+	total := 0
+	var dices [6]int
+	var roll func(i int)
+	roll = func(i int) {
+		if i == len(dices) {
+			counts[g.CalculatePrize(dices)]++
+			total++
+			return
+		}
+		for point := 1; point <= 6; point++ {
+			dices[i] = point
+			roll(i + 1)
+		}
+	}
+	roll(0)
+
+	odds := make(map[PrizeLevel]float64, len(PrizeLevelName))
+	for level := range PrizeLevelName {
+		odds[level] = float64(counts[level]) / float64(total)
+	}
+	return odds
+}
+
 // Play 进行一次博饼游戏
 func (g *MooncakeGame) Play() GameResult {
-	dices := g.RollDices()
-	prizeLevel := g.CalculatePrize(dices)
+	return g.PlayWithDices(g.RollDices())
+}
 
-	return GameResult{
-		Dices:      dices,
-		PrizeLevel: prizeLevel,
-		PrizeName:  PrizeLevelName[prizeLevel],
-	}
+// PlayWith 使用给定的随机数进行一次博饼游戏
+func (g *MooncakeGame) PlayWith(r *rand.Rand) GameResult {
+	return g.PlayWithDices(g.RollDicesWith(r))
 }
 
 // CalculatePrize 计算奖励等级
@@ -2,6 +2,7 @@ package mooncakeGambling
 
 import (
 	"fmt"
+	"math"
 	"testing"
 )
 
@@ -16,6 +17,21 @@ func TestMooncakeGame_Play(t *testing.T) {
 	}
 }
 
+func TestMooncakeGame_PlayWith(t *testing.T) {
+	game := NewMooncakeGame()
+
+	// 同一种子的骰子与之后的随机数都相同
+	a, b := NewSeededRand(20251006), NewSeededRand(20251006)
+	for i := 0; i < 10; i++ {
+		if x, y := game.PlayWith(a), game.PlayWith(b); x != y {
+			t.Fatalf("第%d次: %v != %v", i+1, x, y)
+		}
+	}
+	if x, y := a.IntN(100), b.IntN(100); x != y {
+		t.Errorf("之后的随机数不同: %d != %d", x, y)
+	}
+}
+
 func TestMooncakeGame_CalculatePrize(t *testing.T) {
 	game := NewMooncakeGame()
 
@@ -206,6 +222,32 @@ func TestMooncakeGame_ProbabilityStatistics(t *testing.T) {
 	}
 }
 
+func TestMooncakeGame_Odds(t *testing.T) {
+	odds := NewMooncakeGame().Odds()
+
+	total := 0.0
+	for _, p := range odds {
+		total += p
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("概率之和 %f 不为 1", total)
+	}
+
+	// 可以直接算出的组合数
+	cases := map[PrizeLevel]float64{
+		PrizeLevelZYLiuBo4:   1,   // 六个4
+		PrizeLevelZBianDiJin: 1,   // 六个1
+		PrizeLevelZYHeiLiuBo: 4,   // 六个2、3、5、6
+		PrizeLevelZYJinHua:   15,  // 四个4两个1的排列
+		PrizeLevelDuiTang:    720, // 1~6的全排列
+	}
+	for level, combinations := range cases {
+		if want := combinations / 46656; math.Abs(odds[level]-want) > 1e-12 {
+			t.Errorf("%s 的概率为 %g，期望 %g", PrizeLevelName[level], odds[level], want)
+		}
+	}
+}
+
 func TestCompareGameResult(t *testing.T) {
 	game := NewMooncakeGame()
 
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bless-activity/model"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	NotificationTypeChampionDethroned = "champion.dethroned" // 状元被夺走
+	NotificationTypeGuardTripped      = "guard.tripped"      // 积分发放熔断，发给运营
+	NotificationTypeLowStock          = "reward.low_stock"   // 奖品库存不足，发给运营
+	NotificationTypeRewardRetried     = "reward.retried"     // 用户申请的积分重新发放已处理
+)
+
+type NotificationService struct {
+	app core.App
+}
+
+func NewNotificationService(app core.App) *NotificationService {
+	return &NotificationService{
+		app: app,
+	}
+}
+
+// Send 给用户发送站内通知，txApp 为空时使用默认连接
+func (service *NotificationService) Send(txApp core.App, userId string, typ string, title string, content string, data any) (*model.Notification, error) {
+	if txApp == nil {
+		txApp = service.app
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameNotifications)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := model.NewNotificationFromCollection(collection)
+	notification.SetUserId(userId)
+	notification.SetType(typ)
+	notification.SetTitle(title)
+	notification.SetContent(content)
+	notification.SetData(data)
+	notification.SetRead(false)
+	if err = txApp.Save(notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// SendRole 给指定角色的所有用户发送站内通知，返回发送数量
+func (service *NotificationService) SendRole(txApp core.App, role model.UserRole, typ string, title string, content string, data any) (int, error) {
+	if txApp == nil {
+		txApp = service.app
+	}
+
+	users := []*model.User{}
+	if err := txApp.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.UsersFieldRole: role.String()}).All(&users); err != nil {
+		return 0, err
+	}
+	for i, user := range users {
+		if _, err := service.Send(txApp, user.Id, typ, title, content, data); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+// List 用户的通知，按时间倒序
+func (service *NotificationService) List(userId string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	where := dbx.HashExp{model.NotificationsFieldUserId: userId}
+	if unreadOnly {
+		where[model.NotificationsFieldRead] = false
+	}
+
+	notifications := []*model.Notification{}
+	if err := service.app.RecordQuery(model.DbNameNotifications).
+		Where(where).
+		OrderBy(model.NotificationsFieldCreated + " desc").
+		Limit(int64(limit)).
+		All(&notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// Unread 用户未读通知数量
+func (service *NotificationService) Unread(userId string) (int64, error) {
+	return service.app.CountRecords(model.DbNameNotifications, dbx.HashExp{
+		model.NotificationsFieldUserId: userId,
+		model.NotificationsFieldRead:   false,
+	})
+}
+
+// MarkRead 将用户的通知标记为已读
+func (service *NotificationService) MarkRead(userId string, id string) (*model.Notification, error) {
+	notification := new(model.Notification)
+	if err := service.app.RecordQuery(model.DbNameNotifications).
+		Where(dbx.HashExp{
+			model.CommonFieldId:            id,
+			model.NotificationsFieldUserId: userId,
+		}).
+		One(notification); err != nil {
+		return nil, err
+	}
+
+	if notification.Read() {
+		return notification, nil
+	}
+	notification.SetRead(true)
+	if err := service.app.Save(notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
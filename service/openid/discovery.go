@@ -0,0 +1,126 @@
+package openid
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	typeServer = "http://specs.openid.net/auth/2.0/server"
+	typeSignon = "http://specs.openid.net/auth/2.0/signon"
+
+	// maxDiscoveryHops X-XRDS-Location 最多跟随的次数，防止互相指向时无限请求
+	maxDiscoveryHops = 3
+)
+
+var ErrDiscoveryFailed = errors.New("openid: 未发现 OP 端点")
+
+type xrdsDocument struct {
+	XRD struct {
+		Services []xrdsService `xml:"Service"`
+	} `xml:"XRD"`
+}
+
+type xrdsService struct {
+	Priority int      `xml:"priority,attr"`
+	Types    []string `xml:"Type"`
+	URIs     []string `xml:"URI"`
+}
+
+var linkProviderRegexp = regexp.MustCompile(`(?is)<link[^>]+rel=["']?openid2\.provider["']?[^>]*>`)
+var hrefRegexp = regexp.MustCompile(`(?is)href=["']([^"']+)["']`)
+
+// discover 对标识符执行 Yadis(XRDS) 发现，失败时回退到 HTML 的 openid2.provider 链接
+func discover(ctx context.Context, client *http.Client, identifier string) (string, error) {
+	return discoverHops(ctx, client, identifier, 0)
+}
+
+func discoverHops(ctx context.Context, client *http.Client, identifier string, hops int) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, identifier, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Accept", "application/xrds+xml, text/html;q=0.9")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status:%d", ErrDiscoveryFailed, response.StatusCode)
+	}
+
+	// X-XRDS-Location 指向真正的 XRDS 文档
+	if location := response.Header.Get("X-XRDS-Location"); location != "" && location != identifier {
+		if hops >= maxDiscoveryHops {
+			return "", fmt.Errorf("%w: X-XRDS-Location 跳转超过 %d 次", ErrDiscoveryFailed, maxDiscoveryHops)
+		}
+		return discoverHops(ctx, client, location, hops+1)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "application/xrds+xml" || strings.Contains(string(body), "<xrds:XRDS") {
+		return parseXRDS(body)
+	}
+
+	return parseHTML(body)
+}
+
+func parseXRDS(body []byte) (string, error) {
+	document := new(xrdsDocument)
+	if err := xml.Unmarshal(body, document); err != nil {
+		return "", err
+	}
+
+	var endpoint string
+	bestPriority := -1
+	for _, service := range document.XRD.Services {
+		matched := false
+		for _, t := range service.Types {
+			t = strings.TrimSpace(t)
+			if t == typeServer || t == typeSignon {
+				matched = true
+				break
+			}
+		}
+		if !matched || len(service.URIs) == 0 {
+			continue
+		}
+		// priority 越小优先级越高
+		if bestPriority == -1 || service.Priority < bestPriority {
+			bestPriority = service.Priority
+			endpoint = strings.TrimSpace(service.URIs[0])
+		}
+	}
+
+	if endpoint == "" {
+		return "", ErrDiscoveryFailed
+	}
+	return endpoint, nil
+}
+
+func parseHTML(body []byte) (string, error) {
+	link := linkProviderRegexp.Find(body)
+	if link == nil {
+		return "", ErrDiscoveryFailed
+	}
+	matches := hrefRegexp.FindSubmatch(link)
+	if len(matches) < 2 {
+		return "", ErrDiscoveryFailed
+	}
+	return string(matches[1]), nil
+}
@@ -0,0 +1,33 @@
+package openid
+
+import (
+	"bufio"
+	"strings"
+)
+
+// parseKeyValue 解析 OpenID 直接通信使用的 Key-Value 格式（每行 key:value）
+func parseKeyValue(body string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values
+}
+
+// encodeKeyValue 按 openid.signed 的顺序生成待签名的 Key-Value 文本
+func encodeKeyValue(keys []string, values map[string]string) string {
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key)
+		builder.WriteString(":")
+		builder.WriteString(values[key])
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,525 @@
+// Package openid 实现 OpenID 2.0 依赖方（Relying Party）：
+// OP 端点发现、关联（共享密钥）协商、return_to 校验、签名字段校验、nonce 防重放与直接验证。
+package openid
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NsOpenID20       = "http://specs.openid.net/auth/2.0"
+	IdentifierSelect = "http://specs.openid.net/auth/2.0/identifier_select"
+
+	AssocTypeHMACSHA1   = "HMAC-SHA1"
+	AssocTypeHMACSHA256 = "HMAC-SHA256"
+
+	// StateParam return_to 中携带 CSRF state 的参数名
+	StateParam = "state"
+
+	DefaultNonceMaxAge = 5 * time.Minute
+	// ClockSkew 允许的 OP 时钟偏差，nonce 存储至少保留 NonceMaxAge + ClockSkew
+	ClockSkew = time.Minute
+	// 关联失败后的重试间隔，期间直接使用无状态模式
+	associationRetryInterval = 10 * time.Minute
+)
+
+var (
+	ErrCanceled          = errors.New("openid: 用户取消了认证")
+	ErrInvalidMode       = errors.New("openid: 无效的 openid.mode")
+	ErrInvalidNamespace  = errors.New("openid: 无效的 openid.ns")
+	ErrInvalidReturnTo   = errors.New("openid: return_to 校验失败")
+	ErrInvalidState      = errors.New("openid: state 校验失败")
+	ErrInvalidEndpoint   = errors.New("openid: op_endpoint 与发现结果不一致")
+	ErrUnsignedField     = errors.New("openid: 必要字段未签名")
+	ErrInvalidIdentity   = errors.New("openid: claimed_id 与 identity 不一致")
+	ErrInvalidNonce      = errors.New("openid: response_nonce 无效或已过期")
+	ErrReplayedNonce     = errors.New("openid: response_nonce 已被使用")
+	ErrInvalidSignature  = errors.New("openid: 签名校验失败")
+	ErrAssociationFailed = errors.New("openid: 建立关联失败")
+)
+
+// Config 依赖方配置
+type Config struct {
+	// ProviderURL OP 标识符，用于发现 OP 端点；为空时直接使用 Endpoint
+	ProviderURL string
+	// Endpoint 发现失败时使用的 OP 端点
+	Endpoint string
+	// VerifyURL 直接验证（check_authentication）地址，为空时使用 OP 端点
+	VerifyURL string
+	// VerifyJSON 直接验证时使用 JSON 请求体（摸鱼派的 /openid/verify 需要）
+	VerifyJSON bool
+	// Realm 信任域
+	Realm string
+	// ReturnTo 回调地址
+	ReturnTo string
+	// NonceMaxAge response_nonce 最大有效时间
+	NonceMaxAge time.Duration
+	// Client 发起 HTTP 请求的客户端
+	Client *http.Client
+}
+
+// Result 认证成功后 OP 断言的身份
+type Result struct {
+	ClaimedID  string
+	Identity   string
+	OPEndpoint string
+}
+
+// LocalID 返回 claimed_id 的最后一段路径，即 OP 内部的用户标识
+func (result *Result) LocalID() string {
+	u, err := url.Parse(result.ClaimedID)
+	if err != nil {
+		return ""
+	}
+	id := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if id == "." || id == "/" {
+		return ""
+	}
+	return id
+}
+
+// RelyingParty OpenID 2.0 依赖方
+type RelyingParty struct {
+	config       Config
+	nonces       NonceStore
+	associations AssociationStore
+	client       *http.Client
+	now          func() time.Time
+
+	mu           sync.Mutex
+	endpoint     string
+	assocRetryAt time.Time
+}
+
+func NewRelyingParty(config Config, nonces NonceStore, associations AssociationStore) *RelyingParty {
+	if config.NonceMaxAge <= 0 {
+		config.NonceMaxAge = DefaultNonceMaxAge
+	}
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if nonces == nil {
+		nonces = NewMemoryNonceStore(config.NonceMaxAge + ClockSkew)
+	}
+	if associations == nil {
+		associations = NewMemoryAssociationStore()
+	}
+	return &RelyingParty{
+		config:       config,
+		nonces:       nonces,
+		associations: associations,
+		client:       client,
+		now:          time.Now,
+	}
+}
+
+// Endpoint 返回 OP 端点，首次调用时执行发现并缓存结果
+func (rp *RelyingParty) Endpoint(ctx context.Context) (string, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.endpoint != "" {
+		return rp.endpoint, nil
+	}
+
+	if rp.config.ProviderURL != "" {
+		if endpoint, err := discover(ctx, rp.client, rp.config.ProviderURL); err == nil {
+			rp.endpoint = endpoint
+			return rp.endpoint, nil
+		} else if rp.config.Endpoint == "" {
+			return "", err
+		}
+	}
+	if rp.config.Endpoint == "" {
+		return "", ErrDiscoveryFailed
+	}
+	rp.endpoint = rp.config.Endpoint
+	return rp.endpoint, nil
+}
+
+// AuthURL 生成跳转到 OP 的认证地址，state 会附加在 return_to 上并在回调时校验
+func (rp *RelyingParty) AuthURL(ctx context.Context, state string) (string, error) {
+	endpoint, err := rp.Endpoint(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	returnTo, err := url.Parse(rp.config.ReturnTo)
+	if err != nil {
+		return "", err
+	}
+	returnToQuery := returnTo.Query()
+	returnToQuery.Set(StateParam, state)
+	returnTo.RawQuery = returnToQuery.Encode()
+
+	query := url.Values{}
+	query.Set("openid.ns", NsOpenID20)
+	query.Set("openid.mode", "checkid_setup")
+	query.Set("openid.return_to", returnTo.String())
+	query.Set("openid.realm", rp.config.Realm)
+	query.Set("openid.claimed_id", IdentifierSelect)
+	query.Set("openid.identity", IdentifierSelect)
+
+	// 关联失败时退化为无状态模式，由直接验证保证断言有效
+	if association, assocErr := rp.association(ctx, endpoint); assocErr == nil {
+		query.Set("openid.assoc_handle", association.Handle)
+	}
+
+	addr, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	addrQuery := addr.Query()
+	for key, values := range query {
+		addrQuery[key] = values
+	}
+	addr.RawQuery = addrQuery.Encode()
+	return addr.String(), nil
+}
+
+// association 获取可用的关联，没有时向 OP 申请
+func (rp *RelyingParty) association(ctx context.Context, endpoint string) (*Association, error) {
+	if association := rp.associations.Current(endpoint); association != nil {
+		return association, nil
+	}
+
+	// 未加密的关联只允许在 HTTPS 上进行
+	if !strings.HasPrefix(endpoint, "https://") {
+		return nil, ErrAssociationFailed
+	}
+
+	rp.mu.Lock()
+	retryAt := rp.assocRetryAt
+	rp.mu.Unlock()
+	if rp.now().Before(retryAt) {
+		return nil, ErrAssociationFailed
+	}
+
+	association, err := rp.associate(ctx, endpoint)
+	if err != nil {
+		rp.mu.Lock()
+		rp.assocRetryAt = rp.now().Add(associationRetryInterval)
+		rp.mu.Unlock()
+		return nil, err
+	}
+	rp.associations.Put(endpoint, association)
+	return association, nil
+}
+
+// associate 以 no-encryption 会话向 OP 申请 HMAC-SHA256 关联
+func (rp *RelyingParty) associate(ctx context.Context, endpoint string) (*Association, error) {
+
+	form := url.Values{}
+	form.Set("openid.ns", NsOpenID20)
+	form.Set("openid.mode", "associate")
+	form.Set("openid.assoc_type", AssocTypeHMACSHA256)
+	form.Set("openid.session_type", "no-encryption")
+
+	values, err := rp.postForm(ctx, endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	if values["error"] != "" || values["assoc_handle"] == "" || values["mac_key"] == "" {
+		return nil, fmt.Errorf("%w: %s", ErrAssociationFailed, values["error"])
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(values["mac_key"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAssociationFailed, err)
+	}
+	expiresIn, err := strconv.Atoi(values["expires_in"])
+	if err != nil || expiresIn <= 0 {
+		return nil, fmt.Errorf("%w: expires_in:%s", ErrAssociationFailed, values["expires_in"])
+	}
+	assocType := values["assoc_type"]
+	if assocType != AssocTypeHMACSHA1 && assocType != AssocTypeHMACSHA256 {
+		return nil, fmt.Errorf("%w: assoc_type:%s", ErrAssociationFailed, assocType)
+	}
+
+	return &Association{
+		Handle:    values["assoc_handle"],
+		Type:      assocType,
+		Secret:    secret,
+		ExpiresAt: rp.now().Add(time.Duration(expiresIn) * time.Second),
+	}, nil
+}
+
+// Verify 校验 OP 回调，requestURL 为回调请求地址，state 为发起登录时下发到 cookie 的值
+func (rp *RelyingParty) Verify(ctx context.Context, requestURL *url.URL, state string) (*Result, error) {
+	query := requestURL.Query()
+	fields := make(map[string]string)
+	for key := range query {
+		if strings.HasPrefix(key, "openid.") {
+			fields[strings.TrimPrefix(key, "openid.")] = query.Get(key)
+		}
+	}
+
+	switch fields["mode"] {
+	case "id_res":
+	case "cancel":
+		return nil, ErrCanceled
+	case "error":
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMode, fields["error"])
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMode, fields["mode"])
+	}
+
+	if fields["ns"] != NsOpenID20 {
+		return nil, ErrInvalidNamespace
+	}
+
+	if err := rp.verifyReturnTo(requestURL, fields["return_to"]); err != nil {
+		return nil, err
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(query.Get(StateParam)), []byte(state)) != 1 {
+		return nil, ErrInvalidState
+	}
+
+	endpoint, err := rp.Endpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if fields["op_endpoint"] != endpoint {
+		return nil, ErrInvalidEndpoint
+	}
+
+	signed := strings.Split(fields["signed"], ",")
+	required := []string{"op_endpoint", "return_to", "response_nonce", "assoc_handle", "claimed_id", "identity"}
+	for _, field := range required {
+		if !slices.Contains(signed, field) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsignedField, field)
+		}
+	}
+
+	issuedAt, err := rp.parseNonce(fields["response_nonce"])
+	if err != nil {
+		return nil, err
+	}
+
+	// 先校验签名，未经 OP 签名的 claimed_id 不可信，不能据此发起任何请求
+	if err = rp.verifySignature(ctx, endpoint, fields, signed); err != nil {
+		return nil, err
+	}
+
+	if err = rp.verifyIdentity(endpoint, fields); err != nil {
+		return nil, err
+	}
+
+	// 签名通过后再登记 nonce，避免伪造请求消耗掉合法的 nonce
+	if !rp.nonces.Accept(endpoint, fields["response_nonce"], issuedAt) {
+		return nil, ErrReplayedNonce
+	}
+
+	return &Result{
+		ClaimedID:  fields["claimed_id"],
+		Identity:   fields["identity"],
+		OPEndpoint: endpoint,
+	}, nil
+}
+
+// verifyReturnTo 校验 return_to 与配置的回调地址一致，且其参数与实际请求一致
+func (rp *RelyingParty) verifyReturnTo(requestURL *url.URL, rawReturnTo string) error {
+	returnTo, err := url.Parse(rawReturnTo)
+	if err != nil || rawReturnTo == "" {
+		return ErrInvalidReturnTo
+	}
+	expected, err := url.Parse(rp.config.ReturnTo)
+	if err != nil {
+		return ErrInvalidReturnTo
+	}
+
+	if !strings.EqualFold(returnTo.Scheme, expected.Scheme) ||
+		!strings.EqualFold(returnTo.Host, expected.Host) ||
+		returnTo.Path != expected.Path {
+		return ErrInvalidReturnTo
+	}
+
+	// 服务端收到的请求地址通常只有 path，有 host 时一并校验
+	if requestURL.Host != "" && !strings.EqualFold(requestURL.Host, returnTo.Host) {
+		return ErrInvalidReturnTo
+	}
+	if requestURL.Path != returnTo.Path {
+		return ErrInvalidReturnTo
+	}
+
+	requestQuery := requestURL.Query()
+	for key, values := range returnTo.Query() {
+		if !slices.Equal(requestQuery[key], values) {
+			return ErrInvalidReturnTo
+		}
+	}
+	return nil
+}
+
+// verifyIdentity 校验 claimed_id 与 identity，只接受 OP 所在站点签发的标识
+func (rp *RelyingParty) verifyIdentity(endpoint string, fields map[string]string) error {
+	claimedID, identity := fields["claimed_id"], fields["identity"]
+	if claimedID == "" || identity == "" || claimedID == IdentifierSelect {
+		return ErrInvalidIdentity
+	}
+
+	// 不支持委托，claimed_id 必须与 identity 相同（忽略片段）
+	if strings.SplitN(claimedID, "#", 2)[0] != identity {
+		return ErrInvalidIdentity
+	}
+
+	claimed, err := url.Parse(claimedID)
+	if err != nil {
+		return ErrInvalidIdentity
+	}
+	op, err := url.Parse(endpoint)
+	if err != nil {
+		return ErrInvalidEndpoint
+	}
+
+	// 其它站点的标识需要对 claimed_id 发现才能确认归属，这会向任意地址发起请求，直接拒绝
+	if !strings.EqualFold(claimed.Scheme, op.Scheme) || !strings.EqualFold(claimed.Host, op.Host) {
+		return ErrInvalidIdentity
+	}
+	return nil
+}
+
+// parseNonce 解析 response_nonce 的时间前缀并校验有效期
+func (rp *RelyingParty) parseNonce(nonce string) (time.Time, error) {
+	if len(nonce) < 20 || len(nonce) > 255 {
+		return time.Time{}, ErrInvalidNonce
+	}
+	issuedAt, err := time.Parse(time.RFC3339, nonce[:20])
+	if err != nil {
+		return time.Time{}, ErrInvalidNonce
+	}
+
+	now := rp.now()
+	if issuedAt.After(now.Add(ClockSkew)) || issuedAt.Before(now.Add(-rp.config.NonceMaxAge)) {
+		return time.Time{}, ErrInvalidNonce
+	}
+	return issuedAt, nil
+}
+
+// verifySignature 已知关联时在本地校验签名，否则向 OP 发起直接验证
+func (rp *RelyingParty) verifySignature(ctx context.Context, endpoint string, fields map[string]string, signed []string) error {
+	if handle := fields["invalidate_handle"]; handle != "" {
+		rp.associations.Delete(endpoint, handle)
+	}
+
+	if association := rp.associations.Get(endpoint, fields["assoc_handle"]); association != nil {
+		var hashFunc func() hash.Hash
+		switch association.Type {
+		case AssocTypeHMACSHA1:
+			hashFunc = sha1.New
+		case AssocTypeHMACSHA256:
+			hashFunc = sha256.New
+		default:
+			return ErrInvalidSignature
+		}
+
+		mac := hmac.New(hashFunc, association.Secret)
+		mac.Write([]byte(encodeKeyValue(signed, fields)))
+		expected := mac.Sum(nil)
+
+		sig, err := base64.StdEncoding.DecodeString(fields["sig"])
+		if err != nil || !hmac.Equal(sig, expected) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	return rp.checkAuthentication(ctx, endpoint, fields)
+}
+
+// checkAuthentication 直接验证：把断言原样发回 OP 由其确认
+func (rp *RelyingParty) checkAuthentication(ctx context.Context, endpoint string, fields map[string]string) error {
+	form := url.Values{}
+	for key, value := range fields {
+		form.Set("openid."+key, value)
+	}
+	form.Set("openid.mode", "check_authentication")
+
+	verifyURL := rp.config.VerifyURL
+	if verifyURL == "" {
+		verifyURL = endpoint
+	}
+
+	var values map[string]string
+	var err error
+	if rp.config.VerifyJSON {
+		body := make(map[string]string, len(form))
+		for key := range form {
+			body[key] = form.Get(key)
+		}
+		values, err = rp.postJSON(ctx, verifyURL, body)
+	} else {
+		values, err = rp.postForm(ctx, verifyURL, form)
+	}
+	if err != nil {
+		return err
+	}
+
+	if handle := values["invalidate_handle"]; handle != "" {
+		rp.associations.Delete(endpoint, handle)
+	}
+	if strings.TrimSpace(values["is_valid"]) != "true" {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (rp *RelyingParty) postForm(ctx context.Context, target string, form url.Values) (map[string]string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return rp.do(request)
+}
+
+func (rp *RelyingParty) postJSON(ctx context.Context, target string, body map[string]string) (map[string]string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return rp.do(request)
+}
+
+func (rp *RelyingParty) do(request *http.Request) (map[string]string, error) {
+	response, err := rp.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	// 直接通信出错时 OP 返回 400 且正文仍为 Key-Value 格式
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("status:%d", response.StatusCode)
+	}
+	return parseKeyValue(string(body)), nil
+}
@@ -0,0 +1,412 @@
+package openid
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider 模拟 OP：支持 XRDS 发现、关联、签名断言与直接验证
+type fakeProvider struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	secret       []byte
+	handle       string
+	privateKey   []byte
+	associations int
+	verifies     int
+	nonceSeq     int
+	disableAssoc bool
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	provider := &fakeProvider{
+		secret:     []byte("0123456789abcdef0123456789abcdef"),
+		handle:     "shared-handle",
+		privateKey: []byte("private-secret-for-stateless-mode"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xrds+xml")
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<xrds:XRDS xmlns:xrds="xri://$xrds" xmlns="xri://$xrd*($v*2.0)">
+  <XRD>
+    <Service priority="0">
+      <Type>http://specs.openid.net/auth/2.0/server</Type>
+      <URI>%s/openid/login</URI>
+    </Service>
+  </XRD>
+</xrds:XRDS>`, provider.server.URL)
+	})
+	mux.HandleFunc("/openid/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.PostForm.Get("openid.mode") {
+		case "associate":
+			provider.mu.Lock()
+			provider.associations++
+			disabled := provider.disableAssoc
+			provider.mu.Unlock()
+			if disabled {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = fmt.Fprint(w, "ns:"+NsOpenID20+"\nerror:unsupported\n")
+				return
+			}
+			_, _ = fmt.Fprintf(w, "ns:%s\nassoc_handle:%s\nsession_type:no-encryption\nassoc_type:%s\nexpires_in:3600\nmac_key:%s\n",
+				NsOpenID20, provider.handle, AssocTypeHMACSHA256, base64.StdEncoding.EncodeToString(provider.secret))
+		case "check_authentication":
+			provider.mu.Lock()
+			provider.verifies++
+			provider.mu.Unlock()
+			fields := make(map[string]string)
+			for key := range r.PostForm {
+				fields[strings.TrimPrefix(key, "openid.")] = r.PostForm.Get(key)
+			}
+			signed := strings.Split(fields["signed"], ",")
+			valid := sign(provider.privateKey, signed, fields) == fields["sig"]
+			_, _ = fmt.Fprintf(w, "ns:%s\nis_valid:%t\n", NsOpenID20, valid)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	provider.server = httptest.NewTLSServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (provider *fakeProvider) endpoint() string {
+	return provider.server.URL + "/openid/login"
+}
+
+func (provider *fakeProvider) identity(id string) string {
+	return provider.server.URL + "/member/" + id
+}
+
+// assert 生成 OP 的肯定断言，assocHandle 为空时使用私有关联（无状态模式）
+func (provider *fakeProvider) assert(returnTo string, id string, assocHandle string, issuedAt time.Time) url.Values {
+	provider.mu.Lock()
+	provider.nonceSeq++
+	nonce := fmt.Sprintf("%s%d", issuedAt.UTC().Format(time.RFC3339), provider.nonceSeq)
+	provider.mu.Unlock()
+
+	key := provider.secret
+	if assocHandle == "" {
+		assocHandle = "private-handle"
+		key = provider.privateKey
+	}
+
+	fields := map[string]string{
+		"ns":             NsOpenID20,
+		"mode":           "id_res",
+		"op_endpoint":    provider.endpoint(),
+		"claimed_id":     provider.identity(id),
+		"identity":       provider.identity(id),
+		"return_to":      returnTo,
+		"response_nonce": nonce,
+		"assoc_handle":   assocHandle,
+		"signed":         "op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle",
+	}
+	fields["sig"] = sign(key, strings.Split(fields["signed"], ","), fields)
+
+	query := url.Values{}
+	for k, v := range fields {
+		query.Set("openid."+k, v)
+	}
+	return query
+}
+
+func sign(key []byte, signed []string, fields map[string]string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encodeKeyValue(signed, fields)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resign 修改字段后按原签名字段重新签名
+func resign(assertion url.Values, key []byte) url.Values {
+	fields := make(map[string]string)
+	for k := range assertion {
+		fields[strings.TrimPrefix(k, "openid.")] = assertion.Get(k)
+	}
+	assertion.Set("openid.sig", sign(key, strings.Split(fields["signed"], ","), fields))
+	return assertion
+}
+
+const testReturnTo = "https://activity.example.com/fishpi/callback"
+
+func newTestRelyingParty(provider *fakeProvider) *RelyingParty {
+	return NewRelyingParty(Config{
+		ProviderURL: provider.server.URL,
+		Realm:       "https://activity.example.com",
+		ReturnTo:    testReturnTo,
+		Client:      provider.server.Client(),
+	}, nil, nil)
+}
+
+// callbackURL 模拟 OP 把用户重定向回 return_to 后服务端收到的请求地址
+func callbackURL(returnTo string, assertion url.Values) *url.URL {
+	u, _ := url.Parse(returnTo)
+	query := u.Query()
+	for key, values := range assertion {
+		query[key] = values
+	}
+	return &url.URL{Path: u.Path, RawQuery: query.Encode()}
+}
+
+func returnToFromAuthURL(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("解析认证地址失败: %v", err)
+	}
+	return u.Query().Get("openid.return_to")
+}
+
+func TestRelyingParty_AuthURL(t *testing.T) {
+	provider := newFakeProvider(t)
+	rp := newTestRelyingParty(provider)
+
+	authURL, err := rp.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if got := u.Scheme + "://" + u.Host + u.Path; got != provider.endpoint() {
+		t.Errorf("认证地址应指向发现的端点 %s, 得到 %s", provider.endpoint(), got)
+	}
+	query := u.Query()
+	if query.Get("openid.assoc_handle") != provider.handle {
+		t.Errorf("认证地址应携带关联 handle, 得到 %q", query.Get("openid.assoc_handle"))
+	}
+	if !strings.Contains(query.Get("openid.return_to"), "state=state-1") {
+		t.Errorf("return_to 应携带 state, 得到 %q", query.Get("openid.return_to"))
+	}
+
+	// 关联应被缓存复用
+	if _, err = rp.AuthURL(context.Background(), "state-2"); err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	if provider.associations != 1 {
+		t.Errorf("关联应只建立一次, 实际 %d 次", provider.associations)
+	}
+}
+
+func TestRelyingParty_VerifyWithAssociation(t *testing.T) {
+	provider := newFakeProvider(t)
+	rp := newTestRelyingParty(provider)
+
+	authURL, err := rp.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	returnTo := returnToFromAuthURL(t, authURL)
+
+	result, err := rp.Verify(context.Background(), callbackURL(returnTo, provider.assert(returnTo, "1630399200000", provider.handle, time.Now())), "state-1")
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if result.LocalID() != "1630399200000" {
+		t.Errorf("期望 openid 1630399200000, 得到 %s", result.LocalID())
+	}
+	if provider.verifies != 0 {
+		t.Errorf("已有关联时不应发起直接验证, 实际 %d 次", provider.verifies)
+	}
+}
+
+func TestRelyingParty_VerifyStateless(t *testing.T) {
+	provider := newFakeProvider(t)
+	provider.disableAssoc = true
+	rp := newTestRelyingParty(provider)
+
+	authURL, err := rp.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	returnTo := returnToFromAuthURL(t, authURL)
+
+	if _, err = rp.Verify(context.Background(), callbackURL(returnTo, provider.assert(returnTo, "42", "", time.Now())), "state-1"); err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if provider.verifies != 1 {
+		t.Errorf("无关联时应发起一次直接验证, 实际 %d 次", provider.verifies)
+	}
+
+	// 关联失败后一段时间内不再重复申请
+	if _, err = rp.AuthURL(context.Background(), "state-2"); err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	if provider.associations != 1 {
+		t.Errorf("关联失败后不应立即重试, 实际申请 %d 次", provider.associations)
+	}
+}
+
+func TestRelyingParty_VerifyRejects(t *testing.T) {
+	provider := newFakeProvider(t)
+	rp := newTestRelyingParty(provider)
+
+	authURL, err := rp.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	returnTo := returnToFromAuthURL(t, authURL)
+
+	tests := []struct {
+		name   string
+		build  func() (*url.URL, string)
+		expect error
+	}{
+		{"重放 nonce", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			if _, err := rp.Verify(context.Background(), callbackURL(returnTo, assertion), "state-1"); err != nil {
+				t.Fatalf("首次校验失败: %v", err)
+			}
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrReplayedNonce},
+		{"过期 nonce", func() (*url.URL, string) {
+			return callbackURL(returnTo, provider.assert(returnTo, "42", provider.handle, time.Now().Add(-time.Hour))), "state-1"
+		}, ErrInvalidNonce},
+		{"state 不一致", func() (*url.URL, string) {
+			return callbackURL(returnTo, provider.assert(returnTo, "42", provider.handle, time.Now())), "state-2"
+		}, ErrInvalidState},
+		{"return_to 被篡改", func() (*url.URL, string) {
+			forged := "https://evil.example.com/fishpi/callback?state=state-1"
+			return callbackURL(forged, provider.assert(forged, "42", provider.handle, time.Now())), "state-1"
+		}, ErrInvalidReturnTo},
+		{"请求路径与 return_to 不一致", func() (*url.URL, string) {
+			u := callbackURL(returnTo, provider.assert(returnTo, "42", provider.handle, time.Now()))
+			u.Path = "/other"
+			return u, "state-1"
+		}, ErrInvalidReturnTo},
+		{"签名被篡改", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			assertion.Set("openid.sig", base64.StdEncoding.EncodeToString([]byte("forged")))
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrInvalidSignature},
+		{"identity 被替换", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			assertion.Set("openid.identity", provider.identity("43"))
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrInvalidSignature},
+		{"identity 与 claimed_id 不一致", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			assertion.Set("openid.identity", provider.identity("43"))
+			return callbackURL(returnTo, resign(assertion, provider.secret)), "state-1"
+		}, ErrInvalidIdentity},
+		{"op_endpoint 不一致", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			assertion.Set("openid.op_endpoint", "https://evil.example.com/openid/login")
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrInvalidEndpoint},
+		{"必要字段未签名", func() (*url.URL, string) {
+			assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+			assertion.Set("openid.signed", "op_endpoint,claimed_id,identity,return_to,response_nonce")
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrUnsignedField},
+		{"用户取消", func() (*url.URL, string) {
+			assertion := url.Values{}
+			assertion.Set("openid.ns", NsOpenID20)
+			assertion.Set("openid.mode", "cancel")
+			return callbackURL(returnTo, assertion), "state-1"
+		}, ErrCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestURL, state := tt.build()
+			_, err := rp.Verify(context.Background(), requestURL, state)
+			if !errors.Is(err, tt.expect) {
+				t.Errorf("期望错误 %v, 得到 %v", tt.expect, err)
+			}
+		})
+	}
+}
+
+func TestRelyingParty_VerifyForeignClaimedID(t *testing.T) {
+	provider := newFakeProvider(t)
+	rp := newTestRelyingParty(provider)
+
+	// 其它站点的标识不应被请求
+	requests := 0
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, `<link rel="openid2.provider" href="%s">`, provider.endpoint())
+	}))
+	t.Cleanup(foreign.Close)
+
+	authURL, err := rp.AuthURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("生成认证地址失败: %v", err)
+	}
+	returnTo := returnToFromAuthURL(t, authURL)
+
+	forge := func(key []byte) url.Values {
+		assertion := provider.assert(returnTo, "42", provider.handle, time.Now())
+		assertion.Set("openid.claimed_id", foreign.URL+"/member/42")
+		assertion.Set("openid.identity", foreign.URL+"/member/42")
+		return resign(assertion, key)
+	}
+
+	if _, err = rp.Verify(context.Background(), callbackURL(returnTo, forge([]byte("forged"))), "state-1"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("未签名的断言应先因签名被拒绝, 得到 %v", err)
+	}
+	if _, err = rp.Verify(context.Background(), callbackURL(returnTo, forge(provider.secret)), "state-1"); !errors.Is(err, ErrInvalidIdentity) {
+		t.Errorf("其它站点的 claimed_id 应被拒绝, 得到 %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("不应请求 claimed_id 所在站点, 实际 %d 次", requests)
+	}
+}
+
+func TestDiscover_LocationLoop(t *testing.T) {
+	requests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// a 与 b 互相指向
+		if r.URL.Path == "/a" {
+			w.Header().Set("X-XRDS-Location", server.URL+"/b")
+		} else {
+			w.Header().Set("X-XRDS-Location", server.URL+"/a")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := discover(context.Background(), server.Client(), server.URL+"/a"); !errors.Is(err, ErrDiscoveryFailed) {
+		t.Errorf("循环跳转应发现失败, 得到 %v", err)
+	}
+	if requests != maxDiscoveryHops+1 {
+		t.Errorf("期望请求 %d 次, 实际 %d 次", maxDiscoveryHops+1, requests)
+	}
+}
+
+func TestMemoryNonceStore_Expire(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if !store.Accept("op", "n1", now) {
+		t.Fatal("首次使用的 nonce 应被接受")
+	}
+	if store.Accept("op", "n1", now) {
+		t.Fatal("重复的 nonce 应被拒绝")
+	}
+
+	now = now.Add(2 * time.Minute)
+	store.Accept("op", "n2", now)
+	if len(store.nonces) != 1 {
+		t.Errorf("过期 nonce 应被清理, 剩余 %d 条", len(store.nonces))
+	}
+}
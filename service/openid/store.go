@@ -0,0 +1,134 @@
+package openid
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceStore 记录已使用过的 response_nonce，防止断言被重放
+type NonceStore interface {
+	// Accept 首次出现的 nonce 返回 true，已出现过的返回 false
+	Accept(endpoint string, nonce string, issuedAt time.Time) bool
+}
+
+// AssociationStore 保存与 OP 之间建立的关联（共享密钥）
+type AssociationStore interface {
+	// Get 根据 handle 查找未过期的关联
+	Get(endpoint string, handle string) *Association
+	// Current 返回可用于发起认证的关联
+	Current(endpoint string) *Association
+	Put(endpoint string, association *Association)
+	Delete(endpoint string, handle string)
+}
+
+// Association OpenID 关联
+type Association struct {
+	Handle    string
+	Type      string
+	Secret    []byte
+	ExpiresAt time.Time
+}
+
+func (association *Association) Expired(now time.Time) bool {
+	return !now.Before(association.ExpiresAt)
+}
+
+// MemoryNonceStore 基于内存的 nonce 存储，超过 maxAge 的 nonce 会被清理
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	maxAge time.Duration
+	nonces map[string]time.Time
+	now    func() time.Time
+}
+
+func NewMemoryNonceStore(maxAge time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{
+		maxAge: maxAge,
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (store *MemoryNonceStore) Accept(endpoint string, nonce string, issuedAt time.Time) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// 清理过期的 nonce，过期的断言本身会因时间校验被拒绝，无需继续保存
+	deadline := store.now().Add(-store.maxAge)
+	for key, t := range store.nonces {
+		if t.Before(deadline) {
+			delete(store.nonces, key)
+		}
+	}
+
+	key := endpoint + "\n" + nonce
+	if _, exist := store.nonces[key]; exist {
+		return false
+	}
+	store.nonces[key] = issuedAt
+	return true
+}
+
+// MemoryAssociationStore 基于内存的关联存储
+type MemoryAssociationStore struct {
+	mu           sync.Mutex
+	associations map[string]map[string]*Association
+	now          func() time.Time
+}
+
+func NewMemoryAssociationStore() *MemoryAssociationStore {
+	return &MemoryAssociationStore{
+		associations: make(map[string]map[string]*Association),
+		now:          time.Now,
+	}
+}
+
+func (store *MemoryAssociationStore) Get(endpoint string, handle string) *Association {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	association, exist := store.associations[endpoint][handle]
+	if !exist {
+		return nil
+	}
+	if association.Expired(store.now()) {
+		delete(store.associations[endpoint], handle)
+		return nil
+	}
+	return association
+}
+
+func (store *MemoryAssociationStore) Current(endpoint string) *Association {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var current *Association
+	now := store.now()
+	for handle, association := range store.associations[endpoint] {
+		if association.Expired(now) {
+			delete(store.associations[endpoint], handle)
+			continue
+		}
+		if current == nil || association.ExpiresAt.After(current.ExpiresAt) {
+			current = association
+		}
+	}
+	return current
+}
+
+func (store *MemoryAssociationStore) Put(endpoint string, association *Association) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.associations[endpoint] == nil {
+		store.associations[endpoint] = make(map[string]*Association)
+	}
+	store.associations[endpoint][association.Handle] = association
+}
+
+func (store *MemoryAssociationStore) Delete(endpoint string, handle string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.associations[endpoint], handle)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/openid"
+	"log/slog"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var _ openid.NonceStore = (*OpenIdNonceStore)(nil)
+
+// OpenIdNonceStore 基于 openid_nonces 集合的 nonce 存储，重启后仍能拒绝重放的断言
+type OpenIdNonceStore struct {
+	app    core.App
+	maxAge time.Duration
+}
+
+// NewOpenIdNonceStore maxAge 应不小于断言的有效期加上允许的时钟偏差
+func NewOpenIdNonceStore(app core.App, maxAge time.Duration) *OpenIdNonceStore {
+	return &OpenIdNonceStore{
+		app:    app,
+		maxAge: maxAge,
+	}
+}
+
+// Accept 写入失败时一律拒绝：唯一索引冲突说明已被使用，其它错误无法确认是否重放
+func (store *OpenIdNonceStore) Accept(endpoint string, nonce string, issuedAt time.Time) bool {
+	logger := store.app.Logger().With(slog.String("service", "openid nonce"))
+
+	// 过期的断言本身会因时间校验被拒绝，无需继续保存
+	deadline, _ := types.ParseDateTime(time.Now().Add(-store.maxAge))
+	if _, err := store.app.DB().Delete(model.DbNameOpenidNonces, dbx.NewExp(
+		"[["+model.OpenidNoncesFieldIssuedAt+"]] < {:deadline}", dbx.Params{"deadline": deadline.String()},
+	)).Execute(); err != nil {
+		logger.Warn("清理过期 nonce 失败", slog.Any("err", err))
+	}
+
+	collection, err := store.app.FindCollectionByNameOrId(model.DbNameOpenidNonces)
+	if err != nil {
+		logger.Error("查询 nonce 集合失败", slog.Any("err", err))
+		return false
+	}
+	record := model.NewOpenidNonceFromCollection(collection)
+	record.SetEndpoint(endpoint)
+	record.SetNonce(nonce)
+	at, _ := types.ParseDateTime(issuedAt)
+	record.SetIssuedAt(at)
+	if err = store.app.Save(record); err != nil {
+		logger.Warn("登记 nonce 失败", slog.String("nonce", nonce), slog.Any("err", err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"testing"
+	"time"
+)
+
+func TestOpenIdNonceStore_Accept(t *testing.T) {
+	app := testapp.New(t)
+	now := time.Now()
+
+	store := NewOpenIdNonceStore(app, time.Minute)
+	if !store.Accept("op", "n1", now) {
+		t.Fatal("首次使用的 nonce 应被接受")
+	}
+	if store.Accept("op", "n1", now) {
+		t.Fatal("重复的 nonce 应被拒绝")
+	}
+	if !store.Accept("other", "n1", now) {
+		t.Fatal("不同 OP 的相同 nonce 应被接受")
+	}
+
+	// 重启后新建的存储仍能拒绝重放
+	if NewOpenIdNonceStore(app, time.Minute).Accept("op", "n1", now) {
+		t.Fatal("重启后重复的 nonce 应被拒绝")
+	}
+
+	// 过期的 nonce 被清理
+	store.Accept("op", "old", now.Add(-2*time.Minute))
+	store.Accept("op", "n2", now)
+	count, err := app.CountRecords(model.DbNameOpenidNonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("过期 nonce 应被清理, 剩余 %d 条", count)
+	}
+}
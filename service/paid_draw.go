@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/ledger"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var (
+	ErrPaidDrawDisabled  = errors.New("未开启付费博饼")
+	ErrPaidDrawExhausted = errors.New("付费博饼次数已用完")
+	ErrPaidDrawCharging  = errors.New("上一次付费博饼正在扣费，请稍后再试")
+	ErrPaidDrawCharge    = errors.New("扣除积分失败")
+)
+
+// PaidDrawConfig 付费博饼：免费次数用完后可花费积分购买额外的博饼次数
+type PaidDrawConfig struct {
+	Enabled bool `json:"enabled"`
+	Cost    int  `json:"cost"` // 每次花费的积分
+	// MaxTimes 每位用户最多购买的次数，为 0 时免费与付费次数合计不超过 MaxMooncakeGamblingTimes
+	MaxTimes int `json:"max_times"`
+}
+
+func (config *PaidDrawConfig) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.Cost, validation.When(config.Enabled, validation.Required.Error("开启后不能为空")), validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.MaxTimes, validation.Min(0).Error("不能为负数")),
+	)
+}
+
+// PaidDrawQuota 用户的付费博饼次数
+type PaidDrawQuota struct {
+	Enabled bool `json:"enabled"`
+	Cost    int  `json:"cost"`
+	Used    int  `json:"used"` // 已付费博饼的次数
+	Rest    int  `json:"rest"` // 还可以购买的次数
+}
+
+// PaidDrawService 付费博饼：博饼前通过积分账本扣费，博饼失败时退款
+type PaidDrawService struct {
+	app           core.App
+	ledgerService *ledger.Service
+	registry      *config.Registry
+	logger        *slog.Logger
+}
+
+func NewPaidDrawService(app core.App, ledgerService *ledger.Service, registry *config.Registry) *PaidDrawService {
+	return &PaidDrawService{
+		app:           app,
+		ledgerService: ledgerService,
+		registry:      registry,
+		logger:        app.Logger().WithGroup("paid_draw"),
+	}
+}
+
+// Quota 用户的付费博饼次数，freeTimes 为用户的免费次数
+func (service *PaidDrawService) Quota(txApp core.App, userId string, freeTimes int) (PaidDrawQuota, error) {
+	value, err := config.Get[PaidDrawConfig](service.registry, model.ConfigKeyPaidDraw)
+	if err != nil {
+		return PaidDrawQuota{}, err
+	}
+	quota := PaidDrawQuota{Enabled: value.Enabled, Cost: value.Cost}
+
+	used, err := txApp.CountRecords(model.DbNameHistories, dbx.And(
+		dbx.HashExp{model.HistoriesFieldUserId: userId},
+		dbx.Not(dbx.HashExp{model.HistoriesFieldFeeId: ""}),
+	))
+	if err != nil {
+		return quota, err
+	}
+	quota.Used = int(used)
+
+	if value.Enabled {
+		limit := model.MaxMooncakeGamblingTimes - freeTimes
+		if value.MaxTimes > 0 {
+			limit = value.MaxTimes
+		}
+		quota.Rest = max(0, limit-quota.Used)
+	}
+	return quota, nil
+}
+
+// Charge 扣除一次付费博饼的积分，返回发放成功的扣费订单
+func (service *PaidDrawService) Charge(user *model.User, quota PaidDrawQuota) (*model.Points, error) {
+	if !quota.Enabled {
+		return nil, ErrPaidDrawDisabled
+	}
+	if quota.Rest <= 0 {
+		return nil, ErrPaidDrawExhausted
+	}
+
+	// 幂等键按扣费笔数递增，并发的请求会得到同一个键，只有一个能扣费
+	charged, err := service.app.CountRecords(model.DbNamePoints, dbx.HashExp{
+		model.PointsFieldUserId: user.Id,
+		model.PointsFieldSource: ledger.SourceDrawFee,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 上一笔扣费仍待发放（正在扣费或结果未知）时不再扣费，结果未知的订单需要运营核对
+	pending, err := service.app.CountRecords(model.DbNamePoints, dbx.HashExp{
+		model.PointsFieldUserId: user.Id,
+		model.PointsFieldSource: ledger.SourceDrawFee,
+		model.PointsFieldStatus: model.PointStatusPending.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrPaidDrawCharging
+	}
+	fee, created, err := service.ledgerService.Create(ledger.Order{
+		Key:    ledger.DrawFeeKey(user.Id, int(charged)+1),
+		Source: ledger.SourceDrawFee,
+		UserId: user.Id,
+		Point:  -quota.Cost,
+		Memo:   fmt.Sprintf("活动《双节同庆·福签传情》付费博饼：第%d次付费博饼扣除%d积分", quota.Used+1, quota.Cost),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrPaidDrawCharging
+	}
+	if err = service.ledgerService.Distribute(fee, user.Name()); err != nil {
+		return fee, fmt.Errorf("%w: %w", ErrPaidDrawCharge, err)
+	}
+	return fee, nil
+}
+
+// Refund 博饼失败时退还扣费，每笔扣费只会退款一次
+func (service *PaidDrawService) Refund(user *model.User, fee *model.Points, reason string) {
+	refund, err := service.ledgerService.Pay(ledger.Order{
+		Key:    ledger.DrawRefundKey(fee.Key()),
+		Source: ledger.SourceDrawRefund,
+		UserId: user.Id,
+		Point:  -fee.Point(),
+		Memo:   fmt.Sprintf("【退款】活动《双节同庆·福签传情》付费博饼失败，退还%d积分：%s", -fee.Point(), reason),
+	}, user.Name())
+	if err != nil {
+		// 退款订单失败或熔断时保持待发放，可在后台重试或恢复发放
+		service.logger.Error("付费博饼退款失败",
+			slog.String("user", user.Name()),
+			slog.String("fee_id", fee.Id),
+			slog.Any("err", err))
+		return
+	}
+	service.logger.Info("付费博饼已退款",
+		slog.String("user", user.Name()),
+		slog.String("fee_id", fee.Id),
+		slog.String("refund_id", refund.Id))
+}
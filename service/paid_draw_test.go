@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/ledger"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestPaidDraw 开启付费博饼，每次花费 cost 积分
+func newTestPaidDraw(t *testing.T, app core.App, cost int) (*PaidDrawService, *ledger.Service, *testapp.Fishpi) {
+	t.Helper()
+	ledgerService, registry, fake := newTestLedger(t, app, config.Definition{Key: model.ConfigKeyPaidDraw, Default: func() any { return &PaidDrawConfig{} }})
+	patch, _ := json.Marshal(PaidDrawConfig{Enabled: true, Cost: cost})
+	if _, _, err := registry.Update(app, model.ConfigKeyPaidDraw, patch); err != nil {
+		t.Fatalf("开启付费博饼失败: %v", err)
+	}
+	return NewPaidDrawService(app, ledgerService, registry), ledgerService, fake
+}
+
+func TestPaidDrawService_Charge(t *testing.T) {
+	app := testapp.New(t)
+	service, _, fake := newTestPaidDraw(t, app, 10)
+	user := testapp.User(t, app, "1001", "alice")
+
+	quota, err := service.Quota(app, user.Id, model.MaxMooncakeGamblingTimes-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !quota.Enabled || quota.Cost != 10 || quota.Rest != 2 {
+		t.Fatalf("付费次数 = %+v", quota)
+	}
+
+	fee, err := service.Charge(user, quota)
+	if err != nil {
+		t.Fatalf("扣费失败: %v", err)
+	}
+	if fee.Status() != model.PointStatusSuccess || fee.Point() != -10 || fee.Key() != ledger.DrawFeeKey(user.Id, 1) {
+		t.Errorf("扣费订单 = %s %d %s", fee.Status(), fee.Point(), fee.Key())
+	}
+	if fake.Credited() != -10 {
+		t.Errorf("摸鱼派积分变化 %d, 期望 -10", fake.Credited())
+	}
+
+	// 付费博饼记录计入已使用次数
+	history := newHistory(t, app, user, findReward(t, app, "一秀奖励"), 1)
+	history.SetFeeId(fee.Id)
+	if err = app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+	if quota, _ = service.Quota(app, user.Id, model.MaxMooncakeGamblingTimes-2); quota.Used != 1 || quota.Rest != 1 {
+		t.Errorf("博饼后付费次数 = %+v", quota)
+	}
+
+	// 第二笔扣费使用新的幂等键
+	if fee, err = service.Charge(user, quota); err != nil || fee.Key() != ledger.DrawFeeKey(user.Id, 2) {
+		t.Fatalf("第二次扣费失败: %v", err)
+	}
+}
+
+func TestPaidDrawService_ChargeRejected(t *testing.T) {
+	app := testapp.New(t)
+	service, _, fake := newTestPaidDraw(t, app, 10)
+	user := testapp.User(t, app, "1001", "alice")
+	quota, _ := service.Quota(app, user.Id, 0)
+
+	if _, err := service.Charge(user, PaidDrawQuota{Enabled: false, Rest: 1}); !errors.Is(err, ErrPaidDrawDisabled) {
+		t.Errorf("未开启时应返回 ErrPaidDrawDisabled, 得到 %v", err)
+	}
+	if _, err := service.Charge(user, PaidDrawQuota{Enabled: true, Cost: 10, Rest: 0}); !errors.Is(err, ErrPaidDrawExhausted) {
+		t.Errorf("次数用完时应返回 ErrPaidDrawExhausted, 得到 %v", err)
+	}
+
+	// 积分不足时摸鱼派拒绝扣费，订单失败，之后可以再次扣费
+	fake.SetMode(testapp.FishpiReject)
+	fee, err := service.Charge(user, quota)
+	if !errors.Is(err, ErrPaidDrawCharge) || fee.Status() != model.PointStatusFailed {
+		t.Fatalf("拒绝扣费时应返回 ErrPaidDrawCharge, 得到 %v", err)
+	}
+	fake.SetMode(testapp.FishpiOK)
+	if fee, err = service.Charge(user, quota); err != nil || fee.Status() != model.PointStatusSuccess {
+		t.Fatalf("拒绝后再次扣费失败: %v", err)
+	}
+}
+
+func TestPaidDrawService_ChargeConflict(t *testing.T) {
+	app := testapp.New(t)
+	service, _, fake := newTestPaidDraw(t, app, 10)
+	user := testapp.User(t, app, "1001", "alice")
+	quota, _ := service.Quota(app, user.Id, 0)
+
+	// 扣费结果未知时订单保持待发放，核对前不能再次扣费
+	fake.SetMode(testapp.FishpiDrop)
+	fee, err := service.Charge(user, quota)
+	if !errors.Is(err, ErrPaidDrawCharge) || !errors.Is(err, ledger.ErrUnknownResult) {
+		t.Fatalf("扣费结果未知时应返回 ErrUnknownResult, 得到 %v", err)
+	}
+	if fee.Status() != model.PointStatusPending {
+		t.Errorf("结果未知的扣费订单状态 = %s", fee.Status())
+	}
+	fake.SetMode(testapp.FishpiOK)
+	if _, err = service.Charge(user, quota); !errors.Is(err, ErrPaidDrawCharging) {
+		t.Errorf("上一笔扣费待发放时应返回 ErrPaidDrawCharging, 得到 %v", err)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("摸鱼派请求 %d 次, 期望 1 次", len(fake.Calls()))
+	}
+
+}
+
+func TestPaidDrawService_ChargeSameKey(t *testing.T) {
+	app := testapp.New(t)
+	service, _, fake := newTestPaidDraw(t, app, 10)
+	user := testapp.User(t, app, "1001", "alice")
+	quota, _ := service.Quota(app, user.Id, 0)
+
+	// 并发的请求统计到相同的扣费笔数，后到的请求发现同一幂等键的订单已存在
+	collection, err := app.FindCollectionByNameOrId(model.DbNamePoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := model.NewPointsFromCollection(collection)
+	existing.SetKey(ledger.DrawFeeKey(user.Id, 1))
+	existing.SetUserId(user.Id)
+	existing.SetPoint(-10)
+	existing.SetStatus(model.PointStatusSuccess)
+	if err = app.Save(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = service.Charge(user, quota); !errors.Is(err, ErrPaidDrawCharging) {
+		t.Errorf("幂等键已存在时应返回 ErrPaidDrawCharging, 得到 %v", err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("幂等键已存在时不应扣费")
+	}
+}
+
+func TestPaidDrawService_Refund(t *testing.T) {
+	app := testapp.New(t)
+	service, _, fake := newTestPaidDraw(t, app, 10)
+	user := testapp.User(t, app, "1001", "alice")
+	quota, _ := service.Quota(app, user.Id, 0)
+
+	fee, err := service.Charge(user, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每笔扣费只退款一次
+	service.Refund(user, fee, "测试退款")
+	service.Refund(user, fee, "重复退款")
+
+	refunds := []*model.Points{}
+	if err = app.RecordQuery(model.DbNamePoints).Where(dbx.HashExp{model.PointsFieldSource: ledger.SourceDrawRefund}).All(&refunds); err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Point() != 10 || refunds[0].Status() != model.PointStatusSuccess {
+		t.Fatalf("退款订单 = %v", refunds)
+	}
+	if refunds[0].Key() != ledger.DrawRefundKey(fee.Key()) {
+		t.Errorf("退款幂等键 = %s", refunds[0].Key())
+	}
+	if fake.Credited() != 0 {
+		t.Errorf("扣费并退款后积分变化 %d, 期望 0", fake.Credited())
+	}
+}
@@ -0,0 +1,519 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// 发放任务
+const (
+	PayoutJobRewardReissue     = "reward_reissue"
+	PayoutJobRetryFailedPoints = "retry_failed_points"
+	PayoutJobArticleReward     = "article_reward"
+)
+
+// 文章评分奖励的积分订单备注前缀，用于判断作者是否已发放过
+const articleRewardMemoPrefix = "活动《双节同庆·福签传情》文章评分奖励："
+
+var PayoutJobs = []string{PayoutJobRewardReissue, PayoutJobRetryFailedPoints, PayoutJobArticleReward}
+
+var (
+	ErrPayoutRunning    = errors.New("已有发放任务正在执行")
+	ErrPayoutUnknownJob = errors.New("未知的发放任务")
+)
+
+// PayoutSummary 发放任务执行结果
+type PayoutSummary struct {
+	Job     string `json:"job"`
+	Total   int    `json:"total"`
+	Success int    `json:"success"`
+	Skipped int    `json:"skipped"`
+	Failed  int    `json:"failed"`
+}
+
+// PayoutService 积分补发、失败重试与文章奖励等发放任务，同一时间只允许执行一个
+type PayoutService struct {
+	app              core.App
+	ledgerService    *ledger.Service
+	inventoryService *inventory.Service
+	logger           *slog.Logger
+
+	mu      sync.Mutex
+	running string
+}
+
+func NewPayoutService(app core.App, ledgerService *ledger.Service, inventoryService *inventory.Service) *PayoutService {
+	return &PayoutService{
+		app:              app,
+		ledgerService:    ledgerService,
+		inventoryService: inventoryService,
+		logger:           app.Logger().WithGroup("payout"),
+	}
+}
+
+// Running 返回正在执行的任务，没有时为空
+func (service *PayoutService) Running() string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.running
+}
+
+// Start 在后台执行发放任务，已有任务执行时返回 ErrPayoutRunning
+func (service *PayoutService) Start(job string) error {
+	run, err := service.acquire(job)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer service.release()
+
+		if _, err := run(); err != nil {
+			service.logger.Error("发放任务执行失败", slog.String("job", job), slog.Any("err", err))
+		}
+	}()
+
+	return nil
+}
+
+// Run 同步执行发放任务，已有任务执行时返回 ErrPayoutRunning
+func (service *PayoutService) Run(job string) (*PayoutSummary, error) {
+	run, err := service.acquire(job)
+	if err != nil {
+		return nil, err
+	}
+	defer service.release()
+
+	return run()
+}
+
+func (service *PayoutService) acquire(job string) (func() (*PayoutSummary, error), error) {
+	var run func() (*PayoutSummary, error)
+	switch job {
+	case PayoutJobRewardReissue:
+		run = service.RewardReissue
+	case PayoutJobRetryFailedPoints:
+		run = service.RetryFailedPoints
+	case PayoutJobArticleReward:
+		run = service.ArticleScoreAndReward
+	default:
+		return nil, ErrPayoutUnknownJob
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if service.running != "" {
+		return nil, ErrPayoutRunning
+	}
+	service.running = job
+	return run, nil
+}
+
+func (service *PayoutService) release() {
+	service.mu.Lock()
+	service.running = ""
+	service.mu.Unlock()
+}
+
+// RewardReissue 奖励补发
+func (service *PayoutService) RewardReissue() (*PayoutSummary, error) {
+	logger := service.logger.With(slog.String("job", PayoutJobRewardReissue))
+
+	// 1. 预加载所有奖励数据到缓存
+	rewardCache := make(map[string]*model.Reward)
+	var allRewards []*model.Reward
+	if err := service.app.RecordQuery(model.DbNameRewards).All(&allRewards); err != nil {
+		logger.Error("预加载奖励数据失败", slog.Any("err", err))
+		return nil, err
+	}
+	for _, r := range allRewards {
+		rewardCache[r.Id] = r
+	}
+
+	// 2. 预加载所有奖项数据到缓存
+	awardCache := make(map[string]*model.Awards)
+	var allAwards []*model.Awards
+	if err := service.app.RecordQuery(model.DbNameAwards).All(&allAwards); err != nil {
+		logger.Error("预加载奖项数据失败", slog.Any("err", err))
+		return nil, err
+	}
+	for _, a := range allAwards {
+		awardCache[a.Id] = a
+	}
+
+	// 3. 预加载所有用户数据到缓存
+	userCache := make(map[string]*model.User)
+	var allUsers []*model.User
+	if err := service.app.RecordQuery(model.DbNameUsers).All(&allUsers); err != nil {
+		logger.Error("预加载用户数据失败", slog.Any("err", err))
+		return nil, err
+	}
+	for _, u := range allUsers {
+		userCache[u.Id] = u
+	}
+
+	// 4. 查找所有 gotReward = false 的历史记录，按创建时间升序排序（先到先得）
+	var histories []*model.Histories
+	if err := service.app.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{model.HistoriesFieldGotReward: false}).
+		AndWhere(dbx.Not(dbx.HashExp{model.HistoriesFieldRewardId: ""})).
+		OrderBy(model.HistoriesFieldCreated + " asc").
+		All(&histories); err != nil {
+		logger.Error("查找历史记录失败", slog.Any("err", err))
+		return nil, err
+	}
+
+	logger.Info("开始补发奖励", slog.Int("count", len(histories)))
+
+	successCount := 0
+	skipCount := 0
+
+	// 5. 遍历历史记录进行补发，按库存预留，已发完或已下架的奖励跳过
+	for _, history := range histories {
+		// 从缓存获取奖励信息
+		reward, exists := rewardCache[history.RewardId()]
+		if !exists {
+			logger.Warn("奖励不存在", slog.String("reward_id", history.RewardId()))
+			skipCount++
+			continue
+		}
+
+		// 检查状元级别的特殊规则：必须是 isBest 才能获得
+		if history.IsTop() && !history.IsBest() {
+			logger.Debug("状元级别奖励需要isBest才能获得",
+				slog.String("history_id", history.Id),
+				slog.Bool("is_best", history.IsBest()))
+			skipCount++
+			continue
+		}
+
+		// 预留库存并更新历史记录为已获得奖励
+		err := service.app.RunInTransaction(func(txApp core.App) error {
+			if _, err := service.inventoryService.Reserve(txApp, reward.Id, history.Id); err != nil {
+				return err
+			}
+			history.SetGotReward(true)
+			return txApp.Save(history)
+		})
+		if errors.Is(err, inventory.ErrOutOfStock) || errors.Is(err, inventory.ErrRetired) {
+			history.SetGotReward(false)
+			logger.Debug("奖励已发完或已下架",
+				slog.String("reward_id", reward.Id),
+				slog.String("reward_name", reward.Name()),
+				slog.Any("err", err))
+			skipCount++
+			continue
+		}
+		if err != nil {
+			history.SetGotReward(false)
+			logger.Error("更新历史记录失败", slog.String("history_id", history.Id), slog.Any("err", err))
+			skipCount++
+			continue
+		}
+
+		// 如果有积分奖励，创建积分订单并发放，其他奖品由用户领取
+		if reward.Kind() == model.RewardKindPoints && reward.Point() > 0 {
+			// 从缓存获取奖项名称
+			awardName := ""
+			if history.AwardId() != "" {
+				if award, exists := awardCache[history.AwardId()]; exists {
+					awardName = award.Name()
+				}
+			}
+
+			// 从缓存获取用户信息
+			user, exists := userCache[history.UserId()]
+			if !exists {
+				logger.Error("用户不存在", slog.String("user_id", history.UserId()))
+				continue
+			}
+
+			// 创建积分订单并发放，已有订单时不重复发放
+			_, err := service.ledgerService.Pay(ledger.Order{
+				Key:       ledger.HistoryKey(history.Id),
+				Source:    ledger.SourceReissue,
+				UserId:    user.Id,
+				HistoryId: history.Id,
+				Point:     reward.Point(),
+				Memo: fmt.Sprintf("【补发】活动《双节同庆·福签传情》第%d次博饼：%s(%s)",
+					history.Times(), awardName, reward.Name()),
+			}, user.Name())
+			if err != nil {
+				logger.Error("发放积分失败",
+					slog.String("user", user.Name()),
+					slog.Int("point", reward.Point()),
+					slog.Any("err", err))
+			} else {
+				successCount++
+				logger.Info("补发积分成功",
+					slog.String("user", user.Name()),
+					slog.String("reward", reward.Name()),
+					slog.Int("point", reward.Point()),
+					slog.Int("times", history.Times()))
+			}
+		} else {
+			// 没有积分奖励，也算作成功处理
+			successCount++
+		}
+	}
+
+	logger.Info("奖励补发完成",
+		slog.Int("total", len(histories)),
+		slog.Int("success", successCount),
+		slog.Int("skip", skipCount))
+	return &PayoutSummary{Job: PayoutJobRewardReissue, Total: len(histories), Success: successCount, Skipped: skipCount}, nil
+}
+
+// RetryFailedPoints 重新发放失败的积分订单
+func (service *PayoutService) RetryFailedPoints() (*PayoutSummary, error) {
+	logger := service.logger.With(slog.String("job", PayoutJobRetryFailedPoints))
+
+	// 预加载所有用户数据到缓存
+	userCache := make(map[string]*model.User)
+	var allUsers []*model.User
+	if err := service.app.RecordQuery(model.DbNameUsers).All(&allUsers); err != nil {
+		logger.Error("预加载用户数据失败", slog.Any("err", err))
+		return nil, err
+	}
+	for _, u := range allUsers {
+		userCache[u.Id] = u
+	}
+
+	// 查找所有失败的积分订单，付费博饼的扣费订单不重试
+	var failedPoints []*model.Points
+	if err := service.app.RecordQuery(model.DbNamePoints).
+		Where(dbx.HashExp{model.PointsFieldStatus: model.PointStatusFailed}).
+		AndWhere(dbx.NewExp("[[" + model.PointsFieldPoint + "]] > 0")).
+		OrderBy(model.PointsFieldCreated + " asc").
+		All(&failedPoints); err != nil {
+		logger.Error("查找失败的积分订单失败", slog.Any("err", err))
+		return nil, err
+	}
+
+	logger.Info("开始重新发放失败的积分订单", slog.Int("count", len(failedPoints)))
+
+	successCount := 0
+	failCount := 0
+
+	for i, pointsRecord := range failedPoints {
+		// 从缓存获取用户信息
+		user, exists := userCache[pointsRecord.UserId()]
+		if !exists {
+			logger.Warn("用户不存在，跳过",
+				slog.String("user_id", pointsRecord.UserId()),
+				slog.String("points_id", pointsRecord.Id))
+			failCount++
+			continue
+		}
+
+		// 重新尝试发放积分，重新检查来源预算
+		if err := service.ledgerService.Retry(pointsRecord, user.Name()); err != nil {
+			// 发放失败
+			logger.Error("重新发放积分失败",
+				slog.String("user", user.Name()),
+				slog.Int("point", pointsRecord.Point()),
+				slog.String("points_id", pointsRecord.Id),
+				slog.Any("err", err))
+			failCount++
+		} else {
+			// 发放成功
+			successCount++
+			logger.Info("重新发放积分成功",
+				slog.String("user", user.Name()),
+				slog.Int("point", pointsRecord.Point()),
+				slog.String("points_id", pointsRecord.Id))
+		}
+
+		// 每处理一条记录后延迟一段时间，避免请求过快
+		// 每10条延迟1秒，避免API限流
+		if (i+1)%10 == 0 {
+			logger.Info("已处理10条记录，等待1秒",
+				slog.Int("processed", i+1),
+				slog.Int("total", len(failedPoints)))
+			time.Sleep(1 * time.Second)
+		} else {
+			// 其他情况延迟100ms
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	logger.Info("失败积分订单重新发放完成",
+		slog.Int("total", len(failedPoints)),
+		slog.Int("success", successCount),
+		slog.Int("failed", failCount))
+
+	return &PayoutSummary{Job: PayoutJobRetryFailedPoints, Total: len(failedPoints), Success: successCount, Failed: failCount}, nil
+}
+
+// ArticleScoreAndReward 文章评分和奖励发放（被取消资格的文章不参与）
+func (service *PayoutService) ArticleScoreAndReward() (*PayoutSummary, error) {
+	logger := service.logger.With(slog.String("job", PayoutJobArticleReward))
+
+	// 1. 获取所有文章
+	var articles []*model.Article
+	if err := service.app.RecordQuery(model.DbNameArticles).
+		Where(dbx.Not(dbx.HashExp{model.CommonFieldId: "4tmbyzsuhbd66yr"})).
+		AndWhere(dbx.HashExp{model.ArticlesFieldDisqualified: false}).
+		All(&articles); err != nil {
+		logger.Error("查询文章失败", slog.Any("err", err))
+		return nil, err
+	}
+
+	logger.Info("开始计算文章评分", slog.Int("total", len(articles)))
+
+	// 2. 计算并更新每篇文章的评分
+	type ArticleScore struct {
+		Article *model.Article
+		Score   float64
+	}
+
+	articleScores := make([]ArticleScore, 0, len(articles))
+
+	for _, article := range articles {
+		score := article.CalculateScore()
+		article.SetScore(score)
+
+		if err := service.app.Save(article); err != nil {
+			logger.Error("更新文章评分失败",
+				slog.String("article_id", article.Id),
+				slog.String("title", article.Title()),
+				slog.Any("err", err))
+			continue
+		}
+
+		articleScores = append(articleScores, ArticleScore{
+			Article: article,
+			Score:   score,
+		})
+
+		logger.Debug("文章评分计算完成",
+			slog.String("title", article.Title()),
+			slog.Float64("score", score),
+			slog.Int("viewCount", article.ViewCount()),
+			slog.Int("goodCnt", article.GoodCnt()),
+			slog.Int("collectCnt", article.CollectCnt()),
+			slog.Int("commentCount", article.CommentCount()),
+			slog.Int("thankCnt", article.ThankCnt()))
+	}
+
+	// 3. 按评分排序（从高到低）
+	for i := 0; i < len(articleScores); i++ {
+		for j := i + 1; j < len(articleScores); j++ {
+			if articleScores[j].Score > articleScores[i].Score {
+				articleScores[i], articleScores[j] = articleScores[j], articleScores[i]
+			}
+		}
+	}
+
+	logger.Info("文章评分排序完成", slog.Int("count", len(articleScores)))
+
+	// 4. 预加载用户缓存
+	userCache := make(map[string]*model.User)
+	var allUsers []*model.User
+	if err := service.app.RecordQuery(model.DbNameUsers).All(&allUsers); err != nil {
+		logger.Error("预加载用户数据失败", slog.Any("err", err))
+		return nil, err
+	}
+	for _, u := range allUsers {
+		userCache[u.Id] = u
+	}
+
+	// 5. 根据排名发放积分
+	successCount := 0
+	failCount := 0
+
+	for rank, as := range articleScores {
+		ranking := rank + 1
+		var points int
+		var rankName string
+
+		// 根据排名确定积分
+		if ranking == 1 {
+			points = 1024
+			rankName = "第一名"
+		} else if ranking >= 2 && ranking <= 3 {
+			points = 512
+			rankName = fmt.Sprintf("第%d名", ranking)
+		} else if ranking >= 4 && ranking <= 10 {
+			points = 256
+			rankName = fmt.Sprintf("第%d名", ranking)
+		} else {
+			points = 128
+			rankName = "参与奖"
+		}
+
+		// 获取文章作者
+		user, exists := userCache[as.Article.UserId()]
+		if !exists {
+			logger.Warn("文章作者不存在，跳过",
+				slog.String("article_id", as.Article.Id),
+				slog.String("user_id", as.Article.UserId()))
+			failCount++
+			continue
+		}
+
+		// 已发放过的作者跳过，避免重复执行时重复发放
+		paid, err := service.app.CountRecords(model.DbNamePoints,
+			dbx.HashExp{model.PointsFieldUserId: user.Id},
+			dbx.Like(model.PointsFieldMemo, articleRewardMemoPrefix).Match(false, true))
+		if err != nil {
+			logger.Error("查询积分订单失败", slog.String("user", user.Name()), slog.Any("err", err))
+			failCount++
+			continue
+		}
+		if paid > 0 {
+			logger.Debug("文章评分奖励已发放，跳过", slog.String("user", user.Name()))
+			continue
+		}
+
+		// 创建积分订单并发放
+		_, err = service.ledgerService.Pay(ledger.Order{
+			Key:    ledger.ArticleRewardKey(user.Id),
+			Source: ledger.SourceArticleReward,
+			UserId: user.Id,
+			Point:  points,
+			Memo:   fmt.Sprintf(articleRewardMemoPrefix+"%s（评分：%.2f）", rankName, as.Score),
+		}, user.Name())
+		if err != nil {
+			logger.Error("发放积分失败",
+				slog.String("user", user.Name()),
+				slog.Int("point", points),
+				slog.Any("err", err))
+			failCount++
+		} else {
+			successCount++
+			logger.Info("发放积分成功",
+				slog.Int("ranking", ranking),
+				slog.String("user", user.Name()),
+				slog.String("article", as.Article.Title()),
+				slog.Float64("score", as.Score),
+				slog.Int("point", points))
+		}
+
+		// 延迟，避免请求过快
+		if ranking%10 == 0 {
+			logger.Info("已处理10条记录，等待1秒",
+				slog.Int("processed", ranking),
+				slog.Int("total", len(articleScores)))
+			time.Sleep(1 * time.Second)
+		} else {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	logger.Info("文章评分奖励发放完成",
+		slog.Int("total", len(articleScores)),
+		slog.Int("success", successCount),
+		slog.Int("failed", failCount))
+
+	return &PayoutSummary{Job: PayoutJobArticleReward, Total: len(articleScores), Success: successCount, Failed: failCount}, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestLedger 使用模拟摸鱼派接口的积分账本，extra 为需要额外注册的配置项
+func newTestLedger(t *testing.T, app core.App, extra ...config.Definition) (*ledger.Service, *config.Registry, *testapp.Fishpi) {
+	t.Helper()
+	fake := testapp.NewFishpi(t)
+	definitions := append([]config.Definition{
+		testapp.FishpiDefinition(),
+		{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+	}, extra...)
+	registry := testapp.Registry(t, app, definitions...)
+	return ledger.NewService(app, fake.Service(t, app, registry), registry), registry, fake
+}
+
+// findReward 按名称查找迁移预置的奖励
+func findReward(t *testing.T, app core.App, name string) *model.Reward {
+	t.Helper()
+	reward := new(model.Reward)
+	if err := app.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.RewardsFieldName: name}).One(reward); err != nil {
+		t.Fatalf("查找奖励 %s 失败: %v", name, err)
+	}
+	return reward
+}
+
+// newHistory 创建未发放奖励的博饼记录
+func newHistory(t *testing.T, app core.App, user *model.User, reward *model.Reward, times int) *model.Histories {
+	t.Helper()
+	award := new(model.Awards)
+	if err := app.RecordQuery(model.DbNameAwards).Where(dbx.HashExp{model.AwardsFieldRewardId: reward.Id}).One(award); err != nil {
+		t.Fatalf("查找奖项失败: %v", err)
+	}
+	collection, err := app.FindCollectionByNameOrId(model.DbNameHistories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := model.NewHistoriesFromCollection(collection)
+	history.SetUserId(user.Id)
+	history.SetTimes(times)
+	history.SetAwardId(award.Id)
+	history.SetRewardId(reward.Id)
+	history.SetDetails([6]int{1, 2, 3, 4, 5, 6})
+	if err = app.Save(history); err != nil {
+		t.Fatalf("创建博饼记录失败: %v", err)
+	}
+	return history
+}
+
+func TestPayoutService_RewardReissue(t *testing.T) {
+	app := testapp.New(t)
+	ledgerService, _, fake := newTestLedger(t, app)
+	service := NewPayoutService(app, ledgerService, inventory.NewService(app))
+
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+	paid := newHistory(t, app, alice, findReward(t, app, "一秀奖励"), 1)
+	// 状元级别需要 isBest 才能获得
+	top := newHistory(t, app, bob, findReward(t, app, "状元奖励"), 1)
+	top.SetIsTop(true)
+	if err := app.Save(top); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := service.Run(PayoutJobRewardReissue)
+	if err != nil {
+		t.Fatalf("补发失败: %v", err)
+	}
+	if summary.Total != 2 || summary.Success != 1 || summary.Skipped != 1 {
+		t.Errorf("补发结果 %+v", summary)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].UserName != "alice" || calls[0].Point != 8 {
+		t.Errorf("期望向 alice 发放 8 积分, 实际 %+v", calls)
+	}
+	reloaded, err := app.FindRecordById(model.DbNameHistories, paid.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !model.NewHistories(reloaded).GotReward() {
+		t.Error("补发后应标记为已获得奖励")
+	}
+
+	// 再次执行不重复发放
+	if _, err = service.Run(PayoutJobRewardReissue); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Errorf("重复执行不应再次发放, 实际 %d 次", len(calls))
+	}
+
+	if _, err = service.Run("unknown"); !errors.Is(err, ErrPayoutUnknownJob) {
+		t.Errorf("未知任务应返回 ErrPayoutUnknownJob, 得到 %v", err)
+	}
+}
+
+func TestPayoutService_ArticleScoreAndReward(t *testing.T) {
+	app := testapp.New(t)
+	ledgerService, _, fake := newTestLedger(t, app)
+	service := NewPayoutService(app, ledgerService, inventory.NewService(app))
+
+	// 评分依次降低，被取消资格的文章不参与
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		user := testapp.User(t, app, name, name)
+		article := testapp.Article(t, app, user, name)
+		article.SetViewCount(1000 - i*100)
+		article.SetGoodCnt(100 - i*10)
+		article.SetDisqualified(name == "dave")
+		if err := app.Save(article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := service.Run(PayoutJobArticleReward)
+	if err != nil {
+		t.Fatalf("发放文章奖励失败: %v", err)
+	}
+	if summary.Total != 3 || summary.Success != 3 {
+		t.Errorf("发放结果 %+v", summary)
+	}
+	expected := map[string]int{"alice": 1024, "bob": 512, "carol": 512}
+	calls := fake.Calls()
+	if len(calls) != len(expected) {
+		t.Fatalf("期望发放 %d 次, 实际 %+v", len(expected), calls)
+	}
+	for _, call := range calls {
+		if expected[call.UserName] != call.Point {
+			t.Errorf("%s 期望 %d 积分, 实际 %d", call.UserName, expected[call.UserName], call.Point)
+		}
+	}
+
+	// 再次执行时已发放的作者跳过
+	if summary, err = service.Run(PayoutJobArticleReward); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Success != 0 || len(fake.Calls()) != len(expected) {
+		t.Errorf("重复执行不应再次发放, 结果 %+v, 请求 %d 次", summary, len(fake.Calls()))
+	}
+}
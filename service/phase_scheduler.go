@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const phaseSchedulerJobId = "activity_phase"
+
+// PhaseHookFunc 阶段切换时执行的任务，可能因失败被重复执行，需保证幂等
+type PhaseHookFunc func() error
+
+type phaseHook struct {
+	name string
+	fn   PhaseHookFunc
+}
+
+// PhaseScheduler 每分钟检查活动日程，阶段开始后按注册顺序执行该阶段的任务
+// 执行结果按阶段、任务与阶段开始时间记录在 phase_hooks 中，成功的任务不会重复执行，失败的任务在下次检查时重试
+// 重新设置日程后阶段开始时间变化，视为新的一轮活动，任务会再次执行
+type PhaseScheduler struct {
+	app             core.App
+	activityService *ActivityService
+	logger          *slog.Logger
+
+	hooks   map[model.ActivityPhase][]phaseHook
+	running sync.Mutex
+}
+
+func NewPhaseScheduler(app core.App, activityService *ActivityService) *PhaseScheduler {
+	return &PhaseScheduler{
+		app:             app,
+		activityService: activityService,
+		logger:          app.Logger().WithGroup("phase"),
+		hooks:           map[model.ActivityPhase][]phaseHook{},
+	}
+}
+
+// Register 注册阶段开始时执行的任务
+func (scheduler *PhaseScheduler) Register(phase model.ActivityPhase, name string, fn PhaseHookFunc) {
+	scheduler.hooks[phase] = append(scheduler.hooks[phase], phaseHook{name: name, fn: fn})
+}
+
+// Start 注册定时检查
+func (scheduler *PhaseScheduler) Start() {
+	scheduler.app.Cron().MustAdd(phaseSchedulerJobId, "* * * * *", func() {
+		// 上一次检查的任务还没执行完时跳过
+		if !scheduler.running.TryLock() {
+			return
+		}
+		defer scheduler.running.Unlock()
+
+		if err := scheduler.Run(time.Now()); err != nil {
+			scheduler.logger.Error("执行阶段任务失败", slog.Any("err", err))
+		}
+	})
+}
+
+// Run 执行截至 now 已开始的阶段中尚未成功的任务，某个任务失败时停止，后续任务等待下次检查
+func (scheduler *PhaseScheduler) Run(now time.Time) error {
+	schedule, err := scheduler.activityService.Schedule()
+	if err != nil {
+		return err
+	}
+
+	for _, phase := range schedule.Started(now) {
+		for _, hook := range scheduler.hooks[phase.Name] {
+			record, err := scheduler.findHook(phase, hook.name)
+			if err != nil {
+				return err
+			}
+			if !record.IsNew() && record.Status() == model.PhaseHookStatusSuccess {
+				continue
+			}
+
+			logger := scheduler.logger.With(
+				slog.String("phase", phase.Name.String()),
+				slog.String("hook", hook.name),
+			)
+			logger.Info("开始执行阶段任务")
+
+			runErr := hook.fn()
+			record.SetRunAt(types.NowDateTime())
+			if runErr != nil {
+				record.SetStatus(model.PhaseHookStatusFailed)
+				record.SetError(runErr.Error())
+			} else {
+				record.SetStatus(model.PhaseHookStatusSuccess)
+				record.SetError("")
+			}
+			if err = scheduler.app.Save(record); err != nil {
+				logger.Error("保存阶段任务状态失败", slog.Any("err", err))
+				return err
+			}
+
+			if runErr != nil {
+				logger.Error("阶段任务执行失败", slog.Any("err", runErr))
+				return runErr
+			}
+			logger.Info("阶段任务执行完成")
+		}
+	}
+
+	return nil
+}
+
+// findHook 查找任务在该阶段开始时间下的执行记录，不存在时返回未保存的新记录
+func (scheduler *PhaseScheduler) findHook(phase Phase, name string) (*model.PhaseHook, error) {
+	startAt, err := types.ParseDateTime(phase.StartAt)
+	if err != nil {
+		return nil, err
+	}
+
+	record := new(model.PhaseHook)
+	err = scheduler.app.RecordQuery(model.DbNamePhaseHooks).
+		Where(dbx.HashExp{
+			model.PhaseHooksFieldPhase:   phase.Name,
+			model.PhaseHooksFieldHook:    name,
+			model.PhaseHooksFieldStartAt: startAt.String(),
+		}).
+		One(record)
+	if err == nil {
+		return record, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	collection, err := scheduler.app.FindCollectionByNameOrId(model.DbNamePhaseHooks)
+	if err != nil {
+		return nil, err
+	}
+	record = model.NewPhaseHookFromCollection(collection)
+	record.SetPhase(phase.Name)
+	record.SetHook(name)
+	record.SetStartAt(startAt)
+	return record, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestScheduler 使用 testSchedule 日程的阶段调度器
+func newTestScheduler(t *testing.T, app core.App) (*PhaseScheduler, *ActivityService) {
+	t.Helper()
+	registry := testapp.Registry(t, app, config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+		schedule := DefaultSchedule()
+		return &schedule
+	}})
+	activityService := NewActivityService(app, registry)
+	if _, err := activityService.SetSchedule(app, testSchedule()); err != nil {
+		t.Fatalf("保存日程失败: %v", err)
+	}
+	return NewPhaseScheduler(app, activityService), activityService
+}
+
+// hookStatus 任务每轮执行记录的状态，按阶段开始时间排序
+func hookStatus(t *testing.T, app core.App, name string) []model.PhaseHookStatus {
+	t.Helper()
+	records := []*model.PhaseHook{}
+	if err := app.RecordQuery(model.DbNamePhaseHooks).
+		Where(dbx.HashExp{model.PhaseHooksFieldHook: name}).
+		OrderBy(model.PhaseHooksFieldStartAt + " asc").
+		All(&records); err != nil {
+		t.Fatal(err)
+	}
+	statuses := []model.PhaseHookStatus{}
+	for _, record := range records {
+		statuses = append(statuses, record.Status())
+	}
+	return statuses
+}
+
+func TestPhaseScheduler_Run(t *testing.T) {
+	app := testapp.New(t)
+	scheduler, _ := newTestScheduler(t, app)
+
+	calls := map[string]int{}
+	errFirst := errors.New("第一次执行失败")
+	scheduler.Register(model.ActivityPhaseSettlement, "freeze", func() error {
+		calls["freeze"]++
+		return nil
+	})
+	scheduler.Register(model.ActivityPhaseSettlement, "settle", func() error {
+		calls["settle"]++
+		if calls["settle"] == 1 {
+			return errFirst
+		}
+		return nil
+	})
+	scheduler.Register(model.ActivityPhaseSettlement, "pay", func() error {
+		calls["pay"]++
+		return nil
+	})
+	scheduler.Register(model.ActivityPhaseArchive, "snapshot", func() error {
+		calls["snapshot"]++
+		return nil
+	})
+
+	// 阶段开始前不执行
+	if err := scheduler.Run(time.Date(2025, 10, 11, 0, 0, 0, 0, time.UTC)); err != nil || len(calls) != 0 {
+		t.Fatalf("结算阶段开始前执行了任务: %v, %v", calls, err)
+	}
+
+	// 某个任务失败时停止，后续任务等待下次检查
+	settlement := time.Date(2025, 10, 12, 1, 0, 0, 0, time.UTC)
+	if err := scheduler.Run(settlement); !errors.Is(err, errFirst) {
+		t.Fatalf("任务失败时应返回错误, 得到 %v", err)
+	}
+	if calls["freeze"] != 1 || calls["settle"] != 1 || calls["pay"] != 0 {
+		t.Fatalf("第一次检查 = %v", calls)
+	}
+	if got := hookStatus(t, app, "settle"); len(got) != 1 || got[0] != model.PhaseHookStatusFailed {
+		t.Errorf("失败的任务状态 = %v", got)
+	}
+
+	// 下次检查跳过已成功的任务，重试失败的任务
+	if err := scheduler.Run(settlement.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if calls["freeze"] != 1 || calls["settle"] != 2 || calls["pay"] != 1 {
+		t.Fatalf("第二次检查 = %v", calls)
+	}
+	if got := hookStatus(t, app, "settle"); len(got) != 1 || got[0] != model.PhaseHookStatusSuccess {
+		t.Errorf("重试成功后状态 = %v", got)
+	}
+
+	// 全部成功后不再执行，之后开始的阶段按时执行
+	if err := scheduler.Run(time.Date(2025, 10, 14, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if calls["freeze"] != 1 || calls["settle"] != 2 || calls["pay"] != 1 || calls["snapshot"] != 1 {
+		t.Errorf("第三次检查 = %v", calls)
+	}
+}
+
+func TestPhaseScheduler_RunRescheduled(t *testing.T) {
+	app := testapp.New(t)
+	scheduler, activityService := newTestScheduler(t, app)
+
+	calls := 0
+	scheduler.Register(model.ActivityPhaseSettlement, "settle", func() error {
+		calls++
+		return nil
+	})
+	if err := scheduler.Run(time.Date(2025, 10, 12, 1, 0, 0, 0, time.UTC)); err != nil || calls != 1 {
+		t.Fatalf("第一轮结算 = %d, %v", calls, err)
+	}
+
+	// 重新设置日程后再次进入结算阶段，视为新的一轮活动
+	schedule := testSchedule()
+	for i := range schedule.Phases {
+		schedule.Phases[i].StartAt = schedule.Phases[i].StartAt.AddDate(1, 0, 0)
+		if !schedule.Phases[i].EndAt.IsZero() {
+			schedule.Phases[i].EndAt = schedule.Phases[i].EndAt.AddDate(1, 0, 0)
+		}
+	}
+	schedule.Phases[0].StartAt = time.Time{}
+	if _, err := activityService.SetSchedule(app, schedule); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Run(time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)); err != nil || calls != 1 {
+		t.Fatalf("新一轮结算开始前 = %d, %v", calls, err)
+	}
+	if err := scheduler.Run(time.Date(2026, 10, 12, 1, 0, 0, 0, time.UTC)); err != nil || calls != 2 {
+		t.Fatalf("新一轮结算 = %d, %v", calls, err)
+	}
+	if got := hookStatus(t, app, "settle"); len(got) != 2 {
+		t.Errorf("每轮活动各一条执行记录, 得到 %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package quota
+
+import (
+	"bless-activity/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Config 博饼次数：基础次数、文章感谢、收到福签合计不超过 Cap，每日签到与管理员补发不受上限限制
+type Config struct {
+	Base         int `json:"base"`           // 发布活动文章后的基础次数
+	Cap          int `json:"cap"`            // 基础次数、文章感谢、收到福签合计的上限
+	VotesPerDraw int `json:"votes_per_draw"` // 每收到几张福签增加一次，0 表示不计入
+	Checkin      int `json:"checkin"`        // 每日签到赠送的次数，仅当天有效，0 表示不开放签到
+}
+
+// DefaultConfig 默认配置，与签到、福签上线前的规则一致
+func DefaultConfig() *Config {
+	return &Config{
+		Base: model.DefaultMooncakeGamblingTimes,
+		Cap:  model.MaxMooncakeGamblingTimes,
+	}
+}
+
+func (config *Config) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.Base, validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.Cap, validation.Required.Error("至少为1"), validation.Min(1).Error("至少为1")),
+		validation.Field(&config.VotesPerDraw, validation.Min(0).Error("不能为负数")),
+		validation.Field(&config.Checkin, validation.Min(0).Error("不能为负数"), validation.Max(100).Error("不能超过100")),
+	)
+}
@@ -0,0 +1,299 @@
+package quota
+
+import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var (
+	ErrCheckinClosed = errors.New("未开放签到")
+	ErrCheckedIn     = errors.New("今天已经签到过了")
+	ErrExhausted     = errors.New("博饼次数已用完")
+)
+
+// Quota 用户的博饼次数与各来源的明细
+type Quota struct {
+	HasArticle bool                  `json:"has_article"`
+	Base       int                   `json:"base"` // 配置的基础次数
+	Cap        int                   `json:"cap"`  // 计入上限的来源合计的上限
+	Items      []Item                `json:"items"`
+	Capped     int                   `json:"capped"`   // 超出上限未计入的次数
+	Total      int                   `json:"total"`    // 免费次数合计，每日重置的来源只计当天
+	Used       int                   `json:"used"`     // 已使用的免费次数，每日重置的来源只计当天
+	Rest       int                   `json:"rest"`     // 剩余的免费次数
+	Draws      int                   `json:"draws"`    // 已博饼的次数，含付费博饼与往日签到的次数
+	ResetAt    types.DateTime        `json:"reset_at"` // 每日重置的次数下次清零的时间
+	Paid       service.PaidDrawQuota `json:"paid"`     // 免费次数用完后可购买的次数
+
+	article *model.Article
+	next    model.QuotaSource
+}
+
+// Article 用户最新的活动文章，没有时为 nil
+func (quota *Quota) Article() *model.Article {
+	return quota.article
+}
+
+// Next 下一次免费博饼消耗的每日重置的来源，优先消耗当天会清零的次数，为空表示消耗长期次数
+func (quota *Quota) Next() model.QuotaSource {
+	return quota.next
+}
+
+// usage 已使用的免费次数
+type usage struct {
+	permanent int                       // 消耗长期次数的博饼
+	daily     map[model.QuotaSource]int // 当天消耗每日重置的来源的博饼
+}
+
+// tally 汇总各来源的次数：计入上限的来源合计不超过 limit，再加上不受限制的来源
+func tally(items []Item, limit int, used usage) *Quota {
+	quota := &Quota{Cap: limit, Items: items}
+
+	capped, permanent := 0, 0
+	for _, item := range items {
+		switch {
+		case item.Daily:
+		case item.Capped:
+			capped += item.Times
+		default:
+			permanent += item.Times
+		}
+	}
+	if capped > limit {
+		quota.Capped = capped - limit
+		capped = limit
+	}
+	permanent = max(0, capped+permanent)
+	quota.Total, quota.Used = permanent, used.permanent
+	quota.Rest = max(0, permanent-used.permanent)
+
+	for i := range quota.Items {
+		item := &quota.Items[i]
+		if !item.Daily {
+			continue
+		}
+		item.Used = used.daily[item.Source]
+		rest := max(0, item.Times-item.Used)
+		quota.Total += item.Times
+		quota.Used += item.Used
+		quota.Rest += rest
+		if rest > 0 && quota.next == "" {
+			quota.next = item.Source
+		}
+	}
+	return quota
+}
+
+// Service 博饼次数：汇总各来源的免费次数，免费次数用完后由付费博饼补充
+type Service struct {
+	app             core.App
+	registry        *config.Registry
+	paidDrawService *service.PaidDrawService
+	sources         []Source
+}
+
+func NewService(app core.App, registry *config.Registry, thankService *service.ThankService, paidDrawService *service.PaidDrawService) *Service {
+	quotaService := &Service{
+		app:             app,
+		registry:        registry,
+		paidDrawService: paidDrawService,
+	}
+	quotaService.Register(baseSource{}, thanksSource{thankService: thankService}, votesSource{}, checkinSource{}, grantSource{})
+	return quotaService
+}
+
+// Register 添加次数来源，按添加顺序展示，每日重置的来源按添加顺序消耗
+func (service *Service) Register(sources ...Source) {
+	service.sources = append(service.sources, sources...)
+}
+
+// Get 用户当前的博饼次数，没有活动文章时只展示明细，不能博饼
+// 博饼时在保存记录的事务中重新查询，并发博饼不会超出次数
+func (service *Service) Get(txApp core.App, user *model.User) (*Quota, error) {
+	value, err := config.Get[Config](service.registry, model.ConfigKeyQuota)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &Context{App: txApp, Config: value, User: user, Now: time.Now()}
+	article := new(model.Article)
+	if err = txApp.RecordQuery(model.DbNameArticles).
+		Where(dbx.HashExp{model.ArticlesFieldUserId: user.Id}).
+		OrderBy(model.ArticlesFieldCreatedAt + " desc").
+		One(article); err == nil {
+		ctx.Article = article
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(service.sources))
+	daily := map[model.QuotaSource]bool{}
+	for _, source := range service.sources {
+		item, err := source.Item(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		daily[item.Source] = item.Daily
+	}
+
+	used, draws, err := service.usage(txApp, user.Id, startOfDay(ctx.Now), daily)
+	if err != nil {
+		return nil, err
+	}
+	quota := tally(items, value.Cap, used)
+	quota.HasArticle, quota.article = ctx.Article != nil, ctx.Article
+	quota.Base = value.Base
+	quota.ResetAt, _ = types.ParseDateTime(startOfDay(ctx.Now).AddDate(0, 0, 1))
+
+	if quota.Paid, err = service.paidDrawService.Quota(txApp, user.Id, quota.Total); err != nil {
+		return nil, err
+	}
+	quota.Draws = draws + quota.Paid.Used
+
+	// 自行注册的用户可能没有活动文章，此时只能赠送福签，不能博饼
+	if !quota.HasArticle {
+		quota.Rest, quota.Paid.Rest, quota.next = 0, 0, ""
+	}
+	return quota, nil
+}
+
+// usage 统计已使用的免费次数，每日重置的来源只统计当天，返回值 draws 为全部免费博饼的次数
+func (service *Service) usage(txApp core.App, userId string, today time.Time, daily map[model.QuotaSource]bool) (usage, int, error) {
+	since, _ := types.ParseDateTime(today)
+	var rows []struct {
+		Source string `db:"source"`
+		Today  int    `db:"today"`
+		Total  int    `db:"total"`
+	}
+	if err := txApp.DB().
+		Select(
+			"[["+model.HistoriesFieldQuotaSource+"]] AS source",
+			"COALESCE(SUM([["+model.HistoriesFieldCreated+"]] >= {:since}), 0) AS today",
+			"COUNT(*) AS total",
+		).
+		From(model.DbNameHistories).
+		Where(dbx.HashExp{model.HistoriesFieldUserId: userId, model.HistoriesFieldFeeId: ""}).
+		GroupBy(model.HistoriesFieldQuotaSource).
+		Bind(dbx.Params{"since": since.String()}).
+		All(&rows); err != nil {
+		return usage{}, 0, err
+	}
+
+	used := usage{daily: map[model.QuotaSource]int{}}
+	draws := 0
+	for _, row := range rows {
+		draws += row.Total
+		if source := model.QuotaSource(row.Source); daily[source] {
+			used.daily[source] = row.Today
+		} else {
+			used.permanent += row.Total
+		}
+	}
+	return used, draws, nil
+}
+
+// Checkin 每日签到，获得的次数当天有效
+func (service *Service) Checkin(user *model.User) (*model.Checkin, error) {
+	value, err := config.Get[Config](service.registry, model.ConfigKeyQuota)
+	if err != nil {
+		return nil, err
+	}
+	if value.Checkin <= 0 {
+		return nil, ErrCheckinClosed
+	}
+
+	day := time.Now().Format(time.DateOnly)
+	exists := dbx.HashExp{model.CheckinsFieldUserId: user.Id, model.CheckinsFieldDay: day}
+	count, err := service.app.CountRecords(model.DbNameCheckins, exists)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrCheckedIn
+	}
+
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameCheckins)
+	if err != nil {
+		return nil, err
+	}
+	checkin := model.NewCheckinFromCollection(collection)
+	checkin.SetUserId(user.Id)
+	checkin.SetDay(day)
+	checkin.SetTimes(value.Checkin)
+	if err = service.app.Save(checkin); err != nil {
+		// 并发签到时唯一索引冲突
+		if count, _ = service.app.CountRecords(model.DbNameCheckins, exists); count > 0 {
+			return nil, ErrCheckedIn
+		}
+		return nil, err
+	}
+	return checkin, nil
+}
+
+// Grant 管理员补发博饼次数，times 为负数时扣回，用户不存在时返回 sql.ErrNoRows
+func (service *Service) Grant(txApp core.App, actor *model.User, userId string, times int, reason string) (*model.DrawGrant, error) {
+	reason = strings.TrimSpace(reason)
+	if err := (validation.Errors{
+		"user_id": validation.Validate(userId, validation.Required.Error("不能为空")),
+		"times":   validation.Validate(times, validation.Required.Error("不能为0"), validation.Min(-100).Error("不能小于-100"), validation.Max(100).Error("不能超过100")),
+		"reason":  validation.Validate(reason, validation.Required.Error("请填写补发原因")),
+	}).Filter(); err != nil {
+		return nil, err
+	}
+	if _, err := txApp.FindRecordById(model.DbNameUsers, userId); err != nil {
+		return nil, err
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameDrawGrants)
+	if err != nil {
+		return nil, err
+	}
+	grant := model.NewDrawGrantFromCollection(collection)
+	grant.SetUserId(userId)
+	grant.SetActorId(actor.Id)
+	grant.SetTimes(times)
+	grant.SetReason(reason)
+	if err = txApp.Save(grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Grants 分页查询补发记录，userId 为空时查询全部，按补发时间倒序
+func (service *Service) Grants(userId string, page int, perPage int) ([]*model.DrawGrant, int, error) {
+	// 空的 HashExp 在 CountRecords 中会生成 WHERE ()，没有过滤条件时不传
+	filters := []dbx.Expression{}
+	if userId != "" {
+		filters = append(filters, dbx.HashExp{model.DrawGrantsFieldUserId: userId})
+	}
+	total, err := service.app.CountRecords(model.DbNameDrawGrants, filters...)
+	if err != nil {
+		return nil, 0, err
+	}
+	grants := []*model.DrawGrant{}
+	if err = service.app.RecordQuery(model.DbNameDrawGrants).
+		Where(dbx.And(filters...)).
+		OrderBy(model.DrawGrantsFieldCreated+" desc", model.CommonFieldId+" desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&grants); err != nil {
+		return nil, 0, err
+	}
+	return grants, int(total), nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
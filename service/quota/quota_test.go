@@ -0,0 +1,260 @@
+package quota
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/config"
+	"bless-activity/service/ledger"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func testItems(base, thanks, votes, checkin, grant int) []Item {
+	return []Item{
+		{Source: model.QuotaSourceBase, Times: base, Capped: true},
+		{Source: model.QuotaSourceThanks, Times: thanks, Capped: true},
+		{Source: model.QuotaSourceVotes, Times: votes, Capped: true},
+		{Source: model.QuotaSourceCheckin, Times: checkin, Daily: true},
+		{Source: model.QuotaSourceGrant, Times: grant},
+	}
+}
+
+func TestTally(t *testing.T) {
+	cases := []struct {
+		name                string
+		items               []Item
+		used                usage
+		capped, total, rest int
+		next                model.QuotaSource
+	}{
+		{"只有基础次数", testItems(6, 0, 0, 0, 0), usage{permanent: 2}, 0, 6, 4, ""},
+		{"超出上限", testItems(6, 12, 5, 0, 0), usage{}, 3, 20, 20, ""},
+		{"补发不受上限限制", testItems(6, 18, 0, 0, 3), usage{permanent: 20}, 4, 23, 3, ""},
+		{"扣回后不为负数", testItems(6, 0, 0, 0, -10), usage{permanent: 3}, 0, 0, 0, ""},
+		{"签到次数优先消耗", testItems(6, 0, 0, 2, 0), usage{permanent: 1, daily: map[model.QuotaSource]int{model.QuotaSourceCheckin: 1}}, 0, 8, 6, model.QuotaSourceCheckin},
+		{"签到次数用完后消耗长期次数", testItems(6, 0, 0, 2, 0), usage{permanent: 1, daily: map[model.QuotaSource]int{model.QuotaSourceCheckin: 2}}, 0, 8, 5, ""},
+		{"长期次数用完只剩签到", testItems(6, 0, 0, 1, 0), usage{permanent: 9}, 0, 7, 1, model.QuotaSourceCheckin},
+	}
+	for _, c := range cases {
+		quota := tally(c.items, 20, c.used)
+		if quota.Capped != c.capped || quota.Total != c.total || quota.Rest != c.rest || quota.Next() != c.next {
+			t.Errorf("%s: capped = %d, total = %d, rest = %d, next = %q, 期望 %d, %d, %d, %q",
+				c.name, quota.Capped, quota.Total, quota.Rest, quota.Next(), c.capped, c.total, c.rest, c.next)
+		}
+	}
+
+	// 每日重置的来源单独统计当天已用的次数
+	quota := tally(testItems(6, 0, 0, 2, 0), 20, usage{permanent: 4, daily: map[model.QuotaSource]int{model.QuotaSourceCheckin: 1}})
+	if quota.Items[3].Used != 1 || quota.Used != 5 {
+		t.Errorf("checkin used = %d, used = %d", quota.Items[3].Used, quota.Used)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("默认配置校验失败: %v", err)
+	}
+
+	cases := map[string]Config{
+		"上限为0":   {Base: 6, Cap: 0},
+		"基础次数为负": {Base: -1, Cap: 20},
+		"福签为负":   {Base: 6, Cap: 20, VotesPerDraw: -1},
+		"签到过多":   {Base: 6, Cap: 20, Checkin: 101},
+	}
+	for name, config := range cases {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+}
+
+// newTestService 使用给定次数配置的博饼次数服务
+func newTestService(t *testing.T, app core.App, value Config) (*Service, *config.Registry) {
+	t.Helper()
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app,
+		testapp.FishpiDefinition(),
+		config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+			schedule := service.DefaultSchedule()
+			return &schedule
+		}},
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+		config.Definition{Key: model.ConfigKeyPaidDraw, Default: func() any { return &service.PaidDrawConfig{} }},
+		config.Definition{Key: model.ConfigKeyQuota, Default: func() any { return DefaultConfig() }},
+	)
+	setConfig(t, app, registry, value)
+
+	fishpiService := fake.Service(t, app, registry)
+	paidDrawService := service.NewPaidDrawService(app, ledger.NewService(app, fishpiService, registry), registry)
+	thankService := service.NewThankService(app, fishpiService, service.NewActivityService(app, registry))
+	return NewService(app, registry, thankService, paidDrawService), registry
+}
+
+func setConfig(t *testing.T, app core.App, registry *config.Registry, value Config) {
+	t.Helper()
+	patch, _ := json.Marshal(value)
+	if _, _, err := registry.Update(app, model.ConfigKeyQuota, patch); err != nil {
+		t.Fatalf("修改次数配置失败: %v", err)
+	}
+}
+
+// draw 创建一条消耗免费次数的博饼记录，created 为空时使用当前时间
+func draw(t *testing.T, app core.App, user *model.User, reward *model.Reward, source model.QuotaSource, times int, created time.Time) {
+	t.Helper()
+	history := testapp.History(t, app, user, reward, times)
+	history.SetQuotaSource(source.String())
+	if err := app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+	if created.IsZero() {
+		return
+	}
+	date, _ := types.ParseDateTime(created)
+	if _, err := app.DB().Update(model.DbNameHistories, dbx.Params{model.HistoriesFieldCreated: date.String()},
+		dbx.HashExp{model.CommonFieldId: history.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService_Checkin(t *testing.T) {
+	app := testapp.New(t)
+	quotaService, registry := newTestService(t, app, Config{Base: 2, Cap: 20})
+	user := testapp.User(t, app, "1001", "alice")
+	testapp.Article(t, app, user, "2001")
+	reward := testapp.Reward(t, app, "月饼", 100)
+
+	if _, err := quotaService.Checkin(user); !errors.Is(err, ErrCheckinClosed) {
+		t.Errorf("未开放签到时应返回 ErrCheckinClosed, 得到 %v", err)
+	}
+	setConfig(t, app, registry, Config{Base: 2, Cap: 20, Checkin: 3})
+
+	checkin, err := quotaService.Checkin(user)
+	if err != nil || checkin.Times() != 3 {
+		t.Fatalf("签到失败: %v", err)
+	}
+	if _, err = quotaService.Checkin(user); !errors.Is(err, ErrCheckedIn) {
+		t.Errorf("重复签到应返回 ErrCheckedIn, 得到 %v", err)
+	}
+
+	// 签到的次数优先消耗
+	quota, err := quotaService.Get(app, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Total != 5 || quota.Rest != 5 || quota.Next() != model.QuotaSourceCheckin {
+		t.Errorf("签到后 total = %d, rest = %d, next = %q", quota.Total, quota.Rest, quota.Next())
+	}
+
+	// 签到次数用完后消耗长期次数
+	for i := range 3 {
+		draw(t, app, user, reward, model.QuotaSourceCheckin, i+1, time.Time{})
+	}
+	if quota, _ = quotaService.Get(app, user); quota.Rest != 2 || quota.Next() != "" || quota.Draws != 3 {
+		t.Errorf("签到次数用完后 rest = %d, next = %q, draws = %d", quota.Rest, quota.Next(), quota.Draws)
+	}
+}
+
+func TestService_DailyReset(t *testing.T) {
+	app := testapp.New(t)
+	quotaService, _ := newTestService(t, app, Config{Base: 2, Cap: 20, Checkin: 3})
+	user := testapp.User(t, app, "1001", "alice")
+	testapp.Article(t, app, user, "2001")
+	reward := testapp.Reward(t, app, "月饼", 100)
+
+	// 昨天签到并用完签到次数，另外消耗了一次长期次数
+	yesterday := time.Now().AddDate(0, 0, -1)
+	collection, _ := app.FindCollectionByNameOrId(model.DbNameCheckins)
+	checkin := model.NewCheckinFromCollection(collection)
+	checkin.SetUserId(user.Id)
+	checkin.SetDay(yesterday.Format(time.DateOnly))
+	checkin.SetTimes(3)
+	if err := app.Save(checkin); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		draw(t, app, user, reward, model.QuotaSourceCheckin, i+1, yesterday)
+	}
+	draw(t, app, user, reward, "", 4, yesterday)
+
+	// 往日签到的次数不保留，也不占用长期次数
+	quota, err := quotaService.Get(app, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Total != 2 || quota.Used != 1 || quota.Rest != 1 || quota.Draws != 4 {
+		t.Errorf("次日 total = %d, used = %d, rest = %d, draws = %d", quota.Total, quota.Used, quota.Rest, quota.Draws)
+	}
+	tomorrow := startOfDay(time.Now()).AddDate(0, 0, 1)
+	if !quota.ResetAt.Time().Equal(tomorrow) {
+		t.Errorf("reset_at = %s, 期望 %s", quota.ResetAt, tomorrow)
+	}
+
+	// 当天重新签到获得次数
+	if _, err = quotaService.Checkin(user); err != nil {
+		t.Fatal(err)
+	}
+	if quota, _ = quotaService.Get(app, user); quota.Rest != 4 || quota.Items[3].Used != 0 {
+		t.Errorf("重新签到后 rest = %d, checkin used = %d", quota.Rest, quota.Items[3].Used)
+	}
+}
+
+func TestService_Grant(t *testing.T) {
+	app := testapp.New(t)
+	quotaService, _ := newTestService(t, app, Config{Base: 2, Cap: 2})
+	admin := testapp.User(t, app, "1000", "admin")
+	user := testapp.User(t, app, "1001", "alice")
+	other := testapp.User(t, app, "1002", "bob")
+	testapp.Article(t, app, user, "2001")
+
+	for name, times := range map[string]int{"次数为0": 0, "超过100": 101} {
+		if _, err := quotaService.Grant(app, admin, user.Id, times, "补偿"); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+	if _, err := quotaService.Grant(app, admin, user.Id, 1, " "); err == nil {
+		t.Error("未填写原因时应校验失败")
+	}
+	if _, err := quotaService.Grant(app, admin, "missing", 1, "补偿"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("用户不存在时应返回 sql.ErrNoRows, 得到 %v", err)
+	}
+
+	// 补发不受上限限制，扣回从补发中扣除
+	for _, times := range []int{3, -1} {
+		if _, err := quotaService.Grant(app, admin, user.Id, times, "补偿"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	quota, err := quotaService.Get(app, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Items[4].Times != 2 || quota.Total != 4 {
+		t.Errorf("补发后 grant = %d, total = %d", quota.Items[4].Times, quota.Total)
+	}
+
+	cases := []struct {
+		userId string
+		want   int
+	}{
+		{"", 2},
+		{user.Id, 2},
+		{other.Id, 0},
+	}
+	for _, c := range cases {
+		grants, total, err := quotaService.Grants(c.userId, 1, 10)
+		if err != nil || total != c.want || len(grants) != c.want {
+			t.Errorf("Grants(%q) = %d 条, total %d, %v, 期望 %d", c.userId, len(grants), total, err, c.want)
+		}
+	}
+	if grants, _, _ := quotaService.Grants(user.Id, 1, 10); len(grants) == 2 && grants[0].Times() != -1 {
+		t.Errorf("补发记录应按时间倒序, 得到 %d", grants[0].Times())
+	}
+}
@@ -0,0 +1,137 @@
+package quota
+
+import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Context 计算次数时的用户信息
+type Context struct {
+	App     core.App
+	Config  Config
+	User    *model.User
+	Article *model.Article // 用户最新的活动文章，没有时为 nil
+	Now     time.Time
+}
+
+// Day 当天的日期，每日重置的来源按此区分
+func (ctx *Context) Day() string {
+	return ctx.Now.Format(time.DateOnly)
+}
+
+// Item 一个来源提供的免费次数
+type Item struct {
+	Source model.QuotaSource `json:"source"`
+	Name   string            `json:"name"`
+	Times  int               `json:"times"`
+	Capped bool              `json:"capped"` // 计入上限
+	Daily  bool              `json:"daily"`  // 每天重置，当天未用完的次数不保留
+	Used   int               `json:"used"`   // 仅每日重置的来源单独统计当天已用的次数
+	Detail string            `json:"detail"` // 次数的由来，展示给用户
+}
+
+// Source 博饼次数的来源
+type Source interface {
+	Item(ctx *Context) (Item, error)
+}
+
+// baseSource 发布活动文章后的基础次数
+type baseSource struct{}
+
+func (baseSource) Item(ctx *Context) (Item, error) {
+	item := Item{Source: model.QuotaSourceBase, Name: "基础次数", Capped: true}
+	if ctx.Article == nil {
+		item.Detail = fmt.Sprintf("发布活动文章后获得 %d 次", ctx.Config.Base)
+		return item, nil
+	}
+	item.Times = ctx.Config.Base
+	item.Detail = fmt.Sprintf("发布活动文章《%s》获得 %d 次", ctx.Article.Title(), ctx.Config.Base)
+	return item, nil
+}
+
+// thanksSource 活动期间感谢过文章的不同用户，每位增加一次
+type thanksSource struct {
+	thankService *service.ThankService
+}
+
+func (source thanksSource) Item(ctx *Context) (Item, error) {
+	item := Item{Source: model.QuotaSourceThanks, Name: "文章感谢", Capped: true}
+	if ctx.Article == nil {
+		item.Detail = "活动期间每有一位用户感谢你的文章增加一次"
+		return item, nil
+	}
+	count, err := source.thankService.Count(ctx.Article)
+	if err != nil {
+		return item, err
+	}
+	item.Times = count
+	item.Detail = fmt.Sprintf("活动期间 %d 位用户感谢了你的文章，不含自己的感谢与重复感谢", count)
+	return item, nil
+}
+
+// votesSource 收到的福签，不含被标记的福签
+type votesSource struct{}
+
+func (votesSource) Item(ctx *Context) (Item, error) {
+	item := Item{Source: model.QuotaSourceVotes, Name: "收到福签", Capped: true}
+	if ctx.Config.VotesPerDraw <= 0 {
+		item.Detail = "收到福签不增加博饼次数"
+		return item, nil
+	}
+	count, err := ctx.App.CountRecords(model.DbNameVotes, dbx.HashExp{
+		model.VotesFieldToUserId: ctx.User.Id,
+		model.VotesFieldFlagged:  false,
+	})
+	if err != nil {
+		return item, err
+	}
+	item.Times = int(count) / ctx.Config.VotesPerDraw
+	item.Detail = fmt.Sprintf("收到 %d 张福签，每 %d 张增加一次", count, ctx.Config.VotesPerDraw)
+	return item, nil
+}
+
+// checkinSource 每日签到，次数按签到时的配置保存，当天有效
+type checkinSource struct{}
+
+func (checkinSource) Item(ctx *Context) (Item, error) {
+	item := Item{Source: model.QuotaSourceCheckin, Name: "每日签到", Daily: true}
+	checkin := new(model.Checkin)
+	err := ctx.App.RecordQuery(model.DbNameCheckins).
+		Where(dbx.HashExp{model.CheckinsFieldUserId: ctx.User.Id, model.CheckinsFieldDay: ctx.Day()}).
+		One(checkin)
+	switch {
+	case err == nil:
+		item.Times = checkin.Times()
+		item.Detail = fmt.Sprintf("今日已签到，获得 %d 次，当天有效", item.Times)
+	case !errors.Is(err, sql.ErrNoRows):
+		return item, err
+	case ctx.Config.Checkin > 0:
+		item.Detail = fmt.Sprintf("今日未签到，签到可获得 %d 次，当天有效", ctx.Config.Checkin)
+	default:
+		item.Detail = "未开放签到"
+	}
+	return item, nil
+}
+
+// grantSource 管理员补发与扣回的合计
+type grantSource struct{}
+
+func (grantSource) Item(ctx *Context) (Item, error) {
+	item := Item{Source: model.QuotaSourceGrant, Name: "管理员补发"}
+	if err := ctx.App.DB().
+		Select("COALESCE(SUM([[" + model.DrawGrantsFieldTimes + "]]), 0)").
+		From(model.DbNameDrawGrants).
+		Where(dbx.HashExp{model.DrawGrantsFieldUserId: ctx.User.Id}).
+		Row(&item.Times); err != nil {
+		return item, err
+	}
+	item.Detail = fmt.Sprintf("管理员补发 %d 次", item.Times)
+	return item, nil
+}
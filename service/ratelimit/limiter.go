@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// 空闲桶的清理间隔
+	cleanupInterval = 5 * time.Minute
+	// 被限流后写入记录的最小间隔，期间的拒绝次数合并到下一条记录
+	reportInterval = 10 * time.Second
+)
+
+// Limit 令牌桶参数
+type Limit struct {
+	PerMinute int `json:"perMinute"` // 每分钟补充的令牌数
+	Burst     int `json:"burst"`     // 桶容量，即允许的瞬时突发次数
+}
+
+// Valid 参数是否有效，无效的限制视为不限流
+func (limit Limit) Valid() bool {
+	return limit.PerMinute > 0 && limit.Burst > 0
+}
+
+func (limit Limit) ratePerSecond() float64 {
+	return float64(limit.PerMinute) / 60
+}
+
+// Decision 单次请求的限流结果
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	// Report 为 true 时应记录一次限流，Dropped 为自上次记录以来被拒绝的次数
+	Report  bool
+	Dropped int
+}
+
+type bucket struct {
+	limit      Limit
+	tokens     float64
+	updatedAt  time.Time
+	dropped    int
+	reportedAt time.Time
+}
+
+// Limiter 基于令牌桶的内存限流器，按 key 隔离
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	cleanedAt time.Time
+
+	now func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow 从 key 对应的桶中取一个令牌
+func (limiter *Limiter) Allow(key string, limit Limit) Decision {
+	if !limit.Valid() {
+		return Decision{Allowed: true}
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	limiter.cleanup(now)
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		limiter.buckets[key] = b
+	}
+	b.limit = limit
+
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.ratePerSecond())
+		b.updatedAt = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return Decision{Allowed: true}
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.ratePerSecond() * float64(time.Second))
+	decision := Decision{
+		Allowed:    false,
+		RetryAfter: wait,
+	}
+
+	b.dropped++
+	if now.Sub(b.reportedAt) >= reportInterval {
+		decision.Report = true
+		decision.Dropped = b.dropped
+		b.dropped = 0
+		b.reportedAt = now
+	}
+
+	return decision
+}
+
+// cleanup 移除已回满且长时间未使用的桶，避免按 IP 建桶导致内存无限增长
+func (limiter *Limiter) cleanup(now time.Time) {
+	if now.Sub(limiter.cleanedAt) < cleanupInterval {
+		return
+	}
+	limiter.cleanedAt = now
+
+	for key, b := range limiter.buckets {
+		idle := now.Sub(b.updatedAt)
+		full := b.tokens+idle.Seconds()*b.limit.ratePerSecond() >= float64(b.limit.Burst)
+		if idle >= cleanupInterval && full {
+			delete(limiter.buckets, key)
+		}
+	}
+}
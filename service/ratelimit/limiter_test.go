@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter()
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	limit := Limit{PerMinute: 60, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if d := limiter.Allow("a", limit); !d.Allowed {
+			t.Fatalf("第%d次请求应放行", i+1)
+		}
+	}
+
+	d := limiter.Allow("a", limit)
+	if d.Allowed {
+		t.Fatal("超出突发上限应被限流")
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, 期望 1s", d.RetryAfter)
+	}
+
+	// 不同 key 互不影响
+	if d := limiter.Allow("b", limit); !d.Allowed {
+		t.Error("其他 key 应放行")
+	}
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limit := Limit{PerMinute: 30, Burst: 1}
+
+	if d := limiter.Allow("a", limit); !d.Allowed {
+		t.Fatal("首次请求应放行")
+	}
+	if d := limiter.Allow("a", limit); d.Allowed {
+		t.Fatal("令牌耗尽应被限流")
+	}
+
+	*now = now.Add(time.Second)
+	if d := limiter.Allow("a", limit); d.Allowed || d.RetryAfter != time.Second {
+		t.Fatalf("补充半个令牌时仍应限流, RetryAfter = %v", d.RetryAfter)
+	}
+
+	*now = now.Add(time.Second)
+	if d := limiter.Allow("a", limit); !d.Allowed {
+		t.Fatal("补充一个令牌后应放行")
+	}
+
+	// 长时间空闲后令牌数不超过桶容量
+	*now = now.Add(time.Hour)
+	limiter.Allow("a", limit)
+	if d := limiter.Allow("a", limit); d.Allowed {
+		t.Fatal("令牌数不应超过桶容量")
+	}
+}
+
+func TestLimiter_Report(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limit := Limit{PerMinute: 1, Burst: 1}
+
+	limiter.Allow("a", limit)
+	if d := limiter.Allow("a", limit); !d.Report || d.Dropped != 1 {
+		t.Fatalf("首次被限流应记录, Report = %v, Dropped = %d", d.Report, d.Dropped)
+	}
+	for i := 0; i < 4; i++ {
+		if d := limiter.Allow("a", limit); d.Report {
+			t.Fatal("记录间隔内不应重复记录")
+		}
+	}
+
+	*now = now.Add(reportInterval)
+	if d := limiter.Allow("a", limit); !d.Report || d.Dropped != 5 {
+		t.Fatalf("间隔后应合并记录, Report = %v, Dropped = %d", d.Report, d.Dropped)
+	}
+}
+
+func TestLimiter_InvalidLimit(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	for i := 0; i < 100; i++ {
+		if d := limiter.Allow("a", Limit{}); !d.Allowed {
+			t.Fatal("未配置的限制不应限流")
+		}
+	}
+}
+
+func TestLimiter_Cleanup(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limit := Limit{PerMinute: 60, Burst: 2}
+
+	limiter.Allow("a", limit)
+	*now = now.Add(cleanupInterval)
+	limiter.Allow("b", limit)
+
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("空闲且已回满的桶应被清理")
+	}
+	if _, ok := limiter.buckets["b"]; !ok {
+		t.Error("活跃的桶不应被清理")
+	}
+}
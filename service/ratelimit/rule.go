@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	RuleMooncakeGambling = "mooncake_gambling"
+	RuleVoteCreate       = "vote_create"
+	RuleVoteDelete       = "vote_delete"
+	RuleTableRoll        = "table_roll"
+	RuleRewardRetry      = "reward_retry"
+)
+
+// Rule 单个路由的限流规则，按用户与按 IP 分别计数，两者都通过才放行
+type Rule struct {
+	User Limit `json:"user"`
+	Ip   Limit `json:"ip"`
+}
+
+// Rules 路由名称到限流规则的映射
+type Rules map[string]Rule
+
+// DefaultRules 默认规则，可通过 configs 中 key 为 ratelimit 的记录按路由覆盖，修改后即时生效
+func DefaultRules() Rules {
+	return Rules{
+		RuleMooncakeGambling: {
+			User: Limit{PerMinute: 20, Burst: 3},
+			Ip:   Limit{PerMinute: 60, Burst: 10},
+		},
+		RuleVoteCreate: {
+			User: Limit{PerMinute: 30, Burst: 5},
+			Ip:   Limit{PerMinute: 90, Burst: 15},
+		},
+		RuleVoteDelete: {
+			User: Limit{PerMinute: 30, Burst: 5},
+			Ip:   Limit{PerMinute: 90, Burst: 15},
+		},
+		RuleTableRoll: {
+			User: Limit{PerMinute: 30, Burst: 3},
+			Ip:   Limit{PerMinute: 90, Burst: 10},
+		},
+		RuleRewardRetry: {
+			User: Limit{PerMinute: 2, Burst: 2},
+			Ip:   Limit{PerMinute: 10, Burst: 5},
+		},
+	}
+}
+
+// Validate 限制不能为负数，为 0 时视为不限流
+func (rules Rules) Validate() error {
+	errs := validation.Errors{}
+	for name, rule := range rules {
+		if rule.User.PerMinute < 0 || rule.User.Burst < 0 || rule.Ip.PerMinute < 0 || rule.Ip.Burst < 0 {
+			errs[name] = validation.NewError("validation_invalid_limit", "限制不能为负数")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
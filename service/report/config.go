@@ -0,0 +1,149 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DefaultTemplate 默认的报告正文模板，数据为 Summary
+const DefaultTemplate = `## 活动概况
+
+- 参与人数：{{.Participants}} 人（博饼 {{.Drawers}} 人，送福签 {{.Voters}} 人）
+- 参赛文章：{{.ArticleCount}} 篇
+- 博饼次数：{{.Draws}} 次{{if .PaidDraws}}，其中付费博饼 {{.PaidDraws}} 次{{end}}
+- 送出福签：{{.VoteCount}} 张
+
+## 奖项分布
+
+| 奖项 | 次数 | 实际占比 | 理论概率 |
+| --- | ---: | ---: | ---: |
+{{range .Prizes}}| {{.Name}} | {{.Count}} | {{percent .Rate}} | {{percent .Odds}} |
+{{end}}
+{{- if .Rewards}}
+| 奖品 | 发放数量 |
+| --- | ---: |
+{{range .Rewards}}| {{escape .Name}} | {{.Count}} |
+{{end}}{{end}}
+## 状元榜
+{{if .Champions}}
+| 名次 | 用户 | 状元 | 骰子 | 第几次博饼 |
+| ---: | --- | --- | --- | ---: |
+{{range .Champions}}| {{.Rank}} | {{user .Username .Nickname}} | {{.PrizeName}}{{if .GotReward}} 🏆{{end}} | {{dices .Dices}} | {{.Times}} |
+{{end}}{{else}}
+本次活动无人博出状元。
+{{end}}
+## 福签榜
+{{range .Votes}}
+### {{.Name}}
+{{if .Winners}}
+{{range .Winners}}{{.Rank}}. {{user .Username .Nickname}}：{{.Count}} 张
+{{end}}{{else}}
+暂无
+{{end}}{{end}}
+## 文章排行
+{{if .Articles}}
+{{range .Articles}}{{.Rank}}. [{{escape .Title}}]({{.Url}}) - {{user .Username .Nickname}}，评分 {{printf "%.2f" .Score}}
+{{end}}{{else}}
+暂无
+{{end}}
+## 积分发放
+
+| 来源 | 笔数 | 积分 |
+| --- | ---: | ---: |
+{{range .Points}}| {{.Name}} | {{.Count}} | {{.Point}} |
+{{end}}
+- 累计发放：{{.PointsIssued}} 积分
+{{- if .PointsSpent}}
+- 付费博饼消耗：{{.PointsSpent}} 积分
+{{- end}}
+
+*本报告由活动数据自动生成于 {{date .GeneratedAt}}*
+`
+
+// Config 活动报告配置，标题与正文使用 text/template 语法，数据为 Summary
+type Config struct {
+	Title    string `json:"title"`
+	Template string `json:"template"` // 为空时使用默认模板
+	Tags     string `json:"tags"`     // 逗号分隔
+	Top      int    `json:"top"`      // 福签榜、文章排行的上榜数量
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Title:    "【活动总结】双节同庆·福签传情",
+		Template: DefaultTemplate,
+		Tags:     "活动总结",
+		Top:      10,
+	}
+}
+
+func (config *Config) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.Title, validation.Required.Error("不能为空"), validation.By(validateTemplate)),
+		validation.Field(&config.Template, validation.By(validateTemplate)),
+		validation.Field(&config.Tags, validation.Required.Error("不能为空")),
+		validation.Field(&config.Top, validation.Required.Error("至少为1"), validation.Min(1).Error("至少为1"), validation.Max(100).Error("不能超过100")),
+	)
+}
+
+func validateTemplate(value any) error {
+	if _, err := parse(value.(string)); err != nil {
+		return validation.NewError("validation_template", "模板语法错误: "+err.Error())
+	}
+	return nil
+}
+
+// funcs 模板中可用的函数
+var funcs = template.FuncMap{
+	"percent": percent,
+	"user": func(username string, nickname string) string {
+		if nickname == "" || nickname == username {
+			return escape(username)
+		}
+		return escape(nickname + "(" + username + ")")
+	},
+	"dices": func(dices [6]int) string {
+		points := make([]string, len(dices))
+		for i, point := range dices {
+			points[i] = string(rune('0' + point))
+		}
+		return strings.Join(points, " ")
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+	"escape": escape,
+}
+
+// percent 百分比，状元的概率很小，小于 1% 时多保留两位小数
+func percent(rate float64) string {
+	if rate > 0 && rate < 0.01 {
+		return fmt.Sprintf("%.4f%%", rate*100)
+	}
+	return fmt.Sprintf("%.2f%%", rate*100)
+}
+
+func parse(text string) (*template.Template, error) {
+	return template.New("report").Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// escape 转义 Markdown 表格与链接中有特殊含义的字符
+func escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`*`, `\*`,
+	`_`, `\_`,
+	"`", "\\`",
+	"\n", " ",
+)
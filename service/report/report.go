@@ -0,0 +1,194 @@
+package report
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/fishpi"
+	"bless-activity/service/mooncakeGambling"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var (
+	ErrPublishing    = errors.New("报告正在发布，请稍后再试")
+	ErrPublishFailed = errors.New("发布到摸鱼派失败")
+)
+
+// Draft 渲染后的报告
+type Draft struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Tags    string `json:"tags"`
+}
+
+// Render 按配置的模板渲染报告
+func Render(value Config, summary *Summary) (*Draft, error) {
+	text := value.Template
+	if strings.TrimSpace(text) == "" {
+		text = DefaultTemplate
+	}
+
+	draft := &Draft{Tags: value.Tags}
+	for _, part := range []struct {
+		text   string
+		target *string
+	}{
+		{value.Title, &draft.Title},
+		{text, &draft.Content},
+	} {
+		tmpl, err := parse(part.text)
+		if err != nil {
+			return nil, err
+		}
+		builder := &strings.Builder{}
+		if err = tmpl.Execute(builder, summary); err != nil {
+			return nil, err
+		}
+		*part.target = strings.TrimSpace(builder.String())
+	}
+	return draft, nil
+}
+
+// Service 活动总结报告：汇总活动数据，按模板生成 Markdown，发布为摸鱼派帖子
+// 首次发布创建帖子，之后再次发布时更新同一篇帖子，每次发布保存一个版本
+type Service struct {
+	app           core.App
+	fishpiService *fishpi.Service
+	registry      *config.Registry
+	game          *mooncakeGambling.MooncakeGame
+	logger        *slog.Logger
+
+	publishing sync.Mutex
+}
+
+func NewService(app core.App, fishpiService *fishpi.Service, registry *config.Registry) *Service {
+	return &Service{
+		app:           app,
+		fishpiService: fishpiService,
+		registry:      registry,
+		game:          mooncakeGambling.NewMooncakeGame(),
+		logger:        app.Logger().WithGroup("report"),
+	}
+}
+
+// Generate 使用当前数据与配置生成报告
+func (service *Service) Generate() (*Draft, error) {
+	value, err := config.Get[Config](service.registry, model.ConfigKeyReport)
+	if err != nil {
+		return nil, err
+	}
+	fishpiConfig, err := config.Get[fishpi.Config](service.registry, model.ConfigKeyFishpi)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := collect(service.app, service.game, value.Top, articleUrlPrefix(fishpiConfig.BaseUrl))
+	if err != nil {
+		return nil, err
+	}
+	return Render(value, summary)
+}
+
+// Publish 生成报告并发布到摸鱼派，已发布过时更新原帖子，返回保存的版本与是否新建了帖子
+func (service *Service) Publish(actor *model.User) (*model.Report, bool, error) {
+	if !service.publishing.TryLock() {
+		return nil, false, ErrPublishing
+	}
+	defer service.publishing.Unlock()
+
+	draft, err := service.Generate()
+	if err != nil {
+		return nil, false, err
+	}
+
+	previous, err := service.Latest()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	request := &fishpi.PostArticleRequest{
+		ArticleTitle:      draft.Title,
+		ArticleContent:    draft.Content,
+		ArticleTags:       draft.Tags,
+		Commentable:       true,
+		ArticleShowInList: 1,
+	}
+	var articleId string
+	created := previous == nil
+	if created {
+		if articleId, err = service.fishpiService.PostArticle(request); err != nil {
+			return nil, false, fmt.Errorf("%w: %w", ErrPublishFailed, err)
+		}
+		service.logger.Info("活动报告已发布", slog.String("article_id", articleId))
+	} else {
+		articleId = previous.ArticleId()
+		if err = service.fishpiService.PutArticle(articleId, request); err != nil {
+			return nil, false, fmt.Errorf("%w: %w", ErrPublishFailed, err)
+		}
+		service.logger.Info("活动报告已更新", slog.String("article_id", articleId))
+	}
+
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameReports)
+	if err != nil {
+		return nil, false, err
+	}
+	report := model.NewReportFromCollection(collection)
+	report.SetActorId(actor.Id)
+	report.SetTitle(draft.Title)
+	report.SetContent(draft.Content)
+	report.SetTags(draft.Tags)
+	report.SetArticleId(articleId)
+	if err = service.app.Save(report); err != nil {
+		// 帖子已经发布，只是没有保存版本，记录帖子 id 便于手动补录
+		service.logger.Error("保存活动报告失败", slog.String("article_id", articleId), slog.Any("err", err))
+		return nil, false, err
+	}
+	return report, created, nil
+}
+
+// Latest 最近一次发布的报告，没有时返回 sql.ErrNoRows
+func (service *Service) Latest() (*model.Report, error) {
+	report := new(model.Report)
+	if err := service.app.RecordQuery(model.DbNameReports).
+		OrderBy(model.ReportsFieldCreated+" desc", model.CommonFieldId+" desc").
+		Limit(1).
+		One(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// List 分页查询发布过的报告版本，按发布时间倒序
+func (service *Service) List(page int, perPage int) ([]*model.Report, int, error) {
+	total, err := service.app.CountRecords(model.DbNameReports)
+	if err != nil {
+		return nil, 0, err
+	}
+	reports := []*model.Report{}
+	if err = service.app.RecordQuery(model.DbNameReports).
+		OrderBy(model.ReportsFieldCreated+" desc", model.CommonFieldId+" desc").
+		Offset(int64((page - 1) * perPage)).
+		Limit(int64(perPage)).
+		All(&reports); err != nil {
+		return nil, 0, err
+	}
+	return reports, int(total), nil
+}
+
+// Find 查找报告版本，不存在时返回 sql.ErrNoRows
+func (service *Service) Find(id string) (*model.Report, error) {
+	report := new(model.Report)
+	if err := service.app.RecordQuery(model.DbNameReports).
+		Where(dbx.HashExp{model.CommonFieldId: id}).
+		One(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"bless-activity/service/mooncakeGambling"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDefaultTemplate(t *testing.T) {
+	summary := &Summary{
+		GeneratedAt:  time.Date(2025, 10, 8, 20, 0, 0, 0, time.Local),
+		Participants: 12,
+		Drawers:      10,
+		Voters:       8,
+		ArticleCount: 5,
+		Draws:        60,
+		PaidDraws:    3,
+		VoteCount:    24,
+		Prizes: prizeDistribution(map[mooncakeGambling.PrizeLevel]int{
+			mooncakeGambling.PrizeLevelZYLiuBo4: 1,
+			mooncakeGambling.PrizeLevelNone:     59,
+		}, mooncakeGambling.NewMooncakeGame().Odds(), 60),
+		Rewards:   []RewardStat{{Name: "月饼|礼盒", Count: 1}},
+		Champions: []Champion{{Rank: 1, Username: "alice", Nickname: "爱丽丝", PrizeName: "状元六勃红", Dices: [6]int{4, 4, 4, 4, 4, 4}, Times: 3, GotReward: true}},
+		Votes: []VoteBoard{
+			{Type: "career", Name: "事业符", Winners: []VoteWinner{{Rank: 1, Username: "bob", Count: 4}}},
+			{Type: "romance", Name: "姻缘符"},
+		},
+		Articles:     []ArticleRank{{Rank: 1, Title: "中秋[快乐]", Url: "https://fishpi.cn/article/1", Score: 98.5, Username: "bob"}},
+		Points:       []PointStat{{Source: "gambling", Name: "博饼奖励", Count: 2, Point: 300}},
+		PointsIssued: 300,
+		PointsSpent:  60,
+	}
+
+	draft, err := Render(*DefaultConfig(), summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.Title != "【活动总结】双节同庆·福签传情" || draft.Tags != "活动总结" {
+		t.Errorf("title = %q, tags = %q", draft.Title, draft.Tags)
+	}
+	for _, want := range []string{
+		"- 参与人数：12 人（博饼 10 人，送福签 8 人）",
+		"- 博饼次数：60 次，其中付费博饼 3 次",
+		"| 状元六勃红 | 1 | 1.67% | 0.0021% |",
+		"| 无奖 | 59 | 98.33% |",
+		"| 月饼\\|礼盒 | 1 |",
+		"| 1 | 爱丽丝(alice) | 状元六勃红 🏆 | 4 4 4 4 4 4 | 3 |",
+		"### 事业符\n\n1. bob：4 张\n",
+		"### 姻缘符\n\n暂无\n",
+		"1. [中秋\\[快乐\\]](https://fishpi.cn/article/1) - bob，评分 98.50",
+		"- 累计发放：300 积分\n- 付费博饼消耗：60 积分",
+		"2025-10-08 20:00",
+	} {
+		if !strings.Contains(draft.Content, want) {
+			t.Errorf("报告缺少 %q\n%s", want, draft.Content)
+		}
+	}
+}
+
+func TestRenderEmptyActivity(t *testing.T) {
+	draft, err := Render(Config{Title: "总结 {{.Draws}}", Tags: "a"}, &Summary{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.Title != "总结 0" {
+		t.Errorf("title = %q", draft.Title)
+	}
+	// 模板为空时使用默认模板
+	for _, want := range []string{"本次活动无人博出状元", "## 文章排行\n\n暂无"} {
+		if !strings.Contains(draft.Content, want) {
+			t.Errorf("报告缺少 %q\n%s", want, draft.Content)
+		}
+	}
+	if strings.Contains(draft.Content, "付费博饼") {
+		t.Errorf("没有付费博饼时不应显示\n%s", draft.Content)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("默认配置校验失败: %v", err)
+	}
+
+	cases := map[string]Config{
+		"标题语法错误": {Title: "{{.Draws", Tags: "a", Top: 10},
+		"正文语法错误": {Title: "a", Template: "{{range .Prizes}}", Tags: "a", Top: 10},
+		"未知函数":   {Title: "a", Template: "{{upper .Title}}", Tags: "a", Top: 10},
+		"上榜数量":   {Title: "a", Tags: "a", Top: 0},
+		"标签为空":   {Title: "a", Top: 10},
+	}
+	for name, config := range cases {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	cases := map[float64]string{0: "0.00%", 0.5: "50.00%", 0.01: "1.00%", 1.0 / 46656: "0.0021%", 1: "100.00%"}
+	for rate, want := range cases {
+		if got := percent(rate); got != want {
+			t.Errorf("percent(%g) = %s, 期望 %s", rate, got, want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package report
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/ledger"
+	"bless-activity/service/mooncakeGambling"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// voteTypeNames 福签类型的名称，按报告中的顺序排列
+var voteTypeNames = []struct{ Type, Name string }{
+	{model.VoteTypeCareer, "事业符"},
+	{model.VoteTypeRomance, "姻缘符"},
+	{model.VoteTypeWealth, "招财符"},
+}
+
+// sourceNames 积分来源的名称
+var sourceNames = map[string]string{
+	ledger.SourceGambling:      "博饼奖励",
+	ledger.SourceSettlement:    "状元结算",
+	ledger.SourceReissue:       "补发奖励",
+	ledger.SourceArticleReward: "文章奖励",
+}
+
+// Summary 活动报告的数据
+type Summary struct {
+	GeneratedAt  time.Time
+	Participants int // 博饼或送福签的用户数
+	Drawers      int
+	Voters       int
+	ArticleCount int
+	Draws        int
+	PaidDraws    int
+	VoteCount    int
+	Prizes       []PrizeStat // 按等级从高到低
+	Rewards      []RewardStat
+	Champions    []Champion
+	Votes        []VoteBoard
+	Articles     []ArticleRank
+	Points       []PointStat
+	PointsIssued int // 各来源发放成功的积分合计
+	PointsSpent  int // 用户付费博饼实际消耗的积分（扣除退款）
+}
+
+// PrizeStat 奖项的实际占比与理论概率
+type PrizeStat struct {
+	Level int
+	Name  string
+	Count int
+	Rate  float64
+	Odds  float64
+}
+
+// RewardStat 奖品的发放数量
+type RewardStat struct {
+	Name  string `db:"name"`
+	Count int    `db:"count"`
+}
+
+// Champion 状元榜，按博饼结果从好到差排列
+type Champion struct {
+	Rank      int
+	Username  string
+	Nickname  string
+	PrizeName string
+	Dices     [6]int
+	Times     int
+	GotReward bool
+}
+
+// VoteBoard 一种福签的上榜用户
+type VoteBoard struct {
+	Type    string
+	Name    string
+	Winners []VoteWinner
+}
+
+type VoteWinner struct {
+	Rank     int
+	Username string `db:"username"`
+	Nickname string `db:"nickname"`
+	Count    int    `db:"count"`
+}
+
+// ArticleRank 文章排行，不含取消评奖资格的文章
+type ArticleRank struct {
+	Rank     int
+	Title    string  `db:"title"`
+	OId      string  `db:"oId"`
+	Url      string  `db:"-"`
+	Score    float64 `db:"score"`
+	Username string  `db:"username"`
+	Nickname string  `db:"nickname"`
+}
+
+// PointStat 各来源发放成功的积分
+type PointStat struct {
+	Source string `db:"source"`
+	Name   string `db:"-"`
+	Count  int    `db:"count"`
+	Point  int    `db:"point"`
+}
+
+// collect 汇总活动数据，top 为福签榜、文章排行的上榜数量，articleUrl 为摸鱼派帖子链接的前缀
+func collect(app core.App, game *mooncakeGambling.MooncakeGame, top int, articleUrl string) (*Summary, error) {
+	summary := &Summary{GeneratedAt: time.Now()}
+
+	counts := []struct {
+		target *int
+		sql    string
+	}{
+		{&summary.Participants, "SELECT COUNT(*) FROM (SELECT userId FROM histories UNION SELECT fromUserId FROM votes)"},
+		{&summary.Drawers, "SELECT COUNT(DISTINCT userId) FROM histories"},
+		{&summary.Voters, "SELECT COUNT(DISTINCT fromUserId) FROM votes"},
+		{&summary.ArticleCount, "SELECT COUNT(*) FROM articles"},
+		{&summary.Draws, "SELECT COUNT(*) FROM histories"},
+		{&summary.PaidDraws, "SELECT COUNT(*) FROM histories WHERE feeId != ''"},
+		{&summary.VoteCount, "SELECT COUNT(*) FROM votes"},
+	}
+	for _, count := range counts {
+		if err := app.DB().NewQuery(count.sql).Row(count.target); err != nil {
+			return nil, fmt.Errorf("统计活动数据失败: %w", err)
+		}
+	}
+
+	var err error
+	if summary.Prizes, err = prizeStats(app, game, summary.Draws); err != nil {
+		return nil, fmt.Errorf("统计奖项分布失败: %w", err)
+	}
+	if err = app.DB().NewQuery(`
+		SELECT r.name, COUNT(*) AS count
+		FROM histories h
+		JOIN rewards r ON r.id = h.rewardId
+		WHERE h.gotReward = TRUE
+		GROUP BY h.rewardId
+		ORDER BY r.level DESC, r.name
+	`).All(&summary.Rewards); err != nil {
+		return nil, fmt.Errorf("统计奖品发放失败: %w", err)
+	}
+	if summary.Champions, err = champions(app, game); err != nil {
+		return nil, fmt.Errorf("查询状元榜失败: %w", err)
+	}
+	if summary.Votes, err = voteBoards(app, top); err != nil {
+		return nil, fmt.Errorf("查询福签榜失败: %w", err)
+	}
+	if err = app.DB().NewQuery(`
+		SELECT ar.title, ar.oId, ar.score, u.name AS username, u.nickname
+		FROM articles ar
+		LEFT JOIN users u ON u.id = ar.userId
+		WHERE ar.disqualified = FALSE
+		ORDER BY ar.score DESC, ar.id
+		LIMIT {:limit}
+	`).Bind(dbx.Params{"limit": top}).All(&summary.Articles); err != nil {
+		return nil, fmt.Errorf("查询文章排行失败: %w", err)
+	}
+	for i := range summary.Articles {
+		summary.Articles[i].Rank = i + 1
+		summary.Articles[i].Url = articleUrl + summary.Articles[i].OId
+	}
+	if err = pointStats(app, summary); err != nil {
+		return nil, fmt.Errorf("统计积分发放失败: %w", err)
+	}
+
+	return summary, nil
+}
+
+// prizeStats 按骰子重新计算每次博饼的奖项，与理论概率对比
+func prizeStats(app core.App, game *mooncakeGambling.MooncakeGame, draws int) ([]PrizeStat, error) {
+	var rows []struct {
+		Details string `db:"details"`
+		Count   int    `db:"count"`
+	}
+	if err := app.DB().NewQuery("SELECT details, COUNT(*) AS count FROM histories GROUP BY details").All(&rows); err != nil {
+		return nil, err
+	}
+
+	counts := map[mooncakeGambling.PrizeLevel]int{}
+	for _, row := range rows {
+		var dices [6]int
+		if err := json.Unmarshal([]byte(row.Details), &dices); err != nil {
+			return nil, err
+		}
+		counts[game.CalculatePrize(dices)] += row.Count
+	}
+
+	return prizeDistribution(counts, game.Odds(), draws), nil
+}
+
+// prizeDistribution 各奖项的次数、实际占比与理论概率，按等级从高到低排列
+func prizeDistribution(counts map[mooncakeGambling.PrizeLevel]int, odds map[mooncakeGambling.PrizeLevel]float64, draws int) []PrizeStat {
+	stats := make([]PrizeStat, 0, len(mooncakeGambling.PrizeLevelName))
+	for level, name := range mooncakeGambling.PrizeLevelName {
+		stat := PrizeStat{Level: int(level), Name: name, Count: counts[level], Odds: odds[level]}
+		if draws > 0 {
+			stat.Rate = float64(stat.Count) / float64(draws)
+		}
+		stats = append(stats, stat)
+	}
+	slices.SortFunc(stats, func(a, b PrizeStat) int { return b.Level - a.Level })
+	return stats
+}
+
+// champions 博出状元的记录，按博饼结果排名，结果相同时先博出者在前
+func champions(app core.App, game *mooncakeGambling.MooncakeGame) ([]Champion, error) {
+	var rows []struct {
+		Details   string `db:"details"`
+		Times     int    `db:"times"`
+		GotReward bool   `db:"gotReward"`
+		Username  string `db:"username"`
+		Nickname  string `db:"nickname"`
+	}
+	if err := app.DB().NewQuery(`
+		SELECT h.details, h.times, h.gotReward, u.name AS username, u.nickname
+		FROM histories h
+		LEFT JOIN users u ON u.id = h.userId
+		WHERE h.isTop = TRUE
+		ORDER BY h.created, h.id
+	`).All(&rows); err != nil {
+		return nil, err
+	}
+
+	champions := make([]Champion, 0, len(rows))
+	results := make([]mooncakeGambling.GameResult, 0, len(rows))
+	for _, row := range rows {
+		var dices [6]int
+		if err := json.Unmarshal([]byte(row.Details), &dices); err != nil {
+			return nil, err
+		}
+		result := game.PlayWithDices(dices)
+		champions = append(champions, Champion{
+			Username:  row.Username,
+			Nickname:  row.Nickname,
+			PrizeName: result.PrizeName,
+			Dices:     dices,
+			Times:     row.Times,
+			GotReward: row.GotReward,
+		})
+		results = append(results, result)
+	}
+
+	indexes := make([]int, len(champions))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return mooncakeGambling.CompareGameResult(results[b], results[a])
+	})
+	sorted := make([]Champion, len(champions))
+	for rank, i := range indexes {
+		sorted[rank] = champions[i]
+		sorted[rank].Rank = rank + 1
+	}
+	return sorted, nil
+}
+
+// voteBoards 每种福签收到最多的用户，数量相同时先收到者在前
+func voteBoards(app core.App, top int) ([]VoteBoard, error) {
+	boards := make([]VoteBoard, 0, len(voteTypeNames))
+	for _, voteType := range voteTypeNames {
+		board := VoteBoard{Type: voteType.Type, Name: voteType.Name}
+		if err := app.DB().NewQuery(`
+			SELECT u.name AS username, u.nickname, COUNT(*) AS count
+			FROM votes v
+			LEFT JOIN users u ON u.id = v.toUserId
+			WHERE v.voteType = {:voteType}
+			GROUP BY v.toUserId
+			ORDER BY count DESC, MIN(v.created)
+			LIMIT {:limit}
+		`).Bind(dbx.Params{"voteType": voteType.Type, "limit": top}).All(&board.Winners); err != nil {
+			return nil, err
+		}
+		for i := range board.Winners {
+			board.Winners[i].Rank = i + 1
+		}
+		boards = append(boards, board)
+	}
+	return boards, nil
+}
+
+// pointStats 各来源发放成功的积分，以及付费博饼扣费与退款的合计
+func pointStats(app core.App, summary *Summary) error {
+	var rows []PointStat
+	if err := app.DB().NewQuery(`
+		SELECT source, COUNT(*) AS count, COALESCE(SUM(point), 0) AS point
+		FROM points
+		WHERE status = {:status}
+		GROUP BY source
+	`).Bind(dbx.Params{"status": model.PointStatusSuccess.String()}).All(&rows); err != nil {
+		return err
+	}
+
+	bySource := map[string]PointStat{}
+	for _, row := range rows {
+		bySource[row.Source] = row
+	}
+	for _, source := range ledger.Sources {
+		stat := bySource[source]
+		stat.Source, stat.Name = source, sourceNames[source]
+		summary.Points = append(summary.Points, stat)
+		summary.PointsIssued += stat.Point
+	}
+	// 扣费订单的积分为负数，退款为正数
+	summary.PointsSpent = -(bySource[ledger.SourceDrawFee].Point + bySource[ledger.SourceDrawRefund].Point)
+	return nil
+}
+
+// articleUrlPrefix 摸鱼派帖子链接的前缀
+func articleUrlPrefix(baseUrl string) string {
+	return strings.TrimRight(baseUrl, "/") + "/article/"
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bless-activity/model"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	SessionCookieName = "token"
+	CsrfCookieName    = "csrf_token"
+	CsrfHeaderName    = "X-CSRF-Token"
+
+	sessionTokenLength = 48
+	// 活跃时间的最小更新间隔，避免每个请求都写库
+	sessionTouchInterval = time.Minute
+	maxDeviceLength      = 255
+)
+
+var ErrSessionInvalid = errors.New("会话无效或已过期")
+
+type SessionService struct {
+	app core.App
+}
+
+func NewSessionService(app core.App) *SessionService {
+	return &SessionService{
+		app: app,
+	}
+}
+
+// Duration 会话有效期，与 users 集合的 token 有效期保持一致
+func (service *SessionService) Duration(user *model.User) time.Duration {
+	return user.Collection().AuthToken.DurationTime()
+}
+
+// Create 为用户创建会话，返回只下发一次的明文 token
+func (service *SessionService) Create(user *model.User, device string, ip string) (string, *model.Session, error) {
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameSessions)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := security.RandomString(sessionTokenLength)
+	now := time.Now()
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+
+	session := model.NewSessionFromCollection(collection)
+	session.SetUserId(user.Id)
+	session.SetTokenHash(hashSessionToken(token))
+	session.SetDevice(device)
+	session.SetIp(ip)
+	session.SetLastActiveAt(types.NowDateTime())
+	expiresAt, _ := types.ParseDateTime(now.Add(service.Duration(user)))
+	session.SetExpiresAt(expiresAt)
+	if err = service.app.Save(session); err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// Authenticate 校验 token 对应的会话并返回会话所属用户
+func (service *SessionService) Authenticate(token string, ip string) (*model.Session, *core.Record, error) {
+	if token == "" {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	session := new(model.Session)
+	if err := service.app.RecordQuery(model.DbNameSessions).
+		Where(dbx.HashExp{model.SessionsFieldTokenHash: hashSessionToken(token)}).
+		One(session); err != nil {
+		return nil, nil, ErrSessionInvalid
+	}
+	if session.Revoked() || session.ExpiresAt().Time().Before(time.Now()) {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	user, err := service.app.FindRecordById(model.DbNameUsers, session.UserId())
+	if err != nil {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	if time.Since(session.LastActiveAt().Time()) > sessionTouchInterval || session.Ip() != ip {
+		session.SetLastActiveAt(types.NowDateTime())
+		session.SetIp(ip)
+		if err = service.app.Save(session); err != nil {
+			service.app.Logger().Warn("更新会话活跃时间失败", slog.String("session_id", session.Id), slog.Any("err", err))
+		}
+	}
+
+	return session, user, nil
+}
+
+// Refresh 轮换会话 token 并顺延有效期，旧 token 立即失效
+func (service *SessionService) Refresh(session *model.Session, user *model.User) (string, error) {
+	token := security.RandomString(sessionTokenLength)
+	session.SetTokenHash(hashSessionToken(token))
+	session.SetLastActiveAt(types.NowDateTime())
+	expiresAt, _ := types.ParseDateTime(time.Now().Add(service.Duration(user)))
+	session.SetExpiresAt(expiresAt)
+	if err := service.app.Save(session); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke 注销单个会话
+func (service *SessionService) Revoke(session *model.Session) error {
+	session.SetRevoked(true)
+	return service.app.Save(session)
+}
+
+// RevokeAll 注销用户的所有会话，返回注销数量
+// 同时轮换用户的 tokenKey，使通过 Authorization 请求头登录的 PocketBase token 一并失效
+func (service *SessionService) RevokeAll(userId string) (int, error) {
+	sessions, err := service.List(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = service.app.RunInTransaction(func(txApp core.App) error {
+		for _, session := range sessions {
+			session.SetRevoked(true)
+			if err := txApp.Save(session); err != nil {
+				return err
+			}
+			count++
+		}
+
+		user, err := txApp.FindRecordById(model.DbNameUsers, userId)
+		if err != nil {
+			return err
+		}
+		user.RefreshTokenKey()
+		return txApp.Save(user)
+	})
+	return count, err
+}
+
+// List 返回用户所有有效会话（按最近活跃倒序）
+func (service *SessionService) List(userId string) ([]*model.Session, error) {
+	sessions := []*model.Session{}
+	err := service.app.RecordQuery(model.DbNameSessions).
+		Where(dbx.HashExp{
+			model.SessionsFieldUserId:  userId,
+			model.SessionsFieldRevoked: false,
+		}).
+		AndWhere(dbx.NewExp(model.SessionsFieldExpiresAt+" > {:now}", dbx.Params{"now": types.NowDateTime().String()})).
+		OrderBy(model.SessionsFieldLastActiveAt + " desc").
+		All(&sessions)
+	return sessions, err
+}
+
+// CsrfToken 由会话 token 派生 CSRF token，前端从非 HttpOnly 的 cookie 读取后放入请求头
+func CsrfToken(token string) string {
+	sum := sha256.Sum256([]byte("csrf:" + token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// VerifyCsrf 校验请求头中的 CSRF token 是否由会话 token 派生
+func VerifyCsrf(token string, actual string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(actual), []byte(CsrfToken(token))) == 1
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
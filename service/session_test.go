@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestSessionService_Lifecycle(t *testing.T) {
+	app := testapp.New(t)
+	service := NewSessionService(app)
+	user := testapp.User(t, app, "1001", "alice")
+
+	token, session, err := service.Create(user, "Mozilla/5.0", "1.1.1.1")
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+	if session.TokenHash() == token {
+		t.Error("会话只应保存 token 的摘要")
+	}
+
+	got, record, err := service.Authenticate(token, "2.2.2.2")
+	if err != nil {
+		t.Fatalf("校验会话失败: %v", err)
+	}
+	if got.Id != session.Id || record.Id != user.Id {
+		t.Errorf("会话或用户不一致: %s %s", got.Id, record.Id)
+	}
+	if got.Ip() != "2.2.2.2" {
+		t.Errorf("IP 变化时应更新会话, 得到 %s", got.Ip())
+	}
+	if _, _, err = service.Authenticate("unknown", ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("未知 token 应无效, 得到 %v", err)
+	}
+
+	// 刷新后旧 token 立即失效
+	refreshed, err := service.Refresh(got, user)
+	if err != nil {
+		t.Fatalf("刷新会话失败: %v", err)
+	}
+	if _, _, err = service.Authenticate(token, ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("刷新后旧 token 应无效, 得到 %v", err)
+	}
+	if _, _, err = service.Authenticate(refreshed, ""); err != nil {
+		t.Errorf("刷新后新 token 应有效, 得到 %v", err)
+	}
+
+	// 注销单个会话
+	if err = service.Revoke(got); err != nil {
+		t.Fatalf("注销会话失败: %v", err)
+	}
+	if _, _, err = service.Authenticate(refreshed, ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("注销后 token 应无效, 得到 %v", err)
+	}
+
+	// 过期的会话无效
+	expired, session, err := service.Create(user, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt, _ := types.ParseDateTime(time.Now().Add(-time.Minute))
+	session.SetExpiresAt(expiresAt)
+	if err = app.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = service.Authenticate(expired, ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("过期的会话应无效, 得到 %v", err)
+	}
+}
+
+func TestSessionService_RevokeAll(t *testing.T) {
+	app := testapp.New(t)
+	service := NewSessionService(app)
+	user := testapp.User(t, app, "1001", "alice")
+	other := testapp.User(t, app, "1002", "bob")
+
+	tokens := []string{}
+	for range 3 {
+		token, _, err := service.Create(user, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	otherToken, _, err := service.Create(other, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := service.RevokeAll(user.Id)
+	if err != nil {
+		t.Fatalf("注销所有会话失败: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("期望注销 3 个会话, 实际 %d 个", count)
+	}
+	for _, token := range tokens {
+		if _, _, err = service.Authenticate(token, ""); !errors.Is(err, ErrSessionInvalid) {
+			t.Errorf("注销后 token 应无效, 得到 %v", err)
+		}
+	}
+	if _, _, err = service.Authenticate(otherToken, ""); err != nil {
+		t.Errorf("其它用户的会话不受影响, 得到 %v", err)
+	}
+
+	// 通过 Authorization 请求头使用的 PocketBase token 同时失效
+	if _, err = app.FindAuthRecordByToken(jwt, core.TokenTypeAuth); err == nil {
+		t.Error("注销所有设备后 PocketBase token 应失效")
+	}
+	if sessions, _ := service.List(user.Id); len(sessions) != 0 {
+		t.Errorf("注销后不应有有效会话, 剩余 %d 个", len(sessions))
+	}
+}
+
+func TestVerifyCsrf(t *testing.T) {
+	token := "session-token"
+	if !VerifyCsrf(token, CsrfToken(token)) {
+		t.Error("由会话 token 派生的 CSRF token 应校验通过")
+	}
+	for name, actual := range map[string]string{
+		"缺少请求头":       "",
+		"其它会话的 token": CsrfToken("other-token"),
+		"会话 token 本身": token,
+	} {
+		if VerifyCsrf(token, actual) {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+	if VerifyCsrf("", CsrfToken("")) {
+		t.Error("没有会话时应校验失败")
+	}
+}
@@ -0,0 +1,93 @@
+package settlement
+
+import (
+	"bless-activity/service/mooncakeGambling"
+	"sort"
+	"time"
+)
+
+// Candidate 一条状元级别的博饼记录
+type Candidate struct {
+	HistoryId string
+	UserId    string
+	RewardId  string
+	Times     int
+	Created   time.Time
+	Result    mooncakeGambling.GameResult
+}
+
+// better 结果更好的排前面，结果相同时先博出的排前面
+func better(a, b Candidate) bool {
+	if compare := mooncakeGambling.CompareGameResult(a.Result, b.Result); compare != 0 {
+		return compare > 0
+	}
+	if !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	return a.HistoryId < b.HistoryId
+}
+
+// Allocation 结算分配结果
+type Allocation struct {
+	// Bests 每位用户最好的一条记录，按结果从好到坏排列
+	Bests []Candidate
+	// Winners 按名次排列的获奖记录
+	Winners []Candidate
+}
+
+// IsWinner 判断记录是否获奖
+func (allocation Allocation) IsWinner(historyId string) bool {
+	for _, winner := range allocation.Winners {
+		if winner.HistoryId == historyId {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBest 判断记录是否为用户最好的一条
+func (allocation Allocation) IsBest(historyId string) bool {
+	for _, best := range allocation.Bests {
+		if best.HistoryId == historyId {
+			return true
+		}
+	}
+	return false
+}
+
+// Allocate 每位用户只取最好的一条记录参与排名，按全局名次依次占用对应奖励的库存，库存用完后不再获奖
+func Allocate(candidates []Candidate, stock map[string]int) Allocation {
+	bestByUser := map[string]Candidate{}
+	for _, candidate := range candidates {
+		if current, ok := bestByUser[candidate.UserId]; !ok || better(candidate, current) {
+			bestByUser[candidate.UserId] = candidate
+		}
+	}
+
+	bests := make([]Candidate, 0, len(bestByUser))
+	for _, candidate := range bestByUser {
+		bests = append(bests, candidate)
+	}
+	sort.Slice(bests, func(i, j int) bool {
+		return better(bests[i], bests[j])
+	})
+
+	remaining := map[string]int{}
+	for rewardId, amount := range stock {
+		remaining[rewardId] = amount
+	}
+
+	winners := []Candidate{}
+	for _, candidate := range bests {
+		if remaining[candidate.RewardId] <= 0 {
+			continue
+		}
+		remaining[candidate.RewardId]--
+		winners = append(winners, candidate)
+	}
+
+	return Allocation{
+		Bests:   bests,
+		Winners: winners,
+	}
+}
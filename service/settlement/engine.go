@@ -0,0 +1,306 @@
+package settlement
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"bless-activity/service/mooncakeGambling"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SigningKeyEnv 结算报告签名密钥的环境变量
+const SigningKeyEnv = "SETTLEMENT_SIGNING_KEY"
+
+var (
+	ErrSigningKeyMissing = errors.New("未配置结算报告签名密钥 " + SigningKeyEnv)
+	ErrRunning           = errors.New("结算正在执行")
+)
+
+// Engine 状元结算：按最终排名修正 isBest/gotReward，为获奖记录创建积分订单并生成签名报告
+// 每次执行都基于当前数据重新计算，已发放的积分订单不会重复创建，可重复执行
+type Engine struct {
+	app              core.App
+	ledgerService    *ledger.Service
+	inventoryService *inventory.Service
+	game             *mooncakeGambling.MooncakeGame
+	key              []byte
+	logger           *slog.Logger
+
+	running sync.Mutex
+}
+
+func NewEngine(app core.App, ledgerService *ledger.Service, inventoryService *inventory.Service, key []byte) *Engine {
+	return &Engine{
+		app:              app,
+		ledgerService:    ledgerService,
+		inventoryService: inventoryService,
+		game:             mooncakeGambling.NewMooncakeGame(),
+		key:              key,
+		logger:           app.Logger().WithGroup("settlement"),
+	}
+}
+
+// Run 执行一次结算并保存报告
+func (engine *Engine) Run() (*model.Settlement, error) {
+	if len(engine.key) == 0 {
+		return nil, ErrSigningKeyMissing
+	}
+	if !engine.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer engine.running.Unlock()
+
+	histories := []*model.Histories{}
+	if err := engine.app.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{model.HistoriesFieldIsTop: true}).
+		OrderBy(model.HistoriesFieldCreated + " asc").
+		All(&histories); err != nil {
+		return nil, fmt.Errorf("查找状元记录失败: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(histories))
+	historyById := map[string]*model.Histories{}
+	for _, history := range histories {
+		historyById[history.Id] = history
+		candidates = append(candidates, Candidate{
+			HistoryId: history.Id,
+			UserId:    history.UserId(),
+			RewardId:  history.RewardId(),
+			Times:     history.Times(),
+			Created:   history.Created().Time(),
+			Result:    engine.game.PlayWithDices(history.Details()),
+		})
+	}
+
+	rewards, stock, err := engine.loadStock(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	allocation := Allocate(candidates, stock)
+
+	report := &Report{
+		GeneratedAt: time.Now().UTC(),
+		Candidates:  len(candidates),
+		Stock:       stock,
+		Winners:     []ReportWinner{},
+		Changes:     []Change{},
+		Payouts:     []Payout{},
+	}
+	for i, winner := range allocation.Winners {
+		report.Winners = append(report.Winners, ReportWinner{
+			Rank:       i + 1,
+			HistoryId:  winner.HistoryId,
+			UserId:     winner.UserId,
+			RewardId:   winner.RewardId,
+			Times:      winner.Times,
+			Dices:      winner.Result.Dices,
+			PrizeLevel: int(winner.Result.PrizeLevel),
+			PrizeName:  winner.Result.PrizeName,
+		})
+	}
+
+	// 1. 修正 isBest 与 gotReward，同步调整奖品预留：先释放落选的记录，再为新的获奖记录预留
+	err = engine.app.RunInTransaction(func(txApp core.App) error {
+		changed := []*model.Histories{}
+		for _, history := range histories {
+			isBest := allocation.IsBest(history.Id)
+			gotReward := allocation.IsWinner(history.Id)
+			if history.IsBest() == isBest && history.GotReward() == gotReward {
+				continue
+			}
+
+			report.Changes = append(report.Changes, Change{
+				HistoryId: history.Id,
+				IsBest:    [2]bool{history.IsBest(), isBest},
+				GotReward: [2]bool{history.GotReward(), gotReward},
+			})
+			if history.GotReward() && !gotReward {
+				if _, err := engine.inventoryService.Release(txApp, history.Id, "状元结算未获奖"); err != nil {
+					return err
+				}
+			}
+			history.SetIsBest(isBest)
+			history.SetGotReward(gotReward)
+			changed = append(changed, history)
+		}
+		for _, history := range changed {
+			if history.GotReward() {
+				if _, err := engine.inventoryService.ReserveSettled(txApp, history.RewardId(), history.Id); err != nil {
+					return fmt.Errorf("预留奖品失败 %s: %w", history.Id, err)
+				}
+			}
+			if err := txApp.Save(history); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("修正博饼记录失败: %w", err)
+	}
+
+	// 2. 为获奖记录发放积分
+	for _, winner := range allocation.Winners {
+		payout, err := engine.pay(historyById[winner.HistoryId], rewards[winner.RewardId], winner.Result)
+		if err != nil {
+			return nil, err
+		}
+		if payout != nil {
+			report.Payouts = append(report.Payouts, *payout)
+		}
+	}
+
+	// 3. 与上一次结算比较并保存签名报告
+	previous, err := engine.Latest()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("查找上一次结算失败: %w", err)
+	}
+	previousRunNo, previousWinners := 0, []string{}
+	if previous != nil {
+		previousReport := new(Report)
+		if err = previous.UnmarshalJSONField(model.SettlementsFieldReport, previousReport); err != nil {
+			return nil, fmt.Errorf("解析上一次结算报告失败: %w", err)
+		}
+		previousRunNo, previousWinners = previous.RunNo(), previousReport.WinnerIds()
+	}
+	report.RunNo = previousRunNo + 1
+	report.Diff = NewDiff(previousRunNo, previousWinners, report.WinnerIds())
+	if len(report.Diff.Removed) > 0 {
+		engine.logger.Warn("上一次结算的获奖记录本次未获奖，已发放的积分需人工处理", slog.Any("history_ids", report.Diff.Removed))
+	}
+
+	data, digest, signature, err := Sign(engine.key, report)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := engine.app.FindCollectionByNameOrId(model.DbNameSettlements)
+	if err != nil {
+		return nil, err
+	}
+	settlement := model.NewSettlementFromCollection(collection)
+	settlement.SetRunNo(report.RunNo)
+	settlement.SetWinners(len(report.Winners))
+	settlement.SetDigest(digest)
+	settlement.SetSignature(signature)
+	settlement.SetReport(string(data))
+	if err = engine.app.Save(settlement); err != nil {
+		return nil, fmt.Errorf("保存结算报告失败: %w", err)
+	}
+
+	engine.logger.Info("状元结算完成",
+		slog.Int("run_no", report.RunNo),
+		slog.Int("candidates", report.Candidates),
+		slog.Int("winners", len(report.Winners)),
+		slog.Int("changes", len(report.Changes)),
+		slog.Int("payouts", len(report.Payouts)))
+	return settlement, nil
+}
+
+// Latest 最近一次结算
+func (engine *Engine) Latest() (*model.Settlement, error) {
+	settlement := new(model.Settlement)
+	if err := engine.app.RecordQuery(model.DbNameSettlements).
+		OrderBy(model.SettlementsFieldRunNo + " desc").
+		Limit(1).
+		One(settlement); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// Verify 校验结算报告未被篡改
+func (engine *Engine) Verify(settlement *model.Settlement) bool {
+	data := []byte(settlement.Report())
+	return len(engine.key) > 0 && Digest(data) == settlement.Digest() && Verify(engine.key, data, settlement.Signature())
+}
+
+// loadStock 计算各状元奖励的剩余库存：总量减去非状元记录已占用的数量，已下架的奖励不超过已获得的数量
+func (engine *Engine) loadStock(candidates []Candidate) (map[string]*model.Reward, map[string]int, error) {
+	rewards := map[string]*model.Reward{}
+	stock := map[string]int{}
+	for _, candidate := range candidates {
+		if _, ok := rewards[candidate.RewardId]; ok || candidate.RewardId == "" {
+			continue
+		}
+
+		reward := new(model.Reward)
+		if err := engine.app.RecordQuery(model.DbNameRewards).Where(dbx.HashExp{model.CommonFieldId: candidate.RewardId}).One(reward); err != nil {
+			return nil, nil, fmt.Errorf("查找奖励失败 %s: %w", candidate.RewardId, err)
+		}
+		used, err := engine.app.CountRecords(model.DbNameHistories, dbx.HashExp{
+			model.HistoriesFieldRewardId:  reward.Id,
+			model.HistoriesFieldGotReward: true,
+			model.HistoriesFieldIsTop:     false,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("查询奖励已发放数量失败 %s: %w", reward.Id, err)
+		}
+
+		rewards[reward.Id] = reward
+		stock[reward.Id] = max(0, reward.Amount()-int(used))
+
+		// 已下架的奖励不再增加获奖记录，库存不超过状元记录当前已获得的数量
+		if reward.Retired() {
+			held, err := engine.app.CountRecords(model.DbNameHistories, dbx.HashExp{
+				model.HistoriesFieldRewardId:  reward.Id,
+				model.HistoriesFieldGotReward: true,
+				model.HistoriesFieldIsTop:     true,
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("查询奖励已发放数量失败 %s: %w", reward.Id, err)
+			}
+			stock[reward.Id] = min(stock[reward.Id], int(held))
+		}
+	}
+	return rewards, stock, nil
+}
+
+// pay 为获奖记录创建积分订单并发放，已有订单或不是积分奖品时跳过
+func (engine *Engine) pay(history *model.Histories, reward *model.Reward, result mooncakeGambling.GameResult) (*Payout, error) {
+	if reward == nil || reward.Kind() != model.RewardKindPoints || reward.Point() <= 0 {
+		return nil, nil
+	}
+
+	// 已有该记录的订单时 Create 返回已有订单，不重复发放
+	user := new(model.User)
+	if err := engine.app.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.CommonFieldId: history.UserId()}).One(user); err != nil {
+		return nil, fmt.Errorf("查找用户失败 %s: %w", history.UserId(), err)
+	}
+
+	pointsRecord, created, err := engine.ledgerService.Create(ledger.Order{
+		Key:       ledger.HistoryKey(history.Id),
+		Source:    ledger.SourceSettlement,
+		UserId:    user.Id,
+		HistoryId: history.Id,
+		Point:     reward.Point(),
+		Memo:      fmt.Sprintf("活动《双节同庆·福签传情》状元结算：第%d次博饼：%s(%s)", history.Times(), result.PrizeName, reward.Name()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, nil
+	}
+	if err = engine.ledgerService.Distribute(pointsRecord, user.Name()); err != nil {
+		engine.logger.Error("发放积分失败", slog.String("user", user.Name()), slog.Any("err", err))
+	}
+
+	return &Payout{
+		HistoryId: history.Id,
+		PointsId:  pointsRecord.Id,
+		UserId:    user.Id,
+		Point:     reward.Point(),
+		Status:    pointsRecord.Status().String(),
+		Error:     pointsRecord.Error(),
+	}, nil
+}
@@ -0,0 +1,191 @@
+package settlement
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/inventory"
+	"bless-activity/service/ledger"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestEngine 使用模拟摸鱼派接口发放积分的结算引擎
+func newTestEngine(t *testing.T, app core.App) (*Engine, *inventory.Service, *testapp.Fishpi) {
+	t.Helper()
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app,
+		testapp.FishpiDefinition(),
+		config.Definition{Key: model.ConfigKeyGuard, Default: func() any { return &ledger.GuardConfig{} }},
+	)
+	inventoryService := inventory.NewService(app)
+	ledgerService := ledger.NewService(app, fake.Service(t, app, registry), registry)
+	return NewEngine(app, ledgerService, inventoryService, []byte("test-key")), inventoryService, fake
+}
+
+// topHistory 创建一条状元记录，gotReward 为 true 时按博饼接口的方式预留奖品
+func topHistory(t *testing.T, app core.App, inventoryService *inventory.Service, user *model.User, reward *model.Reward, dices [6]int, gotReward bool) *model.Histories {
+	t.Helper()
+	history := testapp.History(t, app, user, reward, 1)
+	history.SetIsTop(true)
+	history.SetDetails(dices)
+	history.SetIsBest(true)
+	history.SetGotReward(gotReward)
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if gotReward {
+			if _, err := inventoryService.ReserveSettled(txApp, reward.Id, history.Id); err != nil {
+				return err
+			}
+		}
+		return txApp.Save(history)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return history
+}
+
+// runReport 执行一次结算并解析报告
+func runReport(t *testing.T, engine *Engine) (*model.Settlement, *Report) {
+	t.Helper()
+	settlement, err := engine.Run()
+	if err != nil {
+		t.Fatalf("结算失败: %v", err)
+	}
+	if !engine.Verify(settlement) {
+		t.Error("结算报告签名校验失败")
+	}
+	report := new(Report)
+	if err = settlement.UnmarshalJSONField(model.SettlementsFieldReport, report); err != nil {
+		t.Fatal(err)
+	}
+	return settlement, report
+}
+
+// changedIds 报告中修正过的记录
+func changedIds(report *Report) []string {
+	ids := []string{}
+	for _, change := range report.Changes {
+		ids = append(ids, change.HistoryId)
+	}
+	return ids
+}
+
+// heldIds 奖品当前预留中的获奖记录与预留数量
+func heldIds(t *testing.T, app core.App, reward *model.Reward) ([]string, int) {
+	t.Helper()
+	reservations := []*model.Reservation{}
+	if err := app.RecordQuery(model.DbNameReservations).
+		Where(dbx.HashExp{
+			model.ReservationsFieldRewardId: reward.Id,
+			model.ReservationsFieldStatus:   model.ReservationStatusHeld.String(),
+		}).
+		All(&reservations); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, reservation := range reservations {
+		ids = append(ids, reservation.HistoryId())
+	}
+	fresh, err := app.FindRecordById(model.DbNameRewards, reward.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids, fresh.GetInt(model.RewardsFieldReserved)
+}
+
+func countOrders(t *testing.T, app core.App, historyId string) int64 {
+	t.Helper()
+	count, err := app.CountRecords(model.DbNamePoints, dbx.HashExp{model.PointsFieldHistoryId: historyId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestEngine_RunTwice(t *testing.T) {
+	app := testapp.New(t)
+	engine, inventoryService, fake := newTestEngine(t, app)
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+	carol := testapp.User(t, app, "1003", "carol")
+
+	// 只有一份状元奖励
+	reward := testapp.Reward(t, app, "状元积分", 1)
+	reward.SetKind(model.RewardKindPoints)
+	reward.SetPoint(100)
+	if err := app.Save(reward); err != nil {
+		t.Fatal(err)
+	}
+
+	// 博饼时 alice 先获得奖励，bob 之后博出更好的状元但奖励已发完
+	first := topHistory(t, app, inventoryService, alice, reward, [6]int{4, 4, 4, 4, 1, 2}, true)
+	second := topHistory(t, app, inventoryService, bob, reward, [6]int{4, 4, 4, 4, 5, 6}, false)
+
+	// 第一次结算：bob 获奖，释放 alice 的预留
+	settlement, report := runReport(t, engine)
+	if settlement.RunNo() != 1 || !reflect.DeepEqual(report.WinnerIds(), []string{second.Id}) {
+		t.Fatalf("第一次结算 run_no = %d, winners = %v", settlement.RunNo(), report.WinnerIds())
+	}
+	if got := changedIds(report); len(got) != 2 {
+		t.Errorf("第一次结算修正 %v, 期望 alice 与 bob", got)
+	}
+	if len(report.Payouts) != 1 || report.Payouts[0].HistoryId != second.Id {
+		t.Errorf("第一次结算发放 = %+v", report.Payouts)
+	}
+	if held, reserved := heldIds(t, app, reward); !reflect.DeepEqual(held, []string{second.Id}) || reserved != 1 {
+		t.Errorf("第一次结算后预留 = %v, reserved = %d", held, reserved)
+	}
+
+	// 数据不变时重复结算：没有修正，不重复发放
+	settlement, report = runReport(t, engine)
+	if settlement.RunNo() != 2 || len(report.Changes) != 0 || len(report.Payouts) != 0 {
+		t.Errorf("重复结算 run_no = %d, changes = %v, payouts = %v", settlement.RunNo(), report.Changes, report.Payouts)
+	}
+	if report.Diff.PreviousRunNo != 1 || len(report.Diff.Added) != 0 || len(report.Diff.Removed) != 0 {
+		t.Errorf("重复结算 diff = %+v", report.Diff)
+	}
+	if count := countOrders(t, app, second.Id); count != 1 {
+		t.Errorf("bob 的积分订单 %d 条, 期望 1 条", count)
+	}
+	if fake.Credited() != 100 {
+		t.Errorf("到账积分 %d, 期望 100", fake.Credited())
+	}
+
+	// carol 博出更好的状元后再次结算：只修正 bob 与 carol，预留随之转移
+	third := topHistory(t, app, inventoryService, carol, reward, [6]int{4, 4, 4, 4, 4, 1}, false)
+	settlement, report = runReport(t, engine)
+	if settlement.RunNo() != 3 || !reflect.DeepEqual(report.WinnerIds(), []string{third.Id}) {
+		t.Fatalf("第三次结算 run_no = %d, winners = %v", settlement.RunNo(), report.WinnerIds())
+	}
+	if got := changedIds(report); len(got) != 2 || (got[0] != second.Id && got[1] != second.Id) || (got[0] != third.Id && got[1] != third.Id) {
+		t.Errorf("第三次结算修正 %v, 期望只有 bob 与 carol", got)
+	}
+	if !reflect.DeepEqual(report.Diff.Added, []string{third.Id}) || !reflect.DeepEqual(report.Diff.Removed, []string{second.Id}) {
+		t.Errorf("第三次结算 diff = %+v", report.Diff)
+	}
+	if held, reserved := heldIds(t, app, reward); !reflect.DeepEqual(held, []string{third.Id}) || reserved != 1 {
+		t.Errorf("第三次结算后预留 = %v, reserved = %d", held, reserved)
+	}
+	for _, history := range []*model.Histories{first, second, third} {
+		if count := countOrders(t, app, history.Id); count > 1 {
+			t.Errorf("%s 的积分订单 %d 条", history.Id, count)
+		}
+	}
+	if count, _ := app.CountRecords(model.DbNamePoints); count != 2 {
+		t.Errorf("积分订单共 %d 条, 期望 bob 与 carol 各一条", count)
+	}
+}
+
+func TestEngine_RunWithoutKey(t *testing.T) {
+	app := testapp.New(t)
+	engine, _, _ := newTestEngine(t, app)
+	engine.key = nil
+	if _, err := engine.Run(); !errors.Is(err, ErrSigningKeyMissing) {
+		t.Errorf("未配置签名密钥时应返回 ErrSigningKeyMissing, 得到 %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package settlement
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Report 结算报告，签名覆盖报告的全部内容
+type Report struct {
+	RunNo       int            `json:"runNo"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Candidates  int            `json:"candidates"`
+	Stock       map[string]int `json:"stock"`
+	Winners     []ReportWinner `json:"winners"`
+	Changes     []Change       `json:"changes"`
+	Payouts     []Payout       `json:"payouts"`
+	Diff        Diff           `json:"diff"`
+}
+
+// ReportWinner 获奖记录
+type ReportWinner struct {
+	Rank       int    `json:"rank"`
+	HistoryId  string `json:"historyId"`
+	UserId     string `json:"userId"`
+	RewardId   string `json:"rewardId"`
+	Times      int    `json:"times"`
+	Dices      [6]int `json:"dices"`
+	PrizeLevel int    `json:"prizeLevel"`
+	PrizeName  string `json:"prizeName"`
+}
+
+// Change 本次结算修正的博饼记录
+type Change struct {
+	HistoryId string  `json:"historyId"`
+	IsBest    [2]bool `json:"isBest"`    // 修改前、修改后
+	GotReward [2]bool `json:"gotReward"` // 修改前、修改后
+}
+
+// Payout 本次结算创建的积分订单
+type Payout struct {
+	HistoryId string `json:"historyId"`
+	PointsId  string `json:"pointsId"`
+	UserId    string `json:"userId"`
+	Point     int    `json:"point"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Diff 与上一次结算相比获奖名单的变化
+type Diff struct {
+	PreviousRunNo int      `json:"previousRunNo"`
+	Added         []string `json:"added"`
+	Removed       []string `json:"removed"`
+}
+
+// NewDiff 比较两次结算的获奖记录 id
+func NewDiff(previousRunNo int, previous []string, current []string) Diff {
+	diff := Diff{PreviousRunNo: previousRunNo, Added: []string{}, Removed: []string{}}
+
+	previousSet := map[string]bool{}
+	for _, id := range previous {
+		previousSet[id] = true
+	}
+	currentSet := map[string]bool{}
+	for _, id := range current {
+		currentSet[id] = true
+		if !previousSet[id] {
+			diff.Added = append(diff.Added, id)
+		}
+	}
+	for _, id := range previous {
+		if !currentSet[id] {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	return diff
+}
+
+// WinnerIds 获奖记录 id 列表
+func (report *Report) WinnerIds() []string {
+	ids := make([]string, 0, len(report.Winners))
+	for _, winner := range report.Winners {
+		ids = append(ids, winner.HistoryId)
+	}
+	return ids
+}
+
+// Sign 序列化报告并计算摘要与 HMAC-SHA256 签名
+func Sign(key []byte, report *Report) (data []byte, digest string, signature string, err error) {
+	if data, err = json.Marshal(report); err != nil {
+		return nil, "", "", err
+	}
+	return data, Digest(data), signBytes(key, data), nil
+}
+
+// Verify 校验报告原文的签名
+func Verify(key []byte, data []byte, signature string) bool {
+	return hmac.Equal([]byte(signBytes(key, data)), []byte(signature))
+}
+
+// Digest 报告原文的 SHA-256 摘要
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func signBytes(key []byte, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,101 @@
+package settlement
+
+import (
+	"bless-activity/service/mooncakeGambling"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func candidate(historyId string, userId string, rewardId string, minute int, dices [6]int) Candidate {
+	return Candidate{
+		HistoryId: historyId,
+		UserId:    userId,
+		RewardId:  rewardId,
+		Created:   time.Date(2025, 10, 1, 12, minute, 0, 0, time.UTC),
+		Result:    mooncakeGambling.NewMooncakeGame().PlayWithDices(dices),
+	}
+}
+
+func historyIds(candidates []Candidate) []string {
+	ids := []string{}
+	for _, c := range candidates {
+		ids = append(ids, c.HistoryId)
+	}
+	return ids
+}
+
+func TestAllocate(t *testing.T) {
+	candidates := []Candidate{
+		candidate("a1", "alice", "r1", 1, [6]int{4, 4, 4, 4, 1, 2}), // 状元四点红
+		candidate("a2", "alice", "r2", 2, [6]int{4, 4, 4, 4, 4, 1}), // 状元五红，alice 最好的一条
+		candidate("b1", "bob", "r2", 3, [6]int{4, 4, 4, 4, 4, 2}),   // 状元五红，多余骰子更大
+		candidate("c1", "carol", "r1", 4, [6]int{4, 4, 4, 4, 3, 3}), // 状元四点红
+		candidate("d1", "dave", "r1", 0, [6]int{4, 4, 4, 4, 3, 3}),  // 与 carol 相同，但更早博出
+	}
+	stock := map[string]int{"r1": 1, "r2": 1}
+
+	allocation := Allocate(candidates, stock)
+
+	if got, want := historyIds(allocation.Bests), []string{"b1", "a2", "d1", "c1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bests = %v, 期望 %v", got, want)
+	}
+	if got, want := historyIds(allocation.Winners), []string{"b1", "d1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Winners = %v, 期望 %v", got, want)
+	}
+	if allocation.IsBest("a1") || !allocation.IsBest("a2") {
+		t.Error("每位用户只有最好的一条记录为 isBest")
+	}
+	if allocation.IsWinner("a2") {
+		t.Error("库存不足时不应获奖")
+	}
+	if stock["r1"] != 1 || stock["r2"] != 1 {
+		t.Error("Allocate 不应修改传入的库存")
+	}
+
+	// 输入顺序不影响结果
+	reversed := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		reversed[len(candidates)-1-i] = c
+	}
+	if again := Allocate(reversed, stock); !reflect.DeepEqual(historyIds(again.Winners), historyIds(allocation.Winners)) {
+		t.Errorf("重复结算结果不一致: %v", historyIds(again.Winners))
+	}
+}
+
+func TestNewDiff(t *testing.T) {
+	diff := NewDiff(3, []string{"a", "b"}, []string{"b", "c"})
+	if diff.PreviousRunNo != 3 || !reflect.DeepEqual(diff.Added, []string{"c"}) || !reflect.DeepEqual(diff.Removed, []string{"a"}) {
+		t.Errorf("NewDiff() = %+v", diff)
+	}
+
+	empty := NewDiff(0, nil, []string{"a"})
+	if !reflect.DeepEqual(empty.Added, []string{"a"}) || len(empty.Removed) != 0 {
+		t.Errorf("首次结算 NewDiff() = %+v", empty)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key := []byte("secret")
+	report := &Report{RunNo: 1, Winners: []ReportWinner{{Rank: 1, HistoryId: "h1"}}}
+
+	data, digest, signature, err := Sign(key, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Digest(data) != digest {
+		t.Error("摘要不一致")
+	}
+	if !Verify(key, data, signature) {
+		t.Error("签名校验失败")
+	}
+	if Verify([]byte("other"), data, signature) {
+		t.Error("错误的密钥不应通过校验")
+	}
+
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-2] = ' '
+	if Verify(key, tampered, signature) {
+		t.Error("被篡改的报告不应通过校验")
+	}
+}
@@ -0,0 +1,98 @@
+package simulator
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// FakeFishpi 模拟摸鱼派接口：记录积分发放、接收聊天室消息，可按比例返回失败
+type FakeFishpi struct {
+	server   *httptest.Server
+	failRate float64
+
+	mutex    sync.Mutex
+	points   map[string]int
+	payouts  int
+	failures int
+	messages int
+}
+
+func NewFakeFishpi(failRate float64) *FakeFishpi {
+	fake := &FakeFishpi{
+		failRate: failRate,
+		points:   map[string]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /user/edit/points", fake.editPoints)
+	mux.HandleFunc("POST /chat-room/send", fake.chatroomSend)
+	fake.server = httptest.NewServer(mux)
+
+	return fake
+}
+
+// URL 模拟接口地址，写入 fishpi 配置的 base_url
+func (fake *FakeFishpi) URL() string {
+	return fake.server.URL
+}
+
+func (fake *FakeFishpi) Close() {
+	fake.server.Close()
+}
+
+// Totals 成功发放的积分订单数量、积分总数与失败次数
+func (fake *FakeFishpi) Totals() (payouts int, points int, failures int) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	for _, point := range fake.points {
+		points += point
+	}
+	return fake.payouts, points, fake.failures
+}
+
+// Messages 收到的聊天室消息数量
+func (fake *FakeFishpi) Messages() int {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.messages
+}
+
+func (fake *FakeFishpi) editPoints(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		UserName string `json:"userName"`
+		Point    int    `json:"point"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeJSON(w, map[string]any{"code": -1, "msg": "请求参数错误"})
+		return
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if fake.failRate > 0 && rand.Float64() < fake.failRate {
+		fake.failures++
+		writeJSON(w, map[string]any{"code": -1, "msg": "模拟发放失败"})
+		return
+	}
+	fake.payouts++
+	fake.points[data.UserName] += data.Point
+	writeJSON(w, map[string]any{"code": 0, "msg": ""})
+}
+
+func (fake *FakeFishpi) chatroomSend(w http.ResponseWriter, r *http.Request) {
+	fake.mutex.Lock()
+	fake.messages++
+	fake.mutex.Unlock()
+
+	writeJSON(w, map[string]any{"code": 0, "msg": ""})
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,344 @@
+package simulator
+
+import (
+	"bless-activity/model"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 数据一致性检查项
+const (
+	InvariantOverQuotaDraw   = "over_quota_draw"  // 博饼次数超过额度
+	InvariantOverIssued      = "over_issued"      // 奖励发放数量超过库存
+	InvariantMultipleBest    = "multiple_best"    // 同一用户有多条 isBest 记录
+	InvariantOverQuotaVote   = "over_quota_vote"  // 福签数量超过上限或同类型重复
+	InvariantDuplicatePayout = "duplicate_payout" // 同一博饼记录有多条积分订单
+	InvariantPayoutMismatch  = "payout_mismatch"  // 发放成功的积分与摸鱼派收到的不一致
+	InvariantLedgerMismatch  = "ledger_mismatch"  // 账本借贷不平衡或用户收入与发放成功的积分不一致
+	InvariantStockMismatch   = "stock_mismatch"   // 奖品预留计数、预留记录与获奖记录数量不一致
+)
+
+// maxVotesPerUser 每位用户可赠送的福签上限，与投票接口保持一致
+const maxVotesPerUser = 3
+
+// Report 模拟报告
+type Report struct {
+	Seed       uint64        `json:"seed"`
+	Users      int           `json:"users"`
+	Duration   time.Duration `json:"duration"`
+	Latencies  []Latency     `json:"latencies"`
+	Rewards    []RewardUsage `json:"rewards"`
+	Payouts    PayoutTotals  `json:"payouts"`
+	Violations []Violation   `json:"violations"`
+}
+
+// RewardUsage 奖励发放情况，ExhaustedAfter 为模拟开始到库存发完的时间，未发完时为 -1
+// Reserved 为奖品的预留计数，Held 为有效的预留记录数量，两者都应与 Issued 一致
+type RewardUsage struct {
+	Name           string        `json:"name"`
+	Point          int           `json:"point"`
+	Amount         int           `json:"amount"`
+	Issued         int           `json:"issued"`
+	Reserved       int           `json:"reserved"`
+	Held           int           `json:"held"`
+	ExhaustedAfter time.Duration `json:"exhaustedAfter"`
+}
+
+// PayoutTotals 积分订单与摸鱼派收到的发放汇总
+type PayoutTotals struct {
+	Orders         map[string]int `json:"orders"` // 各状态的订单数量
+	Points         map[string]int `json:"points"` // 各状态的积分总数
+	FishpiPayouts  int            `json:"fishpiPayouts"`
+	FishpiPoints   int            `json:"fishpiPoints"`
+	FishpiFailures int            `json:"fishpiFailures"`
+	ChatMessages   int            `json:"chatMessages"`
+}
+
+// Violation 违反的一致性检查
+type Violation struct {
+	Invariant string `json:"invariant"`
+	Detail    string `json:"detail"`
+}
+
+func (simulator *Simulator) report(start time.Time, duration time.Duration, recorder *recorder, fishpi *FakeFishpi) (*Report, error) {
+	report := &Report{
+		Seed:       simulator.options.Seed,
+		Users:      len(simulator.users),
+		Duration:   duration,
+		Latencies:  recorder.latencies(),
+		Violations: []Violation{},
+	}
+
+	var err error
+	if report.Rewards, err = simulator.rewardUsage(start); err != nil {
+		return nil, err
+	}
+	if report.Payouts, err = simulator.payoutTotals(fishpi); err != nil {
+		return nil, err
+	}
+	if err = simulator.checkInvariants(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (simulator *Simulator) rewardUsage(start time.Time) ([]RewardUsage, error) {
+	rewards := []*model.Reward{}
+	if err := simulator.app.RecordQuery(model.DbNameRewards).OrderBy(model.RewardsFieldLevel + " asc").All(&rewards); err != nil {
+		return nil, err
+	}
+
+	var issued []struct {
+		RewardId string `db:"rewardId"`
+		Created  string `db:"created"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`SELECT rewardId, created FROM histories WHERE gotReward = 1 ORDER BY created ASC`).
+		All(&issued); err != nil {
+		return nil, err
+	}
+
+	var held []struct {
+		RewardId string `db:"rewardId"`
+		Count    int    `db:"count"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`SELECT rewardId, COUNT(*) as count FROM reservations WHERE status = 'held' GROUP BY rewardId`).
+		All(&held); err != nil {
+		return nil, err
+	}
+
+	usages := make([]RewardUsage, 0, len(rewards))
+	for _, reward := range rewards {
+		usage := RewardUsage{
+			Name:           reward.Name(),
+			Point:          reward.Point(),
+			Amount:         reward.Amount(),
+			Reserved:       reward.Reserved(),
+			ExhaustedAfter: -1,
+		}
+		for _, row := range held {
+			if row.RewardId == reward.Id {
+				usage.Held = row.Count
+			}
+		}
+		for _, row := range issued {
+			if row.RewardId != reward.Id {
+				continue
+			}
+			usage.Issued++
+			if usage.Issued == usage.Amount {
+				created, _ := types.ParseDateTime(row.Created)
+				usage.ExhaustedAfter = created.Time().Sub(start).Round(time.Millisecond)
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+func (simulator *Simulator) payoutTotals(fishpi *FakeFishpi) (PayoutTotals, error) {
+	totals := PayoutTotals{
+		Orders: map[string]int{},
+		Points: map[string]int{},
+	}
+
+	var rows []struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
+		Point  int    `db:"point"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`SELECT status, COUNT(*) as count, COALESCE(SUM(point), 0) as point FROM points GROUP BY status`).
+		All(&rows); err != nil {
+		return totals, err
+	}
+	for _, row := range rows {
+		totals.Orders[row.Status] = row.Count
+		totals.Points[row.Status] = row.Point
+	}
+
+	totals.FishpiPayouts, totals.FishpiPoints, totals.FishpiFailures = fishpi.Totals()
+	totals.ChatMessages = fishpi.Messages()
+	return totals, nil
+}
+
+func (simulator *Simulator) checkInvariants(report *Report) error {
+	violate := func(invariant string, format string, args ...any) {
+		report.Violations = append(report.Violations, Violation{Invariant: invariant, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// 1. 免费博饼次数不超过 min(默认次数 + 有效感谢数, 上限)，有效感谢数为预置时活动期间感谢过的不同用户数
+	thankCnts := make(map[string]int, len(simulator.users))
+	for _, user := range simulator.users {
+		thankCnts[user.name] = user.thankCnt
+	}
+	var draws []struct {
+		Name       string `db:"name"`
+		Draws      int    `db:"draws"`
+		HasArticle bool   `db:"hasArticle"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`
+			SELECT u.name, COUNT(DISTINCT h.id) as draws, EXISTS(SELECT 1 FROM articles a WHERE a.userId = u.id) as hasArticle
+			FROM histories h
+			JOIN users u ON h.userId = u.id
+			WHERE h.feeId = ''
+			GROUP BY h.userId
+		`).
+		All(&draws); err != nil {
+		return err
+	}
+	for _, row := range draws {
+		quota := 0
+		if row.HasArticle {
+			quota = min(model.DefaultMooncakeGamblingTimes+thankCnts[row.Name], model.MaxMooncakeGamblingTimes)
+		}
+		if row.Draws > quota {
+			violate(InvariantOverQuotaDraw, "%s 博饼 %d 次，额度 %d 次", row.Name, row.Draws, quota)
+		}
+	}
+
+	// 2. 奖励发放数量不超过库存，且与预留一致
+	for _, usage := range report.Rewards {
+		if usage.Issued > usage.Amount {
+			violate(InvariantOverIssued, "%s 发放 %d 份，库存 %d 份", usage.Name, usage.Issued, usage.Amount)
+		}
+		if usage.Reserved != usage.Issued || usage.Held != usage.Issued {
+			violate(InvariantStockMismatch, "%s 发放 %d 份，预留计数 %d，预留记录 %d 条", usage.Name, usage.Issued, usage.Reserved, usage.Held)
+		}
+	}
+
+	// 3. 每位用户最多一条 isBest
+	var bests []struct {
+		Name  string `db:"name"`
+		Count int    `db:"count"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`
+			SELECT u.name, COUNT(*) as count
+			FROM histories h
+			JOIN users u ON h.userId = u.id
+			WHERE h.isBest = 1
+			GROUP BY h.userId
+			HAVING count > 1
+		`).
+		All(&bests); err != nil {
+		return err
+	}
+	for _, row := range bests {
+		violate(InvariantMultipleBest, "%s 有 %d 条 isBest 记录", row.Name, row.Count)
+	}
+
+	// 4. 福签不超过上限且同类型只送一次
+	var votes []struct {
+		Name     string `db:"name"`
+		Total    int    `db:"total"`
+		Distinct int    `db:"distinctTypes"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`
+			SELECT u.name, COUNT(*) as total, COUNT(DISTINCT v.voteType) as distinctTypes
+			FROM votes v
+			JOIN users u ON v.fromUserId = u.id
+			GROUP BY v.fromUserId
+		`).
+		All(&votes); err != nil {
+		return err
+	}
+	for _, row := range votes {
+		if row.Total > maxVotesPerUser || row.Total != row.Distinct {
+			violate(InvariantOverQuotaVote, "%s 赠送 %d 张福签，其中 %d 种类型", row.Name, row.Total, row.Distinct)
+		}
+	}
+
+	// 5. 每条博饼记录最多一条积分订单
+	var payouts []struct {
+		HistoryId string `db:"historyId"`
+		Count     int    `db:"count"`
+	}
+	if err := simulator.app.DB().
+		NewQuery(`SELECT historyId, COUNT(*) as count FROM points WHERE historyId != '' GROUP BY historyId HAVING count > 1`).
+		All(&payouts); err != nil {
+		return err
+	}
+	for _, row := range payouts {
+		violate(InvariantDuplicatePayout, "博饼记录 %s 有 %d 条积分订单", row.HistoryId, row.Count)
+	}
+
+	// 6. 发放成功的积分与摸鱼派收到的一致
+	success := model.PointStatusSuccess.String()
+	if report.Payouts.Orders[success] != report.Payouts.FishpiPayouts || report.Payouts.Points[success] != report.Payouts.FishpiPoints {
+		violate(InvariantPayoutMismatch, "积分订单成功 %d 笔共 %d 积分，摸鱼派收到 %d 笔共 %d 积分",
+			report.Payouts.Orders[success], report.Payouts.Points[success], report.Payouts.FishpiPayouts, report.Payouts.FishpiPoints)
+	}
+
+	// 7. 账本借贷平衡，用户账户收入等于发放成功的积分
+	ledger := struct {
+		Total    int `db:"total"`
+		Credited int `db:"credited"`
+	}{}
+	if err := simulator.app.DB().
+		NewQuery(`SELECT COALESCE(SUM(amount), 0) as total, COALESCE(SUM(CASE WHEN account LIKE 'user:%' THEN amount ELSE 0 END), 0) as credited FROM ledger_entries`).
+		One(&ledger); err != nil {
+		return err
+	}
+	if ledger.Total != 0 || ledger.Credited != report.Payouts.Points[success] {
+		violate(InvariantLedgerMismatch, "账本借贷合计 %d，用户收入 %d 积分，发放成功 %d 积分",
+			ledger.Total, ledger.Credited, report.Payouts.Points[success])
+	}
+
+	return nil
+}
+
+// Write 输出文本格式的报告
+func (report *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "模拟用户 %d 位，耗时 %s，随机种子 %d\n\n", report.Users, report.Duration.Round(time.Millisecond), report.Seed)
+
+	fmt.Fprintln(w, "接口延迟")
+	for _, latency := range report.Latencies {
+		statuses := []string{}
+		for status, count := range latency.Statuses {
+			statuses = append(statuses, fmt.Sprintf("%d×%d", status, count))
+		}
+		sort.Strings(statuses)
+		fmt.Fprintf(w, "  %-26s 请求 %-6d p50 %-10s p90 %-10s p99 %-10s max %-10s %s\n",
+			latency.Route, latency.Count, round(latency.P50), round(latency.P90), round(latency.P99), round(latency.Max), strings.Join(statuses, " "))
+		for message, count := range latency.Errors {
+			fmt.Fprintf(w, "    %s ×%d\n", message, count)
+		}
+	}
+
+	fmt.Fprintln(w, "\n奖励发放")
+	for _, usage := range report.Rewards {
+		exhausted := "未发完"
+		if usage.ExhaustedAfter >= 0 {
+			exhausted = "发完于 +" + usage.ExhaustedAfter.String()
+		}
+		fmt.Fprintf(w, "  %-10s %4d 积分  %4d/%-4d %s\n", usage.Name, usage.Point, usage.Issued, usage.Amount, exhausted)
+	}
+
+	fmt.Fprintln(w, "\n积分发放")
+	for status, count := range report.Payouts.Orders {
+		fmt.Fprintf(w, "  订单 %-8s %d 笔，%d 积分\n", status, count, report.Payouts.Points[status])
+	}
+	fmt.Fprintf(w, "  摸鱼派收到 %d 笔，%d 积分，失败 %d 次，聊天室消息 %d 条\n",
+		report.Payouts.FishpiPayouts, report.Payouts.FishpiPoints, report.Payouts.FishpiFailures, report.Payouts.ChatMessages)
+
+	fmt.Fprintln(w, "\n一致性检查")
+	if len(report.Violations) == 0 {
+		fmt.Fprintln(w, "  全部通过")
+	}
+	for _, violation := range report.Violations {
+		fmt.Fprintf(w, "  [%s] %s\n", violation.Invariant, violation.Detail)
+	}
+}
+
+func round(duration time.Duration) string {
+	return duration.Round(10 * time.Microsecond).String()
+}
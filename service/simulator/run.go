@@ -0,0 +1,175 @@
+package simulator
+
+import (
+	"bless-activity/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	routeMe       = "GET /user/me"
+	routeVote     = "POST /vote"
+	routeGambling = "POST /mooncake/gambling"
+
+	// maxThrottleRetries 被限流时按 Retry-After 等待后重试的次数
+	maxThrottleRetries = 5
+)
+
+var voteTypes = []string{model.VoteTypeCareer, model.VoteTypeRomance, model.VoteTypeWealth}
+
+// Run 按并发数回放每位用户的流量：查看个人信息、赠送福签、博饼直到次数用完，结束后检查数据一致性
+func (simulator *Simulator) Run(ctx context.Context, baseUrl string, fishpi *FakeFishpi) (*Report, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        simulator.options.Concurrency,
+			MaxIdleConnsPerHost: simulator.options.Concurrency,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	recorder := newRecorder()
+	start := time.Now()
+
+	queue := make(chan *simUser)
+	wg := sync.WaitGroup{}
+	for range simulator.options.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range queue {
+				session := &session{client: client, baseUrl: baseUrl, user: user, recorder: recorder}
+				simulator.play(ctx, session)
+			}
+		}()
+	}
+
+	// 投票目标在投递前生成，保证相同种子得到相同的流量
+	plans := simulator.votePlans()
+	for _, user := range simulator.users {
+		user.votes = plans[user.id]
+		select {
+		case queue <- user:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return simulator.report(start, time.Since(start), recorder, fishpi)
+}
+
+// votePlan 一次赠送福签
+type votePlan struct {
+	articleId string
+	voteType  string
+}
+
+func (simulator *Simulator) votePlans() map[string][]votePlan {
+	plans := map[string][]votePlan{}
+	for _, user := range simulator.users {
+		for range simulator.options.VotesPerUser {
+			target := simulator.users[simulator.rand.IntN(len(simulator.users))]
+			plans[user.id] = append(plans[user.id], votePlan{
+				articleId: target.articleId,
+				voteType:  voteTypes[simulator.rand.IntN(len(voteTypes))],
+			})
+		}
+	}
+	return plans
+}
+
+func (simulator *Simulator) play(ctx context.Context, session *session) {
+	me := struct {
+		RestTimes int `json:"rest_times"`
+	}{}
+	if status := session.do(ctx, routeMe, http.MethodGet, "/user/me", nil, &me); status != http.StatusOK {
+		return
+	}
+
+	// 福签可能送给自己或重复类型，由接口拒绝，用于覆盖校验路径
+	for _, plan := range session.user.votes {
+		session.do(ctx, routeVote, http.MethodPost, "/vote", map[string]any{
+			"article_id": plan.articleId,
+			"vote_type":  plan.voteType,
+		}, nil)
+	}
+
+	for range me.RestTimes + simulator.options.ExtraDraws {
+		if ctx.Err() != nil {
+			return
+		}
+		session.do(ctx, routeGambling, http.MethodPost, "/mooncake/gambling", nil, nil)
+	}
+}
+
+// session 以某位模拟用户的身份发送请求
+type session struct {
+	client   *http.Client
+	baseUrl  string
+	user     *simUser
+	recorder *recorder
+}
+
+// do 发送请求并记录耗时，被限流时等待后重试，返回最终的状态码
+func (session *session) do(ctx context.Context, route string, method string, path string, body any, result any) int {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, session.baseUrl+path, bytes.NewReader(payload))
+		if err != nil {
+			return 0
+		}
+		request.Header.Set("Authorization", session.user.token)
+		request.Header.Set("X-Forwarded-For", session.user.ip)
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		begin := time.Now()
+		response, err := session.client.Do(request)
+		if err != nil {
+			session.recorder.record(route, time.Since(begin), 0, err.Error())
+			return 0
+		}
+		data, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		duration := time.Since(begin)
+
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			session.recorder.record(route, duration, response.StatusCode, "")
+			if result != nil {
+				_ = json.Unmarshal(data, result)
+			}
+			return response.StatusCode
+		}
+
+		failure := struct {
+			Message string `json:"message"`
+		}{}
+		_ = json.Unmarshal(data, &failure)
+		session.recorder.record(route, duration, response.StatusCode, failure.Message)
+
+		if response.StatusCode != http.StatusTooManyRequests || attempt >= maxThrottleRetries {
+			return response.StatusCode
+		}
+		retryAfter, _ := strconv.Atoi(response.Header.Get("Retry-After"))
+		select {
+		case <-time.After(time.Duration(max(retryAfter, 1)) * time.Second):
+		case <-ctx.Done():
+			return response.StatusCode
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package simulator
+
+import (
+	"bless-activity/model"
+	"bless-activity/service"
+	"bless-activity/service/ratelimit"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Options 模拟参数
+type Options struct {
+	Users          int     // 模拟用户数量，每位用户发布一篇文章
+	Concurrency    int     // 同时在线的用户数量
+	VotesPerUser   int     // 每位用户尝试赠送的福签数量
+	MaxThankCnt    int     // 文章感谢数的上限，感谢数决定额外的博饼次数
+	ExtraDraws     int     // 次数用完后继续尝试博饼的次数，用于检验是否会超额
+	RateLimit      bool    // 是否启用默认限流规则，关闭时不限流
+	FishpiFailRate float64 // 模拟摸鱼派积分发放失败的比例
+	Seed           uint64  // 随机种子，相同种子生成相同的用户与文章
+}
+
+// Normalize 填充默认值
+func (options *Options) Normalize() error {
+	if options.Users == 0 {
+		options.Users = 200
+	}
+	if options.Concurrency == 0 {
+		options.Concurrency = 50
+	}
+	if options.VotesPerUser == 0 {
+		options.VotesPerUser = 3
+	}
+	if options.MaxThankCnt == 0 {
+		options.MaxThankCnt = 20
+	}
+	if options.ExtraDraws == 0 {
+		options.ExtraDraws = 2
+	}
+	if options.Seed == 0 {
+		options.Seed = uint64(time.Now().UnixNano())
+	}
+
+	if options.Users < 2 {
+		return errors.New("至少需要两名用户")
+	}
+	if options.Concurrency < 1 {
+		return errors.New("并发数至少为 1")
+	}
+	if options.FishpiFailRate < 0 || options.FishpiFailRate > 1 {
+		return errors.New("发放失败比例需在 0-1 之间")
+	}
+	return nil
+}
+
+// simUser 模拟用户与其文章
+type simUser struct {
+	id        string
+	name      string
+	token     string
+	ip        string
+	articleId string
+	thankCnt  int
+	votes     []votePlan
+}
+
+// Simulator 在临时数据目录中预置数据，通过真实 HTTP 路由回放投票与博饼流量
+type Simulator struct {
+	app     core.App
+	options Options
+	rand    *rand.Rand
+	users   []*simUser
+}
+
+// Prepare 在迁移后的数据库中预置配置、用户与文章，奖励使用迁移中的默认配置，需要在应用初始化之前执行
+func Prepare(app core.App, fishpiUrl string, options Options) (*Simulator, error) {
+	if err := options.Normalize(); err != nil {
+		return nil, err
+	}
+
+	simulator := &Simulator{
+		app:     app,
+		options: options,
+		rand:    rand.New(rand.NewPCG(options.Seed, options.Seed>>32)),
+	}
+
+	// 每位模拟用户使用不同的 IP，按 X-Forwarded-For 识别
+	settings := app.Settings()
+	settings.TrustedProxy.Headers = []string{"X-Forwarded-For"}
+	if err := app.Save(settings); err != nil {
+		return nil, fmt.Errorf("保存设置失败: %w", err)
+	}
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if err := simulator.seedConfigs(txApp, fishpiUrl); err != nil {
+			return err
+		}
+		return simulator.seedUsers(txApp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return simulator, nil
+}
+
+func (simulator *Simulator) seedConfigs(txApp core.App, fishpiUrl string) error {
+	now := time.Now()
+	configs := map[model.ConfigKey]any{
+		model.ConfigKeyFishpi: map[string]any{
+			"base_url":        fishpiUrl,
+			"gold_finger_key": "simulate",
+		},
+		// 模拟期间处于投票阶段，投票与博饼同时进行
+		model.ConfigKeyActivity: service.Schedule{
+			Phases: []service.Phase{
+				{Name: model.ActivityPhaseVoting, StartAt: now.Add(-time.Hour), EndAt: now.Add(24 * time.Hour)},
+				{Name: model.ActivityPhaseSettlement, StartAt: now.Add(24 * time.Hour), EndAt: now.Add(48 * time.Hour)},
+				{Name: model.ActivityPhaseArchive, StartAt: now.Add(48 * time.Hour)},
+			},
+		},
+	}
+	if !simulator.options.RateLimit {
+		// 无效的限制视为不限流
+		rules := ratelimit.Rules{}
+		for name := range ratelimit.DefaultRules() {
+			rules[name] = ratelimit.Rule{}
+		}
+		configs[model.ConfigKeyRatelimit] = rules
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameConfigs)
+	if err != nil {
+		return err
+	}
+	for key, value := range configs {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		// 迁移已写入默认配置，存在时覆盖
+		config := new(model.Config)
+		if err = txApp.RecordQuery(collection).Where(dbx.HashExp{model.ConfigsFieldKey: key}).One(config); err != nil {
+			config = model.NewConfigFromCollection(collection)
+			config.SetKey(key)
+		}
+		config.SetValue(string(data))
+		if err = txApp.Save(config); err != nil {
+			return fmt.Errorf("保存配置 %s 失败: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (simulator *Simulator) seedUsers(txApp core.App) error {
+	usersCollection, err := txApp.FindCollectionByNameOrId(model.DbNameUsers)
+	if err != nil {
+		return err
+	}
+	articlesCollection, err := txApp.FindCollectionByNameOrId(model.DbNameArticles)
+	if err != nil {
+		return err
+	}
+	thanksCollection, err := txApp.FindCollectionByNameOrId(model.DbNameThanks)
+	if err != nil {
+		return err
+	}
+
+	// 密码哈希较慢，所有模拟用户共用第一位用户的密码哈希
+	var passwordHash string
+	for i := 1; i <= simulator.options.Users; i++ {
+		name := fmt.Sprintf("sim%04d", i)
+
+		user := model.NewUserFromCollection(usersCollection)
+		user.SetEmail(name + "@simulate.local")
+		if passwordHash == "" {
+			user.SetRandomPassword()
+		} else {
+			user.SetRaw(core.FieldNamePassword, &core.PasswordFieldValue{Hash: passwordHash})
+		}
+		user.SetName(name)
+		user.SetNickname("模拟用户" + name[3:])
+		user.SetOId(name)
+		if err = txApp.Save(user); err != nil {
+			return fmt.Errorf("保存用户失败: %w", err)
+		}
+		passwordHash = user.GetString(core.FieldNamePassword + ":hash")
+
+		token, err := user.NewAuthToken()
+		if err != nil {
+			return err
+		}
+
+		thankCnt := simulator.rand.IntN(simulator.options.MaxThankCnt + 1)
+		article := model.NewArticleFromCollection(articlesCollection)
+		article.SetUserId(user.Id)
+		article.SetOId(fmt.Sprintf("%d", 1700000000000+i))
+		article.SetTitle(fmt.Sprintf("%s 的活动文章", name))
+		article.SetThankCnt(thankCnt)
+		article.SetCreatedAt(types.NowDateTime())
+		if err = txApp.Save(article); err != nil {
+			return fmt.Errorf("保存文章失败: %w", err)
+		}
+		if err = seedThanks(txApp, thanksCollection, article, name, thankCnt); err != nil {
+			return fmt.Errorf("保存感谢明细失败: %w", err)
+		}
+
+		simulator.users = append(simulator.users, &simUser{
+			id:        user.Id,
+			name:      name,
+			token:     token,
+			ip:        fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+			articleId: article.Id,
+			thankCnt:  thankCnt,
+		})
+	}
+	return nil
+}
+
+// seedThanks 预置 thankCnt 位不同用户在活动期间的感谢，另加不应计入博饼次数的自己感谢、打赏、活动开始前的感谢与重复感谢
+func seedThanks(txApp core.App, collection *core.Collection, article *model.Article, author string, thankCnt int) error {
+	now := time.Now()
+	type seed struct {
+		kind      model.ThankKind
+		fromOId   string
+		thankedAt time.Time
+	}
+	seeds := []seed{
+		{model.ThankKindThank, author, now},
+		{model.ThankKindReward, "fan-reward", now},
+		{model.ThankKindThank, "fan-early", now.Add(-2 * time.Hour)},
+	}
+	for i := range thankCnt {
+		seeds = append(seeds, seed{model.ThankKindThank, fmt.Sprintf("fan%d", i), now})
+	}
+	if thankCnt > 0 {
+		seeds = append(seeds, seed{model.ThankKindThank, "fan0", now.Add(time.Minute)})
+	}
+
+	for i, seed := range seeds {
+		thank := model.NewThankFromCollection(collection)
+		thank.SetArticleId(article.Id)
+		thank.SetOId(fmt.Sprintf("%s-%d", article.OId(), i))
+		thank.SetKind(seed.kind)
+		thank.SetFromOId(seed.fromOId)
+		thankedAt, _ := types.ParseDateTime(seed.thankedAt)
+		thank.SetThankedAt(thankedAt)
+		if err := txApp.Save(thank); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package simulator
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency 单个路由的延迟分位数
+type Latency struct {
+	Route    string         `json:"route"`
+	Count    int            `json:"count"`
+	P50      time.Duration  `json:"p50"`
+	P90      time.Duration  `json:"p90"`
+	P99      time.Duration  `json:"p99"`
+	Max      time.Duration  `json:"max"`
+	Statuses map[int]int    `json:"statuses"`
+	Errors   map[string]int `json:"errors,omitempty"` // 非 2xx 的错误信息
+}
+
+// recorder 并发记录每次请求的耗时与状态码
+type recorder struct {
+	mutex     sync.Mutex
+	durations map[string][]time.Duration
+	statuses  map[string]map[int]int
+	errors    map[string]map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		durations: map[string][]time.Duration{},
+		statuses:  map[string]map[int]int{},
+		errors:    map[string]map[string]int{},
+	}
+}
+
+func (r *recorder) record(route string, duration time.Duration, status int, message string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.durations[route] = append(r.durations[route], duration)
+	if r.statuses[route] == nil {
+		r.statuses[route] = map[int]int{}
+	}
+	r.statuses[route][status]++
+	if message != "" {
+		if r.errors[route] == nil {
+			r.errors[route] = map[string]int{}
+		}
+		r.errors[route][message]++
+	}
+}
+
+// latencies 按路由名称排序输出
+func (r *recorder) latencies() []Latency {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := []Latency{}
+	for route, durations := range r.durations {
+		sorted := slices.Clone(durations)
+		slices.Sort(sorted)
+		result = append(result, Latency{
+			Route:    route,
+			Count:    len(sorted),
+			P50:      Percentile(sorted, 50),
+			P90:      Percentile(sorted, 90),
+			P99:      Percentile(sorted, 99),
+			Max:      sorted[len(sorted)-1],
+			Statuses: r.statuses[route],
+			Errors:   r.errors[route],
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Route < result[j].Route
+	})
+	return result
+}
+
+// Percentile 已排序耗时的第 p 百分位（最近秩法）
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := min(max(int(math.Ceil(p/100*float64(len(sorted)))), 1), len(sorted))
+	return sorted[rank-1]
+}
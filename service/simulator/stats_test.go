@@ -0,0 +1,52 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	cases := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{90, 9 * time.Millisecond},
+		{99, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, c := range cases {
+		if got := Percentile(sorted, c.p); got != c.want {
+			t.Errorf("p%v = %v, want %v", c.p, got, c.want)
+		}
+	}
+
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("空列表 = %v", got)
+	}
+}
+
+func TestOptionsNormalize(t *testing.T) {
+	options := Options{}
+	if err := options.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if options.Users != 200 || options.Concurrency != 50 || options.Seed == 0 {
+		t.Errorf("默认值 = %+v", options)
+	}
+
+	options = Options{Users: 1}
+	if err := options.Normalize(); err == nil {
+		t.Error("一名用户应当报错")
+	}
+	options = Options{FishpiFailRate: 1.5}
+	if err := options.Normalize(); err == nil {
+		t.Error("失败比例超过 1 应当报错")
+	}
+}
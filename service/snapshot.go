@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bless-activity/model"
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	SnapshotVotesFrozen = "votes_frozen" // 投票冻结时的福签统计
+	SnapshotFinal       = "final"        // 归档时的最终活动结果
+)
+
+type SnapshotService struct {
+	app core.App
+}
+
+func NewSnapshotService(app core.App) *SnapshotService {
+	return &SnapshotService{
+		app: app,
+	}
+}
+
+// Save 保存快照，同名快照会被覆盖
+func (service *SnapshotService) Save(name string, phase model.ActivityPhase, data any) error {
+	snapshot, err := service.Find(name)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		collection, collectionErr := service.app.FindCollectionByNameOrId(model.DbNameSnapshots)
+		if collectionErr != nil {
+			return collectionErr
+		}
+		snapshot = model.NewSnapshotFromCollection(collection)
+		snapshot.SetName(name)
+	}
+
+	snapshot.SetPhase(phase)
+	snapshot.SetData(data)
+	return service.app.Save(snapshot)
+}
+
+// Find 按名称查找快照
+func (service *SnapshotService) Find(name string) (*model.Snapshot, error) {
+	snapshot := new(model.Snapshot)
+	if err := service.app.RecordQuery(model.DbNameSnapshots).Where(dbx.HashExp{model.SnapshotsFieldName: name}).One(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// VoteCounts 统计每位用户收到的各类福签数量
+func (service *SnapshotService) VoteCounts() ([]map[string]any, error) {
+	var rows []struct {
+		ToUserId string `db:"toUserId"`
+		VoteType string `db:"voteType"`
+		Count    int    `db:"count"`
+	}
+	if err := service.app.DB().
+		NewQuery(`
+			SELECT toUserId, voteType, COUNT(*) as count
+			FROM votes
+			GROUP BY toUserId, voteType
+			ORDER BY toUserId
+		`).
+		All(&rows); err != nil {
+		return nil, err
+	}
+
+	result := []map[string]any{}
+	index := map[string]map[string]any{}
+	for _, row := range rows {
+		item, ok := index[row.ToUserId]
+		if !ok {
+			item = map[string]any{
+				"user_id":             row.ToUserId,
+				model.VoteTypeCareer:  0,
+				model.VoteTypeRomance: 0,
+				model.VoteTypeWealth:  0,
+				"total":               0,
+			}
+			index[row.ToUserId] = item
+			result = append(result, item)
+		}
+		item[row.VoteType] = row.Count
+		item["total"] = item["total"].(int) + row.Count
+	}
+
+	return result, nil
+}
@@ -0,0 +1,408 @@
+package table
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/mooncakeGambling"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// watchInterval 检查超时回合的间隔
+const watchInterval = 5 * time.Second
+
+var (
+	ErrNotOwner        = errors.New("只有桌主可以开始")
+	ErrSeatedElsewhere = errors.New("已在其他牌桌中")
+)
+
+// CreateOptions 创建牌桌的参数，零值使用默认配置
+type CreateOptions struct {
+	Name        string `json:"name"`
+	Capacity    int    `json:"capacity"`
+	MaxRounds   int    `json:"max_rounds"`
+	TurnTimeout int    `json:"turn_timeout"`
+	Pool        Pool   `json:"pool"`
+}
+
+// Normalize 填充默认值并校验
+func (options *CreateOptions) Normalize() error {
+	options.Name = strings.TrimSpace(options.Name)
+	if options.Capacity == 0 {
+		options.Capacity = 6
+	}
+	if options.MaxRounds == 0 {
+		options.MaxRounds = 10
+	}
+	if options.TurnTimeout == 0 {
+		options.TurnTimeout = 30
+	}
+	if options.Pool == nil {
+		options.Pool = DefaultPool()
+	}
+
+	if options.Name == "" || len([]rune(options.Name)) > 50 {
+		return errors.New("牌桌名称需为 1-50 个字符")
+	}
+	if options.Capacity < 2 || options.Capacity > 10 {
+		return errors.New("人数需在 2-10 之间")
+	}
+	if options.MaxRounds < 1 || options.MaxRounds > 50 {
+		return errors.New("轮数需在 1-50 之间")
+	}
+	if options.TurnTimeout < 10 || options.TurnTimeout > 600 {
+		return errors.New("回合超时需在 10-600 秒之间")
+	}
+	return options.Pool.Validate()
+}
+
+// Service 多人牌桌：建桌、入座、按座位轮流博饼、超时自动博饼与桌内结算
+// 牌桌与回合记录通过 PocketBase realtime 推送给入座的玩家
+type Service struct {
+	app    core.App
+	game   *mooncakeGambling.MooncakeGame
+	logger *slog.Logger
+
+	mutex    sync.Mutex
+	watching sync.Once
+}
+
+func NewService(app core.App) *Service {
+	return &Service{
+		app:    app,
+		game:   mooncakeGambling.NewMooncakeGame(),
+		logger: app.Logger().With(slog.String("service", "table")),
+	}
+}
+
+// Watch 服务启动后定时为超时的玩家自动博饼，应用退出时停止
+// 回合超时精确到秒，Cron 最小间隔为一分钟，因此使用独立的定时器
+func (service *Service) Watch() {
+	service.watching.Do(func() {
+		stop := make(chan struct{})
+		service.app.OnServe().BindFunc(func(event *core.ServeEvent) error {
+			go service.sweep(stop)
+			return event.Next()
+		})
+		service.app.OnTerminate().BindFunc(func(event *core.TerminateEvent) error {
+			close(stop)
+			return event.Next()
+		})
+	})
+}
+
+// sweep 每隔 watchInterval 检查一次超时回合，直到 stop 关闭
+func (service *Service) sweep(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			service.expire(now)
+		}
+	}
+}
+
+// Create 创建牌桌，桌主自动入座
+func (service *Service) Create(ownerId string, options CreateOptions) (*model.Table, error) {
+	if err := options.Normalize(); err != nil {
+		return nil, err
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	var table *model.Table
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		if err := service.checkSeated(txApp, ownerId); err != nil {
+			return err
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId(model.DbNameTables)
+		if err != nil {
+			return err
+		}
+		table = model.NewTableFromCollection(collection)
+		table.SetName(options.Name)
+		table.SetOwnerId(ownerId)
+		table.SetStatus(model.TableStatusWaiting)
+		table.SetCapacity(options.Capacity)
+		table.SetMaxRounds(options.MaxRounds)
+		table.SetTurnTimeout(options.TurnTimeout)
+		table.SetPlayers([]string{ownerId})
+		table.SetPool(options.Pool)
+		table.SetRemaining(options.Pool)
+		return txApp.Save(table)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Join 入座
+func (service *Service) Join(tableId string, userId string) (*model.Table, error) {
+	return service.update(tableId, func(txApp core.App, table *model.Table, state *State) error {
+		if err := service.checkSeated(txApp, userId); err != nil {
+			return err
+		}
+		return state.Join(userId)
+	})
+}
+
+// Leave 开始前离座，桌主离座时由下一位玩家接任
+func (service *Service) Leave(tableId string, userId string) (*model.Table, error) {
+	return service.update(tableId, func(txApp core.App, table *model.Table, state *State) error {
+		if err := state.Leave(userId); err != nil {
+			return err
+		}
+		if table.OwnerId() == userId && len(state.Players) > 0 {
+			table.SetOwnerId(state.Players[0])
+		}
+		return nil
+	})
+}
+
+// Start 桌主开始博饼
+func (service *Service) Start(tableId string, userId string) (*model.Table, error) {
+	return service.update(tableId, func(txApp core.App, table *model.Table, state *State) error {
+		if table.OwnerId() != userId {
+			return ErrNotOwner
+		}
+		if err := state.Start(); err != nil {
+			return err
+		}
+		table.SetTurnDeadline(nextDeadline(table))
+		return nil
+	})
+}
+
+// Roll 当前玩家博饼
+func (service *Service) Roll(tableId string, userId string) (*model.Table, *model.TableTurn, error) {
+	var turn *model.TableTurn
+	table, err := service.update(tableId, func(txApp core.App, table *model.Table, state *State) error {
+		var err error
+		turn, err = service.roll(txApp, table, state, userId, false)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return table, turn, nil
+}
+
+// Find 查找牌桌
+func (service *Service) Find(tableId string) (*model.Table, error) {
+	return findTable(service.app, tableId)
+}
+
+// List 等待中的牌桌与用户所在的牌桌
+func (service *Service) List(userId string) ([]*model.Table, error) {
+	records, err := service.app.FindRecordsByFilter(model.DbNameTables,
+		"status = {:waiting} || players.id ?= {:userId}",
+		"-created", 50, 0,
+		dbx.Params{"waiting": model.TableStatusWaiting, "userId": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*model.Table, 0, len(records))
+	for _, record := range records {
+		tables = append(tables, model.NewTable(record))
+	}
+	return tables, nil
+}
+
+// Turns 牌桌的回合记录，按先后排列
+func (service *Service) Turns(tableId string) ([]*model.TableTurn, error) {
+	return findTurns(service.app, tableId)
+}
+
+// Standings 已结算牌桌的名次
+func Standings(table *model.Table) ([]Standing, error) {
+	standings := []Standing{}
+	if table.Result() == "" || table.Result() == "null" {
+		return standings, nil
+	}
+	if err := json.Unmarshal([]byte(table.Result()), &standings); err != nil {
+		return nil, err
+	}
+	return standings, nil
+}
+
+// update 加锁并在事务中读取牌桌状态、执行变更、写回
+func (service *Service) update(tableId string, fn func(txApp core.App, table *model.Table, state *State) error) (*model.Table, error) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	var table *model.Table
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if table, err = findTable(txApp, tableId); err != nil {
+			return err
+		}
+		state, err := stateOf(table)
+		if err != nil {
+			return err
+		}
+		if err = fn(txApp, table, state); err != nil {
+			return err
+		}
+
+		table.SetStatus(state.Status)
+		table.SetPlayers(state.Players)
+		table.SetSeat(state.Seat)
+		table.SetRound(state.Round)
+		table.SetRemaining(state.Remaining)
+		if state.Status != model.TableStatusPlaying {
+			table.SetTurnDeadline(types.DateTime{})
+		}
+		return txApp.Save(table)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// roll 保存回合记录，牌桌结束时结算名次
+func (service *Service) roll(txApp core.App, table *model.Table, state *State, userId string, auto bool) (*model.TableTurn, error) {
+	played, err := state.Roll(userId, service.game.Play(), auto)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId(model.DbNameTableTurns)
+	if err != nil {
+		return nil, err
+	}
+	turn := model.NewTableTurnFromCollection(collection)
+	turn.SetTableId(table.Id)
+	turn.SetUserId(played.UserId)
+	turn.SetSeat(played.Seat)
+	turn.SetRound(played.Round)
+	turn.SetDices(played.Result.Dices)
+	turn.SetPrizeLevel(int(played.Result.PrizeLevel))
+	turn.SetPrizeName(played.Result.PrizeName)
+	turn.SetPrize(string(played.Prize))
+	turn.SetAuto(auto)
+	if err = txApp.Save(turn); err != nil {
+		return nil, fmt.Errorf("保存回合记录失败: %w", err)
+	}
+
+	if state.Status != model.TableStatusFinished {
+		table.SetTurnDeadline(nextDeadline(table))
+		return turn, nil
+	}
+
+	records, err := findTurns(txApp, table.Id)
+	if err != nil {
+		return nil, err
+	}
+	turns := make([]Turn, 0, len(records))
+	for _, record := range records {
+		turns = append(turns, Turn{
+			UserId: record.UserId(),
+			Seat:   record.Seat(),
+			Round:  record.Round(),
+			Result: service.game.PlayWithDices(record.Dices()),
+			Prize:  Category(record.Prize()),
+			Auto:   record.Auto(),
+		})
+	}
+	table.SetResult(Settle(state.Players, turns))
+	return turn, nil
+}
+
+// expire 为回合超时的玩家自动博饼
+func (service *Service) expire(now time.Time) {
+	deadline, _ := types.ParseDateTime(now)
+	tables := []*model.Table{}
+	if err := service.app.RecordQuery(model.DbNameTables).
+		Where(dbx.HashExp{model.TablesFieldStatus: model.TableStatusPlaying}).
+		AndWhere(dbx.NewExp(model.TablesFieldTurnDeadline+" != '' AND "+model.TablesFieldTurnDeadline+" <= {:now}", dbx.Params{"now": deadline.String()})).
+		All(&tables); err != nil {
+		service.logger.Error("查找超时牌桌失败", slog.Any("err", err))
+		return
+	}
+
+	for _, expired := range tables {
+		_, err := service.update(expired.Id, func(txApp core.App, table *model.Table, state *State) error {
+			// 加锁后重新确认仍然超时，避免与玩家手动博饼冲突
+			if table.TurnDeadline().IsZero() || table.TurnDeadline().Time().After(now) {
+				return nil
+			}
+			_, err := service.roll(txApp, table, state, state.Current(), true)
+			return err
+		})
+		if err != nil {
+			service.logger.Error("超时自动博饼失败", slog.String("table_id", expired.Id), slog.Any("err", err))
+		}
+	}
+}
+
+// checkSeated 每位用户同时只能坐在一张未结束的牌桌
+func (service *Service) checkSeated(txApp core.App, userId string) error {
+	records, err := txApp.FindRecordsByFilter(model.DbNameTables,
+		"(status = {:waiting} || status = {:playing}) && players.id ?= {:userId}",
+		"", 1, 0,
+		dbx.Params{"waiting": model.TableStatusWaiting, "playing": model.TableStatusPlaying, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		return ErrSeatedElsewhere
+	}
+	return nil
+}
+
+// nextDeadline 下一位玩家的回合截止时间
+func nextDeadline(table *model.Table) types.DateTime {
+	deadline, _ := types.ParseDateTime(time.Now().Add(time.Duration(table.TurnTimeout()) * time.Second))
+	return deadline
+}
+
+func stateOf(table *model.Table) (*State, error) {
+	remaining := Pool{}
+	if err := json.Unmarshal([]byte(table.Remaining()), &remaining); err != nil {
+		return nil, fmt.Errorf("解析牌桌奖池失败: %w", err)
+	}
+	return &State{
+		Status:    table.Status(),
+		Capacity:  table.Capacity(),
+		MaxRounds: table.MaxRounds(),
+		Players:   table.Players(),
+		Seat:      table.Seat(),
+		Round:     table.Round(),
+		Remaining: remaining,
+	}, nil
+}
+
+func findTable(app core.App, tableId string) (*model.Table, error) {
+	table := new(model.Table)
+	if err := app.RecordQuery(model.DbNameTables).Where(dbx.HashExp{model.CommonFieldId: tableId}).One(table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func findTurns(app core.App, tableId string) ([]*model.TableTurn, error) {
+	turns := []*model.TableTurn{}
+	if err := app.RecordQuery(model.DbNameTableTurns).
+		Where(dbx.HashExp{model.TableTurnsFieldTableId: tableId}).
+		OrderBy(model.TableTurnsFieldRound+" asc", model.TableTurnsFieldSeat+" asc").
+		All(&turns); err != nil {
+		return nil, err
+	}
+	return turns, nil
+}
@@ -0,0 +1,73 @@
+package table
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestTable 两人一轮的牌桌，alice 开始后轮到 alice
+func newTestTable(t *testing.T, app core.App, service *Service) (*model.Table, *model.User, *model.User) {
+	t.Helper()
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+	table, err := service.Create(alice.Id, CreateOptions{Name: "中秋", Capacity: 2, MaxRounds: 1, TurnTimeout: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.Join(table.Id, bob.Id); err != nil {
+		t.Fatal(err)
+	}
+	if table, err = service.Start(table.Id, alice.Id); err != nil {
+		t.Fatal(err)
+	}
+	return table, alice, bob
+}
+
+func TestService_Expire(t *testing.T) {
+	app := testapp.New(t)
+	service := NewService(app)
+	table, alice, bob := newTestTable(t, app, service)
+
+	// 未超时不自动博饼
+	service.expire(time.Now())
+	if turns, _ := service.Turns(table.Id); len(turns) != 0 {
+		t.Fatalf("未超时自动博饼 %d 次", len(turns))
+	}
+
+	// 超时后为当前玩家自动博饼，轮到下一位并重新计时
+	service.expire(table.TurnDeadline().Time().Add(time.Second))
+	turns, err := service.Turns(table.Id)
+	if err != nil || len(turns) != 1 || turns[0].UserId() != alice.Id || !turns[0].Auto() {
+		t.Fatalf("超时自动博饼 = %v, %v", turns, err)
+	}
+	table, err = service.Find(table.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Status() != model.TableStatusPlaying || table.Seat() != 1 || table.TurnDeadline().IsZero() {
+		t.Fatalf("自动博饼后 status = %s, seat = %d, deadline = %s", table.Status(), table.Seat(), table.TurnDeadline())
+	}
+
+	// 最后一位玩家超时，自动博饼后结算
+	service.expire(table.TurnDeadline().Time().Add(time.Second))
+	if turns, _ = service.Turns(table.Id); len(turns) != 2 || turns[1].UserId() != bob.Id || !turns[1].Auto() {
+		t.Fatalf("第二次超时自动博饼 = %v", turns)
+	}
+	if table, err = service.Find(table.Id); err != nil {
+		t.Fatal(err)
+	}
+	standings, err := Standings(table)
+	if table.Status() != model.TableStatusFinished || !table.TurnDeadline().IsZero() || err != nil || len(standings) != 2 {
+		t.Errorf("结算后 status = %s, deadline = %s, standings = %v, %v", table.Status(), table.TurnDeadline(), standings, err)
+	}
+
+	// 已结束的牌桌不再自动博饼
+	service.expire(time.Now().Add(time.Hour))
+	if turns, _ = service.Turns(table.Id); len(turns) != 2 {
+		t.Errorf("结束后仍自动博饼, 共 %d 次", len(turns))
+	}
+}
@@ -0,0 +1,302 @@
+package table
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/mooncakeGambling"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrTableFull        = errors.New("牌桌已满")
+	ErrAlreadySeated    = errors.New("已在牌桌中")
+	ErrNotSeated        = errors.New("不在牌桌中")
+	ErrNotWaiting       = errors.New("牌桌已开始")
+	ErrNotPlaying       = errors.New("牌桌未在进行中")
+	ErrNotYourTurn      = errors.New("还没轮到你")
+	ErrNotEnoughPlayers = errors.New("至少需要两名玩家")
+)
+
+// Category 桌内奖池的奖项
+type Category string
+
+const (
+	CategoryZhuangYuan Category = "zhuangyuan" // 状元，结算时按 SortResults 决出
+	CategoryDuiTang    Category = "duitang"    // 对堂
+	CategorySanHong    Category = "sanhong"    // 三红
+	CategorySiJin      Category = "sijin"      // 四进
+	CategoryErJu       Category = "erju"       // 二举
+	CategoryYiXiu      Category = "yixiu"      // 一秀
+)
+
+// Categories 奖项从高到低
+var Categories = []Category{CategoryZhuangYuan, CategoryDuiTang, CategorySanHong, CategorySiJin, CategoryErJu, CategoryYiXiu}
+
+// CategoryOf 博饼结果对应的奖项，无奖时返回空
+func CategoryOf(level mooncakeGambling.PrizeLevel) Category {
+	switch {
+	case level.IsTop():
+		return CategoryZhuangYuan
+	case level == mooncakeGambling.PrizeLevelDuiTang:
+		return CategoryDuiTang
+	case level == mooncakeGambling.PrizeLevelSanHong:
+		return CategorySanHong
+	case level == mooncakeGambling.PrizeLevelSiJin:
+		return CategorySiJin
+	case level == mooncakeGambling.PrizeLevelErJu:
+		return CategoryErJu
+	case level == mooncakeGambling.PrizeLevelYiXiu:
+		return CategoryYiXiu
+	}
+	return ""
+}
+
+// Pool 桌内奖池，各奖项的数量
+type Pool map[Category]int
+
+// DefaultPool 传统会饼：1 状元、2 对堂、4 三红、8 四进、16 二举、32 一秀
+func DefaultPool() Pool {
+	return Pool{
+		CategoryZhuangYuan: 1,
+		CategoryDuiTang:    2,
+		CategorySanHong:    4,
+		CategorySiJin:      8,
+		CategoryErJu:       16,
+		CategoryYiXiu:      32,
+	}
+}
+
+// Validate 校验奖池，状元只有一个且其余奖项至少有一个
+func (pool Pool) Validate() error {
+	for category, amount := range pool {
+		if !slices.Contains(Categories, category) {
+			return fmt.Errorf("无效的奖项: %s", category)
+		}
+		if amount < 0 || amount > 100 {
+			return fmt.Errorf("奖项 %s 的数量需在 0-100 之间", category)
+		}
+	}
+	if pool[CategoryZhuangYuan] != 1 {
+		return errors.New("状元奖项数量必须为 1")
+	}
+	if pool.Remaining() == 0 {
+		return errors.New("奖池不能为空")
+	}
+	return nil
+}
+
+// Remaining 除状元外剩余的奖项数量，为 0 时牌桌结束
+func (pool Pool) Remaining() int {
+	total := 0
+	for category, amount := range pool {
+		if category != CategoryZhuangYuan {
+			total += amount
+		}
+	}
+	return total
+}
+
+// Clone 复制奖池
+func (pool Pool) Clone() Pool {
+	clone := Pool{}
+	for category, amount := range pool {
+		clone[category] = amount
+	}
+	return clone
+}
+
+// State 牌桌状态，玩家按座位顺序轮流博饼
+type State struct {
+	Status    model.TableStatus
+	Capacity  int
+	MaxRounds int
+	Players   []string
+	Seat      int  // 当前轮到的座位
+	Round     int  // 当前轮次，从 1 开始
+	Remaining Pool // 剩余奖池
+}
+
+// Join 加入牌桌
+func (state *State) Join(userId string) error {
+	if state.Status != model.TableStatusWaiting {
+		return ErrNotWaiting
+	}
+	if slices.Contains(state.Players, userId) {
+		return ErrAlreadySeated
+	}
+	if len(state.Players) >= state.Capacity {
+		return ErrTableFull
+	}
+	state.Players = append(state.Players, userId)
+	return nil
+}
+
+// Leave 离开牌桌，只能在开始前离开，最后一名玩家离开时牌桌解散
+func (state *State) Leave(userId string) error {
+	if state.Status != model.TableStatusWaiting {
+		return ErrNotWaiting
+	}
+	index := slices.Index(state.Players, userId)
+	if index < 0 {
+		return ErrNotSeated
+	}
+	state.Players = slices.Delete(state.Players, index, index+1)
+	if len(state.Players) == 0 {
+		state.Status = model.TableStatusCancelled
+	}
+	return nil
+}
+
+// Start 开始博饼，从 1 号座位开始
+func (state *State) Start() error {
+	if state.Status != model.TableStatusWaiting {
+		return ErrNotWaiting
+	}
+	if len(state.Players) < 2 {
+		return ErrNotEnoughPlayers
+	}
+	state.Status = model.TableStatusPlaying
+	state.Seat = 0
+	state.Round = 1
+	return nil
+}
+
+// Current 当前轮到的玩家
+func (state *State) Current() string {
+	if state.Status != model.TableStatusPlaying || state.Seat >= len(state.Players) {
+		return ""
+	}
+	return state.Players[state.Seat]
+}
+
+// Turn 一次博饼
+type Turn struct {
+	UserId string
+	Seat   int
+	Round  int
+	Result mooncakeGambling.GameResult
+	Prize  Category // 本次从奖池拿到的奖项，状元在结算时才确定
+	Auto   bool
+}
+
+// Roll 当前玩家博饼：从奖池拿走对应奖项并轮到下一位，奖池拿完或轮次用完时牌桌结束
+func (state *State) Roll(userId string, result mooncakeGambling.GameResult, auto bool) (Turn, error) {
+	if state.Status != model.TableStatusPlaying {
+		return Turn{}, ErrNotPlaying
+	}
+	if state.Current() != userId {
+		return Turn{}, ErrNotYourTurn
+	}
+
+	turn := Turn{
+		UserId: userId,
+		Seat:   state.Seat,
+		Round:  state.Round,
+		Result: result,
+		Auto:   auto,
+	}
+	if category := CategoryOf(result.PrizeLevel); category != "" && category != CategoryZhuangYuan && state.Remaining[category] > 0 {
+		state.Remaining[category]--
+		turn.Prize = category
+	}
+
+	state.Seat++
+	if state.Seat >= len(state.Players) {
+		state.Seat = 0
+		state.Round++
+	}
+	if state.Remaining.Remaining() == 0 || state.Round > state.MaxRounds {
+		state.Status = model.TableStatusFinished
+	}
+	return turn, nil
+}
+
+// Standing 结算名次
+type Standing struct {
+	Rank       int              `json:"rank"`
+	UserId     string           `json:"userId"`
+	Seat       int              `json:"seat"`
+	Dices      [6]int           `json:"dices"`
+	PrizeLevel int              `json:"prizeLevel"`
+	PrizeName  string           `json:"prizeName"`
+	ZhuangYuan bool             `json:"zhuangYuan"`
+	Prizes     map[Category]int `json:"prizes"`
+}
+
+// Settle 按每位玩家最好的一次结果用 SortResults 排名，结果相同时先博出者在前；名列第一且为状元级别的玩家拿走状元
+func Settle(players []string, turns []Turn) []Standing {
+	type best struct {
+		seat   int
+		result mooncakeGambling.GameResult
+		order  int
+	}
+
+	bests := map[string]*best{}
+	prizes := map[string]map[Category]int{}
+	for i, turn := range turns {
+		if prizes[turn.UserId] == nil {
+			prizes[turn.UserId] = map[Category]int{}
+		}
+		if turn.Prize != "" {
+			prizes[turn.UserId][turn.Prize]++
+		}
+		if current, ok := bests[turn.UserId]; !ok || mooncakeGambling.CompareGameResult(turn.Result, current.result) > 0 {
+			bests[turn.UserId] = &best{seat: turn.Seat, result: turn.Result, order: i}
+		}
+	}
+
+	results := make([]mooncakeGambling.GameResult, 0, len(bests))
+	for _, player := range players {
+		if b, ok := bests[player]; ok {
+			results = append(results, b.result)
+		}
+	}
+	mooncakeGambling.SortResults(results)
+
+	// SortResults 只对结果排序，按顺序把结果分配回最早博出该结果的玩家
+	standings := make([]Standing, 0, len(players))
+	assigned := map[string]bool{}
+	for _, result := range results {
+		userId := ""
+		for _, player := range players {
+			b, ok := bests[player]
+			if !ok || assigned[player] || mooncakeGambling.CompareGameResult(b.result, result) != 0 {
+				continue
+			}
+			if userId == "" || b.order < bests[userId].order {
+				userId = player
+			}
+		}
+		assigned[userId] = true
+
+		b := bests[userId]
+		standings = append(standings, Standing{
+			Rank:       len(standings) + 1,
+			UserId:     userId,
+			Seat:       b.seat,
+			Dices:      b.result.Dices,
+			PrizeLevel: int(b.result.PrizeLevel),
+			PrizeName:  b.result.PrizeName,
+			Prizes:     prizes[userId],
+		})
+	}
+	if len(standings) > 0 && mooncakeGambling.PrizeLevel(standings[0].PrizeLevel).IsTop() {
+		standings[0].ZhuangYuan = true
+		standings[0].Prizes[CategoryZhuangYuan]++
+	}
+
+	// 没有博过的玩家排在最后
+	for seat, player := range players {
+		if _, ok := bests[player]; ok {
+			continue
+		}
+		standings = append(standings, Standing{
+			Rank:   len(standings) + 1,
+			UserId: player,
+			Seat:   seat,
+			Prizes: map[Category]int{},
+		})
+	}
+	return standings
+}
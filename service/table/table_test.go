@@ -0,0 +1,145 @@
+package table
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/mooncakeGambling"
+	"errors"
+	"testing"
+)
+
+func roll(dices [6]int) mooncakeGambling.GameResult {
+	return mooncakeGambling.NewMooncakeGame().PlayWithDices(dices)
+}
+
+func TestState(t *testing.T) {
+	state := &State{Status: model.TableStatusWaiting, Capacity: 2, MaxRounds: 2, Remaining: Pool{CategoryZhuangYuan: 1, CategoryYiXiu: 1, CategoryErJu: 1}}
+
+	if err := state.Start(); !errors.Is(err, ErrNotEnoughPlayers) {
+		t.Errorf("一人开始 err = %v", err)
+	}
+	for _, player := range []string{"alice", "bob"} {
+		if err := state.Join(player); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.Join("carol"); !errors.Is(err, ErrTableFull) {
+		t.Errorf("满员入座 err = %v", err)
+	}
+	if err := state.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Join("carol"); !errors.Is(err, ErrNotWaiting) {
+		t.Errorf("开始后入座 err = %v", err)
+	}
+
+	if _, err := state.Roll("bob", roll([6]int{1, 2, 3, 5, 6, 6}), false); !errors.Is(err, ErrNotYourTurn) {
+		t.Errorf("未轮到 err = %v", err)
+	}
+
+	// 奖池没有对堂，一秀从奖池拿走
+	turn, err := state.Roll("alice", roll([6]int{4, 1, 2, 3, 5, 6}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turn.Prize != "" {
+		t.Errorf("奖池没有对堂时不应拿到 %s", turn.Prize)
+	}
+	turn, err = state.Roll("bob", roll([6]int{4, 1, 2, 2, 5, 6}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turn.Prize != CategoryYiXiu || state.Remaining[CategoryYiXiu] != 0 {
+		t.Errorf("一秀 Prize = %s, 剩余 %d", turn.Prize, state.Remaining[CategoryYiXiu])
+	}
+	if state.Round != 2 || state.Current() != "alice" {
+		t.Errorf("第二轮应轮到 alice, Round = %d, Current = %s", state.Round, state.Current())
+	}
+
+	// 奖池已空的一秀不再发放，二举拿完后牌桌结束
+	if turn, _ = state.Roll("alice", roll([6]int{4, 1, 2, 2, 5, 6}), true); turn.Prize != "" || !turn.Auto {
+		t.Errorf("一秀已拿完 Prize = %s", turn.Prize)
+	}
+	if turn, _ = state.Roll("bob", roll([6]int{4, 4, 2, 2, 5, 6}), false); turn.Prize != CategoryErJu {
+		t.Errorf("二举 Prize = %s", turn.Prize)
+	}
+	if state.Status != model.TableStatusFinished {
+		t.Errorf("奖池拿完 Status = %s", state.Status)
+	}
+}
+
+func TestState_MaxRounds(t *testing.T) {
+	state := &State{Status: model.TableStatusWaiting, Capacity: 2, MaxRounds: 1, Remaining: DefaultPool()}
+	_ = state.Join("alice")
+	_ = state.Join("bob")
+	_ = state.Start()
+
+	_, _ = state.Roll("alice", roll([6]int{1, 2, 3, 5, 6, 6}), false)
+	if state.Status != model.TableStatusPlaying {
+		t.Fatal("轮次未结束")
+	}
+	_, _ = state.Roll("bob", roll([6]int{1, 2, 3, 5, 6, 6}), false)
+	if state.Status != model.TableStatusFinished {
+		t.Errorf("轮次用完 Status = %s", state.Status)
+	}
+}
+
+func TestState_Leave(t *testing.T) {
+	state := &State{Status: model.TableStatusWaiting, Capacity: 2, MaxRounds: 1, Remaining: DefaultPool()}
+	_ = state.Join("alice")
+	if err := state.Leave("bob"); !errors.Is(err, ErrNotSeated) {
+		t.Errorf("未入座离开 err = %v", err)
+	}
+	if err := state.Leave("alice"); err != nil || state.Status != model.TableStatusCancelled {
+		t.Errorf("最后一人离开 err = %v, Status = %s", err, state.Status)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	players := []string{"alice", "bob", "carol", "dave"}
+	turns := []Turn{
+		{UserId: "alice", Seat: 0, Round: 1, Result: roll([6]int{4, 4, 4, 4, 1, 2}), Prize: ""},           // 状元四点红
+		{UserId: "bob", Seat: 1, Round: 1, Result: roll([6]int{4, 1, 2, 2, 5, 6}), Prize: CategoryYiXiu},  // 一秀
+		{UserId: "carol", Seat: 2, Round: 1, Result: roll([6]int{4, 4, 4, 4, 1, 2}), Prize: ""},           // 与 alice 相同，但更晚博出
+		{UserId: "alice", Seat: 0, Round: 2, Result: roll([6]int{4, 4, 2, 2, 5, 6}), Prize: CategoryErJu}, // 二举
+		{UserId: "bob", Seat: 1, Round: 2, Result: roll([6]int{4, 4, 4, 4, 4, 1}), Prize: ""},             // 状元五红
+		{UserId: "carol", Seat: 2, Round: 2, Result: roll([6]int{1, 2, 3, 5, 6, 6}), Prize: ""},           // 无奖
+	}
+
+	standings := Settle(players, turns)
+	want := []string{"bob", "alice", "carol", "dave"}
+	if len(standings) != len(want) {
+		t.Fatalf("名次数量 = %d", len(standings))
+	}
+	for i, userId := range want {
+		if standings[i].UserId != userId || standings[i].Rank != i+1 {
+			t.Errorf("第 %d 名 = %s, 期望 %s", i+1, standings[i].UserId, userId)
+		}
+	}
+	if !standings[0].ZhuangYuan || standings[0].Prizes[CategoryZhuangYuan] != 1 || standings[0].Prizes[CategoryYiXiu] != 1 {
+		t.Errorf("状元 = %+v", standings[0])
+	}
+	if standings[1].ZhuangYuan || standings[1].Prizes[CategoryErJu] != 1 {
+		t.Errorf("第二名 = %+v", standings[1])
+	}
+	if standings[3].PrizeName != "" || len(standings[3].Prizes) != 0 {
+		t.Errorf("未博饼的玩家 = %+v", standings[3])
+	}
+}
+
+func TestPool_Validate(t *testing.T) {
+	if err := DefaultPool().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string]Pool{
+		"无状元":   {CategoryYiXiu: 1},
+		"多个状元":  {CategoryZhuangYuan: 2, CategoryYiXiu: 1},
+		"只有状元":  {CategoryZhuangYuan: 1},
+		"无效奖项":  {CategoryZhuangYuan: 1, "unknown": 1},
+		"数量为负数": {CategoryZhuangYuan: 1, CategoryYiXiu: -1},
+	}
+	for name, pool := range invalid {
+		if err := pool.Validate(); err == nil {
+			t.Errorf("%s 期望校验失败", name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/fishpi"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ThankService 文章收到的感谢明细：爬取时逐条保存，博饼次数按明细统计
+type ThankService struct {
+	app             core.App
+	fishpiService   *fishpi.Service
+	activityService *ActivityService
+}
+
+func NewThankService(app core.App, fishpiService *fishpi.Service, activityService *ActivityService) *ThankService {
+	return &ThankService{
+		app:             app,
+		fishpiService:   fishpiService,
+		activityService: activityService,
+	}
+}
+
+// Sync 拉取文章的感谢与打赏明细，保存尚未记录的部分，返回新增的数量
+func (service *ThankService) Sync(article *model.Article) (int, error) {
+	var saved []string
+	if err := service.app.DB().
+		Select(model.ThanksFieldOId).
+		From(model.DbNameThanks).
+		Where(dbx.HashExp{model.ThanksFieldArticleId: article.Id}).
+		Column(&saved); err != nil {
+		return 0, err
+	}
+	exists := make(map[string]bool, len(saved))
+	for _, oId := range saved {
+		exists[oId] = true
+	}
+
+	collection, err := service.app.FindCollectionByNameOrId(model.DbNameThanks)
+	if err != nil {
+		return 0, err
+	}
+
+	const size = 50
+	created := 0
+	for page := 1; ; page++ {
+		response, err := service.fishpiService.GetApiArticleThanks(article.OId(), page, size)
+		if err != nil {
+			return created, err
+		}
+
+		users, err := service.findUsers(response.Data.Thanks)
+		if err != nil {
+			return created, err
+		}
+
+		for _, item := range response.Data.Thanks {
+			kind, kindErr := model.ParseThankKind(item.Type)
+			if kindErr != nil || item.OId == "" || item.UserOId == "" || exists[item.OId] {
+				continue
+			}
+
+			thank := model.NewThankFromCollection(collection)
+			thank.SetArticleId(article.Id)
+			thank.SetOId(item.OId)
+			thank.SetKind(kind)
+			thank.SetFromOId(item.UserOId)
+			thank.SetFromName(item.UserName)
+			thank.SetPoint(item.Point)
+			thankedAt, _ := types.ParseDateTime(time.UnixMilli(item.Time))
+			thank.SetThankedAt(thankedAt)
+			// 感谢者参与了活动时关联用户
+			if userId, ok := users[item.UserOId]; ok {
+				thank.SetFromUserId(userId)
+			}
+			if err = service.app.Save(thank); err != nil {
+				return created, err
+			}
+			exists[item.OId] = true
+			created++
+		}
+
+		if len(response.Data.Thanks) == 0 || page >= response.Data.Pagination.PaginationPageCount {
+			return created, nil
+		}
+	}
+}
+
+// findUsers 一页感谢中参与了活动的感谢者，摸鱼派用户 oId 到用户 id 的映射
+func (service *ThankService) findUsers(thanks []*fishpi.GetApiArticleThanksResponseThank) (map[string]string, error) {
+	oIds := make([]any, 0, len(thanks))
+	for _, item := range thanks {
+		if item.UserOId != "" {
+			oIds = append(oIds, item.UserOId)
+		}
+	}
+	users := map[string]string{}
+	if len(oIds) == 0 {
+		return users, nil
+	}
+
+	records := []*model.User{}
+	if err := service.app.RecordQuery(model.DbNameUsers).
+		Where(dbx.In(model.UsersFieldOId, oIds...)).
+		All(&records); err != nil {
+		return nil, err
+	}
+	for _, user := range records {
+		users[user.OId()] = user.Id
+	}
+	return users, nil
+}
+
+// Count 活动期间感谢过文章的不同用户数，不含作者本人，打赏不计入
+func (service *ThankService) Count(article *model.Article) (int, error) {
+	schedule, err := service.activityService.Schedule()
+	if err != nil {
+		return 0, err
+	}
+	author, err := service.app.FindRecordById(model.DbNameUsers, article.UserId())
+	if err != nil {
+		return 0, err
+	}
+
+	where := dbx.And(
+		dbx.HashExp{
+			model.ThanksFieldArticleId: article.Id,
+			model.ThanksFieldKind:      model.ThankKindThank.String(),
+		},
+		dbx.Not(dbx.HashExp{model.ThanksFieldFromOId: model.NewUser(author).OId()}),
+	)
+	start, end := schedule.ActiveWindow()
+	if !start.IsZero() {
+		from, _ := types.ParseDateTime(start)
+		where = dbx.And(where, dbx.NewExp("[["+model.ThanksFieldThankedAt+"]] >= {:start}", dbx.Params{"start": from.String()}))
+	}
+	if !end.IsZero() {
+		to, _ := types.ParseDateTime(end)
+		where = dbx.And(where, dbx.NewExp("[["+model.ThanksFieldThankedAt+"]] < {:end}", dbx.Params{"end": to.String()}))
+	}
+
+	count := 0
+	err = service.app.DB().
+		Select("COUNT(DISTINCT [[" + model.ThanksFieldFromOId + "]])").
+		From(model.DbNameThanks).
+		Where(where).
+		Row(&count)
+	return count, err
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/config"
+	"bless-activity/service/fishpi"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+// thankAt 一条感谢明细，day 为 2025 年 10 月的日期
+func thankAt(oId string, kind model.ThankKind, from string, day int) *fishpi.GetApiArticleThanksResponseThank {
+	return &fishpi.GetApiArticleThanksResponseThank{
+		OId:      oId,
+		Type:     kind.String(),
+		Time:     time.Date(2025, 10, day, 12, 0, 0, 0, time.UTC).UnixMilli(),
+		UserOId:  from,
+		UserName: "user" + from,
+	}
+}
+
+func TestThankService_SyncAndCount(t *testing.T) {
+	app := testapp.New(t)
+	fake := testapp.NewFishpi(t)
+	registry := testapp.Registry(t, app, testapp.FishpiDefinition(), config.Definition{Key: model.ConfigKeyActivity, Default: func() any {
+		schedule := DefaultSchedule()
+		return &schedule
+	}})
+	activityService := NewActivityService(app, registry)
+	// 活动期间为 10 月 2 日至 10 月 10 日
+	if _, err := activityService.SetSchedule(app, testSchedule()); err != nil {
+		t.Fatal(err)
+	}
+	service := NewThankService(app, fake.Service(t, app, registry), activityService)
+
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+	article := testapp.Article(t, app, alice, "2001")
+
+	thanks := []*fishpi.GetApiArticleThanksResponseThank{
+		thankAt("t1", model.ThankKindThank, "1002", 3),  // bob
+		thankAt("t2", model.ThankKindThank, "1002", 4),  // bob 再次感谢只计一次
+		thankAt("t3", model.ThankKindThank, "1001", 5),  // 作者本人
+		thankAt("t4", model.ThankKindReward, "1003", 5), // 打赏
+		thankAt("t5", model.ThankKindThank, "1004", 1),  // 活动开始前
+		thankAt("t6", model.ThankKindThank, "1005", 10), // 活动结束后
+		thankAt("t7", "unknown", "1006", 5),             // 未知类型不保存
+	}
+	// 未参与活动的用户多次感谢，超过一页
+	for i := range 55 {
+		thanks = append(thanks, thankAt(fmt.Sprintf("s%d", i), model.ThankKindThank, "1009", 6))
+	}
+	fake.SetThanks(article.OId(), thanks...)
+
+	created, err := service.Sync(article)
+	if err != nil || created != len(thanks)-1 {
+		t.Fatalf("同步 %d 条, 期望 %d 条: %v", created, len(thanks)-1, err)
+	}
+	if created, err = service.Sync(article); err != nil || created != 0 {
+		t.Errorf("重复同步新增 %d 条: %v", created, err)
+	}
+
+	// 参与了活动的感谢者关联用户
+	linked := []*model.Thank{}
+	if err = app.RecordQuery(model.DbNameThanks).Where(dbx.Not(dbx.HashExp{model.ThanksFieldFromUserId: ""})).All(&linked); err != nil {
+		t.Fatal(err)
+	}
+	fromUsers := map[string]string{}
+	for _, thank := range linked {
+		fromUsers[thank.OId()] = thank.FromUserId()
+	}
+	if len(fromUsers) != 3 || fromUsers["t1"] != bob.Id || fromUsers["t2"] != bob.Id || fromUsers["t3"] != alice.Id {
+		t.Errorf("关联用户 = %v", fromUsers)
+	}
+
+	// 只统计活动期间感谢过的不同用户：bob 与未参与活动的用户
+	if count, err := service.Count(article); err != nil || count != 2 {
+		t.Errorf("感谢人数 = %d, 期望 2: %v", count, err)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/fishpi"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FishpiProfile 摸鱼派用户资料
+type FishpiProfile struct {
+	OId      string
+	Name     string
+	Nickname string
+	Avatar   string
+}
+
+type UserService struct {
+	app core.App
+}
+
+func NewUserService(app core.App) *UserService {
+	return &UserService{
+		app: app,
+	}
+}
+
+// SaveFishpiUser 按 oId 查找或创建用户并同步资料，同时登记摸鱼派的 _externalAuths
+// 应在事务中调用；爬虫与登录注册可能同时创建同一用户，创建失败时会回查已存在的用户
+func (service *UserService) SaveFishpiUser(txApp core.App, profile FishpiProfile) (*model.User, error) {
+	user, err := service.findByOId(txApp, profile.OId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if user == nil {
+		userCollection, collectionErr := txApp.FindCollectionByNameOrId(model.DbNameUsers)
+		if collectionErr != nil {
+			return nil, collectionErr
+		}
+		user = model.NewUserFromCollection(userCollection)
+		user.SetEmail(fmt.Sprintf("%s@fishpi.cn", profile.OId))
+		user.SetEmailVisibility(true)
+		user.SetVerified(true)
+		user.SetOId(profile.OId)
+		user.SetName(profile.Name)
+		user.SetNickname(profile.Nickname)
+		user.SetAvatar(profile.Avatar)
+		user.SetRandomPassword()
+		if err = txApp.Save(user); err != nil {
+			// oId 唯一索引冲突：用户已被并发创建，回查后按更新处理
+			existing, findErr := service.findByOId(txApp, profile.OId)
+			if findErr != nil {
+				return nil, err
+			}
+			user = existing
+		}
+	}
+
+	if user.Name() != profile.Name || user.Nickname() != profile.Nickname || user.Avatar() != profile.Avatar {
+		user.SetName(profile.Name)
+		user.SetNickname(profile.Nickname)
+		user.SetAvatar(profile.Avatar)
+		if err = txApp.Save(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = service.EnsureFishpiAuth(txApp, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// EnsureFishpiAuth 确保用户已关联摸鱼派账号
+func (service *UserService) EnsureFishpiAuth(txApp core.App, user *model.User) error {
+	_, err := txApp.FindFirstExternalAuthByExpr(dbx.HashExp{
+		"collectionRef": user.Collection().Id,
+		"recordRef":     user.Id,
+		"provider":      fishpi.AuthProviderName,
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	externalAuth := core.NewExternalAuth(txApp)
+	externalAuth.SetCollectionRef(user.Collection().Id)
+	externalAuth.SetRecordRef(user.Id)
+	externalAuth.SetProvider(fishpi.AuthProviderName)
+	externalAuth.SetProviderId(user.OId())
+	return txApp.Save(externalAuth)
+}
+
+func (service *UserService) findByOId(txApp core.App, oId string) (*model.User, error) {
+	user := new(model.User)
+	if err := txApp.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.UsersFieldOId: oId}).One(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bless-activity/model"
+	"bless-activity/service/ledger"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	userRewardRetryJobId = "user_reward_retry"
+	// userRewardRetryBatch 每分钟最多重新发放的订单数，避免摸鱼派接口限流
+	userRewardRetryBatch = 20
+	// userRewardRetryCooldown 重新发放失败后，再次申请的间隔
+	userRewardRetryCooldown = 10 * time.Minute
+	// maxUserRewardRetries 用户最多申请重新发放的次数，超过后需联系运营
+	maxUserRewardRetries = 3
+)
+
+// 我的奖励状态
+const (
+	UserRewardStatusMissed    = "missed"    // 未获得奖励
+	UserRewardStatusUnpaid    = "unpaid"    // 已获奖，积分订单尚未创建（如状元等待结算）
+	UserRewardStatusPending   = "pending"   // 积分待发放
+	UserRewardStatusSuccess   = "success"   // 积分已到账
+	UserRewardStatusFailed    = "failed"    // 积分发放失败
+	UserRewardStatusAllocated = "allocated" // 非积分奖品待领取，其余状态同兑奖记录
+)
+
+var (
+	ErrRetryNotAllowed = errors.New("该奖励不需要重新发放")
+	ErrRetryQueued     = errors.New("已申请重新发放，请等待")
+	ErrRetryCooldown   = errors.New("重新发放失败，请稍后再申请")
+	ErrRetryExhausted  = errors.New("重新发放次数已用完，请联系运营")
+)
+
+// UserReward 用户获得的奖励及其到账情况
+type UserReward struct {
+	HistoryId   string         `json:"history_id"`
+	Times       int            `json:"times"`
+	Dices       [6]int         `json:"dices"`
+	PrizeLevel  int            `json:"prize_level"`
+	AwardName   string         `json:"award_name"`
+	RewardId    string         `json:"reward_id"`
+	RewardName  string         `json:"reward_name"`
+	Kind        string         `json:"kind"`
+	Point       int            `json:"point"`
+	IsTop       bool           `json:"is_top"`
+	GotReward   bool           `json:"got_reward"`
+	Reason      string         `json:"reason"` // 未获得奖励的原因
+	Status      string         `json:"status"`
+	PointsId    string         `json:"points_id"`
+	Error       string         `json:"error"`
+	RetryAt     types.DateTime `json:"retry_at"` // 已申请重新发放时，预计重新发放的时间
+	Retries     int            `json:"retries"`
+	CanRetry    bool           `json:"can_retry"`
+	Fulfillment string         `json:"fulfillment_id"`
+	Created     types.DateTime `json:"created"`
+}
+
+// missReason 未获得奖励的原因，released 为奖品预留被释放时记录的原因
+func missReason(isTop bool, settled bool, released string) string {
+	switch {
+	case released != "":
+		return released
+	case isTop && !settled:
+		return "状元奖励在活动结束后统一结算"
+	case isTop:
+		return "状元结算未获奖"
+	default:
+		return "奖品已发完或不满足领取条件"
+	}
+}
+
+// retryETA 排队重新发放的预计时间：每分钟处理一批，ahead 为排在前面的订单数
+func retryETA(now time.Time, ahead int) time.Time {
+	return now.Truncate(time.Minute).Add(time.Duration(1+ahead/userRewardRetryBatch) * time.Minute)
+}
+
+// UserRewardService 我的奖励：汇总用户的博饼获奖、积分订单与兑奖记录，用户可申请重新发放失败的积分，排队后每分钟分批处理
+type UserRewardService struct {
+	app                 core.App
+	ledgerService       *ledger.Service
+	notificationService *NotificationService
+	logger              *slog.Logger
+
+	running sync.Mutex
+}
+
+func NewUserRewardService(app core.App, ledgerService *ledger.Service, notificationService *NotificationService) *UserRewardService {
+	return &UserRewardService{
+		app:                 app,
+		ledgerService:       ledgerService,
+		notificationService: notificationService,
+		logger:              app.Logger().WithGroup("user_reward"),
+	}
+}
+
+// Start 注册每分钟处理排队的重新发放
+func (service *UserRewardService) Start() {
+	service.app.Cron().MustAdd(userRewardRetryJobId, "* * * * *", func() {
+		// 上一批还没处理完时跳过
+		if !service.running.TryLock() {
+			return
+		}
+		defer service.running.Unlock()
+
+		if _, err := service.RunRetries(time.Now()); err != nil {
+			service.logger.Error("处理重新发放失败", slog.Any("err", err))
+		}
+	})
+}
+
+// List 用户中奖的博饼记录（不含谢谢参与），按时间倒序
+func (service *UserRewardService) List(userId string) ([]UserReward, error) {
+	histories := []*model.Histories{}
+	if err := service.app.RecordQuery(model.DbNameHistories).
+		Where(dbx.HashExp{model.HistoriesFieldUserId: userId}).
+		OrderBy(model.HistoriesFieldCreated + " desc").
+		All(&histories); err != nil {
+		return nil, err
+	}
+
+	rewards := []*model.Reward{}
+	if err := service.app.RecordQuery(model.DbNameRewards).All(&rewards); err != nil {
+		return nil, err
+	}
+	rewardMap := make(map[string]*model.Reward, len(rewards))
+	for _, reward := range rewards {
+		rewardMap[reward.Id] = reward
+	}
+	awards := []*model.Awards{}
+	if err := service.app.RecordQuery(model.DbNameAwards).All(&awards); err != nil {
+		return nil, err
+	}
+	awardMap := make(map[string]*model.Awards, len(awards))
+	for _, award := range awards {
+		awardMap[award.Id] = award
+	}
+
+	pointsList := []*model.Points{}
+	if err := service.app.RecordQuery(model.DbNamePoints).
+		Where(dbx.HashExp{model.PointsFieldUserId: userId}).
+		AndWhere(dbx.Not(dbx.HashExp{model.PointsFieldHistoryId: ""})).
+		All(&pointsList); err != nil {
+		return nil, err
+	}
+	pointsMap := make(map[string]*model.Points, len(pointsList))
+	for _, points := range pointsList {
+		// 付费博饼的扣费订单也关联博饼记录，只取奖励订单
+		if points.Point() > 0 {
+			pointsMap[points.HistoryId()] = points
+		}
+	}
+
+	fulfillments := []*model.Fulfillment{}
+	if err := service.app.RecordQuery(model.DbNameFulfillments).
+		Where(dbx.HashExp{model.FulfillmentsFieldUserId: userId}).
+		All(&fulfillments); err != nil {
+		return nil, err
+	}
+	fulfillmentMap := make(map[string]*model.Fulfillment, len(fulfillments))
+	for _, fulfillment := range fulfillments {
+		fulfillmentMap[fulfillment.HistoryId()] = fulfillment
+	}
+
+	historyIds := make([]any, 0, len(histories))
+	for _, history := range histories {
+		historyIds = append(historyIds, history.Id)
+	}
+	released := map[string]string{}
+	if len(historyIds) > 0 {
+		reservations := []*model.Reservation{}
+		if err := service.app.RecordQuery(model.DbNameReservations).
+			Where(dbx.In(model.ReservationsFieldHistoryId, historyIds...)).
+			AndWhere(dbx.HashExp{model.ReservationsFieldStatus: model.ReservationStatusReleased.String()}).
+			OrderBy(model.ReservationsFieldCreated + " asc").
+			All(&reservations); err != nil {
+			return nil, err
+		}
+		for _, reservation := range reservations {
+			released[reservation.HistoryId()] = reservation.Reason()
+		}
+	}
+
+	settled, err := service.app.CountRecords(model.DbNameSettlements)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]UserReward, 0, len(histories))
+	for _, history := range histories {
+		reward, ok := rewardMap[history.RewardId()]
+		if !ok || reward.Level() <= 0 {
+			continue
+		}
+		item := UserReward{
+			HistoryId:  history.Id,
+			Times:      history.Times(),
+			Dices:      history.Details(),
+			PrizeLevel: reward.Level(),
+			RewardId:   reward.Id,
+			RewardName: reward.Name(),
+			Kind:       reward.Kind().String(),
+			Point:      reward.Point(),
+			IsTop:      history.IsTop(),
+			GotReward:  history.GotReward(),
+			Created:    history.Created(),
+		}
+		if award, ok := awardMap[history.AwardId()]; ok {
+			item.AwardName = award.Name()
+		}
+
+		switch {
+		case !history.GotReward():
+			item.Status = UserRewardStatusMissed
+			item.Reason = missReason(history.IsTop(), settled > 0, released[history.Id])
+		case reward.Kind() != model.RewardKindPoints:
+			item.Status = UserRewardStatusAllocated
+			if fulfillment, ok := fulfillmentMap[history.Id]; ok {
+				item.Status = fulfillment.Status().String()
+				item.Error = fulfillment.Message()
+				item.Fulfillment = fulfillment.Id
+			}
+		default:
+			item.Status = UserRewardStatusUnpaid
+			if points, ok := pointsMap[history.Id]; ok {
+				item.Status = points.Status().String()
+				item.PointsId = points.Id
+				item.Error = points.Error()
+				item.Retries = points.Retries()
+				if points.Status() == model.PointStatusFailed {
+					item.RetryAt = points.RetryAt()
+					item.CanRetry = points.RetryAt().IsZero() && points.Retries() < maxUserRewardRetries
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RequestRetry 申请重新发放博饼记录失败的积分订单，排队后返回订单，RetryAt 为预计重新发放的时间
+// 已在排队时返回 ErrRetryQueued，上次重新发放失败不久时返回 ErrRetryCooldown，两者都会返回订单用于提示时间
+func (service *UserRewardService) RequestRetry(userId string, historyId string) (*model.Points, error) {
+	points := new(model.Points)
+	err := service.app.RunInTransaction(func(txApp core.App) error {
+		if err := txApp.RecordQuery(model.DbNamePoints).
+			Where(dbx.HashExp{
+				model.PointsFieldUserId:    userId,
+				model.PointsFieldHistoryId: historyId,
+			}).
+			AndWhere(dbx.NewExp("[[" + model.PointsFieldPoint + "]] > 0")).
+			One(points); err != nil {
+			return err
+		}
+		if points.Status() != model.PointStatusFailed {
+			return ErrRetryNotAllowed
+		}
+		if !points.RetryAt().IsZero() {
+			return ErrRetryQueued
+		}
+		if points.Retries() >= maxUserRewardRetries {
+			return ErrRetryExhausted
+		}
+		now := time.Now()
+		if points.Retries() > 0 && now.Before(points.Updated().Time().Add(userRewardRetryCooldown)) {
+			return ErrRetryCooldown
+		}
+
+		ahead, err := txApp.CountRecords(model.DbNamePoints, dbx.HashExp{model.PointsFieldStatus: model.PointStatusFailed.String()},
+			dbx.Not(dbx.HashExp{model.PointsFieldRetryAt: ""}))
+		if err != nil {
+			return err
+		}
+		retryAt, err := types.ParseDateTime(retryETA(now, int(ahead)))
+		if err != nil {
+			return err
+		}
+		points.SetRetryAt(retryAt)
+		return txApp.Save(points)
+	})
+	return points, err
+}
+
+// RetryCooldown 上次重新发放失败后，还需等待的时间
+func (service *UserRewardService) RetryCooldown(points *model.Points) time.Duration {
+	return max(0, time.Until(points.Updated().Time().Add(userRewardRetryCooldown)))
+}
+
+// RunRetries 重新发放到时间的排队订单，返回处理的数量，结果通过站内通知告知用户
+func (service *UserRewardService) RunRetries(now time.Time) (int, error) {
+	due, err := types.ParseDateTime(now)
+	if err != nil {
+		return 0, err
+	}
+	queued := []*model.Points{}
+	if err = service.app.RecordQuery(model.DbNamePoints).
+		Where(dbx.HashExp{model.PointsFieldStatus: model.PointStatusFailed.String()}).
+		AndWhere(dbx.Not(dbx.HashExp{model.PointsFieldRetryAt: ""})).
+		AndWhere(dbx.NewExp("[["+model.PointsFieldRetryAt+"]] <= {:due}", dbx.Params{"due": due.String()})).
+		OrderBy(model.PointsFieldRetryAt+" asc", model.PointsFieldCreated+" asc").
+		Limit(userRewardRetryBatch).
+		All(&queued); err != nil {
+		return 0, err
+	}
+
+	for _, points := range queued {
+		user := new(model.User)
+		if err = service.app.RecordQuery(model.DbNameUsers).Where(dbx.HashExp{model.CommonFieldId: points.UserId()}).One(user); err != nil {
+			service.logger.Error("查找用户失败", slog.String("points_id", points.Id), slog.Any("err", err))
+			continue
+		}
+
+		// 先出队再发放，发放失败后需要用户重新申请
+		points.SetRetryAt(types.DateTime{})
+		points.SetRetries(points.Retries() + 1)
+		if err = service.app.Save(points); err != nil {
+			service.logger.Error("更新积分订单失败", slog.String("points_id", points.Id), slog.Any("err", err))
+			continue
+		}
+
+		title, content := "积分已到账", fmt.Sprintf("重新发放的 %d 积分已到账", points.Point())
+		if retryErr := service.ledgerService.Retry(points, user.Name()); retryErr != nil {
+			service.logger.Error("重新发放积分失败",
+				slog.String("user", user.Name()),
+				slog.String("points_id", points.Id),
+				slog.Any("err", retryErr))
+			title, content = "积分重新发放失败", fmt.Sprintf("%d 积分重新发放失败：%s", points.Point(), retryErr)
+		}
+		if _, err = service.notificationService.Send(service.app, points.UserId(), NotificationTypeRewardRetried, title, content, map[string]any{
+			"points_id":  points.Id,
+			"history_id": points.HistoryId(),
+		}); err != nil {
+			service.logger.Error("发送重新发放通知失败", slog.String("points_id", points.Id), slog.Any("err", err))
+		}
+	}
+	return len(queued), nil
+}
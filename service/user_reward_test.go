@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/fulfillment"
+	"bless-activity/service/ledger"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestMissReason(t *testing.T) {
+	cases := []struct {
+		name     string
+		isTop    bool
+		settled  bool
+		released string
+		want     string
+	}{
+		{"奖品发完", false, false, "", "奖品已发完或不满足领取条件"},
+		{"状元待结算", true, false, "", "状元奖励在活动结束后统一结算"},
+		{"状元结算未获奖", true, true, "", "状元结算未获奖"},
+		{"预留被释放", true, true, "状元被超越", "状元被超越"},
+	}
+	for _, c := range cases {
+		if got := missReason(c.isTop, c.settled, c.released); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRetryETA(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 30, 45, 0, time.UTC)
+	cases := []struct {
+		ahead int
+		want  time.Time
+	}{
+		{0, time.Date(2025, 10, 3, 12, 31, 0, 0, time.UTC)},
+		{userRewardRetryBatch - 1, time.Date(2025, 10, 3, 12, 31, 0, 0, time.UTC)},
+		{userRewardRetryBatch, time.Date(2025, 10, 3, 12, 32, 0, 0, time.UTC)},
+		{userRewardRetryBatch*3 + 5, time.Date(2025, 10, 3, 12, 34, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if got := retryETA(now, c.ahead); !got.Equal(c.want) {
+			t.Errorf("ahead %d: got %s, want %s", c.ahead, got, c.want)
+		}
+	}
+}
+
+// newTestUserReward 使用模拟摸鱼派接口发放积分的我的奖励服务
+func newTestUserReward(t *testing.T, app core.App) (*UserRewardService, *ledger.Service, *testapp.Fishpi) {
+	t.Helper()
+	ledgerService, _, fake := newTestLedger(t, app)
+	return NewUserRewardService(app, ledgerService, NewNotificationService(app)), ledgerService, fake
+}
+
+// paidHistory 按博饼接口的方式创建获奖记录并发放积分，发放结果由模拟接口的响应方式决定
+func paidHistory(t *testing.T, app core.App, ledgerService *ledger.Service, user *model.User, times int) (*model.Histories, *model.Points) {
+	t.Helper()
+	reward := findReward(t, app, "一秀奖励")
+	history := newHistory(t, app, user, reward, times)
+	history.SetGotReward(true)
+	if err := app.Save(history); err != nil {
+		t.Fatal(err)
+	}
+	points, err := ledgerService.Pay(ledger.Order{
+		Key:       ledger.HistoryKey(history.Id),
+		Source:    ledger.SourceGambling,
+		UserId:    user.Id,
+		HistoryId: history.Id,
+		Point:     reward.Point(),
+		Memo:      "博饼奖励",
+	}, user.Name())
+	if points == nil {
+		t.Fatalf("创建积分订单失败: %v", err)
+	}
+	return history, points
+}
+
+// expireCooldown 将积分订单的更新时间提前，跳过重新发放的冷却时间
+func expireCooldown(t *testing.T, app core.App, points *model.Points) {
+	t.Helper()
+	updated, _ := types.ParseDateTime(time.Now().Add(-userRewardRetryCooldown))
+	if _, err := app.DB().Update(model.DbNamePoints, dbx.Params{model.PointsFieldUpdated: updated.String()},
+		dbx.HashExp{model.CommonFieldId: points.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserRewardService_List(t *testing.T) {
+	app := testapp.New(t)
+	service, ledgerService, fake := newTestUserReward(t, app)
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+
+	paid, paidPoints := paidHistory(t, app, ledgerService, alice, 1)
+	fake.SetMode(testapp.FishpiReject)
+	failed, _ := paidHistory(t, app, ledgerService, alice, 2)
+	paidHistory(t, app, ledgerService, bob, 1)
+
+	// 实物奖品：获奖后分配兑奖记录
+	physical := testapp.Reward(t, app, "月饼礼盒", 1)
+	physical.SetLevel(2)
+	if err := app.Save(physical); err != nil {
+		t.Fatal(err)
+	}
+	gift := testapp.History(t, app, alice, physical, 3)
+	gift.SetGotReward(true)
+	if err := app.Save(gift); err != nil {
+		t.Fatal(err)
+	}
+	if err := fulfillment.NewService(app, nil).Allocate(app, physical, gift.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// 奖品发完未获得，谢谢参与不列出
+	missed := newHistory(t, app, alice, findReward(t, app, "二举奖励"), 4)
+	newHistory(t, app, alice, findReward(t, app, "谢谢参与"), 5)
+
+	items, err := service.List(alice.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byHistory := map[string]UserReward{}
+	for _, item := range items {
+		byHistory[item.HistoryId] = item
+	}
+	if len(items) != 4 || len(byHistory) != 4 {
+		t.Fatalf("alice 的奖励 %d 条, 期望 4 条: %+v", len(items), items)
+	}
+
+	if item := byHistory[paid.Id]; item.Status != UserRewardStatusSuccess || item.PointsId != paidPoints.Id ||
+		item.AwardName != "一秀" || item.RewardName != "一秀奖励" || item.Point != 8 || item.CanRetry {
+		t.Errorf("已到账 = %+v", item)
+	}
+	if item := byHistory[failed.Id]; item.Status != UserRewardStatusFailed || item.Error == "" || !item.CanRetry || !item.RetryAt.IsZero() {
+		t.Errorf("发放失败 = %+v", item)
+	}
+	if item := byHistory[gift.Id]; item.Status != model.FulfillmentStatusAllocated.String() || item.Fulfillment == "" ||
+		item.Kind != model.RewardKindPhysical.String() || item.AwardName != "月饼礼盒" {
+		t.Errorf("实物奖品 = %+v", item)
+	}
+	if item := byHistory[missed.Id]; item.Status != UserRewardStatusMissed || item.Reason != "奖品已发完或不满足领取条件" || item.AwardName != "二举" {
+		t.Errorf("未获得 = %+v", item)
+	}
+}
+
+func TestUserRewardService_Retry(t *testing.T) {
+	app := testapp.New(t)
+	service, ledgerService, fake := newTestUserReward(t, app)
+	alice := testapp.User(t, app, "1001", "alice")
+	bob := testapp.User(t, app, "1002", "bob")
+
+	fake.SetMode(testapp.FishpiReject)
+	history, _ := paidHistory(t, app, ledgerService, alice, 1)
+	bobHistory, _ := paidHistory(t, app, ledgerService, bob, 1)
+	success, _ := paidHistory(t, app, ledgerService, bob, 2)
+	if _, err := service.RequestRetry(bob.Id, history.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("申请重新发放他人的订单 err = %v", err)
+	}
+	fake.SetMode(testapp.FishpiOK)
+	if err := ledgerService.Retry(findPoints(t, app, success), bob.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RequestRetry(bob.Id, success.Id); !errors.Is(err, ErrRetryNotAllowed) {
+		t.Errorf("已到账的订单 err = %v", err)
+	}
+	fake.SetMode(testapp.FishpiReject)
+
+	// 排队后在预计时间之前不处理，重复申请提示等待
+	points, err := service.RequestRetry(alice.Id, history.Id)
+	if err != nil || points.RetryAt().IsZero() {
+		t.Fatalf("申请重新发放 = %v, %v", points, err)
+	}
+	retryAt := points.RetryAt().Time()
+	if _, err = service.RequestRetry(alice.Id, history.Id); !errors.Is(err, ErrRetryQueued) {
+		t.Errorf("重复申请 err = %v", err)
+	}
+	if count, err := service.RunRetries(retryAt.Add(-time.Second)); err != nil || count != 0 {
+		t.Errorf("预计时间之前处理了 %d 条, %v", count, err)
+	}
+
+	// 重新发放失败后进入冷却，冷却结束前不能再申请
+	for retries := 1; retries <= maxUserRewardRetries; retries++ {
+		if retries > 1 {
+			if _, err = service.RequestRetry(alice.Id, history.Id); !errors.Is(err, ErrRetryCooldown) {
+				t.Fatalf("第 %d 次申请未冷却 err = %v", retries, err)
+			}
+			expireCooldown(t, app, points)
+			if points, err = service.RequestRetry(alice.Id, history.Id); err != nil {
+				t.Fatalf("第 %d 次申请失败: %v", retries, err)
+			}
+		}
+		if count, err := service.RunRetries(points.RetryAt().Time()); err != nil || count != 1 {
+			t.Fatalf("第 %d 次重新发放处理 %d 条, %v", retries, count, err)
+		}
+		points = findPoints(t, app, history)
+		if points.Status() != model.PointStatusFailed || points.Retries() != retries || !points.RetryAt().IsZero() {
+			t.Fatalf("第 %d 次重新发放后 status = %s, retries = %d, retry_at = %s", retries, points.Status(), points.Retries(), points.RetryAt())
+		}
+	}
+
+	// 次数用完后不再允许申请
+	expireCooldown(t, app, points)
+	if _, err = service.RequestRetry(alice.Id, history.Id); !errors.Is(err, ErrRetryExhausted) {
+		t.Errorf("次数用完后 err = %v", err)
+	}
+	items, err := service.List(alice.Id)
+	if err != nil || len(items) != 1 || items[0].CanRetry || items[0].Retries != maxUserRewardRetries {
+		t.Errorf("次数用完后的奖励 = %+v, %v", items, err)
+	}
+
+	// 接口恢复后重新发放到账
+	fake.SetMode(testapp.FishpiOK)
+	if points, err = service.RequestRetry(bob.Id, bobHistory.Id); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := service.RunRetries(points.RetryAt().Time()); err != nil || count != 1 {
+		t.Fatalf("重新发放处理 %d 条, %v", count, err)
+	}
+	if points = findPoints(t, app, bobHistory); points.Status() != model.PointStatusSuccess || points.Retries() != 1 {
+		t.Errorf("重新发放到账后 status = %s, retries = %d", points.Status(), points.Retries())
+	}
+
+	notifications := []*model.Notification{}
+	if err = app.RecordQuery(model.DbNameNotifications).
+		Where(dbx.HashExp{model.NotificationsFieldType: NotificationTypeRewardRetried}).
+		OrderBy(model.NotificationsFieldCreated + " asc").
+		All(&notifications); err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string][]string{}
+	for _, notification := range notifications {
+		titles[notification.UserId()] = append(titles[notification.UserId()], notification.Title())
+	}
+	if len(titles[alice.Id]) != maxUserRewardRetries || titles[alice.Id][0] != "积分重新发放失败" {
+		t.Errorf("alice 的通知 = %v", titles[alice.Id])
+	}
+	if len(titles[bob.Id]) != 1 || titles[bob.Id][0] != "积分已到账" {
+		t.Errorf("bob 的通知 = %v", titles[bob.Id])
+	}
+}
+
+// findPoints 博饼记录的奖励订单
+func findPoints(t *testing.T, app core.App, history *model.Histories) *model.Points {
+	t.Helper()
+	points := new(model.Points)
+	if err := app.RecordQuery(model.DbNamePoints).Where(dbx.HashExp{model.PointsFieldHistoryId: history.Id}).One(points); err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
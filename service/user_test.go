@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bless-activity/internal/testapp"
+	"bless-activity/model"
+	"bless-activity/service/fishpi"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func saveFishpiUser(app core.App, service *UserService, profile FishpiProfile) (*model.User, error) {
+	var user *model.User
+	err := app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		user, err = service.SaveFishpiUser(txApp, profile)
+		return err
+	})
+	return user, err
+}
+
+func countFishpiUser(t *testing.T, app core.App, oId string) (int64, int64) {
+	t.Helper()
+	users, err := app.CountRecords(model.DbNameUsers, dbx.HashExp{model.UsersFieldOId: oId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auths, err := app.CountRecords(core.CollectionNameExternalAuths, dbx.HashExp{"provider": fishpi.AuthProviderName, "providerId": oId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return users, auths
+}
+
+func TestUserService_SaveFishpiUser(t *testing.T) {
+	app := testapp.New(t)
+	service := NewUserService(app)
+
+	// 首次登录创建用户并关联摸鱼派账号
+	profile := FishpiProfile{OId: "1001", Name: "alice", Nickname: "爱丽丝", Avatar: "https://fishpi.cn/a.png"}
+	first, err := saveFishpiUser(app, service, profile)
+	if err != nil {
+		t.Fatalf("首次登录失败: %v", err)
+	}
+	if first.Name() != "alice" || first.Nickname() != "爱丽丝" || first.Avatar() != profile.Avatar {
+		t.Errorf("资料未保存: %s %s %s", first.Name(), first.Nickname(), first.Avatar())
+	}
+
+	// 再次登录复用用户并同步资料
+	profile.Nickname = "小爱"
+	second, err := saveFishpiUser(app, service, profile)
+	if err != nil {
+		t.Fatalf("再次登录失败: %v", err)
+	}
+	if second.Id != first.Id || second.Nickname() != "小爱" {
+		t.Errorf("再次登录应更新同一用户, 得到 %s %s", second.Id, second.Nickname())
+	}
+	if users, auths := countFishpiUser(t, app, "1001"); users != 1 || auths != 1 {
+		t.Errorf("期望 1 个用户 1 条关联, 得到 %d, %d", users, auths)
+	}
+}
+
+func TestUserService_SaveFishpiUserConcurrent(t *testing.T) {
+	app := testapp.New(t)
+	service := NewUserService(app)
+
+	// 查询之后、保存之前被其它请求抢先创建，保存因唯一索引失败后回查已存在的用户
+	raced := false
+	app.OnRecordCreateExecute(model.DbNameUsers).BindFunc(func(e *core.RecordEvent) error {
+		if raced {
+			return e.Next()
+		}
+		raced = true
+		other := model.NewUserFromCollection(e.Record.Collection())
+		other.SetEmail("other@fishpi.cn")
+		other.SetOId(e.Record.GetString(model.UsersFieldOId))
+		other.SetName("crawler")
+		other.SetRandomPassword()
+		if err := e.App.Save(other); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	user, err := saveFishpiUser(app, service, FishpiProfile{OId: "2002", Name: "bob"})
+	if err != nil {
+		t.Fatalf("并发创建应回查已存在的用户: %v", err)
+	}
+	if user.Email() != "other@fishpi.cn" || user.Name() != "bob" {
+		t.Errorf("应更新抢先创建的用户, 得到 %s %s", user.Email(), user.Name())
+	}
+	if users, auths := countFishpiUser(t, app, "2002"); users != 1 || auths != 1 {
+		t.Errorf("期望 1 个用户 1 条关联, 得到 %d, %d", users, auths)
+	}
+
+	// 多个请求同时登录同一用户
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := saveFishpiUser(app, service, FishpiProfile{OId: "3003", Name: "carol"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("并发登录失败: %v", err)
+		}
+	}
+	if users, auths := countFishpiUser(t, app, "3003"); users != 1 || auths != 1 {
+		t.Errorf("期望 1 个用户 1 条关联, 得到 %d, %d", users, auths)
+	}
+}